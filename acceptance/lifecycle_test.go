@@ -327,29 +327,34 @@ var _ = Describe("bosh", func() {
 			nextStep := func() int { stepIndex++; return stepIndex }
 
 			validatingSteps, doneIndex := findStage(outputLines, "validating", doneIndex)
+			Expect(validatingSteps[nextStep()]).To(MatchRegexp("^  Validating deployment manifest" + stageFinishedPattern))
+			if !config.IsLocalStemcell() {
+				Expect(validatingSteps[nextStep()]).To(MatchRegexp("^  Downloading stemcell"))
+			}
 			if !config.IsLocalCPIRelease() {
 				Expect(validatingSteps[nextStep()]).To(MatchRegexp("^  Downloading release 'bosh-warden-cpi'"))
 			}
 			Expect(validatingSteps[nextStep()]).To(MatchRegexp("^  Validating release 'bosh-warden-cpi'" + stageFinishedPattern))
-			Expect(validatingSteps[nextStep()]).To(MatchRegexp("^  Validating release 'sample-release'" + stageFinishedPattern))
 			Expect(validatingSteps[nextStep()]).To(MatchRegexp("^  Validating cpi release" + stageFinishedPattern))
-			Expect(validatingSteps[nextStep()]).To(MatchRegexp("^  Validating deployment manifest" + stageFinishedPattern))
-			if !config.IsLocalStemcell() {
-				Expect(validatingSteps[nextStep()]).To(MatchRegexp("^  Downloading stemcell"))
-			}
 			Expect(validatingSteps[nextStep()]).To(MatchRegexp("^  Validating stemcell" + stageFinishedPattern))
 
 			installingSteps, doneIndex := findStage(outputLines, "installing CPI", doneIndex+1)
 			numInstallingSteps := len(installingSteps)
-			for _, line := range installingSteps[:numInstallingSteps-3] {
+			Expect(installingSteps[0]).To(MatchRegexp("^  Compiling packages" + stageFinishedPattern))
+			for _, line := range installingSteps[1 : numInstallingSteps-3] {
 				Expect(line).To(MatchRegexp("^  Compiling package '.*/.*'" + stageFinishedPattern))
 			}
 			Expect(installingSteps[numInstallingSteps-3]).To(MatchRegexp("^  Installing packages" + stageFinishedPattern))
 			Expect(installingSteps[numInstallingSteps-2]).To(MatchRegexp("^  Rendering job templates" + stageFinishedPattern))
 			Expect(installingSteps[numInstallingSteps-1]).To(MatchRegexp("^  Installing job 'warden_cpi'" + stageFinishedPattern))
 
+			Expect(outputLines[doneIndex+2]).To(MatchRegexp("^Uploading stemcell '.*/.*'" + stageFinishedPattern))
+
+			validatingDeploymentSteps, doneIndex := findStage(outputLines, "validating deployment", doneIndex+1)
+			Expect(validatingDeploymentSteps[0]).To(MatchRegexp("^  Validating release 'sample-release'" + stageFinishedPattern))
+			Expect(validatingDeploymentSteps[1]).To(MatchRegexp("^  Validating deployment jobs" + stageFinishedPattern))
+
 			Expect(outputLines[doneIndex+2]).To(MatchRegexp("^Starting registry" + stageFinishedPattern))
-			Expect(outputLines[doneIndex+3]).To(MatchRegexp("^Uploading stemcell '.*/.*'" + stageFinishedPattern))
 
 			deployingSteps, doneIndex := findStage(outputLines, "deploying", doneIndex+1)
 			numDeployingSteps := len(deployingSteps)
@@ -359,7 +364,8 @@ var _ = Describe("bosh", func() {
 			Expect(deployingSteps[3]).To(MatchRegexp("^  Attaching disk '.*' to VM '.*'" + stageFinishedPattern))
 			Expect(deployingSteps[4]).To(MatchRegexp("^  Rendering job templates" + stageFinishedPattern))
 
-			for _, line := range deployingSteps[5 : numDeployingSteps-3] {
+			Expect(deployingSteps[5]).To(MatchRegexp("^  Compiling packages" + stageFinishedPattern))
+			for _, line := range deployingSteps[6 : numDeployingSteps-3] {
 				Expect(line).To(MatchRegexp("^  Compiling package '.*/.*'" + stageCompiledPackageSkippedPattern))
 			}
 
@@ -499,30 +505,35 @@ fvue6FeCS62q1lOmwKsNHi26szI5qY8b6Xj3cNjhDS5pIfg=
 			nextStep := func() int { stepIndex++; return stepIndex }
 
 			validatingSteps, doneIndex := findStage(outputLines, "validating", doneIndex)
+			Expect(validatingSteps[nextStep()]).To(MatchRegexp("^  Validating deployment manifest" + stageFinishedPattern))
+			if !config.IsLocalStemcell() {
+				Expect(validatingSteps[nextStep()]).To(MatchRegexp("^  Downloading stemcell"))
+			}
 			if !config.IsLocalCPIRelease() {
 				Expect(validatingSteps[nextStep()]).To(MatchRegexp("^  Downloading release 'bosh-warden-cpi'"))
 			}
 			Expect(validatingSteps[nextStep()]).To(MatchRegexp("^  Validating release 'bosh-warden-cpi'" + stageFinishedPattern))
-			Expect(validatingSteps[nextStep()]).To(MatchRegexp("^  Validating release 'dummy'" + stageFinishedPattern))
-			Expect(validatingSteps[nextStep()]).To(MatchRegexp("^  Validating release 'dummyToo'" + stageFinishedPattern))
 			Expect(validatingSteps[nextStep()]).To(MatchRegexp("^  Validating cpi release" + stageFinishedPattern))
-			Expect(validatingSteps[nextStep()]).To(MatchRegexp("^  Validating deployment manifest" + stageFinishedPattern))
-			if !config.IsLocalStemcell() {
-				Expect(validatingSteps[nextStep()]).To(MatchRegexp("^  Downloading stemcell"))
-			}
 			Expect(validatingSteps[nextStep()]).To(MatchRegexp("^  Validating stemcell" + stageFinishedPattern))
 
 			installingSteps, doneIndex := findStage(outputLines, "installing CPI", doneIndex+1)
 			numInstallingSteps := len(installingSteps)
-			for _, line := range installingSteps[:numInstallingSteps-3] {
+			Expect(installingSteps[0]).To(MatchRegexp("^  Compiling packages" + stageFinishedPattern))
+			for _, line := range installingSteps[1 : numInstallingSteps-3] {
 				Expect(line).To(MatchRegexp("^  Compiling package '.*/.*'" + stageFinishedPattern))
 			}
 			Expect(installingSteps[numInstallingSteps-3]).To(MatchRegexp("^  Installing packages" + stageFinishedPattern))
 			Expect(installingSteps[numInstallingSteps-2]).To(MatchRegexp("^  Rendering job templates" + stageFinishedPattern))
 			Expect(installingSteps[numInstallingSteps-1]).To(MatchRegexp("^  Installing job 'warden_cpi'" + stageFinishedPattern))
 
+			Expect(outputLines[doneIndex+2]).To(MatchRegexp("^Uploading stemcell '.*/.*'" + stageFinishedPattern))
+
+			validatingDeploymentSteps, doneIndex := findStage(outputLines, "validating deployment", doneIndex+1)
+			Expect(validatingDeploymentSteps[0]).To(MatchRegexp("^  Validating release 'dummy'" + stageFinishedPattern))
+			Expect(validatingDeploymentSteps[1]).To(MatchRegexp("^  Validating release 'dummyToo'" + stageFinishedPattern))
+			Expect(validatingDeploymentSteps[2]).To(MatchRegexp("^  Validating deployment jobs" + stageFinishedPattern))
+
 			Expect(outputLines[doneIndex+2]).To(MatchRegexp("^Starting registry" + stageFinishedPattern))
-			Expect(outputLines[doneIndex+3]).To(MatchRegexp("^Uploading stemcell '.*/.*'" + stageFinishedPattern))
 
 			deployingSteps, doneIndex := findStage(outputLines, "deploying", doneIndex+1)
 			numDeployingSteps := len(deployingSteps)
@@ -532,7 +543,8 @@ fvue6FeCS62q1lOmwKsNHi26szI5qY8b6Xj3cNjhDS5pIfg=
 			Expect(deployingSteps[3]).To(MatchRegexp("^  Attaching disk '.*' to VM '.*'" + stageFinishedPattern))
 			Expect(deployingSteps[4]).To(MatchRegexp("^  Rendering job templates" + stageFinishedPattern))
 
-			for _, line := range deployingSteps[5 : numDeployingSteps-3] {
+			Expect(deployingSteps[5]).To(MatchRegexp("^  Compiling packages" + stageFinishedPattern))
+			for _, line := range deployingSteps[6 : numDeployingSteps-3] {
 				Expect(line).To(MatchRegexp("^  Compiling package '.*/.*'" + stageFinishedPattern))
 			}
 
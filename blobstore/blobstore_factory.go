@@ -1,6 +1,8 @@
 package blobstore
 
 import (
+	gobytes "bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -9,12 +11,20 @@ import (
 	boshdavcliconf "github.com/cloudfoundry/bosh-davcli/config"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+	s3client "github.com/pivotal-golang/s3cli/client"
+	s3config "github.com/pivotal-golang/s3cli/config"
 )
 
 type Factory interface {
+	// Create returns a blobstore backed by the agent's DAV server at the mbus URL.
 	Create(string, *http.Client) (Blobstore, error)
+
+	// CreateFromProvider returns a remote blobstore configured the same way as
+	// the agent's blobstore settings. Supported providers are dav, s3 and gcs.
+	CreateFromProvider(string, biproperty.Map, *http.Client) (Blobstore, error)
 }
 
 type blobstoreFactory struct {
@@ -46,6 +56,78 @@ func (f blobstoreFactory) Create(blobstoreURL string, httpClient *http.Client) (
 	return NewBlobstore(davClient, f.uuidGenerator, f.fs, f.logger), nil
 }
 
+func (f blobstoreFactory) CreateFromProvider(provider string, options biproperty.Map, httpClient *http.Client) (Blobstore, error) {
+	switch provider {
+	case "dav":
+		return f.createDAV(options, httpClient)
+	case "s3":
+		return f.createS3(options)
+	case "gcs":
+		return f.createS3(f.gcsOptions(options))
+	default:
+		return nil, bosherr.Errorf("Unknown blobstore provider '%s', expected one of dav, s3 or gcs", provider)
+	}
+}
+
+func (f blobstoreFactory) createDAV(options biproperty.Map, httpClient *http.Client) (Blobstore, error) {
+	endpoint, _ := options["endpoint"].(string)
+	if endpoint == "" {
+		return nil, bosherr.Error("Expected dav blobstore option 'endpoint' to be non-empty")
+	}
+
+	user, _ := options["user"].(string)
+	password, _ := options["password"].(string)
+
+	davConfig := boshdavcliconf.Config{
+		Endpoint: endpoint,
+		User:     user,
+		Password: password,
+	}
+
+	davClient := boshdavcli.NewClient(davConfig, httpClient, f.logger)
+
+	return NewBlobstore(davClient, f.uuidGenerator, f.fs, f.logger), nil
+}
+
+func (f blobstoreFactory) createS3(options biproperty.Map) (Blobstore, error) {
+	optionsBytes, err := json.Marshal(options)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Marshalling s3 blobstore options")
+	}
+
+	s3Config, err := s3config.NewFromReader(gobytes.NewBuffer(optionsBytes))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading s3 blobstore options")
+	}
+
+	s3SDK, err := s3client.NewSDK(s3Config)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Building s3 client SDK")
+	}
+
+	s3Client, err := s3client.New(s3SDK, &s3Config)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Building s3 client")
+	}
+
+	return NewS3Blobstore(&s3Client, f.uuidGenerator, f.fs, f.logger), nil
+}
+
+// gcsOptions talks to GCS through its S3 interoperable XML API, which
+// authenticates with HMAC keys and only supports v2 signatures.
+func (f blobstoreFactory) gcsOptions(options biproperty.Map) biproperty.Map {
+	gcsOptions := biproperty.Map{
+		"host":              "storage.googleapis.com",
+		"signature_version": "2",
+	}
+
+	for key, value := range options {
+		gcsOptions[key] = value
+	}
+
+	return gcsOptions
+}
+
 func (f blobstoreFactory) parseBlobstoreURL(blobstoreURL string) (Config, error) {
 	parsedURL, err := url.Parse(blobstoreURL)
 	if err != nil {
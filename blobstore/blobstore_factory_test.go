@@ -12,6 +12,7 @@ import (
 	boshdavcliconf "github.com/cloudfoundry/bosh-davcli/config"
 	bihttpclient "github.com/cloudfoundry/bosh-utils/httpclient"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
 )
@@ -64,4 +65,58 @@ var _ = Describe("BlobstoreFactory", func() {
 			})
 		})
 	})
+
+	Describe("CreateFromProvider", func() {
+		It("returns a dav blobstore for the configured endpoint", func() {
+			blobstore, err := blobstoreFactory.CreateFromProvider("dav", biproperty.Map{
+				"endpoint": "https://fake-host:25250",
+				"user":     "fake-user",
+				"password": "fake-password",
+			}, httpClient)
+			Expect(err).ToNot(HaveOccurred())
+
+			davClient := boshdavcli.NewClient(boshdavcliconf.Config{
+				Endpoint: "https://fake-host:25250",
+				User:     "fake-user",
+				Password: "fake-password",
+			}, httpClient, logger)
+			Expect(blobstore).To(Equal(NewBlobstore(davClient, fakeUUIDGenerator, fs, logger)))
+		})
+
+		It("returns an error when dav endpoint is missing", func() {
+			_, err := blobstoreFactory.CreateFromProvider("dav", biproperty.Map{}, httpClient)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Expected dav blobstore option 'endpoint' to be non-empty"))
+		})
+
+		It("returns an s3 blobstore", func() {
+			_, err := blobstoreFactory.CreateFromProvider("s3", biproperty.Map{
+				"bucket_name":       "fake-bucket",
+				"access_key_id":     "fake-access-key-id",
+				"secret_access_key": "fake-secret-access-key",
+			}, httpClient)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns an error when s3 options are invalid", func() {
+			_, err := blobstoreFactory.CreateFromProvider("s3", biproperty.Map{}, httpClient)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("bucket_name must be set"))
+		})
+
+		It("returns a gcs blobstore", func() {
+			_, err := blobstoreFactory.CreateFromProvider("gcs", biproperty.Map{
+				"bucket_name":       "fake-bucket",
+				"access_key_id":     "fake-hmac-access-key-id",
+				"secret_access_key": "fake-hmac-secret",
+			}, httpClient)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns an error for an unknown provider", func() {
+			_, err := blobstoreFactory.CreateFromProvider("local", biproperty.Map{}, httpClient)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unknown blobstore provider 'local'"))
+		})
+	})
 })
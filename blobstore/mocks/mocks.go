@@ -5,6 +5,7 @@ package mocks
 
 import (
 	blobstore "github.com/cloudfoundry/bosh-cli/blobstore"
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
 	gomock "github.com/golang/mock/gomock"
 	http "net/http"
 )
@@ -41,6 +42,17 @@ func (_mr *_MockFactoryRecorder) Create(arg0, arg1 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Create", arg0, arg1)
 }
 
+func (_m *MockFactory) CreateFromProvider(_param0 string, _param1 biproperty.Map, _param2 *http.Client) (blobstore.Blobstore, error) {
+	ret := _m.ctrl.Call(_m, "CreateFromProvider", _param0, _param1, _param2)
+	ret0, _ := ret[0].(blobstore.Blobstore)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockFactoryRecorder) CreateFromProvider(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "CreateFromProvider", arg0, arg1, arg2)
+}
+
 // Mock of Blobstore interface
 type MockBlobstore struct {
 	ctrl     *gomock.Controller
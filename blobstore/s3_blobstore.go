@@ -0,0 +1,86 @@
+package blobstore
+
+import (
+	"io"
+	"os"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+)
+
+// S3Client is the part of the s3cli client used to move blobs in and out of
+// an S3 compatible bucket.
+type S3Client interface {
+	Get(src string, dest io.WriterAt) error
+	Put(src io.ReadSeeker, dest string) error
+}
+
+type s3Blobstore struct {
+	s3Client      S3Client
+	uuidGenerator boshuuid.Generator
+	fs            boshsys.FileSystem
+	logger        boshlog.Logger
+	logTag        string
+}
+
+func NewS3Blobstore(s3Client S3Client, uuidGenerator boshuuid.Generator, fs boshsys.FileSystem, logger boshlog.Logger) Blobstore {
+	return &s3Blobstore{
+		s3Client:      s3Client,
+		uuidGenerator: uuidGenerator,
+		fs:            fs,
+		logger:        logger,
+		logTag:        "s3Blobstore",
+	}
+}
+
+func (b *s3Blobstore) Get(blobID string) (LocalBlob, error) {
+	file, err := b.fs.TempFile("bosh-init-local-blob")
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Creating temp file for blob")
+	}
+
+	destinationPath := file.Name()
+
+	b.logger.Debug(b.logTag, "Downloading blob %s to %s", blobID, destinationPath)
+
+	err = b.s3Client.Get(blobID, file)
+	if err != nil {
+		_ = file.Close()
+		return nil, bosherr.WrapErrorf(err, "Getting blob %s from blobstore", blobID)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Closing blob file '%s'", destinationPath)
+	}
+
+	return NewLocalBlob(destinationPath, b.fs, b.logger), nil
+}
+
+func (b *s3Blobstore) Add(sourcePath string) (string, error) {
+	blobID, err := b.uuidGenerator.Generate()
+	if err != nil {
+		return "", bosherr.WrapError(err, "Generating Blob ID")
+	}
+
+	b.logger.Debug(b.logTag, "Uploading blob %s from %s", blobID, sourcePath)
+
+	file, err := b.fs.OpenFile(sourcePath, os.O_RDONLY, 0)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Opening file for reading %s", sourcePath)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			b.logger.Warn(b.logTag, "Couldn't close source file: %s", err.Error())
+		}
+	}()
+
+	err = b.s3Client.Put(file, blobID)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Putting file '%s' into blobstore (via S3 client) as blobID '%s'", sourcePath, blobID)
+	}
+
+	return blobID, nil
+}
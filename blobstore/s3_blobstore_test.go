@@ -0,0 +1,114 @@
+package blobstore_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+
+	. "github.com/cloudfoundry/bosh-cli/blobstore"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeS3Client struct {
+	GetSrc string
+	GetErr error
+
+	PutDest     string
+	PutContents string
+	PutErr      error
+}
+
+func (c *fakeS3Client) Get(src string, dest io.WriterAt) error {
+	c.GetSrc = src
+	return c.GetErr
+}
+
+func (c *fakeS3Client) Put(src io.ReadSeeker, dest string) error {
+	c.PutDest = dest
+
+	contents, err := ioutil.ReadAll(src)
+	if err != nil {
+		return err
+	}
+
+	c.PutContents = string(contents)
+
+	return c.PutErr
+}
+
+var _ = Describe("S3Blobstore", func() {
+	var (
+		s3Client          *fakeS3Client
+		fakeUUIDGenerator *fakeuuid.FakeGenerator
+		fs                *fakesys.FakeFileSystem
+		blobstore         Blobstore
+	)
+
+	BeforeEach(func() {
+		s3Client = &fakeS3Client{}
+		fakeUUIDGenerator = fakeuuid.NewFakeGenerator()
+		fs = fakesys.NewFakeFileSystem()
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+
+		blobstore = NewS3Blobstore(s3Client, fakeUUIDGenerator, fs, logger)
+	})
+
+	Describe("Get", func() {
+		BeforeEach(func() {
+			fs.ReturnTempFile = fakesys.NewFakeFile("fake-destination-path", fs)
+		})
+
+		It("downloads the blob to a local file", func() {
+			localBlob, err := blobstore.Get("fake-blob-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(localBlob.Path()).To(Equal("fake-destination-path"))
+			Expect(s3Client.GetSrc).To(Equal("fake-blob-id"))
+		})
+
+		It("returns an error when getting from the bucket fails", func() {
+			s3Client.GetErr = errors.New("fake-get-error")
+
+			_, err := blobstore.Get("fake-blob-id")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-get-error"))
+		})
+
+		It("returns an error when creating the temp file fails", func() {
+			fs.TempFileError = errors.New("fake-temp-file-error")
+
+			_, err := blobstore.Get("fake-blob-id")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-temp-file-error"))
+		})
+	})
+
+	Describe("Add", func() {
+		BeforeEach(func() {
+			fs.RegisterOpenFile("fake-source-path", &fakesys.FakeFile{
+				Contents: []byte("fake-contents"),
+			})
+		})
+
+		It("uploads the file under a new blob ID", func() {
+			fakeUUIDGenerator.GeneratedUUID = "fake-blob-id"
+
+			blobID, err := blobstore.Add("fake-source-path")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blobID).To(Equal("fake-blob-id"))
+			Expect(s3Client.PutDest).To(Equal("fake-blob-id"))
+			Expect(s3Client.PutContents).To(Equal("fake-contents"))
+		})
+
+		It("returns an error when putting into the bucket fails", func() {
+			s3Client.PutErr = errors.New("fake-put-error")
+
+			_, err := blobstore.Add("fake-source-path")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-put-error"))
+		})
+	})
+})
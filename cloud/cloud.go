@@ -1,6 +1,7 @@
 package cloud
 
 import (
+	"encoding/json"
 	"fmt"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -8,7 +9,22 @@ import (
 	biproperty "github.com/cloudfoundry/bosh-utils/property"
 )
 
+// MaxCPIAPIVersion is the highest CPI API version this CLI speaks. CPIs
+// reporting a higher version in their info are talked to with this one.
+const MaxCPIAPIVersion = 2
+
+// MinRegistryLessStemcellAPIVersion is the lowest stemcell api_version whose
+// agent reads its settings from the CPI (e.g. a config drive) instead of the
+// registry.
+const MinRegistryLessStemcellAPIVersion = 2
+
+type CPIInfo struct {
+	StemcellFormats []string `json:"stemcell_formats"`
+	APIVersion      int      `json:"api_version"`
+}
+
 type Cloud interface {
+	Info() (CPIInfo, error)
 	CreateStemcell(imagePath string, cloudProperties biproperty.Map) (stemcellCID string, err error)
 	DeleteStemcell(stemcellCID string) error
 	HasVM(vmCID string) (bool, error)
@@ -20,9 +36,14 @@ type Cloud interface {
 		env biproperty.Map,
 	) (vmCID string, err error)
 	SetVMMetadata(cmCID string, metadata VMMetadata) error
+	// UsesRegistry reports whether agent settings are passed to the agent
+	// through the registry rather than by the CPI in create_vm.
+	UsesRegistry() bool
 	DeleteVM(vmCID string) error
 	CreateDisk(size int, cloudProperties biproperty.Map, vmCID string) (diskCID string, err error)
-	AttachDisk(vmCID, diskCID string) error
+	// AttachDisk returns the disk hint of CPIs speaking version 2 of the CPI
+	// API, which the agent needs to find the disk, or nil.
+	AttachDisk(vmCID, diskCID string) (diskHint interface{}, err error)
 	DetachDisk(vmCID, diskCID string) error
 	DeleteDisk(diskCID string) error
 	fmt.Stringer
@@ -37,19 +58,72 @@ type cloud struct {
 
 type VMMetadata map[string]string
 
+// NewCloud returns a Cloud that talks to the CPI with version 1 of the CPI API.
 func NewCloud(
 	cpiCmdRunner CPICmdRunner,
 	directorID string,
 	logger boshlog.Logger,
 ) Cloud {
+	return NewCloudWithAPIVersion(cpiCmdRunner, directorID, 1, 0, logger)
+}
+
+// NewCloudWithAPIVersion returns a Cloud that talks to the CPI with the given
+// version of the CPI API, as negotiated from the CPI's info. The api_version
+// of the stemcell, if known, is sent to CPIs speaking version 2.
+func NewCloudWithAPIVersion(
+	cpiCmdRunner CPICmdRunner,
+	directorID string,
+	apiVersion int,
+	stemcellAPIVersion int,
+	logger boshlog.Logger,
+) Cloud {
+	context := CmdContext{DirectorID: directorID}
+	if apiVersion > 1 {
+		context.APIVersion = apiVersion
+
+		if stemcellAPIVersion > 0 {
+			context.VM = &VMContext{Stemcell: StemcellContext{APIVersion: stemcellAPIVersion}}
+		}
+	}
+
 	return cloud{
 		cpiCmdRunner: cpiCmdRunner,
-		context:      CmdContext{DirectorID: directorID},
+		context:      context,
 		logger:       logger,
 		logTag:       "cloud",
 	}
 }
 
+func (c cloud) Info() (CPIInfo, error) {
+	method := "info"
+	cmdOutput, err := c.cpiCmdRunner.Run(c.context, method)
+	if err != nil {
+		return CPIInfo{}, bosherr.WrapError(err, "Calling CPI 'info' method")
+	}
+
+	if cmdOutput.Error != nil {
+		return CPIInfo{}, NewCPIError(method, *cmdOutput.Error)
+	}
+
+	resultBytes, err := json.Marshal(cmdOutput.Result)
+	if err != nil {
+		return CPIInfo{}, bosherr.WrapErrorf(err, "Marshalling external CPI command result: '%#v'", cmdOutput.Result)
+	}
+
+	info := CPIInfo{}
+	err = json.Unmarshal(resultBytes, &info)
+	if err != nil {
+		return CPIInfo{}, bosherr.Errorf("Unexpected external CPI command result: '%#v'", cmdOutput.Result)
+	}
+
+	// CPIs predating the api_version key speak version 1
+	if info.APIVersion == 0 {
+		info.APIVersion = 1
+	}
+
+	return info, nil
+}
+
 func (c cloud) CreateStemcell(imagePath string, cloudProperties biproperty.Map) (string, error) {
 	c.logger.Debug(c.logTag, "Creating stemcell")
 
@@ -132,12 +206,20 @@ func (c cloud) CreateVM(
 		return "", NewCPIError(method, *cmdOutput.Error)
 	}
 
-	// for create_vm, the result is a string of the vm cid
-	cidString, ok := cmdOutput.Result.(string)
-	if !ok {
-		return "", bosherr.Errorf("Unexpected external CPI command result: '%#v'", cmdOutput.Result)
+	// for create_vm, the result is a string of the vm cid with version 1 of the
+	// CPI API, and a list of the vm cid and its networks with version 2
+	switch result := cmdOutput.Result.(type) {
+	case string:
+		return result, nil
+	case []interface{}:
+		if len(result) > 0 {
+			if cidString, ok := result[0].(string); ok {
+				return cidString, nil
+			}
+		}
 	}
-	return cidString, nil
+
+	return "", bosherr.Errorf("Unexpected external CPI command result: '%#v'", cmdOutput.Result)
 }
 
 func (c cloud) SetVMMetadata(vmCID string, metadata VMMetadata) error {
@@ -159,6 +241,10 @@ func (c cloud) SetVMMetadata(vmCID string, metadata VMMetadata) error {
 	return nil
 }
 
+func (c cloud) UsesRegistry() bool {
+	return c.context.VM == nil || c.context.VM.Stemcell.APIVersion < MinRegistryLessStemcellAPIVersion
+}
+
 func (c cloud) CreateDisk(size int, cloudProperties biproperty.Map, vmCID string) (string, error) {
 	c.logger.Debug(c.logTag,
 		"Creating disk with size %d, cloudProperties %#v, instanceID %s",
@@ -189,7 +275,7 @@ func (c cloud) CreateDisk(size int, cloudProperties biproperty.Map, vmCID string
 	return cidString, nil
 }
 
-func (c cloud) AttachDisk(vmCID, diskCID string) error {
+func (c cloud) AttachDisk(vmCID, diskCID string) (interface{}, error) {
 	c.logger.Debug(c.logTag, "Attaching disk '%s' to vm '%s'", diskCID, vmCID)
 	method := "attach_disk"
 	cmdOutput, err := c.cpiCmdRunner.Run(
@@ -199,14 +285,20 @@ func (c cloud) AttachDisk(vmCID, diskCID string) error {
 		diskCID,
 	)
 	if err != nil {
-		return bosherr.WrapError(err, "Calling CPI 'attach_disk' method")
+		return nil, bosherr.WrapError(err, "Calling CPI 'attach_disk' method")
 	}
 
 	if cmdOutput.Error != nil {
-		return NewCPIError(method, *cmdOutput.Error)
+		return nil, NewCPIError(method, *cmdOutput.Error)
 	}
 
-	return nil
+	// with version 2 of the CPI API, the result is the disk hint that is
+	// otherwise written to the registry by the CPI
+	if c.context.APIVersion < 2 {
+		return nil, nil
+	}
+
+	return cmdOutput.Result, nil
 }
 
 func (c cloud) DetachDisk(vmCID, diskCID string) error {
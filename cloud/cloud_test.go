@@ -49,6 +49,53 @@ var _ = Describe("Cloud", func() {
 		})
 	}
 
+	Describe("Info", func() {
+		It("returns the stemcell formats and api version of the cpi", func() {
+			fakeCPICmdRunner.RunCmdOutput = CmdOutput{
+				Result: map[string]interface{}{
+					"stemcell_formats": []interface{}{"fake-format"},
+					"api_version":      2,
+				},
+			}
+
+			info, err := cloud.Info()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info).To(Equal(CPIInfo{StemcellFormats: []string{"fake-format"}, APIVersion: 2}))
+			Expect(fakeCPICmdRunner.RunInputs).To(Equal([]fakebicloud.RunInput{
+				{Context: context, Method: "info", Arguments: nil},
+			}))
+		})
+
+		It("defaults to api version 1 when the cpi does not report one", func() {
+			fakeCPICmdRunner.RunCmdOutput = CmdOutput{
+				Result: map[string]interface{}{"stemcell_formats": []interface{}{}},
+			}
+
+			info, err := cloud.Info()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.APIVersion).To(Equal(1))
+		})
+
+		Context("when the result is of an unexpected type", func() {
+			BeforeEach(func() {
+				fakeCPICmdRunner.RunCmdOutput = CmdOutput{
+					Result: 1,
+				}
+			})
+
+			It("returns an error", func() {
+				_, err := cloud.Info()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Unexpected external CPI command result: '1'"))
+			})
+		})
+
+		itHandlesCPIErrors("info", func() error {
+			_, err := cloud.Info()
+			return err
+		})
+	})
+
 	Describe("CreateStemcell", func() {
 		var (
 			stemcellImagePath string
@@ -260,6 +307,29 @@ var _ = Describe("Cloud", func() {
 			})
 		})
 
+		Context("when the cpi speaks api version 2", func() {
+			BeforeEach(func() {
+				logger := boshlog.NewLogger(boshlog.LevelNone)
+				cloud = NewCloudWithAPIVersion(fakeCPICmdRunner, "fake-director-id", 2, 0, logger)
+				fakeCPICmdRunner.RunCmdOutput = CmdOutput{
+					Result: []interface{}{"fake-vm-cid", map[string]interface{}{"bosh": map[string]interface{}{"ip": "10.0.0.2"}}},
+				}
+			})
+
+			It("sends the api version in the context", func() {
+				_, err := cloud.CreateVM(agentID, stemcellCID, cloudProperties, networkInterfaces, env)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeCPICmdRunner.RunInputs).To(HaveLen(1))
+				Expect(fakeCPICmdRunner.RunInputs[0].Context).To(Equal(CmdContext{DirectorID: "fake-director-id", APIVersion: 2}))
+			})
+
+			It("returns the cid from the list returned from executing the cpi script", func() {
+				cid, err := cloud.CreateVM(agentID, stemcellCID, cloudProperties, networkInterfaces, env)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cid).To(Equal("fake-vm-cid"))
+			})
+		})
+
 		Context("when the result is of an unexpected type", func() {
 			BeforeEach(func() {
 				fakeCPICmdRunner.RunCmdOutput = CmdOutput{
@@ -292,6 +362,45 @@ var _ = Describe("Cloud", func() {
 		})
 	})
 
+	Describe("UsesRegistry", func() {
+		var logger boshlog.Logger
+
+		BeforeEach(func() {
+			logger = boshlog.NewLogger(boshlog.LevelNone)
+		})
+
+		It("uses the registry with cpi api version 1", func() {
+			cloud = NewCloudWithAPIVersion(fakeCPICmdRunner, "fake-director-id", 1, 2, logger)
+			Expect(cloud.UsesRegistry()).To(BeTrue())
+		})
+
+		It("uses the registry with stemcells predating api version 2", func() {
+			cloud = NewCloudWithAPIVersion(fakeCPICmdRunner, "fake-director-id", 2, 1, logger)
+			Expect(cloud.UsesRegistry()).To(BeTrue())
+
+			cloud = NewCloudWithAPIVersion(fakeCPICmdRunner, "fake-director-id", 2, 0, logger)
+			Expect(cloud.UsesRegistry()).To(BeTrue())
+		})
+
+		It("does not use the registry when both cpi and stemcell speak api version 2", func() {
+			cloud = NewCloudWithAPIVersion(fakeCPICmdRunner, "fake-director-id", 2, 2, logger)
+			Expect(cloud.UsesRegistry()).To(BeFalse())
+		})
+
+		It("sends the stemcell api version in the context to cpis speaking api version 2", func() {
+			cloud = NewCloudWithAPIVersion(fakeCPICmdRunner, "fake-director-id", 2, 2, logger)
+			fakeCPICmdRunner.RunCmdOutput = CmdOutput{Result: "fake-vm-cid"}
+
+			_, err := cloud.CreateVM("fake-agent-id", "fake-stemcell-cid", biproperty.Map{}, map[string]biproperty.Map{}, biproperty.Map{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeCPICmdRunner.RunInputs[0].Context).To(Equal(CmdContext{
+				DirectorID: "fake-director-id",
+				APIVersion: 2,
+				VM:         &VMContext{Stemcell: StemcellContext{APIVersion: 2}},
+			}))
+		})
+	})
+
 	Describe("SetVMMetadata", func() {
 		It("calls the set_vm_metadata CPI method", func() {
 			vmCID := "fake-vm-cid"
@@ -421,8 +530,9 @@ var _ = Describe("Cloud", func() {
 	Describe("AttachDisk", func() {
 		Context("when the cpi successfully attaches the disk", func() {
 			It("executes the cpi job script with the correct arguments", func() {
-				err := cloud.AttachDisk("fake-vm-cid", "fake-disk-cid")
+				diskHint, err := cloud.AttachDisk("fake-vm-cid", "fake-disk-cid")
 				Expect(err).NotTo(HaveOccurred())
+				Expect(diskHint).To(BeNil())
 				Expect(fakeCPICmdRunner.RunInputs).To(HaveLen(1))
 				Expect(fakeCPICmdRunner.RunInputs[0]).To(Equal(fakebicloud.RunInput{
 					Context: context,
@@ -441,14 +551,29 @@ var _ = Describe("Cloud", func() {
 			})
 
 			It("returns an error", func() {
-				err := cloud.AttachDisk("fake-vm-cid", "fake-disk-cid")
+				_, err := cloud.AttachDisk("fake-vm-cid", "fake-disk-cid")
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-run-error"))
 			})
 		})
 
+		Context("when the cpi speaks api version 2", func() {
+			BeforeEach(func() {
+				logger := boshlog.NewLogger(boshlog.LevelNone)
+				cloud = NewCloudWithAPIVersion(fakeCPICmdRunner, "fake-director-id", 2, 2, logger)
+				fakeCPICmdRunner.RunCmdOutput = CmdOutput{Result: map[string]interface{}{"path": "/dev/sdc"}}
+			})
+
+			It("returns the disk hint", func() {
+				diskHint, err := cloud.AttachDisk("fake-vm-cid", "fake-disk-cid")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(diskHint).To(Equal(map[string]interface{}{"path": "/dev/sdc"}))
+			})
+		})
+
 		itHandlesCPIErrors("attach_disk", func() error {
-			return cloud.AttachDisk("fake-vm-cid", "fake-disk-cid")
+			_, err := cloud.AttachDisk("fake-vm-cid", "fake-disk-cid")
+			return err
 		})
 	})
 
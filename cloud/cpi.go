@@ -13,3 +13,17 @@ type CPI struct {
 func (j CPI) ExecutablePath() string {
 	return filepath.Join(j.JobPath, "bin", "cpi")
 }
+
+// PluginPath is the executable of a CPI plugin, which CPI jobs written in Go
+// may ship in addition to the CPI executable.
+func (j CPI) PluginPath() string {
+	return filepath.Join(j.JobPath, "bin", "cpi-plugin")
+}
+
+func (j CPI) env() map[string]string {
+	return map[string]string{
+		"BOSH_PACKAGES_DIR": j.PackagesDir,
+		"BOSH_JOBS_DIR":     j.JobsDir,
+		"PATH":              "/usr/local/bin:/usr/bin:/bin",
+	}
+}
@@ -4,20 +4,41 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"time"
 
+	bieventlog "github.com/cloudfoundry/bosh-cli/eventlog"
+	biinstallmanifest "github.com/cloudfoundry/bosh-cli/installation/manifest"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
 type CmdInput struct {
-	Method    string        `json:"method"`
-	Arguments []interface{} `json:"arguments"`
-	Context   CmdContext    `json:"context"`
+	Method     string        `json:"method"`
+	Arguments  []interface{} `json:"arguments"`
+	Context    CmdContext    `json:"context"`
+	APIVersion int           `json:"api_version,omitempty"`
 }
 
 type CmdContext struct {
 	DirectorID string `json:"director_uuid"`
+
+	// APIVersion is the CPI API version the request is made with. It is sent
+	// at the top level of the request, and left out for version 1.
+	APIVersion int `json:"-"`
+
+	// VM tells CPIs speaking version 2 about the stemcell of the VMs, so
+	// that they can pass agent settings to stemcells that do not read them
+	// from the registry.
+	VM *VMContext `json:"vm,omitempty"`
+}
+
+type VMContext struct {
+	Stemcell StemcellContext `json:"stemcell"`
+}
+
+type StemcellContext struct {
+	APIVersion int `json:"api_version"`
 }
 
 func (c CmdContext) String() string {
@@ -48,6 +69,10 @@ type CmdOutput struct {
 	Log    string      `json:"log"`
 }
 
+// cpiKillGracePeriod is how long a CPI process that ran over its timeout is
+// given to exit after being terminated, before it is killed.
+const cpiKillGracePeriod = 10 * time.Second
+
 type CPICmdRunner interface {
 	Run(context CmdContext, method string, args ...interface{}) (CmdOutput, error)
 }
@@ -55,6 +80,9 @@ type CPICmdRunner interface {
 type cpiCmdRunner struct {
 	cmdRunner boshsys.CmdRunner
 	cpi       CPI
+	plugin    PluginCPI
+	calls     biinstallmanifest.CPICalls
+	eventLog  bieventlog.Log
 	logger    boshlog.Logger
 	logTag    string
 }
@@ -62,39 +90,113 @@ type cpiCmdRunner struct {
 func NewCPICmdRunner(
 	cmdRunner boshsys.CmdRunner,
 	cpi CPI,
+	calls biinstallmanifest.CPICalls,
+	eventLog bieventlog.Log,
 	logger boshlog.Logger,
 ) CPICmdRunner {
 	return &cpiCmdRunner{
 		cmdRunner: cmdRunner,
 		cpi:       cpi,
+		calls:     calls,
+		eventLog:  eventLog,
 		logger:    logger,
 		logTag:    "cpiCmdRunner",
 	}
 }
 
+// NewPluginCPICmdRunner returns a runner making the calls to a CPI plugin,
+// with the same call policies and event log as for CPI executables.
+func NewPluginCPICmdRunner(
+	plugin PluginCPI,
+	calls biinstallmanifest.CPICalls,
+	eventLog bieventlog.Log,
+	logger boshlog.Logger,
+) CPICmdRunner {
+	return &cpiCmdRunner{
+		plugin:   plugin,
+		calls:    calls,
+		eventLog: eventLog,
+		logger:   logger,
+		logTag:   "cpiCmdRunner",
+	}
+}
+
 func (r *cpiCmdRunner) Run(context CmdContext, method string, args ...interface{}) (CmdOutput, error) {
 	cmdInput := CmdInput{
-		Method:    method,
-		Arguments: args,
-		Context:   context,
+		Method:     method,
+		Arguments:  args,
+		Context:    context,
+		APIVersion: context.APIVersion,
 	}
 	inputBytes, err := json.Marshal(cmdInput)
 	if err != nil {
 		return CmdOutput{}, bosherr.WrapErrorf(err, "Marshalling external CPI command input %#v", cmdInput)
 	}
 
+	policy := r.calls.For(method)
+
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var cmdOutput CmdOutput
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			r.logger.Debug(r.logTag, "Retrying external CPI method '%s' in %s (attempt %d of %d)", method, policy.RetryDelay, attempt, maxAttempts)
+			time.Sleep(policy.RetryDelay)
+		}
+
+		startTime := time.Now()
+		if r.plugin != nil {
+			cmdOutput, err = r.runPlugin(cmdInput, inputBytes, policy.Timeout)
+		} else {
+			cmdOutput, err = r.run(method, inputBytes, policy.Timeout)
+		}
+		r.recordCall(method, startTime, cmdOutput, err)
+
+		if !r.isRetryable(cmdOutput, err) {
+			break
+		}
+	}
+
+	return cmdOutput, err
+}
+
+func (r *cpiCmdRunner) recordCall(method string, startTime time.Time, cmdOutput CmdOutput, err error) {
+	event := bieventlog.Event{Type: bieventlog.CPICall}
+	if err != nil {
+		event = bieventlog.NewErrorEvent(bieventlog.CPICall, err)
+	} else if cmdOutput.Error != nil {
+		event = bieventlog.NewErrorEvent(bieventlog.CPICall, NewCPIError(method, *cmdOutput.Error))
+	}
+
+	event.Method = method
+	event.Duration = time.Since(startTime).Seconds()
+	r.eventLog.Record(event)
+}
+
+// isRetryable is only true for CPI errors the CPI marks as ok to retry. A call
+// killed for running over its timeout is not retried, as methods such as
+// create_vm may have created resources that a retry would leak.
+func (r *cpiCmdRunner) isRetryable(cmdOutput CmdOutput, err error) bool {
+	return err == nil && cmdOutput.Error != nil && cmdOutput.Error.OkToRetry
+}
+
+func (r *cpiCmdRunner) run(method string, inputBytes []byte, timeout time.Duration) (CmdOutput, error) {
 	cmdPath := r.cpi.ExecutablePath()
 	cmd := boshsys.Command{
-		Name: cmdPath,
-		Env: map[string]string{
-			"BOSH_PACKAGES_DIR": r.cpi.PackagesDir,
-			"BOSH_JOBS_DIR":     r.cpi.JobsDir,
-			"PATH":              "/usr/local/bin:/usr/bin:/bin",
-		},
+		Name:           cmdPath,
+		Env:            r.cpi.env(),
 		UseIsolatedEnv: true,
 		Stdin:          bytes.NewReader(inputBytes),
 	}
-	stdout, stderr, exitCode, err := r.cmdRunner.RunComplexCommand(cmd)
+	stdout, stderr, exitCode, err := r.runCommand(cmd, method, timeout)
+	if _, timedOut := err.(cpiTimeoutError); timedOut {
+		r.logger.Error(r.logTag, "Killed external CPI command '%s' after %s\nSTDIN: '%s'", cmdPath, timeout, string(inputBytes))
+		return CmdOutput{}, err
+	}
+
 	r.logger.Debug(r.logTag, "Exit Code %d when executing external CPI command '%s'\nSTDIN: '%s'\nSTDOUT: '%s'\nSTDERR: '%s'", exitCode, cmdPath, string(inputBytes), stdout, stderr)
 	if err != nil {
 		return CmdOutput{}, bosherr.WrapErrorf(err, "Executing external CPI command: '%s'", cmdPath)
@@ -110,3 +212,59 @@ func (r *cpiCmdRunner) Run(context CmdContext, method string, args ...interface{
 
 	return cmdOutput, err
 }
+
+func (r *cpiCmdRunner) runPlugin(cmdInput CmdInput, inputBytes []byte, timeout time.Duration) (CmdOutput, error) {
+	cmdOutput, err := callPlugin(r.plugin, cmdInput, timeout)
+	if _, timedOut := err.(cpiTimeoutError); timedOut {
+		r.logger.Error(r.logTag, "CPI plugin call timed out after %s\nREQUEST: '%s'", timeout, string(inputBytes))
+
+		// a plugin started by the CLI would keep running the call, so it is
+		// stopped and restarted by the next call
+		if startedPlugin, ok := r.plugin.(Plugin); ok {
+			stopErr := startedPlugin.Stop()
+			if stopErr != nil {
+				r.logger.Error(r.logTag, "Stopping CPI plugin after timeout: %s", stopErr.Error())
+			}
+		}
+
+		return CmdOutput{}, err
+	}
+
+	if err != nil {
+		r.logger.Debug(r.logTag, "CPI plugin call failed\nREQUEST: '%s'\nERROR: '%s'", string(inputBytes), err.Error())
+		return CmdOutput{}, bosherr.WrapError(err, "Calling CPI plugin")
+	}
+
+	r.logger.Debug(r.logTag, "CPI plugin call\nREQUEST: '%s'\nRESULT: '%#v'", string(inputBytes), cmdOutput.Result)
+	r.logger.Debug(r.logTag, cmdOutput.Log)
+
+	return cmdOutput, nil
+}
+
+// runCommand runs the CPI command, terminating it when it runs for longer
+// than a non-zero timeout so that a hung CPI does not block forever.
+func (r *cpiCmdRunner) runCommand(cmd boshsys.Command, method string, timeout time.Duration) (string, string, int, error) {
+	if timeout <= 0 {
+		return r.cmdRunner.RunComplexCommand(cmd)
+	}
+
+	process, err := r.cmdRunner.RunComplexCommandAsync(cmd)
+	if err != nil {
+		return "", "", -1, err
+	}
+
+	resultCh := process.Wait()
+
+	select {
+	case result := <-resultCh:
+		return result.Stdout, result.Stderr, result.ExitStatus, result.Error
+
+	case <-time.After(timeout):
+		err = process.TerminateNicely(cpiKillGracePeriod)
+		if err != nil {
+			r.logger.Error(r.logTag, "Terminating external CPI command '%s': %s", cmd.Name, err.Error())
+		}
+
+		return "", "", -1, cpiTimeoutError{method: method, timeout: timeout}
+	}
+}
@@ -4,9 +4,14 @@ import (
 	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"time"
 
 	. "github.com/cloudfoundry/bosh-cli/cloud"
+	bieventlog "github.com/cloudfoundry/bosh-cli/eventlog"
+	fakebieventlog "github.com/cloudfoundry/bosh-cli/eventlog/fakes"
+	biinstallmanifest "github.com/cloudfoundry/bosh-cli/installation/manifest"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -33,7 +38,7 @@ var _ = Describe("CpiCmdRunner", func() {
 
 		cmdRunner = fakesys.NewFakeCmdRunner()
 		logger := boshlog.NewLogger(boshlog.LevelNone)
-		cpiCmdRunner = NewCPICmdRunner(cmdRunner, cpi, logger)
+		cpiCmdRunner = NewCPICmdRunner(cmdRunner, cpi, biinstallmanifest.CPICalls{}, bieventlog.NewNoopLog(), logger)
 	})
 
 	Describe("Run", func() {
@@ -72,6 +77,30 @@ var _ = Describe("CpiCmdRunner", func() {
 			))
 		})
 
+		It("sends the api version at the top level when it is set in the context", func() {
+			cmdOutput := CmdOutput{}
+			outputBytes, err := json.Marshal(cmdOutput)
+			Expect(err).NotTo(HaveOccurred())
+
+			cmdRunner.AddCmdResult("/jobs/cpi/bin/cpi", fakesys.FakeCmdResult{Stdout: string(outputBytes)})
+
+			context.APIVersion = 2
+			_, err = cpiCmdRunner.Run(context, "fake-method", "fake-argument")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cmdRunner.RunComplexCommands).To(HaveLen(1))
+
+			bytes, err := ioutil.ReadAll(cmdRunner.RunComplexCommands[0].Stdin)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(bytes)).To(Equal(
+				`{` +
+					`"method":"fake-method",` +
+					`"arguments":["fake-argument"],` +
+					`"context":{"director_uuid":"fake-director-id"},` +
+					`"api_version":2` +
+					`}`,
+			))
+		})
+
 		Context("when the command succeeds", func() {
 			BeforeEach(func() {
 				cmdOutput := CmdOutput{
@@ -137,5 +166,125 @@ var _ = Describe("CpiCmdRunner", func() {
 				Expect(cmdOutput.Error.Message).To(ContainSubstring("fake-run-error"))
 			})
 		})
+
+		It("records the call in the event log", func() {
+			outputBytes, err := json.Marshal(CmdOutput{Error: &CmdError{Type: "fake-error-type", Message: "fake-run-error"}})
+			Expect(err).NotTo(HaveOccurred())
+			cmdRunner.AddCmdResult("/jobs/cpi/bin/cpi", fakesys.FakeCmdResult{Stdout: string(outputBytes)})
+
+			fakeEventLog := fakebieventlog.NewFakeLog()
+			logger := boshlog.NewLogger(boshlog.LevelNone)
+			cpiCmdRunner = NewCPICmdRunner(cmdRunner, cpi, biinstallmanifest.CPICalls{}, fakeEventLog, logger)
+
+			_, err = cpiCmdRunner.Run(context, "fake-method")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeEventLog.Events).To(HaveLen(1))
+			Expect(fakeEventLog.Events[0].Type).To(Equal(bieventlog.CPICall))
+			Expect(fakeEventLog.Events[0].Method).To(Equal("fake-method"))
+			Expect(fakeEventLog.Events[0].Error).To(ContainSubstring("fake-run-error"))
+		})
+
+		Context("when the call policy allows several attempts", func() {
+			var okToRetryOutput string
+
+			BeforeEach(func() {
+				logger := boshlog.NewLogger(boshlog.LevelNone)
+				cpiCmdRunner = NewCPICmdRunner(cmdRunner, cpi, biinstallmanifest.CPICalls{
+					CPICallPolicy: biinstallmanifest.CPICallPolicy{MaxAttempts: 3},
+					Methods: map[string]biinstallmanifest.CPICallPolicy{
+						"fake-single-attempt-method": {MaxAttempts: 1},
+					},
+				}, bieventlog.NewNoopLog(), logger)
+
+				outputBytes, err := json.Marshal(CmdOutput{Error: &CmdError{Message: "fake-retryable-error", OkToRetry: true}})
+				Expect(err).NotTo(HaveOccurred())
+				okToRetryOutput = string(outputBytes)
+			})
+
+			It("retries CPI errors that are ok to retry", func() {
+				successBytes, err := json.Marshal(CmdOutput{Result: "fake-cid"})
+				Expect(err).NotTo(HaveOccurred())
+
+				cmdRunner.AddCmdResult("/jobs/cpi/bin/cpi", fakesys.FakeCmdResult{Stdout: okToRetryOutput})
+				cmdRunner.AddCmdResult("/jobs/cpi/bin/cpi", fakesys.FakeCmdResult{Stdout: string(successBytes)})
+
+				cmdOutput, err := cpiCmdRunner.Run(context, "fake-method")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cmdOutput.Result).To(Equal("fake-cid"))
+				Expect(cmdRunner.RunComplexCommands).To(HaveLen(2))
+			})
+
+			It("returns the last CPI error when all attempts fail", func() {
+				cmdRunner.AddCmdResult("/jobs/cpi/bin/cpi", fakesys.FakeCmdResult{Stdout: okToRetryOutput, Sticky: true})
+
+				cmdOutput, err := cpiCmdRunner.Run(context, "fake-method")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cmdOutput.Error.Message).To(Equal("fake-retryable-error"))
+				Expect(cmdRunner.RunComplexCommands).To(HaveLen(3))
+			})
+
+			It("uses the policy of the method", func() {
+				cmdRunner.AddCmdResult("/jobs/cpi/bin/cpi", fakesys.FakeCmdResult{Stdout: okToRetryOutput, Sticky: true})
+
+				_, err := cpiCmdRunner.Run(context, "fake-single-attempt-method")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cmdRunner.RunComplexCommands).To(HaveLen(1))
+			})
+
+			It("does not retry errors running the command", func() {
+				cmdRunner.AddCmdResult("/jobs/cpi/bin/cpi", fakesys.FakeCmdResult{Error: errors.New("fake-error-trying-to-run-command"), Sticky: true})
+
+				_, err := cpiCmdRunner.Run(context, "fake-method")
+				Expect(err).To(HaveOccurred())
+				Expect(cmdRunner.RunComplexCommands).To(HaveLen(1))
+			})
+		})
+
+		Context("when the call policy has a timeout", func() {
+			var successOutput string
+
+			BeforeEach(func() {
+				logger := boshlog.NewLogger(boshlog.LevelNone)
+				cpiCmdRunner = NewCPICmdRunner(cmdRunner, cpi, biinstallmanifest.CPICalls{
+					CPICallPolicy: biinstallmanifest.CPICallPolicy{
+						Timeout:     10 * time.Millisecond,
+						MaxAttempts: 2,
+					},
+				}, bieventlog.NewNoopLog(), logger)
+
+				outputBytes, err := json.Marshal(CmdOutput{Result: "fake-cid"})
+				Expect(err).NotTo(HaveOccurred())
+				successOutput = string(outputBytes)
+			})
+
+			It("returns the output of commands finishing in time", func() {
+				cmdRunner.AddProcess("/jobs/cpi/bin/cpi", &fakesys.FakeProcess{
+					WaitResult: boshsys.Result{Stdout: successOutput},
+				})
+
+				cmdOutput, err := cpiCmdRunner.Run(context, "fake-method")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cmdOutput.Result).To(Equal("fake-cid"))
+			})
+
+			It("kills hung commands and does not retry them", func() {
+				hungProcess := &fakesys.FakeProcess{
+					TerminatedNicelyCallBack: func(p *fakesys.FakeProcess) {
+						p.WaitCh <- boshsys.Result{Error: errors.New("fake-killed")}
+					},
+				}
+				cmdRunner.AddProcess("/jobs/cpi/bin/cpi", hungProcess)
+				cmdRunner.AddProcess("/jobs/cpi/bin/cpi", &fakesys.FakeProcess{
+					WaitResult: boshsys.Result{Stdout: successOutput},
+				})
+
+				_, err := cpiCmdRunner.Run(context, "create_vm")
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("CPI 'create_vm' method did not finish within 10ms and was killed"))
+				Expect(hungProcess.TerminatedNicely).To(BeTrue())
+				Expect(cmdRunner.RunComplexCommands).To(HaveLen(1))
+			})
+		})
 	})
 })
@@ -2,6 +2,7 @@ package cloud
 
 import (
 	"fmt"
+	"time"
 )
 
 const (
@@ -50,3 +51,19 @@ func (e cpiError) Message() string {
 func (e cpiError) OkToRetry() bool {
 	return e.cmdError.OkToRetry
 }
+
+type cpiTimeoutError struct {
+	method  string
+	timeout time.Duration
+}
+
+func (e cpiTimeoutError) Error() string {
+	return fmt.Sprintf("CPI '%s' method did not finish within %s and was killed", e.method, e.timeout)
+}
+
+// IsTimeoutError returns true when the error is a CPI method killed for not
+// finishing within its timeout.
+func IsTimeoutError(err error) bool {
+	_, ok := err.(cpiTimeoutError)
+	return ok
+}
@@ -1,35 +1,65 @@
 package cloud
 
 import (
+	"sync"
+
+	bieventlog "github.com/cloudfoundry/bosh-cli/eventlog"
 	biinstall "github.com/cloudfoundry/bosh-cli/installation"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"github.com/pivotal-golang/clock"
 )
 
 type Factory interface {
-	NewCloud(installation biinstall.Installation, directorID string) (Cloud, error)
+	// NewCloud returns a Cloud for the installed CPI. The stemcell API version
+	// is 0 when the stemcell is unknown, such as when deleting.
+	NewCloud(installation biinstall.Installation, directorID string, stemcellAPIVersion int) (Cloud, error)
+}
+
+// PluginStopper is implemented by factories that start CPI plugins, which
+// keep running until they are stopped.
+type PluginStopper interface {
+	StopPlugins() error
 }
 
 type factory struct {
-	fs        boshsys.FileSystem
-	cmdRunner boshsys.CmdRunner
-	logger    boshlog.Logger
+	fs          boshsys.FileSystem
+	cmdRunner   boshsys.CmdRunner
+	eventLog    bieventlog.Log
+	tracer      Tracer
+	timeService clock.Clock
+	plugins     []Plugin
+	logger      boshlog.Logger
+	logTag      string
+
+	// infos holds the info of every CPI executable and plugin asked for it
+	// so far, by path, as the info of a CPI does not change
+	infos     map[string]CPIInfo
+	infosLock sync.Mutex
 }
 
 func NewFactory(
 	fs boshsys.FileSystem,
 	cmdRunner boshsys.CmdRunner,
+	eventLog bieventlog.Log,
+	tracer Tracer,
+	timeService clock.Clock,
 	logger boshlog.Logger,
 ) Factory {
 	return &factory{
-		fs:        fs,
-		cmdRunner: cmdRunner,
-		logger:    logger,
+		fs:          fs,
+		cmdRunner:   cmdRunner,
+		eventLog:    eventLog,
+		tracer:      tracer,
+		timeService: timeService,
+		logger:      logger,
+		logTag:      "cloudFactory",
+		infos:       map[string]CPIInfo{},
 	}
 }
 
-func (f *factory) NewCloud(installation biinstall.Installation, directorID string) (Cloud, error) {
+func (f *factory) NewCloud(installation biinstall.Installation, directorID string, stemcellAPIVersion int) (Cloud, error) {
 	cpiJob := installation.Job()
 	target := installation.Target()
 	cpi := CPI{
@@ -39,10 +69,126 @@ func (f *factory) NewCloud(installation biinstall.Installation, directorID strin
 	}
 
 	cmdPath := cpi.ExecutablePath()
-	if !f.fs.FileExists(cmdPath) {
+	hasExecutable := f.fs.FileExists(cmdPath)
+
+	if f.fs.FileExists(cpi.PluginPath()) {
+		cpiCmdRunner, info, err := f.newPluginCmdRunner(cpi, installation, directorID)
+		if err == nil {
+			return NewCloudWithAPIVersion(cpiCmdRunner, directorID, f.negotiateAPIVersion(info), stemcellAPIVersion, f.logger), nil
+		}
+
+		if !hasExecutable {
+			return nil, err
+		}
+
+		f.logger.Warn(f.logTag, "Falling back to CPI executable '%s': %s", cmdPath, err.Error())
+	}
+
+	if !hasExecutable {
 		return nil, bosherr.Errorf("Installed CPI job '%s' does not contain the required executable '%s'", cpiJob.Name, cmdPath)
 	}
 
-	cpiCmdRunner := NewCPICmdRunner(f.cmdRunner, cpi, f.logger)
-	return NewCloud(cpiCmdRunner, directorID, f.logger), nil
+	cpiCmdRunner := NewCPICmdRunner(f.cmdRunner, cpi, installation.CPICalls(), f.eventLog, f.logger)
+	cpiCmdRunner = NewTracingCPICmdRunner(cpiCmdRunner, f.tracer, f.timeService)
+
+	return NewCloudWithAPIVersion(cpiCmdRunner, directorID, f.apiVersion(cpiCmdRunner, cmdPath, directorID), stemcellAPIVersion, f.logger), nil
+}
+
+// newPluginCmdRunner starts the CPI plugin and makes sure it answers info,
+// which every plugin has to. A plugin that does not is stopped. The info of a
+// plugin that answered before is not asked for again.
+func (f *factory) newPluginCmdRunner(cpi CPI, installation biinstall.Installation, directorID string) (CPICmdRunner, CPIInfo, error) {
+	plugin, err := StartPlugin(f.cmdRunner, cpi, f.logger)
+	if err != nil {
+		return nil, CPIInfo{}, err
+	}
+
+	cpiCmdRunner := NewPluginCPICmdRunner(plugin, installation.CPICalls(), f.eventLog, f.logger)
+	cpiCmdRunner = NewTracingCPICmdRunner(cpiCmdRunner, f.tracer, f.timeService)
+
+	info, found := f.cachedInfo(cpi.PluginPath())
+	if found {
+		f.plugins = append(f.plugins, plugin)
+		return cpiCmdRunner, info, nil
+	}
+
+	info, err = NewCloud(cpiCmdRunner, directorID, f.logger).Info()
+	if err != nil {
+		stopErr := plugin.Stop()
+		if stopErr != nil {
+			f.logger.Error(f.logTag, stopErr.Error())
+		}
+
+		return nil, CPIInfo{}, bosherr.WrapErrorf(err, "Getting info from CPI plugin '%s'", cpi.PluginPath())
+	}
+
+	f.plugins = append(f.plugins, plugin)
+	f.cacheInfo(cpi.PluginPath(), info)
+
+	return cpiCmdRunner, info, nil
+}
+
+// StopPlugins stops the CPI plugins started for the clouds of the factory.
+func (f *factory) StopPlugins() error {
+	var errs []error
+
+	for _, plugin := range f.plugins {
+		err := plugin.Stop()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	f.plugins = nil
+
+	if len(errs) > 0 {
+		return bosherr.NewMultiError(errs...)
+	}
+
+	return nil
+}
+
+// apiVersion negotiates the CPI API version from the CPI's info: the highest
+// version both sides speak. CPIs that fail to answer info speak version 1.
+// The CPI at cmdPath is only asked for its info once.
+func (f *factory) apiVersion(cpiCmdRunner CPICmdRunner, cmdPath string, directorID string) int {
+	info, found := f.cachedInfo(cmdPath)
+	if !found {
+		var err error
+
+		info, err = NewCloud(cpiCmdRunner, directorID, f.logger).Info()
+		if err != nil {
+			f.logger.Debug(f.logTag, "Falling back to CPI API version 1: %s", err.Error())
+			info = CPIInfo{APIVersion: 1}
+		}
+
+		f.cacheInfo(cmdPath, info)
+	}
+
+	return f.negotiateAPIVersion(info)
+}
+
+func (f *factory) cachedInfo(path string) (CPIInfo, bool) {
+	f.infosLock.Lock()
+	defer f.infosLock.Unlock()
+
+	info, found := f.infos[path]
+	return info, found
+}
+
+func (f *factory) cacheInfo(path string, info CPIInfo) {
+	f.infosLock.Lock()
+	defer f.infosLock.Unlock()
+
+	f.infos[path] = info
+}
+
+// negotiateAPIVersion returns the highest version both the CLI and the CPI
+// speak.
+func (f *factory) negotiateAPIVersion(info CPIInfo) int {
+	if info.APIVersion > MaxCPIAPIVersion {
+		return MaxCPIAPIVersion
+	}
+
+	return info.APIVersion
 }
@@ -0,0 +1,52 @@
+package cloud_test
+
+import (
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock"
+
+	. "github.com/cloudfoundry/bosh-cli/cloud"
+	bieventlog "github.com/cloudfoundry/bosh-cli/eventlog"
+	biinstall "github.com/cloudfoundry/bosh-cli/installation"
+	biinstallmanifest "github.com/cloudfoundry/bosh-cli/installation/manifest"
+)
+
+var _ = Describe("Factory", func() {
+	var (
+		fs           *fakesys.FakeFileSystem
+		cmdRunner    *fakesys.FakeCmdRunner
+		installation biinstall.Installation
+		factory      Factory
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		cmdRunner = fakesys.NewFakeCmdRunner()
+
+		installedJob := biinstall.NewInstalledJob(biinstall.RenderedJobRef{Name: "cpi"}, "/target/jobs/cpi")
+		installation = biinstall.NewInstallation(biinstall.NewTarget("/target"), installedJob, biinstallmanifest.Manifest{}, nil)
+
+		factory = NewFactory(fs, cmdRunner, bieventlog.NewNoopLog(), NewNoopTracer(), clock.NewClock(), boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	Describe("NewCloud", func() {
+		BeforeEach(func() {
+			fs.WriteFileString("/target/jobs/cpi/bin/cpi", "")
+			cmdRunner.AddCmdResult("/target/jobs/cpi/bin/cpi", fakesys.FakeCmdResult{
+				Stdout: `{"result":{"api_version":2}}`,
+			})
+		})
+
+		It("asks the CPI for its info only for the first cloud", func() {
+			_, err := factory.NewCloud(installation, "fake-director-id", 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = factory.NewCloud(installation, "fake-director-id", 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(cmdRunner.RunComplexCommands).To(HaveLen(1))
+		})
+	})
+})
@@ -6,6 +6,10 @@ import (
 )
 
 type FakeCloud struct {
+	InfoCalled int
+	InfoResult cloud.CPIInfo
+	InfoErr    error
+
 	CreateStemcellInputs []CreateStemcellInput
 	CreateStemcellCID    string
 	CreateStemcellErr    error
@@ -23,6 +27,7 @@ type FakeCloud struct {
 	CreateDiskErr   error
 
 	AttachDiskInput AttachDiskInput
+	AttachDiskHint  interface{}
 	AttachDiskErr   error
 
 	DetachDiskInput DetachDiskInput
@@ -40,6 +45,8 @@ type FakeCloud struct {
 	SetVMMetadataCid      string
 	SetVMMetadataMetadata cloud.VMMetadata
 	SetVMMetadataError    error
+
+	UsesRegistryResult bool
 }
 
 type CreateStemcellInput struct {
@@ -91,9 +98,15 @@ func NewFakeCloud() *FakeCloud {
 	return &FakeCloud{
 		CreateStemcellInputs: []CreateStemcellInput{},
 		DeleteDiskInputs:     []DeleteDiskInput{},
+		UsesRegistryResult:   true,
 	}
 }
 
+func (c *FakeCloud) Info() (cloud.CPIInfo, error) {
+	c.InfoCalled++
+	return c.InfoResult, c.InfoErr
+}
+
 func (c *FakeCloud) CreateStemcell(imagePath string, cloudProperties biproperty.Map) (string, error) {
 	c.CreateStemcellInputs = append(c.CreateStemcellInputs, CreateStemcellInput{
 		ImagePath:       imagePath,
@@ -155,12 +168,12 @@ func (c *FakeCloud) CreateDisk(
 	return c.CreateDiskCID, c.CreateDiskErr
 }
 
-func (c *FakeCloud) AttachDisk(vmCID, diskCID string) error {
+func (c *FakeCloud) AttachDisk(vmCID, diskCID string) (interface{}, error) {
 	c.AttachDiskInput = AttachDiskInput{
 		VMCID:   vmCID,
 		DiskCID: diskCID,
 	}
-	return c.AttachDiskErr
+	return c.AttachDiskHint, c.AttachDiskErr
 }
 
 func (c *FakeCloud) DetachDisk(vmCID, diskCID string) error {
@@ -185,6 +198,10 @@ func (c *FakeCloud) DeleteDisk(diskCID string) error {
 	return c.DeleteDiskErr
 }
 
+func (c *FakeCloud) UsesRegistry() bool {
+	return c.UsesRegistryResult
+}
+
 func (c *FakeCloud) String() string {
 	return "FakeCloud{}"
 }
@@ -31,10 +31,11 @@ func (_m *MockCloud) EXPECT() *_MockCloudRecorder {
 	return _m.recorder
 }
 
-func (_m *MockCloud) AttachDisk(_param0 string, _param1 string) error {
+func (_m *MockCloud) AttachDisk(_param0 string, _param1 string) (interface{}, error) {
 	ret := _m.ctrl.Call(_m, "AttachDisk", _param0, _param1)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(interface{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 func (_mr *_MockCloudRecorder) AttachDisk(arg0, arg1 interface{}) *gomock.Call {
@@ -125,6 +126,17 @@ func (_mr *_MockCloudRecorder) HasVM(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "HasVM", arg0)
 }
 
+func (_m *MockCloud) Info() (cloud.CPIInfo, error) {
+	ret := _m.ctrl.Call(_m, "Info")
+	ret0, _ := ret[0].(cloud.CPIInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockCloudRecorder) Info() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Info")
+}
+
 func (_m *MockCloud) SetVMMetadata(_param0 string, _param1 cloud.VMMetadata) error {
 	ret := _m.ctrl.Call(_m, "SetVMMetadata", _param0, _param1)
 	ret0, _ := ret[0].(error)
@@ -145,6 +157,16 @@ func (_mr *_MockCloudRecorder) String() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "String")
 }
 
+func (_m *MockCloud) UsesRegistry() bool {
+	ret := _m.ctrl.Call(_m, "UsesRegistry")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+func (_mr *_MockCloudRecorder) UsesRegistry() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "UsesRegistry")
+}
+
 // Mock of Factory interface
 type MockFactory struct {
 	ctrl     *gomock.Controller
@@ -166,13 +188,13 @@ func (_m *MockFactory) EXPECT() *_MockFactoryRecorder {
 	return _m.recorder
 }
 
-func (_m *MockFactory) NewCloud(_param0 installation.Installation, _param1 string) (cloud.Cloud, error) {
-	ret := _m.ctrl.Call(_m, "NewCloud", _param0, _param1)
+func (_m *MockFactory) NewCloud(_param0 installation.Installation, _param1 string, _param2 int) (cloud.Cloud, error) {
+	ret := _m.ctrl.Call(_m, "NewCloud", _param0, _param1, _param2)
 	ret0, _ := ret[0].(cloud.Cloud)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockFactoryRecorder) NewCloud(arg0, arg1 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "NewCloud", arg0, arg1)
+func (_mr *_MockFactoryRecorder) NewCloud(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "NewCloud", arg0, arg1, arg2)
 }
@@ -0,0 +1,247 @@
+package cloud
+
+import (
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"strings"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// PluginCPI is a CPI written in Go. It gets the same requests as a CPI
+// executable and answers them with the same output, but a single process
+// serves all requests instead of one process per request.
+type PluginCPI interface {
+	Call(input CmdInput) (CmdOutput, error)
+}
+
+const pluginServiceName = "CPI"
+
+type pluginService struct {
+	cpi PluginCPI
+}
+
+func (s pluginService) Call(input CmdInput, output *CmdOutput) error {
+	result, err := s.cpi.Call(input)
+	if err != nil {
+		return err
+	}
+
+	*output = result
+
+	return nil
+}
+
+// ServePlugin serves requests of the CLI to cpi on conn until it is closed.
+// CPI plugins call it from their main with the standard input and output of
+// the process.
+func ServePlugin(cpi PluginCPI, conn io.ReadWriteCloser) error {
+	server := rpc.NewServer()
+
+	err := server.RegisterName(pluginServiceName, pluginService{cpi: cpi})
+	if err != nil {
+		return bosherr.WrapError(err, "Registering CPI plugin")
+	}
+
+	server.ServeCodec(jsonrpc.NewServerCodec(conn))
+
+	return nil
+}
+
+type pluginClient struct {
+	client *rpc.Client
+}
+
+// NewPluginClient returns a PluginCPI sending requests to a plugin served on
+// conn.
+func NewPluginClient(conn io.ReadWriteCloser) PluginCPI {
+	return pluginClient{client: jsonrpc.NewClient(conn)}
+}
+
+func (c pluginClient) Call(input CmdInput) (CmdOutput, error) {
+	var output CmdOutput
+
+	err := c.client.Call(pluginServiceName+".Call", input, &output)
+	if err != nil {
+		return CmdOutput{}, err
+	}
+
+	return output, nil
+}
+
+// pluginConn talks to a plugin process through its standard input and output.
+type pluginConn struct {
+	io.Reader
+	io.WriteCloser
+}
+
+// Plugin is a CPI plugin process started by the CLI. It is restarted by the
+// next call once it exited or was stopped.
+type Plugin interface {
+	PluginCPI
+
+	// Stop closes the standard input of the plugin so that it exits, and
+	// kills it when it does not exit in time.
+	Stop() error
+}
+
+type pluginProcess struct {
+	cmdRunner boshsys.CmdRunner
+	cpi       CPI
+	logger    boshlog.Logger
+	logTag    string
+
+	lock    sync.Mutex
+	client  PluginCPI
+	process boshsys.Process
+	stdin   io.Closer
+}
+
+// StartPlugin starts the plugin executable of the CPI. Anything the plugin
+// writes to its standard error is logged.
+func StartPlugin(cmdRunner boshsys.CmdRunner, cpi CPI, logger boshlog.Logger) (Plugin, error) {
+	plugin := &pluginProcess{
+		cmdRunner: cmdRunner,
+		cpi:       cpi,
+		logger:    logger,
+		logTag:    "cpiPlugin",
+	}
+
+	_, err := plugin.start()
+	if err != nil {
+		return nil, err
+	}
+
+	return plugin, nil
+}
+
+func (p *pluginProcess) Call(input CmdInput) (CmdOutput, error) {
+	client, err := p.start()
+	if err != nil {
+		return CmdOutput{}, err
+	}
+
+	return client.Call(input)
+}
+
+func (p *pluginProcess) start() (PluginCPI, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.client != nil {
+		return p.client, nil
+	}
+
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+
+	cmd := boshsys.Command{
+		Name:           p.cpi.PluginPath(),
+		Env:            p.cpi.env(),
+		UseIsolatedEnv: true,
+		Stdin:          stdinReader,
+		Stdout:         stdoutWriter,
+		Stderr:         pluginStderrWriter{logger: p.logger, logTag: p.logTag},
+	}
+
+	process, err := p.cmdRunner.RunComplexCommandAsync(cmd)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Starting CPI plugin '%s'", cmd.Name)
+	}
+
+	resultCh := process.Wait()
+
+	// unblock pending calls once the plugin exits
+	go func() {
+		result := <-resultCh
+		stdoutWriter.CloseWithError(pluginExitError{result: result})
+		stdinReader.Close()
+
+		p.lock.Lock()
+		defer p.lock.Unlock()
+
+		if p.process == process {
+			p.client, p.process, p.stdin = nil, nil, nil
+		}
+	}()
+
+	p.client = NewPluginClient(pluginConn{Reader: stdoutReader, WriteCloser: stdinWriter})
+	p.process = process
+	p.stdin = stdinWriter
+
+	return p.client, nil
+}
+
+func (p *pluginProcess) Stop() error {
+	p.lock.Lock()
+	process, stdin := p.process, p.stdin
+	p.client, p.process, p.stdin = nil, nil, nil
+	p.lock.Unlock()
+
+	if process == nil {
+		return nil
+	}
+
+	_ = stdin.Close()
+
+	err := process.TerminateNicely(cpiKillGracePeriod)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Stopping CPI plugin '%s'", p.cpi.PluginPath())
+	}
+
+	return nil
+}
+
+type pluginStderrWriter struct {
+	logger boshlog.Logger
+	logTag string
+}
+
+func (w pluginStderrWriter) Write(b []byte) (int, error) {
+	w.logger.Debug(w.logTag, "STDERR: '%s'", strings.TrimRight(string(b), "\n"))
+	return len(b), nil
+}
+
+type pluginExitError struct {
+	result boshsys.Result
+}
+
+func (e pluginExitError) Error() string {
+	if e.result.Error != nil {
+		return "CPI plugin exited: " + e.result.Error.Error()
+	}
+	return "CPI plugin exited"
+}
+
+type pluginCallResult struct {
+	output CmdOutput
+	err    error
+}
+
+// callPlugin makes a call to the plugin that gives up after a non-zero
+// timeout. The plugin keeps running the call until it is stopped.
+func callPlugin(plugin PluginCPI, input CmdInput, timeout time.Duration) (CmdOutput, error) {
+	if timeout <= 0 {
+		return plugin.Call(input)
+	}
+
+	resultCh := make(chan pluginCallResult, 1)
+
+	go func() {
+		output, err := plugin.Call(input)
+		resultCh <- pluginCallResult{output: output, err: err}
+	}()
+
+	select {
+	case result := <-resultCh:
+		return result.output, result.err
+
+	case <-time.After(timeout):
+		return CmdOutput{}, cpiTimeoutError{method: input.Method, timeout: timeout}
+	}
+}
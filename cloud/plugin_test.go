@@ -0,0 +1,250 @@
+package cloud_test
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	. "github.com/cloudfoundry/bosh-cli/cloud"
+	bieventlog "github.com/cloudfoundry/bosh-cli/eventlog"
+	fakebieventlog "github.com/cloudfoundry/bosh-cli/eventlog/fakes"
+	biinstallmanifest "github.com/cloudfoundry/bosh-cli/installation/manifest"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+type fakePluginCPI struct {
+	inputs []CmdInput
+	call   func(CmdInput) (CmdOutput, error)
+}
+
+func (p *fakePluginCPI) Call(input CmdInput) (CmdOutput, error) {
+	p.inputs = append(p.inputs, input)
+	return p.call(input)
+}
+
+type stoppablePluginCPI struct {
+	PluginCPI
+	stopped bool
+}
+
+func (p *stoppablePluginCPI) Stop() error {
+	p.stopped = true
+	return nil
+}
+
+var _ = Describe("PluginCPI", func() {
+	var (
+		pluginCPI    *fakePluginCPI
+		plugin       PluginCPI
+		clientConn   net.Conn
+		fakeEventLog *fakebieventlog.FakeLog
+		logger       boshlog.Logger
+		context      CmdContext
+	)
+
+	BeforeEach(func() {
+		pluginCPI = &fakePluginCPI{
+			call: func(CmdInput) (CmdOutput, error) {
+				return CmdOutput{Result: "fake-result", Log: "fake-log"}, nil
+			},
+		}
+
+		var serverConn net.Conn
+		clientConn, serverConn = net.Pipe()
+
+		go func() {
+			defer GinkgoRecover()
+			Expect(ServePlugin(pluginCPI, serverConn)).To(Succeed())
+		}()
+
+		plugin = NewPluginClient(clientConn)
+
+		fakeEventLog = fakebieventlog.NewFakeLog()
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+		context = CmdContext{DirectorID: "fake-director-id", APIVersion: 2}
+	})
+
+	AfterEach(func() {
+		clientConn.Close()
+	})
+
+	It("sends requests to the plugin and returns its output", func() {
+		output, err := plugin.Call(CmdInput{
+			Method:     "fake-method",
+			Arguments:  []interface{}{"fake-argument"},
+			Context:    context,
+			APIVersion: 2,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(output).To(Equal(CmdOutput{Result: "fake-result", Log: "fake-log"}))
+
+		Expect(pluginCPI.inputs).To(Equal([]CmdInput{
+			{
+				Method:     "fake-method",
+				Arguments:  []interface{}{"fake-argument"},
+				Context:    CmdContext{DirectorID: "fake-director-id"},
+				APIVersion: 2,
+			},
+		}))
+	})
+
+	It("returns errors of the plugin", func() {
+		pluginCPI.call = func(CmdInput) (CmdOutput, error) {
+			return CmdOutput{}, errors.New("fake-plugin-err")
+		}
+
+		_, err := plugin.Call(CmdInput{Method: "fake-method"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("fake-plugin-err"))
+	})
+
+	Describe("NewPluginCPICmdRunner", func() {
+		It("makes calls to the plugin and records them in the event log", func() {
+			pluginCPI.call = func(CmdInput) (CmdOutput, error) {
+				return CmdOutput{Error: &CmdError{Type: "fake-error-type", Message: "fake-cpi-error"}}, nil
+			}
+
+			cpiCmdRunner := NewPluginCPICmdRunner(plugin, biinstallmanifest.CPICalls{}, fakeEventLog, logger)
+
+			output, err := cpiCmdRunner.Run(context, "fake-method", "fake-argument")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(output.Error).To(Equal(&CmdError{Type: "fake-error-type", Message: "fake-cpi-error"}))
+
+			Expect(pluginCPI.inputs).To(HaveLen(1))
+			Expect(pluginCPI.inputs[0].Method).To(Equal("fake-method"))
+			Expect(pluginCPI.inputs[0].APIVersion).To(Equal(2))
+
+			Expect(fakeEventLog.Events).To(HaveLen(1))
+			Expect(fakeEventLog.Events[0].Type).To(Equal(bieventlog.CPICall))
+			Expect(fakeEventLog.Events[0].Method).To(Equal("fake-method"))
+			Expect(fakeEventLog.Events[0].Error).To(ContainSubstring("fake-cpi-error"))
+		})
+
+		It("returns an error when the plugin cannot be reached", func() {
+			clientConn.Close()
+
+			cpiCmdRunner := NewPluginCPICmdRunner(plugin, biinstallmanifest.CPICalls{}, fakeEventLog, logger)
+
+			_, err := cpiCmdRunner.Run(context, "fake-method")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Calling CPI plugin"))
+		})
+
+		It("gives up on calls running over their timeout", func() {
+			release := make(chan struct{})
+			defer close(release)
+
+			pluginCPI.call = func(CmdInput) (CmdOutput, error) {
+				<-release
+				return CmdOutput{}, nil
+			}
+
+			calls := biinstallmanifest.CPICalls{
+				CPICallPolicy: biinstallmanifest.CPICallPolicy{Timeout: 10 * time.Millisecond},
+			}
+			cpiCmdRunner := NewPluginCPICmdRunner(plugin, calls, fakeEventLog, logger)
+
+			_, err := cpiCmdRunner.Run(context, "fake-method")
+			Expect(err).To(HaveOccurred())
+			Expect(IsTimeoutError(err)).To(BeTrue())
+		})
+
+		It("stops plugins started by the CLI when a call times out", func() {
+			release := make(chan struct{})
+			defer close(release)
+
+			pluginCPI.call = func(CmdInput) (CmdOutput, error) {
+				<-release
+				return CmdOutput{}, nil
+			}
+
+			startedPlugin := &stoppablePluginCPI{PluginCPI: plugin}
+
+			calls := biinstallmanifest.CPICalls{
+				CPICallPolicy: biinstallmanifest.CPICallPolicy{Timeout: 10 * time.Millisecond},
+			}
+			cpiCmdRunner := NewPluginCPICmdRunner(startedPlugin, calls, fakeEventLog, logger)
+
+			_, err := cpiCmdRunner.Run(context, "fake-method")
+			Expect(IsTimeoutError(err)).To(BeTrue())
+			Expect(startedPlugin.stopped).To(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("StartPlugin", func() {
+	var (
+		cmdRunner *fakesys.FakeCmdRunner
+		process   *fakesys.FakeProcess
+		logBuffer *gbytes.Buffer
+		logger    boshlog.Logger
+		cpi       CPI
+	)
+
+	BeforeEach(func() {
+		cmdRunner = fakesys.NewFakeCmdRunner()
+		process = &fakesys.FakeProcess{
+			TerminatedNicelyCallBack: func(p *fakesys.FakeProcess) {
+				p.WaitCh <- boshsys.Result{}
+			},
+		}
+		cmdRunner.AddProcess("/jobs/cpi/bin/cpi-plugin", process)
+
+		logBuffer = gbytes.NewBuffer()
+		logger = boshlog.NewWriterLogger(boshlog.LevelDebug, logBuffer, logBuffer)
+		cpi = CPI{JobPath: "/jobs/cpi", JobsDir: "/jobs", PackagesDir: "/packages"}
+	})
+
+	It("logs the standard error of the plugin", func() {
+		_, err := StartPlugin(cmdRunner, cpi, logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(cmdRunner.RunComplexCommands).To(HaveLen(1))
+		_, err = cmdRunner.RunComplexCommands[0].Stderr.Write([]byte("fake-plugin-stderr\n"))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(logBuffer).To(gbytes.Say("fake-plugin-stderr"))
+	})
+
+	It("stops the plugin", func() {
+		plugin, err := StartPlugin(cmdRunner, cpi, logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(plugin.Stop()).To(Succeed())
+		Expect(process.TerminatedNicely).To(BeTrue())
+	})
+
+	It("restarts a stopped plugin for the next call", func() {
+		plugin, err := StartPlugin(cmdRunner, cpi, logger)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(plugin.Stop()).To(Succeed())
+
+		restartedProcess := &fakesys.FakeProcess{
+			TerminatedNicelyCallBack: func(p *fakesys.FakeProcess) {
+				p.WaitCh <- boshsys.Result{}
+			},
+		}
+		cmdRunner.AddProcess("/jobs/cpi/bin/cpi-plugin", restartedProcess)
+
+		restarted := make(chan struct{}, 1)
+		cmdRunner.SetCmdCallback("/jobs/cpi/bin/cpi-plugin", func() { restarted <- struct{}{} })
+
+		callErrCh := make(chan error, 1)
+		go func() {
+			_, err := plugin.Call(CmdInput{Method: "info"})
+			callErrCh <- err
+		}()
+
+		Eventually(restarted).Should(Receive())
+
+		// the fake plugin never answers, stopping it fails the pending call
+		Expect(plugin.Stop()).To(Succeed())
+		Eventually(callErrCh).Should(Receive(HaveOccurred()))
+		Expect(restartedProcess.TerminatedNicely).To(BeTrue())
+	})
+})
@@ -0,0 +1,114 @@
+package cloud
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"github.com/pivotal-golang/clock"
+
+	biinstall "github.com/cloudfoundry/bosh-cli/installation"
+)
+
+type replayCPICmdRunner struct {
+	calls []TracedCall
+	next  int
+	lock  sync.Mutex
+}
+
+// NewReplayCPICmdRunner returns a runner answering CPI calls with the calls
+// traced to dir by a file Tracer, in the order they were traced, instead of
+// running a CPI. Calls have to be made in the same order, by method.
+func NewReplayCPICmdRunner(dir string, fs boshsys.FileSystem) (CPICmdRunner, error) {
+	paths, err := fs.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Finding CPI traces in '%s'", dir)
+	}
+
+	if len(paths) == 0 {
+		return nil, bosherr.Errorf("No CPI traces found in '%s'", dir)
+	}
+
+	sort.Strings(paths)
+
+	calls := []TracedCall{}
+
+	for _, path := range paths {
+		bytes, err := fs.ReadFile(path)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Reading CPI trace '%s'", path)
+		}
+
+		var call TracedCall
+
+		err = json.Unmarshal(bytes, &call)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Unmarshalling CPI trace '%s'", path)
+		}
+
+		calls = append(calls, call)
+	}
+
+	return &replayCPICmdRunner{calls: calls}, nil
+}
+
+func (r *replayCPICmdRunner) Run(context CmdContext, method string, args ...interface{}) (CmdOutput, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.next >= len(r.calls) {
+		return CmdOutput{}, bosherr.Errorf("Replaying CPI call '%s': all %d traced calls have been replayed", method, len(r.calls))
+	}
+
+	call := r.calls[r.next]
+
+	if call.Method != method {
+		return CmdOutput{}, bosherr.Errorf("Replaying CPI call %d: expected '%s' but got '%s'", r.next+1, call.Method, method)
+	}
+
+	r.next++
+
+	if call.Response == nil {
+		return CmdOutput{}, bosherr.Errorf("Replaying CPI call %d '%s': %s", r.next, method, call.Error)
+	}
+
+	return *call.Response, nil
+}
+
+type replayFactory struct {
+	factory      *factory
+	cpiCmdRunner CPICmdRunner
+}
+
+// NewReplayFactory returns a Factory whose clouds make their calls to
+// cpiCmdRunner, e.g. a replay runner, instead of to the installed CPI.
+func NewReplayFactory(
+	cpiCmdRunner CPICmdRunner,
+	tracer Tracer,
+	timeService clock.Clock,
+	logger boshlog.Logger,
+) Factory {
+	return replayFactory{
+		factory: &factory{
+			tracer:      tracer,
+			timeService: timeService,
+			logger:      logger,
+			logTag:      "replayCloudFactory",
+			infos:       map[string]CPIInfo{},
+		},
+		cpiCmdRunner: cpiCmdRunner,
+	}
+}
+
+func (f replayFactory) NewCloud(_ biinstall.Installation, directorID string, stemcellAPIVersion int) (Cloud, error) {
+	cpiCmdRunner := NewTracingCPICmdRunner(f.cpiCmdRunner, f.factory.tracer, f.factory.timeService)
+
+	// like the installed CPI, the replayed CPI is asked for its info once
+	apiVersion := f.factory.apiVersion(cpiCmdRunner, "", directorID)
+
+	return NewCloudWithAPIVersion(cpiCmdRunner, directorID, apiVersion, stemcellAPIVersion, f.factory.logger), nil
+}
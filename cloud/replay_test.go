@@ -0,0 +1,119 @@
+package cloud_test
+
+import (
+	"time"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/cloudfoundry/bosh-cli/cloud"
+	fakebicloud "github.com/cloudfoundry/bosh-cli/cloud/fakes"
+	bilog "github.com/cloudfoundry/bosh-cli/logger"
+)
+
+var _ = Describe("Replay", func() {
+	var (
+		fs          *fakesys.FakeFileSystem
+		timeService *fakeclock.FakeClock
+		logger      boshlog.Logger
+		context     CmdContext
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		timeService = fakeclock.NewFakeClock(time.Date(2009, time.November, 10, 23, 1, 2, 0, time.UTC))
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+		context = CmdContext{DirectorID: "fake-director-id"}
+	})
+
+	record := func(paths []string, calls func(CPICmdRunner)) {
+		tracer, err := NewFileTracer("/fake-trace-dir", fs, bilog.NewRedactor(), logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		cpiCmdRunner := fakebicloud.NewFakeCPICmdRunner()
+		calls(NewTracingCPICmdRunner(&recordingCPICmdRunner{fake: cpiCmdRunner}, tracer, timeService))
+
+		fs.SetGlob("/fake-trace-dir/*.json", paths)
+	}
+
+	Describe("NewReplayCPICmdRunner", func() {
+		BeforeEach(func() {
+			// globbed out of order to show that traces are sorted
+			record([]string{"/fake-trace-dir/0002-create_vm.json", "/fake-trace-dir/0001-info.json"}, func(runner CPICmdRunner) {
+				runner.Run(context, "info")
+				runner.Run(context, "create_vm", "fake-agent-id")
+			})
+		})
+
+		It("answers calls with the traced responses in order", func() {
+			replay, err := NewReplayCPICmdRunner("/fake-trace-dir", fs)
+			Expect(err).ToNot(HaveOccurred())
+
+			output, err := replay.Run(context, "info")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(output.Result).To(Equal(map[string]interface{}{"api_version": float64(2)}))
+
+			output, err = replay.Run(context, "create_vm", "fake-agent-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(output.Result).To(Equal("fake-vm-cid"))
+
+			_, err = replay.Run(context, "delete_vm", "fake-vm-cid")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Replaying CPI call 'delete_vm': all 2 traced calls have been replayed"))
+		})
+
+		It("returns an error when calls are made in another order", func() {
+			replay, err := NewReplayCPICmdRunner("/fake-trace-dir", fs)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = replay.Run(context, "create_vm", "fake-agent-id")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Replaying CPI call 1: expected 'info' but got 'create_vm'"))
+		})
+
+		It("returns an error when there are no traces", func() {
+			_, err := NewReplayCPICmdRunner("/fake-other-dir", fs)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("No CPI traces found in '/fake-other-dir'"))
+		})
+	})
+
+	Describe("NewReplayFactory", func() {
+		It("returns clouds replaying the traced info before their calls", func() {
+			record([]string{"/fake-trace-dir/0001-info.json", "/fake-trace-dir/0002-delete_vm.json"}, func(runner CPICmdRunner) {
+				runner.Run(context, "info")
+				runner.Run(context, "delete_vm", "fake-vm-cid")
+			})
+
+			replay, err := NewReplayCPICmdRunner("/fake-trace-dir", fs)
+			Expect(err).ToNot(HaveOccurred())
+
+			cloud, err := NewReplayFactory(replay, NewNoopTracer(), timeService, logger).NewCloud(nil, "fake-director-id", 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(cloud.DeleteVM("fake-vm-cid")).To(Succeed())
+		})
+	})
+})
+
+// recordingCPICmdRunner answers info and create_vm like a CPI speaking
+// version 2 would, and any other call with no result.
+type recordingCPICmdRunner struct {
+	fake *fakebicloud.FakeCPICmdRunner
+}
+
+func (r *recordingCPICmdRunner) Run(context CmdContext, method string, args ...interface{}) (CmdOutput, error) {
+	switch method {
+	case "info":
+		r.fake.RunCmdOutput = CmdOutput{Result: map[string]interface{}{"api_version": 2}}
+	case "create_vm":
+		r.fake.RunCmdOutput = CmdOutput{Result: "fake-vm-cid"}
+	default:
+		r.fake.RunCmdOutput = CmdOutput{}
+	}
+
+	return r.fake.Run(context, method, args...)
+}
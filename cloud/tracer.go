@@ -0,0 +1,135 @@
+package cloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"github.com/pivotal-golang/clock"
+
+	bilog "github.com/cloudfoundry/bosh-cli/logger"
+)
+
+// TracedCall is a CPI call captured by a Tracer. Duration is in seconds.
+type TracedCall struct {
+	Method    string     `json:"method"`
+	StartedAt time.Time  `json:"started_at"`
+	Duration  float64    `json:"duration"`
+	Request   CmdInput   `json:"request"`
+	Response  *CmdOutput `json:"response,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// Tracer captures the requests sent to the CPI and its responses, for
+// debugging CPIs and replaying their calls. Failing to trace a call never
+// fails the call.
+type Tracer interface {
+	Trace(TracedCall)
+}
+
+type fileTracer struct {
+	dir      string
+	fs       boshsys.FileSystem
+	redactor *bilog.Redactor
+	lock     sync.Mutex
+	calls    int
+
+	logTag string
+	logger boshlog.Logger
+}
+
+// NewFileTracer writes each traced call to its own file in dir, numbered in
+// the order of the calls, with secrets redacted.
+func NewFileTracer(dir string, fs boshsys.FileSystem, redactor *bilog.Redactor, logger boshlog.Logger) (Tracer, error) {
+	err := fs.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Creating CPI trace directory '%s'", dir)
+	}
+
+	return &fileTracer{
+		dir:      dir,
+		fs:       fs,
+		redactor: redactor,
+
+		logTag: "cpiTracer",
+		logger: logger,
+	}, nil
+}
+
+func (t *fileTracer) Trace(call TracedCall) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.calls++
+
+	bytes, err := json.MarshalIndent(call, "", "  ")
+	if err != nil {
+		t.logger.Error(t.logTag, "Marshalling CPI call '%s': %s", call.Method, err.Error())
+		return
+	}
+
+	path := filepath.Join(t.dir, fmt.Sprintf("%04d-%s.json", t.calls, call.Method))
+
+	err = t.fs.WriteFileString(path, t.redactor.Redact(string(bytes))+"\n")
+	if err != nil {
+		t.logger.Error(t.logTag, "Writing CPI call '%s': %s", call.Method, err.Error())
+	}
+}
+
+type noopTracer struct{}
+
+// NewNoopTracer returns a Tracer for runs without CPI tracing.
+func NewNoopTracer() Tracer {
+	return noopTracer{}
+}
+
+func (noopTracer) Trace(TracedCall) {}
+
+type tracingCPICmdRunner struct {
+	cpiCmdRunner CPICmdRunner
+	tracer       Tracer
+	timeService  clock.Clock
+}
+
+// NewTracingCPICmdRunner returns a runner passing the calls to cpiCmdRunner
+// and tracing them, including retries, as a single call each.
+func NewTracingCPICmdRunner(cpiCmdRunner CPICmdRunner, tracer Tracer, timeService clock.Clock) CPICmdRunner {
+	return tracingCPICmdRunner{
+		cpiCmdRunner: cpiCmdRunner,
+		tracer:       tracer,
+		timeService:  timeService,
+	}
+}
+
+func (r tracingCPICmdRunner) Run(context CmdContext, method string, args ...interface{}) (CmdOutput, error) {
+	startedAt := r.timeService.Now()
+
+	cmdOutput, err := r.cpiCmdRunner.Run(context, method, args...)
+
+	call := TracedCall{
+		Method:    method,
+		StartedAt: startedAt,
+		Duration:  r.timeService.Now().Sub(startedAt).Seconds(),
+		Request: CmdInput{
+			Method:     method,
+			Arguments:  args,
+			Context:    context,
+			APIVersion: context.APIVersion,
+		},
+	}
+
+	if err != nil {
+		call.Error = err.Error()
+	} else {
+		call.Response = &cmdOutput
+	}
+
+	r.tracer.Trace(call)
+
+	return cmdOutput, err
+}
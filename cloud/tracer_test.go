@@ -0,0 +1,98 @@
+package cloud_test
+
+import (
+	"errors"
+	"time"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/cloudfoundry/bosh-cli/cloud"
+	fakebicloud "github.com/cloudfoundry/bosh-cli/cloud/fakes"
+	bilog "github.com/cloudfoundry/bosh-cli/logger"
+)
+
+var _ = Describe("Tracer", func() {
+	var (
+		fs           *fakesys.FakeFileSystem
+		cpiCmdRunner *fakebicloud.FakeCPICmdRunner
+		tracer       Tracer
+		runner       CPICmdRunner
+		context      CmdContext
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		cpiCmdRunner = fakebicloud.NewFakeCPICmdRunner()
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+
+		var err error
+		tracer, err = NewFileTracer("/fake-trace-dir", fs, bilog.NewRedactor(), logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		timeService := fakeclock.NewFakeClock(time.Date(2009, time.November, 10, 23, 1, 2, 0, time.UTC))
+		runner = NewTracingCPICmdRunner(cpiCmdRunner, tracer, timeService)
+		context = CmdContext{DirectorID: "fake-director-id"}
+	})
+
+	It("writes each call to a numbered file with secrets redacted", func() {
+		cpiCmdRunner.RunCmdOutput = CmdOutput{Result: "fake-vm-cid"}
+
+		output, err := runner.Run(context, "create_vm", "fake-agent-id", map[string]interface{}{"password": "fake-secret"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(output).To(Equal(CmdOutput{Result: "fake-vm-cid"}))
+
+		_, err = runner.Run(context, "delete_vm", "fake-vm-cid")
+		Expect(err).ToNot(HaveOccurred())
+
+		trace, err := fs.ReadFileString("/fake-trace-dir/0001-create_vm.json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(trace).To(Equal(`{
+  "method": "create_vm",
+  "started_at": "2009-11-10T23:01:02Z",
+  "duration": 0,
+  "request": {
+    "method": "create_vm",
+    "arguments": [
+      "fake-agent-id",
+      {
+        "password": "<redacted>"
+      }
+    ],
+    "context": {
+      "director_uuid": "fake-director-id"
+    }
+  },
+  "response": {
+    "result": "fake-vm-cid",
+    "log": ""
+  }
+}
+`))
+
+		Expect(fs.FileExists("/fake-trace-dir/0002-delete_vm.json")).To(BeTrue())
+	})
+
+	It("records errors running the call", func() {
+		cpiCmdRunner.RunErr = errors.New("fake-run-err")
+
+		_, err := runner.Run(context, "info")
+		Expect(err).To(HaveOccurred())
+
+		trace, err := fs.ReadFileString("/fake-trace-dir/0001-info.json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(trace).To(ContainSubstring(`"error": "fake-run-err"`))
+		Expect(trace).ToNot(ContainSubstring(`"response"`))
+	})
+
+	It("returns an error when the trace directory cannot be created", func() {
+		fs.MkdirAllError = errors.New("fake-mkdir-err")
+
+		_, err := NewFileTracer("/fake-other-dir", fs, bilog.NewRedactor(), boshlog.NewLogger(boshlog.LevelNone))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-mkdir-err"))
+	})
+})
@@ -0,0 +1,14 @@
+package cmd
+
+import (
+	biagent "github.com/cloudfoundry/bosh-cli/deployment/agent"
+)
+
+func (f AgentFlags) AsMbusOpts() biagent.MbusOpts {
+	return biagent.MbusOpts{
+		Attempts:       f.AgentAttempts,
+		RetryDelay:     f.AgentRetryDelay,
+		RequestTimeout: f.AgentRequestTimeout,
+		TaskTimeout:    f.AgentTaskTimeout,
+	}
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"github.com/cppforlife/go-patch/patch"
+
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	boshui "github.com/cloudfoundry/bosh-cli/ui"
+)
+
+type AttachDiskEnvCmd struct {
+	envProvider func(string, string, boshtpl.Variables, patch.Op) EnvDisks
+	ui          boshui.UI
+}
+
+func NewAttachDiskEnvCmd(envProvider func(string, string, boshtpl.Variables, patch.Op) EnvDisks, ui boshui.UI) AttachDiskEnvCmd {
+	return AttachDiskEnvCmd{envProvider: envProvider, ui: ui}
+}
+
+func (c AttachDiskEnvCmd) Run(opts AttachDiskEnvOpts) error {
+	envDisks := c.envProvider(
+		opts.Args.Manifest.Path, opts.StatePath, opts.VarFlags.AsVariables(), opts.OpsFlags.AsOp())
+
+	err := envDisks.AttachDisk(opts.Args.DiskCID, opts.Instance)
+	if err != nil {
+		return err
+	}
+
+	c.ui.PrintLinef("Disk '%s' will be attached when the environment is next updated with create-env", opts.Args.DiskCID)
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package cmd_test
+
+import (
+	"errors"
+
+	"github.com/cppforlife/go-patch/patch"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/cmd"
+	mock_cmd "github.com/cloudfoundry/bosh-cli/cmd/mocks"
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	fakeui "github.com/cloudfoundry/bosh-cli/ui/fakes"
+)
+
+var _ = Describe("AttachDiskEnvCmd", func() {
+	var mockCtrl *gomock.Controller
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	var (
+		mockEnvDisks *mock_cmd.MockEnvDisks
+		ui           *fakeui.FakeUI
+		command      AttachDiskEnvCmd
+
+		opts         AttachDiskEnvOpts
+		manifestPath string
+		statePath    string
+	)
+
+	BeforeEach(func() {
+		mockEnvDisks = mock_cmd.NewMockEnvDisks(mockCtrl)
+		ui = &fakeui.FakeUI{}
+
+		envProvider := func(manifestPath_ string, statePath_ string, _ boshtpl.Variables, _ patch.Op) EnvDisks {
+			manifestPath = manifestPath_
+			statePath = statePath_
+			return mockEnvDisks
+		}
+
+		command = NewAttachDiskEnvCmd(envProvider, ui)
+
+		opts = AttachDiskEnvOpts{
+			Args: AttachDiskEnvArgs{
+				Manifest: FileBytesWithPathArg{Path: "/path/to/bosh.yml"},
+				DiskCID:  "fake-disk-cid",
+			},
+			StatePath: "/path/to/state.json",
+		}
+	})
+
+	act := func() error { return command.Run(opts) }
+
+	It("attaches the disk with the state of the manifest", func() {
+		mockEnvDisks.EXPECT().AttachDisk("fake-disk-cid", 0).Return(nil)
+
+		Expect(act()).ToNot(HaveOccurred())
+
+		Expect(manifestPath).To(Equal("/path/to/bosh.yml"))
+		Expect(statePath).To(Equal("/path/to/state.json"))
+		Expect(ui.Said).To(Equal([]string{
+			"Disk 'fake-disk-cid' will be attached when the environment is next updated with create-env",
+		}))
+	})
+
+	It("attaches the disk to the VM of the given instance", func() {
+		opts.Instance = 1
+		mockEnvDisks.EXPECT().AttachDisk("fake-disk-cid", 1).Return(nil)
+
+		Expect(act()).ToNot(HaveOccurred())
+	})
+
+	It("returns an error when attaching the disk fails", func() {
+		mockEnvDisks.EXPECT().AttachDisk("fake-disk-cid", 0).Return(errors.New("fake-err"))
+
+		err := act()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("fake-err"))
+		Expect(ui.Said).To(BeEmpty())
+	})
+})
@@ -8,7 +8,9 @@ import (
 	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
 	"github.com/pivotal-golang/clock"
 
+	bicloud "github.com/cloudfoundry/bosh-cli/cloud"
 	bicrypto "github.com/cloudfoundry/bosh-cli/crypto"
+	bieventlog "github.com/cloudfoundry/bosh-cli/eventlog"
 	boshui "github.com/cloudfoundry/bosh-cli/ui"
 )
 
@@ -24,6 +26,18 @@ type BasicDeps struct {
 	DigestCreationAlgorithms []boshcrypto.Algorithm
 
 	Time clock.Clock
+
+	EventLog  bieventlog.Log
+	CPITracer bicloud.Tracer
+
+	// CPIReplay answers CPI calls instead of the installed CPI when set
+	CPIReplay bicloud.CPICmdRunner
+
+	// TmpDir keeps temporary files instead of ~/.bosh/tmp and the
+	// installations when set
+	TmpDir string
+
+	ExitCleanup *ExitCleanup
 }
 
 func NewBasicDeps(ui *boshui.ConfUI, logger boshlog.Logger) BasicDeps {
@@ -47,6 +61,11 @@ func NewBasicDepsWithFS(ui *boshui.ConfUI, fs boshsys.FileSystem, logger boshlog
 		DigestCalculator:         digestCalculator,
 		DigestCreationAlgorithms: digestCreationAlgorithms,
 		Time: clock.NewClock(),
+
+		EventLog:  bieventlog.NewNoopLog(),
+		CPITracer: bicloud.NewNoopTracer(),
+
+		ExitCleanup: NewExitCleanup(),
 	}
 }
 
@@ -57,3 +76,23 @@ func (b BasicDeps) WithSha2CheckSumming() BasicDeps {
 	b.DigestCalculator = bicrypto.NewDigestCalculator(b.FS, b.DigestCreationAlgorithms)
 	return b
 }
+
+func (b BasicDeps) WithEventLog(eventLog bieventlog.Log) BasicDeps {
+	b.EventLog = eventLog
+	return b
+}
+
+func (b BasicDeps) WithTmpDir(path string) BasicDeps {
+	b.TmpDir = path
+	return b
+}
+
+func (b BasicDeps) WithCPITracer(tracer bicloud.Tracer) BasicDeps {
+	b.CPITracer = tracer
+	return b
+}
+
+func (b BasicDeps) WithCPIReplay(cpiCmdRunner bicloud.CPICmdRunner) BasicDeps {
+	b.CPIReplay = cpiCmdRunner
+	return b
+}
@@ -2,18 +2,30 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 
 	"github.com/cppforlife/go-patch/patch"
 
+	bicloud "github.com/cloudfoundry/bosh-cli/cloud"
 	cmdconf "github.com/cloudfoundry/bosh-cli/cmd/config"
+	biconfig "github.com/cloudfoundry/bosh-cli/config"
 	"github.com/cloudfoundry/bosh-cli/crypto"
+	biagent "github.com/cloudfoundry/bosh-cli/deployment/agent"
+	bideplmanifest "github.com/cloudfoundry/bosh-cli/deployment/manifest"
 	boshdir "github.com/cloudfoundry/bosh-cli/director"
 	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	bieventlog "github.com/cloudfoundry/bosh-cli/eventlog"
+	bitarball "github.com/cloudfoundry/bosh-cli/installation/tarball"
+	bilog "github.com/cloudfoundry/bosh-cli/logger"
 	boshrel "github.com/cloudfoundry/bosh-cli/release"
 	boshreldir "github.com/cloudfoundry/bosh-cli/releasedir"
 	boshssh "github.com/cloudfoundry/bosh-cli/ssh"
 	bistemcell "github.com/cloudfoundry/bosh-cli/stemcell"
+	bitemplate "github.com/cloudfoundry/bosh-cli/templatescompiler"
+	bitemplateerb "github.com/cloudfoundry/bosh-cli/templatescompiler/erbrenderer"
 	boshui "github.com/cloudfoundry/bosh-cli/ui"
 	boshuit "github.com/cloudfoundry/bosh-cli/ui/task"
 
@@ -38,6 +50,12 @@ type cmdConveniencePanic struct {
 }
 
 func (c Cmd) Execute() (cmdErr error) {
+	// Registered before all others to categorize the error they return
+	defer func() { cmdErr = CategorizeError(cmdErr) }()
+
+	// Registered first to record the error caught from convenience panics
+	defer func() { c.finishEventLog(cmdErr) }()
+
 	// Catch convenience panics from panicIfErr
 	defer func() {
 		if r := recover(); r != nil {
@@ -50,12 +68,30 @@ func (c Cmd) Execute() (cmdErr error) {
 	}()
 
 	c.configureUI()
+
+	if len(c.BoshOpts.TmpDirOpt) > 0 {
+		c.deps = c.deps.WithTmpDir(c.tmpDir())
+	}
+
 	c.configureFS()
 
 	if c.BoshOpts.Sha2 {
 		c.deps = c.deps.WithSha2CheckSumming()
 	}
 
+	if len(c.BoshOpts.EventLogOpt) > 0 {
+		c.deps = c.deps.WithEventLog(c.eventLog())
+		c.deps.EventLog.Record(bieventlog.Event{Type: bieventlog.RunStarted})
+	}
+
+	if len(c.BoshOpts.CPITraceOpt) > 0 {
+		c.deps = c.deps.WithCPITracer(c.cpiTracer())
+	}
+
+	if len(c.BoshOpts.CPIReplayOpt) > 0 {
+		c.deps = c.deps.WithCPIReplay(c.cpiReplay())
+	}
+
 	deps := c.deps
 
 	switch opts := c.Opts.(type) {
@@ -66,21 +102,124 @@ func (c Cmd) Execute() (cmdErr error) {
 		return NewEnvironmentsCmd(c.config(), deps.UI).Run()
 
 	case *CreateEnvOpts:
+		downloadOpts := opts.DownloadFlags.AsDownloadOpts()
+		downloadOpts.RecreateCache = opts.RecreateCache
+
 		envProvider := func(manifestPath string, statePath string, vars boshtpl.Variables, op patch.Op) DeploymentPreparer {
-			return NewEnvFactory(deps, manifestPath, statePath, vars, op).Preparer()
+			return NewEnvFactory(deps, manifestPath, statePath, opts.StatePassphrase, vars, opts.VarFlags.Sources(), op, opts.Parallel, downloadOpts, opts.AgentFlags.AsMbusOpts(), opts.SkipDiskMigration).Preparer(opts.CloudConfig, opts.RuntimeConfig, opts.StrictProperties)
 		}
 
-		stage := boshui.NewStage(deps.UI, deps.Time, deps.Logger)
+		stage := bieventlog.NewStage(boshui.NewStage(deps.UI, deps.Time, deps.Logger), deps.EventLog, deps.Time)
+		interrupted, stopInterrupting := c.interruptOnSignal()
+		defer stopInterrupting()
+
+		stage = boshui.NewInterruptibleStage(stage, interrupted)
 		return NewCreateEnvCmd(deps.UI, envProvider).Run(stage, *opts)
 
 	case *DeleteEnvOpts:
+		downloadOpts := opts.DownloadFlags.AsDownloadOpts()
 		envProvider := func(manifestPath string, statePath string, vars boshtpl.Variables, op patch.Op) DeploymentDeleter {
-			return NewEnvFactory(deps, manifestPath, statePath, vars, op).Deleter()
+			return NewEnvFactory(deps, manifestPath, statePath, opts.StatePassphrase, vars, opts.VarFlags.Sources(), op, 1, downloadOpts, opts.AgentFlags.AsMbusOpts(), false).Deleter()
 		}
 
-		stage := boshui.NewStage(deps.UI, deps.Time, deps.Logger)
+		stage := bieventlog.NewStage(boshui.NewStage(deps.UI, deps.Time, deps.Logger), deps.EventLog, deps.Time)
+		interrupted, stopInterrupting := c.interruptOnSignal()
+		defer stopInterrupting()
+
+		stage = boshui.NewInterruptibleStage(stage, interrupted)
 		return NewDeleteCmd(deps.UI, envProvider).Run(stage, *opts)
 
+	case *DiffEnvOpts:
+		envProvider := func(manifestPath string, statePath string, vars boshtpl.Variables, op patch.Op) DeploymentPreparer {
+			return NewEnvFactory(deps, manifestPath, statePath, opts.StatePassphrase, vars, opts.VarFlags.Sources(), op, 1, bitarball.DownloadOpts{}, biagent.MbusOpts{}, false).Preparer(opts.CloudConfig, opts.RuntimeConfig, false)
+		}
+
+		stage := bieventlog.NewStage(boshui.NewStage(deps.UI, deps.Time, deps.Logger), deps.EventLog, deps.Time)
+		return NewDiffEnvCmd(deps.UI, envProvider).Run(stage, *opts)
+
+	case *ValidateEnvOpts:
+		envProvider := func(manifestPath string, statePath string, vars boshtpl.Variables, op patch.Op) DeploymentPreparer {
+			return NewEnvFactory(deps, manifestPath, statePath, opts.StatePassphrase, vars, opts.VarFlags.Sources(), op, 1, bitarball.DownloadOpts{}, biagent.MbusOpts{}, false).Preparer(opts.CloudConfig, opts.RuntimeConfig, true)
+		}
+
+		stage := bieventlog.NewStage(boshui.NewStage(deps.UI, deps.Time, deps.Logger), deps.EventLog, deps.Time)
+		return NewValidateEnvCmd(deps.UI, envProvider).Run(stage, *opts)
+
+	case *SSHEnvOpts:
+		envProvider := func(manifestPath string, statePath string, vars boshtpl.Variables, op patch.Op) EnvAgent {
+			return NewEnvFactory(deps, manifestPath, statePath, opts.StatePassphrase, vars, opts.VarFlags.Sources(), op, 1, bitarball.DownloadOpts{}, biagent.MbusOpts{}, false).Agent()
+		}
+
+		sshProvider := boshssh.NewProvider(deps.CmdRunner, deps.FS, deps.UI, deps.Logger)
+		intSSHRunner := sshProvider.NewSSHRunner(true)
+		nonIntSSHRunner := sshProvider.NewSSHRunner(false)
+		resultsSSHRunner := sshProvider.NewResultsSSHRunner(false)
+		return NewSSHEnvCmd(envProvider, intSSHRunner, nonIntSSHRunner, resultsSSHRunner, deps.UI).Run(*opts)
+
+	case *LogsEnvOpts:
+		envProvider := func(manifestPath string, statePath string, vars boshtpl.Variables, op patch.Op) EnvAgent {
+			return NewEnvFactory(deps, manifestPath, statePath, opts.StatePassphrase, vars, opts.VarFlags.Sources(), op, 1, bitarball.DownloadOpts{}, biagent.MbusOpts{}, false).Agent()
+		}
+
+		return NewLogsEnvCmd(envProvider, deps.Time, deps.FS, deps.UI).Run(*opts)
+
+	case *InstancesEnvOpts:
+		envProvider := func(manifestPath string, statePath string, vars boshtpl.Variables, op patch.Op) EnvAgent {
+			return NewEnvFactory(deps, manifestPath, statePath, opts.StatePassphrase, vars, opts.VarFlags.Sources(), op, 1, bitarball.DownloadOpts{}, biagent.MbusOpts{}, false).Agent()
+		}
+
+		return NewInstancesEnvCmd(envProvider, deps.UI).Run(*opts)
+
+	case *RunErrandEnvOpts:
+		envProvider := func(manifestPath string, statePath string, vars boshtpl.Variables, op patch.Op) EnvAgent {
+			return NewEnvFactory(deps, manifestPath, statePath, opts.StatePassphrase, vars, opts.VarFlags.Sources(), op, 1, bitarball.DownloadOpts{}, biagent.MbusOpts{}, false).Agent()
+		}
+
+		deploymentParser := bideplmanifest.NewParser(deps.FS, deps.Logger)
+
+		return NewRunErrandEnvCmd(envProvider, deploymentParser, deps.UI).Run(*opts)
+
+	case *StateEnvOpts:
+		envProvider := func(manifestPath string, statePath string, vars boshtpl.Variables, op patch.Op) biconfig.DeploymentStateService {
+			return NewEnvFactory(deps, manifestPath, statePath, opts.StatePassphrase, vars, opts.VarFlags.Sources(), op, 1, bitarball.DownloadOpts{}, biagent.MbusOpts{}, false).State()
+		}
+
+		return NewStateEnvCmd(envProvider, deps.UI).Run(*opts)
+
+	case *DiagnoseEnvOpts:
+		stateProvider := func(manifestPath string, statePath string, vars boshtpl.Variables, op patch.Op) biconfig.DeploymentStateService {
+			return NewEnvFactory(deps, manifestPath, statePath, opts.StatePassphrase, vars, opts.VarFlags.Sources(), op, 1, bitarball.DownloadOpts{}, biagent.MbusOpts{}, false).State()
+		}
+
+		envProvider := func(manifestPath string, statePath string, vars boshtpl.Variables, op patch.Op) EnvAgent {
+			return NewEnvFactory(deps, manifestPath, statePath, opts.StatePassphrase, vars, opts.VarFlags.Sources(), op, 1, bitarball.DownloadOpts{}, biagent.MbusOpts{}, false).Agent()
+		}
+
+		return NewDiagnoseEnvCmd(stateProvider, envProvider, deps.Compressor, deps.Time, deps.FS, c.redactor(), deps.UI).Run(*opts)
+
+	case *DisksEnvOpts:
+		envProvider := func(manifestPath string, statePath string, vars boshtpl.Variables, op patch.Op) EnvDisks {
+			return NewEnvFactory(deps, manifestPath, statePath, opts.StatePassphrase, vars, opts.VarFlags.Sources(), op, 1, bitarball.DownloadOpts{}, biagent.MbusOpts{}, false).Disks()
+		}
+
+		return NewDisksEnvCmd(envProvider, deps.UI).Run(*opts)
+
+	case *AttachDiskEnvOpts:
+		envProvider := func(manifestPath string, statePath string, vars boshtpl.Variables, op patch.Op) EnvDisks {
+			return NewEnvFactory(deps, manifestPath, statePath, opts.StatePassphrase, vars, opts.VarFlags.Sources(), op, 1, bitarball.DownloadOpts{}, biagent.MbusOpts{}, false).Disks()
+		}
+
+		return NewAttachDiskEnvCmd(envProvider, deps.UI).Run(*opts)
+
+	case *DeleteDiskEnvOpts:
+		downloadOpts := opts.DownloadFlags.AsDownloadOpts()
+		envProvider := func(manifestPath string, statePath string, vars boshtpl.Variables, op patch.Op) DeploymentDeleter {
+			return NewEnvFactory(deps, manifestPath, statePath, opts.StatePassphrase, vars, opts.VarFlags.Sources(), op, 1, downloadOpts, biagent.MbusOpts{}, false).Deleter()
+		}
+
+		stage := bieventlog.NewStage(boshui.NewStage(deps.UI, deps.Time, deps.Logger), deps.EventLog, deps.Time)
+		return NewDeleteDiskEnvCmd(deps.UI, envProvider).Run(stage, *opts)
+
 	case *AliasEnvOpts:
 		sessionFactory := func(config cmdconf.Config) Session {
 			return NewSessionFromOpts(c.BoshOpts, config, deps.UI, true, false, deps.FS, deps.Logger)
@@ -180,6 +319,12 @@ func (c Cmd) Execute() (cmdErr error) {
 
 		return NewRepackStemcellCmd(deps.UI, deps.FS, stemcellExtractor).Run(*opts)
 
+	case *InspectStemcellOpts:
+		stemcellReader := bistemcell.NewReader(deps.Compressor, deps.FS)
+		stemcellExtractor := bistemcell.NewExtractor(stemcellReader, deps.FS)
+
+		return NewInspectStemcellCmd(deps.UI, deps.FS, stemcellExtractor).Run(*opts)
+
 	case *LocksOpts:
 		return NewLocksCmd(deps.UI, c.director()).Run()
 
@@ -345,6 +490,23 @@ func (c Cmd) Execute() (cmdErr error) {
 		_, err := NewCreateReleaseCmd(releaseDirFactory, relProv.NewArchiveWriter(), c.deps.FS, c.deps.UI).Run(*opts)
 		return err
 
+	case *InspectLocalReleaseOpts:
+		relProv, _ := c.releaseProviders()
+		return NewInspectLocalReleaseCmd(relProv.NewArchiveReader(), deps.FS, deps.UI).Run(*opts)
+
+	case *RenderTemplatesOpts:
+		relProv, _ := c.releaseProviders()
+		erbRenderer := bitemplateerb.NewGoFirstERBRenderer(deps.FS, deps.CmdRunner, deps.Logger)
+		jobRenderer := bitemplate.NewJobRenderer(erbRenderer, deps.FS, deps.UUIDGen, deps.Logger)
+
+		return NewRenderTemplatesCmd(
+			relProv.NewExtractingArchiveReader(),
+			bideplmanifest.NewParser(deps.FS, deps.Logger),
+			jobRenderer,
+			deps.FS,
+			deps.UI,
+		).Run(*opts)
+
 	case *Sha2ifyReleaseOpts:
 		relProv, _ := c.releaseProviders()
 
@@ -409,11 +571,116 @@ func (c Cmd) configureUI() {
 }
 
 func (c Cmd) configureFS() {
-	tmpDirPath, err := c.deps.FS.ExpandPath(filepath.Join("~", ".bosh", "tmp"))
+	tmpDirPath := c.deps.TmpDir
+
+	if len(tmpDirPath) == 0 {
+		var err error
+		tmpDirPath, err = c.deps.FS.ExpandPath(filepath.Join("~", ".bosh", "tmp"))
+		c.panicIfErr(err)
+	}
+
+	err := c.deps.FS.ChangeTempRoot(tmpDirPath)
+	c.panicIfErr(err)
+}
+
+func (c Cmd) tmpDir() string {
+	path, err := c.deps.FS.ExpandPath(c.BoshOpts.TmpDirOpt)
+	c.panicIfErr(err)
+
+	return path
+}
+
+func (c Cmd) eventLog() bieventlog.Log {
+	path, err := c.deps.FS.ExpandPath(c.BoshOpts.EventLogOpt)
+	c.panicIfErr(err)
+
+	eventLog, err := bieventlog.NewFileLog(path, c.deps.FS, c.deps.Time, c.redactor(), c.deps.Logger)
+	c.panicIfErr(err)
+
+	return eventLog
+}
+
+func (c Cmd) cpiTracer() bicloud.Tracer {
+	path, err := c.deps.FS.ExpandPath(c.BoshOpts.CPITraceOpt)
 	c.panicIfErr(err)
 
-	err = c.deps.FS.ChangeTempRoot(tmpDirPath)
+	tracer, err := bicloud.NewFileTracer(path, c.deps.FS, c.redactor(), c.deps.Logger)
 	c.panicIfErr(err)
+
+	return tracer
+}
+
+// redactor masks secrets in the files written for post-mortem analysis,
+// unless the --redact=false option is given.
+func (c Cmd) redactor() *bilog.Redactor {
+	redactor := bilog.NewRedactor()
+	redactor.SetEnabled(c.BoshOpts.RedactOpt != "false")
+
+	return redactor
+}
+
+func (c Cmd) cpiReplay() bicloud.CPICmdRunner {
+	path, err := c.deps.FS.ExpandPath(c.BoshOpts.CPIReplayOpt)
+	c.panicIfErr(err)
+
+	cpiCmdRunner, err := bicloud.NewReplayCPICmdRunner(path, c.deps.FS)
+	c.panicIfErr(err)
+
+	return cpiCmdRunner
+}
+
+// interruptOnSignal returns a channel closed on the first SIGINT or SIGTERM
+// so that a command stops after its current step and cleans up as it returns.
+// The next signal exits right away, after the cleanup registered with the
+// ExitCleanup of the deps, e.g. releasing the lock on the deployment state.
+// The returned func stops handling the signals.
+func (c Cmd) interruptOnSignal() (<-chan struct{}, func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	interrupted := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		select {
+		case <-signals:
+		case <-stopped:
+			return
+		}
+
+		c.deps.Logger.Info("cmd", "Interrupted, stopping after the current step")
+		c.deps.UI.ErrorLinef("Interrupted, stopping after the current step. Interrupt again to exit immediately.")
+		close(interrupted)
+
+		select {
+		case <-signals:
+		case <-stopped:
+			return
+		}
+
+		c.deps.Logger.Info("cmd", "Interrupted again, exiting")
+		c.deps.ExitCleanup.Run()
+		c.deps.UI.Flush()
+		os.Exit(ExitCodeUserAbort)
+	}()
+
+	return interrupted, func() {
+		signal.Stop(signals)
+		close(stopped)
+	}
+}
+
+func (c Cmd) finishEventLog(cmdErr error) {
+	if cmdErr != nil {
+		c.deps.EventLog.Record(bieventlog.NewErrorEvent(bieventlog.RunError, cmdErr))
+	}
+
+	c.deps.EventLog.Record(bieventlog.Event{Type: bieventlog.RunFinished})
+
+	err := c.deps.EventLog.Close()
+	if err != nil {
+		c.deps.Logger.Error("cmd", "Closing event log: %s", err.Error())
+	}
 }
 
 func (c Cmd) config() cmdconf.Config {
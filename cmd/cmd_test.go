@@ -96,6 +96,70 @@ var _ = Describe("Cmd", func() {
 			})
 		})
 
+		It("writes the event log when specified", func() {
+			cmd.BoshOpts = BoshOpts{EventLogOpt: "/events.ndjson"}
+			cmd.Opts = &MessageOpts{Message: "output"}
+
+			err := cmd.Execute()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.FileExists("/events.ndjson")).To(BeTrue())
+
+			// fake files only keep the last write
+			contents, err := fs.ReadFileString("/events.ndjson")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(contents).To(ContainSubstring(`"type":"run_finished"`))
+		})
+
+		It("returns error if the event log cannot be opened", func() {
+			cmd.BoshOpts = BoshOpts{EventLogOpt: "/events.ndjson"}
+			cmd.Opts = &MessageOpts{Message: "output"}
+			fs.OpenFileErr = errors.New("fake-open-err")
+
+			err := cmd.Execute()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-open-err"))
+		})
+
+		It("creates the CPI trace directory when specified", func() {
+			cmd.BoshOpts = BoshOpts{CPITraceOpt: "/cpi-trace"}
+			cmd.Opts = &MessageOpts{Message: "output"}
+
+			err := cmd.Execute()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.FileExists("/cpi-trace")).To(BeTrue())
+		})
+
+		It("returns error if the CPI trace directory cannot be created", func() {
+			cmd.BoshOpts = BoshOpts{CPITraceOpt: "/cpi-trace"}
+			cmd.Opts = &MessageOpts{Message: "output"}
+			fs.RegisterMkdirAllError("/cpi-trace", errors.New("fake-mkdir-err"))
+
+			err := cmd.Execute()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-mkdir-err"))
+		})
+
+		It("returns error if there are no CPI traces to replay", func() {
+			cmd.BoshOpts = BoshOpts{CPIReplayOpt: "/cpi-trace"}
+			cmd.Opts = &MessageOpts{Message: "output"}
+
+			err := cmd.Execute()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("No CPI traces found in '/cpi-trace'"))
+		})
+
+		It("keeps temporary files in the tmp dir when specified", func() {
+			cmd.BoshOpts = BoshOpts{TmpDirOpt: "/fake-tmp"}
+			cmd.Opts = &MessageOpts{Message: "output"}
+
+			err := cmd.Execute()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.TempRootPath).To(Equal("/fake-tmp"))
+		})
+
 		It("returns error if changing tmp root fails", func() {
 			fs.ChangeTempRootErr = errors.New("fake-err")
 
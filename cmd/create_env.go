@@ -1,8 +1,12 @@
 package cmd
 
 import (
+	"time"
+
 	"github.com/cppforlife/go-patch/patch"
 
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
 	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
 	boshui "github.com/cloudfoundry/bosh-cli/ui"
 )
@@ -22,5 +26,27 @@ func (c *CreateEnvCmd) Run(stage boshui.Stage, opts CreateEnvOpts) error {
 	depPreparer := c.envProvider(
 		opts.Args.Manifest.Path, opts.StatePath, opts.VarFlags.AsVariables(), opts.OpsFlags.AsOp())
 
-	return depPreparer.PrepareDeployment(stage)
+	if opts.DryRun {
+		return depPreparer.PrepareDryRun(stage)
+	}
+
+	if opts.RollbackOnFailure {
+		depPreparer = depPreparer.WithRollbackOnFailure()
+	}
+
+	if opts.Timeout <= 0 {
+		return depPreparer.PrepareDeployment(stage)
+	}
+
+	timedOut := make(chan struct{})
+	timer := time.AfterFunc(opts.Timeout, func() { close(timedOut) })
+
+	err := depPreparer.PrepareDeployment(boshui.NewInterruptibleStage(stage, timedOut))
+
+	// the timer has fired when it cannot be stopped anymore
+	if !timer.Stop() && walkErrors(err, func(err error) bool { return err == boshui.ErrInterrupted }) {
+		return bosherr.Errorf("Deploy did not finish within %s: %s", opts.Timeout, err.Error())
+	}
+
+	return err
 }
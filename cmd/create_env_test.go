@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"path/filepath"
 	"regexp"
+	"strings"
+	"time"
 
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -35,8 +37,10 @@ import (
 	fakebideplmanifest "github.com/cloudfoundry/bosh-cli/deployment/manifest/manifestfakes"
 	fakebideplval "github.com/cloudfoundry/bosh-cli/deployment/manifest/manifestfakes"
 	mock_deployment "github.com/cloudfoundry/bosh-cli/deployment/mocks"
+	mock_deployment_release "github.com/cloudfoundry/bosh-cli/deployment/release/mocks"
 	bidepltpl "github.com/cloudfoundry/bosh-cli/deployment/template"
 	fakebidepltpl "github.com/cloudfoundry/bosh-cli/deployment/template/templatefakes"
+	bivm "github.com/cloudfoundry/bosh-cli/deployment/vm"
 	fakebivm "github.com/cloudfoundry/bosh-cli/deployment/vm/fakes"
 	mock_vm "github.com/cloudfoundry/bosh-cli/deployment/vm/mocks"
 	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
@@ -45,6 +49,7 @@ import (
 	fakebiinstallmanifest "github.com/cloudfoundry/bosh-cli/installation/manifest/fakes"
 	mock_install "github.com/cloudfoundry/bosh-cli/installation/mocks"
 	bitarball "github.com/cloudfoundry/bosh-cli/installation/tarball"
+	biregistry "github.com/cloudfoundry/bosh-cli/registry"
 	mock_registry "github.com/cloudfoundry/bosh-cli/registry/mocks"
 	boshrel "github.com/cloudfoundry/bosh-cli/release"
 	bireljob "github.com/cloudfoundry/bosh-cli/release/job"
@@ -57,6 +62,8 @@ import (
 	bistemcell "github.com/cloudfoundry/bosh-cli/stemcell"
 	mock_stemcell "github.com/cloudfoundry/bosh-cli/stemcell/mocks"
 	fakebistemcell "github.com/cloudfoundry/bosh-cli/stemcell/stemcellfakes"
+	bitemplate "github.com/cloudfoundry/bosh-cli/templatescompiler"
+	mock_template "github.com/cloudfoundry/bosh-cli/templatescompiler/mocks"
 	biui "github.com/cloudfoundry/bosh-cli/ui"
 	fakebiui "github.com/cloudfoundry/bosh-cli/ui/fakes"
 )
@@ -74,13 +81,15 @@ var _ = Describe("CreateEnvCmd", func() {
 
 	Describe("Run", func() {
 		var (
-			command          *bicmd.CreateEnvCmd
-			fs               *fakesys.FakeFileSystem
-			stdOut           *gbytes.Buffer
-			stdErr           *gbytes.Buffer
-			userInterface    biui.UI
-			digestCalculator crypto.DigestCalculator
-			manifestSHA      string
+			command            *bicmd.CreateEnvCmd
+			diffEnvCommand     *bicmd.DiffEnvCmd
+			validateEnvCommand *bicmd.ValidateEnvCmd
+			fs                 *fakesys.FakeFileSystem
+			stdOut             *gbytes.Buffer
+			stdErr             *gbytes.Buffer
+			userInterface      biui.UI
+			digestCalculator   crypto.DigestCalculator
+			manifestSHA        string
 
 			mockDeployer              *mock_deployment.MockDeployer
 			mockInstaller             *mock_install.MockInstaller
@@ -112,6 +121,11 @@ var _ = Describe("CreateEnvCmd", func() {
 			mockLegacyDeploymentStateMigrator *mock_config.MockLegacyDeploymentStateMigrator
 			setupDeploymentStateService       biconfig.DeploymentStateService
 			fakeDeploymentValidator           *fakebideplval.FakeValidator
+			mockJobResolver                   *mock_deployment_release.MockJobResolver
+			mockJobListRenderer               *mock_template.MockJobListRenderer
+			strictProperties                  bool
+			skipDiskMigration                 bool
+			freeSpace                         uint64
 
 			directorID          = "generated-director-uuid"
 			fakeUUIDGenerator   *fakeuuid.FakeGenerator
@@ -134,6 +148,7 @@ var _ = Describe("CreateEnvCmd", func() {
 			boshDeploymentManifest bideplmanifest.Manifest
 			installationManifest   biinstallmanifest.Manifest
 			cloud                  bicloud.Cloud
+			fakeCPICmdRunner       *fakebicloud.FakeCPICmdRunner
 
 			cloudStemcell bistemcell.CloudStemcell
 
@@ -201,6 +216,11 @@ var _ = Describe("CreateEnvCmd", func() {
 			setupDeploymentStateService = biconfig.NewFileSystemDeploymentStateService(fs, configUUIDGenerator, logger, biconfig.DeploymentStatePath(deploymentManifestPath, ""))
 
 			fakeDeploymentValidator = fakebideplval.NewFakeValidator()
+			mockJobResolver = mock_deployment_release.NewMockJobResolver(mockCtrl)
+			mockJobListRenderer = mock_template.NewMockJobListRenderer(mockCtrl)
+			strictProperties = false
+			skipDiskMigration = false
+			freeSpace = 1024 * 1024 * 1024
 
 			fakeStage = fakebiui.NewFakeStage()
 
@@ -304,7 +324,8 @@ var _ = Describe("CreateEnvCmd", func() {
 				return cpiRelease, nil
 			}
 
-			cloud = bicloud.NewCloud(fakebicloud.NewFakeCPICmdRunner(), "fake-director-id", logger)
+			fakeCPICmdRunner = fakebicloud.NewFakeCPICmdRunner()
+			cloud = bicloud.NewCloud(fakeCPICmdRunner, "fake-director-id", logger)
 			cloudStemcell = fakebistemcell.NewFakeCloudStemcell(
 				"fake-stemcell-cid", "fake-stemcell-name", "fake-stemcell-version")
 
@@ -322,10 +343,12 @@ var _ = Describe("CreateEnvCmd", func() {
 				releaseRepo := biconfig.NewReleaseRepo(deploymentStateService, fakeUUIDGenerator)
 				stemcellRepo := biconfig.NewStemcellRepo(deploymentStateService, fakeUUIDGenerator)
 				deploymentRecord := deployment.NewRecord(deploymentRepo, releaseRepo, stemcellRepo)
+				checkpointRepo := biconfig.NewCheckpointRepo(deploymentStateService)
 
 				fakeHTTPClient := fakebihttpclient.NewFakeHTTPClient()
 				tarballCache := bitarball.NewCache("fake-base-path", fs, logger)
-				tarballProvider := bitarball.NewProvider(tarballCache, fs, fakeHTTPClient, 1, 0, logger)
+				signatureVerifier := bitarball.NewGPGSignatureVerifier(fakesys.NewFakeCmdRunner(), fs, logger)
+				tarballProvider := bitarball.NewProvider(tarballCache, fs, fakeHTTPClient, signatureVerifier, 1, 0, logger)
 
 				cpiInstaller := bicpirel.CpiInstaller{
 					ReleaseManager:   releaseManager,
@@ -357,19 +380,22 @@ var _ = Describe("CreateEnvCmd", func() {
 					filepath.Join("fake-install-dir"),
 				)
 				tempRootConfigurator := bicmd.NewTempRootConfigurator(fs)
+				scratchSpaceChecker := biinstall.NewScratchSpaceChecker(fs, func(string) (uint64, error) { return freeSpace, nil }, logger)
 
 				return bicmd.NewDeploymentPreparer(
 					userInterface,
 					logger,
 					"deployCmd",
+					fs,
 					deploymentStateService,
 					mockLegacyDeploymentStateMigrator,
 					releaseManager,
 					deploymentRecord,
+					checkpointRepo,
 					mockCloudFactory,
 					fakeStemcellManagerFactory,
 					mockAgentClientFactory,
-					mockVMManagerFactory,
+					func(int) bivm.ManagerFactory { return mockVMManagerFactory },
 					mockBlobstoreFactory,
 					mockDeployer,
 					deploymentManifestPath,
@@ -381,11 +407,18 @@ var _ = Describe("CreateEnvCmd", func() {
 					releaseSetAndInstallationManifestParser,
 					deploymentManifestParser,
 					tempRootConfigurator,
+					scratchSpaceChecker,
 					targetProvider,
+					mockJobResolver,
+					mockJobListRenderer,
+					strictProperties,
+					skipDiskMigration,
 				)
 			}
 
 			command = bicmd.NewCreateEnvCmd(userInterface, doGet)
+			diffEnvCommand = bicmd.NewDiffEnvCmd(userInterface, doGet)
+			validateEnvCommand = bicmd.NewValidateEnvCmd(userInterface, doGet)
 
 			expectLegacyMigrate = mockLegacyDeploymentStateMigrator.EXPECT().MigrateIfExists(filepath.Join("/", "path", "to", "bosh-deployments.yml")).AnyTimes()
 
@@ -418,7 +451,7 @@ var _ = Describe("CreateEnvCmd", func() {
 			installation := biinstall.NewInstallation(target, installedJob, installationManifest, mockRegistryServerManager)
 
 			expectInstall = mockInstaller.EXPECT().Install(installationManifest, gomock.Any()).Do(func(_ interface{}, stage biui.Stage) {
-				Expect(fakeStage.SubStages).To(ContainElement(stage))
+				Expect(fakeStage.SubStages).To(ContainElement(biui.NewUninterruptibleStage(stage)))
 			}).Return(installation, nil).AnyTimes()
 			mockInstaller.EXPECT().Cleanup(installation).AnyTimes()
 
@@ -429,14 +462,18 @@ var _ = Describe("CreateEnvCmd", func() {
 				boshDeploymentManifest,
 				cloudStemcell,
 				installationManifest.Registry,
-				fakeVMManager,
-				mockBlobstore,
 				gomock.Any(),
-			).Do(func(_, _, _, _, _, _ interface{}, stage biui.Stage) {
+				gomock.Any(),
+			).Do(func(_, _, _, _ interface{}, instanceClients deployment.InstanceClients, stage biui.Stage) {
 				Expect(fakeStage.SubStages).To(ContainElement(stage))
+
+				vmManager, blobstore, err := instanceClients(0, "")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(vmManager).To(Equal(fakeVMManager))
+				Expect(blobstore).To(Equal(mockBlobstore))
 			}).Return(mockDeployment, nil).AnyTimes()
 
-			expectNewCloud = mockCloudFactory.EXPECT().NewCloud(installation, directorID).Return(cloud, nil).AnyTimes()
+			expectNewCloud = mockCloudFactory.EXPECT().NewCloud(installation, directorID, 0).Return(cloud, nil).AnyTimes()
 		})
 
 		Describe("prints the deployment manifest and state file", func() {
@@ -470,6 +507,238 @@ var _ = Describe("CreateEnvCmd", func() {
 			})
 		})
 
+		Context("when --dry-run is specified", func() {
+			var dryRunOpts bicmd.CreateEnvOpts
+
+			BeforeEach(func() {
+				dryRunOpts = defaultCreateEnvOpts
+				dryRunOpts.DryRun = true
+			})
+
+			It("renders job templates and prints planned CPI calls without deploying", func() {
+				boshDeploymentManifest.Jobs[0].Instances = 1
+				fakeDeploymentParser.ParseReturns(boshDeploymentManifest, nil)
+
+				renderedJobList := mock_template.NewMockRenderedJobList(mockCtrl)
+				renderedJobList.EXPECT().DeleteSilently()
+				mockJobListRenderer.EXPECT().Render(
+					[]bireljob.Job{},
+					map[string]*biproperty.Map{},
+					map[string]biproperty.Map{},
+					boshDeploymentManifest.Jobs[0].Properties,
+					boshDeploymentManifest.Properties,
+					"fake-deployment-name",
+					bitemplate.InstanceSpec{Name: "fake-job-name", Networks: map[string]bitemplate.NetworkSpec{}},
+					"",
+				).Return(renderedJobList, nil)
+
+				expectInstall.Times(0)
+				expectDeploy.Times(0)
+				expectLegacyMigrate.Times(0)
+
+				err := command.Run(fakeStage, dryRunOpts)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(stdOut).To(gbytes.Say("Planned CPI calls:"))
+				Expect(stdOut).To(gbytes.Say("create_stemcell: Stemcell 'fake-stemcell-name/fake-stemcell-version' has not been uploaded"))
+				Expect(stdOut).To(gbytes.Say("create_vm: Job 'fake-job-name' instance 0 with resource pool"))
+				Expect(fs.FileExists(deploymentStatePath)).To(BeFalse())
+			})
+
+			It("returns an error when rendering fails", func() {
+				mockJobListRenderer.EXPECT().Render(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, bosherr.Error("fake-render-error"))
+
+				err := command.Run(fakeStage, dryRunOpts)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-render-error"))
+			})
+		})
+
+		It("records the deployed manifest", func() {
+			err := command.Run(fakeStage, defaultCreateEnvOpts)
+			Expect(err).NotTo(HaveOccurred())
+
+			deploymentState, err := setupDeploymentStateService.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentState.CurrentManifest).To(Equal("test: true\n"))
+		})
+
+		It("records the deployed manifest without the values of its variables", func() {
+			template := bidepltpl.NewDeploymentTemplate([]byte("password: ((password))"))
+			fakeDeploymentTemplateFactory.NewDeploymentTemplateFromPathReturns(template, nil)
+
+			opts := defaultCreateEnvOpts
+			opts.VarFlags = bicmd.VarFlags{
+				VarKVs: []boshtpl.VarKV{{Name: "password", Value: "fake-password"}},
+			}
+
+			err := command.Run(fakeStage, opts)
+			Expect(err).NotTo(HaveOccurred())
+
+			actualInterpolatedTemplate, _ := fakeDeploymentParser.ParseArgsForCall(0)
+			Expect(string(actualInterpolatedTemplate.Content())).To(Equal("password: fake-password\n"))
+
+			deploymentState, err := setupDeploymentStateService.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentState.CurrentManifest).To(Equal("password: ((password))\n"))
+		})
+
+		Context("when a manifest has been deployed before", func() {
+			var fakeUI *fakebiui.FakeUI
+
+			BeforeEach(func() {
+				fakeUI = &fakebiui.FakeUI{}
+				userInterface = fakeUI
+
+				err := setupDeploymentStateService.Save(biconfig.DeploymentState{
+					DirectorID:      directorID,
+					CurrentManifest: "deployed: true\n",
+				})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			JustBeforeEach(func() {
+				deployedManifest := boshDeploymentManifest
+				deployedManifest.Properties = biproperty.Map{"password": "fake-old-password"}
+				deployedManifest.DiskPools = []bideplmanifest.DiskPool{{Name: "fake-disk-pool-name"}}
+
+				fakeDeploymentParser.ParseStub = func(interpolatedTemplate bidepltpl.InterpolatedTemplate, _ string) (bideplmanifest.Manifest, error) {
+					if string(interpolatedTemplate.Content()) == "deployed: true\n" {
+						return deployedManifest, nil
+					}
+					return boshDeploymentManifest, nil
+				}
+			})
+
+			It("prints the redacted manifest changes and asks for confirmation before deploying", func() {
+				err := command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeUI.Said).To(ContainElement("\nManifest changes:\n"))
+				Expect(fakeUI.Said).To(ContainElement("  - disk_pools/fake-disk-pool-name"))
+				Expect(fakeUI.Said).To(ContainElement("  - properties/password: <redacted>"))
+				Expect(fakeUI.AskedConfirmationCalled).To(BeTrue())
+			})
+
+			It("does not deploy when the changes are not confirmed", func() {
+				fakeUI.AskedConfirmationErr = errors.New("fake-confirmation-error")
+				expectDeploy.Times(0)
+
+				err := command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("fake-confirmation-error"))
+			})
+
+			It("does not ask for confirmation when the manifest has not changed", func() {
+				fakeDeploymentParser.ParseStub = nil
+
+				err := command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeUI.AskedConfirmationCalled).To(BeFalse())
+			})
+
+			It("prints the manifest changes with diff-env without deploying", func() {
+				expectInstall.Times(0)
+				expectDeploy.Times(0)
+
+				err := diffEnvCommand.Run(fakeStage, bicmd.DiffEnvOpts{
+					Args: bicmd.DiffEnvArgs{
+						Manifest: bicmd.FileBytesWithPathArg{Path: deploymentManifestPath},
+					},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeUI.Said).To(ContainElement("  - disk_pools/fake-disk-pool-name"))
+				Expect(fakeUI.AskedConfirmationCalled).To(BeFalse())
+			})
+		})
+
+		Describe("validate-env", func() {
+			var (
+				validateEnvOpts       bicmd.ValidateEnvOpts
+				deploymentState       string
+				tempInstallationPaths []string
+			)
+
+			BeforeEach(func() {
+				strictProperties = true
+
+				renderedJobList := mock_template.NewMockRenderedJobList(mockCtrl)
+				renderedJobList.EXPECT().DeleteSilently().AnyTimes()
+				mockJobListRenderer.EXPECT().Render(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(renderedJobList, nil).AnyTimes()
+
+				validateEnvOpts = bicmd.ValidateEnvOpts{
+					Args: bicmd.ValidateEnvArgs{
+						Manifest: bicmd.FileBytesWithPathArg{Path: deploymentManifestPath},
+					},
+				}
+
+				deploymentState = fmt.Sprintf(`{"schema_version":%d,"director_id":"generated-director-uuid","installation_id":"fake-installation-id"}`,
+					biconfig.DeploymentStateSchemaVersion)
+				err := fs.WriteFileString(deploymentStatePath, deploymentState)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = fs.ChangeTempRoot(filepath.Join("/", "fake-tmp"))
+				Expect(err).ToNot(HaveOccurred())
+
+				tempInstallationPaths = nil
+				mockInstallerFactory.EXPECT().NewInstaller(gomock.Any()).Do(func(target biinstall.Target) {
+					tempInstallationPaths = append(tempInstallationPaths, target.Path())
+				}).Return(mockInstaller).AnyTimes()
+			})
+
+			It("installs the CPI and calls its info method without deploying", func() {
+				expectInstall.Times(1)
+				expectNewCloud.Times(1)
+				expectStemcellUpload.Times(0)
+				expectDeploy.Times(0)
+
+				err := validateEnvCommand.Run(fakeStage, validateEnvOpts)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeStage.PerformCalls).To(ContainElement(&fakebiui.PerformCall{Name: "Checking CPI"}))
+				Expect(stdOut).To(gbytes.Say("Manifest, releases, stemcell and CPI are valid."))
+			})
+
+			It("does not change the deployment state", func() {
+				err := validateEnvCommand.Run(fakeStage, validateEnvOpts)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fs.ReadFileString(deploymentStatePath)).To(Equal(deploymentState))
+			})
+
+			It("installs the CPI into a temp installation instead of the one of the deployment", func() {
+				err := validateEnvCommand.Run(fakeStage, validateEnvOpts)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(tempInstallationPaths).To(HaveLen(1))
+				Expect(tempInstallationPaths[0]).ToNot(Equal(filepath.Join("fake-install-dir", "fake-installation-id")))
+				Expect(fs.FileExists(tempInstallationPaths[0])).To(BeFalse())
+			})
+
+			Context("when the manifest does not match the schema", func() {
+				It("returns an error", func() {
+					fakeDeploymentParser.ParseReturns(bideplmanifest.Manifest{}, errors.New("fake-schema-error"))
+					expectInstall.Times(0)
+
+					err := validateEnvCommand.Run(fakeStage, validateEnvOpts)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-schema-error"))
+				})
+			})
+
+			Context("when the CPI info call fails", func() {
+				It("returns an error", func() {
+					fakeCPICmdRunner.RunErr = errors.New("fake-run-error")
+
+					err := validateEnvCommand.Run(fakeStage, validateEnvOpts)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Calling CPI info"))
+					Expect(err.Error()).To(ContainSubstring("fake-run-error"))
+				})
+			})
+		})
+
 		It("does not migrate the legacy bosh-deployments.yml if manifest-state.json exists", func() {
 			err := fs.WriteFileString(deploymentStatePath, "{}")
 			Expect(err).ToNot(HaveOccurred())
@@ -552,15 +821,29 @@ var _ = Describe("CreateEnvCmd", func() {
 				Name: "validating",
 				Stage: &fakebiui.FakeStage{
 					PerformCalls: []*fakebiui.PerformCall{
+						{Name: "Validating deployment manifest"},
 						{Name: "Validating release 'fake-cpi-release-name'"},
 						{Name: "Validating cpi release"},
-						{Name: "Validating deployment manifest"},
 						{Name: "Validating stemcell"},
 					},
 				},
 			}))
 		})
 
+		It("logs the validating deployment stage after the CPI is installed", func() {
+			err := command.Run(fakeStage, defaultCreateEnvOpts)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeStage.PerformCalls[2]).To(Equal(&fakebiui.PerformCall{
+				Name: "validating deployment",
+				Stage: &fakebiui.FakeStage{
+					PerformCalls: []*fakebiui.PerformCall{
+						{Name: "Validating deployment jobs"},
+					},
+				},
+			}))
+		})
+
 		It("installs the CPI locally", func() {
 			expectInstall.Times(1)
 			expectNewCloud.Times(1)
@@ -583,7 +866,7 @@ var _ = Describe("CreateEnvCmd", func() {
 			err := command.Run(fakeStage, defaultCreateEnvOpts)
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(fakeStage.PerformCalls[2]).To(Equal(&fakebiui.PerformCall{
+			Expect(fakeStage.PerformCalls[3]).To(Equal(&fakebiui.PerformCall{
 				Name: "Starting registry",
 			}))
 		})
@@ -599,7 +882,7 @@ var _ = Describe("CreateEnvCmd", func() {
 			})
 
 			It("starts & stops the registry", func() {
-				mockRegistryServerManager.EXPECT().Start("fake-username", "fake-password", "fake-host", 123).Return(mockRegistryServer, nil)
+				mockRegistryServerManager.EXPECT().Start("fake-username", "fake-password", "fake-host", 123, biregistry.TLSConfig{}).Return(mockRegistryServer, nil)
 				mockRegistryServer.EXPECT().Stop()
 
 				err := command.Run(fakeStage, defaultCreateEnvOpts)
@@ -607,6 +890,169 @@ var _ = Describe("CreateEnvCmd", func() {
 			})
 		})
 
+		Context("when the CPI passes agent settings to the agent", func() {
+			BeforeEach(func() {
+				installationManifest.Registry = biinstallmanifest.Registry{
+					Username: "fake-username",
+					Password: "fake-password",
+					Host:     "fake-host",
+					Port:     123,
+				}
+				cloud = bicloud.NewCloudWithAPIVersion(fakebicloud.NewFakeCPICmdRunner(), "fake-director-id", 2, 2, logger)
+			})
+
+			It("deploys without starting the registry", func() {
+				mockDeployer.EXPECT().Deploy(
+					cloud,
+					boshDeploymentManifest,
+					cloudStemcell,
+					biinstallmanifest.Registry{},
+					gomock.Any(),
+					gomock.Any(),
+				).Return(nil, nil)
+
+				err := command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).NotTo(HaveOccurred())
+
+				for _, performCall := range fakeStage.PerformCalls {
+					Expect(performCall.Name).ToNot(Equal("Starting registry"))
+				}
+			})
+		})
+
+		Context("when a blobstore is configured", func() {
+			BeforeEach(func() {
+				installationManifest.Blobstore = biinstallmanifest.Blobstore{
+					Provider: "s3",
+					Options:  biproperty.Map{"bucket_name": "fake-bucket"},
+				}
+			})
+
+			It("deploys using the configured blobstore", func() {
+				mockBlobstoreFactory.EXPECT().CreateFromProvider("s3", biproperty.Map{"bucket_name": "fake-bucket"}, gomock.Any()).Return(mockBlobstore, nil)
+				expectDeploy.Times(1)
+
+				err := command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("returns an error when the blobstore cannot be created", func() {
+				mockBlobstoreFactory.EXPECT().CreateFromProvider("s3", gomock.Any(), gomock.Any()).Return(nil, errors.New("fake-blobstore-error"))
+				expectDeploy.Times(0)
+
+				err := command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-blobstore-error"))
+			})
+		})
+
+		Context("when the resource pool uses a CPI from the cpis section", func() {
+			var cpiManifest biinstallmanifest.Manifest
+
+			BeforeEach(func() {
+				installationManifest.CPIs = []biinstallmanifest.CPI{
+					{
+						Name: "fake-other-cpi",
+						Template: biinstallmanifest.ReleaseJobRef{
+							Name:    "fake-cpi-release-job-name",
+							Release: "fake-cpi-release-name",
+						},
+						Properties: biproperty.Map{"fake-other-cpi-property": "fake-value"},
+					},
+				}
+				boshDeploymentManifest.ResourcePools[0].CPI = "fake-other-cpi"
+
+				var err error
+				cpiManifest, err = installationManifest.ForCPI("fake-other-cpi")
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("installs the CPI and records it in the deployment state", func() {
+				target := biinstall.NewTarget(filepath.Join("fake-install-dir", "fake-installation-id"))
+				installedJob := biinstall.NewInstalledJob(
+					biinstall.RenderedJobRef{Name: "fake-cpi-release-job-name"},
+					filepath.Join(target.JobsPath(), "fake-cpi-release-job-name"),
+				)
+				cpiInstallation := biinstall.NewInstallation(target, installedJob, cpiManifest, mockRegistryServerManager)
+
+				mockInstaller.EXPECT().Install(cpiManifest, gomock.Any()).Return(cpiInstallation, nil)
+				mockInstaller.EXPECT().Cleanup(cpiInstallation)
+				mockCloudFactory.EXPECT().NewCloud(cpiInstallation, directorID, 0).Return(cloud, nil)
+				expectDeploy.Times(1)
+
+				err := command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).NotTo(HaveOccurred())
+
+				deploymentState, err := setupDeploymentStateService.Load()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentState.CurrentCPI).To(Equal("fake-other-cpi"))
+			})
+
+			It("returns an error without deploying when another CPI created the VM", func() {
+				err := setupDeploymentStateService.Save(biconfig.DeploymentState{
+					DirectorID:   directorID,
+					CurrentVMCID: "fake-vm-cid",
+				})
+				Expect(err).ToNot(HaveOccurred())
+				expectDeploy.Times(0)
+
+				err = command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Changing the CPI of the deployment from the cloud_provider CPI to CPI 'fake-other-cpi' requires deleting it first"))
+			})
+
+			It("returns an error from a dry run when another CPI created the VM", func() {
+				err := setupDeploymentStateService.Save(biconfig.DeploymentState{
+					DirectorID:   directorID,
+					CurrentVMCID: "fake-vm-cid",
+				})
+				Expect(err).ToNot(HaveOccurred())
+				expectDeploy.Times(0)
+
+				opts := defaultCreateEnvOpts
+				opts.DryRun = true
+
+				err = command.Run(fakeStage, opts)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("requires deleting it first"))
+			})
+
+			It("returns an error without deploying when another CPI created a disk", func() {
+				err := setupDeploymentStateService.Save(biconfig.DeploymentState{
+					DirectorID: directorID,
+					Disks:      []biconfig.DiskRecord{{ID: "fake-disk-id", CID: "fake-disk-cid"}},
+				})
+				Expect(err).ToNot(HaveOccurred())
+				expectDeploy.Times(0)
+
+				err = command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("requires deleting it first"))
+			})
+
+			It("returns an error without deploying when another CPI uploaded a stemcell", func() {
+				err := setupDeploymentStateService.Save(biconfig.DeploymentState{
+					DirectorID: directorID,
+					Stemcells:  []biconfig.StemcellRecord{{ID: "fake-stemcell-id", CID: "fake-stemcell-cid"}},
+				})
+				Expect(err).ToNot(HaveOccurred())
+				expectDeploy.Times(0)
+
+				err = command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("requires deleting it first"))
+			})
+
+			It("returns an error when the CPI is not in the cpis section", func() {
+				boshDeploymentManifest.ResourcePools[0].CPI = "fake-unknown-cpi"
+				fakeDeploymentParser.ParseReturns(boshDeploymentManifest, nil)
+
+				err := command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("CPI 'fake-unknown-cpi' not found in cpis"))
+			})
+		})
+
 		It("deletes the extracted CPI release", func() {
 			err := command.Run(fakeStage, defaultCreateEnvOpts)
 			Expect(err).NotTo(HaveOccurred())
@@ -632,7 +1078,7 @@ var _ = Describe("CreateEnvCmd", func() {
 			err := command.Run(fakeStage, defaultCreateEnvOpts)
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(fakeStage.PerformCalls[3]).To(Equal(&fakebiui.PerformCall{
+			Expect(fakeStage.PerformCalls[4]).To(Equal(&fakebiui.PerformCall{
 				Name:  "deploying",
 				Stage: &fakebiui.FakeStage{}, // mock deployer doesn't add sub-stages
 			}))
@@ -662,6 +1108,116 @@ var _ = Describe("CreateEnvCmd", func() {
 			}))
 		})
 
+		It("clears the deploy checkpoint", func() {
+			err := command.Run(fakeStage, defaultCreateEnvOpts)
+			Expect(err).NotTo(HaveOccurred())
+
+			deploymentState, err := setupDeploymentStateService.Load()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(deploymentState.Checkpoint).To(BeNil())
+		})
+
+		It("releases the lock on the deployment state", func() {
+			err := command.Run(fakeStage, defaultCreateEnvOpts)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fs.FileExists(deploymentStatePath + ".lock")).To(BeFalse())
+		})
+
+		Context("when another operation holds the lock on the deployment state", func() {
+			BeforeEach(func() {
+				err := setupDeploymentStateService.Lock()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns an error without deploying", func() {
+				expectDeploy.Times(0)
+
+				err := command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Locking deployment state: Another operation is in progress on deployment state"))
+			})
+		})
+
+		Context("when the temp directory does not have enough free space", func() {
+			BeforeEach(func() {
+				freeSpace = 5
+
+				err := fs.WriteFileString(stemcellTarballPath, "fake-stemcell-tarball")
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns an error before extracting anything", func() {
+				expectDeploy.Times(0)
+
+				err := command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Not enough free space in temp directory"))
+				Expect(err.Error()).To(ContainSubstring("about 21 B needed, 5 B available"))
+
+				Expect(releaseReader.ReadCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when job properties are validated strictly", func() {
+			BeforeEach(func() {
+				strictProperties = true
+
+				releaseJob := bireljob.NewJob(NewResource("fake-release-job-name", "job-fp", nil))
+				releaseJob.Properties = map[string]bireljob.PropertyDefinition{
+					"port": {Type: "integer"},
+				}
+
+				boshDeploymentManifest.Jobs[0].Templates = []bideplmanifest.ReleaseJobRef{
+					{Name: "fake-release-job-name", Release: "fake-cpi-release-name"},
+				}
+				fakeDeploymentParser.ParseReturns(boshDeploymentManifest, nil)
+
+				mockJobResolver.EXPECT().Resolve("fake-release-job-name", "fake-cpi-release-name").Return(*releaseJob, nil).AnyTimes()
+			})
+
+			It("deploys when the job properties match the job specs", func() {
+				boshDeploymentManifest.Jobs[0].Properties = biproperty.Map{"port": 8080}
+				fakeDeploymentParser.ParseReturns(boshDeploymentManifest, nil)
+
+				renderedJobList := mock_template.NewMockRenderedJobList(mockCtrl)
+				renderedJobList.EXPECT().DeleteSilently()
+				mockJobListRenderer.EXPECT().Render(gomock.Any(), gomock.Any(), gomock.Any(), boshDeploymentManifest.Jobs[0].Properties, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(renderedJobList, nil)
+
+				expectDeploy.Times(1)
+
+				err := command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("returns an error without deploying when the templates look up undeclared properties", func() {
+				boshDeploymentManifest.Jobs[0].Properties = biproperty.Map{"port": 8080}
+				fakeDeploymentParser.ParseReturns(boshDeploymentManifest, nil)
+
+				mockJobListRenderer.EXPECT().Render(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, bosherr.Error("fake-unknown-property-error"))
+
+				expectDeploy.Times(0)
+
+				err := command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-unknown-property-error"))
+			})
+
+			It("returns an error listing invalid job properties without deploying", func() {
+				boshDeploymentManifest.Jobs[0].Properties = biproperty.Map{"port": "8080", "other": "fake-value"}
+				fakeDeploymentParser.ParseReturns(boshDeploymentManifest, nil)
+
+				expectDeploy.Times(0)
+
+				err := command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Validating job properties"))
+				Expect(err.Error()).To(ContainSubstring("Job 'fake-release-job-name' property 'port' must be of type 'integer'"))
+				Expect(err.Error()).To(ContainSubstring("Instance group property 'other' is not declared by any job spec"))
+			})
+		})
+
 		It("deletes unused stemcells", func() {
 			expectStemcellDeleteUnused.Times(1)
 
@@ -782,6 +1338,29 @@ var _ = Describe("CreateEnvCmd", func() {
 				Expect(err).NotTo(HaveOccurred())
 			})
 
+			It("extracts the other releases while the stemcell is uploaded", func() {
+				uploading := make(chan struct{})
+				extracting := make(chan struct{})
+
+				expectStemcellUpload.Do(func(_ bistemcell.ExtractedStemcell, _ biui.Stage) {
+					close(uploading)
+					Eventually(extracting).Should(BeClosed())
+				})
+
+				releaseReader.ReadStub = func(path string) (boshrel.Release, error) {
+					if path == cpiReleaseTarballPath {
+						return cpiRelease, nil
+					}
+
+					close(extracting)
+					Eventually(uploading).Should(BeClosed())
+					return otherRelease, nil
+				}
+
+				err := command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
 			It("updates the deployment record", func() {
 				err := command.Run(fakeStage, defaultCreateEnvOpts)
 				Expect(err).NotTo(HaveOccurred())
@@ -902,7 +1481,11 @@ var _ = Describe("CreateEnvCmd", func() {
 			BeforeEach(func() {
 				releaseSetManifest.Releases = []birelmanifest.ReleaseRef{
 					{
-						Name: "fake-other-cpi-release-name",
+						Name: "fake-cpi-release-name",
+						URL:  "file://" + cpiReleaseTarballPath,
+					},
+					{
+						Name: "fake-other-release-name",
 						URL:  "file://" + cpiReleaseTarballPath,
 					},
 				}
@@ -911,7 +1494,7 @@ var _ = Describe("CreateEnvCmd", func() {
 			It("returns an error", func() {
 				err := command.Run(fakeStage, defaultCreateEnvOpts)
 				Expect(err).To(HaveOccurred())
-				Expect(err.Error()).To(ContainSubstring("Release name 'fake-other-cpi-release-name' does not match the name in release tarball 'fake-cpi-release-name'"))
+				Expect(err.Error()).To(ContainSubstring("Release name 'fake-other-release-name' does not match the name in release tarball 'fake-cpi-release-name'"))
 			})
 		})
 
@@ -944,7 +1527,7 @@ var _ = Describe("CreateEnvCmd", func() {
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("not there"))
 
-				performCall := fakeStage.PerformCalls[0].Stage.PerformCalls[0]
+				performCall := fakeStage.PerformCalls[0].Stage.PerformCalls[1]
 				Expect(performCall.Name).To(Equal("Validating release 'fake-cpi-release-name'"))
 				Expect(performCall.Error.Error()).To(ContainSubstring("not there"))
 			})
@@ -983,7 +1566,7 @@ var _ = Describe("CreateEnvCmd", func() {
 				err := command.Run(fakeStage, defaultCreateEnvOpts)
 				Expect(err).To(HaveOccurred())
 
-				performCall := fakeStage.PerformCalls[0].Stage.PerformCalls[2]
+				performCall := fakeStage.PerformCalls[0].Stage.PerformCalls[0]
 				Expect(performCall.Name).To(Equal("Validating deployment manifest"))
 				Expect(performCall.Error.Error()).To(Equal("Validating deployment manifest: fake-deployment-validation-error"))
 			})
@@ -1006,10 +1589,40 @@ var _ = Describe("CreateEnvCmd", func() {
 				err := command.Run(fakeStage, defaultCreateEnvOpts)
 				Expect(err).To(HaveOccurred())
 
-				performCall := fakeStage.PerformCalls[0].Stage.PerformCalls[2]
-				Expect(performCall.Name).To(Equal("Validating deployment manifest"))
+				performCall := fakeStage.PerformCalls[2].Stage.PerformCalls[0]
+				Expect(performCall.Name).To(Equal("Validating deployment jobs"))
 				Expect(performCall.Error.Error()).To(Equal("Validating deployment jobs refer to jobs in release: fake-jobs-validation-error"))
 			})
+
+			It("deletes the stemcell uploaded meanwhile", func() {
+				expectStemcellUpload.Times(1)
+				expectDeploy.Times(0)
+
+				err := command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).To(HaveOccurred())
+
+				Expect(cloudStemcell.(*fakebistemcell.FakeCloudStemcell).DeleteCalledTimes).To(Equal(1))
+				Expect(fakeStage.PerformCalls[3].Name).To(Equal("Deleting uploaded stemcell 'fake-stemcell-cid'"))
+			})
+
+			It("keeps a stemcell uploaded by an earlier deploy", func() {
+				err := setupDeploymentStateService.Save(biconfig.DeploymentState{
+					DirectorID:        directorID,
+					CurrentStemcellID: "fake-stemcell-record-id",
+					Stemcells: []biconfig.StemcellRecord{{
+						ID:      "fake-stemcell-record-id",
+						Name:    cloudStemcell.Name(),
+						Version: cloudStemcell.Version(),
+						CID:     cloudStemcell.CID(),
+					}},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				err = command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).To(HaveOccurred())
+
+				Expect(cloudStemcell.(*fakebistemcell.FakeCloudStemcell).DeleteCalledTimes).To(Equal(0))
+			})
 		})
 
 		Context("when uploading stemcell fails", func() {
@@ -1031,8 +1644,7 @@ var _ = Describe("CreateEnvCmd", func() {
 					boshDeploymentManifest,
 					cloudStemcell,
 					installationManifest.Registry,
-					fakeVMManager,
-					mockBlobstore,
+					gomock.Any(),
 					gomock.Any(),
 				).Return(nil, errors.New("fake-deploy-error")).AnyTimes()
 
@@ -1061,6 +1673,282 @@ var _ = Describe("CreateEnvCmd", func() {
 				Expect(deploymentState.Releases).To(Equal([]biconfig.ReleaseRecord{}))
 				Expect(deploymentState.CurrentReleaseIDs).To(Equal([]string{}))
 			})
+
+			It("keeps the deploy checkpoint so that deploying again resumes", func() {
+				err := command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).To(HaveOccurred())
+
+				deploymentState, err := setupDeploymentStateService.Load()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(deploymentState.Checkpoint).To(Equal(&biconfig.CheckpointRecord{
+					ManifestSHA: manifestSHA,
+					StemcellCID: cloudStemcell.CID(),
+					Stages:      []string{deployment.CheckpointStemcellUploaded},
+				}))
+			})
+
+			It("does not upload the stemcell again when deploying again", func() {
+				err := command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).To(HaveOccurred())
+
+				fakeDeploymentValidator.SetValidateBehavior([]fakebideplval.ValidateOutput{{Err: nil}})
+				fakeDeploymentValidator.SetValidateReleaseJobsBehavior([]fakebideplval.ValidateReleaseJobsOutput{{Err: nil}})
+
+				expectStemcellUpload.Times(1)
+				mockStemcellManager.EXPECT().FindCurrent().Return([]bistemcell.CloudStemcell{}, nil)
+				mockStemcellManager.EXPECT().FindUnused().Return([]bistemcell.CloudStemcell{cloudStemcell}, nil)
+
+				err = command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-deploy-error"))
+
+				manifest := extractedStemcell.Manifest()
+				Expect(fakeStage.PerformCalls).To(ContainElement(&fakebiui.PerformCall{
+					Name:      fmt.Sprintf("Uploading stemcell '%s/%s'", manifest.Name, manifest.Version),
+					Error:     biui.NewSkipStageError(bosherr.Errorf("Found stemcell of interrupted deploy: %s", cloudStemcell.CID()), "Stemcell uploaded by interrupted deploy"),
+					SkipError: biui.NewSkipStageError(bosherr.Errorf("Found stemcell of interrupted deploy: %s", cloudStemcell.CID()), "Stemcell uploaded by interrupted deploy"),
+				}))
+			})
+		})
+
+		Context("when deploy fails after creating resources", func() {
+			var (
+				replacingVM     bool
+				deletingDisk    bool
+				migratingDisk   bool
+				interruptDeploy chan struct{}
+			)
+
+			BeforeEach(func() {
+				replacingVM = true
+				deletingDisk = false
+				migratingDisk = false
+				interruptDeploy = nil
+
+				mockDeployer.EXPECT().Deploy(
+					cloud,
+					boshDeploymentManifest,
+					cloudStemcell,
+					installationManifest.Registry,
+					gomock.Any(),
+					gomock.Any(),
+				).Do(func(_, _, _, _, _, _ interface{}) {
+					deploymentState, err := setupDeploymentStateService.Load()
+					Expect(err).ToNot(HaveOccurred())
+
+					if replacingVM {
+						deploymentState.ReplacedVMCID = deploymentState.CurrentVMCID
+					}
+					if deletingDisk {
+						deploymentState.Disks = []biconfig.DiskRecord{}
+					}
+					if migratingDisk {
+						deploymentState.Disks[0].Orphaned = true
+					}
+					deploymentState.CurrentVMCID = "fake-new-vm-cid"
+					deploymentState.CurrentDiskID = "fake-new-disk-id"
+					deploymentState.CurrentStemcellID = "fake-new-stemcell-id"
+					deploymentState.Disks = append(deploymentState.Disks, biconfig.DiskRecord{ID: "fake-new-disk-id", CID: "fake-new-disk-cid"})
+					deploymentState.Stemcells = append(deploymentState.Stemcells, biconfig.StemcellRecord{ID: "fake-new-stemcell-id", CID: "fake-new-stemcell-cid"})
+
+					err = setupDeploymentStateService.Save(deploymentState)
+					Expect(err).ToNot(HaveOccurred())
+
+					if interruptDeploy != nil {
+						close(interruptDeploy)
+					}
+				}).Return(nil, errors.New("fake-deploy-error")).AnyTimes()
+
+				previousDeploymentState := biconfig.DeploymentState{
+					CurrentVMCID:       "fake-old-vm-cid",
+					CurrentDiskID:      "fake-old-disk-id",
+					CurrentStemcellID:  "fake-old-stemcell-id",
+					CurrentManifestSHA: "fake-manifest-sha",
+					Disks:              []biconfig.DiskRecord{{ID: "fake-old-disk-id", CID: "fake-old-disk-cid"}},
+					Stemcells:          []biconfig.StemcellRecord{{ID: "fake-old-stemcell-id", CID: "fake-old-stemcell-cid"}},
+				}
+
+				setupDeploymentStateService.Save(previousDeploymentState)
+			})
+
+			deleteCalls := func() []fakebicloud.RunInput {
+				calls := []fakebicloud.RunInput{}
+				for _, runInput := range fakeCPICmdRunner.RunInputs {
+					if strings.HasPrefix(runInput.Method, "delete_") {
+						calls = append(calls, fakebicloud.RunInput{Method: runInput.Method, Arguments: runInput.Arguments})
+					}
+				}
+				return calls
+			}
+
+			It("keeps the resources when not rolling back", func() {
+				err := command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).To(HaveOccurred())
+				Expect(deleteCalls()).To(BeEmpty())
+			})
+
+			Context("when rolling back on failure", func() {
+				BeforeEach(func() {
+					defaultCreateEnvOpts.RollbackOnFailure = true
+				})
+
+				It("deletes the VM, disk and stemcell created by the deploy", func() {
+					err := command.Run(fakeStage, defaultCreateEnvOpts)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-deploy-error"))
+
+					Expect(deleteCalls()).To(Equal([]fakebicloud.RunInput{
+						{Method: "delete_vm", Arguments: []interface{}{"fake-new-vm-cid"}},
+						{Method: "delete_disk", Arguments: []interface{}{"fake-new-disk-cid"}},
+						{Method: "delete_stemcell", Arguments: []interface{}{"fake-new-stemcell-cid"}},
+					}))
+
+					var rollbackStage *fakebiui.FakeStage
+					for _, call := range fakeStage.PerformCalls {
+						if call.Name == "rolling back" {
+							rollbackStage = call.Stage
+						}
+					}
+					Expect(rollbackStage).ToNot(BeNil())
+					Expect(rollbackStage.PerformCalls).To(ContainElement(&fakebiui.PerformCall{Name: "Deleting VM 'fake-new-vm-cid'"}))
+				})
+
+				It("restores the replaced VM without the manifest SHA, since its jobs were stopped", func() {
+					err := command.Run(fakeStage, defaultCreateEnvOpts)
+					Expect(err).To(HaveOccurred())
+
+					deploymentState, err := setupDeploymentStateService.Load()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(deploymentState.CurrentVMCID).To(Equal("fake-old-vm-cid"))
+					Expect(deploymentState.ReplacedVMCID).To(Equal(""))
+					Expect(deploymentState.CurrentManifestSHA).To(Equal(""))
+					Expect(deploymentState.CurrentDiskID).To(Equal("fake-old-disk-id"))
+					Expect(deploymentState.Disks).To(Equal([]biconfig.DiskRecord{{ID: "fake-old-disk-id", CID: "fake-old-disk-cid"}}))
+					Expect(deploymentState.CurrentStemcellID).To(Equal("fake-old-stemcell-id"))
+					Expect(deploymentState.Stemcells).To(Equal([]biconfig.StemcellRecord{{ID: "fake-old-stemcell-id", CID: "fake-old-stemcell-cid"}}))
+					Expect(deploymentState.Checkpoint).To(BeNil())
+				})
+
+				It("rolls back a deploy that was interrupted", func() {
+					interruptDeploy = make(chan struct{})
+					mockStemcellManager.EXPECT().Upload(extractedStemcell, gomock.Any()).Return(cloudStemcell, nil)
+
+					err := command.Run(biui.NewInterruptibleStage(fakeStage, interruptDeploy), defaultCreateEnvOpts)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-deploy-error"))
+					Expect(err.Error()).ToNot(ContainSubstring("Rolling back"))
+
+					Expect(deleteCalls()).To(Equal([]fakebicloud.RunInput{
+						{Method: "delete_vm", Arguments: []interface{}{"fake-new-vm-cid"}},
+						{Method: "delete_disk", Arguments: []interface{}{"fake-new-disk-cid"}},
+						{Method: "delete_stemcell", Arguments: []interface{}{"fake-new-stemcell-cid"}},
+					}))
+
+					deploymentState, err := setupDeploymentStateService.Load()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(deploymentState.CurrentVMCID).To(Equal("fake-old-vm-cid"))
+				})
+
+				Context("when the deploy already deleted the previous VM", func() {
+					BeforeEach(func() {
+						replacingVM = false
+					})
+
+					It("keeps the VM that replaced it, with its disk and stemcell, and orphans the previous disk", func() {
+						err := command.Run(fakeStage, defaultCreateEnvOpts)
+						Expect(err).To(HaveOccurred())
+						Expect(deleteCalls()).To(BeEmpty())
+
+						deploymentState, err := setupDeploymentStateService.Load()
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(deploymentState.CurrentVMCID).To(Equal("fake-new-vm-cid"))
+						Expect(deploymentState.CurrentManifestSHA).To(Equal(""))
+						Expect(deploymentState.CurrentDiskID).To(Equal("fake-new-disk-id"))
+						Expect(deploymentState.Disks).To(Equal([]biconfig.DiskRecord{
+							{ID: "fake-old-disk-id", CID: "fake-old-disk-cid", Orphaned: true},
+							{ID: "fake-new-disk-id", CID: "fake-new-disk-cid"},
+						}))
+						Expect(deploymentState.CurrentStemcellID).To(Equal("fake-new-stemcell-id"))
+						Expect(deploymentState.Stemcells).To(Equal([]biconfig.StemcellRecord{
+							{ID: "fake-old-stemcell-id", CID: "fake-old-stemcell-cid"},
+							{ID: "fake-new-stemcell-id", CID: "fake-new-stemcell-cid"},
+						}))
+					})
+				})
+
+				Context("when the deploy already deleted the previous disk", func() {
+					BeforeEach(func() {
+						deletingDisk = true
+					})
+
+					It("keeps the disk that replaced it", func() {
+						err := command.Run(fakeStage, defaultCreateEnvOpts)
+						Expect(err).To(HaveOccurred())
+						Expect(deleteCalls()).ToNot(ContainElement(fakebicloud.RunInput{Method: "delete_disk", Arguments: []interface{}{"fake-new-disk-cid"}}))
+
+						deploymentState, err := setupDeploymentStateService.Load()
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(deploymentState.CurrentVMCID).To(Equal("fake-old-vm-cid"))
+						Expect(deploymentState.CurrentManifestSHA).To(Equal(""))
+						Expect(deploymentState.CurrentDiskID).To(Equal("fake-new-disk-id"))
+						Expect(deploymentState.Disks).To(Equal([]biconfig.DiskRecord{{ID: "fake-new-disk-id", CID: "fake-new-disk-cid"}}))
+					})
+				})
+
+				Context("when the deploy migrated the previous disk", func() {
+					BeforeEach(func() {
+						migratingDisk = true
+					})
+
+					It("restores the previous disk and orphans the disk the data was migrated to", func() {
+						err := command.Run(fakeStage, defaultCreateEnvOpts)
+						Expect(err).To(HaveOccurred())
+						Expect(deleteCalls()).ToNot(ContainElement(fakebicloud.RunInput{Method: "delete_disk", Arguments: []interface{}{"fake-new-disk-cid"}}))
+
+						deploymentState, err := setupDeploymentStateService.Load()
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(deploymentState.CurrentDiskID).To(Equal("fake-old-disk-id"))
+						Expect(deploymentState.Disks).To(Equal([]biconfig.DiskRecord{
+							{ID: "fake-old-disk-id", CID: "fake-old-disk-cid"},
+							{ID: "fake-new-disk-id", CID: "fake-new-disk-cid", Orphaned: true},
+						}))
+					})
+				})
+
+				It("returns the errors of rolling back together with the deploy error", func() {
+					fakeCPICmdRunner.RunErr = errors.New("fake-cpi-error")
+
+					err := command.Run(fakeStage, defaultCreateEnvOpts)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-deploy-error"))
+					Expect(err.Error()).To(ContainSubstring("Rolling back"))
+					Expect(err.Error()).To(ContainSubstring("fake-cpi-error"))
+				})
+			})
+		})
+
+		Context("when the deploy takes longer than the timeout", func() {
+			BeforeEach(func() {
+				defaultCreateEnvOpts.Timeout = time.Millisecond
+
+				readRelease := releaseReader.ReadStub
+				releaseReader.ReadStub = func(path string) (boshrel.Release, error) {
+					time.Sleep(20 * time.Millisecond)
+					return readRelease(path)
+				}
+			})
+
+			It("stops after the current step and returns an error", func() {
+				err := command.Run(fakeStage, defaultCreateEnvOpts)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Deploy did not finish within 1ms"))
+				Expect(bicmd.ExitCode(bicmd.CategorizeError(err))).To(Equal(bicmd.ExitCodeError))
+			})
 		})
 	})
 })
@@ -22,5 +22,9 @@ func (c *DeleteCmd) Run(stage boshui.Stage, opts DeleteEnvOpts) error {
 	depDeleter := c.envProvider(
 		opts.Args.Manifest.Path, opts.StatePath, opts.VarFlags.AsVariables(), opts.OpsFlags.AsOp())
 
+	if opts.Force {
+		return depDeleter.ForceDeleteDeployment(stage)
+	}
+
 	return depDeleter.DeleteDeployment(stage)
 }
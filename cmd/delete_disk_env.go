@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"github.com/cppforlife/go-patch/patch"
+
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	boshui "github.com/cloudfoundry/bosh-cli/ui"
+)
+
+type DeleteDiskEnvCmd struct {
+	ui          boshui.UI
+	envProvider func(string, string, boshtpl.Variables, patch.Op) DeploymentDeleter
+}
+
+func NewDeleteDiskEnvCmd(ui boshui.UI, envProvider func(string, string, boshtpl.Variables, patch.Op) DeploymentDeleter) DeleteDiskEnvCmd {
+	return DeleteDiskEnvCmd{ui: ui, envProvider: envProvider}
+}
+
+func (c DeleteDiskEnvCmd) Run(stage boshui.Stage, opts DeleteDiskEnvOpts) error {
+	c.ui.BeginLinef("Deployment manifest: '%s'\n", opts.Args.Manifest.Path)
+
+	depDeleter := c.envProvider(
+		opts.Args.Manifest.Path, opts.StatePath, opts.VarFlags.AsVariables(), opts.OpsFlags.AsOp())
+
+	return depDeleter.DeleteOrphanedDisk(opts.Args.DiskCID, stage)
+}
@@ -0,0 +1,77 @@
+package cmd_test
+
+import (
+	"errors"
+
+	"github.com/cppforlife/go-patch/patch"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/cmd"
+	mock_cmd "github.com/cloudfoundry/bosh-cli/cmd/mocks"
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	fakeui "github.com/cloudfoundry/bosh-cli/ui/fakes"
+)
+
+var _ = Describe("DeleteDiskEnvCmd", func() {
+	var mockCtrl *gomock.Controller
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	var (
+		mockDeploymentDeleter *mock_cmd.MockDeploymentDeleter
+		ui                    *fakeui.FakeUI
+		fakeStage             *fakeui.FakeStage
+		command               DeleteDiskEnvCmd
+
+		opts      DeleteDiskEnvOpts
+		statePath string
+	)
+
+	BeforeEach(func() {
+		mockDeploymentDeleter = mock_cmd.NewMockDeploymentDeleter(mockCtrl)
+		ui = &fakeui.FakeUI{}
+		fakeStage = fakeui.NewFakeStage()
+
+		envProvider := func(_ string, statePath_ string, _ boshtpl.Variables, _ patch.Op) DeploymentDeleter {
+			statePath = statePath_
+			return mockDeploymentDeleter
+		}
+
+		command = NewDeleteDiskEnvCmd(ui, envProvider)
+
+		opts = DeleteDiskEnvOpts{
+			Args: DeleteDiskEnvArgs{
+				Manifest: FileBytesWithPathArg{Path: "/path/to/bosh.yml"},
+				DiskCID:  "fake-disk-cid",
+			},
+			StatePath: "/path/to/state.json",
+		}
+	})
+
+	act := func() error { return command.Run(fakeStage, opts) }
+
+	It("deletes the orphaned disk", func() {
+		mockDeploymentDeleter.EXPECT().DeleteOrphanedDisk("fake-disk-cid", fakeStage).Return(nil)
+
+		Expect(act()).ToNot(HaveOccurred())
+
+		Expect(statePath).To(Equal("/path/to/state.json"))
+		Expect(ui.Said).To(Equal([]string{"Deployment manifest: '/path/to/bosh.yml'\n"}))
+	})
+
+	It("returns an error when deleting the disk fails", func() {
+		mockDeploymentDeleter.EXPECT().DeleteOrphanedDisk("fake-disk-cid", fakeStage).Return(errors.New("fake-err"))
+
+		err := act()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("fake-err"))
+	})
+})
@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"fmt"
+	"time"
+
 	bihttpagent "github.com/cloudfoundry/bosh-agent/agentclient/http"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	bihttpclient "github.com/cloudfoundry/bosh-utils/httpclient"
@@ -12,6 +15,9 @@ import (
 	biconfig "github.com/cloudfoundry/bosh-cli/config"
 	bicpirel "github.com/cloudfoundry/bosh-cli/cpi/release"
 	bidepl "github.com/cloudfoundry/bosh-cli/deployment"
+	bidisk "github.com/cloudfoundry/bosh-cli/deployment/disk"
+	biinstance "github.com/cloudfoundry/bosh-cli/deployment/instance"
+	bivm "github.com/cloudfoundry/bosh-cli/deployment/vm"
 	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
 	biinstall "github.com/cloudfoundry/bosh-cli/installation"
 	boshinst "github.com/cloudfoundry/bosh-cli/installation"
@@ -22,6 +28,8 @@ import (
 
 type DeploymentDeleter interface {
 	DeleteDeployment(stage biui.Stage) (err error)
+	ForceDeleteDeployment(stage biui.Stage) (err error)
+	DeleteOrphanedDisk(diskCID string, stage biui.Stage) (err error)
 }
 
 func NewDeploymentDeleter(
@@ -34,6 +42,10 @@ func NewDeploymentDeleter(
 	agentClientFactory bihttpagent.AgentClientFactory,
 	blobstoreFactory biblobstore.Factory,
 	deploymentManagerFactory bidepl.ManagerFactory,
+	instanceRepo biconfig.InstanceRepo,
+	diskRepo biconfig.DiskRepo,
+	instanceManagerFactory biinstance.ManagerFactory,
+	vmManagerFactories func(index int) bivm.ManagerFactory,
 	deploymentManifestPath string,
 	deploymentVars boshtpl.Variables,
 	deploymentOp patch.Op,
@@ -54,6 +66,10 @@ func NewDeploymentDeleter(
 		agentClientFactory:                      agentClientFactory,
 		blobstoreFactory:                        blobstoreFactory,
 		deploymentManagerFactory:                deploymentManagerFactory,
+		instanceRepo:                            instanceRepo,
+		diskRepo:                                diskRepo,
+		instanceManagerFactory:                  instanceManagerFactory,
+		vmManagerFactories:                      vmManagerFactories,
 		deploymentManifestPath:                  deploymentManifestPath,
 		deploymentVars:                          deploymentVars,
 		deploymentOp:                            deploymentOp,
@@ -76,6 +92,10 @@ type deploymentDeleter struct {
 	agentClientFactory                      bihttpagent.AgentClientFactory
 	blobstoreFactory                        biblobstore.Factory
 	deploymentManagerFactory                bidepl.ManagerFactory
+	instanceRepo                            biconfig.InstanceRepo
+	diskRepo                                biconfig.DiskRepo
+	instanceManagerFactory                  biinstance.ManagerFactory
+	vmManagerFactories                      func(index int) bivm.ManagerFactory
 	deploymentManifestPath                  string
 	deploymentVars                          boshtpl.Variables
 	deploymentOp                            patch.Op
@@ -88,6 +108,18 @@ type deploymentDeleter struct {
 }
 
 func (c *deploymentDeleter) DeleteDeployment(stage biui.Stage) (err error) {
+	return c.deleteDeployment(stage, false)
+}
+
+// ForceDeleteDeployment deletes the deployment like DeleteDeployment, but
+// carries on when the CPI fails to delete VMs, disks or stemcells, for
+// example because they are already gone, so that the deployment state is
+// always cleaned up.
+func (c *deploymentDeleter) ForceDeleteDeployment(stage biui.Stage) (err error) {
+	return c.deleteDeployment(stage, true)
+}
+
+func (c *deploymentDeleter) deleteDeployment(stage biui.Stage, force bool) (err error) {
 	c.ui.BeginLinef("Deployment state: '%s'\n", c.deploymentStateService.Path())
 
 	if !c.deploymentStateService.Exists() {
@@ -95,11 +127,134 @@ func (c *deploymentDeleter) DeleteDeployment(stage biui.Stage) (err error) {
 		return nil
 	}
 
+	err = c.deploymentStateService.Lock()
+	if err != nil {
+		return bosherr.WrapError(err, "Locking deployment state")
+	}
+
+	defer func() {
+		err := c.deploymentStateService.Unlock()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Unlocking deployment state: %s", err.Error())
+		}
+	}()
+
 	deploymentState, err := c.deploymentStateService.Load()
 	if err != nil {
 		return bosherr.WrapError(err, "Loading deployment state")
 	}
 
+	return c.withInstalledCpiRelease(stage, deploymentState.CurrentCPI, func(localCpiInstallation biinstall.Installation, installationManifest biinstallmanifest.Manifest) error {
+		c.logger.Debug(c.logTag, "Creating cloud client...")
+
+		cloud, err := c.cloudFactory.NewCloud(localCpiInstallation, deploymentState.DirectorID, currentStemcellAPIVersion(deploymentState))
+		if err != nil {
+			return bosherr.WrapError(err, "Creating CPI client from CPI installation")
+		}
+		defer stopCPIPlugins(c.cloudFactory, c.logger, c.logTag)
+
+		deleteAndUninstall := func() error {
+			err := c.findAndDeleteDeployment(stage, cloud, deploymentState.DirectorID, installationManifest, force)
+			if err != nil {
+				return err
+			}
+
+			return stage.Perform("Uninstalling local artifacts for CPI and deployment", func() error {
+				// the plugin runs from the installation
+				stopCPIPlugins(c.cloudFactory, c.logger, c.logTag)
+
+				err := c.cpiUninstaller.Uninstall(localCpiInstallation.Target())
+				if err != nil {
+					return err
+				}
+
+				return c.deploymentStateService.Cleanup()
+			})
+		}
+
+		if !cloud.UsesRegistry() {
+			c.logger.Info(c.logTag, "CPI passes agent settings to the agent, skipping the registry")
+			return deleteAndUninstall()
+		}
+
+		return localCpiInstallation.WithRunningRegistry(c.logger, stage, deleteAndUninstall)
+	})
+}
+
+// currentStemcellAPIVersion returns the api_version recorded for the current
+// stemcell of the deployment, or 0 when it is not known.
+func currentStemcellAPIVersion(deploymentState biconfig.DeploymentState) int {
+	for _, stemcellRecord := range deploymentState.Stemcells {
+		if stemcellRecord.ID == deploymentState.CurrentStemcellID {
+			return stemcellRecord.APIVersion
+		}
+	}
+
+	return 0
+}
+
+// DeleteOrphanedDisk deletes a disk that was orphaned when it was replaced,
+// in the cloud and from the deployment state. Disks in use cannot be deleted.
+func (c *deploymentDeleter) DeleteOrphanedDisk(diskCID string, stage biui.Stage) (err error) {
+	c.ui.BeginLinef("Deployment state: '%s'\n", c.deploymentStateService.Path())
+
+	if !c.deploymentStateService.Exists() {
+		return bosherr.Errorf("Deployment state file '%s' not found", c.deploymentStateService.Path())
+	}
+
+	err = c.deploymentStateService.Lock()
+	if err != nil {
+		return bosherr.WrapError(err, "Locking deployment state")
+	}
+
+	defer func() {
+		err := c.deploymentStateService.Unlock()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Unlocking deployment state: %s", err.Error())
+		}
+	}()
+
+	deploymentState, err := c.deploymentStateService.Load()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading deployment state")
+	}
+
+	diskRecord, found, err := c.diskRepo.Find(diskCID)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Finding disk '%s'", diskCID)
+	}
+
+	if !found {
+		return bosherr.Errorf("Disk '%s' not found in the deployment state", diskCID)
+	}
+
+	if !diskRecord.Orphaned {
+		return bosherr.Errorf("Disk '%s' is in use, only orphaned disks can be deleted", diskCID)
+	}
+
+	return c.withInstalledCpiRelease(stage, deploymentState.CurrentCPI, func(localCpiInstallation biinstall.Installation, _ biinstallmanifest.Manifest) error {
+		cloud, err := c.cloudFactory.NewCloud(localCpiInstallation, deploymentState.DirectorID, currentStemcellAPIVersion(deploymentState))
+		if err != nil {
+			return bosherr.WrapError(err, "Creating CPI client from CPI installation")
+		}
+		defer stopCPIPlugins(c.cloudFactory, c.logger, c.logTag)
+
+		disk := bidisk.NewDisk(diskRecord, cloud, c.diskRepo)
+
+		return stage.Perform(fmt.Sprintf("Deleting orphaned disk '%s'", diskCID), func() error {
+			err := disk.Delete()
+			cloudErr, ok := err.(bicloud.Error)
+			if ok && cloudErr.Type() == bicloud.DiskNotFoundError {
+				return biui.NewSkipStageError(cloudErr, "Disk Not Found")
+			}
+			return err
+		})
+	})
+}
+
+// withInstalledCpiRelease validates the releases of the manifest and calls fn
+// with the named CPI, the one that created the deployment, installed.
+func (c *deploymentDeleter) withInstalledCpiRelease(stage biui.Stage, cpiName string, fn func(biinstall.Installation, biinstallmanifest.Manifest) error) error {
 	target, err := c.targetProvider.NewTarget()
 	if err != nil {
 		return bosherr.WrapError(err, "Determining installation target")
@@ -126,6 +281,11 @@ func (c *deploymentDeleter) DeleteDeployment(stage biui.Stage) (err error) {
 			return err
 		}
 
+		installationManifest, err = installationManifest.ForCPI(cpiName)
+		if err != nil {
+			return err
+		}
+
 		cpiReleaseName := installationManifest.Template.Release
 		cpiReleaseRef, found := releaseSetManifest.FindByName(cpiReleaseName)
 		if !found {
@@ -145,43 +305,37 @@ func (c *deploymentDeleter) DeleteDeployment(stage biui.Stage) (err error) {
 		return err
 	}
 
-	err = c.cpiInstaller.WithInstalledCpiRelease(installationManifest, target, stage, func(localCpiInstallation biinstall.Installation) error {
-		return localCpiInstallation.WithRunningRegistry(c.logger, stage, func() error {
-			err = c.findAndDeleteDeployment(stage, localCpiInstallation, deploymentState.DirectorID, installationManifest.Mbus)
-
-			if err != nil {
-				return err
-			}
-
-			return stage.Perform("Uninstalling local artifacts for CPI and deployment", func() error {
-				err := c.cpiUninstaller.Uninstall(localCpiInstallation.Target())
-				if err != nil {
-					return err
-				}
-
-				return c.deploymentStateService.Cleanup()
-			})
-		})
+	return c.cpiInstaller.WithInstalledCpiRelease(installationManifest, target, stage, func(localCpiInstallation biinstall.Installation) error {
+		return fn(localCpiInstallation, installationManifest)
 	})
-
-	return err
 }
 
-func (c *deploymentDeleter) findAndDeleteDeployment(stage biui.Stage, installation biinstall.Installation, directorID, installationMbus string) error {
-	deploymentManager, err := c.deploymentManager(installation, directorID, installationMbus)
+func (c *deploymentDeleter) findAndDeleteDeployment(stage biui.Stage, cloud bicloud.Cloud, directorID string, installationManifest biinstallmanifest.Manifest, force bool) error {
+	err := c.deleteOtherInstances(stage, cloud, directorID, installationManifest, force)
+	if err != nil {
+		return bosherr.WrapError(err, "Deleting instances")
+	}
+
+	deploymentManager, err := c.deploymentManager(cloud, directorID, installationManifest)
 	if err != nil {
 		return err
 	}
 
-	err = c.findCurrentDeploymentAndDelete(stage, deploymentManager)
+	err = c.findCurrentDeploymentAndDelete(stage, deploymentManager, force)
 	if err != nil {
 		return bosherr.WrapError(err, "Deleting deployment")
 	}
 
-	return deploymentManager.Cleanup(stage)
+	err = deploymentManager.Cleanup(stage)
+	if err != nil && force {
+		c.ignoreForcedError(bosherr.WrapError(err, "Deleting unused disks and stemcells"))
+		return nil
+	}
+
+	return err
 }
 
-func (c *deploymentDeleter) findCurrentDeploymentAndDelete(stage biui.Stage, deploymentManager bidepl.Manager) error {
+func (c *deploymentDeleter) findCurrentDeploymentAndDelete(stage biui.Stage, deploymentManager bidepl.Manager, force bool) error {
 	c.logger.Debug(c.logTag, "Finding current deployment...")
 
 	deployment, found, err := deploymentManager.FindCurrent()
@@ -196,25 +350,80 @@ func (c *deploymentDeleter) findCurrentDeploymentAndDelete(stage biui.Stage, dep
 			return nil
 		}
 
+		if force {
+			err := deployment.ForceDelete(deleteStage)
+			if err != nil {
+				c.ignoreForcedError(err)
+			}
+			return nil
+		}
+
 		return deployment.Delete(deleteStage)
 	})
 }
 
-func (c *deploymentDeleter) deploymentManager(installation biinstall.Installation, directorID, installationMbus string) (bidepl.Manager, error) {
-	c.logger.Debug(c.logTag, "Creating cloud client...")
+// ignoreForcedError reports an error that a forced delete carries on past, as
+// the resources it left behind may need to be removed from the IaaS manually.
+func (c *deploymentDeleter) ignoreForcedError(err error) {
+	c.logger.Warn(c.logTag, "Ignoring error when force deleting: %s", err.Error())
+	c.ui.ErrorLinef("Ignoring error when force deleting, remaining resources may have to be deleted manually: %s", err.Error())
+}
 
-	cloud, err := c.cloudFactory.NewCloud(installation, directorID)
+// deleteOtherInstances deletes the VMs of the instances other than the first.
+// Their disks are no longer in use afterwards and are deleted on cleanup.
+func (c *deploymentDeleter) deleteOtherInstances(stage biui.Stage, cloud bicloud.Cloud, directorID string, installationManifest biinstallmanifest.Manifest, force bool) error {
+	records, err := c.instanceRepo.All()
 	if err != nil {
-		return nil, bosherr.WrapError(err, "Creating CPI client from CPI installation")
+		return bosherr.WrapError(err, "Finding instances")
 	}
 
+	for _, record := range records {
+		err = c.deleteInstance(stage, cloud, directorID, installationManifest, record)
+		if err != nil {
+			if !force {
+				return err
+			}
+			c.ignoreForcedError(err)
+		}
+
+		err = c.instanceRepo.Delete(record.Index)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Deleting instance with index %d", record.Index)
+		}
+	}
+
+	return nil
+}
+
+func (c *deploymentDeleter) deleteInstance(stage biui.Stage, cloud bicloud.Cloud, directorID string, installationManifest biinstallmanifest.Manifest, record biconfig.InstanceRecord) error {
+	mbus, err := instanceMbusURL(installationManifest.Mbus, record.IP)
+	if err != nil {
+		return err
+	}
+
+	agentClient, _ := c.agentClientFactory.NewAgentClient(directorID, mbus, installationManifest.Cert.CA)
+
+	blobstore, err := c.blobstoreFactory.Create(mbus, bihttpclient.CreateDefaultClientInsecureSkipVerify())
+	if err != nil {
+		return bosherr.WrapError(err, "Creating blobstore client")
+	}
+
+	vmManager := c.vmManagerFactories(record.Index).NewManager(cloud, agentClient)
+	instanceManager := c.instanceManagerFactory.NewManager(cloud, vmManager, blobstore)
+
+	return stage.PerformComplex(fmt.Sprintf("deleting instance with index %d", record.Index), func(deleteStage biui.Stage) error {
+		return instanceManager.DeleteAll(10*time.Second, 500*time.Millisecond, deleteStage)
+	})
+}
+
+func (c *deploymentDeleter) deploymentManager(cloud bicloud.Cloud, directorID string, installationManifest biinstallmanifest.Manifest) (bidepl.Manager, error) {
 	c.logger.Debug(c.logTag, "Creating agent client...")
 
-	agentClient, _ := c.agentClientFactory.NewAgentClient(directorID, installationMbus, "")
+	agentClient, _ := c.agentClientFactory.NewAgentClient(directorID, installationManifest.Mbus, installationManifest.Cert.CA)
 
 	c.logger.Debug(c.logTag, "Creating blobstore client...")
 
-	blobstore, err := c.blobstoreFactory.Create(installationMbus, bihttpclient.CreateDefaultClientInsecureSkipVerify())
+	blobstore, err := c.blobstoreFactory.Create(installationManifest.Mbus, bihttpclient.CreateDefaultClientInsecureSkipVerify())
 	if err != nil {
 		return nil, bosherr.WrapError(err, "Creating blobstore client")
 	}
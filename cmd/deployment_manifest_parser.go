@@ -13,7 +13,9 @@ import (
 )
 
 type DeploymentManifestParser interface {
-	GetDeploymentManifest(path string, vars boshtpl.Variables, op patch.Op, releaseSetManifest birelsetmanifest.Manifest, stage biui.Stage) (bideplmanifest.Manifest, string, error)
+	GetDeploymentManifest(path string, vars boshtpl.Variables, op patch.Op, releaseSetManifest birelsetmanifest.Manifest, stage biui.Stage) (bideplmanifest.Manifest, bidepltpl.InterpolatedTemplate, error)
+	ValidateReleaseJobs(deploymentManifest bideplmanifest.Manifest, stage biui.Stage) error
+	ParseDeployedManifest(contents []byte, vars boshtpl.Variables, path string) (bideplmanifest.Manifest, error)
 }
 
 type deploymentManifestParser struct {
@@ -36,45 +38,69 @@ func NewDeploymentManifestParser(
 	}
 }
 
-func (y deploymentManifestParser) GetDeploymentManifest(path string, vars boshtpl.Variables, op patch.Op, releaseSetManifest birelsetmanifest.Manifest, stage biui.Stage) (bideplmanifest.Manifest, string, error) {
+func (y deploymentManifestParser) GetDeploymentManifest(path string, vars boshtpl.Variables, op patch.Op, releaseSetManifest birelsetmanifest.Manifest, stage biui.Stage) (bideplmanifest.Manifest, bidepltpl.InterpolatedTemplate, error) {
 	var deploymentManifest bideplmanifest.Manifest
-	var manifestSHA string
+	var interpolatedTemplate bidepltpl.InterpolatedTemplate
 
 	err := stage.Perform("Validating deployment manifest", func() error {
 		var err error
 
 		template, err := y.templateFactory.NewDeploymentTemplateFromPath(path)
 		if err != nil {
-			return bosherr.WrapErrorf(err, "Evaluating manifest")
+			return ValidationError{Err: bosherr.WrapErrorf(err, "Evaluating manifest")}
 		}
 
-		interpolatedTemplate, err := template.Evaluate(vars, op)
+		interpolatedTemplate, err = template.Evaluate(vars, op)
 		if err != nil {
-			return bosherr.WrapErrorf(err, "Evaluating manifest '%s'", path)
+			return ValidationError{Err: bosherr.WrapErrorf(err, "Evaluating manifest '%s'", path)}
 		}
 
-		manifestSHA = interpolatedTemplate.SHA()
-
 		deploymentManifest, err = y.deploymentParser.Parse(interpolatedTemplate, path)
 		if err != nil {
-			return bosherr.WrapErrorf(err, "Parsing deployment manifest '%s'", path)
+			return ValidationError{Err: bosherr.WrapErrorf(err, "Parsing deployment manifest '%s'", path)}
 		}
 
 		err = y.deploymentValidator.Validate(deploymentManifest, releaseSetManifest)
 		if err != nil {
-			return bosherr.WrapError(err, "Validating deployment manifest")
+			return ValidationError{Err: bosherr.WrapError(err, "Validating deployment manifest")}
 		}
 
-		err = y.deploymentValidator.ValidateReleaseJobs(deploymentManifest, y.releaseManager)
+		return nil
+	})
+	if err != nil {
+		return bideplmanifest.Manifest{}, bidepltpl.InterpolatedTemplate{}, err
+	}
+
+	return deploymentManifest, interpolatedTemplate, nil
+}
+
+// ValidateReleaseJobs checks the jobs of the manifest against the releases,
+// which have to be extracted first.
+func (y deploymentManifestParser) ValidateReleaseJobs(deploymentManifest bideplmanifest.Manifest, stage biui.Stage) error {
+	return stage.Perform("Validating deployment jobs", func() error {
+		err := y.deploymentValidator.ValidateReleaseJobs(deploymentManifest, y.releaseManager)
 		if err != nil {
-			return bosherr.WrapError(err, "Validating deployment jobs refer to jobs in release")
+			return ValidationError{Err: bosherr.WrapError(err, "Validating deployment jobs refer to jobs in release")}
 		}
 
 		return nil
 	})
+}
+
+// ParseDeployedManifest parses a manifest recorded in the deployment state,
+// without validating it against the releases. The recorded manifest only
+// references its variables, which are interpolated with the given vars.
+// References to variables that are not given are left as they are.
+func (y deploymentManifestParser) ParseDeployedManifest(contents []byte, vars boshtpl.Variables, path string) (bideplmanifest.Manifest, error) {
+	bytes, err := boshtpl.NewTemplate(contents).Evaluate(vars, nil, boshtpl.EvaluateOpts{})
+	if err != nil {
+		return bideplmanifest.Manifest{}, bosherr.WrapError(err, "Evaluating deployed manifest")
+	}
+
+	deploymentManifest, err := y.deploymentParser.Parse(bidepltpl.NewInterpolatedTemplate(bytes, ""), path)
 	if err != nil {
-		return bideplmanifest.Manifest{}, "", err
+		return bideplmanifest.Manifest{}, bosherr.WrapError(err, "Parsing deployed manifest")
 	}
 
-	return deploymentManifest, manifestSHA, nil
+	return deploymentManifest, nil
 }
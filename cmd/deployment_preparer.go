@@ -1,10 +1,17 @@
 package cmd
 
 import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
 	bihttpagent "github.com/cloudfoundry/bosh-agent/agentclient/http"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	bihttpclient "github.com/cloudfoundry/bosh-utils/httpclient"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	"github.com/cppforlife/go-patch/patch"
 
 	biblobstore "github.com/cloudfoundry/bosh-cli/blobstore"
@@ -12,14 +19,19 @@ import (
 	biconfig "github.com/cloudfoundry/bosh-cli/config"
 	bicpirel "github.com/cloudfoundry/bosh-cli/cpi/release"
 	bidepl "github.com/cloudfoundry/bosh-cli/deployment"
+	biagent "github.com/cloudfoundry/bosh-cli/deployment/agent"
 	bideplmanifest "github.com/cloudfoundry/bosh-cli/deployment/manifest"
+	bideplrel "github.com/cloudfoundry/bosh-cli/deployment/release"
+	bidepltpl "github.com/cloudfoundry/bosh-cli/deployment/template"
 	bivm "github.com/cloudfoundry/bosh-cli/deployment/vm"
 	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
 	biinstall "github.com/cloudfoundry/bosh-cli/installation"
 	boshinst "github.com/cloudfoundry/bosh-cli/installation"
 	biinstallmanifest "github.com/cloudfoundry/bosh-cli/installation/manifest"
+	bireljob "github.com/cloudfoundry/bosh-cli/release/job"
 	birelsetmanifest "github.com/cloudfoundry/bosh-cli/release/set/manifest"
 	bistemcell "github.com/cloudfoundry/bosh-cli/stemcell"
+	bitemplate "github.com/cloudfoundry/bosh-cli/templatescompiler"
 	biui "github.com/cloudfoundry/bosh-cli/ui"
 )
 
@@ -27,14 +39,16 @@ func NewDeploymentPreparer(
 	ui biui.UI,
 	logger boshlog.Logger,
 	logTag string,
+	fs boshsys.FileSystem,
 	deploymentStateService biconfig.DeploymentStateService,
 	legacyDeploymentStateMigrator biconfig.LegacyDeploymentStateMigrator,
 	releaseManager boshinst.ReleaseManager,
 	deploymentRecord bidepl.Record,
+	checkpointRepo biconfig.CheckpointRepo,
 	cloudFactory bicloud.Factory,
 	stemcellManagerFactory bistemcell.ManagerFactory,
 	agentClientFactory bihttpagent.AgentClientFactory,
-	vmManagerFactory bivm.ManagerFactory,
+	vmManagerFactories func(index int) bivm.ManagerFactory,
 	blobstoreFactory biblobstore.Factory,
 	deployer bidepl.Deployer,
 	deploymentManifestPath string,
@@ -46,20 +60,27 @@ func NewDeploymentPreparer(
 	releaseSetAndInstallationManifestParser ReleaseSetAndInstallationManifestParser,
 	deploymentManifestParser DeploymentManifestParser,
 	tempRootConfigurator TempRootConfigurator,
+	scratchSpaceChecker biinstall.ScratchSpaceChecker,
 	targetProvider biinstall.TargetProvider,
+	releaseJobResolver bideplrel.JobResolver,
+	jobListRenderer bitemplate.JobListRenderer,
+	strictProperties bool,
+	skipDiskMigration bool,
 ) DeploymentPreparer {
 	return DeploymentPreparer{
 		ui:                                      ui,
 		logger:                                  logger,
 		logTag:                                  logTag,
+		fs:                                      fs,
 		deploymentStateService:                  deploymentStateService,
 		legacyDeploymentStateMigrator:           legacyDeploymentStateMigrator,
 		releaseManager:                          releaseManager,
 		deploymentRecord:                        deploymentRecord,
+		checkpointRepo:                          checkpointRepo,
 		cloudFactory:                            cloudFactory,
 		stemcellManagerFactory:                  stemcellManagerFactory,
 		agentClientFactory:                      agentClientFactory,
-		vmManagerFactory:                        vmManagerFactory,
+		vmManagerFactories:                      vmManagerFactories,
 		blobstoreFactory:                        blobstoreFactory,
 		deployer:                                deployer,
 		deploymentManifestPath:                  deploymentManifestPath,
@@ -71,7 +92,13 @@ func NewDeploymentPreparer(
 		releaseSetAndInstallationManifestParser: releaseSetAndInstallationManifestParser,
 		deploymentManifestParser:                deploymentManifestParser,
 		tempRootConfigurator:                    tempRootConfigurator,
+		scratchSpaceChecker:                     scratchSpaceChecker,
 		targetProvider:                          targetProvider,
+		releaseJobResolver:                      releaseJobResolver,
+		jobListRenderer:                         jobListRenderer,
+		strictProperties:                        strictProperties,
+		skipDiskMigration:                       skipDiskMigration,
+		exitCleanup:                             NewExitCleanup(),
 	}
 }
 
@@ -79,14 +106,16 @@ type DeploymentPreparer struct {
 	ui                                      biui.UI
 	logger                                  boshlog.Logger
 	logTag                                  string
+	fs                                      boshsys.FileSystem
 	deploymentStateService                  biconfig.DeploymentStateService
 	legacyDeploymentStateMigrator           biconfig.LegacyDeploymentStateMigrator
 	releaseManager                          boshinst.ReleaseManager
 	deploymentRecord                        bidepl.Record
+	checkpointRepo                          biconfig.CheckpointRepo
 	cloudFactory                            bicloud.Factory
 	stemcellManagerFactory                  bistemcell.ManagerFactory
 	agentClientFactory                      bihttpagent.AgentClientFactory
-	vmManagerFactory                        bivm.ManagerFactory
+	vmManagerFactories                      func(index int) bivm.ManagerFactory
 	blobstoreFactory                        biblobstore.Factory
 	deployer                                bidepl.Deployer
 	deploymentManifestPath                  string
@@ -98,12 +127,62 @@ type DeploymentPreparer struct {
 	releaseSetAndInstallationManifestParser ReleaseSetAndInstallationManifestParser
 	deploymentManifestParser                DeploymentManifestParser
 	tempRootConfigurator                    TempRootConfigurator
+	scratchSpaceChecker                     biinstall.ScratchSpaceChecker
 	targetProvider                          biinstall.TargetProvider
+	releaseJobResolver                      bideplrel.JobResolver
+	jobListRenderer                         bitemplate.JobListRenderer
+
+	// strictProperties fails validation when job properties do not match
+	// the release job specs, or when job templates, rendered with the
+	// jobListRenderer, look up properties the specs do not declare
+	strictProperties bool
+
+	// skipDiskMigration keeps persistent disks whose size or cloud
+	// properties changed, so that the dry run plans no migration
+	skipDiskMigration bool
+
+	// verifyDigests checks local tarballs against the digests in the
+	// manifest, as only downloaded tarballs are checked otherwise
+	verifyDigests bool
+
+	// rollbackOnFailure deletes what a failed deploy created and restores
+	// the deployment state from before it
+	rollbackOnFailure bool
+
+	// exitCleanup deletes extracted releases and stemcells when the CLI
+	// exits during a deploy
+	exitCleanup *ExitCleanup
+}
+
+// WithExitCleanup returns a copy of the preparer that registers its cleanup
+// with exitCleanup while deploying.
+func (c DeploymentPreparer) WithExitCleanup(exitCleanup *ExitCleanup) DeploymentPreparer {
+	c.exitCleanup = exitCleanup
+	return c
+}
+
+// WithRollbackOnFailure returns a copy of the preparer that rolls back a
+// deploy that fails.
+func (c DeploymentPreparer) WithRollbackOnFailure() DeploymentPreparer {
+	c.rollbackOnFailure = true
+	return c
 }
 
 func (c *DeploymentPreparer) PrepareDeployment(stage biui.Stage) (err error) {
 	c.ui.BeginLinef("Deployment state: '%s'\n", c.deploymentStateService.Path())
 
+	err = c.deploymentStateService.Lock()
+	if err != nil {
+		return bosherr.WrapError(err, "Locking deployment state")
+	}
+
+	defer func() {
+		err := c.deploymentStateService.Unlock()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Unlocking deployment state: %s", err.Error())
+		}
+	}()
+
 	if !c.deploymentStateService.Exists() {
 		migrated, err := c.legacyDeploymentStateMigrator.MigrateIfExists(biconfig.LegacyDeploymentStatePath(c.deploymentManifestPath))
 		if err != nil {
@@ -129,139 +208,956 @@ func (c *DeploymentPreparer) PrepareDeployment(stage biui.Stage) (err error) {
 		return bosherr.WrapError(err, "Setting temp root")
 	}
 
-	defer func() {
+	defer c.exitCleanup.Defer(func() {
 		err := c.releaseManager.DeleteAll()
 		if err != nil {
 			c.logger.Warn(c.logTag, "Deleting all extracted releases: %s", err.Error())
 		}
-	}()
+	})()
 
-	var (
-		extractedStemcell    bistemcell.ExtractedStemcell
-		deploymentManifest   bideplmanifest.Manifest
-		installationManifest biinstallmanifest.Manifest
-		manifestSHA          string
-	)
+	var validated validation
 	err = stage.PerformComplex("validating", func(stage biui.Stage) error {
-		var releaseSetManifest birelsetmanifest.Manifest
-		releaseSetManifest, installationManifest, err = c.releaseSetAndInstallationManifestParser.ReleaseSetAndInstallationManifest(c.deploymentManifestPath, c.deploymentVars, c.deploymentOp)
+		var err error
+		validated, err = c.validateInstallation(stage)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer c.exitCleanup.Defer(func() { c.cleanupStemcell(validated.extractedStemcell) })()
+
+	installationManifest := validated.installationManifest
+
+	// the stemcell is uploaded before the releases of the deployment are
+	// validated, so the changes are confirmed before the CPI is installed
+	changes, found, err := c.printManifestDiff(validated.deploymentManifest)
+	if err != nil {
+		return err
+	}
+
+	if found && changes > 0 {
+		err = c.ui.AskForConfirmation()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = checkCPISwitch(deploymentState, installationManifest.CPIName)
+	if err != nil {
+		return err
+	}
+
+	if deploymentState.CurrentCPI != installationManifest.CPIName {
+		deploymentState.CurrentCPI = installationManifest.CPIName
+
+		err = c.deploymentStateService.Save(deploymentState)
+		if err != nil {
+			return bosherr.WrapError(err, "Saving CPI in deployment state")
+		}
+	}
+
+	err = c.cpiInstaller.WithInstalledCpiRelease(installationManifest, target, stage, func(installation biinstall.Installation) error {
+		cloud, err := c.cloudFactory.NewCloud(installation, deploymentState.DirectorID, validated.extractedStemcell.Manifest().APIVersion)
+		if err != nil {
+			return bosherr.WrapError(err, "Creating CPI client from CPI installation")
+		}
+		defer stopCPIPlugins(c.cloudFactory, c.logger, c.logTag)
+
+		stemcellManager := c.stemcellManagerFactory.NewManager(cloud)
+
+		cloudStemcell, err := c.uploadStemcell(stemcellManager, deploymentState, validated, stage)
+		if err != nil {
+			return err
+		}
+
+		// the stemcell of a deployment that has not changed was found, not uploaded
+		isDeployed, err := c.deploymentRecord.IsDeployed(validated.interpolatedTemplate.SHA(), c.releaseManager.List(), validated.extractedStemcell)
+		if err != nil {
+			return bosherr.WrapError(err, "Checking if deployment has changed")
+		}
+
+		if isDeployed {
+			c.ui.BeginLinef("No deployment, stemcell or release changes. Skipping deploy.\n")
+			return nil
+		}
+
+		if !cloud.UsesRegistry() {
+			c.logger.Info(c.logTag, "CPI passes agent settings to the agent, skipping the registry")
+
+			// neither the registry nor the SSH tunnel to it is needed
+			registryLessManifest := installationManifest
+			registryLessManifest.Registry = biinstallmanifest.Registry{}
+
+			return c.deploy(
+				cloud,
+				deploymentState,
+				stemcellManager,
+				cloudStemcell,
+				registryLessManifest,
+				validated.deploymentManifest,
+				validated.interpolatedTemplate,
+				stage)
+		}
+
+		return installation.WithRunningRegistry(c.logger, stage, func() error {
+			return c.deploy(
+				cloud,
+				deploymentState,
+				stemcellManager,
+				cloudStemcell,
+				installationManifest,
+				validated.deploymentManifest,
+				validated.interpolatedTemplate,
+				stage)
+		})
+	})
+
+	return err
+
+}
+
+// PrepareDryRun parses, validates and interpolates the manifest, renders the
+// job templates and prints the CPI calls a deploy would make. It neither
+// installs the CPI nor writes the deployment state. Packages are compiled on
+// the deployed VM, so they are not compiled in a dry run.
+func (c *DeploymentPreparer) PrepareDryRun(stage biui.Stage) error {
+	c.ui.BeginLinef("Deployment state: '%s'\n", c.deploymentStateService.Path())
+
+	// Load saves a new state file when there is none, which a dry run must not do.
+	// For the same reason the installation target, whose ID is saved in the
+	// state, is not determined and the default temp root is kept.
+	deploymentState := biconfig.DeploymentState{}
+	if c.deploymentStateService.Exists() {
+		err := c.withLockedState(func() error {
+			var err error
+			deploymentState, err = c.deploymentStateService.Load()
+			if err != nil {
+				return bosherr.WrapError(err, "Loading deployment state")
+			}
+			return nil
+		})
 		if err != nil {
 			return err
 		}
+	}
+
+	defer func() {
+		err := c.releaseManager.DeleteAll()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Deleting all extracted releases: %s", err.Error())
+		}
+	}()
 
-		for _, releaseRef := range releaseSetManifest.Releases {
-			err = c.releaseFetcher.DownloadAndExtract(releaseRef, stage)
+	extractedStemcell, deploymentManifest, installationManifest, _, err := c.validate(stage)
+	if err != nil {
+		return err
+	}
+	defer c.cleanupStemcell(extractedStemcell)
+
+	err = checkCPISwitch(deploymentState, installationManifest.CPIName)
+	if err != nil {
+		return err
+	}
+
+	err = stage.PerformComplex("rendering", func(stage biui.Stage) error {
+		for _, job := range deploymentManifest.Jobs {
+			err := stage.Perform(fmt.Sprintf("Rendering job templates for '%s'", job.Name), func() error {
+				return c.renderJobTemplates(deploymentManifest, job)
+			})
 			if err != nil {
 				return err
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	calls, err := bidepl.Plan(deploymentManifest, deploymentState, extractedStemcell.Manifest(), c.skipDiskMigration)
+	if err != nil {
+		return bosherr.WrapError(err, "Planning CPI calls")
+	}
+
+	c.ui.BeginLinef("\nPlanned CPI calls:\n")
+	for _, call := range calls {
+		c.ui.PrintLinef("  %s", call)
+	}
+
+	return nil
+}
+
+// PrepareValidate runs every check of a deploy that changes neither the
+// environment nor its deployment state. Besides what a dry run validates,
+// including the schema of the manifest, local tarballs are verified against
+// their digests and the CPI is installed to a temp directory and asked for
+// its info. validate-env prepares it with strict job properties.
+func (c *DeploymentPreparer) PrepareValidate(stage biui.Stage) error {
+	c.ui.BeginLinef("Deployment state: '%s'\n", c.deploymentStateService.Path())
 
-		err := c.cpiInstaller.ValidateCpiRelease(installationManifest, stage)
+	// Load saves a new state file when there is none, which validating must not do
+	deploymentState := biconfig.DeploymentState{}
+	if c.deploymentStateService.Exists() {
+		err := c.withLockedState(func() error {
+			var err error
+			deploymentState, err = c.deploymentStateService.Load()
+			if err != nil {
+				return bosherr.WrapError(err, "Loading deployment state")
+			}
+			return nil
+		})
 		if err != nil {
 			return err
 		}
+	}
 
-		deploymentManifest, manifestSHA, err = c.deploymentManifestParser.GetDeploymentManifest(c.deploymentManifestPath, c.deploymentVars, c.deploymentOp, releaseSetManifest, stage)
+	defer func() {
+		err := c.releaseManager.DeleteAll()
 		if err != nil {
-			return err
+			c.logger.Warn(c.logTag, "Deleting all extracted releases: %s", err.Error())
 		}
+	}()
 
-		extractedStemcell, err = c.stemcellFetcher.GetStemcell(deploymentManifest, stage)
+	c.verifyDigests = true
+
+	extractedStemcell, _, installationManifest, _, err := c.validate(stage)
+	if err != nil {
 		return err
+	}
+	defer c.cleanupStemcell(extractedStemcell)
+
+	// the CPI is installed next to, not into, the installation of the deployment
+	path, err := c.fs.TempDir("bosh-validate-installation")
+	if err != nil {
+		return bosherr.WrapError(err, "Creating temp installation")
+	}
+	defer func() {
+		err := c.fs.RemoveAll(path)
+		if err != nil {
+			c.logger.Warn(c.logTag, "Deleting temp installation: %s", err.Error())
+		}
+	}()
+
+	target := biinstall.NewTarget(path)
+
+	return c.cpiInstaller.WithInstalledCpiRelease(installationManifest, target, stage, func(installation biinstall.Installation) error {
+		return stage.Perform("Checking CPI", func() error {
+			cloud, err := c.cloudFactory.NewCloud(installation, deploymentState.DirectorID, extractedStemcell.Manifest().APIVersion)
+			if err != nil {
+				return bosherr.WrapError(err, "Creating CPI client from CPI installation")
+			}
+			defer stopCPIPlugins(c.cloudFactory, c.logger, c.logTag)
+
+			_, err = cloud.Info()
+			if err != nil {
+				return bosherr.WrapError(err, "Calling CPI info")
+			}
+
+			return nil
+		})
 	})
+}
+
+// PrepareDiff parses, validates and interpolates the manifest and prints the
+// redacted changes from the manifest of the last deploy. Like a dry run, it
+// does not write the deployment state.
+func (c *DeploymentPreparer) PrepareDiff(stage biui.Stage) error {
+	c.ui.BeginLinef("Deployment state: '%s'\n", c.deploymentStateService.Path())
+
+	defer func() {
+		err := c.releaseManager.DeleteAll()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Deleting all extracted releases: %s", err.Error())
+		}
+	}()
+
+	extractedStemcell, deploymentManifest, _, _, err := c.validate(stage)
 	if err != nil {
 		return err
 	}
+	defer c.cleanupStemcell(extractedStemcell)
+
+	// loading the deployment state saves a new one when there is none
+	if !c.deploymentStateService.Exists() {
+		c.ui.BeginLinef("\nNo manifest has been deployed yet.\n")
+		return nil
+	}
+
+	var changes int
+	var found bool
+
+	err = c.withLockedState(func() error {
+		var err error
+		changes, found, err = c.printManifestDiff(deploymentManifest)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		c.ui.BeginLinef("\nNo manifest has been deployed yet.\n")
+	} else if changes == 0 {
+		c.ui.BeginLinef("\nNo manifest changes.\n")
+	}
+
+	return nil
+}
+
+// withLockedState holds the lock on the deployment state while fn reads it,
+// so that it is not read while another operation writes it.
+func (c *DeploymentPreparer) withLockedState(fn func() error) (err error) {
+	err = c.deploymentStateService.Lock()
+	if err != nil {
+		return bosherr.WrapError(err, "Locking deployment state")
+	}
+
 	defer func() {
-		deleteErr := extractedStemcell.Cleanup()
-		if deleteErr != nil {
-			c.logger.Warn(c.logTag, "Failed to delete extracted stemcell: %s", deleteErr.Error())
+		unlockErr := c.deploymentStateService.Unlock()
+		if unlockErr != nil && err == nil {
+			err = bosherr.WrapError(unlockErr, "Unlocking deployment state")
 		}
 	}()
 
-	isDeployed, err := c.deploymentRecord.IsDeployed(manifestSHA, c.releaseManager.List(), extractedStemcell)
+	return fn()
+}
+
+// printManifestDiff prints the redacted changes between the manifest of the
+// last deploy, when the deployment state records one, and the new manifest.
+func (c *DeploymentPreparer) printManifestDiff(deploymentManifest bideplmanifest.Manifest) (int, bool, error) {
+	deployedContents, found, err := c.deploymentRecord.FindManifest()
 	if err != nil {
-		return bosherr.WrapError(err, "Checking if deployment has changed")
+		return 0, false, bosherr.WrapError(err, "Finding deployed manifest")
+	}
+
+	if !found {
+		return 0, false, nil
+	}
+
+	deployedManifest, err := c.deploymentManifestParser.ParseDeployedManifest(deployedContents, c.deploymentVars, c.deploymentManifestPath)
+	if err != nil {
+		return 0, false, err
+	}
+
+	changes := bideplmanifest.Diff(deployedManifest, deploymentManifest)
+	if len(changes) == 0 {
+		return 0, true, nil
 	}
 
-	if isDeployed {
-		c.ui.BeginLinef("No deployment, stemcell or release changes. Skipping deploy.\n")
+	c.ui.BeginLinef("\nManifest changes:\n")
+
+	for _, change := range changes {
+		change = change.Redacted()
+
+		// whole networks, pools and jobs are only named, as their fields are not meant to be read
+		segments := strings.Split(change.Path, "/")
+		isEntry := len(segments) == 2 && segments[0] != "properties" && segments[0] != "update"
+
+		switch {
+		case change.Old == nil && isEntry:
+			c.ui.PrintLinef("  + %s", change.Path)
+		case change.New == nil && isEntry:
+			c.ui.PrintLinef("  - %s", change.Path)
+		case change.Old == nil:
+			c.ui.PrintLinef("  + %s: %v", change.Path, change.New)
+		case change.New == nil:
+			c.ui.PrintLinef("  - %s: %v", change.Path, change.Old)
+		default:
+			c.ui.PrintLinef("  ~ %s: %v -> %v", change.Path, change.Old, change.New)
+		}
+	}
+
+	return len(changes), true, nil
+}
+
+func (c *DeploymentPreparer) validate(stage biui.Stage) (
+	extractedStemcell bistemcell.ExtractedStemcell,
+	deploymentManifest bideplmanifest.Manifest,
+	installationManifest biinstallmanifest.Manifest,
+	interpolatedTemplate bidepltpl.InterpolatedTemplate,
+	err error,
+) {
+	err = stage.PerformComplex("validating", func(stage biui.Stage) error {
+		validated, err := c.validateInstallation(stage)
+		if err != nil {
+			return err
+		}
+
+		err = c.validateDeployment(validated, stage)
+		if err != nil {
+			c.cleanupStemcell(validated.extractedStemcell)
+			return err
+		}
+
+		extractedStemcell = validated.extractedStemcell
+		deploymentManifest = validated.deploymentManifest
+		installationManifest = validated.installationManifest
+		interpolatedTemplate = validated.interpolatedTemplate
+
 		return nil
+	})
+
+	return
+}
+
+// validation holds what validating the manifests yields. Only the CPI
+// release is extracted by validateInstallation, the other releases are
+// extracted and checked by validateDeployment.
+type validation struct {
+	releaseSetManifest   birelsetmanifest.Manifest
+	releaseTarballPaths  []string
+	extractedStemcell    bistemcell.ExtractedStemcell
+	deploymentManifest   bideplmanifest.Manifest
+	installationManifest biinstallmanifest.Manifest
+	interpolatedTemplate bidepltpl.InterpolatedTemplate
+}
+
+// validateInstallation parses the manifests, downloads the releases and the
+// stemcell and extracts the stemcell and the CPI release, which is all that
+// installing the CPI and uploading the stemcell need.
+func (c *DeploymentPreparer) validateInstallation(stage biui.Stage) (validated validation, err error) {
+	var releaseSetManifest birelsetmanifest.Manifest
+	releaseSetManifest, validated.installationManifest, err = c.releaseSetAndInstallationManifestParser.ReleaseSetAndInstallationManifest(c.deploymentManifestPath, c.deploymentVars, c.deploymentOp)
+	if err != nil {
+		return validation{}, err
 	}
+	validated.releaseSetManifest = releaseSetManifest
 
-	err = c.cpiInstaller.WithInstalledCpiRelease(installationManifest, target, stage, func(installation biinstall.Installation) error {
-		return installation.WithRunningRegistry(c.logger, stage, func() error {
-			return c.deploy(
-				installation,
-				deploymentState,
-				extractedStemcell,
-				installationManifest,
-				deploymentManifest,
-				manifestSHA,
-				stage)
+	validated.deploymentManifest, validated.interpolatedTemplate, err = c.deploymentManifestParser.GetDeploymentManifest(c.deploymentManifestPath, c.deploymentVars, c.deploymentOp, releaseSetManifest, stage)
+	if err != nil {
+		return validation{}, err
+	}
+
+	// the resource pool may use a CPI from the cpis section instead
+	validated.installationManifest, err = c.selectCPI(validated.installationManifest, validated.deploymentManifest)
+	if err != nil {
+		return validation{}, err
+	}
+
+	validated.deploymentManifest = c.vmStrategyForMbus(validated.deploymentManifest, validated.installationManifest.Mbus)
+
+	stemcellTarballPath, err := c.stemcellFetcher.GetTarball(validated.deploymentManifest, stage)
+	if err != nil {
+		return validation{}, err
+	}
+
+	validated.releaseTarballPaths = make([]string, len(releaseSetManifest.Releases))
+
+	for i, releaseRef := range releaseSetManifest.Releases {
+		validated.releaseTarballPaths[i], err = c.releaseFetcher.Download(releaseRef, stage)
+		if err != nil {
+			return validation{}, err
+		}
+	}
+
+	err = c.scratchSpaceChecker.Check(append([]string{stemcellTarballPath}, validated.releaseTarballPaths...))
+	if err != nil {
+		return validation{}, err
+	}
+
+	if c.verifyDigests {
+		err = stage.Perform("Verifying release and stemcell digests", func() error {
+			for i, releaseRef := range releaseSetManifest.Releases {
+				err := c.releaseFetcher.Verify(releaseRef, validated.releaseTarballPaths[i])
+				if err != nil {
+					return ValidationError{Err: err}
+				}
+			}
+
+			err := c.stemcellFetcher.VerifyTarball(validated.deploymentManifest, stemcellTarballPath)
+			if err != nil {
+				return ValidationError{Err: err}
+			}
+
+			return nil
 		})
+		if err != nil {
+			return validation{}, err
+		}
+	}
+
+	// the stemcell, usually the largest artifact, is extracted while the CPI
+	// release is, and only reported on once it has been validated
+	extractedStemcells := make(chan stemcellExtraction, 1)
+	go func() {
+		extractedStemcell, err := c.stemcellFetcher.Extract(stemcellTarballPath)
+		extractedStemcells <- stemcellExtraction{extractedStemcell: extractedStemcell, err: err}
+	}()
+
+	joined := false
+	defer func() {
+		if !joined {
+			extraction := <-extractedStemcells
+			if extraction.err == nil {
+				c.cleanupStemcell(extraction.extractedStemcell)
+			}
+		}
+	}()
+
+	for i, releaseRef := range releaseSetManifest.Releases {
+		if releaseRef.Name != validated.installationManifest.Template.Release {
+			continue
+		}
+
+		err = c.releaseFetcher.Extract(releaseRef, validated.releaseTarballPaths[i], stage)
+		if err != nil {
+			return validation{}, err
+		}
+	}
+
+	err = c.cpiInstaller.ValidateCpiRelease(validated.installationManifest, stage)
+	if err != nil {
+		return validation{}, err
+	}
+
+	err = stage.Perform("Validating stemcell", func() error {
+		extraction := <-extractedStemcells
+		joined = true
+
+		validated.extractedStemcell = extraction.extractedStemcell
+		return extraction.err
 	})
+	if err != nil {
+		return validation{}, err
+	}
 
-	return err
+	// jobs are rendered differently for Windows stemcells
+	validated.deploymentManifest = validated.deploymentManifest.WithStemcellOS(validated.extractedStemcell.Manifest().OS)
 
+	return validated, nil
 }
 
-func (c *DeploymentPreparer) deploy(
-	installation biinstall.Installation,
+// validateDeployment extracts the releases other than the CPI release and
+// checks the jobs of the deployment against them.
+func (c *DeploymentPreparer) validateDeployment(validated validation, stage biui.Stage) error {
+	for i, releaseRef := range validated.releaseSetManifest.Releases {
+		if releaseRef.Name == validated.installationManifest.Template.Release {
+			continue
+		}
+
+		err := c.releaseFetcher.Extract(releaseRef, validated.releaseTarballPaths[i], stage)
+		if err != nil {
+			return err
+		}
+	}
+
+	deploymentManifest := validated.deploymentManifest
+
+	err := c.deploymentManifestParser.ValidateReleaseJobs(deploymentManifest, stage)
+	if err != nil {
+		return err
+	}
+
+	err = c.validateCompiledPackages(deploymentManifest, validated.extractedStemcell)
+	if err != nil {
+		return err
+	}
+
+	if c.strictProperties {
+		err = stage.Perform("Validating job properties", func() error {
+			return c.validateJobProperties(deploymentManifest)
+		})
+		if err != nil {
+			return err
+		}
+
+		// the job list renderer is strict too, rendering fails on any
+		// lookup of a property that the job spec does not declare
+		for _, job := range deploymentManifest.Jobs {
+			err = stage.Perform(fmt.Sprintf("Rendering job templates for '%s'", job.Name), func() error {
+				return c.renderJobTemplates(deploymentManifest, job)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+type stemcellExtraction struct {
+	extractedStemcell bistemcell.ExtractedStemcell
+	err               error
+}
+
+// uploadStemcell uploads the stemcell while the releases of the deployment
+// are extracted and validated, whose steps are reported once the upload is
+// done. A stemcell uploaded for a deployment that turns out to be invalid
+// is deleted again. The stemcell of an interrupted deploy of the same
+// manifest is not uploaded again.
+func (c *DeploymentPreparer) uploadStemcell(
+	stemcellManager bistemcell.Manager,
 	deploymentState biconfig.DeploymentState,
-	extractedStemcell bistemcell.ExtractedStemcell,
-	installationManifest biinstallmanifest.Manifest,
-	deploymentManifest bideplmanifest.Manifest,
-	manifestSHA string,
+	validated validation,
 	stage biui.Stage,
-) (err error) {
-	cloud, err := c.cloudFactory.NewCloud(installation, deploymentState.DirectorID)
+) (bistemcell.CloudStemcell, error) {
+	validateStage, flush := biui.NewBufferedStage(stage)
+
+	validateErrs := make(chan error, 1)
+	go func() {
+		validateErrs <- validateStage.PerformComplex("validating deployment", func(stage biui.Stage) error {
+			return c.validateDeployment(validated, stage)
+		})
+	}()
+
+	cloudStemcell, uploadErr := c.resumeStemcell(stemcellManager, validated, stage)
+	if uploadErr == nil && cloudStemcell == nil {
+		cloudStemcell, uploadErr = stemcellManager.Upload(validated.extractedStemcell, stage)
+	}
+
+	validateErr := <-validateErrs
+	flush()
+
+	if validateErr != nil {
+		if uploadErr == nil && !hasStemcellRecord(deploymentState, cloudStemcell.CID()) {
+			err := stage.Perform(fmt.Sprintf("Deleting uploaded stemcell '%s'", cloudStemcell.CID()), cloudStemcell.Delete)
+			if err != nil {
+				c.logger.Warn(c.logTag, "Failed to delete uploaded stemcell: %s", err.Error())
+			}
+		}
+
+		return nil, validateErr
+	}
+
+	if uploadErr != nil {
+		return nil, uploadErr
+	}
+
+	return cloudStemcell, nil
+}
+
+// resumeStemcell returns the stemcell that an interrupted deploy of the
+// manifest uploaded, or nil when the stemcell still has to be uploaded.
+func (c *DeploymentPreparer) resumeStemcell(
+	stemcellManager bistemcell.Manager,
+	validated validation,
+	stage biui.Stage,
+) (bistemcell.CloudStemcell, error) {
+	checkpoint, found, err := c.checkpointRepo.Find()
 	if err != nil {
-		return bosherr.WrapError(err, "Creating CPI client from CPI installation")
+		return nil, bosherr.WrapError(err, "Finding deploy checkpoint")
 	}
 
-	stemcellManager := c.stemcellManagerFactory.NewManager(cloud)
+	if !found || checkpoint.ManifestSHA != validated.interpolatedTemplate.SHA() || !checkpoint.IsComplete(bidepl.CheckpointStemcellUploaded) {
+		return nil, nil
+	}
 
-	cloudStemcell, err := stemcellManager.Upload(extractedStemcell, stage)
+	currentStemcells, err := stemcellManager.FindCurrent()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Finding current stemcell")
+	}
+
+	unusedStemcells, err := stemcellManager.FindUnused()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Finding unused stemcells")
+	}
+
+	for _, cloudStemcell := range append(currentStemcells, unusedStemcells...) {
+		if cloudStemcell.CID() != checkpoint.StemcellCID {
+			continue
+		}
+
+		manifest := validated.extractedStemcell.Manifest()
+		stepName := fmt.Sprintf("Uploading stemcell '%s/%s'", manifest.Name, manifest.Version)
+		err = stage.Perform(stepName, func() error {
+			return biui.NewSkipStageError(bosherr.Errorf("Found stemcell of interrupted deploy: %s", checkpoint.StemcellCID), "Stemcell uploaded by interrupted deploy")
+		})
+
+		return cloudStemcell, err
+	}
+
+	return nil, nil
+}
+
+// hasStemcellRecord reports whether the deployment state records a stemcell
+// with the given CID, i.e. whether it was uploaded before this deploy.
+func hasStemcellRecord(deploymentState biconfig.DeploymentState, cid string) bool {
+	for _, record := range deploymentState.Stemcells {
+		if record.CID == cid {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selectCPI returns the installation manifest for the CPI named by the
+// resource pool of the service job, the cloud_provider CPI unless it names
+// one from the cpis section.
+func (c *DeploymentPreparer) selectCPI(installationManifest biinstallmanifest.Manifest, deploymentManifest bideplmanifest.Manifest) (biinstallmanifest.Manifest, error) {
+	resourcePool, err := deploymentManifest.ResourcePool(deploymentManifest.JobName())
+	if err != nil {
+		return biinstallmanifest.Manifest{}, ValidationError{Err: bosherr.WrapError(err, "Selecting CPI")}
+	}
+
+	cpiManifest, err := installationManifest.ForCPI(resourcePool.CPI)
+	if err != nil {
+		return biinstallmanifest.Manifest{}, ValidationError{Err: bosherr.WrapErrorf(err, "Selecting CPI for resource pool '%s'", resourcePool.Name)}
+	}
+
+	return cpiManifest, nil
+}
+
+// checkCPISwitch fails when the deployment switches to another CPI while the
+// deployment state still records cloud resources, as VMs, disks and stemcells
+// can only be deleted by the CPI that created them.
+func checkCPISwitch(deploymentState biconfig.DeploymentState, cpiName string) error {
+	if hasCloudResources(deploymentState) && deploymentState.CurrentCPI != cpiName {
+		return bosherr.Errorf("Changing the CPI of the deployment from %s to %s requires deleting it first",
+			cpiDescription(deploymentState.CurrentCPI), cpiDescription(cpiName))
+	}
+
+	return nil
+}
+
+// hasCloudResources reports whether the deployment state records VMs, disks
+// or stemcells created by a CPI.
+func hasCloudResources(deploymentState biconfig.DeploymentState) bool {
+	if deploymentState.CurrentVMCID != "" || deploymentState.ReplacedVMCID != "" || deploymentState.CurrentDiskID != "" {
+		return true
+	}
+
+	if len(deploymentState.CurrentNamedDiskIDs) > 0 || len(deploymentState.Disks) > 0 || len(deploymentState.Stemcells) > 0 {
+		return true
+	}
+
+	for _, instance := range deploymentState.Instances {
+		if instance.VMCID != "" || instance.ReplacedVMCID != "" || instance.DiskID != "" || len(instance.NamedDiskIDs) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func cpiDescription(name string) string {
+	if name == "" {
+		return "the cloud_provider CPI"
+	}
+	return fmt.Sprintf("CPI '%s'", name)
+}
+
+// vmStrategyForMbus falls back to the delete-create VM strategy when the
+// mbus URL points to the IP of the VM, which a new VM would only get once the
+// current VM is deleted.
+func (c *DeploymentPreparer) vmStrategyForMbus(deploymentManifest bideplmanifest.Manifest, mbus string) bideplmanifest.Manifest {
+	if deploymentManifest.Update.VMStrategy != bideplmanifest.VMStrategyCreateSwapDelete || biagent.IsNATSURL(mbus) {
+		return deploymentManifest
+	}
+
+	mbusURL, err := url.Parse(mbus)
+	if err != nil || net.ParseIP(mbusURL.Hostname()) == nil {
+		return deploymentManifest
+	}
+
+	c.ui.BeginLinef("The mbus URL points to the IP '%s' of the VM, using the 'delete-create' VM strategy.\n", mbusURL.Hostname())
+	deploymentManifest.Update.VMStrategy = bideplmanifest.VMStrategyDeleteCreate
+
+	return deploymentManifest
+}
+
+// validateCompiledPackages makes sure jobs from compiled releases can skip
+// package compilation on the deployed VM.
+func (c *DeploymentPreparer) validateCompiledPackages(deploymentManifest bideplmanifest.Manifest, extractedStemcell bistemcell.ExtractedStemcell) error {
+	releaseJobs := []bireljob.Job{}
+
+	for _, job := range deploymentManifest.Jobs {
+		for _, jobRef := range job.Templates {
+			releaseJob, err := c.releaseJobResolver.Resolve(jobRef.Name, jobRef.Release)
+			if err != nil {
+				return err
+			}
+			releaseJobs = append(releaseJobs, releaseJob)
+		}
+	}
+
+	err := bideplrel.ValidateCompiledPackages(releaseJobs, extractedStemcell.OsAndVersion())
+	if err != nil {
+		return ValidationError{Err: bosherr.WrapError(err, "Validating compiled packages")}
+	}
+
+	return nil
+}
+
+// validateJobProperties checks the properties of every instance group
+// against the specs of its release jobs before any template is rendered.
+func (c *DeploymentPreparer) validateJobProperties(deploymentManifest bideplmanifest.Manifest) error {
+	errs := []error{}
+
+	for _, job := range deploymentManifest.Jobs {
+		releaseJobs := []bireljob.Job{}
+		releaseJobProperties := map[string]*biproperty.Map{}
+
+		for _, jobRef := range job.Templates {
+			releaseJob, err := c.releaseJobResolver.Resolve(jobRef.Name, jobRef.Release)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Resolving job '%s' in release '%s'", jobRef.Name, jobRef.Release)
+			}
+			releaseJobs = append(releaseJobs, releaseJob)
+			releaseJobProperties[jobRef.Name] = jobRef.Properties
+		}
+
+		err := bideplrel.ValidateJobProperties(releaseJobs, releaseJobProperties, job.Properties, deploymentManifest.Properties)
+		if err != nil {
+			errs = append(errs, bosherr.WrapErrorf(err, "Instance group '%s'", job.Name))
+		}
+	}
+
+	if len(errs) > 0 {
+		return ValidationError{Err: bosherr.WrapError(bosherr.NewMultiError(errs...), "Validating job properties")}
+	}
+
+	return nil
+}
+
+func (c *DeploymentPreparer) cleanupStemcell(extractedStemcell bistemcell.ExtractedStemcell) {
+	deleteErr := extractedStemcell.Cleanup()
+	if deleteErr != nil {
+		c.logger.Warn(c.logTag, "Failed to delete extracted stemcell: %s", deleteErr.Error())
+	}
+}
+
+func (c *DeploymentPreparer) renderJobTemplates(deploymentManifest bideplmanifest.Manifest, job bideplmanifest.Job) error {
+	releaseJobs := []bireljob.Job{}
+	releaseJobProperties := map[string]*biproperty.Map{}
+
+	for _, jobRef := range job.Templates {
+		releaseJob, err := c.releaseJobResolver.Resolve(jobRef.Name, jobRef.Release)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Resolving job '%s' in release '%s'", jobRef.Name, jobRef.Release)
+		}
+		releaseJobs = append(releaseJobs, releaseJob)
+		releaseJobProperties[jobRef.Name] = jobRef.Properties
+	}
+
+	releaseJobLinks, err := deploymentManifest.ResolveLinks(job.Name, c.releaseJobResolver)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Resolving links for job '%s'", job.Name)
+	}
+
+	// the address is only known once the VM exists, use a static IP when there is one
+	address := ""
+	networkInterfaces, err := deploymentManifest.NetworkInterfaces(job.Name)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Finding networks for job '%s'", job.Name)
+	}
+	for _, networkInterface := range networkInterfaces {
+		if ip, ok := networkInterface["ip"].(string); ok && address == "" {
+			address = ip
+		}
+	}
+
+	stemcell, err := deploymentManifest.Stemcell(job.Name)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Finding stemcell for job '%s'", job.Name)
+	}
+
+	instance := bitemplate.InstanceSpec{
+		Name:     job.Name,
+		AZ:       job.InstanceAZ(0),
+		Address:  address,
+		Networks: bitemplate.NewNetworkSpecs(networkInterfaces),
+
+		DNSDomainName:   deploymentManifest.Features.DNSDomainName,
+		UseDNSAddresses: deploymentManifest.Features.UseDNSAddresses,
+	}
+
+	renderedJobList, err := c.jobListRenderer.Render(releaseJobs, releaseJobProperties, releaseJobLinks, job.Properties, deploymentManifest.Properties, deploymentManifest.Name, instance, stemcell.OS)
 	if err != nil {
 		return err
 	}
+	renderedJobList.DeleteSilently()
+
+	return nil
+}
+
+func (c *DeploymentPreparer) deploy(
+	cloud bicloud.Cloud,
+	deploymentState biconfig.DeploymentState,
+	stemcellManager bistemcell.Manager,
+	cloudStemcell bistemcell.CloudStemcell,
+	installationManifest biinstallmanifest.Manifest,
+	deploymentManifest bideplmanifest.Manifest,
+	interpolatedTemplate bidepltpl.InterpolatedTemplate,
+	stage biui.Stage,
+) (err error) {
+	if c.rollbackOnFailure {
+		defer func() {
+			if err == nil {
+				return
+			}
+
+			rollbackErr := c.rollback(cloud, deploymentState, stage)
+			if rollbackErr != nil {
+				err = bosherr.NewMultiError(err, bosherr.WrapError(rollbackErr, "Rolling back"))
+			}
+		}()
+	}
 
 	agentClient, err := c.agentClientFactory.NewAgentClient(deploymentState.DirectorID, installationManifest.Mbus, installationManifest.Cert.CA)
 	if err != nil {
 		return err
 	}
-	vmManager := c.vmManagerFactory.NewManager(cloud, agentClient)
+	vmManager := c.vmManagerFactories(0).NewManager(cloud, agentClient)
 
-	blobstore, err := c.blobstoreFactory.Create(installationManifest.Mbus, bihttpclient.CreateDefaultClientInsecureSkipVerify())
+	blobstore, err := newBlobstore(c.blobstoreFactory, installationManifest)
 	if err != nil {
 		return bosherr.WrapError(err, "Creating blobstore client")
 	}
 
+	instanceClients := func(index int, ip string) (bivm.Manager, biblobstore.Blobstore, error) {
+		if index == 0 {
+			return vmManager, blobstore, nil
+		}
+
+		return c.instanceClients(cloud, deploymentState.DirectorID, installationManifest, index, ip)
+	}
+
 	err = stage.PerformComplex("deploying", func(deployStage biui.Stage) error {
 		err = c.deploymentRecord.Clear()
 		if err != nil {
 			return bosherr.WrapError(err, "Clearing deployment record")
 		}
 
+		// an interrupted deploy of the same manifest and stemcell resumes from its checkpoint
+		err = c.checkpointRepo.Begin(interpolatedTemplate.SHA(), cloudStemcell.CID())
+		if err != nil {
+			return bosherr.WrapError(err, "Beginning deploy checkpoint")
+		}
+
+		err = c.checkpointRepo.Complete(bidepl.CheckpointStemcellUploaded)
+		if err != nil {
+			return bosherr.WrapError(err, "Saving deploy checkpoint")
+		}
+
 		_, err = c.deployer.Deploy(
 			cloud,
 			deploymentManifest,
 			cloudStemcell,
 			installationManifest.Registry,
-			vmManager,
-			blobstore,
+			instanceClients,
 			deployStage,
 		)
 		if err != nil {
 			return bosherr.WrapError(err, "Deploying")
 		}
 
-		err = c.deploymentRecord.Update(manifestSHA, c.releaseManager.List())
+		err = c.deploymentRecord.Update(interpolatedTemplate.SHA(), c.releaseManager.List())
+		if err != nil {
+			return bosherr.WrapError(err, "Updating deployment record")
+		}
+
+		err = c.deploymentRecord.UpdateManifest(interpolatedTemplate.Template())
 		if err != nil {
 			return bosherr.WrapError(err, "Updating deployment record")
 		}
 
+		err = c.checkpointRepo.Clear()
+		if err != nil {
+			return bosherr.WrapError(err, "Clearing deploy checkpoint")
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -277,3 +1173,86 @@ func (c *DeploymentPreparer) deploy(
 
 	return nil
 }
+
+// instanceClients returns the VM manager and blobstore of an instance other
+// than the first, whose agent listens on the static IP instead of the host of
+// the mbus URL.
+func (c *DeploymentPreparer) instanceClients(
+	cloud bicloud.Cloud,
+	directorID string,
+	installationManifest biinstallmanifest.Manifest,
+	index int,
+	ip string,
+) (bivm.Manager, biblobstore.Blobstore, error) {
+	mbus, err := instanceMbusURL(installationManifest.Mbus, ip)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	installationManifest.Mbus = mbus
+
+	agentClient, err := c.agentClientFactory.NewAgentClient(directorID, installationManifest.Mbus, installationManifest.Cert.CA)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blobstore, err := newBlobstore(c.blobstoreFactory, installationManifest)
+	if err != nil {
+		return nil, nil, bosherr.WrapError(err, "Creating blobstore client")
+	}
+
+	return c.vmManagerFactories(index).NewManager(cloud, agentClient), blobstore, nil
+}
+
+// instanceMbusURL returns the mbus URL with its host replaced by the IP, the
+// port and credentials being the same for the agents of every instance. A
+// nats:// mbus URL points to the NATS server shared by every agent.
+func instanceMbusURL(mbus, ip string) (string, error) {
+	if biagent.IsNATSURL(mbus) {
+		return mbus, nil
+	}
+
+	mbusURL, err := url.Parse(mbus)
+	if err != nil {
+		return "", bosherr.WrapError(err, "Parsing mbus URL")
+	}
+
+	if port := mbusURL.Port(); port != "" {
+		mbusURL.Host = net.JoinHostPort(ip, port)
+	} else {
+		mbusURL.Host = ip
+	}
+
+	return mbusURL.String(), nil
+}
+
+// newBlobstore returns the blobstore the agent uploads to and fetches from:
+// the configured remote blobstore if any, otherwise the agent's own blobstore
+// behind mbus.
+func newBlobstore(blobstoreFactory biblobstore.Factory, installationManifest biinstallmanifest.Manifest) (biblobstore.Blobstore, error) {
+	httpClient := bihttpclient.CreateDefaultClientInsecureSkipVerify()
+
+	if installationManifest.Blobstore.IsEmpty() {
+		return blobstoreFactory.Create(installationManifest.Mbus, httpClient)
+	}
+
+	return blobstoreFactory.CreateFromProvider(
+		installationManifest.Blobstore.Provider,
+		installationManifest.Blobstore.Options,
+		httpClient,
+	)
+}
+
+// stopCPIPlugins stops the CPI plugins started by the cloud factory, which
+// keep running until they are stopped.
+func stopCPIPlugins(cloudFactory bicloud.Factory, logger boshlog.Logger, logTag string) {
+	pluginStopper, ok := cloudFactory.(bicloud.PluginStopper)
+	if !ok {
+		return
+	}
+
+	err := pluginStopper.StopPlugins()
+	if err != nil {
+		logger.Warn(logTag, "Stopping CPI plugins: %s", err.Error())
+	}
+}
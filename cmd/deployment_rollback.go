@@ -0,0 +1,365 @@
+package cmd
+
+import (
+	"fmt"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	bicloud "github.com/cloudfoundry/bosh-cli/cloud"
+	biconfig "github.com/cloudfoundry/bosh-cli/config"
+	biui "github.com/cloudfoundry/bosh-cli/ui"
+)
+
+// rollback deletes the VMs, disks and stemcells recorded in the deployment
+// state since previousState was loaded, and saves previousState again, as
+// far as what it records still exists (see rollbackDeploymentState). It runs
+// even when the deploy was interrupted.
+func (c *DeploymentPreparer) rollback(cloud bicloud.Cloud, previousState biconfig.DeploymentState, stage biui.Stage) error {
+	// an interrupted stage would not run the first step of rolling back
+	stage = biui.NewUninterruptibleStage(stage)
+
+	return stage.PerformComplex("rolling back", func(rollbackStage biui.Stage) error {
+		currentState, err := c.deploymentStateService.Load()
+		if err != nil {
+			return bosherr.WrapError(err, "Loading deployment state")
+		}
+
+		restoredState := rollbackDeploymentState(previousState, currentState)
+
+		restoredVMCIDs := map[string]bool{}
+		for _, vmCID := range append(deploymentStateVMCIDs(restoredState), deploymentStateReplacedVMCIDs(restoredState)...) {
+			restoredVMCIDs[vmCID] = true
+		}
+
+		for _, vmCID := range append(deploymentStateVMCIDs(currentState), deploymentStateReplacedVMCIDs(currentState)...) {
+			if restoredVMCIDs[vmCID] {
+				continue
+			}
+
+			vmCID := vmCID
+			err = rollbackStage.Perform(fmt.Sprintf("Deleting VM '%s'", vmCID), func() error {
+				return skipNotFound(cloud.DeleteVM(vmCID), bicloud.VMNotFoundError, "VM Not Found")
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		restoredDiskCIDs := map[string]bool{}
+		for _, diskRecord := range restoredState.Disks {
+			restoredDiskCIDs[diskRecord.CID] = true
+		}
+
+		for _, diskRecord := range currentState.Disks {
+			if restoredDiskCIDs[diskRecord.CID] {
+				continue
+			}
+
+			diskCID := diskRecord.CID
+			err = rollbackStage.Perform(fmt.Sprintf("Deleting disk '%s'", diskCID), func() error {
+				return skipNotFound(cloud.DeleteDisk(diskCID), bicloud.DiskNotFoundError, "Disk Not Found")
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		restoredStemcellCIDs := map[string]bool{}
+		for _, stemcellRecord := range restoredState.Stemcells {
+			restoredStemcellCIDs[stemcellRecord.CID] = true
+		}
+
+		for _, stemcellRecord := range currentState.Stemcells {
+			if restoredStemcellCIDs[stemcellRecord.CID] {
+				continue
+			}
+
+			stemcellCID := stemcellRecord.CID
+			err = rollbackStage.Perform(fmt.Sprintf("Deleting stemcell '%s'", stemcellCID), func() error {
+				return skipNotFound(cloud.DeleteStemcell(stemcellCID), bicloud.StemcellNotFoundError, "Stemcell Not Found")
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return rollbackStage.Perform("Restoring deployment state", func() error {
+			err := c.deploymentStateService.Save(restoredState)
+			if err != nil {
+				return bosherr.WrapError(err, "Saving deployment state")
+			}
+
+			return nil
+		})
+	})
+}
+
+// rollbackDeploymentState returns previousState without the VMs, disks and
+// stemcells the deploy deleted, which cannot be restored. An instance whose
+// previous VM was deleted keeps the VM that replaced it, with its disks and
+// stemcell, and its previous disks are orphaned. A previous disk that was
+// deleted is replaced by the disk that replaced it. A disk that replaced a
+// disk orphaned by a migration is orphaned in turn, so that neither the
+// previous nor the migrated data is lost. Whenever the result differs from
+// the previous deployment, as well as when the deploy was replacing a VM
+// whose jobs are stopped, the manifest SHA is cleared so that the next
+// deploy updates the instances.
+func rollbackDeploymentState(previousState, currentState biconfig.DeploymentState) biconfig.DeploymentState {
+	restoredState := previousState
+	restoredState.Checkpoint = nil
+	restoredState.Instances = nil
+
+	previousVMCIDs := map[string]bool{}
+	for _, vmCID := range append(deploymentStateVMCIDs(previousState), deploymentStateReplacedVMCIDs(previousState)...) {
+		previousVMCIDs[vmCID] = true
+	}
+
+	currentVMCIDs := map[string]bool{}
+	for _, vmCID := range append(deploymentStateVMCIDs(currentState), deploymentStateReplacedVMCIDs(currentState)...) {
+		currentVMCIDs[vmCID] = true
+	}
+
+	// a VM being replaced has its jobs stopped and its disks detached
+	replacedVMCIDs := map[string]bool{}
+	for _, vmCID := range deploymentStateReplacedVMCIDs(currentState) {
+		replacedVMCIDs[vmCID] = true
+	}
+
+	currentDiskRecords := map[string]biconfig.DiskRecord{}
+	for _, diskRecord := range currentState.Disks {
+		currentDiskRecords[diskRecord.ID] = diskRecord
+	}
+
+	currentInstances := map[int]biconfig.InstanceRecord{}
+	for _, instanceRecord := range deploymentStateInstanceRecords(currentState) {
+		currentInstances[instanceRecord.Index] = instanceRecord
+	}
+
+	// the disks of the current state to keep, by ID, and whether to orphan them
+	keptDiskIDs := map[string]bool{}
+	keepCurrentStemcell := false
+
+	for _, previousInstance := range deploymentStateInstanceRecords(previousState) {
+		currentInstance := currentInstances[previousInstance.Index]
+		restoredInstance := previousInstance
+		restoredInstance.NamedDiskIDs = copyDiskIDs(previousInstance.NamedDiskIDs)
+
+		if restoredInstance.ReplacedVMCID != "" && !currentVMCIDs[restoredInstance.ReplacedVMCID] {
+			restoredInstance.ReplacedVMCID = ""
+			restoredInstance.ReplacedAgentID = ""
+		}
+
+		if previousInstance.VMCID != "" && !currentVMCIDs[previousInstance.VMCID] {
+			restoredState.CurrentManifestSHA = ""
+
+			if currentInstance.VMCID == "" || previousVMCIDs[currentInstance.VMCID] {
+				restoredInstance.VMCID = ""
+				restoredInstance.AgentID = ""
+				setDeploymentStateInstanceRecord(&restoredState, restoredInstance)
+				continue
+			}
+
+			for _, diskID := range instanceDiskIDs(previousInstance) {
+				if _, found := currentDiskRecords[diskID]; found {
+					keptDiskIDs[diskID] = true
+				}
+			}
+			for _, diskID := range instanceDiskIDs(currentInstance) {
+				keptDiskIDs[diskID] = false
+			}
+			keepCurrentStemcell = true
+
+			setDeploymentStateInstanceRecord(&restoredState, currentInstance)
+			continue
+		}
+
+		if replacedVMCIDs[previousInstance.VMCID] {
+			restoredState.CurrentManifestSHA = ""
+		}
+
+		currentDiskIDs := instanceDiskIDs(currentInstance)
+		for name, diskID := range instanceDiskIDs(previousInstance) {
+			currentDiskID := currentDiskIDs[name]
+
+			diskRecord, found := currentDiskRecords[diskID]
+			if !found {
+				restoredState.CurrentManifestSHA = ""
+
+				if _, found := currentDiskRecords[currentDiskID]; !found {
+					currentDiskID = ""
+				}
+				setInstanceDiskID(&restoredInstance, name, currentDiskID)
+
+				if currentDiskID != "" {
+					keptDiskIDs[currentDiskID] = false
+				}
+				continue
+			}
+
+			if diskRecord.Orphaned && currentDiskID != "" && currentDiskID != diskID {
+				keptDiskIDs[currentDiskID] = true
+			}
+		}
+
+		setDeploymentStateInstanceRecord(&restoredState, restoredInstance)
+	}
+
+	restoredState.Disks = []biconfig.DiskRecord{}
+	for _, diskRecord := range previousState.Disks {
+		if _, found := currentDiskRecords[diskRecord.ID]; !found {
+			continue
+		}
+
+		if orphaned, found := keptDiskIDs[diskRecord.ID]; found {
+			diskRecord.Orphaned = orphaned
+			delete(keptDiskIDs, diskRecord.ID)
+		}
+		restoredState.Disks = append(restoredState.Disks, diskRecord)
+	}
+	for _, diskRecord := range currentState.Disks {
+		if orphaned, found := keptDiskIDs[diskRecord.ID]; found {
+			diskRecord.Orphaned = orphaned
+			restoredState.Disks = append(restoredState.Disks, diskRecord)
+		}
+	}
+
+	if keepCurrentStemcell {
+		restoredState.CurrentStemcellID = currentState.CurrentStemcellID
+	}
+
+	restoredState.Stemcells = []biconfig.StemcellRecord{}
+	for _, stemcellRecord := range currentState.Stemcells {
+		if stemcellRecord.ID == restoredState.CurrentStemcellID || containsStemcellRecord(previousState.Stemcells, stemcellRecord.ID) {
+			restoredState.Stemcells = append(restoredState.Stemcells, stemcellRecord)
+		}
+	}
+
+	return restoredState
+}
+
+func deploymentStateVMCIDs(deploymentState biconfig.DeploymentState) []string {
+	vmCIDs := []string{}
+
+	if deploymentState.CurrentVMCID != "" {
+		vmCIDs = append(vmCIDs, deploymentState.CurrentVMCID)
+	}
+
+	for _, instanceRecord := range deploymentState.Instances {
+		if instanceRecord.VMCID != "" && instanceRecord.VMCID != deploymentState.CurrentVMCID {
+			vmCIDs = append(vmCIDs, instanceRecord.VMCID)
+		}
+	}
+
+	return vmCIDs
+}
+
+// deploymentStateReplacedVMCIDs returns the VMs recorded as being replaced,
+// which are deleted once the VMs replacing them are ready.
+func deploymentStateReplacedVMCIDs(deploymentState biconfig.DeploymentState) []string {
+	vmCIDs := []string{}
+
+	if deploymentState.ReplacedVMCID != "" {
+		vmCIDs = append(vmCIDs, deploymentState.ReplacedVMCID)
+	}
+
+	for _, instanceRecord := range deploymentState.Instances {
+		if instanceRecord.ReplacedVMCID != "" {
+			vmCIDs = append(vmCIDs, instanceRecord.ReplacedVMCID)
+		}
+	}
+
+	return vmCIDs
+}
+
+// skipNotFound skips the step of deleting a resource that is already gone.
+func skipNotFound(err error, notFoundErrorType string, skipReason string) error {
+	if cloudErr, ok := err.(bicloud.Error); ok && cloudErr.Type() == notFoundErrorType {
+		return biui.NewSkipStageError(cloudErr, skipReason)
+	}
+
+	return err
+}
+
+// deploymentStateInstanceRecords returns the VMs and disks of the instances
+// of the deployment state, starting with the first instance, whose VM and
+// disks are the current VM and disks.
+func deploymentStateInstanceRecords(deploymentState biconfig.DeploymentState) []biconfig.InstanceRecord {
+	firstInstance := biconfig.InstanceRecord{
+		Index:           0,
+		VMCID:           deploymentState.CurrentVMCID,
+		AgentID:         deploymentState.CurrentAgentID,
+		DiskID:          deploymentState.CurrentDiskID,
+		NamedDiskIDs:    deploymentState.CurrentNamedDiskIDs,
+		ReplacedVMCID:   deploymentState.ReplacedVMCID,
+		ReplacedAgentID: deploymentState.ReplacedAgentID,
+	}
+
+	return append([]biconfig.InstanceRecord{firstInstance}, deploymentState.Instances...)
+}
+
+func setDeploymentStateInstanceRecord(deploymentState *biconfig.DeploymentState, instanceRecord biconfig.InstanceRecord) {
+	if instanceRecord.Index > 0 {
+		deploymentState.Instances = append(deploymentState.Instances, instanceRecord)
+		return
+	}
+
+	deploymentState.CurrentVMCID = instanceRecord.VMCID
+	deploymentState.CurrentAgentID = instanceRecord.AgentID
+	deploymentState.CurrentDiskID = instanceRecord.DiskID
+	deploymentState.CurrentNamedDiskIDs = instanceRecord.NamedDiskIDs
+	deploymentState.ReplacedVMCID = instanceRecord.ReplacedVMCID
+	deploymentState.ReplacedAgentID = instanceRecord.ReplacedAgentID
+}
+
+// instanceDiskIDs returns the disk IDs of the instance by disk name, the
+// disk of the disk pool or persistent disk size under the empty name.
+func instanceDiskIDs(instanceRecord biconfig.InstanceRecord) map[string]string {
+	diskIDs := map[string]string{}
+
+	if instanceRecord.DiskID != "" {
+		diskIDs[""] = instanceRecord.DiskID
+	}
+
+	for name, diskID := range instanceRecord.NamedDiskIDs {
+		diskIDs[name] = diskID
+	}
+
+	return diskIDs
+}
+
+func setInstanceDiskID(instanceRecord *biconfig.InstanceRecord, name string, diskID string) {
+	if name == "" {
+		instanceRecord.DiskID = diskID
+		return
+	}
+
+	if diskID == "" {
+		delete(instanceRecord.NamedDiskIDs, name)
+		return
+	}
+
+	instanceRecord.NamedDiskIDs[name] = diskID
+}
+
+func copyDiskIDs(diskIDs map[string]string) map[string]string {
+	if diskIDs == nil {
+		return nil
+	}
+
+	copied := map[string]string{}
+	for name, diskID := range diskIDs {
+		copied[name] = diskID
+	}
+
+	return copied
+}
+
+func containsStemcellRecord(stemcellRecords []biconfig.StemcellRecord, id string) bool {
+	for _, stemcellRecord := range stemcellRecords {
+		if stemcellRecord.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshfu "github.com/cloudfoundry/bosh-utils/fileutil"
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"github.com/cppforlife/go-patch/patch"
+	"github.com/pivotal-golang/clock"
+
+	biconfig "github.com/cloudfoundry/bosh-cli/config"
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	bilog "github.com/cloudfoundry/bosh-cli/logger"
+	boshui "github.com/cloudfoundry/bosh-cli/ui"
+)
+
+const diagnoseRedacted = "REDACTED"
+
+// cpiStderrRegexp matches the debug log entries of external CPI commands and
+// captures the command path and its stderr.
+var cpiStderrRegexp = regexp.MustCompile(
+	`(?s)when executing external CPI command '([^']*)'\nSTDIN: .*?\nSTDERR: '(.*?)'\n(?:\[|\z)`)
+
+type DiagnoseEnvCmd struct {
+	stateProvider func(string, string, boshtpl.Variables, patch.Op) biconfig.DeploymentStateService
+	envProvider   func(string, string, boshtpl.Variables, patch.Op) EnvAgent
+	compressor    boshfu.Compressor
+	timeService   clock.Clock
+	fs            boshsys.FileSystem
+	redactor      *bilog.Redactor
+	ui            boshui.UI
+}
+
+func NewDiagnoseEnvCmd(
+	stateProvider func(string, string, boshtpl.Variables, patch.Op) biconfig.DeploymentStateService,
+	envProvider func(string, string, boshtpl.Variables, patch.Op) EnvAgent,
+	compressor boshfu.Compressor,
+	timeService clock.Clock,
+	fs boshsys.FileSystem,
+	redactor *bilog.Redactor,
+	ui boshui.UI,
+) DiagnoseEnvCmd {
+	return DiagnoseEnvCmd{
+		stateProvider: stateProvider,
+		envProvider:   envProvider,
+		compressor:    compressor,
+		timeService:   timeService,
+		fs:            fs,
+		redactor:      redactor,
+		ui:            ui,
+	}
+}
+
+// Run collects what is needed to investigate a failed create-env or
+// delete-env into a single tarball. Parts that cannot be collected, e.g. the
+// agent logs of an environment whose VM is gone, are listed in errors.txt
+// instead of failing the command.
+func (c DiagnoseEnvCmd) Run(opts DiagnoseEnvOpts) error {
+	bundleDir, err := c.fs.TempDir("bosh-diagnose-env")
+	if err != nil {
+		return bosherr.WrapError(err, "Creating bundle directory")
+	}
+
+	defer c.fs.RemoveAll(bundleDir)
+
+	var failures []string
+
+	collectors := []struct {
+		Name    string
+		Collect func(string, DiagnoseEnvOpts) error
+	}{
+		{"run log", c.collectRunLog},
+		{"deployment state", c.collectState},
+		{"agent logs", c.collectAgentLogs},
+	}
+
+	for _, collector := range collectors {
+		err := collector.Collect(bundleDir, opts)
+		if err != nil {
+			failure := fmt.Sprintf("Collecting %s: %s", collector.Name, err)
+			c.ui.ErrorLinef("%s", failure)
+			failures = append(failures, failure)
+		}
+	}
+
+	if len(failures) > 0 {
+		err = c.fs.WriteFileString(filepath.Join(bundleDir, "errors.txt"), strings.Join(failures, "\n")+"\n")
+		if err != nil {
+			return bosherr.WrapError(err, "Writing errors")
+		}
+	}
+
+	manifestName := filepath.Base(opts.Args.Manifest.Path)
+	prefix := strings.TrimSuffix(manifestName, filepath.Ext(manifestName))
+	tsSuffix := strings.Replace(c.timeService.Now().Format("20060102-150405.999999999"), ".", "-", -1)
+
+	dstFilePath := filepath.Join(opts.Directory.Path, fmt.Sprintf("%s-diagnose-%s.tgz", prefix, tsSuffix))
+
+	c.ui.PrintLinef("Writing diagnostics to '%s'...", dstFilePath)
+
+	tarballPath, err := c.compressor.CompressFilesInDir(bundleDir)
+	if err != nil {
+		return bosherr.WrapError(err, "Compressing diagnostics")
+	}
+
+	err = boshfu.NewFileMover(c.fs).Move(tarballPath, dstFilePath)
+	if err != nil {
+		_ = c.compressor.CleanUp(tarballPath)
+		return bosherr.WrapErrorf(err, "Moving to final destination")
+	}
+
+	return nil
+}
+
+// collectRunLog copies the log of the failed run, and the stderr of the CPI
+// commands found in it, with secrets masked: the log may come from a run with
+// --redact=false. The CLI only logs CPI output at the debug level.
+func (c DiagnoseEnvCmd) collectRunLog(bundleDir string, opts DiagnoseEnvOpts) error {
+	if len(opts.LogPath) == 0 {
+		return bosherr.Error("No run log given with --log or BOSH_LOG_PATH")
+	}
+
+	runLog, err := c.fs.ReadFileString(opts.LogPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading run log '%s'", opts.LogPath)
+	}
+
+	err = c.fs.WriteFileString(filepath.Join(bundleDir, "bosh.log"), c.redactor.Redact(runLog))
+	if err != nil {
+		return bosherr.WrapError(err, "Writing run log")
+	}
+
+	var cpiStderr string
+
+	for _, match := range cpiStderrRegexp.FindAllStringSubmatch(runLog, -1) {
+		if stderr := strings.TrimSpace(match[2]); len(stderr) > 0 {
+			cpiStderr += fmt.Sprintf("CPI command '%s':\n%s\n\n", match[1], stderr)
+		}
+	}
+
+	if len(cpiStderr) > 0 {
+		err = c.fs.WriteFileString(filepath.Join(bundleDir, "cpi-stderr.log"), c.redactor.Redact(cpiStderr))
+		if err != nil {
+			return bosherr.WrapError(err, "Writing CPI stderr")
+		}
+	}
+
+	return nil
+}
+
+// collectState copies the deployment state without the manifest and the disk
+// cloud properties, which may hold credentials.
+func (c DiagnoseEnvCmd) collectState(bundleDir string, opts DiagnoseEnvOpts) error {
+	deploymentStateService := c.stateProvider(
+		opts.Args.Manifest.Path, opts.StatePath, opts.VarFlags.AsVariables(), opts.OpsFlags.AsOp())
+
+	if !deploymentStateService.Exists() {
+		return bosherr.Errorf("Deployment state file '%s' not found", deploymentStateService.Path())
+	}
+
+	state, err := deploymentStateService.Load()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading deployment state")
+	}
+
+	if len(state.CurrentManifest) > 0 {
+		state.CurrentManifest = diagnoseRedacted
+	}
+
+	for i, disk := range state.Disks {
+		if disk.CloudProperties == nil {
+			continue
+		}
+
+		redactedProps := biproperty.Map{}
+		for key := range disk.CloudProperties {
+			redactedProps[key] = diagnoseRedacted
+		}
+		state.Disks[i].CloudProperties = redactedProps
+	}
+
+	stateBytes, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling deployment state")
+	}
+
+	err = c.fs.WriteFile(filepath.Join(bundleDir, "state.json"), stateBytes)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing deployment state")
+	}
+
+	return nil
+}
+
+// collectAgentLogs fetches the agent logs of every instance, those of the
+// first one into agent-logs.tgz and the others into agent-logs-<index>.tgz.
+func (c DiagnoseEnvCmd) collectAgentLogs(bundleDir string, opts DiagnoseEnvOpts) error {
+	agent := c.envProvider(
+		opts.Args.Manifest.Path, opts.StatePath, opts.VarFlags.AsVariables(), opts.OpsFlags.AsOp())
+
+	indexes, err := agent.InstanceIndexes()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, index := range indexes {
+		name := "agent-logs.tgz"
+		if index > 0 {
+			name = fmt.Sprintf("agent-logs-%d.tgz", index)
+		}
+
+		blob, err := agent.FetchLogs(index, nil, true)
+		if err != nil {
+			errs = append(errs, bosherr.WrapErrorf(err, "Instance '%d'", index))
+			continue
+		}
+
+		err = boshfu.NewFileMover(c.fs).Move(blob.Path(), filepath.Join(bundleDir, name))
+		if err != nil {
+			blob.DeleteSilently()
+			errs = append(errs, bosherr.WrapErrorf(err, "Moving agent logs of instance '%d'", index))
+		}
+	}
+
+	if len(errs) > 0 {
+		return bosherr.NewMultiError(errs...)
+	}
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"github.com/cppforlife/go-patch/patch"
+
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	boshui "github.com/cloudfoundry/bosh-cli/ui"
+)
+
+type DiffEnvCmd struct {
+	ui          boshui.UI
+	envProvider func(string, string, boshtpl.Variables, patch.Op) DeploymentPreparer
+}
+
+func NewDiffEnvCmd(ui boshui.UI, envProvider func(string, string, boshtpl.Variables, patch.Op) DeploymentPreparer) *DiffEnvCmd {
+	return &DiffEnvCmd{ui: ui, envProvider: envProvider}
+}
+
+func (c *DiffEnvCmd) Run(stage boshui.Stage, opts DiffEnvOpts) error {
+	c.ui.BeginLinef("Deployment manifest: '%s'\n", opts.Args.Manifest.Path)
+
+	depPreparer := c.envProvider(
+		opts.Args.Manifest.Path, opts.StatePath, opts.VarFlags.AsVariables(), opts.OpsFlags.AsOp())
+
+	return depPreparer.PrepareDiff(stage)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"github.com/cppforlife/go-patch/patch"
+
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	boshui "github.com/cloudfoundry/bosh-cli/ui"
+	boshtbl "github.com/cloudfoundry/bosh-cli/ui/table"
+)
+
+type DisksEnvCmd struct {
+	envProvider func(string, string, boshtpl.Variables, patch.Op) EnvDisks
+	ui          boshui.UI
+}
+
+func NewDisksEnvCmd(envProvider func(string, string, boshtpl.Variables, patch.Op) EnvDisks, ui boshui.UI) DisksEnvCmd {
+	return DisksEnvCmd{envProvider: envProvider, ui: ui}
+}
+
+func (c DisksEnvCmd) Run(opts DisksEnvOpts) error {
+	envDisks := c.envProvider(
+		opts.Args.Manifest.Path, opts.StatePath, opts.VarFlags.AsVariables(), opts.OpsFlags.AsOp())
+
+	disks, err := envDisks.Disks()
+	if err != nil {
+		return err
+	}
+
+	table := boshtbl.Table{
+		Content: "disks",
+		Header: []boshtbl.Header{
+			boshtbl.NewHeader("Disk CID"),
+			boshtbl.NewHeader("Size"),
+			boshtbl.NewHeader("State"),
+		},
+		SortBy:         []boshtbl.ColumnSort{{Column: 0, Asc: true}},
+		WrapToTerminal: true,
+	}
+
+	for _, d := range disks {
+		if opts.Orphaned && !d.Orphaned {
+			continue
+		}
+
+		state := "in use"
+		if d.Orphaned {
+			state = "orphaned"
+		}
+
+		table.Rows = append(table.Rows, []boshtbl.Value{
+			boshtbl.NewValueString(d.CID),
+			boshtbl.NewValueMegaBytes(uint64(d.Size)),
+			boshtbl.NewValueString(state),
+		})
+	}
+
+	c.ui.PrintTable(table)
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package cmd_test
+
+import (
+	"errors"
+
+	"github.com/cppforlife/go-patch/patch"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/cmd"
+	mock_cmd "github.com/cloudfoundry/bosh-cli/cmd/mocks"
+	biconfig "github.com/cloudfoundry/bosh-cli/config"
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	fakeui "github.com/cloudfoundry/bosh-cli/ui/fakes"
+	boshtbl "github.com/cloudfoundry/bosh-cli/ui/table"
+)
+
+var _ = Describe("DisksEnvCmd", func() {
+	var mockCtrl *gomock.Controller
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	var (
+		mockEnvDisks *mock_cmd.MockEnvDisks
+		ui           *fakeui.FakeUI
+		command      DisksEnvCmd
+
+		opts  DisksEnvOpts
+		disks []biconfig.DiskRecord
+	)
+
+	BeforeEach(func() {
+		mockEnvDisks = mock_cmd.NewMockEnvDisks(mockCtrl)
+		ui = &fakeui.FakeUI{}
+
+		envProvider := func(_ string, _ string, _ boshtpl.Variables, _ patch.Op) EnvDisks {
+			return mockEnvDisks
+		}
+
+		command = NewDisksEnvCmd(envProvider, ui)
+
+		opts = DisksEnvOpts{
+			Args: DisksEnvArgs{
+				Manifest: FileBytesWithPathArg{Path: "/path/to/bosh.yml"},
+			},
+		}
+
+		disks = []biconfig.DiskRecord{
+			{ID: "fake-disk-id-1", CID: "fake-disk-cid-1", Size: 1024},
+			{ID: "fake-disk-id-2", CID: "fake-disk-cid-2", Size: 2048, Orphaned: true},
+		}
+	})
+
+	act := func() error { return command.Run(opts) }
+
+	It("lists the disks in use and the orphaned disks", func() {
+		mockEnvDisks.EXPECT().Disks().Return(disks, nil)
+
+		Expect(act()).ToNot(HaveOccurred())
+
+		Expect(ui.Tables).To(Equal([]boshtbl.Table{
+			{
+				Content: "disks",
+
+				Header: []boshtbl.Header{
+					boshtbl.NewHeader("Disk CID"),
+					boshtbl.NewHeader("Size"),
+					boshtbl.NewHeader("State"),
+				},
+
+				SortBy: []boshtbl.ColumnSort{{Column: 0, Asc: true}},
+
+				WrapToTerminal: true,
+
+				Rows: [][]boshtbl.Value{
+					{
+						boshtbl.NewValueString("fake-disk-cid-1"),
+						boshtbl.NewValueMegaBytes(1024),
+						boshtbl.NewValueString("in use"),
+					},
+					{
+						boshtbl.NewValueString("fake-disk-cid-2"),
+						boshtbl.NewValueMegaBytes(2048),
+						boshtbl.NewValueString("orphaned"),
+					},
+				},
+			},
+		}))
+	})
+
+	It("lists only the orphaned disks when requested", func() {
+		opts.Orphaned = true
+
+		mockEnvDisks.EXPECT().Disks().Return(disks, nil)
+
+		Expect(act()).ToNot(HaveOccurred())
+
+		Expect(ui.Tables).To(HaveLen(1))
+		Expect(ui.Tables[0].Rows).To(Equal([][]boshtbl.Value{
+			{
+				boshtbl.NewValueString("fake-disk-cid-2"),
+				boshtbl.NewValueMegaBytes(2048),
+				boshtbl.NewValueString("orphaned"),
+			},
+		}))
+	})
+
+	It("returns an error when the disks cannot be found", func() {
+		mockEnvDisks.EXPECT().Disks().Return(nil, errors.New("fake-err"))
+
+		err := act()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("fake-err"))
+	})
+})
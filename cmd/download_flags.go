@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	bitarball "github.com/cloudfoundry/bosh-cli/installation/tarball"
+)
+
+func (f DownloadFlags) AsDownloadOpts() bitarball.DownloadOpts {
+	return bitarball.DownloadOpts{
+		ProxyURL: f.Proxy,
+
+		S3AccessKeyID:     f.S3AccessKeyID,
+		S3SecretAccessKey: f.S3SecretAccessKey,
+		S3Region:          f.S3Region,
+
+		GCSAccessKeyID:     f.GCSAccessKeyID,
+		GCSSecretAccessKey: f.GCSSecretAccessKey,
+	}
+}
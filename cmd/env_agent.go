@@ -0,0 +1,345 @@
+package cmd
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"github.com/cppforlife/go-patch/patch"
+
+	biblobstore "github.com/cloudfoundry/bosh-cli/blobstore"
+	biconfig "github.com/cloudfoundry/bosh-cli/config"
+	biagent "github.com/cloudfoundry/bosh-cli/deployment/agent"
+	boshdir "github.com/cloudfoundry/bosh-cli/director"
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	biinstallmanifest "github.com/cloudfoundry/bosh-cli/installation/manifest"
+)
+
+// EnvAgent talks to the agents of the VMs created by create-env, which are
+// located through the deployment state and the mbus URL of the manifest. The
+// first instance has index 0.
+type EnvAgent interface {
+	InstanceIndexes() ([]int, error)
+
+	SetUpSSH(index int, sshOpts boshdir.SSHOpts) (boshdir.SSHResult, error)
+	CleanUpSSH(index int, sshOpts boshdir.SSHOpts) error
+	FetchLogs(index int, filters []string, agent bool) (biblobstore.LocalBlob, error)
+	InstanceInfo(index int) (EnvInstanceInfo, error)
+	RunErrand(index int, jobName string) (boshdir.ErrandResult, error)
+}
+
+// EnvInstanceInfo describes the environment VM as reported by its agent.
+type EnvInstanceInfo struct {
+	boshdir.VMInfo
+
+	BoshProtocol string
+}
+
+type envAgent struct {
+	deploymentStateService                  biconfig.DeploymentStateService
+	releaseSetAndInstallationManifestParser ReleaseSetAndInstallationManifestParser
+	clientFactory                           biagent.ClientFactory
+	blobstoreFactory                        biblobstore.Factory
+	fs                                      boshsys.FileSystem
+	deploymentManifestPath                  string
+	deploymentVars                          boshtpl.Variables
+	deploymentOp                            patch.Op
+
+	loaded               bool
+	deploymentState      biconfig.DeploymentState
+	installationManifest biinstallmanifest.Manifest
+	instances            map[int]envInstance
+}
+
+// envInstance is an instance of the environment together with the client of
+// its agent and the installation manifest with the mbus URL of that agent.
+type envInstance struct {
+	record               biconfig.InstanceRecord
+	client               biagent.Client
+	installationManifest biinstallmanifest.Manifest
+	host                 string
+}
+
+func NewEnvAgent(
+	deploymentStateService biconfig.DeploymentStateService,
+	releaseSetAndInstallationManifestParser ReleaseSetAndInstallationManifestParser,
+	clientFactory biagent.ClientFactory,
+	blobstoreFactory biblobstore.Factory,
+	fs boshsys.FileSystem,
+	deploymentManifestPath string,
+	deploymentVars boshtpl.Variables,
+	deploymentOp patch.Op,
+) EnvAgent {
+	return &envAgent{
+		deploymentStateService:                  deploymentStateService,
+		releaseSetAndInstallationManifestParser: releaseSetAndInstallationManifestParser,
+		clientFactory:                           clientFactory,
+		blobstoreFactory:                        blobstoreFactory,
+		fs:                                      fs,
+		deploymentManifestPath:                  deploymentManifestPath,
+		deploymentVars:                          deploymentVars,
+		deploymentOp:                            deploymentOp,
+
+		instances: map[int]envInstance{},
+	}
+}
+
+// InstanceIndexes returns the indexes of the instances that have a VM.
+func (a *envAgent) InstanceIndexes() ([]int, error) {
+	err := a.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var indexes []int
+
+	for _, record := range deploymentStateInstanceRecords(a.deploymentState) {
+		if record.VMCID != "" {
+			indexes = append(indexes, record.Index)
+		}
+	}
+
+	return indexes, nil
+}
+
+func (a *envAgent) SetUpSSH(index int, sshOpts boshdir.SSHOpts) (boshdir.SSHResult, error) {
+	instance, err := a.connect(index)
+	if err != nil {
+		return boshdir.SSHResult{}, err
+	}
+
+	result, err := instance.client.SetUpSSH(sshOpts.Username, sshOpts.PublicKey)
+	if err != nil {
+		return boshdir.SSHResult{}, bosherr.WrapError(err, "Setting up SSH")
+	}
+
+	host := boshdir.Host{
+		Job:       a.installationManifest.Name,
+		IndexOrID: strconv.Itoa(index),
+
+		Username:      sshOpts.Username,
+		Host:          instance.host,
+		HostPublicKey: result.HostPublicKey,
+	}
+
+	return boshdir.SSHResult{Hosts: []boshdir.Host{host}}, nil
+}
+
+func (a *envAgent) CleanUpSSH(index int, sshOpts boshdir.SSHOpts) error {
+	instance, err := a.connect(index)
+	if err != nil {
+		return err
+	}
+
+	err = instance.client.CleanUpSSH(sshOpts.Username)
+	if err != nil {
+		return bosherr.WrapError(err, "Cleaning up SSH")
+	}
+
+	return nil
+}
+
+// FetchLogs downloads the job logs, or the agent logs, matching the filters.
+// The caller is responsible for deleting the returned blob.
+func (a *envAgent) FetchLogs(index int, filters []string, agent bool) (biblobstore.LocalBlob, error) {
+	instance, err := a.connect(index)
+	if err != nil {
+		return nil, err
+	}
+
+	logType := "job"
+	if agent {
+		logType = "agent"
+	}
+
+	result, err := instance.client.FetchLogs(logType, filters)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Fetching logs")
+	}
+
+	blobstore, err := newBlobstore(a.blobstoreFactory, instance.installationManifest)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Creating blobstore client")
+	}
+
+	blob, err := blobstore.Get(result.BlobstoreID)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Downloading logs '%s'", result.BlobstoreID)
+	}
+
+	if len(result.SHA1) > 0 {
+		digest, err := boshcrypto.ParseMultipleDigest(result.SHA1)
+		if err == nil {
+			err = digest.VerifyFilePath(blob.Path(), a.fs)
+		}
+		if err != nil {
+			blob.DeleteSilently()
+			return nil, bosherr.WrapErrorf(err, "Verifying logs '%s'", result.BlobstoreID)
+		}
+	}
+
+	return blob, nil
+}
+
+func (a *envAgent) InstanceInfo(index int) (EnvInstanceInfo, error) {
+	instance, err := a.connect(index)
+	if err != nil {
+		return EnvInstanceInfo{}, err
+	}
+
+	state, err := instance.client.State()
+	if err != nil {
+		return EnvInstanceInfo{}, bosherr.WrapError(err, "Getting agent state")
+	}
+
+	info := boshdir.VMInfo{
+		AgentID: state.AgentID,
+
+		JobName:      state.Job.Name,
+		ID:           state.ID,
+		Index:        state.Index,
+		ProcessState: state.JobState,
+
+		VMID: instance.record.VMCID,
+
+		Processes: state.Processes,
+		Vitals:    state.Vitals,
+	}
+
+	if len(info.JobName) == 0 {
+		info.JobName = a.installationManifest.Name
+	}
+
+	for _, network := range state.Networks {
+		info.IPs = append(info.IPs, network.IP)
+	}
+
+	sort.Strings(info.IPs)
+
+	for _, disk := range a.deploymentState.Disks {
+		if disk.ID == instance.record.DiskID {
+			info.DiskID = disk.CID
+			info.DiskIDs = []string{disk.CID}
+		}
+	}
+
+	return EnvInstanceInfo{VMInfo: info, BoshProtocol: state.BoshProtocol}, nil
+}
+
+// RunErrand runs a release job installed on the VM as an errand and returns
+// its exit code and output once it exits.
+func (a *envAgent) RunErrand(index int, jobName string) (boshdir.ErrandResult, error) {
+	instance, err := a.connect(index)
+	if err != nil {
+		return boshdir.ErrandResult{}, err
+	}
+
+	result, err := instance.client.RunErrand(jobName)
+	if err != nil {
+		return boshdir.ErrandResult{}, bosherr.WrapErrorf(err, "Running errand '%s'", jobName)
+	}
+
+	return boshdir.ErrandResult{
+		ExitCode: result.ExitCode,
+		Stdout:   result.Stdout,
+		Stderr:   result.Stderr,
+	}, nil
+}
+
+// connect returns the instance with the index and the client of its agent,
+// which listens on the host of the mbus URL for the first instance and on the
+// static IP of the instance for the others.
+func (a *envAgent) connect(index int) (envInstance, error) {
+	if instance, found := a.instances[index]; found {
+		return instance, nil
+	}
+
+	err := a.load()
+	if err != nil {
+		return envInstance{}, err
+	}
+
+	var record biconfig.InstanceRecord
+	var found bool
+
+	for _, r := range deploymentStateInstanceRecords(a.deploymentState) {
+		if r.Index == index && r.VMCID != "" {
+			record, found = r, true
+		}
+	}
+
+	if !found {
+		return envInstance{}, bosherr.Errorf("Instance '%d' not found in deployment state '%s'", index, a.deploymentStateService.Path())
+	}
+
+	installationManifest := a.installationManifest
+
+	if index > 0 {
+		installationManifest.Mbus, err = instanceMbusURL(installationManifest.Mbus, record.IP)
+		if err != nil {
+			return envInstance{}, err
+		}
+	}
+
+	mbusURL, err := url.Parse(installationManifest.Mbus)
+	if err != nil {
+		return envInstance{}, bosherr.WrapErrorf(err, "Parsing mbus URL")
+	}
+
+	host := mbusURL.Hostname()
+	if index > 0 {
+		host = record.IP
+	}
+
+	client, err := a.clientFactory.NewClient(
+		a.deploymentState.DirectorID, installationManifest.Mbus, installationManifest.Cert.CA)
+	if err != nil {
+		return envInstance{}, bosherr.WrapError(err, "Creating agent client")
+	}
+
+	instance := envInstance{
+		record:               record,
+		client:               client,
+		installationManifest: installationManifest,
+		host:                 host,
+	}
+
+	a.instances[index] = instance
+
+	return instance, nil
+}
+
+// load reads the deployment state and the installation manifest once.
+func (a *envAgent) load() error {
+	if a.loaded {
+		return nil
+	}
+
+	if !a.deploymentStateService.Exists() {
+		return bosherr.Errorf("Deployment state '%s' does not exist", a.deploymentStateService.Path())
+	}
+
+	deploymentState, err := a.deploymentStateService.Load()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading deployment state")
+	}
+
+	if deploymentState.CurrentVMCID == "" {
+		return bosherr.Errorf("No VM found in deployment state '%s'", a.deploymentStateService.Path())
+	}
+
+	_, installationManifest, err := a.releaseSetAndInstallationManifestParser.ReleaseSetAndInstallationManifest(
+		a.deploymentManifestPath, a.deploymentVars, a.deploymentOp)
+	if err != nil {
+		return err
+	}
+
+	a.loaded = true
+	a.deploymentState = deploymentState
+	a.installationManifest = installationManifest
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	biconfig "github.com/cloudfoundry/bosh-cli/config"
+)
+
+// EnvDisks manages the persistent disks recorded in the deployment state of
+// the environment created by create-env.
+type EnvDisks interface {
+	// Disks returns the disks in use and the orphaned disks
+	Disks() ([]biconfig.DiskRecord, error)
+	// AttachDisk makes an orphaned disk the persistent disk of the VM of the
+	// instance with the given index again, orphaning the persistent disk it
+	// uses
+	AttachDisk(diskCID string, index int) error
+}
+
+type envDisks struct {
+	deploymentStateService biconfig.DeploymentStateService
+	diskRepoProvider       func(int) biconfig.DiskRepo
+	deploymentRepo         biconfig.DeploymentRepo
+}
+
+// NewEnvDisks returns the EnvDisks of the deployment state, with the disk
+// repo of each instance given by its index.
+func NewEnvDisks(
+	deploymentStateService biconfig.DeploymentStateService,
+	diskRepoProvider func(int) biconfig.DiskRepo,
+	deploymentRepo biconfig.DeploymentRepo,
+) EnvDisks {
+	return envDisks{
+		deploymentStateService: deploymentStateService,
+		diskRepoProvider:       diskRepoProvider,
+		deploymentRepo:         deploymentRepo,
+	}
+}
+
+func (d envDisks) Disks() (disks []biconfig.DiskRecord, err error) {
+	if !d.deploymentStateService.Exists() {
+		return nil, bosherr.Errorf("Deployment state file '%s' not found", d.deploymentStateService.Path())
+	}
+
+	err = d.deploymentStateService.Lock()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Locking deployment state")
+	}
+
+	defer func() {
+		unlockErr := d.deploymentStateService.Unlock()
+		if unlockErr != nil && err == nil {
+			err = bosherr.WrapError(unlockErr, "Unlocking deployment state")
+		}
+	}()
+
+	return d.diskRepoProvider(0).All()
+}
+
+// AttachDisk only updates the deployment state. The disk is attached when
+// the VM is recreated by the next create-env, which is no longer skipped.
+func (d envDisks) AttachDisk(diskCID string, index int) (err error) {
+	if !d.deploymentStateService.Exists() {
+		return bosherr.Errorf("Deployment state file '%s' not found", d.deploymentStateService.Path())
+	}
+
+	err = d.deploymentStateService.Lock()
+	if err != nil {
+		return bosherr.WrapError(err, "Locking deployment state")
+	}
+
+	defer func() {
+		unlockErr := d.deploymentStateService.Unlock()
+		if unlockErr != nil && err == nil {
+			err = bosherr.WrapError(unlockErr, "Unlocking deployment state")
+		}
+	}()
+
+	deploymentState, err := d.deploymentStateService.Load()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading deployment state")
+	}
+
+	// the first instance is recorded at the top level of the deployment state
+	if index != 0 && !d.hasInstance(deploymentState, index) {
+		return bosherr.Errorf("Instance %d not found in the deployment state", index)
+	}
+
+	diskRepo := d.diskRepoProvider(index)
+
+	diskRecord, found, err := diskRepo.Find(diskCID)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Finding disk '%s'", diskCID)
+	}
+
+	if !found {
+		return bosherr.Errorf("Disk '%s' not found in the deployment state", diskCID)
+	}
+
+	if !diskRecord.Orphaned {
+		return bosherr.Errorf("Disk '%s' is in use, only orphaned disks can be attached", diskCID)
+	}
+
+	currentDiskRecord, found, err := diskRepo.FindCurrent()
+	if err != nil {
+		return bosherr.WrapError(err, "Finding current disk")
+	}
+
+	if found {
+		err = diskRepo.Orphan(currentDiskRecord)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Orphaning disk '%s'", currentDiskRecord.CID)
+		}
+	}
+
+	err = diskRepo.UpdateCurrent(diskRecord.ID)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Updating current disk to '%s'", diskCID)
+	}
+
+	// forget the deployed manifest so that create-env recreates the VM
+	err = d.deploymentRepo.UpdateCurrent("")
+	if err != nil {
+		return bosherr.WrapError(err, "Clearing current deployment")
+	}
+
+	return nil
+}
+
+func (d envDisks) hasInstance(deploymentState biconfig.DeploymentState, index int) bool {
+	for _, instance := range deploymentState.Instances {
+		if instance.Index == index {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,140 @@
+package cmd_test
+
+import (
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/cmd"
+	biconfig "github.com/cloudfoundry/bosh-cli/config"
+)
+
+var _ = Describe("EnvDisks", func() {
+	var (
+		deploymentStateService biconfig.DeploymentStateService
+
+		envDisks EnvDisks
+	)
+
+	BeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		fs := fakesys.NewFakeFileSystem()
+		uuidGenerator := &fakeuuid.FakeGenerator{}
+
+		deploymentStateService = biconfig.NewFileSystemDeploymentStateService(
+			fs, uuidGenerator, logger, "/deployment-dir/fake-deployment-manifest-state.json")
+
+		envDisks = NewEnvDisks(
+			deploymentStateService,
+			func(index int) biconfig.DiskRepo {
+				return biconfig.NewInstanceDiskRepo(deploymentStateService, uuidGenerator, index)
+			},
+			biconfig.NewDeploymentRepo(deploymentStateService),
+		)
+	})
+
+	Context("when the environment has been deployed", func() {
+		BeforeEach(func() {
+			err := deploymentStateService.Save(biconfig.DeploymentState{
+				DirectorID:         "fake-director-id",
+				CurrentManifestSHA: "fake-manifest-sha",
+				CurrentDiskID:      "fake-current-disk-id",
+				Disks: []biconfig.DiskRecord{
+					{ID: "fake-current-disk-id", CID: "fake-current-disk-cid", Size: 1024},
+					{ID: "fake-orphaned-disk-id", CID: "fake-orphaned-disk-cid", Size: 2048, Orphaned: true},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		Describe("Disks", func() {
+			It("returns the disks in use and the orphaned disks", func() {
+				disks, err := envDisks.Disks()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(disks).To(Equal([]biconfig.DiskRecord{
+					{ID: "fake-current-disk-id", CID: "fake-current-disk-cid", Size: 1024},
+					{ID: "fake-orphaned-disk-id", CID: "fake-orphaned-disk-cid", Size: 2048, Orphaned: true},
+				}))
+			})
+		})
+
+		Describe("AttachDisk", func() {
+			It("makes the orphaned disk current and orphans the current disk", func() {
+				err := envDisks.AttachDisk("fake-orphaned-disk-cid", 0)
+				Expect(err).ToNot(HaveOccurred())
+
+				deploymentState, err := deploymentStateService.Load()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentState.CurrentDiskID).To(Equal("fake-orphaned-disk-id"))
+				Expect(deploymentState.Disks).To(Equal([]biconfig.DiskRecord{
+					{ID: "fake-current-disk-id", CID: "fake-current-disk-cid", Size: 1024, Orphaned: true},
+					{ID: "fake-orphaned-disk-id", CID: "fake-orphaned-disk-cid", Size: 2048},
+				}))
+			})
+
+			It("clears the deployed manifest so that the next create-env attaches the disk", func() {
+				err := envDisks.AttachDisk("fake-orphaned-disk-cid", 0)
+				Expect(err).ToNot(HaveOccurred())
+
+				deploymentState, err := deploymentStateService.Load()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentState.CurrentManifestSHA).To(BeEmpty())
+			})
+
+			It("does not attach a disk in use", func() {
+				err := envDisks.AttachDisk("fake-current-disk-cid", 0)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Disk 'fake-current-disk-cid' is in use, only orphaned disks can be attached"))
+			})
+
+			It("makes the orphaned disk current for the instance with the given index", func() {
+				deploymentState, err := deploymentStateService.Load()
+				Expect(err).ToNot(HaveOccurred())
+				deploymentState.Disks = append(deploymentState.Disks,
+					biconfig.DiskRecord{ID: "fake-instance-disk-id", CID: "fake-instance-disk-cid", Size: 1024})
+				deploymentState.Instances = []biconfig.InstanceRecord{{Index: 1, DiskID: "fake-instance-disk-id"}}
+				err = deploymentStateService.Save(deploymentState)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = envDisks.AttachDisk("fake-orphaned-disk-cid", 1)
+				Expect(err).ToNot(HaveOccurred())
+
+				deploymentState, err = deploymentStateService.Load()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentState.CurrentDiskID).To(Equal("fake-current-disk-id"))
+				Expect(deploymentState.Instances).To(Equal([]biconfig.InstanceRecord{{Index: 1, DiskID: "fake-orphaned-disk-id"}}))
+				Expect(deploymentState.Disks).To(Equal([]biconfig.DiskRecord{
+					{ID: "fake-current-disk-id", CID: "fake-current-disk-cid", Size: 1024},
+					{ID: "fake-orphaned-disk-id", CID: "fake-orphaned-disk-cid", Size: 2048},
+					{ID: "fake-instance-disk-id", CID: "fake-instance-disk-cid", Size: 1024, Orphaned: true},
+				}))
+			})
+
+			It("returns an error when the instance is not in the deployment state", func() {
+				err := envDisks.AttachDisk("fake-orphaned-disk-cid", 2)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Instance 2 not found in the deployment state"))
+			})
+
+			It("returns an error when the disk is not in the deployment state", func() {
+				err := envDisks.AttachDisk("fake-unknown-disk-cid", 0)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Disk 'fake-unknown-disk-cid' not found in the deployment state"))
+			})
+		})
+	})
+
+	Context("when the deployment state file does not exist", func() {
+		It("returns an error", func() {
+			_, err := envDisks.Disks()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Deployment state file '/deployment-dir/fake-deployment-manifest-state.json' not found"))
+
+			err = envDisks.AttachDisk("fake-disk-cid", 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("not found"))
+		})
+	})
+})
@@ -13,6 +13,7 @@ import (
 	biconfig "github.com/cloudfoundry/bosh-cli/config"
 	bicpirel "github.com/cloudfoundry/bosh-cli/cpi/release"
 	bidepl "github.com/cloudfoundry/bosh-cli/deployment"
+	biagent "github.com/cloudfoundry/bosh-cli/deployment/agent"
 	bidisk "github.com/cloudfoundry/bosh-cli/deployment/disk"
 	biinstance "github.com/cloudfoundry/bosh-cli/deployment/instance"
 	biinstancestate "github.com/cloudfoundry/bosh-cli/deployment/instance/state"
@@ -22,7 +23,7 @@ import (
 	bidepltpl "github.com/cloudfoundry/bosh-cli/deployment/template"
 	bivm "github.com/cloudfoundry/bosh-cli/deployment/vm"
 	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
-	biindex "github.com/cloudfoundry/bosh-cli/index"
+	bieventlog "github.com/cloudfoundry/bosh-cli/eventlog"
 	boshinst "github.com/cloudfoundry/bosh-cli/installation"
 	boshinstmanifest "github.com/cloudfoundry/bosh-cli/installation/manifest"
 	bitarball "github.com/cloudfoundry/bosh-cli/installation/tarball"
@@ -37,10 +38,14 @@ import (
 )
 
 type envFactory struct {
-	deps         BasicDeps
-	manifestPath string
-	manifestVars boshtpl.Variables
-	manifestOp   patch.Op
+	deps               BasicDeps
+	manifestPath       string
+	manifestVars       boshtpl.Variables
+	manifestVarSources []string
+	manifestOp         patch.Op
+	mbusOpts           biagent.MbusOpts
+
+	skipDiskMigration bool
 
 	deploymentStateService     biconfig.DeploymentStateService
 	installationManifestParser ReleaseSetAndInstallationManifestParser
@@ -56,6 +61,10 @@ type envFactory struct {
 	diskManagerFactory     bidisk.ManagerFactory
 	vmManagerFactory       bivm.ManagerFactory
 	stemcellManagerFactory bistemcell.ManagerFactory
+	stemcellRepo           biconfig.StemcellRepo
+	instanceRepo           biconfig.InstanceRepo
+	diskRepo               biconfig.DiskRepo
+	deploymentRepo         biconfig.DeploymentRepo
 
 	instanceManagerFactory   biinstance.ManagerFactory
 	deploymentManagerFactory bidepl.ManagerFactory
@@ -64,18 +73,26 @@ type envFactory struct {
 	blobstoreFactory   biblobstore.Factory
 	deploymentFactory  bidepl.Factory
 	deploymentRecord   bidepl.Record
+
+	releaseJobResolver    bideplrel.JobResolver
+	jobListRenderer       bitemplate.JobListRenderer
+	strictJobListRenderer bitemplate.JobListRenderer
 }
 
-func NewEnvFactory(deps BasicDeps, manifestPath string, statePath string, manifestVars boshtpl.Variables, manifestOp patch.Op) *envFactory {
+func NewEnvFactory(deps BasicDeps, manifestPath string, statePath string, statePassphrase string, manifestVars boshtpl.Variables, manifestVarSources []string, manifestOp patch.Op, workers int, downloadOpts bitarball.DownloadOpts, mbusOpts biagent.MbusOpts, skipDiskMigration bool) *envFactory {
 	f := envFactory{
-		deps:         deps,
-		manifestPath: manifestPath,
-		manifestVars: manifestVars,
-		manifestOp:   manifestOp,
+		deps:               deps,
+		manifestPath:       manifestPath,
+		manifestVars:       manifestVars,
+		manifestVarSources: manifestVarSources,
+		manifestOp:         manifestOp,
+		mbusOpts:           mbusOpts,
+
+		skipDiskMigration: skipDiskMigration,
 	}
 
 	f.releaseManager = boshinst.NewReleaseManager(deps.Logger)
-	releaseJobResolver := bideplrel.NewJobResolver(f.releaseManager)
+	f.releaseJobResolver = bideplrel.NewJobResolver(f.releaseManager)
 
 	// todo expand path?
 	workspaceRootPath := filepath.Join(os.Getenv("HOME"), ".bosh")
@@ -83,16 +100,18 @@ func NewEnvFactory(deps BasicDeps, manifestPath string, statePath string, manife
 	{
 		tarballCacheBasePath := filepath.Join(workspaceRootPath, "downloads")
 		tarballCache := bitarball.NewCache(tarballCacheBasePath, deps.FS, deps.Logger)
-		httpClient := bihttpclient.NewHTTPClient(bitarball.HTTPClient, deps.Logger)
-		tarballProvider := bitarball.NewProvider(
-			tarballCache, deps.FS, httpClient, 3, 500*time.Millisecond, deps.Logger)
+		httpClient := bihttpclient.NewHTTPClient(bitarball.NewHTTPClient(downloadOpts), deps.Logger)
+		signatureVerifier := bitarball.NewGPGSignatureVerifier(deps.CmdRunner, deps.FS, deps.Logger)
+		objectStoreClientFactory := bitarball.NewObjectStoreClientFactory(downloadOpts)
+		tarballProvider := bitarball.NewProviderWithOpts(
+			tarballCache, deps.FS, httpClient, objectStoreClientFactory, signatureVerifier, 3, 500*time.Millisecond, deps.Logger, downloadOpts)
 
 		releaseProvider := boshrel.NewProvider(
 			deps.CmdRunner, deps.Compressor, deps.DigestCalculator, deps.FS, deps.Logger)
 
 		f.releaseFetcher = boshinst.NewReleaseFetcher(
 			tarballProvider,
-			releaseProvider.NewExtractingArchiveReader(),
+			releaseProvider.NewStreamingArchiveReader(),
 			f.releaseManager,
 		)
 
@@ -105,13 +124,27 @@ func NewEnvFactory(deps BasicDeps, manifestPath string, statePath string, manife
 		}
 	}
 
-	f.deploymentStateService = biconfig.NewFileSystemDeploymentStateService(
-		deps.FS, deps.UUIDGen, deps.Logger, biconfig.DeploymentStatePath(manifestPath, statePath))
+	// Without --state-passphrase or BOSH_STATE_PASSPHRASE interactive commands
+	// ask for the passphrase of an encrypted deployment state
+	askStatePassphrase := func() (string, error) {
+		if !deps.UI.IsInteractive() {
+			return "", nil
+		}
+
+		return deps.UI.AskForPassword("State passphrase")
+	}
+
+	f.deploymentStateService = newExitUnlockingDeploymentStateService(
+		biconfig.NewEncryptedFileSystemDeploymentStateService(
+			deps.FS, deps.UUIDGen, deps.Logger, biconfig.DeploymentStatePath(manifestPath, statePath),
+			statePassphrase, askStatePassphrase),
+		deps.ExitCleanup,
+	)
 
 	{
 		registryServer := biregistry.NewServerManager(deps.Logger)
 		installerFactory := boshinst.NewInstallerFactory(
-			deps.UI, deps.CmdRunner, deps.Compressor, releaseJobResolver,
+			deps.UI, deps.CmdRunner, deps.Compressor, f.releaseJobResolver,
 			deps.UUIDGen, registryServer, deps.Logger, deps.FS, deps.DigestCreationAlgorithms)
 
 		f.cpiInstaller = bicpirel.CpiInstaller{
@@ -121,41 +154,57 @@ func NewEnvFactory(deps BasicDeps, manifestPath string, statePath string, manife
 		}
 	}
 
-	f.targetProvider = boshinst.NewTargetProvider(
-		f.deploymentStateService, deps.UUIDGen, filepath.Join(workspaceRootPath, "installations"))
+	f.targetProvider = boshinst.NewTargetProviderWithTmpRoot(
+		f.deploymentStateService, deps.UUIDGen, filepath.Join(workspaceRootPath, "installations"), deps.TmpDir)
+
+	stemcellRepo := biconfig.NewStemcellRepo(f.deploymentStateService, deps.UUIDGen)
+	f.stemcellRepo = stemcellRepo
+	f.instanceRepo = biconfig.NewInstanceRepo(f.deploymentStateService)
 
 	{
-		diskRepo := biconfig.NewDiskRepo(f.deploymentStateService, deps.UUIDGen)
-		stemcellRepo := biconfig.NewStemcellRepo(f.deploymentStateService, deps.UUIDGen)
+		f.diskRepo = biconfig.NewDiskRepo(f.deploymentStateService, deps.UUIDGen)
 		vmRepo := biconfig.NewVMRepo(f.deploymentStateService)
 
-		f.diskManagerFactory = bidisk.NewManagerFactory(diskRepo, deps.Logger)
-		diskDeployer := bivm.NewDiskDeployer(f.diskManagerFactory, diskRepo, deps.Logger)
+		f.diskManagerFactory = bidisk.NewManagerFactory(f.diskRepo, deps.Logger)
+		diskDeployer := bivm.NewDiskDeployer(f.diskManagerFactory, f.diskRepo, f.skipDiskMigration, deps.Logger)
 
 		f.stemcellManagerFactory = bistemcell.NewManagerFactory(stemcellRepo)
 		f.vmManagerFactory = bivm.NewManagerFactory(
 			vmRepo, stemcellRepo, diskDeployer, deps.UUIDGen, deps.FS, deps.Logger)
 
-		deploymentRepo := biconfig.NewDeploymentRepo(f.deploymentStateService)
+		f.deploymentRepo = biconfig.NewDeploymentRepo(f.deploymentStateService)
 		releaseRepo := biconfig.NewReleaseRepo(f.deploymentStateService, deps.UUIDGen)
-		f.deploymentRecord = bidepl.NewRecord(deploymentRepo, releaseRepo, stemcellRepo)
+		f.deploymentRecord = bidepl.NewRecord(f.deploymentRepo, releaseRepo, stemcellRepo)
 	}
 
 	{
 		f.blobstoreFactory = biblobstore.NewBlobstoreFactory(deps.UUIDGen, deps.FS, deps.Logger)
 		f.deploymentFactory = bidepl.NewFactory(10*time.Second, 500*time.Millisecond)
-		f.agentClientFactory = bihttpagent.NewAgentClientFactory(1*time.Second, deps.Logger)
-		f.cloudFactory = bicloud.NewFactory(deps.FS, deps.CmdRunner, deps.Logger)
+		f.agentClientFactory = bieventlog.NewAgentClientFactory(
+			biagent.NewAgentClientFactory(1*time.Second, mbusOpts, deps.UUIDGen, deps.Logger), deps.EventLog, deps.Time)
+		f.cloudFactory = bicloud.NewFactory(deps.FS, deps.CmdRunner, deps.EventLog, deps.CPITracer, deps.Time, deps.Logger)
+
+		if deps.CPIReplay != nil {
+			f.cloudFactory = bicloud.NewReplayFactory(deps.CPIReplay, deps.CPITracer, deps.Time, deps.Logger)
+		}
 	}
 
 	{
-		erbRenderer := bitemplateerb.NewERBRenderer(deps.FS, deps.CmdRunner, deps.Logger)
+		erbRenderer := bitemplateerb.NewGoFirstERBRenderer(deps.FS, deps.CmdRunner, deps.Logger)
 		jobRenderer := bitemplate.NewJobRenderer(erbRenderer, deps.FS, deps.UUIDGen, deps.Logger)
+		f.jobListRenderer = bitemplate.NewParallelJobListRenderer(jobRenderer, workers, deps.Logger)
+
+		strictERBRenderer := bitemplateerb.NewStrictERBRenderer(deps.FS, deps.CmdRunner, deps.Logger)
+		strictJobRenderer := bitemplate.NewJobRenderer(strictERBRenderer, deps.FS, deps.UUIDGen, deps.Logger)
+		f.strictJobListRenderer = bitemplate.NewParallelJobListRenderer(strictJobRenderer, workers, deps.Logger)
 
 		builderFactory := biinstancestate.NewBuilderFactory(
-			bistatepkg.NewCompiledPackageRepo(biindex.NewInMemoryIndex()),
-			releaseJobResolver,
-			bitemplate.NewJobListRenderer(jobRenderer, deps.Logger),
+			bistatepkg.NewCompiledPackageCache(filepath.Join(workspaceRootPath, "compiled_packages"), deps.FS, deps.Logger),
+			stemcellRepo,
+			biconfig.NewCheckpointRepo(f.deploymentStateService),
+			workers,
+			f.releaseJobResolver,
+			f.jobListRenderer,
 			bitemplate.NewRenderedJobListCompressor(deps.FS, deps.Compressor, deps.DigestCalculator, deps.Logger),
 			deps.Logger,
 		)
@@ -169,10 +218,13 @@ func NewEnvFactory(deps BasicDeps, manifestPath string, statePath string, manife
 
 	{
 		releaseSetValidator := birelsetmanifest.NewValidator(deps.Logger)
-		releaseSetParser := birelsetmanifest.NewParser(deps.FS, deps.Logger, releaseSetValidator)
+		releaseSetParser := birelsetmanifest.NewTemplateParser(
+			deps.FS, bidepltpl.NewVarSourcesTemplateFactory(bidepltpl.NewDeploymentTemplateFactory(deps.FS), manifestVarSources),
+			deps.Logger, releaseSetValidator)
 
 		installValidator := boshinstmanifest.NewValidator(deps.Logger)
-		installParser := boshinstmanifest.NewParser(deps.FS, deps.UUIDGen, deps.Logger, installValidator)
+		installParser := boshinstmanifest.NewParserWithOpts(
+			deps.FS, deps.UUIDGen, deps.Logger, installValidator, boshinstmanifest.ParserOpts{VarSources: manifestVarSources})
 
 		f.installationManifestParser = ReleaseSetAndInstallationManifestParser{
 			ReleaseSetParser:   releaseSetParser,
@@ -183,11 +235,36 @@ func NewEnvFactory(deps BasicDeps, manifestPath string, statePath string, manife
 	return &f
 }
 
-func (f *envFactory) Preparer() DeploymentPreparer {
+func (f *envFactory) Preparer(cloudConfigPath, runtimeConfigPath string, strictProperties bool) DeploymentPreparer {
+	templateFactory := bidepltpl.NewDeploymentTemplateFactory(f.deps.FS)
+	if cloudConfigPath != "" {
+		templateFactory = bidepltpl.NewCloudConfigTemplateFactory(f.deps.FS, cloudConfigPath)
+	}
+
+	jobListRenderer := f.jobListRenderer
+	if strictProperties {
+		jobListRenderer = f.strictJobListRenderer
+	}
+
+	installationManifestParser := f.installationManifestParser
+	if runtimeConfigPath != "" {
+		templateFactory = bidepltpl.NewRuntimeConfigTemplateFactory(f.deps.FS, templateFactory, runtimeConfigPath)
+	}
+
+	templateFactory = bidepltpl.NewVarSourcesTemplateFactory(templateFactory, f.manifestVarSources)
+
+	if runtimeConfigPath != "" {
+		installationManifestParser.ReleaseSetParser = birelsetmanifest.NewTemplateParser(
+			f.deps.FS, templateFactory, f.deps.Logger, birelsetmanifest.NewValidator(f.deps.Logger))
+	}
+
+	checkpointRepo := biconfig.NewCheckpointRepo(f.deploymentStateService)
+
 	return NewDeploymentPreparer(
 		f.deps.UI,
 		f.deps.Logger,
 		"DeploymentPreparer",
+		f.deps.FS,
 		f.deploymentStateService,
 		biconfig.NewLegacyDeploymentStateMigrator(
 			f.deploymentStateService,
@@ -197,15 +274,18 @@ func (f *envFactory) Preparer() DeploymentPreparer {
 		),
 		f.releaseManager,
 		f.deploymentRecord,
+		checkpointRepo,
 		f.cloudFactory,
 		f.stemcellManagerFactory,
 		f.agentClientFactory,
-		f.vmManagerFactory,
+		f.instanceVMManagerFactory,
 		f.blobstoreFactory,
 		bidepl.NewDeployer(
 			f.vmManagerFactory,
 			f.instanceManagerFactory,
 			f.deploymentFactory,
+			checkpointRepo,
+			f.instanceRepo,
 			f.deps.Logger,
 		),
 		f.manifestPath,
@@ -214,16 +294,21 @@ func (f *envFactory) Preparer() DeploymentPreparer {
 		f.cpiInstaller,
 		f.releaseFetcher,
 		f.stemcellFetcher,
-		f.installationManifestParser,
+		installationManifestParser,
 		NewDeploymentManifestParser(
 			bideplmanifest.NewParser(f.deps.FS, f.deps.Logger),
 			bideplmanifest.NewValidator(f.deps.Logger),
 			f.releaseManager,
-			bidepltpl.NewDeploymentTemplateFactory(f.deps.FS),
+			templateFactory,
 		),
 		NewTempRootConfigurator(f.deps.FS),
+		boshinst.NewScratchSpaceChecker(f.deps.FS, boshinst.FreeSpace, f.deps.Logger),
 		f.targetProvider,
-	)
+		f.releaseJobResolver,
+		jobListRenderer,
+		strictProperties,
+		f.skipDiskMigration,
+	).WithExitCleanup(f.deps.ExitCleanup)
 }
 
 func (f *envFactory) Deleter() DeploymentDeleter {
@@ -243,6 +328,10 @@ func (f *envFactory) Deleter() DeploymentDeleter {
 			f.stemcellManagerFactory,
 			f.deploymentFactory,
 		),
+		f.instanceRepo,
+		f.diskRepo,
+		f.instanceManagerFactory,
+		f.instanceVMManagerFactory,
 		f.manifestPath,
 		f.manifestVars,
 		f.manifestOp,
@@ -254,3 +343,46 @@ func (f *envFactory) Deleter() DeploymentDeleter {
 		f.targetProvider,
 	)
 }
+
+// instanceVMManagerFactory returns the factory of managers of the VM of the
+// instance with the given index. The VMs and disks of instances other than
+// the first are recorded separately in the deployment state.
+func (f *envFactory) instanceVMManagerFactory(index int) bivm.ManagerFactory {
+	if index == 0 {
+		return f.vmManagerFactory
+	}
+
+	diskRepo := biconfig.NewInstanceDiskRepo(f.deploymentStateService, f.deps.UUIDGen, index)
+	vmRepo := biconfig.NewInstanceVMRepo(f.deploymentStateService, index)
+
+	diskManagerFactory := bidisk.NewManagerFactory(diskRepo, f.deps.Logger)
+	diskDeployer := bivm.NewDiskDeployer(diskManagerFactory, diskRepo, f.skipDiskMigration, f.deps.Logger)
+
+	return bivm.NewInstanceManagerFactory(
+		vmRepo, f.stemcellRepo, diskDeployer, f.deps.UUIDGen, f.deps.FS, f.deps.Logger, index)
+}
+
+func (f *envFactory) Agent() EnvAgent {
+	return NewEnvAgent(
+		f.deploymentStateService,
+		f.installationManifestParser,
+		biagent.NewClientFactory(1*time.Second, f.mbusOpts, f.deps.Logger),
+		f.blobstoreFactory,
+		f.deps.FS,
+		f.manifestPath,
+		f.manifestVars,
+		f.manifestOp,
+	)
+}
+
+func (f *envFactory) State() biconfig.DeploymentStateService {
+	return f.deploymentStateService
+}
+
+func (f *envFactory) Disks() EnvDisks {
+	diskRepoProvider := func(index int) biconfig.DiskRepo {
+		return biconfig.NewInstanceDiskRepo(f.deploymentStateService, f.deps.UUIDGen, index)
+	}
+
+	return NewEnvDisks(f.deploymentStateService, diskRepoProvider, f.deploymentRepo)
+}
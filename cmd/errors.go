@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	bicloud "github.com/cloudfoundry/bosh-cli/cloud"
+	biagent "github.com/cloudfoundry/bosh-cli/deployment/agent"
+	biui "github.com/cloudfoundry/bosh-cli/ui"
+)
+
+// Exit codes of the CLI by failure category, so that automation can tell
+// apart failures worth retrying from invalid input.
+const (
+	ExitCodeError        = 1
+	ExitCodeValidation   = 2
+	ExitCodeCPIFailure   = 3
+	ExitCodeAgentTimeout = 4
+	ExitCodeUserAbort    = 5
+)
+
+// CategorizedError tells the failure category of the error it wraps.
+type CategorizedError interface {
+	error
+	ExitCode() int
+	Cause() error
+}
+
+// ValidationError is returned when the manifests, releases or stemcell given
+// to a command are invalid.
+type ValidationError struct{ Err error }
+
+func (e ValidationError) Error() string { return e.Err.Error() }
+func (e ValidationError) Cause() error  { return e.Err }
+func (e ValidationError) ExitCode() int { return ExitCodeValidation }
+
+// CPIFailure is returned when a CPI method failed or timed out.
+type CPIFailure struct{ Err error }
+
+func (e CPIFailure) Error() string { return e.Err.Error() }
+func (e CPIFailure) Cause() error  { return e.Err }
+func (e CPIFailure) ExitCode() int { return ExitCodeCPIFailure }
+
+// AgentTimeout is returned when the agent did not respond or did not finish
+// a task in time.
+type AgentTimeout struct{ Err error }
+
+func (e AgentTimeout) Error() string { return e.Err.Error() }
+func (e AgentTimeout) Cause() error  { return e.Err }
+func (e AgentTimeout) ExitCode() int { return ExitCodeAgentTimeout }
+
+// UserAbort is returned when the user did not confirm to continue.
+type UserAbort struct{ Err error }
+
+func (e UserAbort) Error() string { return e.Err.Error() }
+func (e UserAbort) Cause() error  { return e.Err }
+func (e UserAbort) ExitCode() int { return ExitCodeUserAbort }
+
+// ExitCode returns the exit code for the failure category of err.
+func ExitCode(err error) int {
+	if categorizedErr, ok := err.(CategorizedError); ok {
+		return categorizedErr.ExitCode()
+	}
+	return ExitCodeError
+}
+
+// CategorizeError returns err as the error of its failure category. The
+// category is the first one found in the errors it wraps, either set by a
+// command or told from the CPI, agent and UI errors.
+func CategorizeError(err error) error {
+	if _, ok := err.(CategorizedError); ok || err == nil {
+		return err
+	}
+
+	var categorizedErr CategorizedError
+
+	walkErrors(err, func(wrappedErr error) bool {
+		switch specificErr := wrappedErr.(type) {
+		case ValidationError:
+			categorizedErr = ValidationError{Err: err}
+		case CPIFailure, bicloud.Error:
+			categorizedErr = CPIFailure{Err: err}
+		case AgentTimeout:
+			categorizedErr = AgentTimeout{Err: err}
+		case UserAbort:
+			categorizedErr = UserAbort{Err: err}
+		case biagent.MbusError:
+			if specificErr.Category == biagent.MbusTimeoutError || specificErr.Category == biagent.MbusTaskTimeoutError {
+				categorizedErr = AgentTimeout{Err: err}
+			}
+		default:
+			if bicloud.IsTimeoutError(wrappedErr) {
+				categorizedErr = CPIFailure{Err: err}
+			} else if wrappedErr == biui.ErrConfirmationDeclined || wrappedErr == biui.ErrInterrupted {
+				categorizedErr = UserAbort{Err: err}
+			}
+		}
+		return categorizedErr != nil
+	})
+
+	if categorizedErr == nil {
+		return err
+	}
+
+	return categorizedErr
+}
+
+// walkErrors calls fn with err and the errors it wraps, depth first, until
+// fn returns true.
+func walkErrors(err error, fn func(error) bool) bool {
+	if err == nil {
+		return false
+	}
+
+	if fn(err) {
+		return true
+	}
+
+	switch specificErr := err.(type) {
+	case bosherr.ComplexError:
+		return walkErrors(specificErr.Err, fn) || walkErrors(specificErr.Cause, fn)
+	case bosherr.MultiError:
+		for _, sibling := range specificErr.Errors {
+			if walkErrors(sibling, fn) {
+				return true
+			}
+		}
+	case CategorizedError:
+		return walkErrors(specificErr.Cause(), fn)
+	}
+
+	return false
+}
@@ -0,0 +1,84 @@
+package cmd_test
+
+import (
+	"errors"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	bicloud "github.com/cloudfoundry/bosh-cli/cloud"
+	. "github.com/cloudfoundry/bosh-cli/cmd"
+	biagent "github.com/cloudfoundry/bosh-cli/deployment/agent"
+	biui "github.com/cloudfoundry/bosh-cli/ui"
+)
+
+var _ = Describe("CategorizeError", func() {
+	It("returns nil for nil", func() {
+		Expect(CategorizeError(nil)).To(BeNil())
+	})
+
+	It("leaves errors without a category as they are", func() {
+		err := bosherr.WrapError(errors.New("fake-err"), "Deploying")
+		Expect(CategorizeError(err)).To(Equal(err))
+		Expect(ExitCode(CategorizeError(err))).To(Equal(ExitCodeError))
+	})
+
+	It("categorizes errors wrapping a validation error", func() {
+		err := bosherr.WrapError(ValidationError{Err: errors.New("fake-err")}, "Validating")
+
+		categorizedErr := CategorizeError(err)
+		Expect(categorizedErr).To(Equal(ValidationError{Err: err}))
+		Expect(categorizedErr.Error()).To(Equal("Validating: fake-err"))
+		Expect(ExitCode(categorizedErr)).To(Equal(ExitCodeValidation))
+	})
+
+	It("categorizes errors wrapping a CPI error as CPI failures", func() {
+		cpiErr := bicloud.NewCPIError("create_vm", bicloud.CmdError{Type: "Bosh::Clouds::VMCreationFailed", Message: "fake-message"})
+		err := bosherr.WrapError(bosherr.WrapError(cpiErr, "Creating VM"), "Deploying")
+
+		Expect(CategorizeError(err)).To(Equal(CPIFailure{Err: err}))
+		Expect(ExitCode(CategorizeError(err))).To(Equal(ExitCodeCPIFailure))
+	})
+
+	It("categorizes errors wrapping agent timeouts", func() {
+		mbusErr := biagent.MbusError{Category: biagent.MbusTaskTimeoutError, Attempts: 1, Err: errors.New("fake-err")}
+		err := bosherr.NewMultiError(errors.New("fake-other-err"), bosherr.WrapError(mbusErr, "Applying"))
+
+		Expect(CategorizeError(err)).To(Equal(AgentTimeout{Err: err}))
+		Expect(ExitCode(CategorizeError(err))).To(Equal(ExitCodeAgentTimeout))
+	})
+
+	It("does not categorize other agent errors", func() {
+		err := biagent.MbusError{Category: biagent.MbusTLSError, Attempts: 1, Err: errors.New("fake-err")}
+		Expect(ExitCode(CategorizeError(err))).To(Equal(ExitCodeError))
+	})
+
+	It("categorizes declined confirmations as user aborts", func() {
+		err := bosherr.WrapError(biui.ErrConfirmationDeclined, "Deleting")
+
+		Expect(CategorizeError(err)).To(Equal(UserAbort{Err: err}))
+		Expect(ExitCode(CategorizeError(err))).To(Equal(ExitCodeUserAbort))
+	})
+
+	It("categorizes interruptions as user aborts", func() {
+		err := bosherr.WrapError(biui.ErrInterrupted, "Deploying")
+
+		Expect(CategorizeError(err)).To(Equal(UserAbort{Err: err}))
+		Expect(ExitCode(CategorizeError(err))).To(Equal(ExitCodeUserAbort))
+	})
+
+	It("uses the first category found", func() {
+		err := bosherr.NewMultiError(
+			ValidationError{Err: errors.New("fake-validation-err")},
+			UserAbort{Err: errors.New("fake-abort-err")},
+		)
+
+		Expect(ExitCode(CategorizeError(err))).To(Equal(ExitCodeValidation))
+	})
+
+	It("keeps errors that are already categorized", func() {
+		err := CPIFailure{Err: errors.New("fake-err")}
+		Expect(CategorizeError(err)).To(Equal(err))
+	})
+})
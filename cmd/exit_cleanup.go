@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"sync"
+
+	biconfig "github.com/cloudfoundry/bosh-cli/config"
+)
+
+// ExitCleanup holds the cleanup of a running command, e.g. releasing the
+// lock on the deployment state or deleting extracted releases, that has to be
+// done when the CLI exits without returning through the command, e.g. on a
+// second interrupt.
+type ExitCleanup struct {
+	cleanups map[int]func()
+	next     int
+	lock     sync.Mutex
+}
+
+func NewExitCleanup() *ExitCleanup {
+	return &ExitCleanup{cleanups: map[int]func(){}}
+}
+
+// Add registers cleanup until the returned func is called, once the command
+// has done the cleanup itself.
+func (c *ExitCleanup) Add(cleanup func()) func() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	id := c.next
+	c.next++
+	c.cleanups[id] = cleanup
+
+	return func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		delete(c.cleanups, id)
+	}
+}
+
+// Defer registers cleanup until the returned func, which does the cleanup, is
+// called, e.g. deferred by the command.
+func (c *ExitCleanup) Defer(cleanup func()) func() {
+	remove := c.Add(cleanup)
+
+	return func() {
+		remove()
+		cleanup()
+	}
+}
+
+// Run does the registered cleanup, the most recently added first, on a best
+// effort basis.
+func (c *ExitCleanup) Run() {
+	c.lock.Lock()
+	cleanups := []func(){}
+	for id := c.next - 1; id >= 0; id-- {
+		if cleanup, found := c.cleanups[id]; found {
+			cleanups = append(cleanups, cleanup)
+			delete(c.cleanups, id)
+		}
+	}
+	c.lock.Unlock()
+
+	for _, cleanup := range cleanups {
+		cleanup()
+	}
+}
+
+// exitUnlockingDeploymentStateService releases the lock on the deployment
+// state when the CLI exits while holding it.
+type exitUnlockingDeploymentStateService struct {
+	biconfig.DeploymentStateService
+	exitCleanup *ExitCleanup
+	remove      func()
+}
+
+func newExitUnlockingDeploymentStateService(deploymentStateService biconfig.DeploymentStateService, exitCleanup *ExitCleanup) biconfig.DeploymentStateService {
+	return &exitUnlockingDeploymentStateService{
+		DeploymentStateService: deploymentStateService,
+		exitCleanup:            exitCleanup,
+		remove:                 func() {},
+	}
+}
+
+func (s *exitUnlockingDeploymentStateService) Lock() error {
+	err := s.DeploymentStateService.Lock()
+	if err != nil {
+		return err
+	}
+
+	s.remove = s.exitCleanup.Add(func() {
+		_ = s.DeploymentStateService.Unlock()
+	})
+
+	return nil
+}
+
+func (s *exitUnlockingDeploymentStateService) Unlock() error {
+	s.remove()
+	s.remove = func() {}
+
+	return s.DeploymentStateService.Unlock()
+}
@@ -0,0 +1,102 @@
+package cmd_test
+
+import (
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/cmd"
+	biagent "github.com/cloudfoundry/bosh-cli/deployment/agent"
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	bitarball "github.com/cloudfoundry/bosh-cli/installation/tarball"
+	boshui "github.com/cloudfoundry/bosh-cli/ui"
+	fakeui "github.com/cloudfoundry/bosh-cli/ui/fakes"
+)
+
+var _ = Describe("ExitCleanup", func() {
+	var (
+		exitCleanup *ExitCleanup
+		cleanedUp   []string
+	)
+
+	BeforeEach(func() {
+		exitCleanup = NewExitCleanup()
+		cleanedUp = []string{}
+	})
+
+	cleanup := func(name string) func() {
+		return func() { cleanedUp = append(cleanedUp, name) }
+	}
+
+	It("runs the registered cleanup, the most recently added first", func() {
+		exitCleanup.Add(cleanup("first"))
+		exitCleanup.Add(cleanup("second"))
+
+		exitCleanup.Run()
+		Expect(cleanedUp).To(Equal([]string{"second", "first"}))
+
+		exitCleanup.Run()
+		Expect(cleanedUp).To(Equal([]string{"second", "first"}))
+	})
+
+	It("does not run cleanup that was removed", func() {
+		remove := exitCleanup.Add(cleanup("removed"))
+		exitCleanup.Add(cleanup("kept"))
+
+		remove()
+		exitCleanup.Run()
+
+		Expect(cleanedUp).To(Equal([]string{"kept"}))
+	})
+
+	Describe("Defer", func() {
+		It("returns a func doing the cleanup instead of Run", func() {
+			done := exitCleanup.Defer(cleanup("deferred"))
+
+			done()
+			Expect(cleanedUp).To(Equal([]string{"deferred"}))
+
+			exitCleanup.Run()
+			Expect(cleanedUp).To(Equal([]string{"deferred"}))
+		})
+	})
+
+	Describe("with the deployment state of an env", func() {
+		var (
+			fs          *fakesys.FakeFileSystem
+			deps        BasicDeps
+			manifestVar boshtpl.StaticVariables
+		)
+
+		BeforeEach(func() {
+			logger := boshlog.NewLogger(boshlog.LevelNone)
+			fs = fakesys.NewFakeFileSystem()
+			deps = NewBasicDepsWithFS(boshui.NewWrappingConfUI(&fakeui.FakeUI{}, logger), fs, logger)
+			deps.ExitCleanup = exitCleanup
+			manifestVar = boshtpl.StaticVariables{}
+		})
+
+		It("releases the lock on the deployment state when run while it is held", func() {
+			deploymentStateService := NewEnvFactory(deps, "/path/to/manifest.yml", "", "", manifestVar, nil, nil, 1, bitarball.DownloadOpts{}, biagent.MbusOpts{}, false).State()
+
+			Expect(deploymentStateService.Lock()).To(Succeed())
+			Expect(fs.FileExists("/path/to/manifest-state.json.lock")).To(BeTrue())
+
+			exitCleanup.Run()
+			Expect(fs.FileExists("/path/to/manifest-state.json.lock")).To(BeFalse())
+		})
+
+		It("leaves a lock file it does not hold", func() {
+			deploymentStateService := NewEnvFactory(deps, "/path/to/manifest.yml", "", "", manifestVar, nil, nil, 1, bitarball.DownloadOpts{}, biagent.MbusOpts{}, false).State()
+
+			Expect(deploymentStateService.Lock()).To(Succeed())
+			Expect(deploymentStateService.Unlock()).To(Succeed())
+
+			fs.WriteFileString("/path/to/manifest-state.json.lock", "")
+
+			exitCleanup.Run()
+			Expect(fs.FileExists("/path/to/manifest-state.json.lock")).To(BeTrue())
+		})
+	})
+})
@@ -7,6 +7,8 @@ import (
 	"reflect"
 	"strings"
 
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+
 	// Should only be imported here to avoid leaking use of goflags through project
 	goflags "github.com/jessevdk/go-flags"
 )
@@ -88,6 +90,7 @@ func (f Factory) New(args []string) (Cmd, error) {
 	boshOpts.SSH.GatewayFlags.UUIDGen = f.deps.UUIDGen
 	boshOpts.SCP.GatewayFlags.UUIDGen = f.deps.UUIDGen
 	boshOpts.Logs.GatewayFlags.UUIDGen = f.deps.UUIDGen
+	boshOpts.SSHEnv.GatewayFlags.UUIDGen = f.deps.UUIDGen
 
 	goflags.FactoryFunc = func(val interface{}) {
 		stype := reflect.Indirect(reflect.ValueOf(val))
@@ -96,6 +99,11 @@ func (f Factory) New(args []string) (Cmd, error) {
 			if field.IsValid() {
 				field.Set(reflect.ValueOf(f.deps.FS))
 			}
+
+			field = stype.FieldByName("Logger")
+			if field.IsValid() && field.Type() == reflect.TypeOf((*boshlog.Logger)(nil)).Elem() {
+				field.Set(reflect.ValueOf(f.deps.Logger))
+			}
 		}
 	}
 
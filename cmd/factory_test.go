@@ -56,6 +56,7 @@ var _ = Describe("Factory", func() {
 			"delete-deployment":     []string{},
 			"delete-disk":           []string{"cid"},
 			"delete-env":            []string{filepath.Join("/", "file")},
+			"diff-env":              []string{filepath.Join("/", "file")},
 			"delete-release":        []string{"release-version"},
 			"delete-snapshot":       []string{"cid"},
 			"delete-snapshots":      []string{},
@@ -103,6 +104,7 @@ var _ = Describe("Factory", func() {
 			"upload-blobs":          []string{},
 			"upload-release":        []string{filepath.Join("/", "file")},
 			"upload-stemcell":       []string{filepath.Join("/", "file")},
+			"validate-env":          []string{filepath.Join("/", "file")},
 			"vms":                   []string{},
 		}
 
@@ -357,6 +359,7 @@ var _ = Describe("Factory", func() {
 			boshOpts.GenerateJob = GenerateJobOpts{}
 			boshOpts.GeneratePackage = GeneratePackageOpts{}
 			boshOpts.CreateRelease = CreateReleaseOpts{}
+			boshOpts.CreateEnv = CreateEnvOpts{}
 			boshOpts.FinalizeRelease = FinalizeReleaseOpts{}
 			boshOpts.Blobs = BlobsOpts{}
 			boshOpts.AddBlob = AddBlobOpts{}
@@ -365,6 +368,9 @@ var _ = Describe("Factory", func() {
 			boshOpts.UploadBlobs = UploadBlobsOpts{}
 			boshOpts.SSH = SSHOpts{}
 			boshOpts.SCP = SCPOpts{}
+			boshOpts.SSHEnv = SSHEnvOpts{}
+			boshOpts.LogsEnv = LogsEnvOpts{}
+			boshOpts.DiagnoseEnv = DiagnoseEnvOpts{}
 			return boshOpts
 		}
 
@@ -375,6 +381,7 @@ var _ = Describe("Factory", func() {
 			// Check against entire BoshOpts to avoid future missing assertions
 			Expect(clearNonGlobalOpts(cmd.BoshOpts)).To(Equal(BoshOpts{
 				ConfigPathOpt: "~/.bosh/config",
+				RedactOpt:     "true",
 			}))
 		})
 
@@ -390,6 +397,7 @@ var _ = Describe("Factory", func() {
 				"--tty",
 				"--no-color",
 				"--non-interactive",
+				"--redact=false",
 				"locks",
 			}
 
@@ -407,6 +415,7 @@ var _ = Describe("Factory", func() {
 				TTYOpt:            true,
 				NoColorOpt:        true,
 				NonInteractiveOpt: true,
+				RedactOpt:         "false",
 			}))
 		})
 
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+
+	boshrel "github.com/cloudfoundry/bosh-cli/release"
+	boshui "github.com/cloudfoundry/bosh-cli/ui"
+	boshtbl "github.com/cloudfoundry/bosh-cli/ui/table"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type InspectLocalReleaseCmd struct {
+	reader boshrel.Reader
+	fs     boshsys.FileSystem
+	ui     boshui.UI
+}
+
+func NewInspectLocalReleaseCmd(reader boshrel.Reader, fs boshsys.FileSystem, ui boshui.UI) InspectLocalReleaseCmd {
+	return InspectLocalReleaseCmd{reader: reader, fs: fs, ui: ui}
+}
+
+func (c InspectLocalReleaseCmd) Run(opts InspectLocalReleaseOpts) error {
+	release, err := c.reader.Read(opts.Args.Path)
+	if err != nil {
+		return err
+	}
+
+	defer release.CleanUp()
+
+	summaryTable := boshtbl.Table{
+		Header: []boshtbl.Header{
+			boshtbl.NewHeader("Name"),
+			boshtbl.NewHeader("Version"),
+			boshtbl.NewHeader("Commit Hash"),
+		},
+		Rows: [][]boshtbl.Value{
+			{
+				boshtbl.NewValueString(release.Name()),
+				boshtbl.NewValueString(release.Version()),
+				boshtbl.NewValueString(release.CommitHashWithMark("+")),
+			},
+		},
+		Transpose: true,
+	}
+
+	jobsTable := boshtbl.Table{
+		Content: "jobs",
+		Header: []boshtbl.Header{
+			boshtbl.NewHeader("Job"),
+			boshtbl.NewHeader("Digest"),
+			boshtbl.NewHeader("Size"),
+			boshtbl.NewHeader("Packages"),
+		},
+		SortBy: []boshtbl.ColumnSort{{Column: 0, Asc: true}},
+	}
+
+	for _, job := range release.Jobs() {
+		size, err := c.archiveSize(job.ArchivePath())
+		if err != nil {
+			return err
+		}
+
+		var pkgNames []string
+		for _, pkg := range job.Packages {
+			pkgNames = append(pkgNames, pkg.Name())
+		}
+
+		jobsTable.Rows = append(jobsTable.Rows, []boshtbl.Value{
+			boshtbl.NewValueString(fmt.Sprintf("%s/%s", job.Name(), job.Fingerprint())),
+			boshtbl.NewValueString(job.ArchiveSHA1()),
+			size,
+			boshtbl.NewValueStrings(pkgNames),
+		})
+	}
+
+	pkgsTable := boshtbl.Table{
+		Content: "packages",
+		Header: []boshtbl.Header{
+			boshtbl.NewHeader("Package"),
+			boshtbl.NewHeader("Compiled for"),
+			boshtbl.NewHeader("Digest"),
+			boshtbl.NewHeader("Size"),
+			boshtbl.NewHeader("Dependencies"),
+		},
+		SortBy: []boshtbl.ColumnSort{{Column: 0, Asc: true}},
+	}
+
+	for _, pkg := range release.Packages() {
+		size, err := c.archiveSize(pkg.ArchivePath())
+		if err != nil {
+			return err
+		}
+
+		pkgsTable.Rows = append(pkgsTable.Rows, []boshtbl.Value{
+			boshtbl.NewValueString(fmt.Sprintf("%s/%s", pkg.Name(), pkg.Fingerprint())),
+			boshtbl.NewValueString("(source)"),
+			boshtbl.NewValueString(pkg.ArchiveSHA1()),
+			size,
+			boshtbl.NewValueStrings(pkg.DependencyNames()),
+		})
+	}
+
+	for _, pkg := range release.CompiledPackages() {
+		size, err := c.archiveSize(pkg.ArchivePath())
+		if err != nil {
+			return err
+		}
+
+		pkgsTable.Rows = append(pkgsTable.Rows, []boshtbl.Value{
+			boshtbl.NewValueString(fmt.Sprintf("%s/%s", pkg.Name(), pkg.Fingerprint())),
+			boshtbl.NewValueString(pkg.OSVersionSlug()),
+			boshtbl.NewValueString(pkg.ArchiveSHA1()),
+			size,
+			boshtbl.NewValueStrings(pkg.DependencyNames()),
+		})
+	}
+
+	c.ui.PrintTable(summaryTable)
+	c.ui.PrintTable(jobsTable)
+	c.ui.PrintTable(pkgsTable)
+
+	return nil
+}
+
+func (c InspectLocalReleaseCmd) archiveSize(path string) (boshtbl.ValueBytes, error) {
+	stat, err := c.fs.Stat(path)
+	if err != nil {
+		return boshtbl.ValueBytes{}, bosherr.WrapErrorf(err, "Checking size of '%s'", path)
+	}
+
+	return boshtbl.NewValueBytes(uint64(stat.Size())), nil
+}
@@ -0,0 +1,168 @@
+package cmd_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/cmd"
+	boshjob "github.com/cloudfoundry/bosh-cli/release/job"
+	boshpkg "github.com/cloudfoundry/bosh-cli/release/pkg"
+	fakerel "github.com/cloudfoundry/bosh-cli/release/releasefakes"
+	. "github.com/cloudfoundry/bosh-cli/release/resource"
+	fakeui "github.com/cloudfoundry/bosh-cli/ui/fakes"
+	boshtbl "github.com/cloudfoundry/bosh-cli/ui/table"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("InspectLocalReleaseCmd", func() {
+	var (
+		releaseReader *fakerel.FakeReader
+		fs            *fakesys.FakeFileSystem
+		ui            *fakeui.FakeUI
+		command       InspectLocalReleaseCmd
+	)
+
+	BeforeEach(func() {
+		releaseReader = &fakerel.FakeReader{}
+		fs = fakesys.NewFakeFileSystem()
+		ui = &fakeui.FakeUI{}
+		command = NewInspectLocalReleaseCmd(releaseReader, fs, ui)
+	})
+
+	Describe("Run", func() {
+		var (
+			opts    InspectLocalReleaseOpts
+			release *fakerel.FakeRelease
+		)
+
+		BeforeEach(func() {
+			opts = InspectLocalReleaseOpts{
+				Args: InspectLocalReleaseArgs{Path: "/release.tgz"},
+			}
+
+			pkg1 := boshpkg.NewPackage(NewResourceWithBuiltArchive("pkg1", "pkg1-fp", "/pkg1.tgz", "pkg1-sha1"), []string{"pkg2"})
+			pkg2 := boshpkg.NewPackage(NewResourceWithBuiltArchive("pkg2", "pkg2-fp", "/pkg2.tgz", "pkg2-sha1"), nil)
+			compiledPkg := boshpkg.NewCompiledPackageWithArchive("pkg3", "pkg3-fp", "ubuntu-trusty/3421", "/pkg3.tgz", "pkg3-sha1", nil)
+
+			job := boshjob.NewJob(NewResourceWithBuiltArchive("job1", "job1-fp", "/job1.tgz", "job1-sha1"))
+			job.Packages = []boshpkg.Compilable{pkg1, compiledPkg}
+
+			fs.WriteFileString("/job1.tgz", "job1-content")
+			fs.WriteFileString("/pkg1.tgz", "pkg1-content-longer")
+			fs.WriteFileString("/pkg2.tgz", "pkg2")
+			fs.WriteFileString("/pkg3.tgz", "pkg3-compiled")
+
+			release = &fakerel.FakeRelease{}
+			release.NameReturns("rel")
+			release.VersionReturns("ver")
+			release.CommitHashWithMarkReturns("commit+")
+			release.JobsReturns([]*boshjob.Job{job})
+			release.PackagesReturns([]*boshpkg.Package{pkg1, pkg2})
+			release.CompiledPackagesReturns([]*boshpkg.CompiledPackage{compiledPkg})
+
+			releaseReader.ReadReturns(release, nil)
+		})
+
+		It("reads the release tarball at the given path", func() {
+			err := command.Run(opts)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(releaseReader.ReadCallCount()).To(Equal(1))
+			Expect(releaseReader.ReadArgsForCall(0)).To(Equal("/release.tgz"))
+		})
+
+		It("prints the release summary, jobs and packages with their sizes", func() {
+			err := command.Run(opts)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(ui.Tables).To(HaveLen(3))
+
+			Expect(ui.Tables[0]).To(Equal(boshtbl.Table{
+				Header: []boshtbl.Header{
+					boshtbl.NewHeader("Name"),
+					boshtbl.NewHeader("Version"),
+					boshtbl.NewHeader("Commit Hash"),
+				},
+				Rows: [][]boshtbl.Value{
+					{
+						boshtbl.NewValueString("rel"),
+						boshtbl.NewValueString("ver"),
+						boshtbl.NewValueString("commit+"),
+					},
+				},
+				Transpose: true,
+			}))
+
+			Expect(ui.Tables[1]).To(Equal(boshtbl.Table{
+				Content: "jobs",
+				Header: []boshtbl.Header{
+					boshtbl.NewHeader("Job"),
+					boshtbl.NewHeader("Digest"),
+					boshtbl.NewHeader("Size"),
+					boshtbl.NewHeader("Packages"),
+				},
+				SortBy: []boshtbl.ColumnSort{{Column: 0, Asc: true}},
+				Rows: [][]boshtbl.Value{
+					{
+						boshtbl.NewValueString("job1/job1-fp"),
+						boshtbl.NewValueString("job1-sha1"),
+						boshtbl.NewValueBytes(12),
+						boshtbl.NewValueStrings([]string{"pkg1", "pkg3"}),
+					},
+				},
+			}))
+
+			Expect(ui.Tables[2]).To(Equal(boshtbl.Table{
+				Content: "packages",
+				Header: []boshtbl.Header{
+					boshtbl.NewHeader("Package"),
+					boshtbl.NewHeader("Compiled for"),
+					boshtbl.NewHeader("Digest"),
+					boshtbl.NewHeader("Size"),
+					boshtbl.NewHeader("Dependencies"),
+				},
+				SortBy: []boshtbl.ColumnSort{{Column: 0, Asc: true}},
+				Rows: [][]boshtbl.Value{
+					{
+						boshtbl.NewValueString("pkg1/pkg1-fp"),
+						boshtbl.NewValueString("(source)"),
+						boshtbl.NewValueString("pkg1-sha1"),
+						boshtbl.NewValueBytes(19),
+						boshtbl.NewValueStrings([]string{"pkg2"}),
+					},
+					{
+						boshtbl.NewValueString("pkg2/pkg2-fp"),
+						boshtbl.NewValueString("(source)"),
+						boshtbl.NewValueString("pkg2-sha1"),
+						boshtbl.NewValueBytes(4),
+						boshtbl.NewValueStrings(nil),
+					},
+					{
+						boshtbl.NewValueString("pkg3/pkg3-fp"),
+						boshtbl.NewValueString("ubuntu-trusty/3421"),
+						boshtbl.NewValueString("pkg3-sha1"),
+						boshtbl.NewValueBytes(13),
+						boshtbl.NewValueStrings(nil),
+					},
+				},
+			}))
+		})
+
+		It("cleans up the extracted release", func() {
+			err := command.Run(opts)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(release.CleanUpCallCount()).To(Equal(1))
+		})
+
+		It("returns error if reading the release fails", func() {
+			releaseReader.ReadReturns(nil, errors.New("fake-err"))
+
+			err := command.Run(opts)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-err"))
+		})
+	})
+})
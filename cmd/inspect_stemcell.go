@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strconv"
+
+	"github.com/cloudfoundry/bosh-cli/stemcell"
+	boshui "github.com/cloudfoundry/bosh-cli/ui"
+	boshtbl "github.com/cloudfoundry/bosh-cli/ui/table"
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type InspectStemcellCmd struct {
+	ui                boshui.UI
+	fs                boshsys.FileSystem
+	stemcellExtractor stemcell.Extractor
+}
+
+func NewInspectStemcellCmd(
+	ui boshui.UI,
+	fs boshsys.FileSystem,
+	stemcellExtractor stemcell.Extractor,
+) InspectStemcellCmd {
+	return InspectStemcellCmd{ui: ui, fs: fs, stemcellExtractor: stemcellExtractor}
+}
+
+func (c InspectStemcellCmd) Run(opts InspectStemcellOpts) error {
+	extractedStemcell, err := c.stemcellExtractor.Extract(opts.Args.PathToStemcell)
+	if err != nil {
+		return err
+	}
+
+	defer extractedStemcell.Cleanup()
+
+	manifest := extractedStemcell.Manifest()
+
+	var apiVersion string
+	if manifest.APIVersion > 0 {
+		apiVersion = strconv.Itoa(manifest.APIVersion)
+	}
+
+	table := boshtbl.Table{
+		Header: []boshtbl.Header{
+			boshtbl.NewHeader("Name"),
+			boshtbl.NewHeader("Version"),
+			boshtbl.NewHeader("OS"),
+			boshtbl.NewHeader("API Version"),
+			boshtbl.NewHeader("BOSH Protocol"),
+			boshtbl.NewHeader("Image Digest"),
+			boshtbl.NewHeader("Cloud Properties"),
+		},
+		Rows: [][]boshtbl.Value{
+			{
+				boshtbl.NewValueString(manifest.Name),
+				boshtbl.NewValueString(manifest.Version),
+				boshtbl.NewValueString(manifest.OS),
+				boshtbl.NewValueString(apiVersion),
+				boshtbl.NewValueString(manifest.BoshProtocol),
+				boshtbl.NewValueString(manifest.SHA1),
+				boshtbl.NewValueInterface(manifest.CloudProperties),
+			},
+		},
+		Transpose: true,
+	}
+
+	c.ui.PrintTable(table)
+
+	return c.verifyImage(extractedStemcell.GetExtractedPath(), manifest.SHA1)
+}
+
+// verifyImage checks the image against the digest in stemcell.MF so that
+// a broken custom stemcell is found before it is uploaded to the CPI.
+func (c InspectStemcellCmd) verifyImage(extractedPath, sha1 string) error {
+	if len(sha1) == 0 {
+		c.ui.PrintLinef("Stemcell manifest does not include an image digest")
+		return nil
+	}
+
+	digest, err := boshcrypto.ParseMultipleDigest(sha1)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Parsing stemcell image digest")
+	}
+
+	err = digest.VerifyFilePath(filepath.Join(extractedPath, "image"), c.fs)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Verifying stemcell image")
+	}
+
+	c.ui.PrintLinef("Stemcell image matches its digest")
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package cmd_test
+
+import (
+	"errors"
+
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/cmd"
+	bistemcell "github.com/cloudfoundry/bosh-cli/stemcell"
+	"github.com/cloudfoundry/bosh-cli/stemcell/stemcellfakes"
+	fakeui "github.com/cloudfoundry/bosh-cli/ui/fakes"
+	boshtbl "github.com/cloudfoundry/bosh-cli/ui/table"
+)
+
+var _ = Describe("InspectStemcellCmd", func() {
+	var (
+		fs                *fakesys.FakeFileSystem
+		ui                *fakeui.FakeUI
+		extractor         *stemcellfakes.FakeExtractor
+		extractedStemcell *stemcellfakes.FakeExtractedStemcell
+		manifest          bistemcell.Manifest
+		command           InspectStemcellCmd
+		opts              InspectStemcellOpts
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		ui = &fakeui.FakeUI{}
+		extractor = stemcellfakes.NewFakeExtractor()
+		command = NewInspectStemcellCmd(ui, fs, extractor)
+
+		opts = InspectStemcellOpts{
+			Args: InspectStemcellArgs{PathToStemcell: "some-stemcell.tgz"},
+		}
+
+		manifest = bistemcell.Manifest{
+			Name:            "fake-stemcell-name",
+			Version:         "3421",
+			OS:              "ubuntu-trusty",
+			SHA1:            "2aae6c35c94fcfb415dbe95f408b9ce91ee846ed",
+			BoshProtocol:    "1",
+			APIVersion:      2,
+			CloudProperties: biproperty.Map{"infrastructure": "aws"},
+		}
+
+		extractedStemcell = &stemcellfakes.FakeExtractedStemcell{}
+		extractedStemcell.ManifestStub = func() bistemcell.Manifest { return manifest }
+		extractedStemcell.GetExtractedPathReturns("/extracted-path")
+		extractor.SetExtractBehavior("some-stemcell.tgz", extractedStemcell, nil)
+
+		fs.WriteFileString("/extracted-path/image", "hello world")
+	})
+
+	Describe("Run", func() {
+		It("prints the stemcell manifest", func() {
+			err := command.Run(opts)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(ui.Tables[0]).To(Equal(boshtbl.Table{
+				Header: []boshtbl.Header{
+					boshtbl.NewHeader("Name"),
+					boshtbl.NewHeader("Version"),
+					boshtbl.NewHeader("OS"),
+					boshtbl.NewHeader("API Version"),
+					boshtbl.NewHeader("BOSH Protocol"),
+					boshtbl.NewHeader("Image Digest"),
+					boshtbl.NewHeader("Cloud Properties"),
+				},
+				Rows: [][]boshtbl.Value{
+					{
+						boshtbl.NewValueString("fake-stemcell-name"),
+						boshtbl.NewValueString("3421"),
+						boshtbl.NewValueString("ubuntu-trusty"),
+						boshtbl.NewValueString("2"),
+						boshtbl.NewValueString("1"),
+						boshtbl.NewValueString("2aae6c35c94fcfb415dbe95f408b9ce91ee846ed"),
+						boshtbl.NewValueInterface(biproperty.Map{"infrastructure": "aws"}),
+					},
+				},
+				Transpose: true,
+			}))
+		})
+
+		It("leaves the API version empty when the stemcell does not have one", func() {
+			manifest.APIVersion = 0
+
+			err := command.Run(opts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ui.Tables[0].Rows[0][3]).To(Equal(boshtbl.NewValueString("")))
+		})
+
+		It("verifies the image against its digest", func() {
+			err := command.Run(opts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ui.Said).To(ContainElement("Stemcell image matches its digest"))
+		})
+
+		It("returns error if the image does not match its digest", func() {
+			fs.WriteFileString("/extracted-path/image", "corrupted")
+
+			err := command.Run(opts)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Verifying stemcell image"))
+			Expect(ui.Tables).To(HaveLen(1))
+		})
+
+		It("skips verification if the manifest does not include a digest", func() {
+			manifest.SHA1 = ""
+
+			err := command.Run(opts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ui.Said).To(ContainElement("Stemcell manifest does not include an image digest"))
+		})
+
+		It("cleans up the extracted stemcell", func() {
+			err := command.Run(opts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(extractedStemcell.CleanupCallCount()).To(Equal(1))
+		})
+
+		It("returns error if extracting the stemcell fails", func() {
+			extractor.SetExtractBehavior("some-stemcell.tgz", nil, errors.New("fake-err"))
+
+			err := command.Run(opts)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-err"))
+		})
+	})
+})
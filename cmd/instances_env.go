@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"github.com/cppforlife/go-patch/patch"
+
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	boshui "github.com/cloudfoundry/bosh-cli/ui"
+	boshtbl "github.com/cloudfoundry/bosh-cli/ui/table"
+)
+
+type InstancesEnvCmd struct {
+	envProvider func(string, string, boshtpl.Variables, patch.Op) EnvAgent
+	ui          boshui.UI
+}
+
+func NewInstancesEnvCmd(envProvider func(string, string, boshtpl.Variables, patch.Op) EnvAgent, ui boshui.UI) InstancesEnvCmd {
+	return InstancesEnvCmd{envProvider: envProvider, ui: ui}
+}
+
+func (c InstancesEnvCmd) Run(opts InstancesEnvOpts) error {
+	agent := c.envProvider(
+		opts.Args.Manifest.Path, opts.StatePath, opts.VarFlags.AsVariables(), opts.OpsFlags.AsOp())
+
+	indexes, err := agent.InstanceIndexes()
+	if err != nil {
+		return err
+	}
+
+	instTable := InstanceTable{
+		Processes: opts.Processes,
+		Details:   opts.Details,
+		Vitals:    opts.Vitals,
+	}
+
+	table := boshtbl.Table{
+		Content: "instances",
+
+		Header: append(instTable.Headers(), boshtbl.NewHeader("BOSH Protocol")),
+
+		WrapToTerminal: true,
+	}
+
+	for _, index := range indexes {
+		info, err := agent.InstanceInfo(index)
+		if err != nil {
+			return err
+		}
+
+		row := append(instTable.AsValues(instTable.ForVMInfo(info.VMInfo)), boshtbl.NewValueString(info.BoshProtocol))
+
+		section := boshtbl.Section{
+			FirstColumn: row[0],
+			Rows:        [][]boshtbl.Value{row},
+		}
+
+		if opts.Processes {
+			for _, p := range info.Processes {
+				row := append(instTable.AsValues(instTable.ForProcess(p)), boshtbl.ValueString{})
+				section.Rows = append(section.Rows, row)
+			}
+		}
+
+		table.Sections = append(table.Sections, section)
+	}
+
+	c.ui.PrintTable(table)
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package cmd_test
+
+import (
+	"errors"
+
+	"github.com/cppforlife/go-patch/patch"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/cmd"
+	mock_cmd "github.com/cloudfoundry/bosh-cli/cmd/mocks"
+	boshdir "github.com/cloudfoundry/bosh-cli/director"
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	fakeui "github.com/cloudfoundry/bosh-cli/ui/fakes"
+	boshtbl "github.com/cloudfoundry/bosh-cli/ui/table"
+)
+
+var _ = Describe("InstancesEnvCmd", func() {
+	var mockCtrl *gomock.Controller
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	var (
+		mockEnvAgent *mock_cmd.MockEnvAgent
+		ui           *fakeui.FakeUI
+		command      InstancesEnvCmd
+
+		opts InstancesEnvOpts
+		info EnvInstanceInfo
+	)
+
+	BeforeEach(func() {
+		mockEnvAgent = mock_cmd.NewMockEnvAgent(mockCtrl)
+		ui = &fakeui.FakeUI{}
+
+		envProvider := func(_ string, _ string, _ boshtpl.Variables, _ patch.Op) EnvAgent {
+			return mockEnvAgent
+		}
+
+		command = NewInstancesEnvCmd(envProvider, ui)
+
+		opts = InstancesEnvOpts{
+			Args: InstancesEnvArgs{
+				Manifest: FileBytesWithPathArg{Path: "/path/to/bosh.yml"},
+			},
+		}
+
+		index := 0
+
+		info = EnvInstanceInfo{
+			VMInfo: boshdir.VMInfo{
+				AgentID:      "fake-agent-id",
+				JobName:      "bosh",
+				Index:        &index,
+				ProcessState: "running",
+				IPs:          []string{"10.0.0.6"},
+				VMID:         "fake-vm-cid",
+				DiskIDs:      []string{"fake-disk-cid"},
+
+				Processes: []boshdir.VMInfoProcess{
+					{Name: "nats", State: "running"},
+					{Name: "director", State: "failing"},
+				},
+			},
+			BoshProtocol: "1",
+		}
+	})
+
+	act := func() error { return command.Run(opts) }
+
+	It("lists the instance reported by the agent", func() {
+		mockEnvAgent.EXPECT().InstanceIndexes().Return([]int{0}, nil)
+		mockEnvAgent.EXPECT().InstanceInfo(0).Return(info, nil)
+
+		Expect(act()).ToNot(HaveOccurred())
+
+		Expect(ui.Tables).To(Equal([]boshtbl.Table{
+			{
+				Content: "instances",
+
+				WrapToTerminal: true,
+
+				Header: []boshtbl.Header{
+					boshtbl.NewHeader("Instance"),
+					boshtbl.NewHeader("Process State"),
+					boshtbl.NewHeader("AZ"),
+					boshtbl.NewHeader("IPs"),
+					boshtbl.NewHeader("BOSH Protocol"),
+				},
+
+				Sections: []boshtbl.Section{
+					{
+						FirstColumn: boshtbl.NewValueString("bosh"),
+						Rows: [][]boshtbl.Value{
+							{
+								boshtbl.NewValueString("bosh"),
+								boshtbl.ValueFmt{
+									V:     boshtbl.NewValueString("running"),
+									Error: true,
+								},
+								boshtbl.NewValueString(""),
+								boshtbl.NewValueStrings([]string{"10.0.0.6"}),
+								boshtbl.NewValueString("1"),
+							},
+						},
+					},
+				},
+			},
+		}))
+	})
+
+	It("lists every instance reported by its agent", func() {
+		secondIndex := 1
+		secondInfo := info
+		secondInfo.Index = &secondIndex
+		secondInfo.IPs = []string{"10.0.0.7"}
+
+		mockEnvAgent.EXPECT().InstanceIndexes().Return([]int{0, 1}, nil)
+		mockEnvAgent.EXPECT().InstanceInfo(0).Return(info, nil)
+		mockEnvAgent.EXPECT().InstanceInfo(1).Return(secondInfo, nil)
+
+		Expect(act()).ToNot(HaveOccurred())
+
+		Expect(ui.Tables).To(HaveLen(1))
+		sections := ui.Tables[0].Sections
+		Expect(sections).To(HaveLen(2))
+		Expect(sections[0].Rows[0][3]).To(Equal(boshtbl.NewValueStrings([]string{"10.0.0.6"})))
+		Expect(sections[1].Rows[0][3]).To(Equal(boshtbl.NewValueStrings([]string{"10.0.0.7"})))
+	})
+
+	It("lists processes and details when requested", func() {
+		opts.Processes = true
+		opts.Details = true
+
+		mockEnvAgent.EXPECT().InstanceIndexes().Return([]int{0}, nil)
+		mockEnvAgent.EXPECT().InstanceInfo(0).Return(info, nil)
+
+		Expect(act()).ToNot(HaveOccurred())
+
+		Expect(ui.Tables).To(HaveLen(1))
+		table := ui.Tables[0]
+
+		Expect(table.Header).To(ContainElement(boshtbl.NewHeader("Process")))
+		Expect(table.Header).To(ContainElement(boshtbl.NewHeader("VM CID")))
+		Expect(table.Header).To(ContainElement(boshtbl.NewHeader("Disk CIDs")))
+
+		rows := table.Sections[0].Rows
+		Expect(rows).To(HaveLen(3))
+		Expect(rows[0]).To(ContainElement(boshtbl.NewValueString("fake-vm-cid")))
+		Expect(rows[0]).To(ContainElement(boshtbl.NewValueStrings([]string{"fake-disk-cid"})))
+		Expect(rows[1][1]).To(Equal(boshtbl.NewValueString("nats")))
+		Expect(rows[2][1]).To(Equal(boshtbl.NewValueString("director")))
+		Expect(rows[2][2]).To(Equal(boshtbl.ValueFmt{V: boshtbl.NewValueString("failing"), Error: true}))
+		Expect(rows[2]).To(HaveLen(len(table.Header)))
+	})
+
+	It("returns an error when the agent state cannot be retrieved", func() {
+		mockEnvAgent.EXPECT().InstanceIndexes().Return([]int{0}, nil)
+		mockEnvAgent.EXPECT().InstanceInfo(0).Return(EnvInstanceInfo{}, errors.New("fake-state-error"))
+
+		err := act()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-state-error"))
+	})
+})
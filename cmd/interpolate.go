@@ -23,6 +23,7 @@ func (c InterpolateCmd) Run(opts InterpolateOpts) error {
 	evalOpts := boshtpl.EvaluateOpts{
 		ExpectAllKeys:     opts.VarErrors,
 		ExpectAllVarsUsed: opts.VarErrorsUnused,
+		VarSources:        opts.VarFlags.Sources(),
 	}
 
 	if opts.Path.IsSet() {
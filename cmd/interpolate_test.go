@@ -130,7 +130,7 @@ var _ = Describe("InterpolateCmd", func() {
 
 			err := act()
 			Expect(err).To(HaveOccurred())
-			Expect(err.Error()).To(ContainSubstring("Expected to find variables: name2"))
+			Expect(err.Error()).To(ContainSubstring("Expected to find variables (searched --var): name2"))
 		})
 
 		It("returns error if variables are not used in templated manifest if var-errs-unused flag is set", func() {
@@ -54,7 +54,7 @@ func (c LogsCmd) tail(opts LogsOpts) error {
 		_ = c.deployment.CleanUpSSH(opts.Args.Slug, sshOpts)
 	}()
 
-	err = c.nonIntSSHRunner.Run(connOpts, result, c.buildTailCmd(opts))
+	err = c.nonIntSSHRunner.Run(connOpts, result, buildLogsTailCmd(opts))
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Running follow over non-interactive SSH")
 	}
@@ -62,7 +62,7 @@ func (c LogsCmd) tail(opts LogsOpts) error {
 	return nil
 }
 
-func (c LogsCmd) buildTailCmd(opts LogsOpts) []string {
+func buildLogsTailCmd(opts LogsOpts) []string {
 	cmd := []string{"sudo", "tail"}
 
 	if opts.Follow {
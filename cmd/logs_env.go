@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshfu "github.com/cloudfoundry/bosh-utils/fileutil"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"github.com/cppforlife/go-patch/patch"
+	"github.com/pivotal-golang/clock"
+
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	boshui "github.com/cloudfoundry/bosh-cli/ui"
+)
+
+// logsFollowInterval is how long to wait before fetching the logs again
+// while following them
+const logsFollowInterval = 5 * time.Second
+
+// logsDefaultNum is the number of last lines shown when following logs
+// without --num, like tail does
+const logsDefaultNum = 10
+
+type LogsEnvCmd struct {
+	envProvider func(string, string, boshtpl.Variables, patch.Op) EnvAgent
+	timeService clock.Clock
+	fs          boshsys.FileSystem
+	ui          boshui.UI
+}
+
+func NewLogsEnvCmd(
+	envProvider func(string, string, boshtpl.Variables, patch.Op) EnvAgent,
+	timeService clock.Clock,
+	fs boshsys.FileSystem,
+	ui boshui.UI,
+) LogsEnvCmd {
+	return LogsEnvCmd{
+		envProvider: envProvider,
+		timeService: timeService,
+		fs:          fs,
+		ui:          ui,
+	}
+}
+
+func (c LogsEnvCmd) Run(opts LogsEnvOpts) error {
+	agent := c.envProvider(
+		opts.Args.Manifest.Path, opts.StatePath, opts.VarFlags.AsVariables(), opts.OpsFlags.AsOp())
+
+	if opts.Follow || opts.Num > 0 {
+		return c.tail(agent, opts)
+	}
+
+	return c.fetch(agent, opts)
+}
+
+// tail prints the last lines of the logs fetched through the agent. When
+// following, the logs are fetched again every logsFollowInterval and only
+// what was appended to them since is printed.
+func (c LogsEnvCmd) tail(agent EnvAgent, opts LogsEnvOpts) error {
+	// Agent filters are globs relative to the logs directory
+	var filters []string
+
+	if len(opts.Jobs) > 0 {
+		for _, job := range opts.Jobs {
+			filters = append(filters, fmt.Sprintf("%s/*.log", job))
+		}
+	} else if len(opts.Filters) > 0 {
+		filters = opts.Filters
+	} else {
+		filters = []string{"**/*.log"}
+	}
+
+	num := opts.Num
+	if num == 0 {
+		num = logsDefaultNum
+	}
+
+	// offsets holds the size of every log file already printed
+	offsets := map[string]int{}
+
+	for {
+		blob, err := agent.FetchLogs(opts.Index, filters, opts.Agent)
+		if err != nil {
+			return err
+		}
+
+		err = c.printLogs(blob.Path(), offsets, num, opts.Quiet)
+		blob.DeleteSilently()
+		if err != nil {
+			return err
+		}
+
+		if !opts.Follow {
+			return nil
+		}
+
+		c.timeService.Sleep(logsFollowInterval)
+	}
+}
+
+// printLogs prints the last lines of the log files in the tarball that were
+// not printed before, and what was appended to the others. A file that
+// shrank, e.g. because it was rotated, is printed again from its start.
+func (c LogsEnvCmd) printLogs(tarballPath string, offsets map[string]int, num int, quiet bool) error {
+	file, err := c.fs.OpenFile(tarballPath, os.O_RDONLY, 0)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Opening logs '%s'", tarballPath)
+	}
+
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading logs '%s'", tarballPath)
+	}
+
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return bosherr.WrapErrorf(err, "Reading logs '%s'", tarballPath)
+		}
+
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		content, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Reading log '%s'", header.Name)
+		}
+
+		name := strings.TrimPrefix(filepath.Clean(header.Name), "./")
+
+		offset, found := offsets[name]
+
+		var appended []byte
+
+		if !found {
+			appended = lastLines(content, num)
+		} else if len(content) < offset {
+			appended = content
+		} else {
+			appended = content[offset:]
+		}
+
+		offsets[name] = len(content)
+
+		if len(appended) == 0 {
+			continue
+		}
+
+		if !quiet {
+			c.ui.PrintLinef("==> %s <==", name)
+		}
+
+		c.ui.PrintBlock(string(appended))
+	}
+}
+
+// lastLines returns the last num lines of the content
+func lastLines(content []byte, num int) []byte {
+	end := len(bytes.TrimSuffix(content, []byte("\n")))
+
+	for i := end - 1; i >= 0; i-- {
+		if content[i] == '\n' {
+			num--
+			if num == 0 {
+				return content[i+1:]
+			}
+		}
+	}
+
+	return content
+}
+
+func (c LogsEnvCmd) fetch(agent EnvAgent, opts LogsEnvOpts) error {
+	// Agent filters are globs relative to the logs directory
+	var filters []string
+
+	for _, job := range opts.Jobs {
+		filters = append(filters, fmt.Sprintf("%s/**/*", job))
+	}
+
+	filters = append(filters, opts.Filters...)
+
+	manifestName := filepath.Base(opts.Args.Manifest.Path)
+	prefix := strings.TrimSuffix(manifestName, filepath.Ext(manifestName))
+	tsSuffix := strings.Replace(c.timeService.Now().Format("20060102-150405.999999999"), ".", "-", -1)
+
+	dstFilePath := filepath.Join(opts.Directory.Path, fmt.Sprintf("%s-logs-%s.tgz", prefix, tsSuffix))
+
+	c.ui.PrintLinef("Fetching logs to '%s'...", dstFilePath)
+
+	blob, err := agent.FetchLogs(opts.Index, filters, opts.Agent)
+	if err != nil {
+		return err
+	}
+
+	err = boshfu.NewFileMover(c.fs).Move(blob.Path(), dstFilePath)
+	if err != nil {
+		blob.DeleteSilently()
+		return bosherr.WrapErrorf(err, "Moving to final destination")
+	}
+
+	return nil
+}
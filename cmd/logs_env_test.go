@@ -0,0 +1,242 @@
+package cmd_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"time"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	"github.com/cppforlife/go-patch/patch"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	biblobstore "github.com/cloudfoundry/bosh-cli/blobstore"
+	. "github.com/cloudfoundry/bosh-cli/cmd"
+	mock_cmd "github.com/cloudfoundry/bosh-cli/cmd/mocks"
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	fakeui "github.com/cloudfoundry/bosh-cli/ui/fakes"
+)
+
+var _ = Describe("LogsEnvCmd", func() {
+	var mockCtrl *gomock.Controller
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	var (
+		mockEnvAgent *mock_cmd.MockEnvAgent
+		timeService  *fakeclock.FakeClock
+		fs           *fakesys.FakeFileSystem
+		ui           *fakeui.FakeUI
+		command      LogsEnvCmd
+
+		opts LogsEnvOpts
+	)
+
+	BeforeEach(func() {
+		mockEnvAgent = mock_cmd.NewMockEnvAgent(mockCtrl)
+		fs = fakesys.NewFakeFileSystem()
+		ui = &fakeui.FakeUI{}
+		timeService = fakeclock.NewFakeClock(time.Date(2009, time.November, 10, 23, 1, 2, 333, time.UTC))
+
+		envProvider := func(_ string, _ string, _ boshtpl.Variables, _ patch.Op) EnvAgent {
+			return mockEnvAgent
+		}
+
+		command = NewLogsEnvCmd(envProvider, timeService, fs, ui)
+
+		opts = LogsEnvOpts{
+			Args: LogsEnvArgs{
+				Manifest: FileBytesWithPathArg{Path: "/path/to/bosh.yml"},
+			},
+
+			Directory: DirOrCWDArg{Path: "/fake-dir"},
+		}
+	})
+
+	act := func() error { return command.Run(opts) }
+
+	Context("when fetching logs (not tailing)", func() {
+		var blob biblobstore.LocalBlob
+
+		BeforeEach(func() {
+			fs.WriteFileString("/tmp/fetched-logs", "logs")
+			fs.MkdirAll("/fake-dir", 0755)
+			blob = biblobstore.NewLocalBlob("/tmp/fetched-logs", fs, boshlog.NewLogger(boshlog.LevelNone))
+		})
+
+		It("fetches all job logs into the destination directory", func() {
+			mockEnvAgent.EXPECT().FetchLogs(0, nil, false).Return(blob, nil)
+
+			Expect(act()).ToNot(HaveOccurred())
+
+			dstPath := "/fake-dir/bosh-logs-20091110-230102-000000333.tgz"
+			Expect(fs.ReadFileString(dstPath)).To(Equal("logs"))
+			Expect(fs.FileExists("/tmp/fetched-logs")).To(BeFalse())
+			Expect(ui.Said).To(ContainElement("Fetching logs to '" + dstPath + "'..."))
+		})
+
+		It("fetches logs of selected jobs and filters", func() {
+			opts.Jobs = []string{"nats", "director"}
+			opts.Filters = []string{"*.log"}
+
+			mockEnvAgent.EXPECT().FetchLogs(0, []string{"nats/**/*", "director/**/*", "*.log"}, false).Return(blob, nil)
+
+			Expect(act()).ToNot(HaveOccurred())
+		})
+
+		It("fetches agent logs", func() {
+			opts.Agent = true
+
+			mockEnvAgent.EXPECT().FetchLogs(0, nil, true).Return(blob, nil)
+
+			Expect(act()).ToNot(HaveOccurred())
+		})
+
+		It("fetches logs of the instance with the index", func() {
+			opts.Index = 1
+
+			mockEnvAgent.EXPECT().FetchLogs(1, nil, false).Return(blob, nil)
+
+			Expect(act()).ToNot(HaveOccurred())
+		})
+
+		It("returns an error when fetching logs fails", func() {
+			mockEnvAgent.EXPECT().FetchLogs(0, nil, false).Return(nil, errors.New("fake-fetch-error"))
+
+			err := act()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-fetch-error"))
+		})
+	})
+
+	Context("when tailing logs", func() {
+		var fetched int
+
+		// logsBlob returns a blob of a logs tarball with the files, at a path
+		// of its own since the fake file system keeps opened files
+		logsBlob := func(files map[string]string) biblobstore.LocalBlob {
+			var buffer bytes.Buffer
+
+			gzipWriter := gzip.NewWriter(&buffer)
+			tarWriter := tar.NewWriter(gzipWriter)
+
+			for _, name := range []string{"./nats/nats.log", "./director/director.log"} {
+				content, found := files[name]
+				if !found {
+					continue
+				}
+
+				err := tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+				Expect(err).ToNot(HaveOccurred())
+				_, err = tarWriter.Write([]byte(content))
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			Expect(tarWriter.Close()).To(Succeed())
+			Expect(gzipWriter.Close()).To(Succeed())
+
+			fetched++
+			path := fmt.Sprintf("/tmp/fetched-logs-%d", fetched)
+			fs.WriteFile(path, buffer.Bytes())
+
+			return biblobstore.NewLocalBlob(path, fs, boshlog.NewLogger(boshlog.LevelNone))
+		}
+
+		BeforeEach(func() {
+			fetched = 0
+		})
+
+		It("prints the last lines of the job logs fetched through the agent", func() {
+			opts.Num = 2
+
+			mockEnvAgent.EXPECT().FetchLogs(0, []string{"**/*.log"}, false).Return(logsBlob(map[string]string{
+				"./nats/nats.log":         "nats-1\nnats-2\nnats-3\n",
+				"./director/director.log": "director-1\n",
+			}), nil)
+
+			Expect(act()).ToNot(HaveOccurred())
+
+			Expect(ui.Said).To(Equal([]string{"==> nats/nats.log <==", "==> director/director.log <=="}))
+			Expect(ui.Blocks).To(Equal([]string{"nats-2\nnats-3\n", "director-1\n"}))
+			Expect(fs.FileExists("/tmp/fetched-logs-1")).To(BeFalse())
+		})
+
+		It("fetches the logs of selected jobs, or matching the filters, from the instance with the index", func() {
+			opts.Num = 1
+			opts.Index = 1
+			opts.Jobs = []string{"nats"}
+
+			mockEnvAgent.EXPECT().FetchLogs(1, []string{"nats/*.log"}, false).Return(logsBlob(nil), nil)
+
+			Expect(act()).ToNot(HaveOccurred())
+
+			opts.Jobs = nil
+			opts.Filters = []string{"nats/*.log"}
+			opts.Agent = true
+
+			mockEnvAgent.EXPECT().FetchLogs(1, []string{"nats/*.log"}, true).Return(logsBlob(nil), nil)
+
+			Expect(act()).ToNot(HaveOccurred())
+		})
+
+		It("does not print headers when quiet", func() {
+			opts.Num = 1
+			opts.Quiet = true
+
+			mockEnvAgent.EXPECT().FetchLogs(0, gomock.Any(), false).Return(logsBlob(map[string]string{
+				"./nats/nats.log": "nats-1\n",
+			}), nil)
+
+			Expect(act()).ToNot(HaveOccurred())
+
+			Expect(ui.Said).To(BeEmpty())
+			Expect(ui.Blocks).To(Equal([]string{"nats-1\n"}))
+		})
+
+		It("follows the logs by fetching them again and printing what was appended", func() {
+			opts.Follow = true
+
+			gomock.InOrder(
+				mockEnvAgent.EXPECT().FetchLogs(0, []string{"**/*.log"}, false).Return(logsBlob(map[string]string{
+					"./nats/nats.log": "nats-1\n",
+				}), nil),
+				mockEnvAgent.EXPECT().FetchLogs(0, []string{"**/*.log"}, false).Return(logsBlob(map[string]string{
+					"./nats/nats.log":         "nats-1\nnats-2\n",
+					"./director/director.log": "director-1\n",
+				}), nil),
+				mockEnvAgent.EXPECT().FetchLogs(0, []string{"**/*.log"}, false).Return(logsBlob(map[string]string{
+					"./nats/nats.log":         "nats-rotated\n",
+					"./director/director.log": "director-1\n",
+				}), nil),
+				mockEnvAgent.EXPECT().FetchLogs(0, []string{"**/*.log"}, false).Return(nil, errors.New("fake-fetch-error")),
+			)
+
+			errCh := make(chan error, 1)
+			go func() { errCh <- act() }()
+
+			for i := 0; i < 3; i++ {
+				Eventually(timeService.WatcherCount).Should(Equal(1))
+				timeService.Increment(5 * time.Second)
+			}
+
+			var err error
+			Eventually(errCh).Should(Receive(&err))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-fetch-error"))
+
+			Expect(ui.Blocks).To(Equal([]string{"nats-1\n", "nats-2\n", "director-1\n", "nats-rotated\n"}))
+		})
+	})
+})
@@ -1,9 +1,13 @@
 // Automatically generated by MockGen. DO NOT EDIT!
-// Source: github.com/cloudfoundry/bosh-cli/cmd (interfaces: DeploymentDeleter)
+// Source: github.com/cloudfoundry/bosh-cli/cmd (interfaces: DeploymentDeleter,EnvAgent,EnvDisks)
 
 package mocks
 
 import (
+	blobstore "github.com/cloudfoundry/bosh-cli/blobstore"
+	cmd "github.com/cloudfoundry/bosh-cli/cmd"
+	config "github.com/cloudfoundry/bosh-cli/config"
+	director "github.com/cloudfoundry/bosh-cli/director"
 	ui "github.com/cloudfoundry/bosh-cli/ui"
 	gomock "github.com/golang/mock/gomock"
 )
@@ -29,6 +33,16 @@ func (_m *MockDeploymentDeleter) EXPECT() *_MockDeploymentDeleterRecorder {
 	return _m.recorder
 }
 
+func (_m *MockDeploymentDeleter) DeleteOrphanedDisk(_param0 string, _param1 ui.Stage) error {
+	ret := _m.ctrl.Call(_m, "DeleteOrphanedDisk", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDeploymentDeleterRecorder) DeleteOrphanedDisk(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteOrphanedDisk", arg0, arg1)
+}
+
 func (_m *MockDeploymentDeleter) DeleteDeployment(_param0 ui.Stage) error {
 	ret := _m.ctrl.Call(_m, "DeleteDeployment", _param0)
 	ret0, _ := ret[0].(error)
@@ -38,3 +52,141 @@ func (_m *MockDeploymentDeleter) DeleteDeployment(_param0 ui.Stage) error {
 func (_mr *_MockDeploymentDeleterRecorder) DeleteDeployment(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteDeployment", arg0)
 }
+
+func (_m *MockDeploymentDeleter) ForceDeleteDeployment(_param0 ui.Stage) error {
+	ret := _m.ctrl.Call(_m, "ForceDeleteDeployment", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDeploymentDeleterRecorder) ForceDeleteDeployment(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ForceDeleteDeployment", arg0)
+}
+
+// Mock of EnvAgent interface
+type MockEnvAgent struct {
+	ctrl     *gomock.Controller
+	recorder *_MockEnvAgentRecorder
+}
+
+// Recorder for MockEnvAgent (not exported)
+type _MockEnvAgentRecorder struct {
+	mock *MockEnvAgent
+}
+
+func NewMockEnvAgent(ctrl *gomock.Controller) *MockEnvAgent {
+	mock := &MockEnvAgent{ctrl: ctrl}
+	mock.recorder = &_MockEnvAgentRecorder{mock}
+	return mock
+}
+
+func (_m *MockEnvAgent) EXPECT() *_MockEnvAgentRecorder {
+	return _m.recorder
+}
+
+func (_m *MockEnvAgent) CleanUpSSH(_param0 int, _param1 director.SSHOpts) error {
+	ret := _m.ctrl.Call(_m, "CleanUpSSH", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockEnvAgentRecorder) CleanUpSSH(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "CleanUpSSH", arg0, arg1)
+}
+
+func (_m *MockEnvAgent) FetchLogs(_param0 int, _param1 []string, _param2 bool) (blobstore.LocalBlob, error) {
+	ret := _m.ctrl.Call(_m, "FetchLogs", _param0, _param1, _param2)
+	ret0, _ := ret[0].(blobstore.LocalBlob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockEnvAgentRecorder) FetchLogs(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "FetchLogs", arg0, arg1, arg2)
+}
+
+func (_m *MockEnvAgent) InstanceIndexes() ([]int, error) {
+	ret := _m.ctrl.Call(_m, "InstanceIndexes")
+	ret0, _ := ret[0].([]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockEnvAgentRecorder) InstanceIndexes() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "InstanceIndexes")
+}
+
+func (_m *MockEnvAgent) InstanceInfo(_param0 int) (cmd.EnvInstanceInfo, error) {
+	ret := _m.ctrl.Call(_m, "InstanceInfo", _param0)
+	ret0, _ := ret[0].(cmd.EnvInstanceInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockEnvAgentRecorder) InstanceInfo(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "InstanceInfo", arg0)
+}
+
+func (_m *MockEnvAgent) RunErrand(_param0 int, _param1 string) (director.ErrandResult, error) {
+	ret := _m.ctrl.Call(_m, "RunErrand", _param0, _param1)
+	ret0, _ := ret[0].(director.ErrandResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockEnvAgentRecorder) RunErrand(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RunErrand", arg0, arg1)
+}
+
+func (_m *MockEnvAgent) SetUpSSH(_param0 int, _param1 director.SSHOpts) (director.SSHResult, error) {
+	ret := _m.ctrl.Call(_m, "SetUpSSH", _param0, _param1)
+	ret0, _ := ret[0].(director.SSHResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockEnvAgentRecorder) SetUpSSH(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetUpSSH", arg0, arg1)
+}
+
+// Mock of EnvDisks interface
+type MockEnvDisks struct {
+	ctrl     *gomock.Controller
+	recorder *_MockEnvDisksRecorder
+}
+
+// Recorder for MockEnvDisks (not exported)
+type _MockEnvDisksRecorder struct {
+	mock *MockEnvDisks
+}
+
+func NewMockEnvDisks(ctrl *gomock.Controller) *MockEnvDisks {
+	mock := &MockEnvDisks{ctrl: ctrl}
+	mock.recorder = &_MockEnvDisksRecorder{mock}
+	return mock
+}
+
+func (_m *MockEnvDisks) EXPECT() *_MockEnvDisksRecorder {
+	return _m.recorder
+}
+
+func (_m *MockEnvDisks) AttachDisk(_param0 string, _param1 int) error {
+	ret := _m.ctrl.Call(_m, "AttachDisk", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockEnvDisksRecorder) AttachDisk(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AttachDisk", arg0, arg1)
+}
+
+func (_m *MockEnvDisks) Disks() ([]config.DiskRecord, error) {
+	ret := _m.ctrl.Call(_m, "Disks")
+	ret0, _ := ret[0].([]config.DiskRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockEnvDisksRecorder) Disks() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Disks")
+}
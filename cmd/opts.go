@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"time"
+
 	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
 	"github.com/cppforlife/go-patch/patch"
 
@@ -35,6 +37,15 @@ type BoshOpts struct {
 	NoColorOpt        bool        `long:"no-color"                  description:"Toggle colorized output"`
 	NonInteractiveOpt bool        `long:"non-interactive" short:"n" description:"Don't ask for user input" env:"BOSH_NON_INTERACTIVE"`
 
+	RedactOpt string `long:"redact" value-name:"BOOL" description:"Mask secrets in logs and error messages" choice:"true" choice:"false" default:"true"`
+
+	EventLogOpt string `long:"event-log" value-name:"PATH" description:"Write stage, CPI call, agent call and error events of the run as NDJSON to path"`
+
+	TmpDirOpt string `long:"tmp-dir" value-name:"DIR" description:"Directory for temporary files, e.g. extracted releases and stemcells"`
+
+	CPITraceOpt  string `long:"cpi-trace"  value-name:"DIR" description:"Write every CPI request and response with its timing as JSON to a file in directory"`
+	CPIReplayOpt string `long:"cpi-replay" value-name:"DIR" description:"Answer CPI calls with the calls traced to directory by --cpi-trace instead of running the CPI"`
+
 	Help HelpOpts `command:"help" description:"Show this help message"`
 
 	// -----> Director management
@@ -44,8 +55,21 @@ type BoshOpts struct {
 	Environments EnvironmentsOpts `command:"environments" alias:"envs" description:"List environments"`
 	CreateEnv    CreateEnvOpts    `command:"create-env"                description:"Create or update BOSH environment"`
 	DeleteEnv    DeleteEnvOpts    `command:"delete-env"                description:"Delete BOSH environment"`
+	DiffEnv      DiffEnvOpts      `command:"diff-env"                  description:"Show manifest changes since BOSH environment was last created or updated"`
+	ValidateEnv  ValidateEnvOpts  `command:"validate-env"              description:"Validate manifest, releases, stemcell and CPI of BOSH environment without changing it"`
+	SSHEnv       SSHEnvOpts       `command:"ssh-env"                   description:"SSH into BOSH environment VM"`
+	LogsEnv      LogsEnvOpts      `command:"logs-env"                  description:"Fetch logs from BOSH environment VM"`
+	InstancesEnv InstancesEnvOpts `command:"instances-env"             description:"Show BOSH environment VM state reported by its agent"`
+	RunErrandEnv RunErrandEnvOpts `command:"run-errand-env"            description:"Run errand on BOSH environment VM"`
+	StateEnv     StateEnvOpts     `command:"state-env"                 description:"Show deployment state of BOSH environment"`
+	DiagnoseEnv  DiagnoseEnvOpts  `command:"diagnose-env"              description:"Collect logs and state of BOSH environment for troubleshooting"`
 	AliasEnv     AliasEnvOpts     `command:"alias-env"                 description:"Alias environment to save URL and CA certificate"`
 
+	// Environment disks
+	DisksEnv      DisksEnvOpts      `command:"disks-env"       description:"List persistent disks of BOSH environment"`
+	AttachDiskEnv AttachDiskEnvOpts `command:"attach-disk-env" description:"Attach orphaned disk to BOSH environment VM on next create-env"`
+	DeleteDiskEnv DeleteDiskEnvOpts `command:"delete-disk-env" description:"Delete orphaned disk of BOSH environment"`
+
 	// Authentication
 	LogIn  LogInOpts  `command:"log-in"  alias:"l" alias:"login"  description:"Log in"`
 	LogOut LogOutOpts `command:"log-out"           alias:"logout" description:"Log out"`
@@ -91,6 +115,8 @@ type BoshOpts struct {
 	DeleteStemcell DeleteStemcellOpts `command:"delete-stemcell" alias:"dels" description:"Delete stemcell"`
 	RepackStemcell RepackStemcellOpts `command:"repack-stemcell"              description:"Repack stemcell"`
 
+	InspectStemcell InspectStemcellOpts `command:"inspect-stemcell" description:"Display information from stemcell tarball"`
+
 	// Releases
 	Releases       ReleasesOpts       `command:"releases"        alias:"rs"   description:"List releases"`
 	UploadRelease  UploadReleaseOpts  `command:"upload-release"  alias:"ur"   description:"Upload release"`
@@ -143,6 +169,9 @@ type BoshOpts struct {
 	GeneratePackage GeneratePackageOpts `command:"generate-package"            description:"Generate package"`
 	CreateRelease   CreateReleaseOpts   `command:"create-release"   alias:"cr" description:"Create release"`
 
+	InspectLocalRelease InspectLocalReleaseOpts `command:"inspect-local-release" description:"Display information from release tarball"`
+	RenderTemplates     RenderTemplatesOpts     `command:"render-templates"      description:"Render job templates from release tarball"`
+
 	// Hidden
 	Sha2ifyRelease  Sha2ifyReleaseOpts  `command:"sha2ify-release"  hidden:"true" description:"Convert release tarball to use SHA256"`
 	FinalizeRelease FinalizeReleaseOpts `command:"finalize-release"               description:"Create final release from dev release tarball"`
@@ -166,7 +195,22 @@ type CreateEnvOpts struct {
 	Args CreateEnvArgs `positional-args:"true" required:"true"`
 	VarFlags
 	OpsFlags
-	StatePath string `long:"state" value-name:"PATH" description:"State file path"`
+	StatePassphraseFlags
+	StatePath     string `long:"state" value-name:"PATH" description:"State file path"`
+	CloudConfig   string `long:"cloud-config" value-name:"PATH" description:"Path to a cloud config with networks, resource pools and disk pools"`
+	RuntimeConfig string `long:"runtime-config" value-name:"PATH" description:"Path to a runtime config with addons for every instance group"`
+	Parallel      int    `long:"parallel" description:"Sets the max number of jobs rendered and packages compiled in parallel" default:"1"`
+	DryRun        bool   `long:"dry-run" description:"Validate the manifest, render templates and print planned CPI calls without deploying"`
+	RecreateCache bool   `long:"recreate-cache" description:"Download releases and stemcells again even if they are cached"`
+
+	Timeout           time.Duration `long:"timeout"             value-name:"DURATION" description:"Stop deploying after the current step once the deploy has taken this long (default: none)"`
+	RollbackOnFailure bool          `long:"rollback-on-failure" description:"Delete the VMs, disks and stemcells created by a failed deploy and restore the previous deployment state"`
+
+	SkipDiskMigration bool `long:"skip-disk-migration" description:"Keep the current persistent disk when its disk pool changes instead of migrating its content to a new disk"`
+	StrictProperties  bool `long:"strict-properties"   description:"Fail when job properties are missing, unknown or of the wrong type according to the release job specs"`
+
+	DownloadFlags
+	AgentFlags
 	cmd
 }
 
@@ -178,7 +222,11 @@ type DeleteEnvOpts struct {
 	Args DeleteEnvArgs `positional-args:"true" required:"true"`
 	VarFlags
 	OpsFlags
+	StatePassphraseFlags
 	StatePath string `long:"state" value-name:"PATH" description:"State file path"`
+	Force     bool   `long:"force" description:"Ignore errors deleting VMs, disks and stemcells and delete the state file anyway"`
+	DownloadFlags
+	AgentFlags
 	cmd
 }
 
@@ -186,6 +234,196 @@ type DeleteEnvArgs struct {
 	Manifest FileBytesWithPathArg `positional-arg-name:"PATH" description:"Path to a manifest file"`
 }
 
+type DiffEnvOpts struct {
+	Args DiffEnvArgs `positional-args:"true" required:"true"`
+	VarFlags
+	OpsFlags
+	StatePassphraseFlags
+	StatePath     string `long:"state" value-name:"PATH" description:"State file path"`
+	CloudConfig   string `long:"cloud-config" value-name:"PATH" description:"Path to a cloud config with networks, resource pools and disk pools"`
+	RuntimeConfig string `long:"runtime-config" value-name:"PATH" description:"Path to a runtime config with addons for every instance group"`
+	cmd
+}
+
+type DiffEnvArgs struct {
+	Manifest FileBytesWithPathArg `positional-arg-name:"PATH" description:"Path to a manifest file"`
+}
+
+type ValidateEnvOpts struct {
+	Args ValidateEnvArgs `positional-args:"true" required:"true"`
+	VarFlags
+	OpsFlags
+	StatePassphraseFlags
+	StatePath     string `long:"state" value-name:"PATH" description:"State file path"`
+	CloudConfig   string `long:"cloud-config" value-name:"PATH" description:"Path to a cloud config with networks, resource pools and disk pools"`
+	RuntimeConfig string `long:"runtime-config" value-name:"PATH" description:"Path to a runtime config with addons for every instance group"`
+	cmd
+}
+
+type ValidateEnvArgs struct {
+	Manifest FileBytesWithPathArg `positional-arg-name:"PATH" description:"Path to a manifest file"`
+}
+
+type SSHEnvOpts struct {
+	Args SSHEnvArgs `positional-args:"true" required:"true"`
+	VarFlags
+	OpsFlags
+	StatePassphraseFlags
+	StatePath string `long:"state" value-name:"PATH" description:"State file path"`
+	Index     int    `long:"index" value-name:"INDEX" description:"Instance index"`
+
+	Command []string         `long:"command" short:"c" description:"Command"`
+	RawOpts TrimmedSpaceArgs `long:"opts"              description:"Options to pass through to SSH"`
+
+	Results bool `long:"results" short:"r" description:"Collect results into a table instead of streaming"`
+
+	GatewayFlags
+
+	cmd
+}
+
+type SSHEnvArgs struct {
+	Manifest FileBytesWithPathArg `positional-arg-name:"PATH" description:"Path to a manifest file"`
+}
+
+type LogsEnvOpts struct {
+	Args LogsEnvArgs `positional-args:"true" required:"true"`
+	VarFlags
+	OpsFlags
+	StatePassphraseFlags
+	StatePath string `long:"state" value-name:"PATH" description:"State file path"`
+	Index     int    `long:"index" value-name:"INDEX" description:"Instance index"`
+
+	Directory DirOrCWDArg `long:"dir" description:"Destination directory" default:"."`
+
+	Follow bool `long:"follow" short:"f" description:"Follow logs"`
+	Num    int  `long:"num"              description:"Last number of lines"`
+	Quiet  bool `long:"quiet"  short:"q" description:"Suppresses printing of headers when multiple files are being examined"`
+
+	Jobs    []string `long:"job"   description:"Limit to only specific jobs"`
+	Filters []string `long:"only"  description:"Filter logs (comma-separated)"`
+	Agent   bool     `long:"agent" description:"Include only agent logs"`
+
+	cmd
+}
+
+type LogsEnvArgs struct {
+	Manifest FileBytesWithPathArg `positional-arg-name:"PATH" description:"Path to a manifest file"`
+}
+
+type InstancesEnvOpts struct {
+	Args InstancesEnvArgs `positional-args:"true" required:"true"`
+	VarFlags
+	OpsFlags
+	StatePassphraseFlags
+	StatePath string `long:"state" value-name:"PATH" description:"State file path"`
+
+	Details   bool `long:"details" short:"i" description:"Show details including VM CID, persistent disk CID, etc."`
+	Vitals    bool `long:"vitals"            description:"Show vitals"`
+	Processes bool `long:"ps"      short:"p" description:"Show processes"`
+
+	cmd
+}
+
+type InstancesEnvArgs struct {
+	Manifest FileBytesWithPathArg `positional-arg-name:"PATH" description:"Path to a manifest file"`
+}
+
+type RunErrandEnvOpts struct {
+	Args RunErrandEnvArgs `positional-args:"true" required:"true"`
+	VarFlags
+	OpsFlags
+	StatePassphraseFlags
+	StatePath string `long:"state" value-name:"PATH" description:"State file path"`
+	Index     int    `long:"index" value-name:"INDEX" description:"Instance index"`
+
+	cmd
+}
+
+type RunErrandEnvArgs struct {
+	Manifest FileBytesWithPathArg `positional-arg-name:"PATH" description:"Path to a manifest file"`
+	Name     string               `positional-arg-name:"NAME" description:"Errand name"`
+}
+
+type DiagnoseEnvOpts struct {
+	Args DiagnoseEnvArgs `positional-args:"true" required:"true"`
+	VarFlags
+	OpsFlags
+	StatePassphraseFlags
+	StatePath string `long:"state" value-name:"PATH" description:"State file path"`
+
+	Directory DirOrCWDArg `long:"dir" description:"Destination directory" default:"."`
+	LogPath   string      `long:"log" value-name:"PATH" description:"Log file of the failed run" env:"BOSH_LOG_PATH"`
+
+	cmd
+}
+
+type DiagnoseEnvArgs struct {
+	Manifest FileBytesWithPathArg `positional-arg-name:"PATH" description:"Path to a manifest file"`
+}
+
+type StateEnvOpts struct {
+	Args StateEnvArgs `positional-args:"true" required:"true"`
+	VarFlags
+	OpsFlags
+	StatePassphraseFlags
+	StatePath string `long:"state" value-name:"PATH" description:"State file path"`
+
+	Path patch.Pointer `long:"path" value-name:"OP-PATH" description:"Extract value out of deployment state (e.g.: /disks/cid=disk-1/size)"`
+
+	cmd
+}
+
+type StateEnvArgs struct {
+	Manifest FileBytesWithPathArg `positional-arg-name:"PATH" description:"Path to a manifest file"`
+}
+
+type DisksEnvOpts struct {
+	Args DisksEnvArgs `positional-args:"true" required:"true"`
+	VarFlags
+	OpsFlags
+	StatePassphraseFlags
+	StatePath string `long:"state" value-name:"PATH" description:"State file path"`
+
+	Orphaned bool `long:"orphaned" description:"List orphaned disks"`
+
+	cmd
+}
+
+type DisksEnvArgs struct {
+	Manifest FileBytesWithPathArg `positional-arg-name:"PATH" description:"Path to a manifest file"`
+}
+
+type AttachDiskEnvOpts struct {
+	Args AttachDiskEnvArgs `positional-args:"true" required:"true"`
+	VarFlags
+	OpsFlags
+	StatePassphraseFlags
+	StatePath string `long:"state" value-name:"PATH" description:"State file path"`
+	Instance  int    `long:"instance" value-name:"INDEX" description:"Index of the instance to attach the disk to (default: 0)"`
+	cmd
+}
+
+type AttachDiskEnvArgs struct {
+	Manifest FileBytesWithPathArg `positional-arg-name:"PATH"     description:"Path to a manifest file"`
+	DiskCID  string               `positional-arg-name:"DISK-CID" description:"CID of an orphaned disk"`
+}
+
+type DeleteDiskEnvOpts struct {
+	Args DeleteDiskEnvArgs `positional-args:"true" required:"true"`
+	VarFlags
+	OpsFlags
+	StatePassphraseFlags
+	StatePath string `long:"state" value-name:"PATH" description:"State file path"`
+	DownloadFlags
+	cmd
+}
+
+type DeleteDiskEnvArgs struct {
+	Manifest FileBytesWithPathArg `positional-arg-name:"PATH"     description:"Path to a manifest file"`
+	DiskCID  string               `positional-arg-name:"DISK-CID" description:"CID of an orphaned disk"`
+}
+
 // Environment
 type EnvironmentOpts struct {
 	cmd
@@ -453,6 +691,15 @@ type RepackStemcellArgs struct {
 	PathToResult   FileArg `positional-arg-name:"PATH-TO-RESULT" description:"Path to repacked stemcell"`
 }
 
+type InspectStemcellOpts struct {
+	Args InspectStemcellArgs `positional-args:"true" required:"true"`
+	cmd
+}
+
+type InspectStemcellArgs struct {
+	PathToStemcell string `positional-arg-name:"PATH-TO-STEMCELL" description:"Path to stemcell"`
+}
+
 // Releases
 type ReleasesOpts struct {
 	cmd
@@ -756,6 +1003,28 @@ type GatewayFlags struct {
 	SOCKS5Proxy string `long:"gw-socks5" description:"SOCKS5 URL" env:"BOSH_ALL_PROXY"`
 }
 
+type DownloadFlags struct {
+	Proxy string `long:"download-proxy" value-name:"URL" description:"Proxy for downloading releases and stemcells instead of HTTP(S)_PROXY"`
+
+	S3AccessKeyID     string `long:"s3-access-key-id"     description:"S3 access key ID for s3:// release and stemcell URLs"     env:"BOSH_S3_ACCESS_KEY_ID"`
+	S3SecretAccessKey string `long:"s3-secret-access-key" description:"S3 secret access key for s3:// release and stemcell URLs" env:"BOSH_S3_SECRET_ACCESS_KEY"`
+	S3Region          string `long:"s3-region"            description:"S3 region of buckets in s3:// release and stemcell URLs" env:"BOSH_S3_REGION"`
+
+	GCSAccessKeyID     string `long:"gcs-access-key-id"     description:"GCS HMAC access key ID for gs:// release and stemcell URLs" env:"BOSH_GCS_ACCESS_KEY_ID"`
+	GCSSecretAccessKey string `long:"gcs-secret-access-key" description:"GCS HMAC secret for gs:// release and stemcell URLs"        env:"BOSH_GCS_SECRET_ACCESS_KEY"`
+}
+
+type StatePassphraseFlags struct {
+	StatePassphrase string `long:"state-passphrase" value-name:"PASSPHRASE" description:"Passphrase to encrypt the state file with" env:"BOSH_STATE_PASSPHRASE"`
+}
+
+type AgentFlags struct {
+	AgentAttempts       int           `long:"agent-attempts"        value-name:"NUMBER"   description:"Attempts of requests to the agent failing with network errors before they are sent (default: 5)"`
+	AgentRetryDelay     time.Duration `long:"agent-retry-delay"     value-name:"DURATION" description:"Delay before retrying a request to the agent, doubled for every retry (default: 1s)"`
+	AgentRequestTimeout time.Duration `long:"agent-request-timeout" value-name:"DURATION" description:"Timeout of a single request to the agent (default: 60s)"`
+	AgentTaskTimeout    time.Duration `long:"agent-task-timeout"    value-name:"DURATION" description:"Timeout of agent tasks such as compile_package (default: none)"`
+}
+
 // Release creation
 type InitReleaseOpts struct {
 	Directory DirOrCWDArg `long:"dir" description:"Release directory path if not current working directory" default:"."`
@@ -826,6 +1095,33 @@ type CreateReleaseArgs struct {
 	Manifest FileBytesWithPathArg `positional-arg-name:"PATH"`
 }
 
+type InspectLocalReleaseOpts struct {
+	Args InspectLocalReleaseArgs `positional-args:"true" required:"true"`
+	cmd
+}
+
+type InspectLocalReleaseArgs struct {
+	Path string `positional-arg-name:"PATH"`
+}
+
+type RenderTemplatesOpts struct {
+	Args RenderTemplatesArgs `positional-args:"true" required:"true"`
+
+	VarFlags
+	OpsFlags
+
+	Release       string      `long:"release"        value-name:"PATH" required:"true" description:"Path to release tarball"`
+	Job           string      `long:"job"            value-name:"NAME" required:"true" description:"Name of the release job to render"`
+	InstanceGroup string      `long:"instance-group" value-name:"NAME"                 description:"Instance group to take properties from if several use the job"`
+	OutputDir     DirOrCWDArg `long:"output-dir"     value-name:"DIR"  required:"true" description:"Destination directory for rendered templates"`
+
+	cmd
+}
+
+type RenderTemplatesArgs struct {
+	Manifest FileBytesWithPathArg `positional-arg-name:"PATH" description:"Path to a manifest file"`
+}
+
 type FinalizeReleaseOpts struct {
 	Args FinalizeReleaseArgs `positional-args:"true" required:"true"`
 
@@ -195,6 +195,46 @@ var _ = Describe("Opts", func() {
 			})
 		})
 
+		Describe("RedactOpt", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("RedactOpt", opts)).To(Equal(
+					`long:"redact" value-name:"BOOL" description:"Mask secrets in logs and error messages" choice:"true" choice:"false" default:"true"`,
+				))
+			})
+		})
+
+		Describe("EventLogOpt", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("EventLogOpt", opts)).To(Equal(
+					`long:"event-log" value-name:"PATH" description:"Write stage, CPI call, agent call and error events of the run as NDJSON to path"`,
+				))
+			})
+		})
+
+		Describe("TmpDirOpt", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("TmpDirOpt", opts)).To(Equal(
+					`long:"tmp-dir" value-name:"DIR" description:"Directory for temporary files, e.g. extracted releases and stemcells"`,
+				))
+			})
+		})
+
+		Describe("CPITraceOpt", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("CPITraceOpt", opts)).To(Equal(
+					`long:"cpi-trace" value-name:"DIR" description:"Write every CPI request and response with its timing as JSON to a file in directory"`,
+				))
+			})
+		})
+
+		Describe("CPIReplayOpt", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("CPIReplayOpt", opts)).To(Equal(
+					`long:"cpi-replay" value-name:"DIR" description:"Answer CPI calls with the calls traced to directory by --cpi-trace instead of running the CPI"`,
+				))
+			})
+		})
+
 		Describe("CreateEnv", func() {
 			It("contains desired values", func() {
 				Expect(getStructTagForName("CreateEnv", opts)).To(Equal(
@@ -211,6 +251,94 @@ var _ = Describe("Opts", func() {
 			})
 		})
 
+		Describe("DiffEnv", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("DiffEnv", opts)).To(Equal(
+					`command:"diff-env" description:"Show manifest changes since BOSH environment was last created or updated"`,
+				))
+			})
+		})
+
+		Describe("ValidateEnv", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("ValidateEnv", opts)).To(Equal(
+					`command:"validate-env" description:"Validate manifest, releases, stemcell and CPI of BOSH environment without changing it"`,
+				))
+			})
+		})
+
+		Describe("InstancesEnv", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("InstancesEnv", opts)).To(Equal(
+					`command:"instances-env" description:"Show BOSH environment VM state reported by its agent"`,
+				))
+			})
+		})
+
+		Describe("RunErrandEnv", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("RunErrandEnv", opts)).To(Equal(
+					`command:"run-errand-env" description:"Run errand on BOSH environment VM"`,
+				))
+			})
+		})
+
+		Describe("StateEnv", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("StateEnv", opts)).To(Equal(
+					`command:"state-env" description:"Show deployment state of BOSH environment"`,
+				))
+			})
+		})
+
+		Describe("DiagnoseEnv", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("DiagnoseEnv", opts)).To(Equal(
+					`command:"diagnose-env" description:"Collect logs and state of BOSH environment for troubleshooting"`,
+				))
+			})
+		})
+
+		Describe("DisksEnv", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("DisksEnv", opts)).To(Equal(
+					`command:"disks-env" description:"List persistent disks of BOSH environment"`,
+				))
+			})
+		})
+
+		Describe("AttachDiskEnv", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("AttachDiskEnv", opts)).To(Equal(
+					`command:"attach-disk-env" description:"Attach orphaned disk to BOSH environment VM on next create-env"`,
+				))
+			})
+		})
+
+		Describe("DeleteDiskEnv", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("DeleteDiskEnv", opts)).To(Equal(
+					`command:"delete-disk-env" description:"Delete orphaned disk of BOSH environment"`,
+				))
+			})
+		})
+
+		Describe("LogsEnv", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("LogsEnv", opts)).To(Equal(
+					`command:"logs-env" description:"Fetch logs from BOSH environment VM"`,
+				))
+			})
+		})
+
+		Describe("SSHEnv", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("SSHEnv", opts)).To(Equal(
+					`command:"ssh-env" description:"SSH into BOSH environment VM"`,
+				))
+			})
+		})
+
 		Describe("Environment", func() {
 			It("contains desired values", func() {
 				Expect(getStructTagForName("Environment", opts)).To(Equal(
@@ -411,6 +539,14 @@ var _ = Describe("Opts", func() {
 			})
 		})
 
+		Describe("InspectStemcell", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("InspectStemcell", opts)).To(Equal(
+					`command:"inspect-stemcell" description:"Display information from stemcell tarball"`,
+				))
+			})
+		})
+
 		Describe("Releases", func() {
 			It("contains desired values", func() {
 				Expect(getStructTagForName("Releases", opts)).To(Equal(
@@ -659,84 +795,582 @@ var _ = Describe("Opts", func() {
 			})
 		})
 
-		Describe("CreateRelease", func() {
+		Describe("CreateRelease", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("CreateRelease", opts)).To(Equal(
+					`command:"create-release" alias:"cr" description:"Create release"`,
+				))
+			})
+		})
+
+		Describe("InspectLocalRelease", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("InspectLocalRelease", opts)).To(Equal(
+					`command:"inspect-local-release" description:"Display information from release tarball"`,
+				))
+			})
+		})
+
+		Describe("RenderTemplates", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("RenderTemplates", opts)).To(Equal(
+					`command:"render-templates" description:"Render job templates from release tarball"`,
+				))
+			})
+		})
+
+		Describe("Sha2ifyRelease", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Sha2ifyRelease", opts)).To(Equal(
+					`command:"sha2ify-release" hidden:"true" description:"Convert release tarball to use SHA256"`,
+				))
+			})
+		})
+
+		Describe("FinalizeRelease", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("FinalizeRelease", opts)).To(Equal(
+					`command:"finalize-release" description:"Create final release from dev release tarball"`,
+				))
+			})
+		})
+
+		Describe("Blobs", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Blobs", opts)).To(Equal(
+					`command:"blobs" description:"List blobs"`,
+				))
+			})
+		})
+
+		Describe("AddBlob", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("AddBlob", opts)).To(Equal(
+					`command:"add-blob" description:"Add blob"`,
+				))
+			})
+		})
+
+		Describe("RemoveBlob", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("RemoveBlob", opts)).To(Equal(
+					`command:"remove-blob" description:"Remove blob"`,
+				))
+			})
+		})
+
+		Describe("SyncBlobs", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("SyncBlobs", opts)).To(Equal(
+					`command:"sync-blobs" description:"Sync blobs"`,
+				))
+			})
+		})
+
+		Describe("UploadBlobs", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("UploadBlobs", opts)).To(Equal(
+					`command:"upload-blobs" description:"Upload blobs"`,
+				))
+			})
+		})
+
+		Describe("AttachDisk", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("AttachDisk", opts)).To(Equal(
+					`command:"attach-disk" description:"Attaches disk to an instance"`,
+				))
+			})
+		})
+	})
+
+	Describe("CreateEnvOpts", func() {
+		var opts *CreateEnvOpts
+
+		BeforeEach(func() {
+			opts = &CreateEnvOpts{}
+		})
+
+		Describe("Args", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Args", opts)).To(Equal(`positional-args:"true" required:"true"`))
+			})
+		})
+
+		It("has --state", func() {
+			Expect(getStructTagForName("StatePath", opts)).To(Equal(
+				`long:"state" value-name:"PATH" description:"State file path"`,
+			))
+		})
+
+		It("has --cloud-config", func() {
+			Expect(getStructTagForName("CloudConfig", opts)).To(Equal(
+				`long:"cloud-config" value-name:"PATH" description:"Path to a cloud config with networks, resource pools and disk pools"`,
+			))
+		})
+
+		It("has --runtime-config", func() {
+			Expect(getStructTagForName("RuntimeConfig", opts)).To(Equal(
+				`long:"runtime-config" value-name:"PATH" description:"Path to a runtime config with addons for every instance group"`,
+			))
+		})
+
+		It("has --parallel", func() {
+			Expect(getStructTagForName("Parallel", opts)).To(Equal(
+				`long:"parallel" description:"Sets the max number of jobs rendered and packages compiled in parallel" default:"1"`,
+			))
+		})
+
+		It("has --dry-run", func() {
+			Expect(getStructTagForName("DryRun", opts)).To(Equal(
+				`long:"dry-run" description:"Validate the manifest, render templates and print planned CPI calls without deploying"`,
+			))
+		})
+
+		It("has --recreate-cache", func() {
+			Expect(getStructTagForName("RecreateCache", opts)).To(Equal(
+				`long:"recreate-cache" description:"Download releases and stemcells again even if they are cached"`,
+			))
+		})
+
+		It("has --timeout", func() {
+			Expect(getStructTagForName("Timeout", opts)).To(Equal(
+				`long:"timeout" value-name:"DURATION" description:"Stop deploying after the current step once the deploy has taken this long (default: none)"`,
+			))
+		})
+
+		It("has --rollback-on-failure", func() {
+			Expect(getStructTagForName("RollbackOnFailure", opts)).To(Equal(
+				`long:"rollback-on-failure" description:"Delete the VMs, disks and stemcells created by a failed deploy and restore the previous deployment state"`,
+			))
+		})
+
+		It("has --skip-disk-migration", func() {
+			Expect(getStructTagForName("SkipDiskMigration", opts)).To(Equal(
+				`long:"skip-disk-migration" description:"Keep the current persistent disk when its disk pool changes instead of migrating its content to a new disk"`,
+			))
+		})
+
+		It("has --strict-properties", func() {
+			Expect(getStructTagForName("StrictProperties", opts)).To(Equal(
+				`long:"strict-properties" description:"Fail when job properties are missing, unknown or of the wrong type according to the release job specs"`,
+			))
+		})
+	})
+
+	Describe("CreateEnvArgs", func() {
+		var args *CreateEnvArgs
+
+		BeforeEach(func() {
+			args = &CreateEnvArgs{}
+		})
+
+		Describe("Manifest", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Manifest", args)).To(Equal(
+					`positional-arg-name:"PATH" description:"Path to a manifest file"`,
+				))
+			})
+		})
+	})
+
+	Describe("DeleteEnvOpts", func() {
+		var opts *DeleteEnvOpts
+
+		BeforeEach(func() {
+			opts = &DeleteEnvOpts{}
+		})
+
+		Describe("Args", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Args", opts)).To(Equal(`positional-args:"true" required:"true"`))
+			})
+		})
+
+		It("has --state", func() {
+			Expect(getStructTagForName("StatePath", opts)).To(Equal(
+				`long:"state" value-name:"PATH" description:"State file path"`,
+			))
+		})
+
+		It("has --force", func() {
+			Expect(getStructTagForName("Force", opts)).To(Equal(
+				`long:"force" description:"Ignore errors deleting VMs, disks and stemcells and delete the state file anyway"`,
+			))
+		})
+	})
+
+	Describe("DeleteEnvArgs", func() {
+		var args *DeleteEnvArgs
+
+		BeforeEach(func() {
+			args = &DeleteEnvArgs{}
+		})
+
+		Describe("Manifest", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Manifest", args)).To(Equal(
+					`positional-arg-name:"PATH" description:"Path to a manifest file"`,
+				))
+			})
+		})
+	})
+
+	Describe("DiffEnvOpts", func() {
+		var opts *DiffEnvOpts
+
+		BeforeEach(func() {
+			opts = &DiffEnvOpts{}
+		})
+
+		Describe("Args", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Args", opts)).To(Equal(`positional-args:"true" required:"true"`))
+			})
+		})
+
+		It("has --state", func() {
+			Expect(getStructTagForName("StatePath", opts)).To(Equal(
+				`long:"state" value-name:"PATH" description:"State file path"`,
+			))
+		})
+
+		It("has --cloud-config", func() {
+			Expect(getStructTagForName("CloudConfig", opts)).To(Equal(
+				`long:"cloud-config" value-name:"PATH" description:"Path to a cloud config with networks, resource pools and disk pools"`,
+			))
+		})
+
+		It("has --runtime-config", func() {
+			Expect(getStructTagForName("RuntimeConfig", opts)).To(Equal(
+				`long:"runtime-config" value-name:"PATH" description:"Path to a runtime config with addons for every instance group"`,
+			))
+		})
+	})
+
+	Describe("DiffEnvArgs", func() {
+		var args *DiffEnvArgs
+
+		BeforeEach(func() {
+			args = &DiffEnvArgs{}
+		})
+
+		Describe("Manifest", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Manifest", args)).To(Equal(
+					`positional-arg-name:"PATH" description:"Path to a manifest file"`,
+				))
+			})
+		})
+	})
+
+	Describe("ValidateEnvOpts", func() {
+		var opts *ValidateEnvOpts
+
+		BeforeEach(func() {
+			opts = &ValidateEnvOpts{}
+		})
+
+		Describe("Args", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Args", opts)).To(Equal(`positional-args:"true" required:"true"`))
+			})
+		})
+
+		It("has --state", func() {
+			Expect(getStructTagForName("StatePath", opts)).To(Equal(
+				`long:"state" value-name:"PATH" description:"State file path"`,
+			))
+		})
+
+		It("has --cloud-config", func() {
+			Expect(getStructTagForName("CloudConfig", opts)).To(Equal(
+				`long:"cloud-config" value-name:"PATH" description:"Path to a cloud config with networks, resource pools and disk pools"`,
+			))
+		})
+
+		It("has --runtime-config", func() {
+			Expect(getStructTagForName("RuntimeConfig", opts)).To(Equal(
+				`long:"runtime-config" value-name:"PATH" description:"Path to a runtime config with addons for every instance group"`,
+			))
+		})
+	})
+
+	Describe("ValidateEnvArgs", func() {
+		var args *ValidateEnvArgs
+
+		BeforeEach(func() {
+			args = &ValidateEnvArgs{}
+		})
+
+		Describe("Manifest", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Manifest", args)).To(Equal(
+					`positional-arg-name:"PATH" description:"Path to a manifest file"`,
+				))
+			})
+		})
+	})
+
+	Describe("SSHEnvOpts", func() {
+		var opts *SSHEnvOpts
+
+		BeforeEach(func() {
+			opts = &SSHEnvOpts{}
+		})
+
+		Describe("Args", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Args", opts)).To(Equal(`positional-args:"true" required:"true"`))
+			})
+		})
+
+		It("has --state", func() {
+			Expect(getStructTagForName("StatePath", opts)).To(Equal(
+				`long:"state" value-name:"PATH" description:"State file path"`,
+			))
+		})
+
+		It("has --index", func() {
+			Expect(getStructTagForName("Index", opts)).To(Equal(
+				`long:"index" value-name:"INDEX" description:"Instance index"`,
+			))
+		})
+
+		Describe("Command", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Command", opts)).To(Equal(
+					`long:"command" short:"c" description:"Command"`,
+				))
+			})
+		})
+
+		Describe("RawOpts", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("RawOpts", opts)).To(Equal(
+					`long:"opts" description:"Options to pass through to SSH"`,
+				))
+			})
+		})
+
+		Describe("Results", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Results", opts)).To(Equal(
+					`long:"results" short:"r" description:"Collect results into a table instead of streaming"`,
+				))
+			})
+		})
+	})
+
+	Describe("SSHEnvArgs", func() {
+		var args *SSHEnvArgs
+
+		BeforeEach(func() {
+			args = &SSHEnvArgs{}
+		})
+
+		Describe("Manifest", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Manifest", args)).To(Equal(
+					`positional-arg-name:"PATH" description:"Path to a manifest file"`,
+				))
+			})
+		})
+	})
+
+	Describe("LogsEnvOpts", func() {
+		var opts *LogsEnvOpts
+
+		BeforeEach(func() {
+			opts = &LogsEnvOpts{}
+		})
+
+		Describe("Args", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Args", opts)).To(Equal(`positional-args:"true" required:"true"`))
+			})
+		})
+
+		It("has --state", func() {
+			Expect(getStructTagForName("StatePath", opts)).To(Equal(
+				`long:"state" value-name:"PATH" description:"State file path"`,
+			))
+		})
+
+		It("has --index", func() {
+			Expect(getStructTagForName("Index", opts)).To(Equal(
+				`long:"index" value-name:"INDEX" description:"Instance index"`,
+			))
+		})
+
+		Describe("Directory", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Directory", opts)).To(Equal(
+					`long:"dir" description:"Destination directory" default:"."`,
+				))
+			})
+		})
+
+		Describe("Follow", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Follow", opts)).To(Equal(
+					`long:"follow" short:"f" description:"Follow logs"`,
+				))
+			})
+		})
+
+		Describe("Jobs", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Jobs", opts)).To(Equal(
+					`long:"job" description:"Limit to only specific jobs"`,
+				))
+			})
+		})
+
+		Describe("Filters", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Filters", opts)).To(Equal(
+					`long:"only" description:"Filter logs (comma-separated)"`,
+				))
+			})
+		})
+
+		Describe("Agent", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Agent", opts)).To(Equal(
+					`long:"agent" description:"Include only agent logs"`,
+				))
+			})
+		})
+	})
+
+	Describe("DiagnoseEnvOpts", func() {
+		var opts *DiagnoseEnvOpts
+
+		BeforeEach(func() {
+			opts = &DiagnoseEnvOpts{}
+		})
+
+		Describe("Args", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Args", opts)).To(Equal(`positional-args:"true" required:"true"`))
+			})
+		})
+
+		It("has --state", func() {
+			Expect(getStructTagForName("StatePath", opts)).To(Equal(
+				`long:"state" value-name:"PATH" description:"State file path"`,
+			))
+		})
+
+		Describe("Directory", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Directory", opts)).To(Equal(
+					`long:"dir" description:"Destination directory" default:"."`,
+				))
+			})
+		})
+
+		Describe("LogPath", func() {
 			It("contains desired values", func() {
-				Expect(getStructTagForName("CreateRelease", opts)).To(Equal(
-					`command:"create-release" alias:"cr" description:"Create release"`,
+				Expect(getStructTagForName("LogPath", opts)).To(Equal(
+					`long:"log" value-name:"PATH" description:"Log file of the failed run" env:"BOSH_LOG_PATH"`,
 				))
 			})
 		})
+	})
 
-		Describe("Sha2ifyRelease", func() {
-			It("contains desired values", func() {
-				Expect(getStructTagForName("Sha2ifyRelease", opts)).To(Equal(
-					`command:"sha2ify-release" hidden:"true" description:"Convert release tarball to use SHA256"`,
-				))
-			})
+	Describe("InstancesEnvOpts", func() {
+		var opts *InstancesEnvOpts
+
+		BeforeEach(func() {
+			opts = &InstancesEnvOpts{}
 		})
 
-		Describe("FinalizeRelease", func() {
+		Describe("Args", func() {
 			It("contains desired values", func() {
-				Expect(getStructTagForName("FinalizeRelease", opts)).To(Equal(
-					`command:"finalize-release" description:"Create final release from dev release tarball"`,
-				))
+				Expect(getStructTagForName("Args", opts)).To(Equal(`positional-args:"true" required:"true"`))
 			})
 		})
 
-		Describe("Blobs", func() {
-			It("contains desired values", func() {
-				Expect(getStructTagForName("Blobs", opts)).To(Equal(
-					`command:"blobs" description:"List blobs"`,
-				))
-			})
+		It("has --state", func() {
+			Expect(getStructTagForName("StatePath", opts)).To(Equal(
+				`long:"state" value-name:"PATH" description:"State file path"`,
+			))
 		})
 
-		Describe("AddBlob", func() {
+		Describe("Details", func() {
 			It("contains desired values", func() {
-				Expect(getStructTagForName("AddBlob", opts)).To(Equal(
-					`command:"add-blob" description:"Add blob"`,
+				Expect(getStructTagForName("Details", opts)).To(Equal(
+					`long:"details" short:"i" description:"Show details including VM CID, persistent disk CID, etc."`,
 				))
 			})
 		})
 
-		Describe("RemoveBlob", func() {
+		Describe("Vitals", func() {
 			It("contains desired values", func() {
-				Expect(getStructTagForName("RemoveBlob", opts)).To(Equal(
-					`command:"remove-blob" description:"Remove blob"`,
+				Expect(getStructTagForName("Vitals", opts)).To(Equal(
+					`long:"vitals" description:"Show vitals"`,
 				))
 			})
 		})
 
-		Describe("SyncBlobs", func() {
+		Describe("Processes", func() {
 			It("contains desired values", func() {
-				Expect(getStructTagForName("SyncBlobs", opts)).To(Equal(
-					`command:"sync-blobs" description:"Sync blobs"`,
+				Expect(getStructTagForName("Processes", opts)).To(Equal(
+					`long:"ps" short:"p" description:"Show processes"`,
 				))
 			})
 		})
+	})
+
+	Describe("RunErrandEnvOpts", func() {
+		var opts *RunErrandEnvOpts
+
+		BeforeEach(func() {
+			opts = &RunErrandEnvOpts{}
+		})
 
-		Describe("UploadBlobs", func() {
+		Describe("Args", func() {
 			It("contains desired values", func() {
-				Expect(getStructTagForName("UploadBlobs", opts)).To(Equal(
-					`command:"upload-blobs" description:"Upload blobs"`,
-				))
+				Expect(getStructTagForName("Args", opts)).To(Equal(`positional-args:"true" required:"true"`))
 			})
 		})
 
-		Describe("AttachDisk", func() {
+		It("has --state", func() {
+			Expect(getStructTagForName("StatePath", opts)).To(Equal(
+				`long:"state" value-name:"PATH" description:"State file path"`,
+			))
+		})
+
+		It("has --index", func() {
+			Expect(getStructTagForName("Index", opts)).To(Equal(
+				`long:"index" value-name:"INDEX" description:"Instance index"`,
+			))
+		})
+	})
+
+	Describe("RunErrandEnvArgs", func() {
+		var opts *RunErrandEnvArgs
+
+		BeforeEach(func() {
+			opts = &RunErrandEnvArgs{}
+		})
+
+		Describe("Name", func() {
 			It("contains desired values", func() {
-				Expect(getStructTagForName("AttachDisk", opts)).To(Equal(
-					`command:"attach-disk" description:"Attaches disk to an instance"`,
+				Expect(getStructTagForName("Name", opts)).To(Equal(
+					`positional-arg-name:"NAME" description:"Errand name"`,
 				))
 			})
 		})
 	})
 
-	Describe("CreateEnvOpts", func() {
-		var opts *CreateEnvOpts
+	Describe("StateEnvOpts", func() {
+		var opts *StateEnvOpts
 
 		BeforeEach(func() {
-			opts = &CreateEnvOpts{}
+			opts = &StateEnvOpts{}
 		})
 
 		Describe("Args", func() {
@@ -750,29 +1384,21 @@ var _ = Describe("Opts", func() {
 				`long:"state" value-name:"PATH" description:"State file path"`,
 			))
 		})
-	})
-
-	Describe("CreateEnvArgs", func() {
-		var args *CreateEnvArgs
-
-		BeforeEach(func() {
-			args = &CreateEnvArgs{}
-		})
 
-		Describe("Manifest", func() {
+		Describe("Path", func() {
 			It("contains desired values", func() {
-				Expect(getStructTagForName("Manifest", args)).To(Equal(
-					`positional-arg-name:"PATH" description:"Path to a manifest file"`,
+				Expect(getStructTagForName("Path", opts)).To(Equal(
+					`long:"path" value-name:"OP-PATH" description:"Extract value out of deployment state (e.g.: /disks/cid=disk-1/size)"`,
 				))
 			})
 		})
 	})
 
-	Describe("DeleteEnvOpts", func() {
-		var opts *DeleteEnvOpts
+	Describe("DisksEnvOpts", func() {
+		var opts *DisksEnvOpts
 
 		BeforeEach(func() {
-			opts = &DeleteEnvOpts{}
+			opts = &DisksEnvOpts{}
 		})
 
 		Describe("Args", func() {
@@ -786,19 +1412,43 @@ var _ = Describe("Opts", func() {
 				`long:"state" value-name:"PATH" description:"State file path"`,
 			))
 		})
+
+		Describe("Orphaned", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Orphaned", opts)).To(Equal(
+					`long:"orphaned" description:"List orphaned disks"`,
+				))
+			})
+		})
 	})
 
-	Describe("DeleteEnvArgs", func() {
-		var args *DeleteEnvArgs
+	Describe("AttachDiskEnvArgs", func() {
+		var args *AttachDiskEnvArgs
 
 		BeforeEach(func() {
-			args = &DeleteEnvArgs{}
+			args = &AttachDiskEnvArgs{}
 		})
 
-		Describe("Manifest", func() {
+		Describe("DiskCID", func() {
 			It("contains desired values", func() {
-				Expect(getStructTagForName("Manifest", args)).To(Equal(
-					`positional-arg-name:"PATH" description:"Path to a manifest file"`,
+				Expect(getStructTagForName("DiskCID", args)).To(Equal(
+					`positional-arg-name:"DISK-CID" description:"CID of an orphaned disk"`,
+				))
+			})
+		})
+	})
+
+	Describe("DeleteDiskEnvArgs", func() {
+		var args *DeleteDiskEnvArgs
+
+		BeforeEach(func() {
+			args = &DeleteDiskEnvArgs{}
+		})
+
+		Describe("DiskCID", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("DiskCID", args)).To(Equal(
+					`positional-arg-name:"DISK-CID" description:"CID of an orphaned disk"`,
 				))
 			})
 		})
@@ -1335,6 +1985,36 @@ var _ = Describe("Opts", func() {
 		})
 	})
 
+	Describe("InspectStemcellOpts", func() {
+		var opts *InspectStemcellOpts
+
+		BeforeEach(func() {
+			opts = &InspectStemcellOpts{}
+		})
+
+		Describe("Args", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Args", opts)).To(Equal(`positional-args:"true" required:"true"`))
+			})
+		})
+	})
+
+	Describe("InspectStemcellArgs", func() {
+		var opts *InspectStemcellArgs
+
+		BeforeEach(func() {
+			opts = &InspectStemcellArgs{}
+		})
+
+		Describe("PathToStemcell", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("PathToStemcell", opts)).To(Equal(
+					`positional-arg-name:"PATH-TO-STEMCELL" description:"Path to stemcell"`,
+				))
+			})
+		})
+	})
+
 	Describe("RepackStemcellArgs", func() {
 		var opts *RepackStemcellArgs
 
@@ -2351,6 +3031,96 @@ var _ = Describe("Opts", func() {
 		})
 	})
 
+	Describe("DownloadFlags", func() {
+		var opts *DownloadFlags
+
+		BeforeEach(func() {
+			opts = &DownloadFlags{}
+		})
+
+		It("Proxy contains desired values", func() {
+			Expect(getStructTagForName("Proxy", opts)).To(Equal(
+				`long:"download-proxy" value-name:"URL" description:"Proxy for downloading releases and stemcells instead of HTTP(S)_PROXY"`,
+			))
+		})
+
+		It("S3AccessKeyID contains desired values", func() {
+			Expect(getStructTagForName("S3AccessKeyID", opts)).To(Equal(
+				`long:"s3-access-key-id" description:"S3 access key ID for s3:// release and stemcell URLs" env:"BOSH_S3_ACCESS_KEY_ID"`,
+			))
+		})
+
+		It("S3SecretAccessKey contains desired values", func() {
+			Expect(getStructTagForName("S3SecretAccessKey", opts)).To(Equal(
+				`long:"s3-secret-access-key" description:"S3 secret access key for s3:// release and stemcell URLs" env:"BOSH_S3_SECRET_ACCESS_KEY"`,
+			))
+		})
+
+		It("S3Region contains desired values", func() {
+			Expect(getStructTagForName("S3Region", opts)).To(Equal(
+				`long:"s3-region" description:"S3 region of buckets in s3:// release and stemcell URLs" env:"BOSH_S3_REGION"`,
+			))
+		})
+
+		It("GCSAccessKeyID contains desired values", func() {
+			Expect(getStructTagForName("GCSAccessKeyID", opts)).To(Equal(
+				`long:"gcs-access-key-id" description:"GCS HMAC access key ID for gs:// release and stemcell URLs" env:"BOSH_GCS_ACCESS_KEY_ID"`,
+			))
+		})
+
+		It("GCSSecretAccessKey contains desired values", func() {
+			Expect(getStructTagForName("GCSSecretAccessKey", opts)).To(Equal(
+				`long:"gcs-secret-access-key" description:"GCS HMAC secret for gs:// release and stemcell URLs" env:"BOSH_GCS_SECRET_ACCESS_KEY"`,
+			))
+		})
+	})
+
+	Describe("StatePassphraseFlags", func() {
+		var opts *StatePassphraseFlags
+
+		BeforeEach(func() {
+			opts = &StatePassphraseFlags{}
+		})
+
+		It("StatePassphrase contains desired values", func() {
+			Expect(getStructTagForName("StatePassphrase", opts)).To(Equal(
+				`long:"state-passphrase" value-name:"PASSPHRASE" description:"Passphrase to encrypt the state file with" env:"BOSH_STATE_PASSPHRASE"`,
+			))
+		})
+	})
+
+	Describe("AgentFlags", func() {
+		var opts *AgentFlags
+
+		BeforeEach(func() {
+			opts = &AgentFlags{}
+		})
+
+		It("AgentAttempts contains desired values", func() {
+			Expect(getStructTagForName("AgentAttempts", opts)).To(Equal(
+				`long:"agent-attempts" value-name:"NUMBER" description:"Attempts of requests to the agent failing with network errors before they are sent (default: 5)"`,
+			))
+		})
+
+		It("AgentRetryDelay contains desired values", func() {
+			Expect(getStructTagForName("AgentRetryDelay", opts)).To(Equal(
+				`long:"agent-retry-delay" value-name:"DURATION" description:"Delay before retrying a request to the agent, doubled for every retry (default: 1s)"`,
+			))
+		})
+
+		It("AgentRequestTimeout contains desired values", func() {
+			Expect(getStructTagForName("AgentRequestTimeout", opts)).To(Equal(
+				`long:"agent-request-timeout" value-name:"DURATION" description:"Timeout of a single request to the agent (default: 60s)"`,
+			))
+		})
+
+		It("AgentTaskTimeout contains desired values", func() {
+			Expect(getStructTagForName("AgentTaskTimeout", opts)).To(Equal(
+				`long:"agent-task-timeout" value-name:"DURATION" description:"Timeout of agent tasks such as compile_package (default: none)"`,
+			))
+		})
+	})
+
 	Describe("InitReleaseOpts", func() {
 		var opts *InitReleaseOpts
 
@@ -2543,6 +3313,96 @@ var _ = Describe("Opts", func() {
 		})
 	})
 
+	Describe("InspectLocalReleaseOpts", func() {
+		var opts *InspectLocalReleaseOpts
+
+		BeforeEach(func() {
+			opts = &InspectLocalReleaseOpts{}
+		})
+
+		Describe("Args", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Args", opts)).To(Equal(`positional-args:"true" required:"true"`))
+			})
+		})
+	})
+
+	Describe("RenderTemplatesOpts", func() {
+		var opts *RenderTemplatesOpts
+
+		BeforeEach(func() {
+			opts = &RenderTemplatesOpts{}
+		})
+
+		Describe("Args", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Args", opts)).To(Equal(`positional-args:"true" required:"true"`))
+			})
+		})
+
+		Describe("Release", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Release", opts)).To(Equal(
+					`long:"release" value-name:"PATH" required:"true" description:"Path to release tarball"`,
+				))
+			})
+		})
+
+		Describe("Job", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Job", opts)).To(Equal(
+					`long:"job" value-name:"NAME" required:"true" description:"Name of the release job to render"`,
+				))
+			})
+		})
+
+		Describe("InstanceGroup", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("InstanceGroup", opts)).To(Equal(
+					`long:"instance-group" value-name:"NAME" description:"Instance group to take properties from if several use the job"`,
+				))
+			})
+		})
+
+		Describe("OutputDir", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("OutputDir", opts)).To(Equal(
+					`long:"output-dir" value-name:"DIR" required:"true" description:"Destination directory for rendered templates"`,
+				))
+			})
+		})
+	})
+
+	Describe("RenderTemplatesArgs", func() {
+		var opts *RenderTemplatesArgs
+
+		BeforeEach(func() {
+			opts = &RenderTemplatesArgs{}
+		})
+
+		Describe("Manifest", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Manifest", opts)).To(Equal(
+					`positional-arg-name:"PATH" description:"Path to a manifest file"`,
+				))
+			})
+		})
+	})
+
+	Describe("InspectLocalReleaseArgs", func() {
+		var opts *InspectLocalReleaseArgs
+
+		BeforeEach(func() {
+			opts = &InspectLocalReleaseArgs{}
+		})
+
+		Describe("Path", func() {
+			It("contains desired values", func() {
+				Expect(getStructTagForName("Path", opts)).To(Equal(`positional-arg-name:"PATH"`))
+			})
+		})
+	})
+
 	Describe("Sha2ifyReleaseOpts", func() {
 		var opts *Sha2ifyReleaseOpts
 
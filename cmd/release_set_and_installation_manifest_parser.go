@@ -17,12 +17,12 @@ type ReleaseSetAndInstallationManifestParser struct {
 func (y ReleaseSetAndInstallationManifestParser) ReleaseSetAndInstallationManifest(deploymentManifestPath string, vars boshtpl.Variables, op patch.Op) (birelsetmanifest.Manifest, biinstallmanifest.Manifest, error) {
 	releaseSetManifest, err := y.ReleaseSetParser.Parse(deploymentManifestPath, vars, op)
 	if err != nil {
-		return birelsetmanifest.Manifest{}, biinstallmanifest.Manifest{}, bosherr.WrapErrorf(err, "Parsing release set manifest '%s'", deploymentManifestPath)
+		return birelsetmanifest.Manifest{}, biinstallmanifest.Manifest{}, ValidationError{Err: bosherr.WrapErrorf(err, "Parsing release set manifest '%s'", deploymentManifestPath)}
 	}
 
 	installationManifest, err := y.InstallationParser.Parse(deploymentManifestPath, vars, op, releaseSetManifest)
 	if err != nil {
-		return birelsetmanifest.Manifest{}, biinstallmanifest.Manifest{}, bosherr.WrapErrorf(err, "Parsing installation manifest '%s'", deploymentManifestPath)
+		return birelsetmanifest.Manifest{}, biinstallmanifest.Manifest{}, ValidationError{Err: bosherr.WrapErrorf(err, "Parsing installation manifest '%s'", deploymentManifestPath)}
 	}
 
 	return releaseSetManifest, installationManifest, nil
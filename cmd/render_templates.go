@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+
+	bideplmanifest "github.com/cloudfoundry/bosh-cli/deployment/manifest"
+	bidepltpl "github.com/cloudfoundry/bosh-cli/deployment/template"
+	boshrel "github.com/cloudfoundry/bosh-cli/release"
+	bireljob "github.com/cloudfoundry/bosh-cli/release/job"
+	bitemplate "github.com/cloudfoundry/bosh-cli/templatescompiler"
+	boshui "github.com/cloudfoundry/bosh-cli/ui"
+)
+
+type RenderTemplatesCmd struct {
+	releaseReader    boshrel.Reader
+	deploymentParser bideplmanifest.Parser
+	jobRenderer      bitemplate.JobRenderer
+	fs               boshsys.FileSystem
+	ui               boshui.UI
+}
+
+func NewRenderTemplatesCmd(
+	releaseReader boshrel.Reader,
+	deploymentParser bideplmanifest.Parser,
+	jobRenderer bitemplate.JobRenderer,
+	fs boshsys.FileSystem,
+	ui boshui.UI,
+) RenderTemplatesCmd {
+	return RenderTemplatesCmd{
+		releaseReader:    releaseReader,
+		deploymentParser: deploymentParser,
+		jobRenderer:      jobRenderer,
+		fs:               fs,
+		ui:               ui,
+	}
+}
+
+// Run renders the templates of a release job with the properties of the
+// instance group using it, without deploying anything, so that release
+// authors can check their templates.
+func (c RenderTemplatesCmd) Run(opts RenderTemplatesOpts) error {
+	manifestPath := opts.Args.Manifest.Path
+
+	interpolatedTemplate, err := bidepltpl.NewDeploymentTemplate(opts.Args.Manifest.Bytes).Evaluate(
+		opts.VarFlags.AsVariables(), opts.OpsFlags.AsOp())
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Evaluating manifest '%s'", manifestPath)
+	}
+
+	deploymentManifest, err := c.deploymentParser.Parse(interpolatedTemplate, manifestPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Parsing deployment manifest '%s'", manifestPath)
+	}
+
+	release, err := c.releaseReader.Read(opts.Release)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading release '%s'", opts.Release)
+	}
+
+	defer release.CleanUp()
+
+	releaseJob, found := release.FindJobByName(opts.Job)
+	if !found {
+		return bosherr.Errorf("Job '%s' not found in release '%s'", opts.Job, release.Name())
+	}
+
+	instanceGroup, jobRef, err := c.findInstanceGroup(deploymentManifest, release.Name(), opts)
+	if err != nil {
+		return err
+	}
+
+	releaseJobLinks, err := deploymentManifest.ResolveLinks(instanceGroup.Name, releaseJobResolver{release: release})
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Resolving links for instance group '%s'", instanceGroup.Name)
+	}
+
+	networkInterfaces, err := deploymentManifest.NetworkInterfaces(instanceGroup.Name)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Finding networks for instance group '%s'", instanceGroup.Name)
+	}
+
+	instance := bitemplate.InstanceSpec{
+		Name:     instanceGroup.Name,
+		AZ:       instanceGroup.InstanceAZ(0),
+		Address:  instanceAddress(instanceGroup),
+		Networks: bitemplate.NewNetworkSpecs(networkInterfaces),
+
+		DNSDomainName:   deploymentManifest.Features.DNSDomainName,
+		UseDNSAddresses: deploymentManifest.Features.UseDNSAddresses,
+	}
+
+	renderedJob, err := c.jobRenderer.Render(
+		releaseJob,
+		jobRef.Properties,
+		releaseJobLinks[jobRef.Name],
+		instanceGroup.Properties,
+		deploymentManifest.Properties,
+		deploymentManifest.Name,
+		instance,
+		"",
+	)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Rendering templates for job '%s'", opts.Job)
+	}
+
+	defer renderedJob.DeleteSilently()
+
+	err = c.fs.CopyDir(renderedJob.Path(), opts.OutputDir.Path)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Copying rendered templates to '%s'", opts.OutputDir.Path)
+	}
+
+	c.ui.PrintLinef("Rendered templates of job '%s' for instance group '%s' to '%s'",
+		opts.Job, instanceGroup.Name, opts.OutputDir.Path)
+
+	return nil
+}
+
+// findInstanceGroup returns the first instance group using the release job,
+// or the instance group given with --instance-group.
+func (c RenderTemplatesCmd) findInstanceGroup(deploymentManifest bideplmanifest.Manifest, releaseName string, opts RenderTemplatesOpts) (bideplmanifest.Job, bideplmanifest.ReleaseJobRef, error) {
+	for _, instanceGroup := range deploymentManifest.Jobs {
+		if len(opts.InstanceGroup) > 0 && instanceGroup.Name != opts.InstanceGroup {
+			continue
+		}
+
+		for _, jobRef := range instanceGroup.Templates {
+			if jobRef.Name == opts.Job && jobRef.Release == releaseName {
+				return instanceGroup, jobRef, nil
+			}
+		}
+	}
+
+	if len(opts.InstanceGroup) > 0 {
+		return bideplmanifest.Job{}, bideplmanifest.ReleaseJobRef{}, bosherr.Errorf(
+			"Instance group '%s' does not use job '%s' from release '%s'", opts.InstanceGroup, opts.Job, releaseName)
+	}
+
+	return bideplmanifest.Job{}, bideplmanifest.ReleaseJobRef{}, bosherr.Errorf(
+		"No instance group uses job '%s' from release '%s'", opts.Job, releaseName)
+}
+
+// instanceAddress returns the IP of the first instance on the default
+// gateway network of the instance group, or on its only network. Templates
+// see it as spec.address; it is empty when the IP is only known once the
+// instance is created.
+func instanceAddress(instanceGroup bideplmanifest.Job) string {
+	for _, network := range instanceGroup.Networks {
+		isDefault := len(instanceGroup.Networks) == 1
+
+		for _, networkDefault := range network.Defaults {
+			if networkDefault == bideplmanifest.NetworkDefaultGateway {
+				isDefault = true
+			}
+		}
+
+		if isDefault && len(network.InstanceIPs()) > 0 {
+			return network.InstanceIPs()[0]
+		}
+	}
+
+	return ""
+}
+
+// releaseJobResolver resolves the jobs of the rendered release, so links
+// can only be provided by jobs of that release.
+type releaseJobResolver struct {
+	release boshrel.Release
+}
+
+func (r releaseJobResolver) Resolve(jobName, releaseName string) (bireljob.Job, error) {
+	if releaseName != r.release.Name() {
+		return bireljob.Job{}, bosherr.Errorf("Finding release '%s', only release '%s' is available", releaseName, r.release.Name())
+	}
+
+	releaseJob, found := r.release.FindJobByName(jobName)
+	if !found {
+		return bireljob.Job{}, bosherr.Errorf("Finding job '%s' in release '%s'", jobName, releaseName)
+	}
+
+	return releaseJob, nil
+}
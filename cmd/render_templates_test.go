@@ -0,0 +1,192 @@
+package cmd_test
+
+import (
+	"errors"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+
+	. "github.com/cloudfoundry/bosh-cli/cmd"
+	bideplmanifest "github.com/cloudfoundry/bosh-cli/deployment/manifest"
+	fakebideplmanifest "github.com/cloudfoundry/bosh-cli/deployment/manifest/manifestfakes"
+	boshjob "github.com/cloudfoundry/bosh-cli/release/job"
+	fakerel "github.com/cloudfoundry/bosh-cli/release/releasefakes"
+	. "github.com/cloudfoundry/bosh-cli/release/resource"
+	bitemplate "github.com/cloudfoundry/bosh-cli/templatescompiler"
+	mock_template "github.com/cloudfoundry/bosh-cli/templatescompiler/mocks"
+	fakeui "github.com/cloudfoundry/bosh-cli/ui/fakes"
+)
+
+var _ = Describe("RenderTemplatesCmd", func() {
+	var (
+		mockCtrl         *gomock.Controller
+		releaseReader    *fakerel.FakeReader
+		deploymentParser *fakebideplmanifest.FakeParser
+		jobRenderer      *mock_template.MockJobRenderer
+		fs               *fakesys.FakeFileSystem
+		ui               *fakeui.FakeUI
+		command          RenderTemplatesCmd
+
+		release            *fakerel.FakeRelease
+		releaseJob         boshjob.Job
+		deploymentManifest bideplmanifest.Manifest
+		opts               RenderTemplatesOpts
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+
+		releaseReader = &fakerel.FakeReader{}
+		deploymentParser = &fakebideplmanifest.FakeParser{}
+		jobRenderer = mock_template.NewMockJobRenderer(mockCtrl)
+		fs = fakesys.NewFakeFileSystem()
+		ui = &fakeui.FakeUI{}
+
+		command = NewRenderTemplatesCmd(releaseReader, deploymentParser, jobRenderer, fs, ui)
+
+		releaseJob = *boshjob.NewJob(NewResource("fake-job", "fake-job-fp", nil))
+
+		release = &fakerel.FakeRelease{}
+		release.NameReturns("fake-release")
+		release.FindJobByNameReturns(releaseJob, true)
+		releaseReader.ReadReturns(release, nil)
+
+		deploymentManifest = bideplmanifest.Manifest{
+			Name:       "fake-deployment",
+			Properties: biproperty.Map{"global": "fake-global-value"},
+			Jobs: []bideplmanifest.Job{
+				{
+					Name: "fake-other-instance-group",
+					Templates: []bideplmanifest.ReleaseJobRef{
+						{Name: "fake-other-job", Release: "fake-release"},
+					},
+				},
+				{
+					Name: "fake-instance-group",
+					AZs:  []string{"z1"},
+					Templates: []bideplmanifest.ReleaseJobRef{
+						{Name: "fake-job", Release: "fake-release"},
+					},
+					Networks: []bideplmanifest.JobNetwork{
+						{Name: "fake-network", StaticIPs: []string{"10.0.0.5"}},
+					},
+					Properties: biproperty.Map{"port": 8080},
+				},
+			},
+		}
+		deploymentParser.ParseReturns(deploymentManifest, nil)
+
+		opts = RenderTemplatesOpts{
+			Args: RenderTemplatesArgs{
+				Manifest: FileBytesWithPathArg{Path: "/path/to/manifest.yml", Bytes: []byte("name: fake-deployment")},
+			},
+			Release:   "/path/to/release.tgz",
+			Job:       "fake-job",
+			OutputDir: DirOrCWDArg{Path: "/path/to/out"},
+		}
+
+		err := fs.WriteFileString("/rendered-job/bin/ctl", "fake-rendered-ctl")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	act := func() error { return command.Run(opts) }
+
+	expectRender := func(jobProperties biproperty.Map, address string) *gomock.Call {
+		renderedJob := bitemplate.NewRenderedJob(releaseJob, "/rendered-job", fs, boshlog.NewLogger(boshlog.LevelNone))
+
+		instance := bitemplate.InstanceSpec{
+			Name:    "fake-instance-group",
+			AZ:      "z1",
+			Address: address,
+			Networks: map[string]bitemplate.NetworkSpec{
+				"fake-network": {IP: address, Default: []string{"dns", "gateway"}},
+			},
+		}
+
+		return jobRenderer.EXPECT().Render(
+			releaseJob, nil, biproperty.Map{}, jobProperties, biproperty.Map{"global": "fake-global-value"}, "fake-deployment", instance, "",
+		).Return(renderedJob, nil)
+	}
+
+	It("renders the job templates with the instance group properties into the output directory", func() {
+		expectRender(biproperty.Map{"port": 8080}, "10.0.0.5")
+
+		Expect(act()).ToNot(HaveOccurred())
+
+		Expect(releaseReader.ReadArgsForCall(0)).To(Equal("/path/to/release.tgz"))
+		Expect(release.FindJobByNameArgsForCall(0)).To(Equal("fake-job"))
+
+		_, manifestPath := deploymentParser.ParseArgsForCall(0)
+		Expect(manifestPath).To(Equal("/path/to/manifest.yml"))
+
+		contents, err := fs.ReadFileString("/path/to/out/bin/ctl")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(contents).To(Equal("fake-rendered-ctl"))
+
+		Expect(ui.Said).To(ContainElement(
+			"Rendered templates of job 'fake-job' for instance group 'fake-instance-group' to '/path/to/out'"))
+	})
+
+	It("deletes the rendered job and cleans up the release", func() {
+		expectRender(biproperty.Map{"port": 8080}, "10.0.0.5")
+
+		Expect(act()).ToNot(HaveOccurred())
+
+		Expect(fs.FileExists("/rendered-job")).To(BeFalse())
+		Expect(release.CleanUpCallCount()).To(Equal(1))
+	})
+
+	It("uses the instance group given with --instance-group", func() {
+		deploymentManifest.Jobs[0].Templates = append(deploymentManifest.Jobs[0].Templates,
+			bideplmanifest.ReleaseJobRef{Name: "fake-job", Release: "fake-release"})
+		deploymentParser.ParseReturns(deploymentManifest, nil)
+
+		opts.InstanceGroup = "fake-instance-group"
+
+		expectRender(biproperty.Map{"port": 8080}, "10.0.0.5")
+
+		Expect(act()).ToNot(HaveOccurred())
+	})
+
+	It("returns an error when the release does not have the job", func() {
+		release.FindJobByNameReturns(boshjob.Job{}, false)
+
+		err := act()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Job 'fake-job' not found in release 'fake-release'"))
+	})
+
+	It("returns an error when no instance group uses the job", func() {
+		opts.InstanceGroup = "fake-other-instance-group"
+
+		err := act()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Instance group 'fake-other-instance-group' does not use job 'fake-job' from release 'fake-release'"))
+	})
+
+	It("returns an error when reading the release fails", func() {
+		releaseReader.ReadReturns(nil, errors.New("fake-err"))
+
+		err := act()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-err"))
+	})
+
+	It("returns an error when rendering fails", func() {
+		expectRender(biproperty.Map{"port": 8080}, "10.0.0.5").Return(nil, errors.New("fake-err"))
+
+		err := act()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Rendering templates for job 'fake-job'"))
+		Expect(err.Error()).To(ContainSubstring("fake-err"))
+	})
+})
@@ -29,7 +29,7 @@ func (c RunErrandCmd) Run(opts RunErrandOpts) error {
 		return err
 	}
 
-	errandErr := c.summarize(opts.Args.Name, results)
+	errandErr := summarizeErrandResults(c.ui, opts.Args.Name, results)
 	for _, result := range results {
 
 		if opts.DownloadLogs && len(result.LogsBlobstoreID) > 0 {
@@ -49,7 +49,9 @@ func (c RunErrandCmd) Run(opts RunErrandOpts) error {
 	return errandErr
 }
 
-func (c RunErrandCmd) summarize(errandName string, results []boshdir.ErrandResult) error {
+// summarizeErrandResults prints the output of every errand run and returns
+// an error when one of them did not exit successfully.
+func summarizeErrandResults(ui biui.UI, errandName string, results []boshdir.ErrandResult) error {
 	table := boshtbl.Table{
 		Content: "errand(s)",
 
@@ -89,7 +91,7 @@ func (c RunErrandCmd) summarize(errandName string, results []boshdir.ErrandResul
 			errandErr = bosherr.Errorf("%s completed with error %s", prefix, suffix)
 		}
 	}
-	c.ui.PrintTable(table)
+	ui.PrintTable(table)
 
 	return errandErr
 }
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"github.com/cppforlife/go-patch/patch"
+
+	bideplmanifest "github.com/cloudfoundry/bosh-cli/deployment/manifest"
+	bidepltpl "github.com/cloudfoundry/bosh-cli/deployment/template"
+	boshdir "github.com/cloudfoundry/bosh-cli/director"
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	boshui "github.com/cloudfoundry/bosh-cli/ui"
+)
+
+type RunErrandEnvCmd struct {
+	envProvider      func(string, string, boshtpl.Variables, patch.Op) EnvAgent
+	deploymentParser bideplmanifest.Parser
+	ui               boshui.UI
+}
+
+func NewRunErrandEnvCmd(
+	envProvider func(string, string, boshtpl.Variables, patch.Op) EnvAgent,
+	deploymentParser bideplmanifest.Parser,
+	ui boshui.UI,
+) RunErrandEnvCmd {
+	return RunErrandEnvCmd{envProvider: envProvider, deploymentParser: deploymentParser, ui: ui}
+}
+
+// Run runs an errand of the deployed environment. Errands are colocated on
+// the VMs of the environment: their release jobs are rendered and installed
+// by create-env, and the agent of the instance runs each of them when asked
+// to.
+func (c RunErrandEnvCmd) Run(opts RunErrandEnvOpts) error {
+	manifestPath := opts.Args.Manifest.Path
+
+	interpolatedTemplate, err := bidepltpl.NewDeploymentTemplate(opts.Args.Manifest.Bytes).Evaluate(
+		opts.VarFlags.AsVariables(), opts.OpsFlags.AsOp())
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Evaluating manifest '%s'", manifestPath)
+	}
+
+	deploymentManifest, err := c.deploymentParser.Parse(interpolatedTemplate, manifestPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Parsing deployment manifest '%s'", manifestPath)
+	}
+
+	errandJob, found := deploymentManifest.FindJobByName(opts.Args.Name)
+	if !found || !errandJob.IsErrand() {
+		return bosherr.Errorf("Errand '%s' not found in deployment manifest '%s'", opts.Args.Name, manifestPath)
+	}
+
+	agent := c.envProvider(manifestPath, opts.StatePath, opts.VarFlags.AsVariables(), opts.OpsFlags.AsOp())
+
+	var results []boshdir.ErrandResult
+
+	for _, releaseJob := range errandJob.Templates {
+		c.ui.PrintLinef("Running errand '%s' job '%s'", errandJob.Name, releaseJob.Name)
+
+		result, err := agent.RunErrand(opts.Index, releaseJob.Name)
+		if err != nil {
+			return err
+		}
+
+		results = append(results, result)
+	}
+
+	return summarizeErrandResults(c.ui, errandJob.Name, results)
+}
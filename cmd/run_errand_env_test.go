@@ -0,0 +1,148 @@
+package cmd_test
+
+import (
+	"errors"
+
+	"github.com/cppforlife/go-patch/patch"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/cmd"
+	mock_cmd "github.com/cloudfoundry/bosh-cli/cmd/mocks"
+	bideplmanifest "github.com/cloudfoundry/bosh-cli/deployment/manifest"
+	fakebideplmanifest "github.com/cloudfoundry/bosh-cli/deployment/manifest/manifestfakes"
+	boshdir "github.com/cloudfoundry/bosh-cli/director"
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	fakeui "github.com/cloudfoundry/bosh-cli/ui/fakes"
+	boshtbl "github.com/cloudfoundry/bosh-cli/ui/table"
+)
+
+var _ = Describe("RunErrandEnvCmd", func() {
+	var mockCtrl *gomock.Controller
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	var (
+		mockEnvAgent     *mock_cmd.MockEnvAgent
+		deploymentParser *fakebideplmanifest.FakeParser
+		ui               *fakeui.FakeUI
+		command          RunErrandEnvCmd
+
+		opts RunErrandEnvOpts
+	)
+
+	BeforeEach(func() {
+		mockEnvAgent = mock_cmd.NewMockEnvAgent(mockCtrl)
+		deploymentParser = &fakebideplmanifest.FakeParser{}
+		ui = &fakeui.FakeUI{}
+
+		envProvider := func(_ string, _ string, _ boshtpl.Variables, _ patch.Op) EnvAgent {
+			return mockEnvAgent
+		}
+
+		command = NewRunErrandEnvCmd(envProvider, deploymentParser, ui)
+
+		deploymentParser.ParseReturns(bideplmanifest.Manifest{
+			Jobs: []bideplmanifest.Job{
+				{
+					Name: "bosh",
+					Templates: []bideplmanifest.ReleaseJobRef{
+						{Name: "director", Release: "bosh"},
+					},
+				},
+				{
+					Name:      "smoke-tests",
+					Lifecycle: bideplmanifest.JobLifecycleErrand,
+					Templates: []bideplmanifest.ReleaseJobRef{
+						{Name: "smoke-tests", Release: "bosh"},
+						{Name: "status", Release: "bosh"},
+					},
+				},
+			},
+		}, nil)
+
+		opts = RunErrandEnvOpts{
+			Args: RunErrandEnvArgs{
+				Manifest: FileBytesWithPathArg{Path: "/path/to/bosh.yml", Bytes: []byte("name: bosh")},
+				Name:     "smoke-tests",
+			},
+		}
+	})
+
+	act := func() error { return command.Run(opts) }
+
+	It("runs every release job of the errand and prints their output", func() {
+		mockEnvAgent.EXPECT().RunErrand(0, "smoke-tests").Return(
+			boshdir.ErrandResult{ExitCode: 0, Stdout: "smoke-tests-stdout"}, nil)
+		mockEnvAgent.EXPECT().RunErrand(0, "status").Return(
+			boshdir.ErrandResult{ExitCode: 0, Stderr: "status-stderr"}, nil)
+
+		err := act()
+		Expect(err).ToNot(HaveOccurred())
+
+		_, manifestPath := deploymentParser.ParseArgsForCall(0)
+		Expect(manifestPath).To(Equal("/path/to/bosh.yml"))
+
+		Expect(ui.Said).To(ContainElement("Running errand 'smoke-tests' job 'smoke-tests'"))
+		Expect(ui.Table.Rows).To(Equal([][]boshtbl.Value{
+			{
+				boshtbl.NewValueInt(0),
+				boshtbl.NewValueString("smoke-tests-stdout"),
+				boshtbl.NewValueString(""),
+			}, {
+				boshtbl.NewValueInt(0),
+				boshtbl.NewValueString(""),
+				boshtbl.NewValueString("status-stderr"),
+			},
+		}))
+	})
+
+	It("runs the errand on the instance with the index", func() {
+		opts.Index = 1
+
+		mockEnvAgent.EXPECT().RunErrand(1, "smoke-tests").Return(boshdir.ErrandResult{ExitCode: 0}, nil)
+		mockEnvAgent.EXPECT().RunErrand(1, "status").Return(boshdir.ErrandResult{ExitCode: 0}, nil)
+
+		Expect(act()).ToNot(HaveOccurred())
+	})
+
+	It("returns an error when the errand exits with an error", func() {
+		mockEnvAgent.EXPECT().RunErrand(0, "smoke-tests").Return(boshdir.ErrandResult{ExitCode: 1}, nil)
+		mockEnvAgent.EXPECT().RunErrand(0, "status").Return(boshdir.ErrandResult{ExitCode: 0}, nil)
+
+		err := act()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Errand 'smoke-tests' completed with error (exit code 1)"))
+	})
+
+	It("returns an error when the errand cannot be run", func() {
+		mockEnvAgent.EXPECT().RunErrand(0, "smoke-tests").Return(boshdir.ErrandResult{}, errors.New("fake-err"))
+
+		err := act()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("fake-err"))
+	})
+
+	It("returns an error when the manifest has no errand with the name", func() {
+		opts.Args.Name = "bosh"
+
+		err := act()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Errand 'bosh' not found in deployment manifest '/path/to/bosh.yml'"))
+	})
+
+	It("returns an error when parsing the manifest fails", func() {
+		deploymentParser.ParseReturns(bideplmanifest.Manifest{}, errors.New("fake-err"))
+
+		err := act()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Parsing deployment manifest '/path/to/bosh.yml'"))
+	})
+})
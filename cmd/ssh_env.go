@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"github.com/cppforlife/go-patch/patch"
+
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	boshssh "github.com/cloudfoundry/bosh-cli/ssh"
+	boshui "github.com/cloudfoundry/bosh-cli/ui"
+)
+
+type SSHEnvCmd struct {
+	envProvider      func(string, string, boshtpl.Variables, patch.Op) EnvAgent
+	intSSHRunner     boshssh.Runner
+	nonIntSSHRunner  boshssh.Runner
+	resultsSSHRunner boshssh.Runner
+	ui               boshui.UI
+}
+
+func NewSSHEnvCmd(
+	envProvider func(string, string, boshtpl.Variables, patch.Op) EnvAgent,
+	intSSHRunner boshssh.Runner,
+	nonIntSSHRunner boshssh.Runner,
+	resultsSSHRunner boshssh.Runner,
+	ui boshui.UI,
+) SSHEnvCmd {
+	return SSHEnvCmd{
+		envProvider:      envProvider,
+		intSSHRunner:     intSSHRunner,
+		nonIntSSHRunner:  nonIntSSHRunner,
+		resultsSSHRunner: resultsSSHRunner,
+		ui:               ui,
+	}
+}
+
+func (c SSHEnvCmd) Run(opts SSHEnvOpts) error {
+	if opts.Results || !c.ui.IsInteractive() {
+		if len(opts.Command) == 0 {
+			return bosherr.Errorf("Non-interactive SSH requires non-empty command")
+		}
+	}
+
+	sshOpts, connOpts, err := opts.GatewayFlags.AsSSHOpts()
+	if err != nil {
+		return err
+	}
+
+	connOpts.RawOpts = opts.RawOpts.AsStrings()
+
+	agent := c.envProvider(
+		opts.Args.Manifest.Path, opts.StatePath, opts.VarFlags.AsVariables(), opts.OpsFlags.AsOp())
+
+	result, err := agent.SetUpSSH(opts.Index, sshOpts)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = agent.CleanUpSSH(opts.Index, sshOpts)
+	}()
+
+	var runner boshssh.Runner
+
+	if opts.Results {
+		runner = c.resultsSSHRunner
+	} else if !c.ui.IsInteractive() || len(opts.Command) > 0 {
+		runner = c.nonIntSSHRunner
+	} else {
+		runner = c.intSSHRunner
+	}
+
+	err = runner.Run(connOpts, result, opts.Command)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Running SSH")
+	}
+
+	return nil
+}
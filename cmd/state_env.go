@@ -0,0 +1,281 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cppforlife/go-patch/patch"
+	"gopkg.in/yaml.v2"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	biconfig "github.com/cloudfoundry/bosh-cli/config"
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	boshui "github.com/cloudfoundry/bosh-cli/ui"
+	boshtbl "github.com/cloudfoundry/bosh-cli/ui/table"
+)
+
+type StateEnvCmd struct {
+	envProvider func(string, string, boshtpl.Variables, patch.Op) biconfig.DeploymentStateService
+	ui          boshui.UI
+}
+
+func NewStateEnvCmd(envProvider func(string, string, boshtpl.Variables, patch.Op) biconfig.DeploymentStateService, ui boshui.UI) StateEnvCmd {
+	return StateEnvCmd{envProvider: envProvider, ui: ui}
+}
+
+func (c StateEnvCmd) Run(opts StateEnvOpts) (err error) {
+	deploymentStateService := c.envProvider(
+		opts.Args.Manifest.Path, opts.StatePath, opts.VarFlags.AsVariables(), opts.OpsFlags.AsOp())
+
+	if !deploymentStateService.Exists() {
+		return bosherr.Errorf("Deployment state file '%s' not found", deploymentStateService.Path())
+	}
+
+	// the deployment state is not read while another operation writes it
+	err = deploymentStateService.Lock()
+	if err != nil {
+		return bosherr.WrapError(err, "Locking deployment state")
+	}
+
+	defer func() {
+		unlockErr := deploymentStateService.Unlock()
+		if unlockErr != nil && err == nil {
+			err = bosherr.WrapError(unlockErr, "Unlocking deployment state")
+		}
+	}()
+
+	state, err := deploymentStateService.Load()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading deployment state")
+	}
+
+	if opts.Path.IsSet() {
+		return c.printPath(state, opts.Path)
+	}
+
+	c.printSummary(state)
+	c.printDisks(state)
+	c.printStemcells(state)
+	c.printReleases(state)
+
+	if len(state.Instances) > 0 {
+		c.printInstances(state)
+	}
+
+	return nil
+}
+
+// printPath prints the value at the path in the deployment state file, like
+// interpolate prints a value of a template
+func (c StateEnvCmd) printPath(state biconfig.DeploymentState, path patch.Pointer) error {
+	jsonBytes, err := json.Marshal(state)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling deployment state")
+	}
+
+	// JSON is YAML, and the YAML representation is what patch operations work on
+	var obj interface{}
+
+	err = yaml.Unmarshal(jsonBytes, &obj)
+	if err != nil {
+		return bosherr.WrapError(err, "Unmarshalling deployment state")
+	}
+
+	obj, err = patch.FindOp{Path: path}.Apply(obj)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Finding '%s' in deployment state", path.String())
+	}
+
+	if str, ok := obj.(string); ok {
+		c.ui.PrintBlock(fmt.Sprintf("%s\n", str))
+		return nil
+	}
+
+	bytes, err := yaml.Marshal(obj)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling value")
+	}
+
+	c.ui.PrintBlock(string(bytes))
+
+	return nil
+}
+
+func (c StateEnvCmd) printSummary(state biconfig.DeploymentState) {
+	var currentDiskCID, currentStemcell string
+
+	for _, disk := range state.Disks {
+		if disk.ID == state.CurrentDiskID {
+			currentDiskCID = disk.CID
+		}
+	}
+
+	for _, stemcell := range state.Stemcells {
+		if stemcell.ID == state.CurrentStemcellID {
+			currentStemcell = stemcell.Name + "/" + stemcell.Version
+		}
+	}
+
+	inProgress := "no"
+	if state.Checkpoint != nil {
+		inProgress = "yes"
+	}
+
+	c.ui.PrintTable(boshtbl.Table{
+		Header: []boshtbl.Header{
+			boshtbl.NewHeader("Director ID"),
+			boshtbl.NewHeader("Installation ID"),
+			boshtbl.NewHeader("VM CID"),
+			boshtbl.NewHeader("Agent ID"),
+			boshtbl.NewHeader("Disk CID"),
+			boshtbl.NewHeader("Stemcell"),
+			boshtbl.NewHeader("Manifest SHA"),
+			boshtbl.NewHeader("Unfinished Deploy"),
+		},
+		Rows: [][]boshtbl.Value{
+			{
+				boshtbl.NewValueString(state.DirectorID),
+				boshtbl.NewValueString(state.InstallationID),
+				boshtbl.NewValueString(state.CurrentVMCID),
+				boshtbl.NewValueString(state.CurrentAgentID),
+				boshtbl.NewValueString(currentDiskCID),
+				boshtbl.NewValueString(currentStemcell),
+				boshtbl.NewValueString(state.CurrentManifestSHA),
+				boshtbl.NewValueString(inProgress),
+			},
+		},
+		Transpose:      true,
+		WrapToTerminal: true,
+	})
+}
+
+func (c StateEnvCmd) printDisks(state biconfig.DeploymentState) {
+	table := boshtbl.Table{
+		Content: "disks",
+		Header: []boshtbl.Header{
+			boshtbl.NewHeader("Disk CID"),
+			boshtbl.NewHeader("Size"),
+			boshtbl.NewHeader("State"),
+		},
+		SortBy:         []boshtbl.ColumnSort{{Column: 0, Asc: true}},
+		WrapToTerminal: true,
+	}
+
+	for _, disk := range state.Disks {
+		diskState := "in use"
+		if disk.Orphaned {
+			diskState = "orphaned"
+		}
+
+		table.Rows = append(table.Rows, []boshtbl.Value{
+			boshtbl.NewValueString(disk.CID),
+			boshtbl.NewValueMegaBytes(uint64(disk.Size)),
+			boshtbl.NewValueString(diskState),
+		})
+	}
+
+	c.ui.PrintTable(table)
+}
+
+func (c StateEnvCmd) printStemcells(state biconfig.DeploymentState) {
+	table := boshtbl.Table{
+		Content: "stemcells",
+		Header: []boshtbl.Header{
+			boshtbl.NewHeader("Name"),
+			boshtbl.NewHeader("Version"),
+			boshtbl.NewHeader("CID"),
+		},
+		SortBy: []boshtbl.ColumnSort{
+			{Column: 0, Asc: true},
+			{Column: 1},
+		},
+		Notes:          []string{"(*) Currently deployed"},
+		WrapToTerminal: true,
+	}
+
+	for _, stemcell := range state.Stemcells {
+		table.Rows = append(table.Rows, []boshtbl.Value{
+			boshtbl.NewValueString(stemcell.Name),
+			boshtbl.NewValueSuffix(
+				boshtbl.NewValueString(stemcell.Version),
+				currentMark(stemcell.ID == state.CurrentStemcellID),
+			),
+			boshtbl.NewValueString(stemcell.CID),
+		})
+	}
+
+	c.ui.PrintTable(table)
+}
+
+func (c StateEnvCmd) printReleases(state biconfig.DeploymentState) {
+	table := boshtbl.Table{
+		Content: "releases",
+		Header: []boshtbl.Header{
+			boshtbl.NewHeader("Name"),
+			boshtbl.NewHeader("Version"),
+		},
+		SortBy: []boshtbl.ColumnSort{
+			{Column: 0, Asc: true},
+			{Column: 1},
+		},
+		Notes:          []string{"(*) Currently deployed"},
+		WrapToTerminal: true,
+	}
+
+	currentReleaseIDs := map[string]bool{}
+	for _, id := range state.CurrentReleaseIDs {
+		currentReleaseIDs[id] = true
+	}
+
+	for _, release := range state.Releases {
+		table.Rows = append(table.Rows, []boshtbl.Value{
+			boshtbl.NewValueString(release.Name),
+			boshtbl.NewValueSuffix(
+				boshtbl.NewValueString(release.Version),
+				currentMark(currentReleaseIDs[release.ID]),
+			),
+		})
+	}
+
+	c.ui.PrintTable(table)
+}
+
+func (c StateEnvCmd) printInstances(state biconfig.DeploymentState) {
+	diskCIDs := map[string]string{}
+	for _, disk := range state.Disks {
+		diskCIDs[disk.ID] = disk.CID
+	}
+
+	table := boshtbl.Table{
+		Content: "instances",
+		Header: []boshtbl.Header{
+			boshtbl.NewHeader("Index"),
+			boshtbl.NewHeader("IP"),
+			boshtbl.NewHeader("VM CID"),
+			boshtbl.NewHeader("Agent ID"),
+			boshtbl.NewHeader("Disk CID"),
+		},
+		SortBy:         []boshtbl.ColumnSort{{Column: 0, Asc: true}},
+		WrapToTerminal: true,
+	}
+
+	for _, instance := range state.Instances {
+		table.Rows = append(table.Rows, []boshtbl.Value{
+			boshtbl.NewValueInt(instance.Index),
+			boshtbl.NewValueString(instance.IP),
+			boshtbl.NewValueString(instance.VMCID),
+			boshtbl.NewValueString(instance.AgentID),
+			boshtbl.NewValueString(diskCIDs[instance.DiskID]),
+		})
+	}
+
+	c.ui.PrintTable(table)
+}
+
+func currentMark(current bool) string {
+	if current {
+		return "*"
+	}
+	return ""
+}
@@ -0,0 +1,245 @@
+package cmd_test
+
+import (
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
+	"github.com/cppforlife/go-patch/patch"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/cmd"
+	biconfig "github.com/cloudfoundry/bosh-cli/config"
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	fakeui "github.com/cloudfoundry/bosh-cli/ui/fakes"
+	boshtbl "github.com/cloudfoundry/bosh-cli/ui/table"
+)
+
+var _ = Describe("StateEnvCmd", func() {
+	var (
+		deploymentStateService biconfig.DeploymentStateService
+		ui                     *fakeui.FakeUI
+		command                StateEnvCmd
+
+		opts StateEnvOpts
+	)
+
+	BeforeEach(func() {
+		deploymentStateService = biconfig.NewFileSystemDeploymentStateService(
+			fakesys.NewFakeFileSystem(), &fakeuuid.FakeGenerator{}, boshlog.NewLogger(boshlog.LevelNone), "/path/to/bosh-state.json")
+		ui = &fakeui.FakeUI{}
+
+		envProvider := func(_ string, _ string, _ boshtpl.Variables, _ patch.Op) biconfig.DeploymentStateService {
+			return deploymentStateService
+		}
+
+		command = NewStateEnvCmd(envProvider, ui)
+
+		opts = StateEnvOpts{
+			Args: StateEnvArgs{
+				Manifest: FileBytesWithPathArg{Path: "/path/to/bosh.yml"},
+			},
+		}
+	})
+
+	act := func() error { return command.Run(opts) }
+
+	Context("when the environment has been deployed", func() {
+		BeforeEach(func() {
+			err := deploymentStateService.Save(biconfig.DeploymentState{
+				DirectorID:         "fake-director-id",
+				InstallationID:     "fake-installation-id",
+				CurrentVMCID:       "fake-vm-cid",
+				CurrentAgentID:     "fake-agent-id",
+				CurrentStemcellID:  "fake-stemcell-id-2",
+				CurrentDiskID:      "fake-disk-id-1",
+				CurrentReleaseIDs:  []string{"fake-release-id-1"},
+				CurrentManifestSHA: "fake-manifest-sha",
+				Disks: []biconfig.DiskRecord{
+					{ID: "fake-disk-id-1", CID: "fake-disk-cid-1", Size: 1024},
+					{ID: "fake-disk-id-2", CID: "fake-disk-cid-2", Size: 2048, Orphaned: true},
+				},
+				Stemcells: []biconfig.StemcellRecord{
+					{ID: "fake-stemcell-id-1", Name: "fake-stemcell", Version: "1", CID: "fake-stemcell-cid-1"},
+					{ID: "fake-stemcell-id-2", Name: "fake-stemcell", Version: "2", CID: "fake-stemcell-cid-2"},
+				},
+				Releases: []biconfig.ReleaseRecord{
+					{ID: "fake-release-id-1", Name: "fake-release", Version: "3"},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("prints the current VM, disk, stemcell and manifest", func() {
+			Expect(act()).ToNot(HaveOccurred())
+
+			Expect(ui.Tables[0]).To(Equal(boshtbl.Table{
+				Header: []boshtbl.Header{
+					boshtbl.NewHeader("Director ID"),
+					boshtbl.NewHeader("Installation ID"),
+					boshtbl.NewHeader("VM CID"),
+					boshtbl.NewHeader("Agent ID"),
+					boshtbl.NewHeader("Disk CID"),
+					boshtbl.NewHeader("Stemcell"),
+					boshtbl.NewHeader("Manifest SHA"),
+					boshtbl.NewHeader("Unfinished Deploy"),
+				},
+				Rows: [][]boshtbl.Value{
+					{
+						boshtbl.NewValueString("fake-director-id"),
+						boshtbl.NewValueString("fake-installation-id"),
+						boshtbl.NewValueString("fake-vm-cid"),
+						boshtbl.NewValueString("fake-agent-id"),
+						boshtbl.NewValueString("fake-disk-cid-1"),
+						boshtbl.NewValueString("fake-stemcell/2"),
+						boshtbl.NewValueString("fake-manifest-sha"),
+						boshtbl.NewValueString("no"),
+					},
+				},
+				Transpose:      true,
+				WrapToTerminal: true,
+			}))
+		})
+
+		It("prints the disks", func() {
+			Expect(act()).ToNot(HaveOccurred())
+
+			Expect(ui.Tables[1].Content).To(Equal("disks"))
+			Expect(ui.Tables[1].Rows).To(Equal([][]boshtbl.Value{
+				{
+					boshtbl.NewValueString("fake-disk-cid-1"),
+					boshtbl.NewValueMegaBytes(1024),
+					boshtbl.NewValueString("in use"),
+				},
+				{
+					boshtbl.NewValueString("fake-disk-cid-2"),
+					boshtbl.NewValueMegaBytes(2048),
+					boshtbl.NewValueString("orphaned"),
+				},
+			}))
+		})
+
+		It("prints the stemcells, marking the current one", func() {
+			Expect(act()).ToNot(HaveOccurred())
+
+			Expect(ui.Tables[2].Content).To(Equal("stemcells"))
+			Expect(ui.Tables[2].Rows).To(Equal([][]boshtbl.Value{
+				{
+					boshtbl.NewValueString("fake-stemcell"),
+					boshtbl.NewValueSuffix(boshtbl.NewValueString("1"), ""),
+					boshtbl.NewValueString("fake-stemcell-cid-1"),
+				},
+				{
+					boshtbl.NewValueString("fake-stemcell"),
+					boshtbl.NewValueSuffix(boshtbl.NewValueString("2"), "*"),
+					boshtbl.NewValueString("fake-stemcell-cid-2"),
+				},
+			}))
+		})
+
+		It("prints the releases, marking the current ones", func() {
+			Expect(act()).ToNot(HaveOccurred())
+
+			Expect(ui.Tables[3].Content).To(Equal("releases"))
+			Expect(ui.Tables[3].Rows).To(Equal([][]boshtbl.Value{
+				{
+					boshtbl.NewValueString("fake-release"),
+					boshtbl.NewValueSuffix(boshtbl.NewValueString("3"), "*"),
+				},
+			}))
+		})
+
+		It("fails while another operation holds the lock on the deployment state", func() {
+			err := deploymentStateService.Lock()
+			Expect(err).ToNot(HaveOccurred())
+
+			err = act()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Locking deployment state"))
+		})
+
+		It("releases the lock on the deployment state", func() {
+			Expect(act()).ToNot(HaveOccurred())
+
+			err := deploymentStateService.Lock()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("does not print instances when there is only the first instance", func() {
+			Expect(act()).ToNot(HaveOccurred())
+
+			Expect(ui.Tables).To(HaveLen(4))
+		})
+
+		Context("when a path is given", func() {
+			It("prints the value at the path", func() {
+				opts.Path = patch.MustNewPointerFromString("/disks/cid=fake-disk-cid-2/size")
+
+				Expect(act()).ToNot(HaveOccurred())
+
+				Expect(ui.Blocks).To(Equal([]string{"2048\n"}))
+				Expect(ui.Tables).To(BeEmpty())
+			})
+
+			It("prints strings without quoting", func() {
+				opts.Path = patch.MustNewPointerFromString("/current_vm_cid")
+
+				Expect(act()).ToNot(HaveOccurred())
+
+				Expect(ui.Blocks).To(Equal([]string{"fake-vm-cid\n"}))
+			})
+
+			It("prints maps and lists as YAML", func() {
+				opts.Path = patch.MustNewPointerFromString("/releases/0")
+
+				Expect(act()).ToNot(HaveOccurred())
+
+				Expect(ui.Blocks).To(Equal([]string{"id: fake-release-id-1\nname: fake-release\nversion: \"3\"\n"}))
+			})
+
+			It("returns an error when the path is not found", func() {
+				opts.Path = patch.MustNewPointerFromString("/disks/cid=unknown-cid")
+
+				err := act()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Finding '/disks/cid=unknown-cid' in deployment state"))
+			})
+		})
+
+		Context("when the environment has other instances", func() {
+			BeforeEach(func() {
+				state, err := deploymentStateService.Load()
+				Expect(err).ToNot(HaveOccurred())
+
+				state.Disks = append(state.Disks, biconfig.DiskRecord{ID: "fake-disk-id-3", CID: "fake-disk-cid-3", Size: 1024})
+				state.Instances = []biconfig.InstanceRecord{
+					{Index: 1, IP: "10.0.0.2", VMCID: "fake-vm-cid-1", AgentID: "fake-agent-id-1", DiskID: "fake-disk-id-3"},
+				}
+
+				err = deploymentStateService.Save(state)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("prints the instances", func() {
+				Expect(act()).ToNot(HaveOccurred())
+
+				Expect(ui.Tables[4].Content).To(Equal("instances"))
+				Expect(ui.Tables[4].Rows).To(Equal([][]boshtbl.Value{
+					{
+						boshtbl.NewValueInt(1),
+						boshtbl.NewValueString("10.0.0.2"),
+						boshtbl.NewValueString("fake-vm-cid-1"),
+						boshtbl.NewValueString("fake-agent-id-1"),
+						boshtbl.NewValueString("fake-disk-cid-3"),
+					},
+				}))
+			})
+		})
+	})
+
+	It("returns an error when the deployment state file does not exist", func() {
+		err := act()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Deployment state file '/path/to/bosh-state.json' not found"))
+	})
+})
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"github.com/cppforlife/go-patch/patch"
+
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	boshui "github.com/cloudfoundry/bosh-cli/ui"
+)
+
+type ValidateEnvCmd struct {
+	ui          boshui.UI
+	envProvider func(string, string, boshtpl.Variables, patch.Op) DeploymentPreparer
+}
+
+func NewValidateEnvCmd(ui boshui.UI, envProvider func(string, string, boshtpl.Variables, patch.Op) DeploymentPreparer) *ValidateEnvCmd {
+	return &ValidateEnvCmd{ui: ui, envProvider: envProvider}
+}
+
+func (c *ValidateEnvCmd) Run(stage boshui.Stage, opts ValidateEnvOpts) error {
+	c.ui.BeginLinef("Deployment manifest: '%s'\n", opts.Args.Manifest.Path)
+
+	depPreparer := c.envProvider(
+		opts.Args.Manifest.Path, opts.StatePath, opts.VarFlags.AsVariables(), opts.OpsFlags.AsOp())
+
+	err := depPreparer.PrepareValidate(stage)
+	if err != nil {
+		return err
+	}
+
+	c.ui.BeginLinef("\nManifest, releases, stemcell and CPI are valid.\n")
+
+	return nil
+}
@@ -8,13 +8,18 @@ import (
 
 // Shared
 type VarFlags struct {
-	VarKVs      []boshtpl.VarKV       `long:"var"        short:"v" value-name:"VAR=VALUE" description:"Set variable"`
-	VarFiles    []boshtpl.VarFileArg  `long:"var-file"             value-name:"VAR=PATH"  description:"Set variable to file contents"`
-	VarsFiles   []boshtpl.VarsFileArg `long:"vars-file"  short:"l" value-name:"PATH"      description:"Load variables from a YAML file"`
-	VarsEnvs    []boshtpl.VarsEnvArg  `long:"vars-env"             value-name:"PREFIX"    description:"Load variables from environment variables (e.g.: 'MY' to load MY_var=value)"`
-	VarsFSStore VarsFSStore           `long:"vars-store"           value-name:"PATH"      description:"Load/save variables from/to a YAML file"`
+	VarKVs           []boshtpl.VarKV       `long:"var"        short:"v" value-name:"VAR=VALUE" description:"Set variable"`
+	VarFiles         []boshtpl.VarFileArg  `long:"var-file"             value-name:"VAR=PATH"  description:"Set variable to file contents"`
+	VarsFiles        []boshtpl.VarsFileArg `long:"vars-file"  short:"l" value-name:"PATH"      description:"Load variables from a YAML file"`
+	VarsEnvs         []boshtpl.VarsEnvArg  `long:"vars-env"             value-name:"PREFIX"    description:"Load variables from environment variables (e.g.: 'MY' to load MY_var=value)"`
+	VarsFSStore      VarsFSStore           `long:"vars-store"           value-name:"PATH"      description:"Load/save variables from/to a YAML file"`
+	VarsConfigServer VarsConfigServerArg   `long:"vars-config-server"   value-name:"PATH"      description:"Load/generate variables using a config server described in a YAML file"`
 }
 
+// AsVariables combines all variable sources; earlier sources take precedence:
+// --var, then --var-file, then --vars-file, then --vars-env, then --vars-store,
+// then --vars-config-server.
+// Within the same flag, later occurrences take precedence over earlier ones.
 func (f VarFlags) AsVariables() boshtpl.Variables {
 	var firstToUse []boshtpl.Variables
 
@@ -38,6 +43,10 @@ func (f VarFlags) AsVariables() boshtpl.Variables {
 		firstToUse = append(firstToUse, store)
 	}
 
+	if f.VarsConfigServer.IsSet() {
+		firstToUse = append(firstToUse, f.VarsConfigServer.Vars)
+	}
+
 	vars := boshtpl.NewMultiVars(firstToUse)
 
 	if f.VarsFSStore.IsSet() {
@@ -56,3 +65,34 @@ func (f VarFlags) kvsAsVars() boshtpl.Variables {
 
 	return vars
 }
+
+// Sources describes the configured variable sources in the same precedence order as AsVariables.
+func (f VarFlags) Sources() []string {
+	var sources []string
+
+	if len(f.VarKVs) > 0 {
+		sources = append(sources, "--var")
+	}
+
+	if len(f.VarFiles) > 0 {
+		sources = append(sources, "--var-file")
+	}
+
+	if len(f.VarsFiles) > 0 {
+		sources = append(sources, "--vars-file")
+	}
+
+	if len(f.VarsEnvs) > 0 {
+		sources = append(sources, "--vars-env")
+	}
+
+	if f.VarsFSStore.IsSet() {
+		sources = append(sources, "--vars-store")
+	}
+
+	if f.VarsConfigServer.IsSet() {
+		sources = append(sources, "--vars-config-server")
+	}
+
+	return sources
+}
@@ -178,4 +178,20 @@ env: store
 			Expect(valRaw["ca"].(string)).To(Equal(caCert))
 		})
 	})
+
+	Describe("Sources", func() {
+		It("returns no sources when no variable flags are given", func() {
+			Expect(VarFlags{}.Sources()).To(BeEmpty())
+		})
+
+		It("lists given variable sources in precedence order", func() {
+			flags := VarFlags{
+				VarsEnvs:  []VarsEnvArg{{}},
+				VarsFiles: []VarsFileArg{{}, {}},
+				VarKVs:    []VarKV{{Name: "kv", Value: "kv"}},
+			}
+
+			Expect(flags.Sources()).To(Equal([]string{"--var", "--vars-file", "--vars-env"}))
+		})
+	})
 })
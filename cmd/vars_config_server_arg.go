@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"gopkg.in/yaml.v2"
+
+	boshcs "github.com/cloudfoundry/bosh-cli/configserver"
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	boshuaa "github.com/cloudfoundry/bosh-cli/uaa"
+)
+
+// VarsConfigServerArg loads variables from a config server (or CredHub)
+// described by a YAML file, e.g.:
+//
+//   url: https://10.0.0.6:8080
+//   ca_cert: ...
+//   namespace: /bosh-init
+//   uaa:
+//     url: https://10.0.0.6:8443
+//     client: director_to_config_server
+//     client_secret: ...
+//     ca_cert: ...
+type VarsConfigServerArg struct {
+	FS     boshsys.FileSystem
+	Logger boshlog.Logger
+
+	Vars boshtpl.Variables
+}
+
+type varsConfigServerConfig struct {
+	URL       string `yaml:"url"`
+	CACert    string `yaml:"ca_cert"`
+	Namespace string `yaml:"namespace"`
+
+	UAA struct {
+		URL          string `yaml:"url"`
+		Client       string `yaml:"client"`
+		ClientSecret string `yaml:"client_secret"`
+		CACert       string `yaml:"ca_cert"`
+	} `yaml:"uaa"`
+}
+
+func (a VarsConfigServerArg) IsSet() bool { return a.Vars != nil }
+
+func (a *VarsConfigServerArg) UnmarshalFlag(filePath string) error {
+	if len(filePath) == 0 {
+		return bosherr.Errorf("Expected file path to be non-empty")
+	}
+
+	bytes, err := a.FS.ReadFile(filePath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading config server file '%s'", filePath)
+	}
+
+	var config varsConfigServerConfig
+
+	err = yaml.Unmarshal(bytes, &config)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Deserializing config server file '%s'", filePath)
+	}
+
+	logger := a.Logger
+	if logger == nil {
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+	}
+
+	uaaConfig, err := boshuaa.NewConfigFromURL(config.UAA.URL)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Building UAA config from config server file '%s'", filePath)
+	}
+
+	uaaConfig.Client = config.UAA.Client
+	uaaConfig.ClientSecret = config.UAA.ClientSecret
+	uaaConfig.CACert = config.UAA.CACert
+
+	uaa, err := boshuaa.NewFactory(logger).New(uaaConfig)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Building UAA from config server file '%s'", filePath)
+	}
+
+	csConfig, err := boshcs.NewConfigFromURL(config.URL)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Building config server config from file '%s'", filePath)
+	}
+
+	csConfig.CACert = config.CACert
+	csConfig.Namespace = config.Namespace
+	csConfig.TokenFunc = boshuaa.NewClientTokenSession(uaa).TokenFunc
+
+	client, err := boshcs.NewFactory(logger).New(csConfig)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Building config server client from file '%s'", filePath)
+	}
+
+	(*a).Vars = client
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package cmd_test
+
+import (
+	"errors"
+
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/cmd"
+)
+
+var _ = Describe("VarsConfigServerArg", func() {
+	Describe("UnmarshalFlag", func() {
+		var (
+			fs  *fakesys.FakeFileSystem
+			arg VarsConfigServerArg
+		)
+
+		BeforeEach(func() {
+			fs = fakesys.NewFakeFileSystem()
+			arg = VarsConfigServerArg{FS: fs}
+		})
+
+		It("sets variables backed by the config server", func() {
+			fs.WriteFileString("/some/path", `
+url: https://config-server:8080
+namespace: /bosh-init
+uaa:
+  url: https://uaa:8443
+  client: client
+  client_secret: client-secret
+`)
+
+			err := (&arg).UnmarshalFlag("/some/path")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(arg.IsSet()).To(BeTrue())
+		})
+
+		It("returns an error if config server url is missing", func() {
+			fs.WriteFileString("/some/path", `
+uaa:
+  url: https://uaa:8443
+  client: client
+  client_secret: client-secret
+`)
+
+			err := (&arg).UnmarshalFlag("/some/path")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Building config server config"))
+		})
+
+		It("returns an error if namespace is not absolute", func() {
+			fs.WriteFileString("/some/path", `
+url: https://config-server:8080
+namespace: bosh-init
+uaa:
+  url: https://uaa:8443
+  client: client
+  client_secret: client-secret
+`)
+
+			err := (&arg).UnmarshalFlag("/some/path")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Building config server client"))
+		})
+
+		It("returns an error if reading file fails", func() {
+			fs.WriteFileString("/some/path", "content")
+			fs.ReadFileError = errors.New("fake-err")
+
+			err := (&arg).UnmarshalFlag("/some/path")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-err"))
+		})
+
+		It("returns an error if parsing file fails", func() {
+			fs.WriteFileString("/some/path", "content")
+
+			err := (&arg).UnmarshalFlag("/some/path")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Deserializing config server file '/some/path'"))
+		})
+
+		It("returns an error when it's empty", func() {
+			err := (&arg).UnmarshalFlag("")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Expected file path to be non-empty"))
+		})
+	})
+})
@@ -32,6 +32,64 @@ var _ = Describe("common.net ip helpers", func() {
 			).To(Equal(net.ParseIP("2001:db8:1234:ffff:ffff:ffff:ffff:ffff")))
 		})
 	})
+
+	Describe("ParseIPRange", func() {
+		It("returns a single address", func() {
+			ips, err := binet.ParseIPRange("10.0.0.5")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ips).To(HaveLen(1))
+			Expect(ips[0].Equal(net.ParseIP("10.0.0.5"))).To(BeTrue())
+		})
+
+		It("expands an inclusive range across octet boundaries", func() {
+			ips, err := binet.ParseIPRange("10.0.0.254 - 10.0.1.1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ips).To(HaveLen(4))
+			Expect(ips[0].Equal(net.ParseIP("10.0.0.254"))).To(BeTrue())
+			Expect(ips[1].Equal(net.ParseIP("10.0.0.255"))).To(BeTrue())
+			Expect(ips[2].Equal(net.ParseIP("10.0.1.0"))).To(BeTrue())
+			Expect(ips[3].Equal(net.ParseIP("10.0.1.1"))).To(BeTrue())
+		})
+
+		It("expands an IPv6 range", func() {
+			ips, err := binet.ParseIPRange("2001:db8::ffff - 2001:db8::1:1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ips).To(HaveLen(3))
+			Expect(ips[0].Equal(net.ParseIP("2001:db8::ffff"))).To(BeTrue())
+			Expect(ips[1].Equal(net.ParseIP("2001:db8::1:0"))).To(BeTrue())
+			Expect(ips[2].Equal(net.ParseIP("2001:db8::1:1"))).To(BeTrue())
+		})
+
+		It("returns an error when the range mixes IPv4 and IPv6", func() {
+			_, err := binet.ParseIPRange("10.0.0.5 - 2001:db8::5")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("mixes IPv4 and IPv6 addresses"))
+		})
+
+		It("returns an error for invalid addresses", func() {
+			_, err := binet.ParseIPRange("10.0.0.5 - nope")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid IP range '10.0.0.5 - nope'"))
+		})
+
+		It("returns an error when the range is reversed", func() {
+			_, err := binet.ParseIPRange("10.0.0.10 - 10.0.0.5")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("must start with the lower address"))
+		})
+	})
+
+	Describe("ParseIPRangeBounds", func() {
+		It("returns the first and last address without expanding the range", func() {
+			first, last, err := binet.ParseIPRangeBounds("2001:db8::1 - 2001:db8::ffff:ffff:ffff")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(first.Equal(net.ParseIP("2001:db8::1"))).To(BeTrue())
+			Expect(last.Equal(net.ParseIP("2001:db8::ffff:ffff:ffff"))).To(BeTrue())
+
+			Expect(binet.InRange(net.ParseIP("2001:db8::1:0"), first, last)).To(BeTrue())
+			Expect(binet.InRange(net.ParseIP("2001:db8::1:0:0:0"), first, last)).To(BeFalse())
+		})
+	})
 })
 
 func netFor(ipNetString string) *net.IPNet {
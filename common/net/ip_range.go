@@ -0,0 +1,82 @@
+package net
+
+import (
+	"bytes"
+	"net"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// ParseIPRange expands either a single address ("10.0.0.5")
+// or an inclusive range ("10.0.0.5 - 10.0.0.10") into the addresses it covers.
+func ParseIPRange(ipRange string) ([]net.IP, error) {
+	first, last, err := ParseIPRangeBounds(ipRange)
+	if err != nil {
+		return nil, err
+	}
+
+	ips := []net.IP{}
+	for ip := first; bytes.Compare(ip.To16(), last.To16()) <= 0; ip = NextAddress(ip) {
+		ips = append(ips, ip)
+		if ip.Equal(last) {
+			break
+		}
+	}
+	return ips, nil
+}
+
+// ParseIPRangeBounds returns the first and the last address of either a single
+// address or an inclusive range, without expanding the addresses in between.
+func ParseIPRangeBounds(ipRange string) (net.IP, net.IP, error) {
+	parts := strings.Split(ipRange, "-")
+
+	switch len(parts) {
+	case 1:
+		ip := net.ParseIP(strings.TrimSpace(parts[0]))
+		if ip == nil {
+			return nil, nil, bosherr.Errorf("Invalid IP '%s'", ipRange)
+		}
+		return ip, ip, nil
+
+	case 2:
+		first := net.ParseIP(strings.TrimSpace(parts[0]))
+		last := net.ParseIP(strings.TrimSpace(parts[1]))
+		if first == nil || last == nil {
+			return nil, nil, bosherr.Errorf("Invalid IP range '%s'", ipRange)
+		}
+
+		if (first.To4() == nil) != (last.To4() == nil) {
+			return nil, nil, bosherr.Errorf("IP range '%s' mixes IPv4 and IPv6 addresses", ipRange)
+		}
+
+		if bytes.Compare(first.To16(), last.To16()) > 0 {
+			return nil, nil, bosherr.Errorf("IP range '%s' must start with the lower address", ipRange)
+		}
+
+		return first, last, nil
+
+	default:
+		return nil, nil, bosherr.Errorf("Invalid IP range '%s'", ipRange)
+	}
+}
+
+// InRange tells whether ip is between first and last, inclusive.
+func InRange(ip, first, last net.IP) bool {
+	return bytes.Compare(ip.To16(), first.To16()) >= 0 && bytes.Compare(ip.To16(), last.To16()) <= 0
+}
+
+// NextAddress returns the address directly following ip.
+func NextAddress(ip net.IP) net.IP {
+	next := make(net.IP, len(ip.To16()))
+	copy(next, ip.To16())
+
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+
+	return next
+}
@@ -12,6 +12,10 @@ func AbsolutifyPath(pathToManifest string, pathToFile string, fs boshsys.FileSys
 		return pathToFile, nil
 	}
 
+	if IsObjectStoreURL(pathToFile) {
+		return pathToFile, nil
+	}
+
 	if strings.HasPrefix(pathToFile, "file:///") || strings.HasPrefix(pathToFile, "/") {
 		return pathToFile, nil
 	}
@@ -36,3 +40,9 @@ func AbsolutifyPath(pathToManifest string, pathToFile string, fs boshsys.FileSys
 
 	return absPath, nil
 }
+
+// IsObjectStoreURL returns true for s3:// and gs:// URLs of releases and
+// stemcells, which are downloaded from their bucket.
+func IsObjectStoreURL(url string) bool {
+	return strings.HasPrefix(url, "s3://") || strings.HasPrefix(url, "gs://")
+}
@@ -72,6 +72,18 @@ var _ = Describe("AbsolutifyPath", func() {
 			})
 		})
 
+		Context("file path begins with s3:// or gs://", func() {
+			It("passes the file path it recieved", func() {
+				result, err := util.AbsolutifyPath(fakeManifestPath, "s3://fake-bucket/path/file.tgz", realfs)
+				Expect(result).To(Equal("s3://fake-bucket/path/file.tgz"))
+				Expect(err).ToNot(HaveOccurred())
+
+				result, err = util.AbsolutifyPath(fakeManifestPath, "gs://fake-bucket/path/file.tgz", realfs)
+				Expect(result).To(Equal("gs://fake-bucket/path/file.tgz"))
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
 		Context("file path begins with file://", func() {
 			Context("file path is relative to manifest", func() {
 				It("joins file path to the manifest directory", func() {
@@ -105,3 +117,15 @@ var _ = Describe("AbsolutifyPath", func() {
 
 	})
 })
+
+var _ = Describe("IsObjectStoreURL", func() {
+	It("returns true for s3:// and gs:// URLs", func() {
+		Expect(util.IsObjectStoreURL("s3://fake-bucket/fake-release.tgz")).To(BeTrue())
+		Expect(util.IsObjectStoreURL("gs://fake-bucket/fake-release.tgz")).To(BeTrue())
+	})
+
+	It("returns false for other URLs", func() {
+		Expect(util.IsObjectStoreURL("https://fake-host/fake-release.tgz")).To(BeFalse())
+		Expect(util.IsObjectStoreURL("file:///fake-release.tgz")).To(BeFalse())
+	})
+})
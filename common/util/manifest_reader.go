@@ -0,0 +1,39 @@
+package util
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// ReadManifest reads a manifest file, transparently decompressing it when it
+// starts with the gzip magic bytes. Other files are returned unchanged.
+func ReadManifest(path string, fs boshsys.FileSystem) ([]byte, error) {
+	contents, err := fs.ReadFile(path)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Reading file %s", path)
+	}
+
+	if !bytes.HasPrefix(contents, gzipMagic) {
+		return contents, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(contents))
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Decompressing gzipped file %s", path)
+	}
+
+	defer reader.Close()
+
+	decompressed, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Decompressing gzipped file %s", path)
+	}
+
+	return decompressed, nil
+}
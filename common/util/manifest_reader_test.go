@@ -0,0 +1,62 @@
+package util_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-cli/common/util"
+)
+
+var _ = Describe("ReadManifest", func() {
+	var (
+		fs *fakesys.FakeFileSystem
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+	})
+
+	It("returns uncompressed files unchanged", func() {
+		fs.WriteFileString("/manifest.yml", "name: fake-name\n")
+
+		contents, err := util.ReadManifest("/manifest.yml", fs)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(contents)).To(Equal("name: fake-name\n"))
+	})
+
+	It("decompresses gzipped files", func() {
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		_, err := writer.Write([]byte("name: fake-name\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.Close()).To(Succeed())
+
+		fs.WriteFile("/manifest.yml.gz", buf.Bytes())
+
+		contents, err := util.ReadManifest("/manifest.yml.gz", fs)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(contents)).To(Equal("name: fake-name\n"))
+	})
+
+	It("returns an error when a gzipped file is corrupt", func() {
+		fs.WriteFile("/manifest.yml.gz", []byte{0x1f, 0x8b, 0x08, 0x00, 0x01})
+
+		_, err := util.ReadManifest("/manifest.yml.gz", fs)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Decompressing gzipped file /manifest.yml.gz"))
+	})
+
+	It("returns an error when the file cannot be read", func() {
+		fs.WriteFileString("/manifest.yml", "name: fake-name\n")
+		fs.RegisterReadFileError("/manifest.yml", errors.New("fake-read-error"))
+
+		_, err := util.ReadManifest("/manifest.yml", fs)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Reading file /manifest.yml: fake-read-error"))
+	})
+})
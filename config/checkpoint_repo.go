@@ -0,0 +1,227 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	biindex "github.com/cloudfoundry/bosh-cli/index"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type CheckpointRepo interface {
+	// Begin starts a checkpoint for deploying the manifest with the stemcell.
+	// The completed stages and compiled packages of an earlier deploy of both
+	// are kept.
+	Begin(manifestSHA, stemcellCID string) error
+	Complete(stage string) error
+	IsComplete(stage string) (bool, error)
+	Find() (CheckpointRecord, bool, error)
+	Clear() error
+
+	// CompiledPackageIndex returns an index of the packages compiled on the
+	// VM, which is kept with the checkpoint.
+	CompiledPackageIndex(vmCID string) biindex.Index
+}
+
+type checkpointRepo struct {
+	deploymentStateService DeploymentStateService
+
+	// compiledPackagesLock serializes saving packages that are compiled
+	// concurrently
+	compiledPackagesLock *sync.Mutex
+}
+
+func NewCheckpointRepo(deploymentStateService DeploymentStateService) CheckpointRepo {
+	return checkpointRepo{
+		deploymentStateService: deploymentStateService,
+		compiledPackagesLock:   &sync.Mutex{},
+	}
+}
+
+func (r checkpointRepo) Begin(manifestSHA, stemcellCID string) error {
+	deploymentState, err := r.deploymentStateService.Load()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading existing config")
+	}
+
+	checkpoint := deploymentState.Checkpoint
+	if checkpoint != nil && checkpoint.ManifestSHA == manifestSHA && checkpoint.StemcellCID == stemcellCID {
+		return nil
+	}
+
+	deploymentState.Checkpoint = &CheckpointRecord{
+		ManifestSHA: manifestSHA,
+		StemcellCID: stemcellCID,
+		Stages:      []string{},
+	}
+
+	err = r.deploymentStateService.Save(deploymentState)
+	if err != nil {
+		return bosherr.WrapError(err, "Saving new config")
+	}
+	return nil
+}
+
+func (r checkpointRepo) Complete(stage string) error {
+	deploymentState, err := r.deploymentStateService.Load()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading existing config")
+	}
+
+	if deploymentState.Checkpoint == nil {
+		return bosherr.Errorf("Completing stage '%s' without a checkpoint", stage)
+	}
+
+	if deploymentState.Checkpoint.IsComplete(stage) {
+		return nil
+	}
+
+	deploymentState.Checkpoint.Stages = append(deploymentState.Checkpoint.Stages, stage)
+
+	err = r.deploymentStateService.Save(deploymentState)
+	if err != nil {
+		return bosherr.WrapError(err, "Saving new config")
+	}
+	return nil
+}
+
+func (r checkpointRepo) IsComplete(stage string) (bool, error) {
+	deploymentState, err := r.deploymentStateService.Load()
+	if err != nil {
+		return false, bosherr.WrapError(err, "Loading existing config")
+	}
+
+	if deploymentState.Checkpoint == nil {
+		return false, nil
+	}
+
+	return deploymentState.Checkpoint.IsComplete(stage), nil
+}
+
+func (r checkpointRepo) Find() (CheckpointRecord, bool, error) {
+	deploymentState, err := r.deploymentStateService.Load()
+	if err != nil {
+		return CheckpointRecord{}, false, bosherr.WrapError(err, "Loading existing config")
+	}
+
+	if deploymentState.Checkpoint == nil {
+		return CheckpointRecord{}, false, nil
+	}
+
+	return *deploymentState.Checkpoint, true, nil
+}
+
+func (r checkpointRepo) Clear() error {
+	deploymentState, err := r.deploymentStateService.Load()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading existing config")
+	}
+
+	deploymentState.Checkpoint = nil
+
+	err = r.deploymentStateService.Save(deploymentState)
+	if err != nil {
+		return bosherr.WrapError(err, "Saving new config")
+	}
+	return nil
+}
+
+func (r checkpointRepo) CompiledPackageIndex(vmCID string) biindex.Index {
+	return compiledPackageCheckpointIndex{
+		deploymentStateService: r.deploymentStateService,
+		vmCID:                  vmCID,
+		lock:                   r.compiledPackagesLock,
+	}
+}
+
+type compiledPackageCheckpointIndex struct {
+	deploymentStateService DeploymentStateService
+	vmCID                  string
+	lock                   *sync.Mutex
+}
+
+func (i compiledPackageCheckpointIndex) Find(key interface{}, value interface{}) error {
+	keyBytes, err := json.Marshal(key)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Marshalling key %#v", key)
+	}
+
+	deploymentState, err := i.deploymentStateService.Load()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading existing config")
+	}
+
+	if deploymentState.Checkpoint == nil {
+		return biindex.ErrNotFound
+	}
+
+	for _, record := range deploymentState.Checkpoint.CompiledPackages {
+		if record.VMCID == i.vmCID && i.sameKey(record.Key, keyBytes) {
+			err = json.Unmarshal(record.Value, value)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Unmarshaling value for key %#v", key)
+			}
+
+			return nil
+		}
+	}
+
+	return biindex.ErrNotFound
+}
+
+func (i compiledPackageCheckpointIndex) Save(key interface{}, value interface{}) error {
+	keyBytes, err := json.Marshal(key)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Marshalling key %#v", key)
+	}
+
+	valueBytes, err := json.Marshal(value)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Marshalling value %#v", value)
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	deploymentState, err := i.deploymentStateService.Load()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading existing config")
+	}
+
+	if deploymentState.Checkpoint == nil {
+		return bosherr.Error("Saving compiled package without a checkpoint")
+	}
+
+	records := []CompiledPackageCheckpointRecord{}
+	for _, record := range deploymentState.Checkpoint.CompiledPackages {
+		if record.VMCID != i.vmCID || !i.sameKey(record.Key, keyBytes) {
+			records = append(records, record)
+		}
+	}
+
+	deploymentState.Checkpoint.CompiledPackages = append(records, CompiledPackageCheckpointRecord{
+		VMCID: i.vmCID,
+		Key:   keyBytes,
+		Value: valueBytes,
+	})
+
+	err = i.deploymentStateService.Save(deploymentState)
+	if err != nil {
+		return bosherr.WrapError(err, "Saving new config")
+	}
+	return nil
+}
+
+// sameKey compares the keys without their whitespace, as the deployment state
+// is saved indented.
+func (i compiledPackageCheckpointIndex) sameKey(recordKey json.RawMessage, keyBytes []byte) bool {
+	var compactKey bytes.Buffer
+
+	err := json.Compact(&compactKey, recordKey)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(compactKey.Bytes(), keyBytes)
+}
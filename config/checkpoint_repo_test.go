@@ -0,0 +1,210 @@
+package config_test
+
+import (
+	. "github.com/cloudfoundry/bosh-cli/config"
+	biindex "github.com/cloudfoundry/bosh-cli/index"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CheckpointRepo", func() {
+	var (
+		repo                   CheckpointRepo
+		deploymentStateService DeploymentStateService
+		fs                     *fakesys.FakeFileSystem
+		fakeUUIDGenerator      *fakeuuid.FakeGenerator
+	)
+
+	BeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		fs = fakesys.NewFakeFileSystem()
+		fakeUUIDGenerator = &fakeuuid.FakeGenerator{}
+		deploymentStateService = NewFileSystemDeploymentStateService(fs, fakeUUIDGenerator, logger, "/fake/path")
+		repo = NewCheckpointRepo(deploymentStateService)
+	})
+
+	Describe("IsComplete", func() {
+		It("returns false when no checkpoint has begun", func() {
+			complete, err := repo.IsComplete("fake-stage")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(complete).To(BeFalse())
+		})
+
+		It("returns true once the stage has been completed", func() {
+			err := repo.Begin("fake-manifest-sha", "fake-stemcell-cid")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = repo.Complete("fake-stage")
+			Expect(err).ToNot(HaveOccurred())
+
+			complete, err := repo.IsComplete("fake-stage")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(complete).To(BeTrue())
+
+			complete, err = repo.IsComplete("fake-other-stage")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(complete).To(BeFalse())
+		})
+	})
+
+	Describe("Begin", func() {
+		BeforeEach(func() {
+			err := repo.Begin("fake-manifest-sha", "fake-stemcell-cid")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = repo.Complete("fake-stage")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("keeps completed stages when deploying the same manifest and stemcell", func() {
+			err := repo.Begin("fake-manifest-sha", "fake-stemcell-cid")
+			Expect(err).ToNot(HaveOccurred())
+
+			deploymentState, err := deploymentStateService.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentState.Checkpoint).To(Equal(&CheckpointRecord{
+				ManifestSHA: "fake-manifest-sha",
+				StemcellCID: "fake-stemcell-cid",
+				Stages:      []string{"fake-stage"},
+			}))
+		})
+
+		It("discards completed stages when the manifest has changed", func() {
+			err := repo.Begin("fake-other-manifest-sha", "fake-stemcell-cid")
+			Expect(err).ToNot(HaveOccurred())
+
+			complete, err := repo.IsComplete("fake-stage")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(complete).To(BeFalse())
+		})
+
+		It("discards completed stages when the stemcell has changed", func() {
+			err := repo.Begin("fake-manifest-sha", "fake-other-stemcell-cid")
+			Expect(err).ToNot(HaveOccurred())
+
+			complete, err := repo.IsComplete("fake-stage")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(complete).To(BeFalse())
+		})
+	})
+
+	Describe("Complete", func() {
+		It("returns an error when no checkpoint has begun", func() {
+			err := repo.Complete("fake-stage")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Completing stage 'fake-stage' without a checkpoint"))
+		})
+	})
+
+	Describe("Find", func() {
+		It("returns false when no checkpoint has begun", func() {
+			_, found, err := repo.Find()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		It("returns the checkpoint", func() {
+			err := repo.Begin("fake-manifest-sha", "fake-stemcell-cid")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = repo.Complete("fake-stage")
+			Expect(err).ToNot(HaveOccurred())
+
+			checkpoint, found, err := repo.Find()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(checkpoint.ManifestSHA).To(Equal("fake-manifest-sha"))
+			Expect(checkpoint.StemcellCID).To(Equal("fake-stemcell-cid"))
+			Expect(checkpoint.IsComplete("fake-stage")).To(BeTrue())
+		})
+	})
+
+	Describe("CompiledPackageIndex", func() {
+		type fakeKey struct {
+			Name string
+		}
+
+		type fakeValue struct {
+			BlobID string
+		}
+
+		It("returns an error when saving without a checkpoint", func() {
+			err := repo.CompiledPackageIndex("fake-vm-cid").Save(fakeKey{Name: "fake-package"}, fakeValue{BlobID: "fake-blob-id"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Saving compiled package without a checkpoint"))
+		})
+
+		Context("when a checkpoint has begun", func() {
+			BeforeEach(func() {
+				err := repo.Begin("fake-manifest-sha", "fake-stemcell-cid")
+				Expect(err).ToNot(HaveOccurred())
+
+				err = repo.CompiledPackageIndex("fake-vm-cid").Save(fakeKey{Name: "fake-package"}, fakeValue{BlobID: "fake-blob-id"})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("finds the packages saved for the VM", func() {
+				var value fakeValue
+				err := NewCheckpointRepo(deploymentStateService).CompiledPackageIndex("fake-vm-cid").Find(fakeKey{Name: "fake-package"}, &value)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(value).To(Equal(fakeValue{BlobID: "fake-blob-id"}))
+			})
+
+			It("replaces a package saved again", func() {
+				index := repo.CompiledPackageIndex("fake-vm-cid")
+				err := index.Save(fakeKey{Name: "fake-package"}, fakeValue{BlobID: "fake-other-blob-id"})
+				Expect(err).ToNot(HaveOccurred())
+
+				var value fakeValue
+				err = index.Find(fakeKey{Name: "fake-package"}, &value)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(value).To(Equal(fakeValue{BlobID: "fake-other-blob-id"}))
+
+				deploymentState, err := deploymentStateService.Load()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentState.Checkpoint.CompiledPackages).To(HaveLen(1))
+			})
+
+			It("does not find the packages of other VMs", func() {
+				var value fakeValue
+				err := repo.CompiledPackageIndex("fake-other-vm-cid").Find(fakeKey{Name: "fake-package"}, &value)
+				Expect(err).To(Equal(biindex.ErrNotFound))
+			})
+
+			It("keeps the packages when deploying the same manifest and stemcell", func() {
+				err := repo.Begin("fake-manifest-sha", "fake-stemcell-cid")
+				Expect(err).ToNot(HaveOccurred())
+
+				var value fakeValue
+				err = repo.CompiledPackageIndex("fake-vm-cid").Find(fakeKey{Name: "fake-package"}, &value)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("discards the packages when the manifest has changed", func() {
+				err := repo.Begin("fake-other-manifest-sha", "fake-stemcell-cid")
+				Expect(err).ToNot(HaveOccurred())
+
+				var value fakeValue
+				err = repo.CompiledPackageIndex("fake-vm-cid").Find(fakeKey{Name: "fake-package"}, &value)
+				Expect(err).To(Equal(biindex.ErrNotFound))
+			})
+		})
+	})
+
+	Describe("Clear", func() {
+		It("removes the checkpoint", func() {
+			err := repo.Begin("fake-manifest-sha", "fake-stemcell-cid")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = repo.Clear()
+			Expect(err).ToNot(HaveOccurred())
+
+			deploymentState, err := deploymentStateService.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentState.Checkpoint).To(BeNil())
+		})
+	})
+})
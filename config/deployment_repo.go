@@ -7,6 +7,8 @@ import (
 type DeploymentRepo interface {
 	UpdateCurrent(manifestSHA string) error
 	FindCurrent() (manifestSHA string, found bool, err error)
+	UpdateCurrentManifest(manifest []byte) error
+	FindCurrentManifest() (manifest []byte, found bool, err error)
 }
 
 type deploymentRepo struct {
@@ -47,3 +49,32 @@ func (r deploymentRepo) UpdateCurrent(manifestSHA string) error {
 	}
 	return nil
 }
+
+func (r deploymentRepo) FindCurrentManifest() ([]byte, bool, error) {
+	deploymentState, err := r.deploymentStateService.Load()
+	if err != nil {
+		return nil, false, bosherr.WrapError(err, "Loading existing config")
+	}
+
+	currentManifest := deploymentState.CurrentManifest
+	if currentManifest != "" {
+		return []byte(currentManifest), true, nil
+	}
+
+	return nil, false, nil
+}
+
+func (r deploymentRepo) UpdateCurrentManifest(manifest []byte) error {
+	deploymentState, err := r.deploymentStateService.Load()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading existing config")
+	}
+
+	deploymentState.CurrentManifest = string(manifest)
+
+	err = r.deploymentStateService.Save(deploymentState)
+	if err != nil {
+		return bosherr.WrapError(err, "Saving new config")
+	}
+	return nil
+}
@@ -64,4 +64,44 @@ var _ = Describe("DeploymentRepo", func() {
 			})
 		})
 	})
+
+	Describe("UpdateCurrentManifest", func() {
+		It("updates deployment manifest", func() {
+			err := repo.UpdateCurrentManifest([]byte("name: fake-deployment-name"))
+			Expect(err).ToNot(HaveOccurred())
+
+			deploymentState, err := deploymentStateService.Load()
+			Expect(err).ToNot(HaveOccurred())
+
+			expectedConfig := DeploymentState{
+				DirectorID:      "fake-uuid-0",
+				CurrentManifest: "name: fake-deployment-name",
+			}
+			Expect(deploymentState).To(Equal(expectedConfig))
+		})
+	})
+
+	Describe("FindCurrentManifest", func() {
+		Context("when a current manifest is set", func() {
+			BeforeEach(func() {
+				err := repo.UpdateCurrentManifest([]byte("name: fake-deployment-name"))
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns current manifest", func() {
+				manifest, found, err := repo.FindCurrentManifest()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeTrue())
+				Expect(string(manifest)).To(Equal("name: fake-deployment-name"))
+			})
+		})
+
+		Context("when a current manifest is not set", func() {
+			It("returns false", func() {
+				_, found, err := repo.FindCurrentManifest()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeFalse())
+			})
+		})
+	})
 })
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"sync"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	deploymentStateEncryption    = "aes-256-gcm"
+	deploymentStateKDF           = "pbkdf2-sha256"
+	deploymentStateKDFIterations = 100000
+	deploymentStateSaltSize      = 16
+	deploymentStateKeySize       = 32
+)
+
+// encryptedDeploymentState is the content of an encrypted deployment state
+// file. The deployment state JSON is encrypted with a key derived from a
+// passphrase, so the passphrase is all that is needed to read the file again.
+type encryptedDeploymentState struct {
+	Encryption string `json:"encryption"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+type deploymentStateCipher struct {
+	passphrase string
+}
+
+// deploymentStateKeys holds the keys derived in this process. Deriving a key
+// is slow on purpose, so it is done once per passphrase and salt however often
+// the deployment state is loaded and saved.
+var deploymentStateKeys = &deploymentStateKeyCache{
+	keys:  map[deploymentStateKeyID][]byte{},
+	salts: map[string][]byte{},
+}
+
+type deploymentStateKeyID struct {
+	passphrase string
+	salt       string
+	iterations int
+}
+
+type deploymentStateKeyCache struct {
+	keys map[deploymentStateKeyID][]byte
+
+	// salts holds the salt to encrypt with by passphrase, the one of the
+	// deployment state last loaded or saved with it
+	salts map[string][]byte
+
+	lock sync.Mutex
+}
+
+// Key returns the key derived from the passphrase with the salt, deriving it
+// only the first time.
+func (c *deploymentStateKeyCache) Key(passphrase string, salt []byte, iterations int) []byte {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	id := deploymentStateKeyID{passphrase: passphrase, salt: string(salt), iterations: iterations}
+
+	key, found := c.keys[id]
+	if !found {
+		key = pbkdf2.Key([]byte(passphrase), salt, iterations, deploymentStateKeySize, sha256.New)
+		c.keys[id] = key
+	}
+
+	if iterations == deploymentStateKDFIterations {
+		c.salts[passphrase] = salt
+	}
+
+	return key
+}
+
+// Salt returns the salt to encrypt with the passphrase, which is generated
+// when nothing was loaded or saved with the passphrase yet.
+func (c *deploymentStateKeyCache) Salt(passphrase string) ([]byte, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if salt, found := c.salts[passphrase]; found {
+		return salt, nil
+	}
+
+	salt := make([]byte, deploymentStateSaltSize)
+
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating salt")
+	}
+
+	c.salts[passphrase] = salt
+
+	return salt, nil
+}
+
+// isEncryptedDeploymentState tells apart encrypted from plain deployment
+// state files; a plain deployment state never has an encryption key.
+func isEncryptedDeploymentState(contents []byte) bool {
+	var encryptedState encryptedDeploymentState
+
+	err := json.Unmarshal(contents, &encryptedState)
+	if err != nil {
+		return false
+	}
+
+	return encryptedState.Encryption != ""
+}
+
+// Encrypt encrypts the deployment state with a key derived from the
+// passphrase. The salt of the deployment state last loaded or saved with the
+// passphrase is reused, so that the key is derived once per process; every
+// encryption still has a nonce of its own.
+func (c deploymentStateCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	salt, err := deploymentStateKeys.Salt(c.passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := c.gcm(salt, deploymentStateKDFIterations)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating nonce")
+	}
+
+	encryptedState := encryptedDeploymentState{
+		Encryption: deploymentStateEncryption,
+		KDF:        deploymentStateKDF,
+		Iterations: deploymentStateKDFIterations,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, nil),
+	}
+
+	contents, err := json.MarshalIndent(encryptedState, "", "    ")
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Marshalling encrypted deployment state into JSON")
+	}
+
+	return contents, nil
+}
+
+func (c deploymentStateCipher) Decrypt(contents []byte) ([]byte, error) {
+	var encryptedState encryptedDeploymentState
+
+	err := json.Unmarshal(contents, &encryptedState)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshalling encrypted deployment state")
+	}
+
+	if encryptedState.Encryption != deploymentStateEncryption {
+		return nil, bosherr.Errorf("Unsupported deployment state encryption '%s'", encryptedState.Encryption)
+	}
+
+	if encryptedState.KDF != deploymentStateKDF {
+		return nil, bosherr.Errorf("Unsupported deployment state key derivation '%s'", encryptedState.KDF)
+	}
+
+	if encryptedState.Iterations < 1 {
+		return nil, bosherr.Errorf("Invalid deployment state key derivation iterations '%d'", encryptedState.Iterations)
+	}
+
+	gcm, err := c.gcm(encryptedState.Salt, encryptedState.Iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(encryptedState.Nonce) != gcm.NonceSize() {
+		return nil, bosherr.Errorf("Invalid deployment state nonce size '%d'", len(encryptedState.Nonce))
+	}
+
+	plaintext, err := gcm.Open(nil, encryptedState.Nonce, encryptedState.Ciphertext, nil)
+	if err != nil {
+		return nil, bosherr.Error("Wrong passphrase or corrupted deployment state")
+	}
+
+	return plaintext, nil
+}
+
+func (c deploymentStateCipher) gcm(salt []byte, iterations int) (cipher.AEAD, error) {
+	key := deploymentStateKeys.Key(c.passphrase, salt, iterations)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Creating AES cipher")
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Creating GCM cipher")
+	}
+
+	return gcm, nil
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// deploymentStateMigration upgrades the deployment state JSON from the
+// previous schema version to Version. Migrations work on the unmarshalled
+// JSON so that they can handle keys unknown to DeploymentState.
+type deploymentStateMigration struct {
+	Version     int
+	Description string
+	Migrate     func(state map[string]interface{}) error
+}
+
+// DeploymentStateSchemaVersion is the schema version of the deployment state
+// written by this CLI. Deployment state files written before versioning have
+// schema version 0, which did not differ from version 1.
+const DeploymentStateSchemaVersion = 1
+
+// deploymentStateMigrations are applied in order to deployment state files
+// with an older schema version. Append a migration, and bump
+// DeploymentStateSchemaVersion to its Version, for every change to the
+// deployment state that existing files cannot be unmarshalled with.
+var deploymentStateMigrations = []deploymentStateMigration{}
+
+// versionedDeploymentState is the content of a deployment state file
+type versionedDeploymentState struct {
+	SchemaVersion int `json:"schema_version"`
+	DeploymentState
+}
+
+func deploymentStateSchemaVersion(contents []byte) (int, error) {
+	var versioned struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+
+	err := json.Unmarshal(contents, &versioned)
+	if err != nil {
+		return 0, bosherr.WrapError(err, "Unmarshalling deployment state schema version")
+	}
+
+	return versioned.SchemaVersion, nil
+}
+
+// migrateDeploymentState upgrades the deployment state JSON from the given
+// schema version to DeploymentStateSchemaVersion. It reports whether any
+// migration changed the deployment state.
+func migrateDeploymentState(contents []byte, fromVersion int) ([]byte, bool, error) {
+	var state, original map[string]interface{}
+
+	err := json.Unmarshal(contents, &state)
+	if err != nil {
+		return nil, false, bosherr.WrapError(err, "Unmarshalling deployment state")
+	}
+
+	err = json.Unmarshal(contents, &original)
+	if err != nil {
+		return nil, false, bosherr.WrapError(err, "Unmarshalling deployment state")
+	}
+
+	for _, migration := range deploymentStateMigrations {
+		if migration.Version <= fromVersion {
+			continue
+		}
+
+		err = migration.Migrate(state)
+		if err != nil {
+			return nil, false, bosherr.WrapErrorf(err, "Migrating deployment state to schema version %d (%s)", migration.Version, migration.Description)
+		}
+	}
+
+	if reflect.DeepEqual(state, original) {
+		return contents, false, nil
+	}
+
+	state["schema_version"] = DeploymentStateSchemaVersion
+
+	migratedContents, err := json.Marshal(state)
+	if err != nil {
+		return nil, false, bosherr.WrapError(err, "Marshalling migrated deployment state")
+	}
+
+	return migratedContents, true, nil
+}
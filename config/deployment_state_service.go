@@ -1,20 +1,39 @@
 package config
 
 import (
+	"encoding/json"
+
 	biproperty "github.com/cloudfoundry/bosh-utils/property"
 )
 
 type DeploymentState struct {
-	DirectorID         string           `json:"director_id"`
-	InstallationID     string           `json:"installation_id"`
-	CurrentVMCID       string           `json:"current_vm_cid"`
-	CurrentStemcellID  string           `json:"current_stemcell_id"`
-	CurrentDiskID      string           `json:"current_disk_id"`
-	CurrentReleaseIDs  []string         `json:"current_release_ids"`
-	CurrentManifestSHA string           `json:"current_manifest_sha"`
-	Disks              []DiskRecord     `json:"disks"`
-	Stemcells          []StemcellRecord `json:"stemcells"`
-	Releases           []ReleaseRecord  `json:"releases"`
+	DirectorID         string            `json:"director_id"`
+	InstallationID     string            `json:"installation_id"`
+	CurrentVMCID       string            `json:"current_vm_cid"`
+	CurrentAgentID     string            `json:"current_agent_id,omitempty"`
+	CurrentStemcellID  string            `json:"current_stemcell_id"`
+	CurrentDiskID      string            `json:"current_disk_id"`
+	CurrentReleaseIDs  []string          `json:"current_release_ids"`
+	CurrentManifestSHA string            `json:"current_manifest_sha"`
+	CurrentManifest    string            `json:"current_manifest,omitempty"`
+	Disks              []DiskRecord      `json:"disks"`
+	Stemcells          []StemcellRecord  `json:"stemcells"`
+	Releases           []ReleaseRecord   `json:"releases"`
+	Checkpoint         *CheckpointRecord `json:"checkpoint,omitempty"`
+	Instances          []InstanceRecord  `json:"instances,omitempty"`
+
+	// CurrentNamedDiskIDs are the IDs of the disks attached in addition to
+	// the current disk, by disk name.
+	CurrentNamedDiskIDs map[string]string `json:"current_named_disk_ids,omitempty"`
+
+	// CurrentCPI is the name of the CPI from the cpis section that created
+	// the current VM, empty for the cloud_provider CPI.
+	CurrentCPI string `json:"current_cpi,omitempty"`
+
+	// ReplacedVMCID is the VM being replaced by the current VM, which is kept
+	// until the current VM is ready and the replaced VM is deleted.
+	ReplacedVMCID   string `json:"replaced_vm_cid,omitempty"`
+	ReplacedAgentID string `json:"replaced_agent_id,omitempty"`
 }
 
 type StemcellRecord struct {
@@ -22,6 +41,9 @@ type StemcellRecord struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
 	CID     string `json:"cid"`
+	// APIVersion is the api_version of the stemcell, which delete-env
+	// sends to the CPI without having the stemcell at hand.
+	APIVersion int `json:"api_version,omitempty"`
 }
 
 type DiskRecord struct {
@@ -29,6 +51,25 @@ type DiskRecord struct {
 	CID             string         `json:"cid"`
 	Size            int            `json:"size"`
 	CloudProperties biproperty.Map `json:"cloud_properties"`
+
+	// Orphaned disks are no longer attached to any instance but are kept
+	// until they are deleted with delete-disk-env or delete-env.
+	Orphaned bool `json:"orphaned,omitempty"`
+}
+
+// InstanceRecord holds the VM and disk of an instance other than the first.
+// The VM and disk of the first instance are the current VM and disk.
+type InstanceRecord struct {
+	Index   int    `json:"index"`
+	IP      string `json:"ip"`
+	VMCID   string `json:"vm_cid,omitempty"`
+	AgentID string `json:"agent_id,omitempty"`
+	DiskID  string `json:"disk_id,omitempty"`
+
+	NamedDiskIDs map[string]string `json:"named_disk_ids,omitempty"`
+
+	ReplacedVMCID   string `json:"replaced_vm_cid,omitempty"`
+	ReplacedAgentID string `json:"replaced_agent_id,omitempty"`
 }
 
 type ReleaseRecord struct {
@@ -37,10 +78,49 @@ type ReleaseRecord struct {
 	Version string `json:"version"`
 }
 
+// CheckpointRecord holds the stages completed by a deploy that has not
+// finished, so that deploying the same manifest and stemcell again resumes
+// after them.
+type CheckpointRecord struct {
+	ManifestSHA string   `json:"manifest_sha"`
+	StemcellCID string   `json:"stemcell_cid"`
+	Stages      []string `json:"stages"`
+
+	// CompiledPackages are the packages compiled on the VMs of the deploy, so
+	// that they are not compiled again when it resumes.
+	CompiledPackages []CompiledPackageCheckpointRecord `json:"compiled_packages,omitempty"`
+}
+
+// IsComplete reports whether the stage was completed.
+func (r CheckpointRecord) IsComplete(stage string) bool {
+	for _, completedStage := range r.Stages {
+		if completedStage == stage {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CompiledPackageCheckpointRecord is a package compiled on a VM. Its blob is
+// in the blobstore of that VM, so it is only reused on the same VM.
+type CompiledPackageCheckpointRecord struct {
+	VMCID string          `json:"vm_cid"`
+	Key   json.RawMessage `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
 type DeploymentStateService interface {
 	Path() string
 	Exists() bool
 	Load() (DeploymentState, error)
 	Save(DeploymentState) error
 	Cleanup() error
+
+	// Lock fails when another operation holds the lock on the deployment
+	// state, so that concurrent operations do not overwrite each other's
+	// changes. The lock is advisory: only operations that take it are kept
+	// apart.
+	Lock() error
+	Unlock() error
 }
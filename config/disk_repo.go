@@ -9,22 +9,43 @@ import (
 type DiskRepo interface {
 	UpdateCurrent(diskID string) error
 	FindCurrent() (DiskRecord, bool, error)
+	// FindAllCurrent returns the current disks of every instance
+	FindAllCurrent() ([]DiskRecord, error)
 	ClearCurrent() error
+	// FindCurrentNamed returns the named disks attached in addition to the
+	// current disk, by name
+	FindCurrentNamed() (map[string]DiskRecord, error)
+	UpdateCurrentNamed(name string, diskID string) error
+	ClearCurrentNamed(name string) error
 	Save(cid string, size int, cloudProperties biproperty.Map) (DiskRecord, error)
+	// Update records the size and cloud properties of an existing disk
+	Update(cid string, size int, cloudProperties biproperty.Map) error
 	Find(cid string) (DiskRecord, bool, error)
 	All() ([]DiskRecord, error)
 	Delete(DiskRecord) error
+	// Orphan keeps the record of a disk that is no longer in use, so that it
+	// can be attached again or deleted later
+	Orphan(DiskRecord) error
+	FindOrphaned() ([]DiskRecord, error)
 }
 
 type diskRepo struct {
 	deploymentStateService DeploymentStateService
 	uuidGenerator          boshuuid.Generator
+	index                  int
 }
 
 func NewDiskRepo(deploymentStateService DeploymentStateService, uuidGenerator boshuuid.Generator) DiskRepo {
+	return NewInstanceDiskRepo(deploymentStateService, uuidGenerator, 0)
+}
+
+// NewInstanceDiskRepo returns a repo of disks whose current disk is the one
+// of the instance with the given index.
+func NewInstanceDiskRepo(deploymentStateService DeploymentStateService, uuidGenerator boshuuid.Generator, index int) DiskRepo {
 	return diskRepo{
 		deploymentStateService: deploymentStateService,
 		uuidGenerator:          uuidGenerator,
+		index:                  index,
 	}
 }
 
@@ -59,13 +80,40 @@ func (r diskRepo) Save(cid string, size int, cloudProperties biproperty.Map) (Di
 	return newRecord, nil
 }
 
+func (r diskRepo) Update(cid string, size int, cloudProperties biproperty.Map) error {
+	config, records, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i, record := range records {
+		if record.CID == cid {
+			found = true
+			records[i].Size = size
+			records[i].CloudProperties = cloudProperties
+		}
+	}
+	if !found {
+		return bosherr.Errorf("Failed to update disk cid '%s', no record found", cid)
+	}
+
+	config.Disks = records
+
+	err = r.deploymentStateService.Save(config)
+	if err != nil {
+		return bosherr.WrapError(err, "Saving new config")
+	}
+	return nil
+}
+
 func (r diskRepo) FindCurrent() (DiskRecord, bool, error) {
 	deploymentState, err := r.deploymentStateService.Load()
 	if err != nil {
 		return DiskRecord{}, false, bosherr.WrapError(err, "Loading existing config")
 	}
 
-	currentDiskID := deploymentState.CurrentDiskID
+	currentDiskID := r.currentDiskID(deploymentState)
 	if currentDiskID == "" {
 		return DiskRecord{}, false, nil
 	}
@@ -86,16 +134,18 @@ func (r diskRepo) UpdateCurrent(diskID string) error {
 	}
 
 	found := false
-	for _, oldRecord := range deploymentState.Disks {
+	for i, oldRecord := range deploymentState.Disks {
 		if oldRecord.ID == diskID {
 			found = true
+			// a disk in use is no longer orphaned
+			deploymentState.Disks[i].Orphaned = false
 		}
 	}
 	if !found {
 		return bosherr.Errorf("Verifying disk record exists with id '%s'", diskID)
 	}
 
-	deploymentState.CurrentDiskID = diskID
+	r.setCurrentDiskID(&deploymentState, diskID)
 
 	err = r.deploymentStateService.Save(deploymentState)
 	if err != nil {
@@ -123,6 +173,33 @@ func (r diskRepo) All() ([]DiskRecord, error) {
 	return deploymentState.Disks, nil
 }
 
+func (r diskRepo) FindAllCurrent() ([]DiskRecord, error) {
+	deploymentState, err := r.deploymentStateService.Load()
+	if err != nil {
+		return []DiskRecord{}, bosherr.WrapError(err, "Loading existing config")
+	}
+
+	currentDiskIDs := map[string]bool{deploymentState.CurrentDiskID: true}
+	for _, diskID := range deploymentState.CurrentNamedDiskIDs {
+		currentDiskIDs[diskID] = true
+	}
+	for _, instance := range deploymentState.Instances {
+		currentDiskIDs[instance.DiskID] = true
+		for _, diskID := range instance.NamedDiskIDs {
+			currentDiskIDs[diskID] = true
+		}
+	}
+
+	records := []DiskRecord{}
+	for _, record := range deploymentState.Disks {
+		if currentDiskIDs[record.ID] {
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
+
 func (r diskRepo) Delete(diskRecord DiskRecord) error {
 	config, records, err := r.load()
 	if err != nil {
@@ -137,11 +214,36 @@ func (r diskRepo) Delete(diskRecord DiskRecord) error {
 	}
 
 	config.Disks = newRecords
+	clearDiskID(&config, diskRecord.ID)
 
-	if config.CurrentDiskID == diskRecord.ID {
-		config.CurrentDiskID = ""
+	err = r.deploymentStateService.Save(config)
+	if err != nil {
+		return bosherr.WrapError(err, "Saving new config")
+	}
+
+	return nil
+}
+
+func (r diskRepo) Orphan(diskRecord DiskRecord) error {
+	config, records, err := r.load()
+	if err != nil {
+		return err
 	}
 
+	found := false
+	for i, record := range records {
+		if record.ID == diskRecord.ID {
+			found = true
+			records[i].Orphaned = true
+		}
+	}
+	if !found {
+		return bosherr.Errorf("Verifying disk record exists with id '%s'", diskRecord.ID)
+	}
+
+	config.Disks = records
+	clearDiskID(&config, diskRecord.ID)
+
 	err = r.deploymentStateService.Save(config)
 	if err != nil {
 		return bosherr.WrapError(err, "Saving new config")
@@ -150,13 +252,115 @@ func (r diskRepo) Delete(diskRecord DiskRecord) error {
 	return nil
 }
 
+func (r diskRepo) FindOrphaned() ([]DiskRecord, error) {
+	_, records, err := r.load()
+	if err != nil {
+		return []DiskRecord{}, err
+	}
+
+	orphanedRecords := []DiskRecord{}
+	for _, record := range records {
+		if record.Orphaned {
+			orphanedRecords = append(orphanedRecords, record)
+		}
+	}
+
+	return orphanedRecords, nil
+}
+
+// clearDiskID removes the disk from the current disks of every instance.
+func clearDiskID(config *DeploymentState, diskID string) {
+	if config.CurrentDiskID == diskID {
+		config.CurrentDiskID = ""
+	}
+	deleteNamedDiskID(config.CurrentNamedDiskIDs, diskID)
+
+	for i := range config.Instances {
+		if config.Instances[i].DiskID == diskID {
+			config.Instances[i].DiskID = ""
+		}
+		deleteNamedDiskID(config.Instances[i].NamedDiskIDs, diskID)
+	}
+}
+
 func (r diskRepo) ClearCurrent() error {
 	deploymentState, err := r.deploymentStateService.Load()
 	if err != nil {
 		return bosherr.WrapError(err, "Loading existing config")
 	}
 
-	deploymentState.CurrentDiskID = ""
+	r.setCurrentDiskID(&deploymentState, "")
+
+	err = r.deploymentStateService.Save(deploymentState)
+	if err != nil {
+		return bosherr.WrapError(err, "Saving new config")
+	}
+	return nil
+}
+
+func (r diskRepo) FindCurrentNamed() (map[string]DiskRecord, error) {
+	records := map[string]DiskRecord{}
+
+	deploymentState, err := r.deploymentStateService.Load()
+	if err != nil {
+		return records, bosherr.WrapError(err, "Loading existing config")
+	}
+
+	for name, diskID := range r.currentNamedDiskIDs(deploymentState) {
+		for _, record := range deploymentState.Disks {
+			if record.ID == diskID {
+				records[name] = record
+			}
+		}
+	}
+
+	return records, nil
+}
+
+func (r diskRepo) UpdateCurrentNamed(name string, diskID string) error {
+	deploymentState, err := r.deploymentStateService.Load()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading existing config")
+	}
+
+	found := false
+	for i, oldRecord := range deploymentState.Disks {
+		if oldRecord.ID == diskID {
+			found = true
+			// a disk in use is no longer orphaned
+			deploymentState.Disks[i].Orphaned = false
+		}
+	}
+	if !found {
+		return bosherr.Errorf("Verifying disk record exists with id '%s'", diskID)
+	}
+
+	namedDiskIDs := r.currentNamedDiskIDs(deploymentState)
+	if namedDiskIDs == nil {
+		namedDiskIDs = map[string]string{}
+	}
+	namedDiskIDs[name] = diskID
+	r.setCurrentNamedDiskIDs(&deploymentState, namedDiskIDs)
+
+	err = r.deploymentStateService.Save(deploymentState)
+	if err != nil {
+		return bosherr.WrapError(err, "Saving new config")
+	}
+	return nil
+}
+
+func (r diskRepo) ClearCurrentNamed(name string) error {
+	deploymentState, err := r.deploymentStateService.Load()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading existing config")
+	}
+
+	namedDiskIDs := r.currentNamedDiskIDs(deploymentState)
+	delete(namedDiskIDs, name)
+	if len(namedDiskIDs) == 0 {
+		namedDiskIDs = nil
+	}
+	r.setCurrentNamedDiskIDs(&deploymentState, namedDiskIDs)
 
 	err = r.deploymentStateService.Save(deploymentState)
 	if err != nil {
@@ -165,6 +369,48 @@ func (r diskRepo) ClearCurrent() error {
 	return nil
 }
 
+func (r diskRepo) currentNamedDiskIDs(deploymentState DeploymentState) map[string]string {
+	if r.index > 0 {
+		record, _ := findInstanceRecord(deploymentState, r.index)
+		return record.NamedDiskIDs
+	}
+
+	return deploymentState.CurrentNamedDiskIDs
+}
+
+func (r diskRepo) setCurrentNamedDiskIDs(deploymentState *DeploymentState, namedDiskIDs map[string]string) {
+	if r.index > 0 {
+		instanceRecord(deploymentState, r.index).NamedDiskIDs = namedDiskIDs
+	} else {
+		deploymentState.CurrentNamedDiskIDs = namedDiskIDs
+	}
+}
+
+func deleteNamedDiskID(namedDiskIDs map[string]string, diskID string) {
+	for name, namedDiskID := range namedDiskIDs {
+		if namedDiskID == diskID {
+			delete(namedDiskIDs, name)
+		}
+	}
+}
+
+func (r diskRepo) currentDiskID(deploymentState DeploymentState) string {
+	if r.index > 0 {
+		record, _ := findInstanceRecord(deploymentState, r.index)
+		return record.DiskID
+	}
+
+	return deploymentState.CurrentDiskID
+}
+
+func (r diskRepo) setCurrentDiskID(deploymentState *DeploymentState, diskID string) {
+	if r.index > 0 {
+		instanceRecord(deploymentState, r.index).DiskID = diskID
+	} else {
+		deploymentState.CurrentDiskID = diskID
+	}
+}
+
 func (r diskRepo) load() (DeploymentState, []DiskRecord, error) {
 	deploymentState, err := r.deploymentStateService.Load()
 	if err != nil {
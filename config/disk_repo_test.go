@@ -60,6 +60,33 @@ var _ = Describe("DiskRepo", func() {
 		})
 	})
 
+	Describe("Update", func() {
+		It("updates the size and cloud properties of the disk record", func() {
+			savedRecord, err := repo.Save("fake-cid", 1024, cloudProperties)
+			Expect(err).ToNot(HaveOccurred())
+
+			newCloudProperties := biproperty.Map{"fake-new-cloud-property-key": "fake-new-cloud-property-value"}
+			err = repo.Update("fake-cid", 2048, newCloudProperties)
+			Expect(err).ToNot(HaveOccurred())
+
+			foundRecord, found, err := repo.Find("fake-cid")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(foundRecord).To(Equal(DiskRecord{
+				ID:              savedRecord.ID,
+				CID:             "fake-cid",
+				Size:            2048,
+				CloudProperties: newCloudProperties,
+			}))
+		})
+
+		It("returns an error when the disk is not in the records", func() {
+			err := repo.Update("fake-cid", 2048, cloudProperties)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("no record found"))
+		})
+	})
+
 	Describe("Find", func() {
 		It("finds existing disk records", func() {
 			savedRecord, err := repo.Save("fake-cid", 1024, cloudProperties)
@@ -244,6 +271,69 @@ var _ = Describe("DiskRepo", func() {
 		})
 	})
 
+	Describe("Orphan", func() {
+		var (
+			firstDisk  DiskRecord
+			secondDisk DiskRecord
+		)
+
+		BeforeEach(func() {
+			var err error
+
+			firstDisk, err = repo.Save("fake-cid-1", 1024, cloudProperties)
+			Expect(err).ToNot(HaveOccurred())
+
+			secondDisk, err = repo.Save("fake-cid-2", 2048, cloudProperties)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = repo.UpdateCurrent(firstDisk.ID)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("keeps the disk record, marked as orphaned, and clears the current disk", func() {
+			err := repo.Orphan(firstDisk)
+			Expect(err).ToNot(HaveOccurred())
+
+			orphanedDisk := firstDisk
+			orphanedDisk.Orphaned = true
+
+			disks, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(disks).To(Equal([]DiskRecord{orphanedDisk, secondDisk}))
+
+			orphanedDisks, err := repo.FindOrphaned()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(orphanedDisks).To(Equal([]DiskRecord{orphanedDisk}))
+
+			_, found, err := repo.FindCurrent()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		It("no longer marks the disk as orphaned once it is current again", func() {
+			err := repo.Orphan(firstDisk)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = repo.UpdateCurrent(firstDisk.ID)
+			Expect(err).ToNot(HaveOccurred())
+
+			orphanedDisks, err := repo.FindOrphaned()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(orphanedDisks).To(BeEmpty())
+
+			currentDisk, found, err := repo.FindCurrent()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(currentDisk).To(Equal(firstDisk))
+		})
+
+		It("returns an error when the disk has no record", func() {
+			err := repo.Orphan(DiskRecord{ID: "fake-unknown-id"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Verifying disk record exists with id 'fake-unknown-id'"))
+		})
+	})
+
 	Describe("ClearCurrent", func() {
 		It("updates disk cid", func() {
 			err := repo.ClearCurrent()
@@ -263,4 +353,107 @@ var _ = Describe("DiskRepo", func() {
 			Expect(found).To(BeFalse())
 		})
 	})
+
+	Describe("FindAllCurrent", func() {
+		It("returns the current disks of every instance", func() {
+			firstDisk, err := repo.Save("fake-cid-1", 1024, cloudProperties)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = repo.Save("fake-cid-2", 1024, cloudProperties)
+			Expect(err).ToNot(HaveOccurred())
+			thirdDisk, err := repo.Save("fake-cid-3", 1024, cloudProperties)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = repo.UpdateCurrent(firstDisk.ID)
+			Expect(err).ToNot(HaveOccurred())
+
+			instanceRepo := NewInstanceDiskRepo(deploymentStateService, fakeUUIDGenerator, 1)
+			err = instanceRepo.UpdateCurrent(thirdDisk.ID)
+			Expect(err).ToNot(HaveOccurred())
+
+			disks, err := repo.FindAllCurrent()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(disks).To(Equal([]DiskRecord{firstDisk, thirdDisk}))
+
+			currentDisk, found, err := instanceRepo.FindCurrent()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(currentDisk).To(Equal(thirdDisk))
+		})
+	})
+
+	Describe("named disks", func() {
+		var (
+			firstDisk  DiskRecord
+			secondDisk DiskRecord
+		)
+
+		BeforeEach(func() {
+			var err error
+
+			firstDisk, err = repo.Save("fake-cid-1", 1024, cloudProperties)
+			Expect(err).ToNot(HaveOccurred())
+
+			secondDisk, err = repo.Save("fake-cid-2", 2048, cloudProperties)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = repo.UpdateCurrent(firstDisk.ID)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = repo.UpdateCurrentNamed("fake-disk-name", secondDisk.ID)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("finds the current named disks by name", func() {
+			disks, err := repo.FindCurrentNamed()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(disks).To(Equal(map[string]DiskRecord{"fake-disk-name": secondDisk}))
+
+			currentDisk, found, err := repo.FindCurrent()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(currentDisk).To(Equal(firstDisk))
+		})
+
+		It("includes the named disks in the current disks", func() {
+			disks, err := repo.FindAllCurrent()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(disks).To(Equal([]DiskRecord{firstDisk, secondDisk}))
+		})
+
+		It("keeps the named disks of other instances apart", func() {
+			instanceRepo := NewInstanceDiskRepo(deploymentStateService, fakeUUIDGenerator, 1)
+
+			disks, err := instanceRepo.FindCurrentNamed()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(disks).To(BeEmpty())
+		})
+
+		It("returns an error when updating to a disk without a record", func() {
+			err := repo.UpdateCurrentNamed("fake-disk-name", "fake-unknown-id")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Verifying disk record exists with id 'fake-unknown-id'"))
+		})
+
+		It("clears a current named disk", func() {
+			err := repo.ClearCurrentNamed("fake-disk-name")
+			Expect(err).ToNot(HaveOccurred())
+
+			disks, err := repo.FindCurrentNamed()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(disks).To(BeEmpty())
+
+			deploymentState, err := deploymentStateService.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentState.CurrentNamedDiskIDs).To(BeNil())
+		})
+
+		It("clears the named disk when deleting its record", func() {
+			err := repo.Delete(secondDisk)
+			Expect(err).ToNot(HaveOccurred())
+
+			disks, err := repo.FindCurrentNamed()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(disks).To(BeEmpty())
+		})
+	})
 })
@@ -4,7 +4,11 @@ type FakeDeploymentRepo struct {
 	UpdateCurrentManifestSHA string
 	UpdateCurrentErr         error
 
-	findCurrentOutput deploymentRepoFindCurrentOutput
+	UpdateCurrentManifestContents []byte
+	UpdateCurrentManifestErr      error
+
+	findCurrentOutput         deploymentRepoFindCurrentOutput
+	findCurrentManifestOutput deploymentRepoFindCurrentManifestOutput
 }
 
 type deploymentRepoFindCurrentOutput struct {
@@ -13,6 +17,12 @@ type deploymentRepoFindCurrentOutput struct {
 	err         error
 }
 
+type deploymentRepoFindCurrentManifestOutput struct {
+	manifest []byte
+	found    bool
+	err      error
+}
+
 func NewFakeDeploymentRepo() *FakeDeploymentRepo {
 	return &FakeDeploymentRepo{}
 }
@@ -33,3 +43,20 @@ func (r *FakeDeploymentRepo) SetFindCurrentBehavior(manifestSHA string, found bo
 		err:         err,
 	}
 }
+
+func (r *FakeDeploymentRepo) UpdateCurrentManifest(manifest []byte) error {
+	r.UpdateCurrentManifestContents = manifest
+	return r.UpdateCurrentManifestErr
+}
+
+func (r *FakeDeploymentRepo) FindCurrentManifest() (manifest []byte, found bool, err error) {
+	return r.findCurrentManifestOutput.manifest, r.findCurrentManifestOutput.found, r.findCurrentManifestOutput.err
+}
+
+func (r *FakeDeploymentRepo) SetFindCurrentManifestBehavior(manifest []byte, found bool, err error) {
+	r.findCurrentManifestOutput = deploymentRepoFindCurrentManifestOutput{
+		manifest: manifest,
+		found:    found,
+		err:      err,
+	}
+}
@@ -14,12 +14,32 @@ type FakeDiskRepo struct {
 	SaveInputs []DiskRepoSaveInput
 	saveOutput diskRepoSaveOutput
 
+	UpdateInputs []DiskRepoSaveInput
+	UpdateErr    error
+
 	findOutput map[string]diskRepoFindOutput
 
 	DeleteInputs []DiskRepoDeleteInput
 	DeleteErr    error
 
 	allOutput diskRepoAllOutput
+
+	FindCurrentNamedRecords  map[string]biconfig.DiskRecord
+	FindCurrentNamedErr      error
+	UpdateCurrentNamedInputs []DiskRepoUpdateCurrentNamedInput
+	UpdateCurrentNamedErr    error
+	ClearCurrentNamedInputs  []string
+	ClearCurrentNamedErr     error
+
+	OrphanInputs        []DiskRepoDeleteInput
+	OrphanErr           error
+	FindOrphanedRecords []biconfig.DiskRecord
+	FindOrphanedErr     error
+}
+
+type DiskRepoUpdateCurrentNamedInput struct {
+	Name   string
+	DiskID string
 }
 
 type DiskRepoUpdateCurrentInput struct {
@@ -64,6 +84,11 @@ func NewFakeDiskRepo() *FakeDiskRepo {
 		SaveInputs:          []DiskRepoSaveInput{},
 		DeleteInputs:        []DiskRepoDeleteInput{},
 		findOutput:          map[string]diskRepoFindOutput{},
+
+		FindCurrentNamedRecords:  map[string]biconfig.DiskRecord{},
+		UpdateCurrentNamedInputs: []DiskRepoUpdateCurrentNamedInput{},
+		ClearCurrentNamedInputs:  []string{},
+		OrphanInputs:             []DiskRepoDeleteInput{},
 	}
 }
 
@@ -78,10 +103,34 @@ func (r *FakeDiskRepo) FindCurrent() (biconfig.DiskRecord, bool, error) {
 	return r.findCurrentOutput.diskRecord, r.findCurrentOutput.found, r.findCurrentOutput.err
 }
 
+func (r *FakeDiskRepo) FindAllCurrent() ([]biconfig.DiskRecord, error) {
+	if !r.findCurrentOutput.found {
+		return []biconfig.DiskRecord{}, r.findCurrentOutput.err
+	}
+	return []biconfig.DiskRecord{r.findCurrentOutput.diskRecord}, r.findCurrentOutput.err
+}
+
 func (r *FakeDiskRepo) ClearCurrent() error {
 	return nil
 }
 
+func (r *FakeDiskRepo) FindCurrentNamed() (map[string]biconfig.DiskRecord, error) {
+	return r.FindCurrentNamedRecords, r.FindCurrentNamedErr
+}
+
+func (r *FakeDiskRepo) UpdateCurrentNamed(name string, diskID string) error {
+	r.UpdateCurrentNamedInputs = append(r.UpdateCurrentNamedInputs, DiskRepoUpdateCurrentNamedInput{
+		Name:   name,
+		DiskID: diskID,
+	})
+	return r.UpdateCurrentNamedErr
+}
+
+func (r *FakeDiskRepo) ClearCurrentNamed(name string) error {
+	r.ClearCurrentNamedInputs = append(r.ClearCurrentNamedInputs, name)
+	return r.ClearCurrentNamedErr
+}
+
 func (r *FakeDiskRepo) Save(cid string, size int, cloudProperties biproperty.Map) (biconfig.DiskRecord, error) {
 	r.SaveInputs = append(r.SaveInputs, DiskRepoSaveInput{
 		CID:             cid,
@@ -92,6 +141,16 @@ func (r *FakeDiskRepo) Save(cid string, size int, cloudProperties biproperty.Map
 	return r.saveOutput.diskRecord, r.saveOutput.err
 }
 
+func (r *FakeDiskRepo) Update(cid string, size int, cloudProperties biproperty.Map) error {
+	r.UpdateInputs = append(r.UpdateInputs, DiskRepoSaveInput{
+		CID:             cid,
+		Size:            size,
+		CloudProperties: cloudProperties,
+	})
+
+	return r.UpdateErr
+}
+
 func (r *FakeDiskRepo) Find(cid string) (biconfig.DiskRecord, bool, error) {
 	return r.findOutput[cid].diskRecord, r.findOutput[cid].found, r.findOutput[cid].err
 }
@@ -108,6 +167,18 @@ func (r *FakeDiskRepo) Delete(diskRecord biconfig.DiskRecord) error {
 	return r.DeleteErr
 }
 
+func (r *FakeDiskRepo) Orphan(diskRecord biconfig.DiskRecord) error {
+	r.OrphanInputs = append(r.OrphanInputs, DiskRepoDeleteInput{
+		DiskRecord: diskRecord,
+	})
+
+	return r.OrphanErr
+}
+
+func (r *FakeDiskRepo) FindOrphaned() ([]biconfig.DiskRecord, error) {
+	return r.FindOrphanedRecords, r.FindOrphanedErr
+}
+
 func (r *FakeDiskRepo) SetUpdateBehavior(err error) {
 	r.updateErr = err
 }
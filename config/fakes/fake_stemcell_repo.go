@@ -30,6 +30,11 @@ type StemcellRepoFindOutput struct {
 	err            error
 }
 
+type StemcellRepoUpdateAPIVersionInput struct {
+	RecordID   string
+	APIVersion int
+}
+
 type FindCurrentOutput struct {
 	stemcellRecord biconfig.StemcellRecord
 	found          bool
@@ -45,6 +50,9 @@ type FakeStemcellRepo struct {
 	UpdateCurrentRecordID string
 	UpdateCurrentErr      error
 
+	UpdateAPIVersionInputs []StemcellRepoUpdateAPIVersionInput
+	UpdateAPIVersionErr    error
+
 	findCurrentOutput FindCurrentOutput
 
 	ClearCurrentCalled bool
@@ -71,6 +79,11 @@ func (fr *FakeStemcellRepo) UpdateCurrent(recordID string) error {
 	return fr.UpdateCurrentErr
 }
 
+func (fr *FakeStemcellRepo) UpdateAPIVersion(recordID string, apiVersion int) error {
+	fr.UpdateAPIVersionInputs = append(fr.UpdateAPIVersionInputs, StemcellRepoUpdateAPIVersionInput{RecordID: recordID, APIVersion: apiVersion})
+	return fr.UpdateAPIVersionErr
+}
+
 func (fr *FakeStemcellRepo) FindCurrent() (biconfig.StemcellRecord, bool, error) {
 	return fr.findCurrentOutput.stemcellRecord, fr.findCurrentOutput.found, fr.findCurrentOutput.err
 }
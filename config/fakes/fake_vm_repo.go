@@ -7,6 +7,19 @@ type FakeVMRepo struct {
 	ClearCurrentCalled bool
 	ClearCurrentErr    error
 
+	UpdateCurrentAgentIDAgentID string
+	UpdateCurrentAgentIDErr     error
+
+	CurrentAgentID        string
+	FindCurrentAgentIDErr error
+
+	ReplacedCID         string
+	ReplacedAgentID     string
+	UpdateReplacedErr   error
+	ClearReplacedCalled bool
+	ClearReplacedErr    error
+	FindReplacedErr     error
+
 	findCurrentOutput vmRepoFindCurrentOutput
 }
 
@@ -41,3 +54,29 @@ func (r *FakeVMRepo) ClearCurrent() error {
 	r.ClearCurrentCalled = true
 	return r.ClearCurrentErr
 }
+
+func (r *FakeVMRepo) FindCurrentAgentID() (agentID string, found bool, err error) {
+	return r.CurrentAgentID, r.CurrentAgentID != "", r.FindCurrentAgentIDErr
+}
+
+func (r *FakeVMRepo) UpdateCurrentAgentID(agentID string) error {
+	r.UpdateCurrentAgentIDAgentID = agentID
+	return r.UpdateCurrentAgentIDErr
+}
+
+func (r *FakeVMRepo) FindReplaced() (cid string, agentID string, found bool, err error) {
+	return r.ReplacedCID, r.ReplacedAgentID, r.ReplacedCID != "", r.FindReplacedErr
+}
+
+func (r *FakeVMRepo) UpdateReplaced(cid string, agentID string) error {
+	r.ReplacedCID = cid
+	r.ReplacedAgentID = agentID
+	return r.UpdateReplacedErr
+}
+
+func (r *FakeVMRepo) ClearReplaced() error {
+	r.ClearReplacedCalled = true
+	r.ReplacedCID = ""
+	r.ReplacedAgentID = ""
+	return r.ClearReplacedErr
+}
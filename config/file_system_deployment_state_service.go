@@ -3,7 +3,9 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -18,6 +20,13 @@ type fileSystemDeploymentStateService struct {
 	uuidGenerator boshuuid.Generator
 	logger        boshlog.Logger
 	logTag        string
+
+	// passphrase encrypts the deployment state file when set
+	passphrase string
+
+	// askPassphrase returns the passphrase of an encrypted deployment state
+	// file loaded without one, e.g. by prompting for it
+	askPassphrase func() (string, error)
 }
 
 func NewFileSystemDeploymentStateService(fs boshsys.FileSystem, uuidGenerator boshuuid.Generator, logger boshlog.Logger, deploymentStatePath string) DeploymentStateService {
@@ -30,6 +39,23 @@ func NewFileSystemDeploymentStateService(fs boshsys.FileSystem, uuidGenerator bo
 	}
 }
 
+// NewEncryptedFileSystemDeploymentStateService returns a deployment state
+// service that encrypts the deployment state file with the passphrase. A
+// plain deployment state file is still loaded, and encrypted when it is next
+// saved. Without a passphrase, askPassphrase is called for the passphrase of
+// an encrypted deployment state file, which is then kept encrypted.
+func NewEncryptedFileSystemDeploymentStateService(fs boshsys.FileSystem, uuidGenerator boshuuid.Generator, logger boshlog.Logger, deploymentStatePath string, passphrase string, askPassphrase func() (string, error)) DeploymentStateService {
+	return &fileSystemDeploymentStateService{
+		configPath:    deploymentStatePath,
+		fs:            fs,
+		uuidGenerator: uuidGenerator,
+		logger:        logger,
+		logTag:        "config",
+		passphrase:    passphrase,
+		askPassphrase: askPassphrase,
+	}
+}
+
 func DeploymentStatePath(deploymentManifestPath string, deploymentStatePath string) string {
 	if deploymentStatePath != "" {
 		return deploymentStatePath
@@ -57,16 +83,54 @@ func (s *fileSystemDeploymentStateService) Load() (DeploymentState, error) {
 	deploymentState := &DeploymentState{}
 
 	if s.fs.FileExists(s.configPath) {
-		deploymentStateFileContents, err := s.fs.ReadFile(s.configPath)
+		fileContents, err := s.fs.ReadFile(s.configPath)
 		if err != nil {
 			return DeploymentState{}, bosherr.WrapErrorf(err, "Reading deployment state file '%s'", s.configPath)
 		}
-		s.logger.Debug(s.logTag, "Deployment File Contents %#s", deploymentStateFileContents)
+		s.logger.Debug(s.logTag, "Deployment File Contents %#s", fileContents)
+
+		deploymentStateFileContents := fileContents
+
+		if isEncryptedDeploymentState(deploymentStateFileContents) {
+			if s.passphrase == "" && s.askPassphrase != nil {
+				s.passphrase, err = s.askPassphrase()
+				if err != nil {
+					return DeploymentState{}, bosherr.WrapErrorf(err, "Asking for the passphrase of deployment state file '%s'", s.configPath)
+				}
+			}
+
+			if s.passphrase == "" {
+				return DeploymentState{}, bosherr.Errorf(
+					"Deployment state file '%s' is encrypted, provide its passphrase with --state-passphrase or BOSH_STATE_PASSPHRASE", s.configPath)
+			}
+
+			deploymentStateFileContents, err = deploymentStateCipher{passphrase: s.passphrase}.Decrypt(deploymentStateFileContents)
+			if err != nil {
+				return DeploymentState{}, bosherr.WrapErrorf(err, "Decrypting deployment state file '%s'", s.configPath)
+			}
+		}
+
+		version, err := deploymentStateSchemaVersion(deploymentStateFileContents)
+		if err != nil {
+			return DeploymentState{}, bosherr.WrapErrorf(err, "Unmarshalling deployment state file '%s'", s.configPath)
+		}
+
+		deploymentStateFileContents, migrated, err := s.migrate(fileContents, deploymentStateFileContents, version)
+		if err != nil {
+			return DeploymentState{}, bosherr.WrapErrorf(err, "Migrating deployment state file '%s'", s.configPath)
+		}
 
 		err = json.Unmarshal(deploymentStateFileContents, deploymentState)
 		if err != nil {
 			return DeploymentState{}, bosherr.WrapErrorf(err, "Unmarshalling deployment state file '%s'", s.configPath)
 		}
+
+		if migrated {
+			err = s.Save(*deploymentState)
+			if err != nil {
+				return DeploymentState{}, bosherr.WrapError(err, "Saving migrated deployment state")
+			}
+		}
 	}
 
 	err := s.initDefaults(deploymentState)
@@ -82,13 +146,27 @@ func (s *fileSystemDeploymentStateService) Save(deploymentState DeploymentState)
 		panic("configPath not yet set!")
 	}
 
-	s.logger.Debug(s.logTag, "Saving deployment state %#v", deploymentState)
+	if s.passphrase == "" {
+		s.logger.Debug(s.logTag, "Saving deployment state %#v", deploymentState)
+	} else {
+		s.logger.Debug(s.logTag, "Saving encrypted deployment state")
+	}
 
-	jsonContent, err := json.MarshalIndent(deploymentState, "", "    ")
+	jsonContent, err := json.MarshalIndent(versionedDeploymentState{
+		SchemaVersion:   DeploymentStateSchemaVersion,
+		DeploymentState: deploymentState,
+	}, "", "    ")
 	if err != nil {
 		return bosherr.WrapError(err, "Marshalling deployment state into JSON")
 	}
 
+	if s.passphrase != "" {
+		jsonContent, err = deploymentStateCipher{passphrase: s.passphrase}.Encrypt(jsonContent)
+		if err != nil {
+			return bosherr.WrapError(err, "Encrypting deployment state")
+		}
+	}
+
 	err = s.fs.WriteFile(s.configPath, jsonContent)
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Writing deployment state file '%s'", s.configPath)
@@ -97,6 +175,40 @@ func (s *fileSystemDeploymentStateService) Save(deploymentState DeploymentState)
 	return nil
 }
 
+// migrate upgrades deployment state contents with an older schema version,
+// keeping a backup of the deployment state file when a migration changes it.
+func (s *fileSystemDeploymentStateService) migrate(fileContents, contents []byte, version int) ([]byte, bool, error) {
+	if version > DeploymentStateSchemaVersion {
+		return nil, false, bosherr.Errorf(
+			"Deployment state schema version %d is newer than the supported version %d, upgrade the CLI", version, DeploymentStateSchemaVersion)
+	}
+
+	if version == DeploymentStateSchemaVersion {
+		return contents, false, nil
+	}
+
+	migratedContents, migrated, err := migrateDeploymentState(contents, version)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !migrated {
+		return contents, false, nil
+	}
+
+	backupPath := fmt.Sprintf("%s.v%d.bak", s.configPath, version)
+
+	err = s.fs.WriteFile(backupPath, fileContents)
+	if err != nil {
+		return nil, false, bosherr.WrapErrorf(err, "Backing up deployment state file to '%s'", backupPath)
+	}
+
+	s.logger.Info(s.logTag, "Migrated deployment state from schema version %d to %d, backed up to '%s'",
+		version, DeploymentStateSchemaVersion, backupPath)
+
+	return migratedContents, true, nil
+}
+
 func (s *fileSystemDeploymentStateService) initDefaults(deploymentState *DeploymentState) error {
 	if deploymentState.DirectorID == "" {
 		uuid, err := s.uuidGenerator.Generate()
@@ -121,3 +233,82 @@ func (s *fileSystemDeploymentStateService) Cleanup() error {
 	}
 	return nil
 }
+
+// Lock creates a lock file holding the process ID next to the deployment
+// state file. The file is created exclusively, so only one of several
+// concurrent operations gets the lock. A lock file left behind by a process
+// that is no longer running is taken over.
+func (s *fileSystemDeploymentStateService) Lock() error {
+	lockPath := s.lockPath()
+
+	if s.fs.FileExists(lockPath) && !s.removeStaleLock(lockPath) {
+		return s.lockedError(lockPath)
+	}
+
+	lockFile, err := s.fs.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return s.lockedError(lockPath)
+		}
+		return bosherr.WrapErrorf(err, "Creating deployment state lock file '%s'", lockPath)
+	}
+
+	defer func() {
+		_ = lockFile.Close()
+	}()
+
+	s.logger.Debug(s.logTag, "Locked deployment state: %s", s.configPath)
+
+	_, err = lockFile.Write([]byte(fmt.Sprintf("%d\n", os.Getpid())))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing deployment state lock file '%s'", lockPath)
+	}
+
+	return nil
+}
+
+// removeStaleLock deletes the lock file when the process that holds it is no
+// longer running. A lock file without a process ID may still be being
+// written, and is kept.
+func (s *fileSystemDeploymentStateService) removeStaleLock(lockPath string) bool {
+	contents, err := s.fs.ReadFileString(lockPath)
+	if err != nil {
+		return false
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(contents))
+	if err != nil || pid <= 0 || processRunning(pid) {
+		return false
+	}
+
+	s.logger.Warn(s.logTag, "Taking over deployment state lock file '%s' of process %d, which is no longer running", lockPath, pid)
+
+	err = s.fs.RemoveAll(lockPath)
+	if err != nil {
+		s.logger.Warn(s.logTag, "Deleting stale deployment state lock file '%s': %s", lockPath, err.Error())
+		return false
+	}
+
+	return true
+}
+
+func (s *fileSystemDeploymentStateService) Unlock() error {
+	err := s.fs.RemoveAll(s.lockPath())
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Deleting deployment state lock file '%s'", s.lockPath())
+	}
+
+	s.logger.Debug(s.logTag, "Unlocked deployment state: %s", s.configPath)
+
+	return nil
+}
+
+func (s *fileSystemDeploymentStateService) lockPath() string {
+	return s.configPath + ".lock"
+}
+
+func (s *fileSystemDeploymentStateService) lockedError(lockPath string) error {
+	return bosherr.Errorf(
+		"Another operation is in progress on deployment state '%s'. If no other operation is running, delete the lock file '%s'",
+		s.configPath, lockPath)
+}
@@ -7,6 +7,8 @@ import (
 
 	"encoding/json"
 	"errors"
+	"fmt"
+	"os"
 	"path/filepath"
 
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -186,8 +188,12 @@ var _ = Describe("fileSystemDeploymentStateService", func() {
 					},
 				},
 			}
-			expectedDeploymentStateFileContents, err := json.MarshalIndent(deploymentState, "", "    ")
-			Expect(deploymentStateFileContents).To(Equal(string(expectedDeploymentStateFileContents)))
+			Expect(deploymentStateFileContents).To(HavePrefix("{\n    \"schema_version\": 1,\n"))
+
+			var savedDeploymentState DeploymentState
+			err = json.Unmarshal([]byte(deploymentStateFileContents), &savedDeploymentState)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(savedDeploymentState).To(Equal(deploymentState))
 		})
 
 		Context("when the deployment file cannot be written", func() {
@@ -231,4 +237,261 @@ var _ = Describe("fileSystemDeploymentStateService", func() {
 			Expect(err.Error()).To(ContainSubstring("Could not do that Dave"))
 		})
 	})
+
+	Describe("migrating the deployment state schema", func() {
+		const unversionedDeploymentState = `{
+    "director_id": "fake-director-id",
+    "current_vm_cid": "fake-vm-cid",
+    "current_manifest_sha": "fake-manifest-sha"
+}`
+
+		It("loads a deployment state without schema version", func() {
+			fakeFs.WriteFileString(deploymentStatePath, unversionedDeploymentState)
+
+			deploymentState, err := service.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentState.DirectorID).To(Equal("fake-director-id"))
+			Expect(deploymentState.CurrentVMCID).To(Equal("fake-vm-cid"))
+			Expect(deploymentState.CurrentManifestSHA).To(Equal("fake-manifest-sha"))
+		})
+
+		It("does not rewrite or back up a deployment state that no migration changes", func() {
+			fakeFs.WriteFileString(deploymentStatePath, unversionedDeploymentState)
+
+			_, err := service.Load()
+			Expect(err).ToNot(HaveOccurred())
+
+			contents, err := fakeFs.ReadFileString(deploymentStatePath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(contents).To(Equal(unversionedDeploymentState))
+			Expect(fakeFs.FileExists("/some/deployment.json.v0.bak")).To(BeFalse())
+		})
+
+		It("returns an error for a deployment state with a newer schema version", func() {
+			fakeFs.WriteFileString(deploymentStatePath, `{"schema_version": 1000, "director_id": "fake-director-id"}`)
+
+			_, err := service.Load()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Deployment state schema version 1000 is newer than the supported version 1, upgrade the CLI"))
+		})
+	})
+
+	Describe("encrypted deployment state", func() {
+		var (
+			encryptedService DeploymentStateService
+			deploymentState  DeploymentState
+		)
+
+		BeforeEach(func() {
+			encryptedService = NewEncryptedFileSystemDeploymentStateService(
+				fakeFs, fakeUUIDGenerator, boshlog.NewLogger(boshlog.LevelNone), deploymentStatePath, "fake-passphrase", nil)
+
+			deploymentState = DeploymentState{
+				DirectorID:         "fake-director-id",
+				CurrentVMCID:       "fake-vm-cid",
+				CurrentDiskID:      "fake-disk-id",
+				CurrentManifestSHA: "fake-manifest-sha",
+				Disks: []DiskRecord{
+					{
+						ID:   "fake-disk-id",
+						CID:  "fake-disk-cid",
+						Size: 1024,
+					},
+				},
+			}
+		})
+
+		It("saves the deployment state encrypted", func() {
+			err := encryptedService.Save(deploymentState)
+			Expect(err).ToNot(HaveOccurred())
+
+			contents, err := fakeFs.ReadFileString(deploymentStatePath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(contents).To(ContainSubstring(`"encryption": "aes-256-gcm"`))
+			Expect(contents).ToNot(ContainSubstring("fake-director-id"))
+			Expect(contents).ToNot(ContainSubstring("fake-disk-cid"))
+		})
+
+		It("loads the deployment state it saved", func() {
+			err := encryptedService.Save(deploymentState)
+			Expect(err).ToNot(HaveOccurred())
+
+			loadedState, err := encryptedService.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loadedState).To(Equal(deploymentState))
+		})
+
+		It("loads a plain deployment state and encrypts it when saving", func() {
+			err := service.Save(deploymentState)
+			Expect(err).ToNot(HaveOccurred())
+
+			loadedState, err := encryptedService.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loadedState).To(Equal(deploymentState))
+
+			err = encryptedService.Save(loadedState)
+			Expect(err).ToNot(HaveOccurred())
+
+			contents, err := fakeFs.ReadFileString(deploymentStatePath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(contents).ToNot(ContainSubstring("fake-director-id"))
+		})
+
+		It("returns an error when the passphrase is wrong", func() {
+			err := encryptedService.Save(deploymentState)
+			Expect(err).ToNot(HaveOccurred())
+
+			otherService := NewEncryptedFileSystemDeploymentStateService(
+				fakeFs, fakeUUIDGenerator, boshlog.NewLogger(boshlog.LevelNone), deploymentStatePath, "fake-other-passphrase", nil)
+
+			_, err = otherService.Load()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Decrypting deployment state file '/some/deployment.json': Wrong passphrase or corrupted deployment state"))
+		})
+
+		It("returns an error when no passphrase is given for an encrypted deployment state", func() {
+			err := encryptedService.Save(deploymentState)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = service.Load()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Deployment state file '/some/deployment.json' is encrypted, " +
+				"provide its passphrase with --state-passphrase or BOSH_STATE_PASSPHRASE"))
+		})
+
+		It("asks for the passphrase of an encrypted deployment state and keeps it encrypted", func() {
+			err := encryptedService.Save(deploymentState)
+			Expect(err).ToNot(HaveOccurred())
+
+			asked := 0
+			askingService := NewEncryptedFileSystemDeploymentStateService(
+				fakeFs, fakeUUIDGenerator, boshlog.NewLogger(boshlog.LevelNone), deploymentStatePath, "",
+				func() (string, error) {
+					asked++
+					return "fake-passphrase", nil
+				})
+
+			loadedState, err := askingService.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loadedState).To(Equal(deploymentState))
+
+			err = askingService.Save(loadedState)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = askingService.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(asked).To(Equal(1))
+
+			contents, err := fakeFs.ReadFileString(deploymentStatePath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(contents).ToNot(ContainSubstring("fake-director-id"))
+		})
+
+		It("returns an error when asking for the passphrase fails", func() {
+			err := encryptedService.Save(deploymentState)
+			Expect(err).ToNot(HaveOccurred())
+
+			askingService := NewEncryptedFileSystemDeploymentStateService(
+				fakeFs, fakeUUIDGenerator, boshlog.NewLogger(boshlog.LevelNone), deploymentStatePath, "",
+				func() (string, error) { return "", errors.New("fake-ask-err") })
+
+			_, err = askingService.Load()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-ask-err"))
+		})
+
+		It("reuses the salt of the loaded deployment state so that the key is derived once", func() {
+			salt := func() []byte {
+				contents, err := fakeFs.ReadFile(deploymentStatePath)
+				Expect(err).ToNot(HaveOccurred())
+
+				var encryptedState struct {
+					Salt []byte `json:"salt"`
+				}
+				Expect(json.Unmarshal(contents, &encryptedState)).To(Succeed())
+
+				return encryptedState.Salt
+			}
+
+			err := encryptedService.Save(deploymentState)
+			Expect(err).ToNot(HaveOccurred())
+			firstSalt := salt()
+
+			loadedState, err := encryptedService.Load()
+			Expect(err).ToNot(HaveOccurred())
+
+			err = encryptedService.Save(loadedState)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(salt()).To(Equal(firstSalt))
+		})
+	})
+
+	Describe("Lock", func() {
+		It("creates a lock file next to the deployment state file", func() {
+			err := service.Lock()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeFs.FileExists("/some/deployment.json.lock")).To(BeTrue())
+		})
+
+		It("fails while another operation holds the lock", func() {
+			err := service.Lock()
+			Expect(err).ToNot(HaveOccurred())
+
+			otherService := NewFileSystemDeploymentStateService(
+				fakeFs, fakeUUIDGenerator, boshlog.NewLogger(boshlog.LevelNone), deploymentStatePath)
+
+			err = otherService.Lock()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Another operation is in progress on deployment state '/some/deployment.json'. " +
+				"If no other operation is running, delete the lock file '/some/deployment.json.lock'"))
+		})
+
+		It("records the process ID in the lock file", func() {
+			err := service.Lock()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeFs.ReadFileString("/some/deployment.json.lock")).To(Equal(fmt.Sprintf("%d\n", os.Getpid())))
+		})
+
+		It("takes over a lock file of a process that is no longer running", func() {
+			// larger than the maximum process ID on linux
+			err := fakeFs.WriteFileString("/some/deployment.json.lock", "4194305\n")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = service.Lock()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeFs.ReadFileString("/some/deployment.json.lock")).To(Equal(fmt.Sprintf("%d\n", os.Getpid())))
+		})
+
+		It("fails when the lock file does not hold a process ID", func() {
+			err := fakeFs.WriteFileString("/some/deployment.json.lock", "")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = service.Lock()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Another operation is in progress"))
+		})
+
+		It("can be locked again once unlocked", func() {
+			err := service.Lock()
+			Expect(err).ToNot(HaveOccurred())
+
+			err = service.Unlock()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeFs.FileExists("/some/deployment.json.lock")).To(BeFalse())
+
+			err = service.Lock()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns an error when the lock file cannot be created", func() {
+			fakeFs.OpenFileErr = errors.New("fake-open-file-error")
+
+			err := service.Lock()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-open-file-error"))
+		})
+	})
 })
@@ -0,0 +1,99 @@
+package config
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// InstanceRepo records the instances other than the first, along with the IP
+// their agent listens on, so that they can be found when scaling down or
+// deleting the deployment.
+type InstanceRepo interface {
+	All() ([]InstanceRecord, error)
+	Save(index int, ip string) error
+	Delete(index int) error
+}
+
+type instanceRepo struct {
+	deploymentStateService DeploymentStateService
+}
+
+func NewInstanceRepo(deploymentStateService DeploymentStateService) InstanceRepo {
+	return instanceRepo{
+		deploymentStateService: deploymentStateService,
+	}
+}
+
+func (r instanceRepo) All() ([]InstanceRecord, error) {
+	deploymentState, err := r.deploymentStateService.Load()
+	if err != nil {
+		return []InstanceRecord{}, bosherr.WrapError(err, "Loading existing config")
+	}
+
+	if deploymentState.Instances == nil {
+		return []InstanceRecord{}, nil
+	}
+
+	return deploymentState.Instances, nil
+}
+
+func (r instanceRepo) Save(index int, ip string) error {
+	deploymentState, err := r.deploymentStateService.Load()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading existing config")
+	}
+
+	instanceRecord(&deploymentState, index).IP = ip
+
+	err = r.deploymentStateService.Save(deploymentState)
+	if err != nil {
+		return bosherr.WrapError(err, "Saving new config")
+	}
+	return nil
+}
+
+func (r instanceRepo) Delete(index int) error {
+	deploymentState, err := r.deploymentStateService.Load()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading existing config")
+	}
+
+	newRecords := []InstanceRecord{}
+	for _, record := range deploymentState.Instances {
+		if record.Index != index {
+			newRecords = append(newRecords, record)
+		}
+	}
+
+	deploymentState.Instances = newRecords
+
+	err = r.deploymentStateService.Save(deploymentState)
+	if err != nil {
+		return bosherr.WrapError(err, "Saving new config")
+	}
+	return nil
+}
+
+// instanceRecord returns the record of the instance with the given index,
+// adding one to the deployment state when there is none.
+func instanceRecord(deploymentState *DeploymentState, index int) *InstanceRecord {
+	for i := range deploymentState.Instances {
+		if deploymentState.Instances[i].Index == index {
+			return &deploymentState.Instances[i]
+		}
+	}
+
+	deploymentState.Instances = append(deploymentState.Instances, InstanceRecord{Index: index})
+
+	return &deploymentState.Instances[len(deploymentState.Instances)-1]
+}
+
+// findInstanceRecord returns the record of the instance with the given index.
+func findInstanceRecord(deploymentState DeploymentState, index int) (InstanceRecord, bool) {
+	for _, record := range deploymentState.Instances {
+		if record.Index == index {
+			return record, true
+		}
+	}
+
+	return InstanceRecord{}, false
+}
@@ -0,0 +1,83 @@
+package config_test
+
+import (
+	. "github.com/cloudfoundry/bosh-cli/config"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("InstanceRepo", func() {
+	var (
+		repo                   InstanceRepo
+		deploymentStateService DeploymentStateService
+	)
+
+	BeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		fs := fakesys.NewFakeFileSystem()
+		fakeUUIDGenerator := &fakeuuid.FakeGenerator{}
+		deploymentStateService = NewFileSystemDeploymentStateService(fs, fakeUUIDGenerator, logger, "/fake/path")
+		repo = NewInstanceRepo(deploymentStateService)
+	})
+
+	Describe("Save", func() {
+		It("records the IP of the instance", func() {
+			err := repo.Save(1, "10.0.0.7")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = repo.Save(2, "10.0.0.8")
+			Expect(err).ToNot(HaveOccurred())
+
+			records, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(records).To(Equal([]InstanceRecord{
+				{Index: 1, IP: "10.0.0.7"},
+				{Index: 2, IP: "10.0.0.8"},
+			}))
+		})
+
+		It("keeps the VM and disk of an existing instance", func() {
+			err := NewInstanceVMRepo(deploymentStateService, 1).UpdateCurrent("fake-vm-cid")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = repo.Save(1, "10.0.0.7")
+			Expect(err).ToNot(HaveOccurred())
+
+			records, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(records).To(Equal([]InstanceRecord{
+				{Index: 1, IP: "10.0.0.7", VMCID: "fake-vm-cid"},
+			}))
+		})
+	})
+
+	Describe("All", func() {
+		It("returns no records when there are no other instances", func() {
+			records, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(records).To(BeEmpty())
+		})
+	})
+
+	Describe("Delete", func() {
+		It("removes the record of the instance", func() {
+			err := repo.Save(1, "10.0.0.7")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = repo.Save(2, "10.0.0.8")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = repo.Delete(1)
+			Expect(err).ToNot(HaveOccurred())
+
+			records, err := repo.All()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(records).To(Equal([]InstanceRecord{
+				{Index: 2, IP: "10.0.0.8"},
+			}))
+		})
+	})
+})
@@ -112,6 +112,7 @@ registry_instances:
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(content).To(MatchRegexp(`{
+    "schema_version": 1,
     "director_id": "bm-5480c6bb-3ba8-449a-a262-a2e75fbe5daf",
     "installation_id": "",
     "current_vm_cid": "",
@@ -164,6 +165,7 @@ registry_instances:
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(content).To(MatchRegexp(`{
+    "schema_version": 1,
     "director_id": "fake-uuid-0",
     "installation_id": "",
     "current_vm_cid": "",
@@ -216,6 +218,7 @@ registry_instances:
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(content).To(MatchRegexp(`{
+    "schema_version": 1,
     "director_id": "bm-5480c6bb-3ba8-449a-a262-a2e75fbe5daf",
     "installation_id": "",
     "current_vm_cid": "",
@@ -268,6 +271,7 @@ registry_instances:
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(content).To(MatchRegexp(`{
+    "schema_version": 1,
     "director_id": "bm-5480c6bb-3ba8-449a-a262-a2e75fbe5daf",
     "installation_id": "",
     "current_vm_cid": "i-a1624150",
@@ -334,6 +338,7 @@ registry_instances:
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(content).To(MatchRegexp(`{
+    "schema_version": 1,
     "director_id": "bm-5480c6bb-3ba8-449a-a262-a2e75fbe5daf",
     "installation_id": "",
     "current_vm_cid": "i-a1624150",
@@ -386,6 +391,7 @@ registry_instances:
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(content).To(MatchRegexp(`{
+    "schema_version": 1,
     "director_id": "bm-5480c6bb-3ba8-449a-a262-a2e75fbe5daf",
     "installation_id": "",
     "current_vm_cid": "",
@@ -445,6 +451,7 @@ registry_instances:
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(content).To(MatchRegexp(`{
+    "schema_version": 1,
     "director_id": "bm-5480c6bb-3ba8-449a-a262-a2e75fbe5daf",
     "installation_id": "",
     "current_vm_cid": "",
@@ -497,6 +504,7 @@ registry_instances:
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(content).To(MatchRegexp(`{
+    "schema_version": 1,
     "director_id": "bm-5480c6bb-3ba8-449a-a262-a2e75fbe5daf",
     "installation_id": "",
     "current_vm_cid": "",
@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package config
+
+import (
+	"syscall"
+)
+
+// processRunning reports whether a process with the ID is running. A process
+// of another user that cannot be signalled is running as well.
+func processRunning(pid int) bool {
+	err := syscall.Kill(pid, syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"syscall"
+)
+
+// stillActive is the exit code of a process that has not exited yet
+const stillActive = 259
+
+// processRunning reports whether a process with the ID is running. A process
+// of another user that cannot be opened is running as well.
+func processRunning(pid int) bool {
+	handle, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return err == syscall.ERROR_ACCESS_DENIED
+	}
+
+	defer func() {
+		_ = syscall.CloseHandle(handle)
+	}()
+
+	var exitCode uint32
+
+	err = syscall.GetExitCodeProcess(handle, &exitCode)
+	if err != nil {
+		return true
+	}
+
+	return exitCode == stillActive
+}
@@ -11,6 +11,7 @@ type StemcellRepo interface // StemcellRepo persists stemcells metadata
 	FindCurrent() (StemcellRecord, bool, error)
 	ClearCurrent() error
 	Save(name, version, cid string) (StemcellRecord, error)
+	UpdateAPIVersion(recordID string, apiVersion int) error
 	Find(name, version string) (StemcellRecord, bool, error)
 	All() ([]StemcellRecord, error)
 	Delete(StemcellRecord) error
@@ -50,7 +51,7 @@ func (r stemcellRepo) Save(name, version, cid string) (StemcellRecord, error) {
 
 		for _, oldRecord := range records {
 			if oldRecord.Name == newRecord.Name && oldRecord.Version == newRecord.Version {
-				return bosherr.Errorf("Failed to save stemcell record '%s' (duplicate name/version), existing record found '%s'", newRecord, oldRecord)
+				return bosherr.Errorf("Failed to save stemcell record '%v' (duplicate name/version), existing record found '%v'", newRecord, oldRecord)
 			}
 		}
 
@@ -153,6 +154,19 @@ func (r stemcellRepo) UpdateCurrent(recordID string) error {
 	})
 }
 
+func (r stemcellRepo) UpdateAPIVersion(recordID string, apiVersion int) error {
+	return r.updateConfig(func(config *DeploymentState) error {
+		for i, oldRecord := range config.Stemcells {
+			if oldRecord.ID == recordID {
+				config.Stemcells[i].APIVersion = apiVersion
+				return nil
+			}
+		}
+
+		return bosherr.Errorf("Verifying stemcell record exists with id '%s'", recordID)
+	})
+}
+
 func (r stemcellRepo) ClearCurrent() error {
 	return r.updateConfig(func(config *DeploymentState) error {
 		config.CurrentStemcellID = ""
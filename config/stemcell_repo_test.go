@@ -191,6 +191,30 @@ var _ = Describe("StemcellRepo", func() {
 		})
 	})
 
+	Describe("UpdateAPIVersion", func() {
+		BeforeEach(func() {
+			fakeUUIDGenerator.GeneratedUUID = "fake-uuid-1"
+			_, err := repo.Save("fake-name", "fake-version", "fake-cid")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("records the api_version of the stemcell", func() {
+			err := repo.UpdateAPIVersion("fake-uuid-1", 2)
+			Expect(err).ToNot(HaveOccurred())
+
+			record, found, err := repo.Find("fake-name", "fake-version")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(record.APIVersion).To(Equal(2))
+		})
+
+		It("returns an error when the stemcell record does not exist", func() {
+			err := repo.UpdateAPIVersion("fake-uuid-2", 2)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Verifying stemcell record exists with id 'fake-uuid-2'"))
+		})
+	})
+
 	Describe("ClearCurrent", func() {
 		Context("when a stemcell record exists with the same ID", func() {
 			BeforeEach(func() {
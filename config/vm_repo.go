@@ -8,15 +8,35 @@ type VMRepo interface {
 	FindCurrent() (cid string, found bool, err error)
 	UpdateCurrent(cid string) error
 	ClearCurrent() error
+
+	// FindCurrentAgentID returns the ID of the agent on the current VM,
+	// which is only recorded for VMs created since agent IDs are recorded.
+	FindCurrentAgentID() (agentID string, found bool, err error)
+	UpdateCurrentAgentID(agentID string) error
+
+	// FindReplaced returns the VM being replaced by the current VM, which is
+	// recorded before its replacement is created, so that it is not lost
+	// when the deploy stops before the replaced VM is deleted.
+	FindReplaced() (cid string, agentID string, found bool, err error)
+	UpdateReplaced(cid string, agentID string) error
+	ClearReplaced() error
 }
 
 type vMRepo struct {
 	deploymentStateService DeploymentStateService
+	index                  int
 }
 
 func NewVMRepo(deploymentStateService DeploymentStateService) VMRepo {
+	return NewInstanceVMRepo(deploymentStateService, 0)
+}
+
+// NewInstanceVMRepo returns a repo of the current VM of the instance with the
+// given index.
+func NewInstanceVMRepo(deploymentStateService DeploymentStateService, index int) VMRepo {
 	return vMRepo{
 		deploymentStateService: deploymentStateService,
+		index:                  index,
 	}
 }
 
@@ -27,6 +47,11 @@ func (r vMRepo) FindCurrent() (string, bool, error) {
 	}
 
 	currentVMCID := deploymentState.CurrentVMCID
+	if r.index > 0 {
+		record, _ := findInstanceRecord(deploymentState, r.index)
+		currentVMCID = record.VMCID
+	}
+
 	if currentVMCID != "" {
 		return currentVMCID, true, nil
 	}
@@ -35,27 +60,92 @@ func (r vMRepo) FindCurrent() (string, bool, error) {
 }
 
 func (r vMRepo) UpdateCurrent(cid string) error {
+	return r.setCurrent(func(deploymentState *DeploymentState) {
+		if r.index > 0 {
+			instanceRecord(deploymentState, r.index).VMCID = cid
+		} else {
+			deploymentState.CurrentVMCID = cid
+		}
+	})
+}
+
+func (r vMRepo) ClearCurrent() error {
+	return r.setCurrent(func(deploymentState *DeploymentState) {
+		if r.index > 0 {
+			record := instanceRecord(deploymentState, r.index)
+			record.VMCID = ""
+			record.AgentID = ""
+		} else {
+			deploymentState.CurrentVMCID = ""
+			deploymentState.CurrentAgentID = ""
+		}
+	})
+}
+
+func (r vMRepo) FindCurrentAgentID() (string, bool, error) {
 	deploymentState, err := r.deploymentStateService.Load()
 	if err != nil {
-		return bosherr.WrapError(err, "Loading existing config")
+		return "", false, bosherr.WrapError(err, "Loading existing config")
 	}
 
-	deploymentState.CurrentVMCID = cid
+	currentAgentID := deploymentState.CurrentAgentID
+	if r.index > 0 {
+		record, _ := findInstanceRecord(deploymentState, r.index)
+		currentAgentID = record.AgentID
+	}
 
-	err = r.deploymentStateService.Save(deploymentState)
+	return currentAgentID, currentAgentID != "", nil
+}
+
+func (r vMRepo) UpdateCurrentAgentID(agentID string) error {
+	return r.setCurrent(func(deploymentState *DeploymentState) {
+		if r.index > 0 {
+			instanceRecord(deploymentState, r.index).AgentID = agentID
+		} else {
+			deploymentState.CurrentAgentID = agentID
+		}
+	})
+}
+
+func (r vMRepo) FindReplaced() (string, string, bool, error) {
+	deploymentState, err := r.deploymentStateService.Load()
 	if err != nil {
-		return bosherr.WrapError(err, "Saving new config")
+		return "", "", false, bosherr.WrapError(err, "Loading existing config")
 	}
-	return nil
+
+	cid, agentID := deploymentState.ReplacedVMCID, deploymentState.ReplacedAgentID
+	if r.index > 0 {
+		record, _ := findInstanceRecord(deploymentState, r.index)
+		cid, agentID = record.ReplacedVMCID, record.ReplacedAgentID
+	}
+
+	return cid, agentID, cid != "", nil
 }
 
-func (r vMRepo) ClearCurrent() error {
+func (r vMRepo) UpdateReplaced(cid string, agentID string) error {
+	return r.setCurrent(func(deploymentState *DeploymentState) {
+		if r.index > 0 {
+			record := instanceRecord(deploymentState, r.index)
+			record.ReplacedVMCID = cid
+			record.ReplacedAgentID = agentID
+		} else {
+			deploymentState.ReplacedVMCID = cid
+			deploymentState.ReplacedAgentID = agentID
+		}
+	})
+}
+
+func (r vMRepo) ClearReplaced() error {
+	return r.UpdateReplaced("", "")
+}
+
+func (r vMRepo) setCurrent(update func(*DeploymentState)) error {
 	deploymentState, err := r.deploymentStateService.Load()
 	if err != nil {
 		return bosherr.WrapError(err, "Loading existing config")
 	}
 
-	deploymentState.CurrentVMCID = ""
+	update(&deploymentState)
 
 	err = r.deploymentStateService.Save(deploymentState)
 	if err != nil {
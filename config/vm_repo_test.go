@@ -84,4 +84,137 @@ var _ = Describe("VMRepo", func() {
 			Expect(found).To(BeFalse())
 		})
 	})
+
+	Describe("UpdateCurrentAgentID", func() {
+		It("records the agent id of the current vm", func() {
+			err := repo.UpdateCurrent("fake-vm-cid")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = repo.UpdateCurrentAgentID("fake-agent-id")
+			Expect(err).ToNot(HaveOccurred())
+
+			agentID, found, err := repo.FindCurrentAgentID()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(agentID).To(Equal("fake-agent-id"))
+		})
+
+		It("is cleared together with the vm cid", func() {
+			err := repo.UpdateCurrentAgentID("fake-agent-id")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = repo.ClearCurrent()
+			Expect(err).ToNot(HaveOccurred())
+
+			_, found, err := repo.FindCurrentAgentID()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Describe("UpdateReplaced", func() {
+		It("records the replaced vm next to the current vm", func() {
+			err := repo.UpdateCurrent("fake-vm-cid")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = repo.UpdateReplaced("fake-replaced-vm-cid", "fake-replaced-agent-id")
+			Expect(err).ToNot(HaveOccurred())
+
+			cid, agentID, found, err := repo.FindReplaced()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(cid).To(Equal("fake-replaced-vm-cid"))
+			Expect(agentID).To(Equal("fake-replaced-agent-id"))
+
+			currentCID, _, err := repo.FindCurrent()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(currentCID).To(Equal("fake-vm-cid"))
+		})
+	})
+
+	Describe("ClearReplaced", func() {
+		It("clears the replaced vm", func() {
+			err := repo.UpdateReplaced("fake-replaced-vm-cid", "fake-replaced-agent-id")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = repo.ClearReplaced()
+			Expect(err).ToNot(HaveOccurred())
+
+			_, _, found, err := repo.FindReplaced()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Context("when the repo is for an instance other than the first", func() {
+		var instanceRepo VMRepo
+
+		BeforeEach(func() {
+			instanceRepo = NewInstanceVMRepo(deploymentStateService, 1)
+
+			err := repo.UpdateCurrent("fake-vm-cid-0")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("records the vm cid of the instance separately", func() {
+			err := instanceRepo.UpdateCurrent("fake-vm-cid-1")
+			Expect(err).ToNot(HaveOccurred())
+
+			deploymentState, err := deploymentStateService.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentState.CurrentVMCID).To(Equal("fake-vm-cid-0"))
+			Expect(deploymentState.Instances).To(Equal([]InstanceRecord{
+				{Index: 1, VMCID: "fake-vm-cid-1"},
+			}))
+
+			cid, found, err := instanceRepo.FindCurrent()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(cid).To(Equal("fake-vm-cid-1"))
+		})
+
+		It("records the agent id of the instance separately", func() {
+			err := repo.UpdateCurrentAgentID("fake-agent-id-0")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = instanceRepo.UpdateCurrentAgentID("fake-agent-id-1")
+			Expect(err).ToNot(HaveOccurred())
+
+			deploymentState, err := deploymentStateService.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentState.CurrentAgentID).To(Equal("fake-agent-id-0"))
+			Expect(deploymentState.Instances).To(Equal([]InstanceRecord{
+				{Index: 1, AgentID: "fake-agent-id-1"},
+			}))
+		})
+
+		It("clears only the vm cid of the instance", func() {
+			err := instanceRepo.UpdateCurrent("fake-vm-cid-1")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = instanceRepo.ClearCurrent()
+			Expect(err).ToNot(HaveOccurred())
+
+			_, found, err := instanceRepo.FindCurrent()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+
+			cid, found, err := repo.FindCurrent()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(cid).To(Equal("fake-vm-cid-0"))
+		})
+
+		It("records the replaced vm of the instance separately", func() {
+			err := instanceRepo.UpdateReplaced("fake-replaced-vm-cid-1", "fake-replaced-agent-id-1")
+			Expect(err).ToNot(HaveOccurred())
+
+			deploymentState, err := deploymentStateService.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentState.ReplacedVMCID).To(BeEmpty())
+			Expect(deploymentState.Instances).To(Equal([]InstanceRecord{
+				{Index: 1, ReplacedVMCID: "fake-replaced-vm-cid-1", ReplacedAgentID: "fake-replaced-agent-id-1"},
+			}))
+		})
+	})
 })
@@ -0,0 +1,233 @@
+package configserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	gourl "net/url"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshhttpclient "github.com/cloudfoundry/bosh-utils/httpclient"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+)
+
+// Client resolves variables against the v1 data API of a config server,
+// which CredHub implements as well. Variables that are missing and declare
+// a type are generated by the server.
+type Client struct {
+	endpoint   string
+	namespace  string
+	httpClient boshhttpclient.HTTPClient
+
+	logTag string
+	logger boshlog.Logger
+}
+
+var _ boshtpl.Variables = Client{}
+
+type dataResponse struct {
+	Data []dataEntry `json:"data"`
+}
+
+type dataEntry struct {
+	ID    string      `json:"id"`
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+type generateRequest struct {
+	Name       string      `json:"name"`
+	Type       string      `json:"type"`
+	Parameters interface{} `json:"parameters"`
+}
+
+func NewClient(endpoint, namespace string, httpClient boshhttpclient.HTTPClient, logger boshlog.Logger) Client {
+	return Client{
+		endpoint:   endpoint,
+		namespace:  namespace,
+		httpClient: httpClient,
+
+		logTag: "configserver.Client",
+		logger: logger,
+	}
+}
+
+func (c Client) Get(varDef boshtpl.VariableDefinition) (interface{}, bool, error) {
+	name := c.absoluteName(varDef.Name)
+
+	val, found, err := c.read(name)
+	if err != nil {
+		return nil, false, bosherr.WrapErrorf(err, "Getting variable '%s' from config server", name)
+	}
+
+	if found {
+		return val, true, nil
+	}
+
+	if len(varDef.Type) == 0 {
+		return nil, false, nil
+	}
+
+	val, err = c.generate(name, varDef)
+	if err != nil {
+		return nil, false, bosherr.WrapErrorf(err, "Generating variable '%s' in config server", name)
+	}
+
+	return val, true, nil
+}
+
+// List does not return any variables since the data API cannot list them.
+func (c Client) List() ([]boshtpl.VariableDefinition, error) {
+	return nil, nil
+}
+
+func (c Client) read(name string) (interface{}, bool, error) {
+	url := fmt.Sprintf("%s/v1/data?name=%s", c.endpoint, gourl.QueryEscape(name))
+
+	setHeaders := func(req *http.Request) {
+		req.Header.Add("Accept", "application/json")
+	}
+
+	resp, err := c.httpClient.GetCustomized(url, setHeaders)
+	if err != nil {
+		return nil, false, bosherr.WrapErrorf(err, "Performing request GET '%s'", url)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, false, nil
+	}
+
+	respBody, err := c.readResponse(resp)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var response dataResponse
+
+	err = json.Unmarshal(respBody, &response)
+	if err != nil {
+		return nil, false, bosherr.WrapError(err, "Unmarshaling config server response")
+	}
+
+	// Latest version is listed first
+	if len(response.Data) == 0 {
+		return nil, false, nil
+	}
+
+	return response.Data[0].Value, true, nil
+}
+
+func (c Client) generate(name string, varDef boshtpl.VariableDefinition) (interface{}, error) {
+	parameters, err := c.jsonCompatible(varDef.Options)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Converting variable options")
+	}
+
+	if parameters == nil {
+		parameters = map[string]interface{}{}
+	}
+
+	// Certificates reference their CA by name, which is namespaced the same way
+	if paramsMap, ok := parameters.(map[string]interface{}); ok {
+		if ca, ok := paramsMap["ca"].(string); ok && len(ca) > 0 {
+			paramsMap["ca"] = c.absoluteName(ca)
+		}
+	}
+
+	payload, err := json.Marshal(generateRequest{Name: name, Type: varDef.Type, Parameters: parameters})
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Marshaling generate request")
+	}
+
+	url := fmt.Sprintf("%s/v1/data", c.endpoint)
+
+	setHeaders := func(req *http.Request) {
+		req.Header.Add("Accept", "application/json")
+		req.Header.Add("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.PostCustomized(url, payload, setHeaders)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Performing request POST '%s'", url)
+	}
+
+	respBody, err := c.readResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var response dataEntry
+
+	err = json.Unmarshal(respBody, &response)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshaling config server response")
+	}
+
+	return response.Value, nil
+}
+
+func (c Client) absoluteName(name string) string {
+	if strings.HasPrefix(name, "/") {
+		return name
+	}
+
+	return strings.TrimSuffix(c.namespace, "/") + "/" + name
+}
+
+// jsonCompatible converts YAML maps, whose keys are interfaces, to JSON maps.
+func (c Client) jsonCompatible(value interface{}) (interface{}, error) {
+	switch typedValue := value.(type) {
+	case map[interface{}]interface{}:
+		result := map[string]interface{}{}
+		for k, v := range typedValue {
+			key, ok := k.(string)
+			if !ok {
+				return nil, bosherr.Errorf("Expected key '%v' to be a string", k)
+			}
+
+			converted, err := c.jsonCompatible(v)
+			if err != nil {
+				return nil, err
+			}
+
+			result[key] = converted
+		}
+		return result, nil
+
+	case []interface{}:
+		result := []interface{}{}
+		for _, v := range typedValue {
+			converted, err := c.jsonCompatible(v)
+			if err != nil {
+				return nil, err
+			}
+
+			result = append(result, converted)
+		}
+		return result, nil
+
+	default:
+		return value, nil
+	}
+}
+
+func (c Client) readResponse(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading config server response")
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg := "Config server responded with non-successful status code '%d' response '%s'"
+		return nil, bosherr.Errorf(msg, resp.StatusCode, body)
+	}
+
+	return body, nil
+}
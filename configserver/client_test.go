@@ -0,0 +1,157 @@
+package configserver_test
+
+import (
+	"net/http"
+
+	boshhttpclient "github.com/cloudfoundry/bosh-utils/httpclient"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	. "github.com/cloudfoundry/bosh-cli/configserver"
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		server *ghttp.Server
+		client Client
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		httpClient := boshhttpclient.NewHTTPClient(http.DefaultClient, logger)
+
+		client = NewClient(server.URL(), "/bosh-init", httpClient, logger)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("Get", func() {
+		It("returns the latest value of the namespaced variable", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/v1/data", "name=%2Fbosh-init%2Fpassword"),
+					ghttp.RespondWith(http.StatusOK, `{"data":[
+						{"id":"2","name":"/bosh-init/password","value":"new-value"},
+						{"id":"1","name":"/bosh-init/password","value":"old-value"}
+					]}`),
+				),
+			)
+
+			val, found, err := client.Get(boshtpl.VariableDefinition{Name: "password"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(val).To(Equal("new-value"))
+		})
+
+		It("does not namespace absolute names", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/v1/data", "name=%2Fshared%2Fpassword"),
+					ghttp.RespondWith(http.StatusOK, `{"data":[{"id":"1","name":"/shared/password","value":"value"}]}`),
+				),
+			)
+
+			val, found, err := client.Get(boshtpl.VariableDefinition{Name: "/shared/password"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(val).To(Equal("value"))
+		})
+
+		It("returns not found if the variable does not exist and has no type", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/v1/data"),
+					ghttp.RespondWith(http.StatusNotFound, `{"error":"Name '/bosh-init/password' not found"}`),
+				),
+			)
+
+			_, found, err := client.Get(boshtpl.VariableDefinition{Name: "password"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		It("generates the variable if it does not exist and has a type", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/v1/data"),
+					ghttp.RespondWith(http.StatusNotFound, ``),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/data"),
+					ghttp.VerifyHeader(http.Header{"Content-Type": []string{"application/json"}}),
+					ghttp.VerifyJSON(`{
+						"name": "/bosh-init/cert",
+						"type": "certificate",
+						"parameters": {"ca": "/bosh-init/ca", "common_name": "host", "alternative_names": ["10.0.0.6"]}
+					}`),
+					ghttp.RespondWith(http.StatusOK, `{"id":"1","name":"/bosh-init/cert","value":{"certificate":"cert","private_key":"key"}}`),
+				),
+			)
+
+			val, found, err := client.Get(boshtpl.VariableDefinition{
+				Name: "cert",
+				Type: "certificate",
+				Options: map[interface{}]interface{}{
+					"ca":                "ca",
+					"common_name":       "host",
+					"alternative_names": []interface{}{"10.0.0.6"},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(val).To(Equal(map[string]interface{}{"certificate": "cert", "private_key": "key"}))
+		})
+
+		It("generates the variable with empty parameters if it has no options", func() {
+			server.AppendHandlers(
+				ghttp.RespondWith(http.StatusOK, `{"data":[]}`),
+				ghttp.CombineHandlers(
+					ghttp.VerifyJSON(`{"name": "/bosh-init/password", "type": "password", "parameters": {}}`),
+					ghttp.RespondWith(http.StatusOK, `{"id":"1","name":"/bosh-init/password","value":"generated"}`),
+				),
+			)
+
+			val, found, err := client.Get(boshtpl.VariableDefinition{Name: "password", Type: "password"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(val).To(Equal("generated"))
+		})
+
+		It("returns error if the config server responds with an error", func() {
+			server.AppendHandlers(
+				ghttp.RespondWith(http.StatusUnauthorized, `{"error":"unauthorized"}`),
+			)
+
+			_, _, err := client.Get(boshtpl.VariableDefinition{Name: "password"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Getting variable '/bosh-init/password' from config server"))
+			Expect(err.Error()).To(ContainSubstring("non-successful status code '401'"))
+		})
+
+		It("returns error if generating fails", func() {
+			server.AppendHandlers(
+				ghttp.RespondWith(http.StatusNotFound, ``),
+				ghttp.RespondWith(http.StatusBadRequest, `{"error":"Unsupported type"}`),
+			)
+
+			_, _, err := client.Get(boshtpl.VariableDefinition{Name: "password", Type: "unknown"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Generating variable '/bosh-init/password' in config server"))
+		})
+	})
+
+	Describe("List", func() {
+		It("returns no variables", func() {
+			defs, err := client.List()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(defs).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,63 @@
+package configserver
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshhttp "github.com/cloudfoundry/bosh-utils/http"
+	boshhttpclient "github.com/cloudfoundry/bosh-utils/httpclient"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+
+	boshdir "github.com/cloudfoundry/bosh-cli/director"
+)
+
+type Factory struct {
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewFactory(logger boshlog.Logger) Factory {
+	return Factory{
+		logTag: "configserver.Factory",
+		logger: logger,
+	}
+}
+
+func (f Factory) New(config Config) (Client, error) {
+	err := config.Validate()
+	if err != nil {
+		return Client{}, bosherr.WrapErrorf(
+			err, "Validating config server connection config")
+	}
+
+	certPool, err := config.CACertPool()
+	if err != nil {
+		return Client{}, err
+	}
+
+	if certPool == nil {
+		f.logger.Debug(f.logTag, "Using default root CAs")
+	} else {
+		f.logger.Debug(f.logTag, "Using custom root CAs")
+	}
+
+	rawClient := boshhttpclient.CreateDefaultClient(certPool)
+	retryClient := boshhttp.NewNetworkSafeRetryClient(rawClient, 5, 500*time.Millisecond, f.logger)
+
+	// Config server only accepts UAA tokens
+	authAdjustment := boshdir.NewAuthRequestAdjustment(config.TokenFunc, "", "")
+	authedClient := boshdir.NewAdjustableClient(retryClient, authAdjustment)
+
+	httpClient := boshhttpclient.NewHTTPClient(authedClient, f.logger)
+
+	endpoint := url.URL{
+		Scheme: "https",
+		Host:   net.JoinHostPort(config.Host, fmt.Sprintf("%d", config.Port)),
+		Path:   config.Path,
+	}
+
+	return NewClient(endpoint.String(), config.Namespace, httpClient, f.logger), nil
+}
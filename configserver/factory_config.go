@@ -0,0 +1,100 @@
+package configserver
+
+import (
+	"crypto/x509"
+	gonet "net"
+	gourl "net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry/bosh-utils/crypto"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type Config struct {
+	Host string
+	Port int
+	Path string
+
+	// CA certificate is not required
+	CACert string
+
+	// Namespace prefixes relative variable names, e.g. '/bosh-init'
+	Namespace string
+
+	TokenFunc func(bool) (string, error)
+}
+
+func NewConfigFromURL(url string) (Config, error) {
+	if len(url) == 0 {
+		return Config{}, bosherr.Error("Expected non-empty config server URL")
+	}
+
+	parsedURL, err := gourl.Parse(url)
+	if err != nil {
+		return Config{}, bosherr.WrapErrorf(err, "Parsing config server URL '%s'", url)
+	}
+
+	host := parsedURL.Host
+	port := 443
+	path := parsedURL.Path
+
+	if len(host) == 0 {
+		host = url
+		path = ""
+	}
+
+	if strings.Contains(host, ":") {
+		var portStr string
+
+		host, portStr, err = gonet.SplitHostPort(host)
+		if err != nil {
+			return Config{}, bosherr.WrapErrorf(
+				err, "Extracting host/port from URL '%s'", url)
+		}
+
+		port, err = strconv.Atoi(portStr)
+		if err != nil {
+			return Config{}, bosherr.WrapErrorf(
+				err, "Extracting port from URL '%s'", url)
+		}
+	}
+
+	if len(host) == 0 {
+		return Config{}, bosherr.Errorf("Expected to extract host from URL '%s'", url)
+	}
+
+	return Config{Host: host, Port: port, Path: path}, nil
+}
+
+func (c Config) Validate() error {
+	if len(c.Host) == 0 {
+		return bosherr.Error("Missing 'Host'")
+	}
+
+	if c.Port == 0 {
+		return bosherr.Error("Missing 'Port'")
+	}
+
+	if c.TokenFunc == nil {
+		return bosherr.Error("Missing 'TokenFunc'")
+	}
+
+	if len(c.Namespace) > 0 && !strings.HasPrefix(c.Namespace, "/") {
+		return bosherr.Errorf("Expected 'Namespace' to start with '/' but was '%s'", c.Namespace)
+	}
+
+	if _, err := c.CACertPool(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c Config) CACertPool() (*x509.CertPool, error) {
+	if len(c.CACert) == 0 {
+		return nil, nil
+	}
+
+	return crypto.CertPoolFromPEM([]byte(c.CACert))
+}
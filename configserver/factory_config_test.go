@@ -0,0 +1,87 @@
+package configserver_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/configserver"
+)
+
+var _ = Describe("NewConfigFromURL", func() {
+	It("sets host and port (443) if scheme is specified", func() {
+		config, err := NewConfigFromURL("https://host")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(config).To(Equal(Config{Host: "host", Port: 443}))
+	})
+
+	It("extracts port and path", func() {
+		config, err := NewConfigFromURL("https://host:8080/api")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(config).To(Equal(Config{Host: "host", Port: 8080, Path: "/api"}))
+	})
+
+	It("returns error if url is empty", func() {
+		_, err := NewConfigFromURL("")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Expected non-empty config server URL"))
+	})
+
+	It("returns error if host is not specified", func() {
+		_, err := NewConfigFromURL("https://:443")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Expected to extract host from"))
+	})
+})
+
+var _ = Describe("Config", func() {
+	var (
+		config Config
+	)
+
+	BeforeEach(func() {
+		config = Config{
+			Host:      "host",
+			Port:      443,
+			TokenFunc: func(bool) (string, error) { return "bearer token", nil },
+		}
+	})
+
+	Describe("Validate", func() {
+		It("returns no error if host, port and token func are set", func() {
+			Expect(config.Validate()).ToNot(HaveOccurred())
+		})
+
+		It("returns error if host is empty", func() {
+			config.Host = ""
+			err := config.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Missing 'Host'"))
+		})
+
+		It("returns error if port is 0", func() {
+			config.Port = 0
+			err := config.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Missing 'Port'"))
+		})
+
+		It("returns error if token func is not set", func() {
+			config.TokenFunc = nil
+			err := config.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Missing 'TokenFunc'"))
+		})
+
+		It("returns error if namespace is not absolute", func() {
+			config.Namespace = "bosh-init"
+			err := config.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Expected 'Namespace' to start with '/' but was 'bosh-init'"))
+		})
+
+		It("returns error if CA cert is invalid", func() {
+			config.CACert = "-----BEGIN CERTIFICATE-----\ninvalid\n-----END CERTIFICATE-----"
+			Expect(config.Validate()).To(HaveOccurred())
+		})
+	})
+})
@@ -17,6 +17,11 @@ func NewValidator() Validator {
 }
 
 func (v Validator) Validate(release birel.Release, cpiReleaseJobName string) error {
+	// The CPI is compiled and run on this machine, not on a stemcell.
+	if release.IsCompiled() {
+		return bosherr.Error("CPI release must not be a compiled release")
+	}
+
 	job, ok := release.FindJobByName(cpiReleaseJobName)
 	if !ok {
 		return bosherr.Errorf("CPI release must contain specified job '%s'", cpiReleaseJobName)
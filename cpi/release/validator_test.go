@@ -44,6 +44,15 @@ var _ = Describe("Validator", func() {
 		Expect(err).NotTo(HaveOccurred())
 	})
 
+	It("returns an error when the release is compiled", func() {
+		release := &fakerel.FakeRelease{}
+		release.IsCompiledReturns(true)
+
+		err := NewValidator().Validate(release, cpiReleaseJobName)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("CPI release must not be a compiled release"))
+	})
+
 	Context("when the cpi job is not present", func() {
 		var validator Validator
 		var release *fakerel.FakeRelease
@@ -0,0 +1,410 @@
+package agent
+
+import (
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/bosh-agent/agentclient"
+	"github.com/cloudfoundry/bosh-agent/agentclient/applyspec"
+	bihttpagent "github.com/cloudfoundry/bosh-agent/agentclient/http"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"github.com/cloudfoundry/bosh-utils/httpclient"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+)
+
+// toleratedErrorCount is the number of failed get_task requests in a row
+// before polling a task gives up.
+const toleratedErrorCount = 10
+
+type agentClientFactory struct {
+	getTaskDelay time.Duration
+	opts         MbusOpts
+	uuidGen      boshuuid.Generator
+	logger       boshlog.Logger
+}
+
+// NewAgentClientFactory returns an AgentClientFactory for deploying. Its
+// agent clients send requests with NewMbusHTTPClient, or through a NATS
+// server for nats:// mbus URLs, and give up on tasks running longer than the
+// task timeout.
+func NewAgentClientFactory(getTaskDelay time.Duration, opts MbusOpts, uuidGen boshuuid.Generator, logger boshlog.Logger) bihttpagent.AgentClientFactory {
+	return agentClientFactory{
+		getTaskDelay: getTaskDelay,
+		opts:         opts,
+		uuidGen:      uuidGen,
+		logger:       logger,
+	}
+}
+
+func (f agentClientFactory) NewAgentClient(directorID, mbusURL, caCert string) (agentclient.AgentClient, error) {
+	if IsNATSURL(mbusURL) {
+		return f.newNATSAgentClient(directorID, mbusURL, caCert)
+	}
+
+	httpClient, err := NewMbusHTTPClient(caCert, f.opts, f.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	client := bihttpagent.NewAgentClient(mbusURL, directorID, f.getTaskDelay, toleratedErrorCount, httpClient, f.logger).(*bihttpagent.AgentClient)
+
+	return f.newPersistentDiskAgentClient(client), nil
+}
+
+func (f agentClientFactory) newNATSAgentClient(directorID, mbusURL, caCert string) (agentclient.AgentClient, error) {
+	opts := f.opts.withDefaults()
+
+	natsClient, err := NewNATSClient(mbusURL, directorID, caCert, opts.RequestTimeout, f.uuidGen, f.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	retryingClient := retryingClient{
+		client: natsClient,
+		opts:   opts,
+		logger: f.logger,
+		logTag: "mbusClient",
+	}
+
+	// the endpoint is not used by the NATS client
+	httpClient := httpclient.NewHTTPClient(retryingClient, f.logger)
+	client := bihttpagent.NewAgentClient(mbusURL, directorID, f.getTaskDelay, toleratedErrorCount, httpClient, f.logger).(*bihttpagent.AgentClient)
+
+	return natsAgentClient{
+		persistentDiskAgentClient: f.newPersistentDiskAgentClient(client),
+		natsClient:                natsClient,
+	}, nil
+}
+
+func (f agentClientFactory) newPersistentDiskAgentClient(client *bihttpagent.AgentClient) persistentDiskAgentClient {
+	scriptTimeoutClient := f.newTaskTimeoutAgentClient(client, 0)
+	scriptTimeoutClient.cancelOnTimeout = true
+
+	return persistentDiskAgentClient{
+		AgentClient:         f.withTaskTimeout(client),
+		httpAgentClient:     client,
+		scriptTimeoutClient: scriptTimeoutClient,
+	}
+}
+
+func (f agentClientFactory) withTaskTimeout(client *bihttpagent.AgentClient) agentclient.AgentClient {
+	if f.opts.TaskTimeout <= 0 {
+		return client
+	}
+
+	return f.newTaskTimeoutAgentClient(client, f.opts.TaskTimeout)
+}
+
+func (f agentClientFactory) newTaskTimeoutAgentClient(client *bihttpagent.AgentClient, timeout time.Duration) taskTimeoutAgentClient {
+	return taskTimeoutAgentClient{
+		AgentClient:         client,
+		agentRequest:        client.AgentRequest,
+		getTaskDelay:        f.getTaskDelay,
+		toleratedErrorCount: toleratedErrorCount,
+		timeout:             timeout,
+		logger:              f.logger,
+		logTag:              "taskTimeoutAgentClient",
+	}
+}
+
+// natsAgentClient lets the VM manager tell the NATS client which agent to
+// send requests to.
+type natsAgentClient struct {
+	persistentDiskAgentClient
+	natsClient *NATSClient
+}
+
+func (c natsAgentClient) SetAgentID(agentID string) {
+	c.natsClient.SetAgentID(agentID)
+}
+
+// persistentDiskAgentClient lets the VM tell the agent about a disk attached
+// by a CPI that returns the disk hint instead of writing it to the registry.
+type persistentDiskAgentClient struct {
+	agentclient.AgentClient
+	httpAgentClient *bihttpagent.AgentClient
+
+	// scriptTimeoutClient runs the scripts limited by RunScriptWithTimeout
+	scriptTimeoutClient taskTimeoutAgentClient
+}
+
+func (c persistentDiskAgentClient) AddPersistentDisk(diskCID string, diskHint interface{}) error {
+	var response valueResponse
+
+	err := c.httpAgentClient.AgentRequest.Send("add_persistent_disk", []interface{}{diskCID, diskHint}, &response)
+	if err != nil {
+		return bosherr.WrapError(err, "Sending 'add_persistent_disk' to the agent")
+	}
+
+	return nil
+}
+
+// namedDiskApplySpec is an apply spec that also tells the agent which
+// persistent disk each named disk of the instance is.
+type namedDiskApplySpec struct {
+	applyspec.ApplySpec
+	PersistentDisks map[string]string `json:"persistent_disks"`
+}
+
+// asyncTaskSender is implemented by the agent clients that send the
+// asynchronous tasks of the persistentDiskAgentClient.
+type asyncTaskSender interface {
+	sendAsyncTask(method string, arguments []interface{}) (map[string]interface{}, error)
+}
+
+// ApplyWithNamedDisks sends the apply spec together with the CIDs of the
+// named disks of the instance by name.
+func (c persistentDiskAgentClient) ApplyWithNamedDisks(spec applyspec.ApplySpec, namedDisks map[string]string) error {
+	arguments := []interface{}{namedDiskApplySpec{ApplySpec: spec, PersistentDisks: namedDisks}}
+
+	var err error
+	if sender, ok := c.AgentClient.(asyncTaskSender); ok {
+		_, err = sender.sendAsyncTask("apply", arguments)
+	} else {
+		_, err = c.httpAgentClient.SendAsyncTaskMessage("apply", arguments)
+	}
+
+	return err
+}
+
+// DiskChecksum asks the agent for a checksum of the content of a mounted
+// disk, which is compared before and after migrating the disk.
+func (c persistentDiskAgentClient) DiskChecksum(diskCID string) (string, error) {
+	arguments := []interface{}{diskCID}
+
+	var value map[string]interface{}
+	var err error
+	if sender, ok := c.AgentClient.(asyncTaskSender); ok {
+		value, err = sender.sendAsyncTask("disk_checksum", arguments)
+	} else {
+		value, err = c.httpAgentClient.SendAsyncTaskMessage("disk_checksum", arguments)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	checksum, _ := value["result"].(string)
+	if checksum == "" {
+		return "", bosherr.Errorf("Unable to parse 'disk_checksum' response from the agent: %#v", value)
+	}
+
+	return checksum, nil
+}
+
+// RunScriptWithTimeout runs a lifecycle script like RunScript, but once the
+// timeout expires it cancels the agent task, which kills the script, and
+// waits for the agent to stop it.
+func (c persistentDiskAgentClient) RunScriptWithTimeout(scriptName string, options map[string]interface{}, timeout time.Duration) error {
+	scriptTimeoutClient := c.scriptTimeoutClient
+	scriptTimeoutClient.timeout = timeout
+
+	return scriptTimeoutClient.RunScript(scriptName, options)
+}
+
+// taskTimeoutAgentClient limits the asynchronous agent tasks. It polls the
+// tasks itself instead of the agent client, which polls a task until it
+// finishes, so that polling stops once the timeout expires. With
+// cancelOnTimeout, a task running longer is also cancelled on the agent.
+type taskTimeoutAgentClient struct {
+	agentclient.AgentClient
+	agentRequest        agentRequestSender
+	getTaskDelay        time.Duration
+	toleratedErrorCount int
+	timeout             time.Duration
+	cancelOnTimeout     bool
+	logger              boshlog.Logger
+	logTag              string
+}
+
+type agentRequestSender interface {
+	Send(method string, arguments []interface{}, response bihttpagent.Response) error
+}
+
+func (c taskTimeoutAgentClient) sendAsyncTask(method string, arguments []interface{}) (map[string]interface{}, error) {
+	timeout := time.NewTimer(c.timeout)
+	defer timeout.Stop()
+
+	var response bihttpagent.TaskResponse
+
+	err := c.agentRequest.Send(method, arguments, &response)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Sending '%s' to the agent", method)
+	}
+
+	agentTaskID, err := response.TaskID()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Getting agent task id")
+	}
+
+	sendErrors := 0
+
+	for {
+		var response bihttpagent.TaskResponse
+
+		err := c.agentRequest.Send("get_task", []interface{}{agentTaskID}, &response)
+		if err != nil {
+			sendErrors++
+			if sendErrors > c.toleratedErrorCount {
+				return nil, bosherr.WrapError(err, "Sending 'get_task' to the agent")
+			}
+			c.logger.Debug(c.logTag, "Error occurred sending get_task. Error retry %d of %d: %s", sendErrors, c.toleratedErrorCount, err.Error())
+		} else {
+			sendErrors = 0
+
+			taskState, err := response.TaskState()
+			if err != nil {
+				return nil, bosherr.WrapError(err, "Getting task state")
+			}
+
+			if taskState != "running" {
+				value, ok := response.Value.(map[string]interface{})
+				if !ok {
+					c.logger.Warn(c.logTag, "Unable to parse get_task response value: %#v", response.Value)
+				}
+				return value, nil
+			}
+		}
+
+		select {
+		case <-timeout.C:
+			err := bosherr.Errorf("Agent task '%s' did not finish within %s", method, c.timeout)
+
+			if c.cancelOnTimeout {
+				cancelErr := c.cancelTask(agentTaskID)
+				if cancelErr != nil {
+					err = bosherr.NewMultiError(err, cancelErr)
+				}
+			}
+
+			return nil, MbusError{Category: MbusTaskTimeoutError, Attempts: 1, Err: err}
+		case <-time.After(c.getTaskDelay):
+		}
+	}
+}
+
+// endedTaskResponse is a get_task response that does not fail when the
+// agent reports the error a task ended with, e.g. once it was cancelled.
+type endedTaskResponse struct {
+	bihttpagent.TaskResponse
+}
+
+func (r *endedTaskResponse) ServerError() error {
+	return nil
+}
+
+// cancelTask tells the agent to cancel a task, which kills the process it
+// runs, and polls the task until the agent stopped it.
+func (c taskTimeoutAgentClient) cancelTask(agentTaskID string) error {
+	var response valueResponse
+
+	err := c.agentRequest.Send("cancel_task", []interface{}{agentTaskID}, &response)
+	if err != nil {
+		return bosherr.WrapError(err, "Sending 'cancel_task' to the agent")
+	}
+
+	sendErrors := 0
+
+	for {
+		var response endedTaskResponse
+
+		err := c.agentRequest.Send("get_task", []interface{}{agentTaskID}, &response)
+		if err != nil {
+			sendErrors++
+			if sendErrors > c.toleratedErrorCount {
+				return bosherr.WrapError(err, "Sending 'get_task' to the agent")
+			}
+			c.logger.Debug(c.logTag, "Error occurred sending get_task. Error retry %d of %d: %s", sendErrors, c.toleratedErrorCount, err.Error())
+		} else {
+			sendErrors = 0
+
+			taskState, err := response.TaskState()
+			if err != nil {
+				return bosherr.WrapError(err, "Getting task state")
+			}
+
+			if taskState != "running" {
+				return nil
+			}
+		}
+
+		time.Sleep(c.getTaskDelay)
+	}
+}
+
+func (c taskTimeoutAgentClient) Stop() error {
+	_, err := c.sendAsyncTask("stop", []interface{}{})
+	return err
+}
+
+func (c taskTimeoutAgentClient) Apply(spec applyspec.ApplySpec) error {
+	_, err := c.sendAsyncTask("apply", []interface{}{spec})
+	return err
+}
+
+func (c taskTimeoutAgentClient) MountDisk(diskCID string) error {
+	_, err := c.sendAsyncTask("mount_disk", []interface{}{diskCID})
+	return err
+}
+
+func (c taskTimeoutAgentClient) UnmountDisk(diskCID string) error {
+	_, err := c.sendAsyncTask("unmount_disk", []interface{}{diskCID})
+	return err
+}
+
+func (c taskTimeoutAgentClient) MigrateDisk() error {
+	_, err := c.sendAsyncTask("migrate_disk", []interface{}{})
+	return err
+}
+
+func (c taskTimeoutAgentClient) RunScript(scriptName string, options map[string]interface{}) error {
+	_, err := c.sendAsyncTask("run_script", []interface{}{scriptName, options})
+
+	if err != nil && strings.Contains(err.Error(), "unknown message") {
+		// same as the agent client, for older stemcells
+		c.logger.Warn(c.logTag, "Ignoring run_script 'unknown message' error from the agent: %s. Received while trying to run: %s", err.Error(), scriptName)
+		return nil
+	}
+
+	return err
+}
+
+func (c taskTimeoutAgentClient) CompilePackage(packageSource agentclient.BlobRef, compiledPackageDependencies []agentclient.BlobRef) (agentclient.BlobRef, error) {
+	dependencies := make(map[string]bihttpagent.BlobRef, len(compiledPackageDependencies))
+	for _, dependency := range compiledPackageDependencies {
+		dependencies[dependency.Name] = bihttpagent.BlobRef{
+			Name:        dependency.Name,
+			Version:     dependency.Version,
+			SHA1:        dependency.SHA1,
+			BlobstoreID: dependency.BlobstoreID,
+		}
+	}
+
+	args := []interface{}{
+		packageSource.BlobstoreID,
+		packageSource.SHA1,
+		packageSource.Name,
+		packageSource.Version,
+		dependencies,
+	}
+
+	responseValue, err := c.sendAsyncTask("compile_package", args)
+	if err != nil {
+		return agentclient.BlobRef{}, err
+	}
+
+	result, _ := responseValue["result"].(map[string]interface{})
+	sha1, _ := result["sha1"].(string)
+	blobstoreID, _ := result["blobstore_id"].(string)
+
+	if sha1 == "" || blobstoreID == "" {
+		return agentclient.BlobRef{}, bosherr.Errorf("Unable to parse 'compile_package' response from the agent: %#v", responseValue)
+	}
+
+	return agentclient.BlobRef{
+		Name:        packageSource.Name,
+		Version:     packageSource.Version,
+		SHA1:        sha1,
+		BlobstoreID: blobstoreID,
+	}, nil
+}
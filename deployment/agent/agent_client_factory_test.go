@@ -0,0 +1,186 @@
+package agent_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/cloudfoundry/bosh-agent/agentclient"
+	"github.com/cloudfoundry/bosh-agent/agentclient/applyspec"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	. "github.com/cloudfoundry/bosh-cli/deployment/agent"
+)
+
+var _ = Describe("AgentClientFactory", func() {
+	var (
+		server *ghttp.Server
+		logger boshlog.Logger
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+
+		server.RouteToHandler("POST", "/agent", ghttp.RespondWith(http.StatusOK, `{"value":{"agent_task_id":"fake-task-id","state":"running"}}`))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("gives up on tasks running longer than the task timeout", func() {
+		opts := MbusOpts{TaskTimeout: 50 * time.Millisecond}
+
+		client, err := NewAgentClientFactory(time.Millisecond, opts, fakeuuid.NewFakeGenerator(), logger).NewAgentClient("fake-director-id", server.URL(), "")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = client.CompilePackage(agentclient.BlobRef{Name: "fake-package"}, nil)
+		Expect(err).To(HaveOccurred())
+		Expect(err).To(BeAssignableToTypeOf(MbusError{}))
+		Expect(err.(MbusError).Category).To(Equal(MbusTaskTimeoutError))
+		Expect(err.Error()).To(ContainSubstring("Agent task 'compile_package' did not finish within 50ms"))
+	})
+
+	It("stops polling the task once the task timeout expires", func() {
+		opts := MbusOpts{TaskTimeout: 20 * time.Millisecond}
+
+		client, err := NewAgentClientFactory(time.Millisecond, opts, fakeuuid.NewFakeGenerator(), logger).NewAgentClient("fake-director-id", server.URL(), "")
+		Expect(err).ToNot(HaveOccurred())
+
+		err = client.Apply(applyspec.ApplySpec{})
+		Expect(err).To(HaveOccurred())
+
+		requestCount := len(server.ReceivedRequests())
+		Consistently(func() int { return len(server.ReceivedRequests()) }, 50*time.Millisecond).Should(Equal(requestCount))
+	})
+
+	It("returns the result of tasks finishing within the task timeout", func() {
+		server.RouteToHandler("POST", "/agent", func(w http.ResponseWriter, r *http.Request) {
+			if len(server.ReceivedRequests()) == 1 {
+				w.Write([]byte(`{"value":{"agent_task_id":"fake-task-id","state":"running"}}`))
+				return
+			}
+			w.Write([]byte(`{"value":{"result":{"sha1":"fake-sha1","blobstore_id":"fake-blob-id"}}}`))
+		})
+
+		opts := MbusOpts{TaskTimeout: time.Minute}
+
+		client, err := NewAgentClientFactory(time.Millisecond, opts, fakeuuid.NewFakeGenerator(), logger).NewAgentClient("fake-director-id", server.URL(), "")
+		Expect(err).ToNot(HaveOccurred())
+
+		compiledPackageRef, err := client.CompilePackage(agentclient.BlobRef{Name: "fake-package", Version: "fake-version"}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(compiledPackageRef).To(Equal(agentclient.BlobRef{
+			Name:        "fake-package",
+			Version:     "fake-version",
+			SHA1:        "fake-sha1",
+			BlobstoreID: "fake-blob-id",
+		}))
+	})
+
+	It("cancels a script running longer than its timeout and waits for the agent to stop it", func() {
+		var methods []string
+		getTasksAfterCancel := 0
+		server.RouteToHandler("POST", "/agent", func(w http.ResponseWriter, r *http.Request) {
+			var request struct {
+				Method string `json:"method"`
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			Expect(json.Unmarshal(body, &request)).To(Succeed())
+			methods = append(methods, request.Method)
+
+			if request.Method == "cancel_task" {
+				w.Write([]byte(`{"value":"canceled"}`))
+				getTasksAfterCancel = 1
+				return
+			}
+
+			if getTasksAfterCancel > 0 {
+				getTasksAfterCancel++
+				if getTasksAfterCancel > 2 {
+					w.Write([]byte(`{"exception":{"message":"Task was cancelled"}}`))
+					return
+				}
+			}
+
+			w.Write([]byte(`{"value":{"agent_task_id":"fake-task-id","state":"running"}}`))
+		})
+
+		client, err := NewAgentClientFactory(time.Millisecond, MbusOpts{}, fakeuuid.NewFakeGenerator(), logger).NewAgentClient("fake-director-id", server.URL(), "")
+		Expect(err).ToNot(HaveOccurred())
+
+		runner, ok := client.(interface {
+			RunScriptWithTimeout(string, map[string]interface{}, time.Duration) error
+		})
+		Expect(ok).To(BeTrue())
+
+		err = runner.RunScriptWithTimeout("pre-start", map[string]interface{}{}, 20*time.Millisecond)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Agent task 'run_script' did not finish within 20ms"))
+
+		Expect(methods[0]).To(Equal("run_script"))
+		Expect(methods[len(methods)-3:]).To(Equal([]string{"cancel_task", "get_task", "get_task"}))
+
+		requestCount := len(server.ReceivedRequests())
+		Consistently(func() int { return len(server.ReceivedRequests()) }, 50*time.Millisecond).Should(Equal(requestCount))
+	})
+
+	It("sends the named disks of the instance with the apply spec", func() {
+		var requestBody []byte
+		server.RouteToHandler("POST", "/agent", func(w http.ResponseWriter, r *http.Request) {
+			if len(server.ReceivedRequests()) == 1 {
+				requestBody, _ = ioutil.ReadAll(r.Body)
+				w.Write([]byte(`{"value":{"agent_task_id":"fake-task-id","state":"running"}}`))
+				return
+			}
+			w.Write([]byte(`{"value":"applied"}`))
+		})
+
+		client, err := NewAgentClientFactory(time.Millisecond, MbusOpts{}, fakeuuid.NewFakeGenerator(), logger).NewAgentClient("fake-director-id", server.URL(), "")
+		Expect(err).ToNot(HaveOccurred())
+
+		applier, ok := client.(interface {
+			ApplyWithNamedDisks(applyspec.ApplySpec, map[string]string) error
+		})
+		Expect(ok).To(BeTrue())
+
+		err = applier.ApplyWithNamedDisks(applyspec.ApplySpec{Deployment: "fake-deployment-name"}, map[string]string{"fake-disk-name": "fake-disk-cid"})
+		Expect(err).ToNot(HaveOccurred())
+
+		var request struct {
+			Method    string                   `json:"method"`
+			Arguments []map[string]interface{} `json:"arguments"`
+		}
+		Expect(json.Unmarshal(requestBody, &request)).To(Succeed())
+		Expect(request.Method).To(Equal("apply"))
+		Expect(request.Arguments[0]["deployment"]).To(Equal("fake-deployment-name"))
+		Expect(request.Arguments[0]["persistent_disks"]).To(Equal(map[string]interface{}{"fake-disk-name": "fake-disk-cid"}))
+	})
+	It("asks the agent for the checksum of a disk", func() {
+		server.RouteToHandler("POST", "/agent", func(w http.ResponseWriter, r *http.Request) {
+			if len(server.ReceivedRequests()) == 1 {
+				w.Write([]byte(`{"value":{"agent_task_id":"fake-task-id","state":"running"}}`))
+				return
+			}
+			w.Write([]byte(`{"value":{"result":"fake-checksum"}}`))
+		})
+
+		client, err := NewAgentClientFactory(time.Millisecond, MbusOpts{}, fakeuuid.NewFakeGenerator(), logger).NewAgentClient("fake-director-id", server.URL(), "")
+		Expect(err).ToNot(HaveOccurred())
+
+		checksummer, ok := client.(interface {
+			DiskChecksum(string) (string, error)
+		})
+		Expect(ok).To(BeTrue())
+
+		checksum, err := checksummer.DiskChecksum("fake-disk-cid")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(checksum).To(Equal("fake-checksum"))
+	})
+})
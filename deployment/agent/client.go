@@ -0,0 +1,209 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bihttpagent "github.com/cloudfoundry/bosh-agent/agentclient/http"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	boshdir "github.com/cloudfoundry/bosh-cli/director"
+)
+
+// Client sends requests to the agent of the deployed VM that are not covered
+// by the agent client used while deploying.
+type Client interface {
+	SetUpSSH(user, publicKey string) (SSHResult, error)
+	CleanUpSSH(user string) error
+	FetchLogs(logType string, filters []string) (LogsResult, error)
+	State() (State, error)
+	RunErrand(jobName string) (ErrandResult, error)
+}
+
+type SSHResult struct {
+	Command       string `json:"command"`
+	Status        string `json:"status"`
+	IP            string `json:"ip"`
+	HostPublicKey string `json:"host_public_key"`
+}
+
+type LogsResult struct {
+	BlobstoreID string
+	SHA1        string
+}
+
+type ErrandResult struct {
+	ExitCode int
+	Stdout   string
+	Stderr   string
+}
+
+// State is the full state reported by the agent. Processes and vitals are
+// reported in the same format the director passes through for VMs.
+type State struct {
+	AgentID      string `json:"agent_id"`
+	BoshProtocol string `json:"bosh_protocol"`
+	JobState     string `json:"job_state"`
+
+	Job struct {
+		Name string `json:"name"`
+	} `json:"job"`
+	Index *int   `json:"index"`
+	ID    string `json:"id"`
+
+	Networks map[string]NetworkState `json:"networks"`
+
+	Processes []boshdir.VMInfoProcess `json:"processes"`
+	Vitals    boshdir.VMInfoVitals    `json:"vitals"`
+}
+
+type NetworkState struct {
+	IP string `json:"ip"`
+}
+
+type client struct {
+	agentClient *bihttpagent.AgentClient
+}
+
+func NewClient(agentClient *bihttpagent.AgentClient) Client {
+	return client{agentClient: agentClient}
+}
+
+func (c client) SetUpSSH(user, publicKey string) (SSHResult, error) {
+	params := map[string]interface{}{
+		"user":       user,
+		"public_key": publicKey,
+	}
+
+	var response sshResponse
+
+	err := c.agentClient.AgentRequest.Send("ssh", []interface{}{"setup", params}, &response)
+	if err != nil {
+		return SSHResult{}, bosherr.WrapError(err, "Sending 'ssh' setup to the agent")
+	}
+
+	if response.Value.Status != "success" {
+		return SSHResult{}, bosherr.Errorf("Setting up SSH user '%s' failed with status '%s'", user, response.Value.Status)
+	}
+
+	return response.Value, nil
+}
+
+func (c client) CleanUpSSH(user string) error {
+	params := map[string]interface{}{
+		"user_regex": fmt.Sprintf("^%s$", user),
+	}
+
+	var response sshResponse
+
+	err := c.agentClient.AgentRequest.Send("ssh", []interface{}{"cleanup", params}, &response)
+	if err != nil {
+		return bosherr.WrapError(err, "Sending 'ssh' cleanup to the agent")
+	}
+
+	return nil
+}
+
+// FetchLogs asks the agent to bundle the 'job' or 'agent' logs matching the
+// filters and upload them to its blobstore.
+func (c client) FetchLogs(logType string, filters []string) (LogsResult, error) {
+	if filters == nil {
+		filters = []string{}
+	}
+
+	value, err := c.agentClient.SendAsyncTaskMessage("fetch_logs", []interface{}{logType, filters})
+	if err != nil {
+		return LogsResult{}, err
+	}
+
+	blobstoreID, _ := value["blobstore_id"].(string)
+	if blobstoreID == "" {
+		return LogsResult{}, bosherr.Errorf("Expected agent to return blobstore ID of fetched logs, got '%#v'", value)
+	}
+
+	sha1, _ := value["sha1"].(string)
+
+	return LogsResult{BlobstoreID: blobstoreID, SHA1: sha1}, nil
+}
+
+func (c client) State() (State, error) {
+	var response stateResponse
+
+	err := c.agentClient.AgentRequest.Send("get_state", []interface{}{"full"}, &response)
+	if err != nil {
+		return State{}, bosherr.WrapError(err, "Sending 'get_state' to the agent")
+	}
+
+	return response.Value, nil
+}
+
+// RunErrand asks the agent to run the bin/run script of an installed release
+// job and waits for it to exit. The agent returns the end of the output.
+func (c client) RunErrand(jobName string) (ErrandResult, error) {
+	value, err := c.agentClient.SendAsyncTaskMessage("run_errand", []interface{}{jobName})
+	if err != nil {
+		return ErrandResult{}, err
+	}
+
+	exitCode, ok := value["exit_code"].(float64)
+	if !ok {
+		return ErrandResult{}, bosherr.Errorf("Expected agent to return exit code of errand, got '%#v'", value)
+	}
+
+	stdout, _ := value["stdout"].(string)
+	stderr, _ := value["stderr"].(string)
+
+	return ErrandResult{ExitCode: int(exitCode), Stdout: stdout, Stderr: stderr}, nil
+}
+
+type exception struct {
+	Message string
+}
+
+type sshResponse struct {
+	Value     SSHResult
+	Exception *exception
+}
+
+func (r *sshResponse) ServerError() error {
+	if r.Exception != nil {
+		return bosherr.Errorf("Agent responded with error: %s", r.Exception.Message)
+	}
+	return nil
+}
+
+func (r *sshResponse) Unmarshal(message []byte) error {
+	return json.Unmarshal(message, r)
+}
+
+type stateResponse struct {
+	Value     State
+	Exception *exception
+}
+
+func (r *stateResponse) ServerError() error {
+	if r.Exception != nil {
+		return bosherr.Errorf("Agent responded with error: %s", r.Exception.Message)
+	}
+	return nil
+}
+
+func (r *stateResponse) Unmarshal(message []byte) error {
+	return json.Unmarshal(message, r)
+}
+
+type valueResponse struct {
+	Value     interface{}
+	Exception *exception
+}
+
+func (r *valueResponse) ServerError() error {
+	if r.Exception != nil {
+		return bosherr.Errorf("Agent responded with error: %s", r.Exception.Message)
+	}
+	return nil
+}
+
+func (r *valueResponse) Unmarshal(message []byte) error {
+	return json.Unmarshal(message, r)
+}
@@ -0,0 +1,42 @@
+package agent
+
+import (
+	"time"
+
+	bihttpagent "github.com/cloudfoundry/bosh-agent/agentclient/http"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+type ClientFactory interface {
+	NewClient(directorID, mbusURL, caCert string) (Client, error)
+}
+
+type clientFactory struct {
+	getTaskDelay time.Duration
+	opts         MbusOpts
+	logger       boshlog.Logger
+}
+
+func NewClientFactory(getTaskDelay time.Duration, opts MbusOpts, logger boshlog.Logger) ClientFactory {
+	return clientFactory{
+		getTaskDelay: getTaskDelay,
+		opts:         opts,
+		logger:       logger,
+	}
+}
+
+func (f clientFactory) NewClient(directorID, mbusURL, caCert string) (Client, error) {
+	if IsNATSURL(mbusURL) {
+		return nil, bosherr.Errorf("Sending agent requests requires an https:// mbus URL, got a nats:// mbus URL")
+	}
+
+	httpClient, err := NewMbusHTTPClient(caCert, f.opts, f.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	agentClient := bihttpagent.NewAgentClient(mbusURL, directorID, f.getTaskDelay, 10, httpClient, f.logger)
+
+	return NewClient(agentClient.(*bihttpagent.AgentClient)), nil
+}
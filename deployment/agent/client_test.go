@@ -0,0 +1,224 @@
+package agent_test
+
+import (
+	"net/http"
+	"time"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	. "github.com/cloudfoundry/bosh-cli/deployment/agent"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		server *ghttp.Server
+		client Client
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+
+		var err error
+		client, err = NewClientFactory(time.Millisecond, MbusOpts{}, logger).NewClient("fake-director-id", server.URL(), "")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("SetUpSSH", func() {
+		It("sets up the user through the agent and returns the connection details", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/agent"),
+					ghttp.VerifyBody([]byte(`{"method":"ssh","arguments":["setup",{"public_key":"fake-public-key","user":"fake-user"}],"reply_to":"fake-director-id"}`)),
+					ghttp.RespondWith(http.StatusOK, `{"value":{
+						"command": "setup",
+						"status": "success",
+						"ip": "10.0.0.6",
+						"host_public_key": "fake-host-public-key"
+					}}`),
+				),
+			)
+
+			result, err := client.SetUpSSH("fake-user", "fake-public-key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(SSHResult{
+				Command:       "setup",
+				Status:        "success",
+				IP:            "10.0.0.6",
+				HostPublicKey: "fake-host-public-key",
+			}))
+		})
+
+		It("returns an error when the agent does not report success", func() {
+			server.AppendHandlers(
+				ghttp.RespondWith(http.StatusOK, `{"value":{"command":"setup","status":"failure"}}`),
+			)
+
+			_, err := client.SetUpSSH("fake-user", "fake-public-key")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Setting up SSH user 'fake-user' failed with status 'failure'"))
+		})
+
+		It("returns an error when the agent responds with an exception", func() {
+			server.AppendHandlers(
+				ghttp.RespondWith(http.StatusOK, `{"exception":{"message":"fake-agent-error"}}`),
+			)
+
+			_, err := client.SetUpSSH("fake-user", "fake-public-key")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-agent-error"))
+		})
+	})
+
+	Describe("CleanUpSSH", func() {
+		It("removes the user through the agent", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/agent"),
+					ghttp.VerifyBody([]byte(`{"method":"ssh","arguments":["cleanup",{"user_regex":"^fake-user$"}],"reply_to":"fake-director-id"}`)),
+					ghttp.RespondWith(http.StatusOK, `{"value":{"command":"cleanup","status":"success"}}`),
+				),
+			)
+
+			err := client.CleanUpSSH("fake-user")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns an error when the request fails", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusInternalServerError, ""))
+
+			err := client.CleanUpSSH("fake-user")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Sending 'ssh' cleanup to the agent"))
+		})
+	})
+
+	Describe("FetchLogs", func() {
+		It("waits for the agent to upload the logs and returns their blob", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/agent"),
+					ghttp.VerifyBody([]byte(`{"method":"fetch_logs","arguments":["job",["nginx/**/*"]],"reply_to":"fake-director-id"}`)),
+					ghttp.RespondWith(http.StatusOK, `{"value":{"agent_task_id":"fake-task-id","state":"running"}}`),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyBody([]byte(`{"method":"get_task","arguments":["fake-task-id"],"reply_to":"fake-director-id"}`)),
+					ghttp.RespondWith(http.StatusOK, `{"value":{"agent_task_id":"fake-task-id","state":"running"}}`),
+				),
+				ghttp.RespondWith(http.StatusOK, `{"value":{"blobstore_id":"fake-blob-id","sha1":"fake-sha1"}}`),
+			)
+
+			result, err := client.FetchLogs("job", []string{"nginx/**/*"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(LogsResult{BlobstoreID: "fake-blob-id", SHA1: "fake-sha1"}))
+		})
+
+		It("sends empty filters when none are given", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyBody([]byte(`{"method":"fetch_logs","arguments":["agent",[]],"reply_to":"fake-director-id"}`)),
+					ghttp.RespondWith(http.StatusOK, `{"value":{"agent_task_id":"fake-task-id","state":"running"}}`),
+				),
+				ghttp.RespondWith(http.StatusOK, `{"value":{"blobstore_id":"fake-blob-id","sha1":"fake-sha1"}}`),
+			)
+
+			_, err := client.FetchLogs("agent", nil)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns an error when the agent does not return a blob", func() {
+			server.AppendHandlers(
+				ghttp.RespondWith(http.StatusOK, `{"value":{"agent_task_id":"fake-task-id","state":"running"}}`),
+				ghttp.RespondWith(http.StatusOK, `{"value":"stopped"}`),
+			)
+
+			_, err := client.FetchLogs("job", nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Expected agent to return blobstore ID of fetched logs"))
+		})
+	})
+
+	Describe("RunErrand", func() {
+		It("waits for the errand to exit and returns its output", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/agent"),
+					ghttp.VerifyBody([]byte(`{"method":"run_errand","arguments":["smoke-tests"],"reply_to":"fake-director-id"}`)),
+					ghttp.RespondWith(http.StatusOK, `{"value":{"agent_task_id":"fake-task-id","state":"running"}}`),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyBody([]byte(`{"method":"get_task","arguments":["fake-task-id"],"reply_to":"fake-director-id"}`)),
+					ghttp.RespondWith(http.StatusOK, `{"value":{"agent_task_id":"fake-task-id","state":"running"}}`),
+				),
+				ghttp.RespondWith(http.StatusOK, `{"value":{"exit_code":1,"stdout":"fake-stdout","stderr":"fake-stderr"}}`),
+			)
+
+			result, err := client.RunErrand("smoke-tests")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(ErrandResult{ExitCode: 1, Stdout: "fake-stdout", Stderr: "fake-stderr"}))
+		})
+
+		It("returns an error when the agent does not return an exit code", func() {
+			server.AppendHandlers(
+				ghttp.RespondWith(http.StatusOK, `{"value":{"agent_task_id":"fake-task-id","state":"running"}}`),
+				ghttp.RespondWith(http.StatusOK, `{"value":"stopped"}`),
+			)
+
+			_, err := client.RunErrand("smoke-tests")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Expected agent to return exit code of errand"))
+		})
+	})
+
+	Describe("State", func() {
+		It("returns the full state of the agent", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyBody([]byte(`{"method":"get_state","arguments":["full"],"reply_to":"fake-director-id"}`)),
+					ghttp.RespondWith(http.StatusOK, `{"value":{
+						"agent_id": "fake-agent-id",
+						"bosh_protocol": "1",
+						"job_state": "running",
+						"job": {"name": "bosh"},
+						"index": 0,
+						"networks": {"default": {"ip": "10.0.0.6"}},
+						"processes": [{"name": "nats", "state": "running", "uptime": {"secs": 10}}],
+						"vitals": {"load": ["0.01", "0.02", "0.03"]}
+					}}`),
+				),
+			)
+
+			state, err := client.State()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(state.AgentID).To(Equal("fake-agent-id"))
+			Expect(state.BoshProtocol).To(Equal("1"))
+			Expect(state.JobState).To(Equal("running"))
+			Expect(state.Job.Name).To(Equal("bosh"))
+			Expect(*state.Index).To(Equal(0))
+			Expect(state.Networks["default"].IP).To(Equal("10.0.0.6"))
+			Expect(state.Processes).To(HaveLen(1))
+			Expect(state.Processes[0].Name).To(Equal("nats"))
+			Expect(state.Processes[0].State).To(Equal("running"))
+			Expect(*state.Processes[0].Uptime.Seconds).To(Equal(uint64(10)))
+			Expect(state.Vitals.Load).To(Equal([]string{"0.01", "0.02", "0.03"}))
+		})
+
+		It("returns an error when the agent responds with an exception", func() {
+			server.AppendHandlers(
+				ghttp.RespondWith(http.StatusOK, `{"exception":{"message":"fake-agent-error"}}`),
+			)
+
+			_, err := client.State()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-agent-error"))
+		})
+	})
+})
@@ -0,0 +1,250 @@
+package agent
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudfoundry/bosh-utils/crypto"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"github.com/cloudfoundry/bosh-utils/httpclient"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// MbusOpts configures requests to the agent over its HTTPS mbus. Zero values
+// fall back to the defaults below.
+type MbusOpts struct {
+	// Attempts is the number of attempts of a request failing with a
+	// connection or timeout error before it was written to the agent. Ping
+	// and get_task requests are sent once, as their callers already retry
+	// them.
+	Attempts int
+
+	// RetryDelay is the delay before the first retry. It doubles for every
+	// further retry up to MaxRetryDelay.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+
+	// RequestTimeout limits a single request, including the polls of
+	// running tasks.
+	RequestTimeout time.Duration
+
+	// TaskTimeout limits long running tasks such as compile_package from
+	// sending the task until the agent finishes it. Zero means no limit.
+	TaskTimeout time.Duration
+}
+
+const (
+	defaultMbusAttempts       = 5
+	defaultMbusRetryDelay     = 1 * time.Second
+	defaultMbusMaxRetryDelay  = 30 * time.Second
+	defaultMbusRequestTimeout = 60 * time.Second
+)
+
+func (o MbusOpts) withDefaults() MbusOpts {
+	if o.Attempts <= 0 {
+		o.Attempts = defaultMbusAttempts
+	}
+	if o.RetryDelay <= 0 {
+		o.RetryDelay = defaultMbusRetryDelay
+	}
+	if o.MaxRetryDelay <= 0 {
+		o.MaxRetryDelay = defaultMbusMaxRetryDelay
+	}
+	if o.RequestTimeout <= 0 {
+		o.RequestTimeout = defaultMbusRequestTimeout
+	}
+	return o
+}
+
+// MbusErrorCategory tells apart why a request to the agent failed.
+type MbusErrorCategory string
+
+const (
+	MbusConnectionError  MbusErrorCategory = "connection"
+	MbusTimeoutError     MbusErrorCategory = "timeout"
+	MbusTLSError         MbusErrorCategory = "tls"
+	MbusResponseError    MbusErrorCategory = "response"
+	MbusTaskTimeoutError MbusErrorCategory = "task timeout"
+)
+
+// MbusError is returned when a request to the agent fails. Connection and
+// timeout errors raised before the request was written are only returned
+// once all attempts failed.
+type MbusError struct {
+	Category MbusErrorCategory
+	Attempts int
+	Err      error
+}
+
+func (e MbusError) Error() string {
+	return fmt.Sprintf("Agent mbus %s error after %d attempt(s): %s", e.Category, e.Attempts, e.Err.Error())
+}
+
+// NewMbusHTTPClient returns an HTTP client for the agent mbus that keeps
+// connections alive between requests and retries requests that could not be
+// sent because of transient network failures, which are common while the VM
+// is booting.
+func NewMbusHTTPClient(caCert string, opts MbusOpts, logger boshlog.Logger) (httpclient.HTTPClient, error) {
+	opts = opts.withDefaults()
+
+	var caCertPool *x509.CertPool
+
+	if caCert != "" {
+		var err error
+		caCertPool, err = crypto.CertPoolFromPEM([]byte(caCert))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	// same as the default bosh-utils client, but with keep-alive
+	transport := &http.Transport{
+		TLSNextProto: map[string]func(authority string, c *tls.Conn) http.RoundTripper{},
+		TLSClientConfig: &tls.Config{
+			RootCAs:            caCertPool,
+			InsecureSkipVerify: caCertPool == nil,
+		},
+
+		Proxy: http.ProxyFromEnvironment,
+		Dial:  httpclient.SOCKS5DialFuncFromEnvironment(dialer.Dial),
+
+		TLSHandshakeTimeout: 30 * time.Second,
+		MaxIdleConnsPerHost: 2,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	retryingClient := retryingClient{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   opts.RequestTimeout,
+		},
+		opts:   opts,
+		logger: logger,
+		logTag: "mbusClient",
+	}
+
+	return httpclient.NewHTTPClient(retryingClient, logger), nil
+}
+
+type retryingClient struct {
+	client httpclient.Client
+	opts   MbusOpts
+	logger boshlog.Logger
+	logTag string
+}
+
+// singleAttemptMethods are sent once, as the ping is repeated until the
+// agent is ready and get_task is repeated until the task finishes.
+var singleAttemptMethods = map[string]bool{
+	"ping":     true,
+	"get_task": true,
+}
+
+func (c retryingClient) Do(request *http.Request) (*http.Response, error) {
+	var body []byte
+
+	if request.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(request.Body)
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Reading request body")
+		}
+		_ = request.Body.Close()
+	}
+
+	attempts := c.opts.Attempts
+	if singleAttemptMethods[agentMethod(body)] {
+		attempts = 1
+	}
+
+	delay := c.opts.RetryDelay
+
+	for attempt := 1; ; attempt++ {
+		var written int32
+
+		trace := &httptrace.ClientTrace{
+			WroteRequest: func(httptrace.WroteRequestInfo) { atomic.StoreInt32(&written, 1) },
+		}
+
+		attemptRequest := request.WithContext(httptrace.WithClientTrace(request.Context(), trace))
+		if body != nil {
+			attemptRequest.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		response, err := c.client.Do(attemptRequest)
+		if mbusErr, ok := err.(MbusError); ok {
+			// already categorized by the underlying client, e.g. a NATS protocol error
+			return nil, mbusErr
+		}
+
+		if err == nil {
+			return response, nil
+		}
+
+		category := categorizeMbusError(err)
+
+		// the agent may have received a request that failed once it was
+		// written, and tasks such as apply or compile_package must not run twice
+		if atomic.LoadInt32(&written) == 1 || category == MbusTLSError || attempt >= attempts {
+			return nil, MbusError{Category: category, Attempts: attempt, Err: err}
+		}
+
+		c.logger.Debug(c.logTag, "Retrying request to the agent in %s after %s error (attempt %d of %d): %s",
+			delay, category, attempt, attempts, err.Error())
+
+		time.Sleep(delay)
+
+		delay *= 2
+		if delay > c.opts.MaxRetryDelay {
+			delay = c.opts.MaxRetryDelay
+		}
+	}
+}
+
+func agentMethod(body []byte) string {
+	var message struct {
+		Method string `json:"method"`
+	}
+
+	_ = json.Unmarshal(body, &message)
+
+	return message.Method
+}
+
+func categorizeMbusError(err error) MbusErrorCategory {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return MbusTimeoutError
+	}
+
+	switch err.(type) {
+	case x509.UnknownAuthorityError, x509.HostnameError, x509.CertificateInvalidError, tls.RecordHeaderError:
+		return MbusTLSError
+	}
+
+	// newer Go versions wrap certificate errors
+	if strings.Contains(err.Error(), "x509: ") {
+		return MbusTLSError
+	}
+
+	return MbusConnectionError
+}
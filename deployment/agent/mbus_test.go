@@ -0,0 +1,110 @@
+package agent_test
+
+import (
+	"net/http"
+	"time"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	. "github.com/cloudfoundry/bosh-cli/deployment/agent"
+)
+
+var _ = Describe("NewMbusHTTPClient", func() {
+	var (
+		server *ghttp.Server
+		opts   MbusOpts
+		logger boshlog.Logger
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		opts = MbusOpts{Attempts: 3, RetryDelay: time.Millisecond}
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("returns error responses without retrying them", func() {
+		server.AppendHandlers(ghttp.RespondWith(http.StatusServiceUnavailable, ""))
+
+		client, err := NewMbusHTTPClient("", opts, logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		response, err := client.Post(server.URL()+"/agent", []byte(`{"method":"apply"}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(response.StatusCode).To(Equal(http.StatusServiceUnavailable))
+		Expect(server.ReceivedRequests()).To(HaveLen(1))
+	})
+
+	It("does not resend requests failing after they were written", func() {
+		server.AppendHandlers(func(w http.ResponseWriter, r *http.Request) {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			Expect(err).ToNot(HaveOccurred())
+			conn.Close()
+		})
+
+		client, err := NewMbusHTTPClient("", opts, logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = client.Post(server.URL()+"/agent", []byte(`{"method":"apply"}`))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Agent mbus connection error after 1 attempt(s)"))
+		Expect(server.ReceivedRequests()).To(HaveLen(1))
+	})
+
+	It("returns a connection error once all attempts failed to connect", func() {
+		closedServer := ghttp.NewServer()
+		url := closedServer.URL()
+		closedServer.Close()
+
+		client, err := NewMbusHTTPClient("", opts, logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = client.Post(url+"/agent", []byte(`{}`))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Agent mbus connection error after 3 attempt(s)"))
+	})
+
+	It("sends ping and get_task requests once, as their callers retry them", func() {
+		closedServer := ghttp.NewServer()
+		url := closedServer.URL()
+		closedServer.Close()
+
+		client, err := NewMbusHTTPClient("", opts, logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = client.Post(url+"/agent", []byte(`{"method":"ping","arguments":[]}`))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Agent mbus connection error after 1 attempt(s)"))
+
+		_, err = client.Post(url+"/agent", []byte(`{"method":"get_task","arguments":["fake-task-id"]}`))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Agent mbus connection error after 1 attempt(s)"))
+	})
+
+	It("returns a timeout error when the agent does not respond in time", func() {
+		opts.Attempts = 1
+		opts.RequestTimeout = 10 * time.Millisecond
+
+		server.AppendHandlers(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(50 * time.Millisecond)
+		})
+
+		client, err := NewMbusHTTPClient("", opts, logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = client.Post(server.URL()+"/agent", []byte(`{}`))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Agent mbus timeout error after 1 attempt(s)"))
+	})
+
+	It("returns an error when the CA certificate is invalid", func() {
+		_, err := NewMbusHTTPClient("fake-ca-cert", opts, logger)
+		Expect(err).To(HaveOccurred())
+	})
+})
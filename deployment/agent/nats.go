@@ -0,0 +1,527 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/bosh-utils/crypto"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+)
+
+const defaultNATSPort = "4222"
+
+// IsNATSURL reports whether agents are reached through a NATS server
+// instead of the HTTPS mbus of the agent.
+func IsNATSURL(mbusURL string) bool {
+	return strings.HasPrefix(mbusURL, "nats://")
+}
+
+// NATSClient sends agent requests through a NATS server. It stands in for the
+// HTTP client of the agent client so that the agent client works the same
+// with both kinds of mbus. Requests are published to the subject of the
+// agent, which is only known once the VM is created. All requests share one
+// connection, on which the client subscribes to its own inbox, and replies
+// are handed to the request waiting for them by their reply subject.
+type NATSClient struct {
+	address    string
+	host       string
+	user       string
+	password   string
+	caCertPool *x509.CertPool
+	directorID string
+	timeout    time.Duration
+	uuidGen    boshuuid.Generator
+	logger     boshlog.Logger
+	logTag     string
+
+	agentIDLock sync.RWMutex
+	agentID     string
+
+	connLock sync.Mutex
+	conn     *natsConn
+}
+
+// NewNATSClient takes the credentials from the user info of the NATS URL. The
+// connection to the NATS server is upgraded to TLS when the server requires it
+// or when a CA certificate is given. A server requiring TLS is only trusted
+// with a CA certificate.
+func NewNATSClient(
+	mbusURL string,
+	directorID string,
+	caCert string,
+	timeout time.Duration,
+	uuidGen boshuuid.Generator,
+	logger boshlog.Logger,
+) (*NATSClient, error) {
+	parsedURL, err := url.Parse(mbusURL)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Parsing NATS URL")
+	}
+
+	host, port, err := net.SplitHostPort(parsedURL.Host)
+	if err != nil {
+		host, port = parsedURL.Host, defaultNATSPort
+	}
+
+	client := &NATSClient{
+		address:    net.JoinHostPort(host, port),
+		host:       host,
+		directorID: directorID,
+		timeout:    timeout,
+		uuidGen:    uuidGen,
+		logger:     logger,
+		logTag:     "natsClient",
+	}
+
+	if parsedURL.User != nil {
+		client.user = parsedURL.User.Username()
+		client.password, _ = parsedURL.User.Password()
+	}
+
+	if caCert != "" {
+		client.caCertPool, err = crypto.CertPoolFromPEM([]byte(caCert))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+// SetAgentID sets the ID of the agent that requests are sent to.
+func (c *NATSClient) SetAgentID(agentID string) {
+	c.agentIDLock.Lock()
+	defer c.agentIDLock.Unlock()
+
+	c.agentID = agentID
+}
+
+func (c *NATSClient) currentAgentID() string {
+	c.agentIDLock.RLock()
+	defer c.agentIDLock.RUnlock()
+
+	return c.agentID
+}
+
+// Do sends the body of a POST request to the agent and returns the reply of
+// the agent as the body of the response.
+func (c *NATSClient) Do(request *http.Request) (*http.Response, error) {
+	if request.Method != "POST" {
+		return nil, bosherr.Errorf("Sending %s requests over NATS is not supported", request.Method)
+	}
+
+	agentID := c.currentAgentID()
+	if agentID == "" {
+		err := bosherr.Error("Agent ID is unknown. The deployment state does not record the agent ID of VMs created by older CLI versions, recreate the VM or use the HTTPS mbus of the agent")
+		return nil, MbusError{Category: MbusConnectionError, Attempts: 1, Err: err}
+	}
+
+	payload, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading request body")
+	}
+
+	conn, err := c.connection()
+	if err != nil {
+		return nil, err
+	}
+
+	// the agent replies to the subject in the message instead of the NATS reply subject
+	replyID, err := c.uuidGen.Generate()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating reply subject")
+	}
+
+	replySubject := conn.inbox + "." + replyID
+
+	var message map[string]interface{}
+
+	err = json.Unmarshal(payload, &message)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshalling agent request")
+	}
+
+	message["reply_to"] = replySubject
+
+	payload, err = json.Marshal(message)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Marshalling agent request")
+	}
+
+	c.logger.Debug(c.logTag, "Sending request to agent '%s' through NATS server '%s'", agentID, c.address)
+
+	reply, err := conn.request("agent."+agentID, replySubject, payload, c.timeout, httptrace.ContextClientTrace(request.Context()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(bytes.NewReader(reply)),
+	}, nil
+}
+
+// connection returns the connection of the client, connecting again when the
+// last one failed.
+func (c *NATSClient) connection() (*natsConn, error) {
+	c.connLock.Lock()
+	defer c.connLock.Unlock()
+
+	if c.conn != nil && !c.conn.failed() {
+		return c.conn, nil
+	}
+
+	inboxID, err := c.uuidGen.Generate()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating inbox subject")
+	}
+
+	conn, err := c.connect(fmt.Sprintf("director.%s.%s", c.directorID, inboxID))
+	if err != nil {
+		return nil, err
+	}
+
+	c.conn = conn
+
+	return conn, nil
+}
+
+type natsServerInfo struct {
+	TLSRequired bool `json:"tls_required"`
+}
+
+type natsConnectOptions struct {
+	Verbose     bool   `json:"verbose"`
+	Pedantic    bool   `json:"pedantic"`
+	TLSRequired bool   `json:"tls_required"`
+	User        string `json:"user,omitempty"`
+	Pass        string `json:"pass,omitempty"`
+	Name        string `json:"name"`
+	Lang        string `json:"lang"`
+}
+
+// connect connects and subscribes to the inbox. The PING after CONNECT makes
+// the server report authorization errors before the first request.
+func (c *NATSClient) connect(inbox string) (*natsConn, error) {
+	var conn net.Conn
+
+	conn, err := net.DialTimeout("tcp", c.address, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	connected := false
+	defer func() {
+		if !connected {
+			_ = conn.Close()
+		}
+	}()
+
+	err = conn.SetDeadline(time.Now().Add(c.timeout))
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+
+	line, err := readNATSLine(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(line, "INFO ") {
+		return nil, natsProtocolError(bosherr.Errorf("Expected INFO from NATS server, got '%s'", line))
+	}
+
+	var info natsServerInfo
+
+	err = json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info)
+	if err != nil {
+		return nil, natsProtocolError(bosherr.WrapError(err, "Unmarshalling NATS server info"))
+	}
+
+	useTLS := info.TLSRequired || c.caCertPool != nil
+
+	if useTLS {
+		if c.caCertPool == nil {
+			return nil, MbusError{
+				Category: MbusTLSError,
+				Attempts: 1,
+				Err:      bosherr.Errorf("NATS server '%s' requires TLS, but no CA certificate is given to verify it", c.address),
+			}
+		}
+
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName: c.host,
+			RootCAs:    c.caCertPool,
+		})
+
+		err = tlsConn.Handshake()
+		if err != nil {
+			return nil, err
+		}
+
+		conn = tlsConn
+		reader = bufio.NewReader(conn)
+	}
+
+	connectOptions, err := json.Marshal(natsConnectOptions{
+		TLSRequired: useTLS,
+		User:        c.user,
+		Pass:        c.password,
+		Name:        "bosh-cli",
+		Lang:        "go",
+	})
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Marshalling NATS connect options")
+	}
+
+	var commands bytes.Buffer
+	fmt.Fprintf(&commands, "CONNECT %s\r\n", connectOptions)
+	fmt.Fprintf(&commands, "SUB %s.* 1\r\n", inbox)
+	fmt.Fprint(&commands, "PING\r\n")
+
+	_, err = conn.Write(commands.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		line, err := readNATSLine(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		if line == "PONG" {
+			break
+		}
+
+		if strings.HasPrefix(line, "-ERR") {
+			return nil, natsServerError(line)
+		}
+	}
+
+	err = conn.SetDeadline(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	natsConn := &natsConn{
+		conn:    conn,
+		inbox:   inbox,
+		pending: map[string]chan []byte{},
+		closed:  make(chan struct{}),
+		logger:  c.logger,
+		logTag:  c.logTag,
+	}
+
+	go natsConn.read(reader)
+
+	connected = true
+
+	return natsConn, nil
+}
+
+// natsConn is a connection to the NATS server shared by the requests of a
+// client. It is closed once reading or writing fails, which fails the
+// requests waiting for a reply.
+type natsConn struct {
+	conn      net.Conn
+	inbox     string
+	writeLock sync.Mutex
+
+	lock    sync.Mutex
+	pending map[string]chan []byte
+	err     error
+	closed  chan struct{}
+
+	logger boshlog.Logger
+	logTag string
+}
+
+func (c *natsConn) request(subject, replySubject string, payload []byte, timeout time.Duration, trace *httptrace.ClientTrace) ([]byte, error) {
+	replyCh := make(chan []byte, 1)
+
+	c.lock.Lock()
+	c.pending[replySubject] = replyCh
+	c.lock.Unlock()
+
+	defer func() {
+		c.lock.Lock()
+		delete(c.pending, replySubject)
+		c.lock.Unlock()
+	}()
+
+	err := c.write(fmt.Sprintf("PUB %s %d\r\n%s\r\n", subject, len(payload), payload))
+	if err != nil {
+		return nil, err
+	}
+
+	// like the HTTP transport, tell the retrying client that the agent may
+	// have received the request
+	if trace != nil && trace.WroteRequest != nil {
+		trace.WroteRequest(httptrace.WroteRequestInfo{})
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case reply := <-replyCh:
+		return reply, nil
+
+	case <-c.closed:
+		return nil, c.closeErr()
+
+	case <-timer.C:
+		return nil, natsTimeoutError{timeout: timeout}
+	}
+}
+
+func (c *natsConn) write(commands string) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	_, err := c.conn.Write([]byte(commands))
+	if err != nil {
+		c.close(err)
+		return err
+	}
+
+	return nil
+}
+
+// read hands the replies to the waiting requests and answers the pings of the
+// server until the connection fails.
+func (c *natsConn) read(reader *bufio.Reader) {
+	for {
+		line, err := readNATSLine(reader)
+		if err != nil {
+			c.close(err)
+			return
+		}
+
+		switch {
+		case strings.HasPrefix(line, "MSG "):
+			payload, err := readNATSMessage(reader, line)
+			if err != nil {
+				c.close(err)
+				return
+			}
+
+			c.deliver(strings.Fields(line)[1], payload)
+
+		case line == "PING":
+			err = c.write("PONG\r\n")
+			if err != nil {
+				return
+			}
+
+		case strings.HasPrefix(line, "-ERR"):
+			c.close(natsServerError(line))
+			return
+		}
+	}
+}
+
+func (c *natsConn) deliver(subject string, payload []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	replyCh, found := c.pending[subject]
+	if !found {
+		c.logger.Debug(c.logTag, "Dropping NATS message on '%s' without a waiting request", subject)
+		return
+	}
+
+	replyCh <- payload
+	delete(c.pending, subject)
+}
+
+func (c *natsConn) close(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.err != nil {
+		return
+	}
+
+	c.err = err
+	close(c.closed)
+
+	_ = c.conn.Close()
+}
+
+func (c *natsConn) closeErr() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.err
+}
+
+func (c *natsConn) failed() bool {
+	return c.closeErr() != nil
+}
+
+type natsTimeoutError struct {
+	timeout time.Duration
+}
+
+func (e natsTimeoutError) Error() string {
+	return fmt.Sprintf("Agent did not reply through NATS within %s", e.timeout)
+}
+
+func (e natsTimeoutError) Timeout() bool   { return true }
+func (e natsTimeoutError) Temporary() bool { return true }
+
+func readNATSLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readNATSMessage reads the payload of 'MSG <subject> <sid> [reply-to] <bytes>'.
+func readNATSMessage(reader *bufio.Reader, line string) ([]byte, error) {
+	fields := strings.Fields(line)
+
+	size, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		return nil, natsProtocolError(bosherr.WrapErrorf(err, "Parsing NATS message '%s'", line))
+	}
+
+	payload := make([]byte, size+2)
+
+	_, err = io.ReadFull(reader, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return payload[:size], nil
+}
+
+func natsServerError(line string) error {
+	return natsProtocolError(bosherr.Errorf("NATS server responded with error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR"))))
+}
+
+func natsProtocolError(err error) error {
+	return MbusError{Category: MbusResponseError, Attempts: 1, Err: err}
+}
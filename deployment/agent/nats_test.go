@@ -0,0 +1,244 @@
+package agent_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/deployment/agent"
+)
+
+// fakeNATSServer accepts a single connection and replies to every published
+// message.
+type fakeNATSServer struct {
+	listener    net.Listener
+	reply       string
+	errLine     string
+	tlsRequired bool
+
+	connect chan string
+	subject chan string
+	payload chan map[string]interface{}
+}
+
+func newFakeNATSServer() *fakeNATSServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).ToNot(HaveOccurred())
+
+	return &fakeNATSServer{
+		listener: listener,
+		connect:  make(chan string, 1),
+		subject:  make(chan string, 2),
+		payload:  make(chan map[string]interface{}, 2),
+	}
+}
+
+func (s *fakeNATSServer) URL(userInfo string) string {
+	return fmt.Sprintf("nats://%s%s", userInfo, s.listener.Addr().String())
+}
+
+func (s *fakeNATSServer) Serve() {
+	defer GinkgoRecover()
+
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"fake-server\",\"tls_required\":%t}\r\n", s.tlsRequired)
+
+	reader := bufio.NewReader(conn)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+
+		switch fields[0] {
+		case "CONNECT":
+			s.connect <- strings.TrimSpace(strings.TrimPrefix(line, "CONNECT"))
+
+		case "PING":
+			if s.errLine != "" {
+				fmt.Fprint(conn, s.errLine+"\r\n")
+				continue
+			}
+			fmt.Fprint(conn, "PONG\r\n")
+
+		case "PUB":
+			size, err := strconv.Atoi(fields[2])
+			Expect(err).ToNot(HaveOccurred())
+
+			payload := make([]byte, size+2)
+			_, err = io.ReadFull(reader, payload)
+			Expect(err).ToNot(HaveOccurred())
+
+			var message map[string]interface{}
+			Expect(json.Unmarshal(payload[:size], &message)).To(Succeed())
+
+			s.subject <- fields[1]
+			s.payload <- message
+
+			replySubject := message["reply_to"].(string)
+			fmt.Fprint(conn, "PING\r\n")
+			fmt.Fprintf(conn, "MSG %s 1 %d\r\n%s\r\n", replySubject, len(s.reply), s.reply)
+		}
+	}
+}
+
+func (s *fakeNATSServer) Close() {
+	_ = s.listener.Close()
+}
+
+var _ = Describe("NATSClient", func() {
+	var (
+		server  *fakeNATSServer
+		uuidGen *fakeuuid.FakeGenerator
+		logger  boshlog.Logger
+	)
+
+	BeforeEach(func() {
+		server = newFakeNATSServer()
+		server.reply = `{"value":"pong"}`
+
+		uuidGen = fakeuuid.NewFakeGenerator()
+		uuidGen.GeneratedUUID = "fake-uuid"
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	post := func(client *NATSClient, body string) (*http.Response, error) {
+		request, err := http.NewRequest("POST", "http://unused/agent", bytes.NewBufferString(body))
+		Expect(err).ToNot(HaveOccurred())
+		return client.Do(request)
+	}
+
+	It("publishes the request to the agent and returns the reply", func() {
+		go server.Serve()
+
+		client, err := NewNATSClient(server.URL("fake-user:fake-password@"), "fake-director-id", "", time.Second, uuidGen, logger)
+		Expect(err).ToNot(HaveOccurred())
+		client.SetAgentID("fake-agent-id")
+
+		response, err := post(client, `{"method":"ping","arguments":[],"reply_to":"fake-director-id"}`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+
+		body, err := ioutil.ReadAll(response.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(body)).To(Equal(`{"value":"pong"}`))
+
+		Expect(<-server.subject).To(Equal("agent.fake-agent-id"))
+
+		message := <-server.payload
+		Expect(message["method"]).To(Equal("ping"))
+		Expect(message["reply_to"]).To(Equal("director.fake-director-id.fake-uuid.fake-uuid"))
+
+		var connectOptions map[string]interface{}
+		Expect(json.Unmarshal([]byte(<-server.connect), &connectOptions)).To(Succeed())
+		Expect(connectOptions["user"]).To(Equal("fake-user"))
+		Expect(connectOptions["pass"]).To(Equal("fake-password"))
+		Expect(connectOptions["verbose"]).To(BeFalse())
+	})
+
+	It("returns an error when the NATS server responds with an error", func() {
+		server.errLine = "-ERR 'Authorization Violation'"
+		go server.Serve()
+
+		client, err := NewNATSClient(server.URL(""), "fake-director-id", "", time.Second, uuidGen, logger)
+		Expect(err).ToNot(HaveOccurred())
+		client.SetAgentID("fake-agent-id")
+
+		_, err = post(client, `{"method":"ping"}`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Agent mbus response error"))
+		Expect(err.Error()).To(ContainSubstring("Authorization Violation"))
+	})
+
+	It("sends further requests on the same connection", func() {
+		go server.Serve()
+
+		client, err := NewNATSClient(server.URL(""), "fake-director-id", "", time.Second, uuidGen, logger)
+		Expect(err).ToNot(HaveOccurred())
+		client.SetAgentID("fake-agent-id")
+
+		_, err = post(client, `{"method":"ping"}`)
+		Expect(err).ToNot(HaveOccurred())
+
+		// the fake server only accepts a single connection
+		response, err := post(client, `{"method":"get_state"}`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+
+		Expect((<-server.payload)["method"]).To(Equal("ping"))
+		Expect((<-server.payload)["method"]).To(Equal("get_state"))
+	})
+
+	It("returns an error when the NATS server requires TLS and no CA certificate is given", func() {
+		server.tlsRequired = true
+		go server.Serve()
+
+		client, err := NewNATSClient(server.URL(""), "fake-director-id", "", time.Second, uuidGen, logger)
+		Expect(err).ToNot(HaveOccurred())
+		client.SetAgentID("fake-agent-id")
+
+		_, err = post(client, `{"method":"ping"}`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Agent mbus tls error"))
+		Expect(err.Error()).To(ContainSubstring("requires TLS, but no CA certificate is given"))
+	})
+
+	It("returns an error when the agent ID is unknown", func() {
+		client, err := NewNATSClient(server.URL(""), "fake-director-id", "", time.Second, uuidGen, logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = post(client, `{"method":"ping"}`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Agent ID is unknown"))
+	})
+
+	It("returns an error when the CA certificate is invalid", func() {
+		_, err := NewNATSClient(server.URL(""), "fake-director-id", "fake-ca-cert", time.Second, uuidGen, logger)
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("through the agent client factory", func() {
+		It("sends agent requests to the agent set on the client", func() {
+			go server.Serve()
+
+			factory := NewAgentClientFactory(time.Millisecond, MbusOpts{Attempts: 1}, uuidGen, logger)
+			client, err := factory.NewAgentClient("fake-director-id", server.URL(""), "")
+			Expect(err).ToNot(HaveOccurred())
+
+			setter, ok := client.(interface {
+				SetAgentID(agentID string)
+			})
+			Expect(ok).To(BeTrue())
+			setter.SetAgentID("fake-agent-id")
+
+			state, err := client.Ping()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(state).To(Equal("pong"))
+			Expect(<-server.subject).To(Equal("agent.fake-agent-id"))
+		})
+	})
+})
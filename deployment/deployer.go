@@ -1,10 +1,12 @@
 package deployment
 
 import (
+	"sync"
 	"time"
 
 	biblobstore "github.com/cloudfoundry/bosh-cli/blobstore"
 	bicloud "github.com/cloudfoundry/bosh-cli/cloud"
+	biconfig "github.com/cloudfoundry/bosh-cli/config"
 	bidisk "github.com/cloudfoundry/bosh-cli/deployment/disk"
 	biinstance "github.com/cloudfoundry/bosh-cli/deployment/instance"
 	bideplmanifest "github.com/cloudfoundry/bosh-cli/deployment/manifest"
@@ -16,14 +18,28 @@ import (
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
+// CheckpointStemcellUploaded is completed once the stemcell of a deploy is
+// uploaded. A deploy resuming after it does not upload the stemcell again.
+const CheckpointStemcellUploaded = "stemcell_uploaded"
+
+// CheckpointInstancesCreated is completed once the VMs of a deploy are
+// created and their disks attached. A deploy resuming after it reuses the
+// VMs instead of recreating them.
+const CheckpointInstancesCreated = "instances_created"
+
+// InstanceClients returns the VM manager and blobstore of the instance with
+// the given index, whose agent listens on the given static IP. The first
+// instance is reached through the mbus URL of the installation manifest
+// instead.
+type InstanceClients func(index int, ip string) (bivm.Manager, biblobstore.Blobstore, error)
+
 type Deployer interface {
 	Deploy(
 		bicloud.Cloud,
 		bideplmanifest.Manifest,
 		bistemcell.CloudStemcell,
 		biinstallmanifest.Registry,
-		bivm.Manager,
-		biblobstore.Blobstore,
+		InstanceClients,
 		biui.Stage,
 	) (Deployment, error)
 }
@@ -32,6 +48,8 @@ type deployer struct {
 	vmManagerFactory       bivm.ManagerFactory
 	instanceManagerFactory biinstance.ManagerFactory
 	deploymentFactory      Factory
+	checkpointRepo         biconfig.CheckpointRepo
+	instanceRepo           biconfig.InstanceRepo
 	logger                 boshlog.Logger
 	logTag                 string
 }
@@ -40,12 +58,16 @@ func NewDeployer(
 	vmManagerFactory bivm.ManagerFactory,
 	instanceManagerFactory biinstance.ManagerFactory,
 	deploymentFactory Factory,
+	checkpointRepo biconfig.CheckpointRepo,
+	instanceRepo biconfig.InstanceRepo,
 	logger boshlog.Logger,
 ) Deployer {
 	return &deployer{
 		vmManagerFactory:       vmManagerFactory,
 		instanceManagerFactory: instanceManagerFactory,
 		deploymentFactory:      deploymentFactory,
+		checkpointRepo:         checkpointRepo,
+		instanceRepo:           instanceRepo,
 		logger:                 logger,
 		logTag:                 "deployer",
 	}
@@ -56,59 +78,281 @@ func (d *deployer) Deploy(
 	deploymentManifest bideplmanifest.Manifest,
 	cloudStemcell bistemcell.CloudStemcell,
 	registryConfig biinstallmanifest.Registry,
-	vmManager bivm.Manager,
-	blobstore biblobstore.Blobstore,
+	instanceClients InstanceClients,
 	deployStage biui.Stage,
 ) (Deployment, error) {
-	instanceManager := d.instanceManagerFactory.NewManager(cloud, vmManager, blobstore)
+	serviceJobs := deploymentManifest.ServiceJobs()
+	if len(serviceJobs) != 1 {
+		return nil, bosherr.Errorf("There must only be one job, found %d", len(serviceJobs))
+	}
+
+	jobSpec := serviceJobs[0]
+
+	if jobSpec.Instances > 1 && !registryConfig.IsEmpty() {
+		return nil, bosherr.Errorf("Job '%s' must have only one instance when a registry is configured, found %d", jobSpec.Name, jobSpec.Instances)
+	}
 
-	pingTimeout := 10 * time.Second
-	pingDelay := 500 * time.Millisecond
-	if err := instanceManager.DeleteAll(pingTimeout, pingDelay, deployStage); err != nil {
+	// the agents of the current VMs are waited for like the agents of new VMs
+	pingTimeout := deploymentManifest.Update.AgentWait.Timeout
+	pingDelay := deploymentManifest.Update.AgentWait.PollInterval
+
+	err := d.deleteRemovedInstances(cloud, jobSpec.Instances, instanceClients, pingTimeout, pingDelay, deployStage)
+	if err != nil {
+		return nil, err
+	}
+
+	instanceManagers, err := d.instanceManagers(cloud, deploymentManifest, jobSpec, instanceClients)
+	if err != nil {
 		return nil, err
 	}
 
-	instances, disks, err := d.createAllInstances(deploymentManifest, instanceManager, cloudStemcell, registryConfig, deployStage)
+	instances, disks, resumed, err := d.resumeAllInstances(deploymentManifest, jobSpec, instanceManagers, registryConfig, deployStage)
+	if err != nil {
+		return nil, err
+	}
+
+	if !resumed {
+		// with create-swap-delete, the current VMs are deleted by Replace
+		if deploymentManifest.VMStrategy(jobSpec.Name) == bideplmanifest.VMStrategyDeleteCreate {
+			for _, instanceManager := range instanceManagers {
+				if err := instanceManager.DeleteAll(pingTimeout, pingDelay, deployStage); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		instances, disks, err = d.createAllInstances(deploymentManifest, jobSpec, instanceManagers, cloudStemcell, registryConfig, pingTimeout, pingDelay, deployStage)
+		if err != nil {
+			return nil, err
+		}
+
+		err = d.checkpointRepo.Complete(CheckpointInstancesCreated)
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Saving deploy checkpoint")
+		}
+	}
+
+	err = d.updateAllInstances(deploymentManifest, instances, deployStage)
 	if err != nil {
 		return nil, err
 	}
 
+	// like the director, post-deploy scripts only run once every instance is
+	// updated and running
+	for _, instance := range instances {
+		err = instance.RunPostDeployScript(deploymentManifest, deployStage)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	stemcells := []bistemcell.CloudStemcell{cloudStemcell}
 	return d.deploymentFactory.NewDeployment(instances, disks, stemcells), nil
 }
 
+// instanceManagers returns a manager for each instance of the job, in order
+// of index. The static IP of every instance other than the first is recorded
+// before any of its VMs is created, so that they can be found again.
+func (d *deployer) instanceManagers(
+	cloud bicloud.Cloud,
+	deploymentManifest bideplmanifest.Manifest,
+	jobSpec bideplmanifest.Job,
+	instanceClients InstanceClients,
+) ([]biinstance.Manager, error) {
+	instanceManagers := []biinstance.Manager{}
+
+	for instanceID := 0; instanceID < jobSpec.Instances; instanceID++ {
+		ip := ""
+
+		if instanceID > 0 {
+			var found bool
+			ip, found = deploymentManifest.InstanceIP(jobSpec.Name, instanceID)
+			if !found {
+				return instanceManagers, bosherr.Errorf("Instance '%s/%d' must have a static IP", jobSpec.Name, instanceID)
+			}
+
+			err := d.instanceRepo.Save(instanceID, ip)
+			if err != nil {
+				return instanceManagers, bosherr.WrapErrorf(err, "Saving instance '%s/%d'", jobSpec.Name, instanceID)
+			}
+		}
+
+		vmManager, blobstore, err := instanceClients(instanceID, ip)
+		if err != nil {
+			return instanceManagers, bosherr.WrapErrorf(err, "Creating clients of instance '%s/%d'", jobSpec.Name, instanceID)
+		}
+
+		instanceManagers = append(instanceManagers, d.instanceManagerFactory.NewManager(cloud, vmManager, blobstore))
+	}
+
+	return instanceManagers, nil
+}
+
+// deleteRemovedInstances deletes the VMs of the instances whose index is no
+// longer below the number of instances. Their disks are left unused and are
+// orphaned along with other unused disks by OrphanUnused, so that they can be
+// deleted with delete-disk-env.
+func (d *deployer) deleteRemovedInstances(
+	cloud bicloud.Cloud,
+	instanceCount int,
+	instanceClients InstanceClients,
+	pingTimeout time.Duration,
+	pingDelay time.Duration,
+	deployStage biui.Stage,
+) error {
+	records, err := d.instanceRepo.All()
+	if err != nil {
+		return bosherr.WrapError(err, "Finding instances")
+	}
+
+	for _, record := range records {
+		if record.Index < instanceCount {
+			continue
+		}
+
+		vmManager, blobstore, err := instanceClients(record.Index, record.IP)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Creating clients of instance with index %d", record.Index)
+		}
+
+		instanceManager := d.instanceManagerFactory.NewManager(cloud, vmManager, blobstore)
+
+		err = instanceManager.DeleteAll(pingTimeout, pingDelay, deployStage)
+		if err != nil {
+			return err
+		}
+
+		err = d.instanceRepo.Delete(record.Index)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Deleting instance with index %d", record.Index)
+		}
+	}
+
+	return nil
+}
+
 func (d *deployer) createAllInstances(
 	deploymentManifest bideplmanifest.Manifest,
-	instanceManager biinstance.Manager,
+	jobSpec bideplmanifest.Job,
+	instanceManagers []biinstance.Manager,
 	cloudStemcell bistemcell.CloudStemcell,
 	registryConfig biinstallmanifest.Registry,
+	pingTimeout time.Duration,
+	pingDelay time.Duration,
 	deployStage biui.Stage,
 ) ([]biinstance.Instance, []bidisk.Disk, error) {
 	instances := []biinstance.Instance{}
 	disks := []bidisk.Disk{}
 
-	if len(deploymentManifest.Jobs) != 1 {
-		return instances, disks, bosherr.Errorf("There must only be one job, found %d", len(deploymentManifest.Jobs))
+	swapVMs := deploymentManifest.VMStrategy(jobSpec.Name) == bideplmanifest.VMStrategyCreateSwapDelete
+
+	for instanceID, instanceManager := range instanceManagers {
+		var instance biinstance.Instance
+		var instanceDisks []bidisk.Disk
+		var err error
+
+		if swapVMs {
+			instance, instanceDisks, err = instanceManager.Replace(jobSpec.Name, instanceID, deploymentManifest, cloudStemcell, registryConfig, pingTimeout, pingDelay, deployStage)
+		} else {
+			instance, instanceDisks, err = instanceManager.Create(jobSpec.Name, instanceID, deploymentManifest, cloudStemcell, registryConfig, deployStage)
+		}
+		if err != nil {
+			return instances, disks, bosherr.WrapErrorf(err, "Creating instance '%s/%d'", jobSpec.Name, instanceID)
+		}
+		instances = append(instances, instance)
+		disks = append(disks, instanceDisks...)
 	}
 
-	for _, jobSpec := range deploymentManifest.Jobs {
-		if jobSpec.Instances != 1 {
-			return instances, disks, bosherr.Errorf("Job '%s' must have only one instance, found %d", jobSpec.Name, jobSpec.Instances)
+	return instances, disks, nil
+}
+
+// resumeAllInstances reuses the VMs of an interrupted deploy of the same
+// manifest and stemcell. When any of them cannot be reused, the deploy starts
+// over and recreates them all.
+func (d *deployer) resumeAllInstances(
+	deploymentManifest bideplmanifest.Manifest,
+	jobSpec bideplmanifest.Job,
+	instanceManagers []biinstance.Manager,
+	registryConfig biinstallmanifest.Registry,
+	deployStage biui.Stage,
+) ([]biinstance.Instance, []bidisk.Disk, bool, error) {
+	instances := []biinstance.Instance{}
+	disks := []bidisk.Disk{}
+
+	created, err := d.checkpointRepo.IsComplete(CheckpointInstancesCreated)
+	if err != nil {
+		return instances, disks, false, bosherr.WrapError(err, "Finding deploy checkpoint")
+	}
+
+	if !created {
+		return instances, disks, false, nil
+	}
+
+	for instanceID, instanceManager := range instanceManagers {
+		instance, instanceDisks, found, err := instanceManager.Resume(jobSpec.Name, instanceID, deploymentManifest, registryConfig, deployStage)
+		if err != nil {
+			d.logger.Warn(d.logTag, "Recreating instances, failed to resume instance '%s/%d': %s", jobSpec.Name, instanceID, err.Error())
+			return []biinstance.Instance{}, []bidisk.Disk{}, false, nil
 		}
-		for instanceID := 0; instanceID < jobSpec.Instances; instanceID++ {
-			instance, instanceDisks, err := instanceManager.Create(jobSpec.Name, instanceID, deploymentManifest, cloudStemcell, registryConfig, deployStage)
-			if err != nil {
-				return instances, disks, bosherr.WrapErrorf(err, "Creating instance '%s/%d'", jobSpec.Name, instanceID)
+
+		if !found {
+			d.logger.Info(d.logTag, "Recreating instances, VM of instance '%s/%d' is gone", jobSpec.Name, instanceID)
+			return []biinstance.Instance{}, []bidisk.Disk{}, false, nil
+		}
+
+		instances = append(instances, instance)
+		disks = append(disks, instanceDisks...)
+	}
+
+	return instances, disks, true, nil
+}
+
+// updateAllInstances updates the jobs of the canaries one at a time and then
+// of the other instances in batches of max_in_flight, in order of index. The
+// deploy stops after the first batch with a failed instance. Instances of a
+// batch are updated one after the other when the update is serial, and all at
+// once otherwise, in which case the output of every instance is written at
+// once when the instance is done.
+func (d *deployer) updateAllInstances(
+	deploymentManifest bideplmanifest.Manifest,
+	instances []biinstance.Instance,
+	deployStage biui.Stage,
+) error {
+	update := deploymentManifest.Update
+
+	if !update.Serial {
+		deployStage = biui.NewSynchronizedStage(deployStage)
+	}
+
+	for _, batch := range update.Batches(len(instances)) {
+		errs := make([]error, len(batch))
+
+		if update.Serial {
+			for i, instanceID := range batch {
+				errs[i] = instances[instanceID].UpdateJobs(deploymentManifest, deployStage)
+			}
+		} else {
+			wg := &sync.WaitGroup{}
+			for i, instanceID := range batch {
+				wg.Add(1)
+				go func(i, instanceID int) {
+					defer wg.Done()
+
+					instanceStage, flush := biui.NewBufferedStage(deployStage)
+					defer flush()
+
+					errs[i] = instances[instanceID].UpdateJobs(deploymentManifest, instanceStage)
+				}(i, instanceID)
 			}
-			instances = append(instances, instance)
-			disks = append(disks, instanceDisks...)
+			wg.Wait()
+		}
 
-			err = instance.UpdateJobs(deploymentManifest, deployStage)
+		for _, err := range errs {
 			if err != nil {
-				return instances, disks, err
+				return err
 			}
 		}
 	}
 
-	return instances, disks, nil
+	return nil
 }
@@ -2,6 +2,7 @@ package deployment_test
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	. "github.com/cloudfoundry/bosh-cli/deployment"
@@ -16,10 +17,12 @@ import (
 	. "github.com/onsi/gomega"
 
 	bias "github.com/cloudfoundry/bosh-agent/agentclient/applyspec"
+	biblobstore "github.com/cloudfoundry/bosh-cli/blobstore"
 	biconfig "github.com/cloudfoundry/bosh-cli/config"
 	biinstance "github.com/cloudfoundry/bosh-cli/deployment/instance"
 	bideplmanifest "github.com/cloudfoundry/bosh-cli/deployment/manifest"
 	bisshtunnel "github.com/cloudfoundry/bosh-cli/deployment/sshtunnel"
+	bivm "github.com/cloudfoundry/bosh-cli/deployment/vm"
 	biinstallmanifest "github.com/cloudfoundry/bosh-cli/installation/manifest"
 	bistemcell "github.com/cloudfoundry/bosh-cli/stemcell"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -32,6 +35,8 @@ import (
 	fakebisshtunnel "github.com/cloudfoundry/bosh-cli/deployment/sshtunnel/fakes"
 	fakebivm "github.com/cloudfoundry/bosh-cli/deployment/vm/fakes"
 	fakebiui "github.com/cloudfoundry/bosh-cli/ui/fakes"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
 )
 
 var _ = Describe("Deployer", func() {
@@ -69,6 +74,10 @@ var _ = Describe("Deployer", func() {
 		mockState               *mock_instance_state.MockState
 
 		mockBlobstore *mock_blobstore.MockBlobstore
+
+		checkpointRepo  biconfig.CheckpointRepo
+		instanceRepo    biconfig.InstanceRepo
+		instanceClients InstanceClients
 	)
 
 	BeforeEach(func() {
@@ -85,6 +94,11 @@ var _ = Describe("Deployer", func() {
 					Start: 0,
 					End:   5478,
 				},
+				AgentWait: bideplmanifest.AgentWait{
+					Timeout:      10 * time.Minute,
+					PollInterval: 500 * time.Millisecond,
+				},
+				Serial: true,
 			},
 			DiskPools: []bideplmanifest.DiskPool{
 				diskPool,
@@ -155,10 +169,23 @@ var _ = Describe("Deployer", func() {
 		pingDelay := 500 * time.Millisecond
 		deploymentFactory := NewFactory(pingTimeout, pingDelay)
 
+		deploymentStateService := biconfig.NewFileSystemDeploymentStateService(fakesys.NewFakeFileSystem(), &fakeuuid.FakeGenerator{}, logger, "/deployment.json")
+		checkpointRepo = biconfig.NewCheckpointRepo(deploymentStateService)
+		err = checkpointRepo.Begin("fake-manifest-sha", "fake-stemcell-cid")
+		Expect(err).ToNot(HaveOccurred())
+
+		instanceRepo = biconfig.NewInstanceRepo(deploymentStateService)
+
+		instanceClients = func(index int, ip string) (bivm.Manager, biblobstore.Blobstore, error) {
+			return fakeVMManager, mockBlobstore, nil
+		}
+
 		deployer = NewDeployer(
 			mockVMManagerFactory,
 			instanceManagerFactory,
 			deploymentFactory,
+			checkpointRepo,
+			instanceRepo,
 			logger,
 		)
 	})
@@ -175,7 +202,7 @@ var _ = Describe("Deployer", func() {
 		fakeAgentState := agentclient.AgentState{}
 		fakeVM.GetStateResult = fakeAgentState
 
-		mockStateBuilderFactory.EXPECT().NewBuilder(mockBlobstore, mockAgentClient).Return(mockStateBuilder).AnyTimes()
+		mockStateBuilderFactory.EXPECT().NewBuilder(mockBlobstore, mockAgentClient, gomock.Any()).Return(mockStateBuilder).AnyTimes()
 		mockStateBuilder.EXPECT().Build(jobName, jobIndex, deploymentManifest, fakeStage, fakeAgentState).Return(mockState, nil).AnyTimes()
 		mockStateBuilder.EXPECT().BuildInitialState(jobName, jobIndex, deploymentManifest).Return(mockState, nil).AnyTimes()
 		mockState.EXPECT().ToApplySpec().Return(applySpec).AnyTimes()
@@ -191,7 +218,7 @@ var _ = Describe("Deployer", func() {
 		})
 
 		It("deletes existing vm", func() {
-			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(fakeExistingVM.DeleteCalled).To(Equal(1))
@@ -202,10 +229,110 @@ var _ = Describe("Deployer", func() {
 				{Name: "Deleting VM 'existing-vm-cid'"},
 			}))
 		})
+
+		It("waits for the agent on the existing vm as configured in the manifest", func() {
+			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeExistingVM.WaitUntilReadyInputs).To(Equal([]fakebivm.WaitUntilReadyInput{
+				{Timeout: 10 * time.Minute, Delay: 500 * time.Millisecond},
+			}))
+		})
+
+		Context("when the vm strategy is create-swap-delete", func() {
+			BeforeEach(func() {
+				deploymentManifest.Update.VMStrategy = bideplmanifest.VMStrategyCreateSwapDelete
+			})
+
+			It("deletes the existing vm after creating a vm", func() {
+				_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeExistingVM.DeleteCalled).To(Equal(0))
+				Expect(fakeExistingVM.StopCalled).To(Equal(1))
+				Expect(fakeVMManager.DeletedReplaced).To(Equal([]bivm.VM{fakeExistingVM}))
+
+				Expect(fakeStage.PerformCalls[:5]).To(Equal([]*fakebiui.PerformCall{
+					{Name: "Waiting for the agent on VM 'existing-vm-cid'"},
+					{Name: "Stopping jobs on instance 'fake-job-name/0'"},
+					{Name: "Creating VM for instance 'fake-job-name/0' from stemcell 'fake-stemcell-cid'"},
+					{Name: "Waiting for the agent on VM 'fake-vm-cid' to be ready"},
+					{Name: "Deleting replaced VM 'existing-vm-cid'"},
+				}))
+			})
+		})
+	})
+
+	Context("when resuming a deploy that created its instances", func() {
+		var fakeExistingVM *fakebivm.FakeVM
+
+		BeforeEach(func() {
+			fakeExistingVM = fakebivm.NewFakeVM("existing-vm-cid")
+			fakeVMManager.SetFindCurrentBehavior(fakeExistingVM, true, nil)
+			fakeExistingVM.AgentClientReturn = mockAgentClient
+
+			err := checkpointRepo.Complete(CheckpointInstancesCreated)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("updates the existing vm instead of recreating it", func() {
+			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeExistingVM.DeleteCalled).To(Equal(0))
+			Expect(fakeVMManager.CreateInput).To(Equal(fakebivm.CreateInput{}))
+			Expect(fakeExistingVM.ApplyInputs).ToNot(BeEmpty())
+
+			Expect(fakeStage.PerformCalls[0]).To(Equal(&fakebiui.PerformCall{
+				Name: "Waiting for the agent on VM 'existing-vm-cid' to be ready",
+			}))
+		})
+
+		Context("when the existing vm is gone", func() {
+			BeforeEach(func() {
+				fakeExistingVM.ExistsFound = false
+			})
+
+			It("creates a vm", func() {
+				_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeVMManager.CreateInput).To(Equal(fakebivm.CreateInput{
+					Stemcell: cloudStemcell,
+					Manifest: deploymentManifest,
+				}))
+			})
+		})
+
+		Context("when the agent on the existing vm does not become ready", func() {
+			BeforeEach(func() {
+				fakeExistingVM.WaitUntilReadyErr = errors.New("fake-wait-error")
+			})
+
+			It("recreates the vm", func() {
+				_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeExistingVM.DeleteCalled).To(Equal(1))
+				Expect(fakeVMManager.CreateInput).To(Equal(fakebivm.CreateInput{
+					Stemcell: cloudStemcell,
+					Manifest: deploymentManifest,
+				}))
+			})
+		})
+	})
+
+	It("completes the instances created checkpoint", func() {
+		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
+		Expect(err).NotTo(HaveOccurred())
+
+		complete, err := checkpointRepo.IsComplete(CheckpointInstancesCreated)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(complete).To(BeTrue())
 	})
 
 	It("creates a vm", func() {
-		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
 		Expect(err).NotTo(HaveOccurred())
 
 		Expect(fakeVMManager.CreateInput).To(Equal(fakebivm.CreateInput{
@@ -232,7 +359,7 @@ var _ = Describe("Deployer", func() {
 		})
 
 		It("starts the SSH tunnel", func() {
-			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(fakeSSHTunnel.Started).To(BeTrue())
 			Expect(fakeSSHTunnelFactory.NewSSHTunnelOptions).To(Equal(bisshtunnel.Options{
@@ -252,7 +379,7 @@ var _ = Describe("Deployer", func() {
 			})
 
 			It("returns an error", func() {
-				_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+				_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-ssh-tunnel-start-error"))
 			})
@@ -260,7 +387,7 @@ var _ = Describe("Deployer", func() {
 	})
 
 	It("waits for the vm", func() {
-		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
 		Expect(err).NotTo(HaveOccurred())
 		Expect(fakeVM.WaitUntilReadyInputs).To(ContainElement(fakebivm.WaitUntilReadyInput{
 			Timeout: 10 * time.Minute,
@@ -269,7 +396,7 @@ var _ = Describe("Deployer", func() {
 	})
 
 	It("logs start and stop events to the eventLogger", func() {
-		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
 		Expect(err).NotTo(HaveOccurred())
 
 		Expect(fakeStage.PerformCalls[1]).To(Equal(&fakebiui.PerformCall{
@@ -287,7 +414,7 @@ var _ = Describe("Deployer", func() {
 		})
 
 		It("logs start and stop events to the eventLogger", func() {
-			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("fake-wait-error"))
 
@@ -299,7 +426,7 @@ var _ = Describe("Deployer", func() {
 	})
 
 	It("updates the vm", func() {
-		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
 		Expect(err).NotTo(HaveOccurred())
 
 		Expect(fakeVM.ApplyInputs).To(Equal([]fakebivm.ApplyInput{
@@ -309,14 +436,33 @@ var _ = Describe("Deployer", func() {
 	})
 
 	It("starts the agent", func() {
-		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
 		Expect(err).NotTo(HaveOccurred())
 
 		Expect(fakeVM.StartCalled).To(Equal(1))
 	})
 
+	It("runs the post-deploy scripts after the post-start scripts", func() {
+		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeVM.RunScriptInputs).To(Equal([]string{"pre-start", "post-start", "post-deploy"}))
+	})
+
+	Context("when running the post-deploy script fails", func() {
+		BeforeEach(func() {
+			fakeVM.RunScriptErrors["post-deploy"] = bosherr.Error("fake-post-deploy-error")
+		})
+
+		It("returns an error", func() {
+			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Running the post-deploy script: fake-post-deploy-error"))
+		})
+	})
+
 	It("waits until agent reports state as running", func() {
-		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
 		Expect(err).NotTo(HaveOccurred())
 
 		Expect(fakeVM.WaitToBeRunningInputs).To(ContainElement(fakebivm.WaitInput{
@@ -331,13 +477,13 @@ var _ = Describe("Deployer", func() {
 		})
 
 		It("returns an error", func() {
-			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
 			Expect(err).To(HaveOccurred())
 		})
 	})
 
 	It("logs instance update ui stages", func() {
-		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
 		Expect(err).NotTo(HaveOccurred())
 
 		Expect(fakeStage.PerformCalls[2:4]).To(Equal([]*fakebiui.PerformCall{
@@ -352,7 +498,7 @@ var _ = Describe("Deployer", func() {
 		})
 
 		It("fails with descriptive error", func() {
-			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal("Applying the initial agent state: fake-apply-error"))
 		})
@@ -364,7 +510,7 @@ var _ = Describe("Deployer", func() {
 		})
 
 		It("logs start and stop events to the eventLogger", func() {
-			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("fake-start-error"))
 
@@ -384,7 +530,7 @@ var _ = Describe("Deployer", func() {
 		})
 
 		It("logs start and stop events to the eventLogger", func() {
-			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("fake-wait-running-error"))
 
@@ -394,4 +540,159 @@ var _ = Describe("Deployer", func() {
 			}))
 		})
 	})
+
+	Context("when the job has more than one instance", func() {
+		var (
+			otherVMManager        *fakebivm.FakeManager
+			otherVM               *fakebivm.FakeVM
+			instanceClientsInputs []string
+		)
+
+		BeforeEach(func() {
+			registryConfig = biinstallmanifest.Registry{}
+
+			deploymentManifest.Jobs[0].Instances = 2
+			deploymentManifest.Jobs[0].Networks = []bideplmanifest.JobNetwork{
+				{Name: "fake-network-name", StaticIPs: []string{"10.0.0.6", "10.0.0.7"}},
+			}
+
+			otherVM = fakebivm.NewFakeVM("fake-other-vm-cid")
+			otherVM.AgentClientReturn = mockAgentClient
+			otherVMManager = fakebivm.NewFakeManager()
+			otherVMManager.CreateVM = otherVM
+
+			instanceClientsInputs = []string{}
+			instanceClients = func(index int, ip string) (bivm.Manager, biblobstore.Blobstore, error) {
+				instanceClientsInputs = append(instanceClientsInputs, fmt.Sprintf("%d:%s", index, ip))
+				if index == 0 {
+					return fakeVMManager, mockBlobstore, nil
+				}
+				return otherVMManager, mockBlobstore, nil
+			}
+		})
+
+		JustBeforeEach(func() {
+			mockStateBuilder.EXPECT().Build("fake-job-name", 1, deploymentManifest, fakeStage, agentclient.AgentState{}).Return(mockState, nil).AnyTimes()
+			mockStateBuilder.EXPECT().BuildInitialState("fake-job-name", 1, deploymentManifest).Return(mockState, nil).AnyTimes()
+		})
+
+		It("creates and updates a vm for every instance", func() {
+			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(instanceClientsInputs).To(Equal([]string{"0:", "1:10.0.0.7"}))
+			Expect(fakeVM.StartCalled).To(Equal(1))
+			Expect(otherVM.StartCalled).To(Equal(1))
+		})
+
+		It("records the static IP of the other instances", func() {
+			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
+			Expect(err).NotTo(HaveOccurred())
+
+			records, err := instanceRepo.All()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(records).To(Equal([]biconfig.InstanceRecord{{Index: 1, IP: "10.0.0.7"}}))
+		})
+
+		Context("when the canary fails to update", func() {
+			BeforeEach(func() {
+				deploymentManifest.Update.Canaries = 1
+				fakeVM.ApplyErr = bosherr.Error("fake-apply-error")
+			})
+
+			It("does not update the other instances", func() {
+				_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-apply-error"))
+
+				Expect(otherVM.ApplyInputs).To(BeEmpty())
+			})
+		})
+
+		Context("when an instance fails to update in a batch", func() {
+			BeforeEach(func() {
+				deploymentManifest.Update.Canaries = 0
+				deploymentManifest.Update.MaxInFlight = 2
+				fakeVM.ApplyErr = bosherr.Error("fake-apply-error")
+			})
+
+			It("updates the rest of the batch before failing", func() {
+				_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-apply-error"))
+
+				Expect(otherVM.StartCalled).To(Equal(1))
+			})
+		})
+
+		Context("when the update is not serial", func() {
+			BeforeEach(func() {
+				deploymentManifest.Update.Canaries = 0
+				deploymentManifest.Update.MaxInFlight = 2
+				deploymentManifest.Update.Serial = false
+			})
+
+			JustBeforeEach(func() {
+				// the instances report progress on a synchronized wrapper of the stage
+				mockStateBuilder.EXPECT().Build("fake-job-name", 0, deploymentManifest, gomock.Any(), agentclient.AgentState{}).Return(mockState, nil).AnyTimes()
+				mockStateBuilder.EXPECT().Build("fake-job-name", 1, deploymentManifest, gomock.Any(), agentclient.AgentState{}).Return(mockState, nil).AnyTimes()
+			})
+
+			It("updates every instance of the batch", func() {
+				_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeVM.StartCalled).To(Equal(1))
+				Expect(otherVM.StartCalled).To(Equal(1))
+			})
+
+			It("returns the error of a failed instance after the batch", func() {
+				otherVM.ApplyErr = bosherr.Error("fake-apply-error")
+
+				_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-apply-error"))
+
+				Expect(fakeVM.StartCalled).To(Equal(1))
+			})
+		})
+
+		Context("when the number of instances was reduced", func() {
+			var removedVM *fakebivm.FakeVM
+
+			BeforeEach(func() {
+				deploymentManifest.Jobs[0].Instances = 1
+
+				err := instanceRepo.Save(1, "10.0.0.7")
+				Expect(err).ToNot(HaveOccurred())
+
+				removedVM = fakebivm.NewFakeVM("fake-removed-vm-cid")
+				removedVM.AgentClientReturn = mockAgentClient
+				otherVMManager.SetFindCurrentBehavior(removedVM, true, nil)
+			})
+
+			It("deletes the removed instances", func() {
+				_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(removedVM.DeleteCalled).To(Equal(1))
+
+				records, err := instanceRepo.All()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(records).To(BeEmpty())
+			})
+		})
+
+		Context("when a registry is configured", func() {
+			BeforeEach(func() {
+				registryConfig = biinstallmanifest.Registry{Host: "fake-registry-host"}
+			})
+
+			It("returns an error", func() {
+				_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, instanceClients, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Job 'fake-job-name' must have only one instance when a registry is configured, found 2"))
+			})
+		})
+	})
 })
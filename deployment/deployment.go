@@ -9,10 +9,12 @@ import (
 	biinstance "github.com/cloudfoundry/bosh-cli/deployment/instance"
 	bistemcell "github.com/cloudfoundry/bosh-cli/stemcell"
 	biui "github.com/cloudfoundry/bosh-cli/ui"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
 type Deployment interface {
 	Delete(biui.Stage) error
+	ForceDelete(biui.Stage) error
 }
 
 type deployment struct {
@@ -77,6 +79,40 @@ func (d *deployment) Delete(deleteStage biui.Stage) error {
 	return nil
 }
 
+// ForceDelete deletes the instances, disks and stemcells like Delete, but
+// carries on past the ones that fail to delete and returns their errors
+// together at the end.
+func (d *deployment) ForceDelete(deleteStage biui.Stage) error {
+	errs := []error{}
+
+	for i := len(d.instances) - 1; i >= 0; i-- {
+		if err := d.instances[i].Delete(d.pingTimeout, d.pingDelay, deleteStage); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	d.instances = []biinstance.Instance{}
+
+	for i := len(d.disks) - 1; i >= 0; i-- {
+		if err := d.deleteDisk(deleteStage, d.disks[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	d.disks = []bidisk.Disk{}
+
+	for i := len(d.stemcells) - 1; i >= 0; i-- {
+		if err := d.deleteStemcell(deleteStage, d.stemcells[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	d.stemcells = []bistemcell.CloudStemcell{}
+
+	if len(errs) > 0 {
+		return bosherr.NewMultiError(errs...)
+	}
+
+	return nil
+}
+
 func (d *deployment) deleteDisk(deleteStage biui.Stage, disk bidisk.Disk) error {
 	stepName := fmt.Sprintf("Deleting disk '%s'", disk.CID())
 	return deleteStage.Perform(stepName, func() error {
@@ -105,7 +105,7 @@ var _ = Describe("Deployment", func() {
 				ConfigurationHash:        "",
 			}
 
-			mockStateBuilderFactory.EXPECT().NewBuilder(mockBlobstore, mockAgentClient).Return(mockStateBuilder).AnyTimes()
+			mockStateBuilderFactory.EXPECT().NewBuilder(mockBlobstore, mockAgentClient, gomock.Any()).Return(mockStateBuilder).AnyTimes()
 			mockState.EXPECT().ToApplySpec().Return(applySpec).AnyTimes()
 		}
 
@@ -134,7 +134,7 @@ var _ = Describe("Deployment", func() {
 		JustBeforeEach(func() {
 			// all these local factories & managers are just used to construct a Deployment based on the deployment state
 			diskManagerFactory := bidisk.NewManagerFactory(diskRepo, logger)
-			diskDeployer := bivm.NewDiskDeployer(diskManagerFactory, diskRepo, logger)
+			diskDeployer := bivm.NewDiskDeployer(diskManagerFactory, diskRepo, false, logger)
 
 			vmManagerFactory := bivm.NewManagerFactory(vmRepo, stemcellRepo, diskDeployer, fakeUUIDGenerator, fs, logger)
 			sshTunnelFactory := bisshtunnel.NewFactory(logger)
@@ -273,6 +273,37 @@ var _ = Describe("Deployment", func() {
 					Expect(fakeStage.PerformCalls).To(BeEmpty())
 				})
 			})
+
+			Context("when deleting is forced", func() {
+				It("stops agent, unmounts disk, deletes vm, deletes disk, deletes stemcell", func() {
+					expectNormalFlow()
+
+					err := deployment.ForceDelete(fakeStage)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("continues deleting the disk and stemcell when deleting the vm fails", func() {
+					gomock.InOrder(
+						mockCloud.EXPECT().HasVM("fake-vm-cid").Return(true, nil),
+						mockAgentClient.EXPECT().Ping().Return("any-state", nil),
+						mockAgentClient.EXPECT().Stop(),
+						mockAgentClient.EXPECT().ListDisk().Return([]string{"fake-disk-cid"}, nil),
+						mockAgentClient.EXPECT().UnmountDisk("fake-disk-cid"),
+						mockCloud.EXPECT().DeleteVM("fake-vm-cid").Return(bosherr.Error("fake-delete-vm-error")),
+						mockCloud.EXPECT().DeleteDisk("fake-disk-cid").Return(bosherr.Error("fake-delete-disk-error")),
+						mockCloud.EXPECT().DeleteStemcell("fake-stemcell-cid"),
+					)
+
+					err := deployment.ForceDelete(fakeStage)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-delete-vm-error"))
+					Expect(err.Error()).To(ContainSubstring("fake-delete-disk-error"))
+
+					_, found, err := stemcellRepo.FindCurrent()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(found).To(BeFalse(), "should be no current stemcell")
+				})
+			})
 		})
 
 		Context("when nothing has been deployed", func() {
@@ -13,6 +13,7 @@ type Disk interface {
 	CID() string
 	NeedsMigration(newSize int, newCloudProperties biproperty.Map) bool
 	Delete() error
+	Orphan() error
 }
 
 type disk struct {
@@ -73,3 +74,23 @@ func (d *disk) Delete() error {
 	// returns bicloud.Error only if it is a DiskNotFoundError
 	return deleteErr
 }
+
+// Orphan keeps the disk in the cloud, but no longer as a disk in use, so that
+// it can be attached again or deleted later.
+func (d *disk) Orphan() error {
+	diskRecord, found, err := d.repo.Find(d.cid)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Finding disk record (cid=%s)", d.cid)
+	}
+
+	if !found {
+		return bosherr.Errorf("Disk record not found (cid=%s)", d.cid)
+	}
+
+	err = d.repo.Orphan(diskRecord)
+	if err != nil {
+		return bosherr.WrapError(err, "Orphaning disk record")
+	}
+
+	return nil
+}
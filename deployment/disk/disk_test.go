@@ -182,4 +182,34 @@ var _ = Describe("Disk", func() {
 			})
 		})
 	})
+
+	Describe("Orphan", func() {
+		It("marks the disk record as orphaned without deleting the disk", func() {
+			diskRecord, err := diskRepo.Save("fake-disk-cid", 1024, diskCloudProperties)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = diskRepo.UpdateCurrent(diskRecord.ID)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = disk.Orphan()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCloud.DeleteDiskInputs).To(BeEmpty())
+
+			diskRecords, err := diskRepo.FindOrphaned()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(diskRecords).To(HaveLen(1))
+			Expect(diskRecords[0].CID).To(Equal("fake-disk-cid"))
+
+			_, found, err := diskRepo.FindCurrent()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		It("returns an error when the disk has no record", func() {
+			err := disk.Orphan()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Disk record not found (cid=fake-disk-cid)"))
+		})
+	})
 })
@@ -12,6 +12,9 @@ type FakeDisk struct {
 
 	DeleteCalledTimes int
 	deleteErr         error
+
+	OrphanCalledTimes int
+	OrphanErr         error
 }
 
 type NeedsMigrationInput struct {
@@ -48,6 +51,11 @@ func (d *FakeDisk) Delete() error {
 	return d.deleteErr
 }
 
+func (d *FakeDisk) Orphan() error {
+	d.OrphanCalledTimes++
+	return d.OrphanErr
+}
+
 func (d *FakeDisk) SetNeedsMigrationBehavior(needsMigration bool) {
 	d.needsMigrationOutput = needsMigrationOutput{
 		needsMigration: needsMigration,
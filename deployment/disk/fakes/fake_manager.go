@@ -13,9 +13,15 @@ type FakeManager struct {
 
 	findCurrentOutput findCurrentOutput
 
+	FindCurrentNamedDisks map[string]bidisk.Disk
+	FindCurrentNamedErr   error
+
 	DeleteUnusedCalledTimes int
 	DeleteUnusedErr         error
 
+	OrphanUnusedCalledTimes int
+	OrphanUnusedErr         error
+
 	findUnusedOutput findUnusedOutput
 }
 
@@ -52,6 +58,13 @@ func (m *FakeManager) FindCurrent() ([]bidisk.Disk, error) {
 	return m.findCurrentOutput.Disks, m.findCurrentOutput.Err
 }
 
+func (m *FakeManager) FindCurrentNamed() (map[string]bidisk.Disk, error) {
+	if m.FindCurrentNamedDisks == nil {
+		return map[string]bidisk.Disk{}, m.FindCurrentNamedErr
+	}
+	return m.FindCurrentNamedDisks, m.FindCurrentNamedErr
+}
+
 func (m *FakeManager) FindUnused() ([]bidisk.Disk, error) {
 	return m.findUnusedOutput.disks, m.findUnusedOutput.err
 }
@@ -61,6 +74,11 @@ func (m *FakeManager) DeleteUnused(eventLogStage biui.Stage) error {
 	return m.DeleteUnusedErr
 }
 
+func (m *FakeManager) OrphanUnused(eventLogStage biui.Stage) error {
+	m.OrphanUnusedCalledTimes++
+	return m.OrphanUnusedErr
+}
+
 func (m *FakeManager) SetFindCurrentBehavior(disks []bidisk.Disk, err error) {
 	m.findCurrentOutput = findCurrentOutput{
 		Disks: disks,
@@ -13,9 +13,11 @@ import (
 
 type Manager interface {
 	FindCurrent() ([]Disk, error)
+	FindCurrentNamed() (map[string]Disk, error)
 	Create(bideplmanifest.DiskPool, string) (Disk, error)
 	FindUnused() ([]Disk, error)
 	DeleteUnused(biui.Stage) error
+	OrphanUnused(biui.Stage) error
 }
 
 func NewManager(
@@ -54,6 +56,23 @@ func (m *manager) FindCurrent() ([]Disk, error) {
 	return disks, nil
 }
 
+// FindCurrentNamed returns the disks attached in addition to the current disk,
+// by name.
+func (m *manager) FindCurrentNamed() (map[string]Disk, error) {
+	disks := map[string]Disk{}
+
+	diskRecords, err := m.diskRepo.FindCurrentNamed()
+	if err != nil {
+		return disks, bosherr.WrapError(err, "Reading named disk records")
+	}
+
+	for name, diskRecord := range diskRecords {
+		disks[name] = NewDisk(diskRecord, m.cloud, m.diskRepo)
+	}
+
+	return disks, nil
+}
+
 func (m *manager) Create(diskPool bideplmanifest.DiskPool, vmCID string) (Disk, error) {
 	diskCloudProperties := diskPool.CloudProperties
 
@@ -85,13 +104,19 @@ func (m *manager) FindUnused() ([]Disk, error) {
 		return disks, bosherr.WrapError(err, "Getting all disk records")
 	}
 
-	currentDiskRecord, found, err := m.diskRepo.FindCurrent()
+	// the current disks of other instances are in use as well
+	currentDiskRecords, err := m.diskRepo.FindAllCurrent()
 	if err != nil {
-		return disks, bosherr.WrapError(err, "Finding current disk record")
+		return disks, bosherr.WrapError(err, "Finding current disk records")
+	}
+
+	currentDiskIDs := map[string]bool{}
+	for _, currentDiskRecord := range currentDiskRecords {
+		currentDiskIDs[currentDiskRecord.ID] = true
 	}
 
 	for _, diskRecord := range diskRecords {
-		if !found || diskRecord.ID != currentDiskRecord.ID {
+		if !currentDiskIDs[diskRecord.ID] {
 			disks = append(disks, NewDisk(diskRecord, m.cloud, m.diskRepo))
 		}
 	}
@@ -122,3 +147,36 @@ func (m *manager) DeleteUnused(eventLoggerStage biui.Stage) error {
 
 	return nil
 }
+
+// OrphanUnused keeps the unused disks instead of deleting them, so that the
+// data on a replaced disk is not lost.
+func (m *manager) OrphanUnused(eventLoggerStage biui.Stage) error {
+	disks, err := m.FindUnused()
+	if err != nil {
+		return bosherr.WrapError(err, "Finding unused disks")
+	}
+
+	orphanedDiskRecords, err := m.diskRepo.FindOrphaned()
+	if err != nil {
+		return bosherr.WrapError(err, "Finding orphaned disk records")
+	}
+
+	orphanedDiskCIDs := map[string]bool{}
+	for _, orphanedDiskRecord := range orphanedDiskRecords {
+		orphanedDiskCIDs[orphanedDiskRecord.CID] = true
+	}
+
+	for _, disk := range disks {
+		if orphanedDiskCIDs[disk.CID()] {
+			continue
+		}
+
+		stepName := fmt.Sprintf("Orphaning unused disk '%s'", disk.CID())
+		err = eventLoggerStage.Perform(stepName, disk.Orphan)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
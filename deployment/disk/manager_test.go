@@ -149,6 +149,33 @@ var _ = Describe("Manager", func() {
 		})
 	})
 
+	Describe("FindCurrentNamed", func() {
+		It("returns the named disks by name", func() {
+			diskRecord, err := diskRepo.Save("fake-named-disk-cid", 1024, biproperty.Map{})
+			Expect(err).ToNot(HaveOccurred())
+
+			err = diskRepo.UpdateCurrentNamed("fake-disk-name", diskRecord.ID)
+			Expect(err).ToNot(HaveOccurred())
+
+			disks, err := manager.FindCurrentNamed()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(disks).To(HaveLen(1))
+			Expect(disks["fake-disk-name"].CID()).To(Equal("fake-named-disk-cid"))
+		})
+
+		It("does not return the current disk", func() {
+			diskRecord, err := diskRepo.Save("fake-existing-disk-cid", 1024, biproperty.Map{})
+			Expect(err).ToNot(HaveOccurred())
+
+			err = diskRepo.UpdateCurrent(diskRecord.ID)
+			Expect(err).ToNot(HaveOccurred())
+
+			disks, err := manager.FindCurrentNamed()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(disks).To(BeEmpty())
+		})
+	})
+
 	Describe("FindUnused", func() {
 		var (
 			firstDisk bidisk.Disk
@@ -233,4 +260,53 @@ var _ = Describe("Manager", func() {
 			}))
 		})
 	})
+
+	Describe("OrphanUnused", func() {
+		var (
+			secondDiskRecord biconfig.DiskRecord
+			fakeStage        *fakebiui.FakeStage
+		)
+		BeforeEach(func() {
+			fakeStage = fakebiui.NewFakeStage()
+
+			fakeUUIDGenerator.GeneratedUUID = "fake-disk-id-1"
+			firstDiskRecord, err := diskRepo.Save("fake-disk-cid-1", 100, nil)
+			Expect(err).ToNot(HaveOccurred())
+			err = diskRepo.Orphan(firstDiskRecord)
+			Expect(err).ToNot(HaveOccurred())
+
+			fakeUUIDGenerator.GeneratedUUID = "fake-disk-id-2"
+			secondDiskRecord, err = diskRepo.Save("fake-disk-cid-2", 100, nil)
+			Expect(err).ToNot(HaveOccurred())
+			err = diskRepo.UpdateCurrent(secondDiskRecord.ID)
+			Expect(err).ToNot(HaveOccurred())
+
+			fakeUUIDGenerator.GeneratedUUID = "fake-disk-id-3"
+			_, err = diskRepo.Save("fake-disk-cid-3", 100, nil)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("orphans the unused disks that are not orphaned yet, without deleting them", func() {
+			err := manager.OrphanUnused(fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCloud.DeleteDiskInputs).To(BeEmpty())
+
+			Expect(fakeStage.PerformCalls).To(Equal([]*fakebiui.PerformCall{
+				{Name: "Orphaning unused disk 'fake-disk-cid-3'"},
+			}))
+
+			currentRecord, found, err := diskRepo.FindCurrent()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(currentRecord).To(Equal(secondDiskRecord))
+
+			records, err := diskRepo.FindOrphaned()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(records).To(Equal([]biconfig.DiskRecord{
+				{ID: "fake-disk-id-1", CID: "fake-disk-cid-1", Size: 100, Orphaned: true},
+				{ID: "fake-disk-id-3", CID: "fake-disk-cid-3", Size: 100, Orphaned: true},
+			}))
+		})
+	})
 })
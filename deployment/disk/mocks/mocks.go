@@ -52,6 +52,16 @@ func (_mr *_MockDiskRecorder) Delete() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Delete")
 }
 
+func (_m *MockDisk) Orphan() error {
+	ret := _m.ctrl.Call(_m, "Orphan")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDiskRecorder) Orphan() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Orphan")
+}
+
 func (_m *MockDisk) NeedsMigration(_param0 int, _param1 property.Map) bool {
 	ret := _m.ctrl.Call(_m, "NeedsMigration", _param0, _param1)
 	ret0, _ := ret[0].(bool)
@@ -104,6 +114,16 @@ func (_mr *_MockManagerRecorder) DeleteUnused(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteUnused", arg0)
 }
 
+func (_m *MockManager) OrphanUnused(_param0 ui.Stage) error {
+	ret := _m.ctrl.Call(_m, "OrphanUnused", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockManagerRecorder) OrphanUnused(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "OrphanUnused", arg0)
+}
+
 func (_m *MockManager) FindCurrent() ([]disk.Disk, error) {
 	ret := _m.ctrl.Call(_m, "FindCurrent")
 	ret0, _ := ret[0].([]disk.Disk)
@@ -115,6 +135,17 @@ func (_mr *_MockManagerRecorder) FindCurrent() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "FindCurrent")
 }
 
+func (_m *MockManager) FindCurrentNamed() (map[string]disk.Disk, error) {
+	ret := _m.ctrl.Call(_m, "FindCurrentNamed")
+	ret0, _ := ret[0].(map[string]disk.Disk)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockManagerRecorder) FindCurrentNamed() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "FindCurrentNamed")
+}
+
 func (_m *MockManager) FindUnused() ([]disk.Disk, error) {
 	ret := _m.ctrl.Call(_m, "FindUnused")
 	ret0, _ := ret[0].([]disk.Disk)
@@ -41,7 +41,7 @@ func (f *factory) NewInstance(
 	blobstore biblobstore.Blobstore,
 	logger boshlog.Logger,
 ) Instance {
-	stateBuilder := f.stateBuilderFactory.NewBuilder(blobstore, vm.AgentClient())
+	stateBuilder := f.stateBuilderFactory.NewBuilder(blobstore, vm.AgentClient(), vm.CID())
 
 	return NewInstance(
 		jobName,
@@ -20,14 +20,20 @@ type Instance interface {
 	JobName() string
 	ID() int
 	Disks() ([]bidisk.Disk, error)
-	WaitUntilReady(biinstallmanifest.Registry, biui.Stage) error
+	WaitUntilReady(biinstallmanifest.Registry, bideplmanifest.AgentWait, biui.Stage) error
 	UpdateDisks(bideplmanifest.Manifest, biui.Stage) ([]bidisk.Disk, error)
 	UpdateJobs(bideplmanifest.Manifest, biui.Stage) error
+	RunPostDeployScript(bideplmanifest.Manifest, biui.Stage) error
 	Delete(
 		pingTimeout time.Duration,
 		pingDelay time.Duration,
 		stage biui.Stage,
 	) error
+	Detach(
+		pingTimeout time.Duration,
+		pingDelay time.Duration,
+		stage biui.Stage,
+	) ([]bidisk.Disk, bool, error)
 }
 
 type instance struct {
@@ -80,6 +86,7 @@ func (i *instance) Disks() ([]bidisk.Disk, error) {
 
 func (i *instance) WaitUntilReady(
 	registryConfig biinstallmanifest.Registry,
+	agentWait bideplmanifest.AgentWait,
 	stage biui.Stage,
 ) error {
 	stepName := fmt.Sprintf("Waiting for the agent on VM '%s' to be ready", i.vm.CID())
@@ -106,7 +113,7 @@ func (i *instance) WaitUntilReady(
 			}
 		}
 
-		return i.vm.WaitUntilReady(10*time.Minute, 500*time.Millisecond)
+		return i.vm.WaitUntilReady(agentWait.Timeout, agentWait.PollInterval, agentWait.MaxErrors)
 	})
 
 	return err
@@ -118,7 +125,12 @@ func (i *instance) UpdateDisks(deploymentManifest bideplmanifest.Manifest, stage
 		return []bidisk.Disk{}, bosherr.WrapError(err, "Getting disk pool")
 	}
 
-	disks, err := i.vm.UpdateDisks(diskPool, stage)
+	namedDisks, err := deploymentManifest.NamedDisks(i.jobName)
+	if err != nil {
+		return []bidisk.Disk{}, bosherr.WrapError(err, "Getting named disks")
+	}
+
+	disks, err := i.vm.UpdateDisks(diskPool, namedDisks, stage)
 	if err != nil {
 		return disks, bosherr.WrapError(err, "Updating disks")
 	}
@@ -163,7 +175,7 @@ func (i *instance) UpdateJobs(
 			return bosherr.WrapError(err, "Applying the agent state")
 		}
 
-		err = i.vm.RunScript("pre-start", map[string]interface{}{})
+		err = i.runScript(bideplmanifest.ScriptPreStart, deploymentManifest.Update)
 		if err != nil {
 			return bosherr.WrapError(err, "Running the pre-start script")
 		}
@@ -179,14 +191,14 @@ func (i *instance) UpdateJobs(
 		return err
 	}
 
-	err = i.waitUntilJobsAreRunning(deploymentManifest.Update.UpdateWatchTime, stage)
+	err = i.waitUntilJobsAreRunning(deploymentManifest.WatchTime(i.jobName, i.id), stage)
 	if err != nil {
 		return err
 	}
 
 	stepName = fmt.Sprintf("Running the post-start scripts '%s/%d'", i.jobName, i.id)
 	err = stage.Perform(stepName, func() error {
-		err = i.vm.RunScript("post-start", map[string]interface{}{})
+		err = i.runScript(bideplmanifest.ScriptPostStart, deploymentManifest.Update)
 		if err != nil {
 			return bosherr.WrapError(err, "Running the post-start script")
 		}
@@ -196,6 +208,33 @@ func (i *instance) UpdateJobs(
 	return err
 }
 
+// RunPostDeployScript runs the post-deploy scripts of the jobs, once every
+// instance of the deployment is updated.
+func (i *instance) RunPostDeployScript(
+	deploymentManifest bideplmanifest.Manifest,
+	stage biui.Stage,
+) error {
+	stepName := fmt.Sprintf("Running the post-deploy scripts '%s/%d'", i.jobName, i.id)
+	return stage.Perform(stepName, func() error {
+		err := i.runScript(bideplmanifest.ScriptPostDeploy, deploymentManifest.Update)
+		if err != nil {
+			return bosherr.WrapError(err, "Running the post-deploy script")
+		}
+		return nil
+	})
+}
+
+// runScript tells the agent to run a lifecycle script of the jobs. With a
+// script timeout in the manifest, the agent kills the script once it expires.
+func (i *instance) runScript(script string, update bideplmanifest.Update) error {
+	timeout, found := update.ScriptTimeouts[script]
+	if !found {
+		return i.vm.RunScript(script, map[string]interface{}{})
+	}
+
+	return i.vm.RunScriptWithTimeout(script, map[string]interface{}{}, timeout)
+}
+
 func (i *instance) Delete(
 	pingTimeout time.Duration,
 	pingDelay time.Duration,
@@ -207,7 +246,7 @@ func (i *instance) Delete(
 	}
 
 	if vmExists {
-		if err = i.shutdown(pingTimeout, pingDelay, stage); err != nil {
+		if _, _, err = i.shutdown(pingTimeout, pingDelay, stage); err != nil {
 			return err
 		}
 	}
@@ -224,30 +263,61 @@ func (i *instance) Delete(
 	})
 }
 
+// Detach stops the jobs of the instance and unmounts and detaches its disks,
+// like Delete does before deleting the VM, but keeps the VM running. It
+// returns the detached disks, and is not reachable when the agent does not
+// respond, in which case nothing is detached.
+func (i *instance) Detach(
+	pingTimeout time.Duration,
+	pingDelay time.Duration,
+	stage biui.Stage,
+) ([]bidisk.Disk, bool, error) {
+	disks, reachable, err := i.shutdown(pingTimeout, pingDelay, stage)
+	if err != nil || !reachable {
+		return []bidisk.Disk{}, reachable, err
+	}
+
+	for _, disk := range disks {
+		stepName := fmt.Sprintf("Detaching disk '%s' from VM '%s'", disk.CID(), i.vm.CID())
+		err = stage.Perform(stepName, func() error {
+			if err := i.vm.DetachDisk(disk); err != nil {
+				return bosherr.WrapErrorf(err, "Detaching disk '%s' from VM '%s'", disk.CID(), i.vm.CID())
+			}
+			return nil
+		})
+		if err != nil {
+			return disks, true, err
+		}
+	}
+
+	return disks, true, nil
+}
+
+// shutdown stops the jobs and unmounts the disks, which it returns, unless
+// the agent does not respond.
 func (i *instance) shutdown(
 	pingTimeout time.Duration,
 	pingDelay time.Duration,
 	stage biui.Stage,
-) error {
+) ([]bidisk.Disk, bool, error) {
 	stepName := fmt.Sprintf("Waiting for the agent on VM '%s'", i.vm.CID())
 	waitingForAgentErr := stage.Perform(stepName, func() error {
-		if err := i.vm.WaitUntilReady(pingTimeout, pingDelay); err != nil {
+		if err := i.vm.WaitUntilReady(pingTimeout, pingDelay, 0); err != nil {
 			return bosherr.WrapError(err, "Agent unreachable")
 		}
 		return nil
 	})
 	if waitingForAgentErr != nil {
 		i.logger.Warn(i.logTag, "Gave up waiting for agent: %s", waitingForAgentErr.Error())
-		return nil
+		return []bidisk.Disk{}, false, nil
 	}
 
 	if err := i.stopJobs(stage); err != nil {
-		return err
+		return []bidisk.Disk{}, true, err
 	}
-	if err := i.unmountDisks(stage); err != nil {
-		return err
-	}
-	return nil
+
+	disks, err := i.unmountDisks(stage)
+	return disks, true, err
 }
 
 func (i *instance) waitUntilJobsAreRunning(updateWatchTime bideplmanifest.WatchTime, stage biui.Stage) error {
@@ -270,10 +340,10 @@ func (i *instance) stopJobs(stage biui.Stage) error {
 	})
 }
 
-func (i *instance) unmountDisks(stage biui.Stage) error {
+func (i *instance) unmountDisks(stage biui.Stage) ([]bidisk.Disk, error) {
 	disks, err := i.vm.Disks()
 	if err != nil {
-		return bosherr.WrapErrorf(err, "Getting VM '%s' disks", i.vm.CID())
+		return disks, bosherr.WrapErrorf(err, "Getting VM '%s' disks", i.vm.CID())
 	}
 
 	for _, disk := range disks {
@@ -285,8 +355,8 @@ func (i *instance) unmountDisks(stage biui.Stage) error {
 			return nil
 		})
 		if err != nil {
-			return err
+			return disks, err
 		}
 	}
-	return nil
+	return disks, nil
 }
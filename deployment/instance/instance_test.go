@@ -266,6 +266,49 @@ var _ = Describe("Instance", func() {
 		})
 	})
 
+	Describe("Detach", func() {
+		var disk *fakebidisk.FakeDisk
+
+		BeforeEach(func() {
+			disk = fakebidisk.NewFakeDisk("fake-disk")
+			fakeVM.ListDisksDisks = []bidisk.Disk{disk}
+		})
+
+		It("stops the jobs and unmounts and detaches the disks without deleting the vm", func() {
+			disks, reachable, err := instance.Detach(pingTimeout, pingDelay, fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reachable).To(BeTrue())
+			Expect(disks).To(Equal([]bidisk.Disk{disk}))
+
+			Expect(fakeVM.StopCalled).To(Equal(1))
+			Expect(fakeVM.UnmountDiskInputs).To(Equal([]fakebivm.UnmountDiskInput{{Disk: disk}}))
+			Expect(fakeVM.DetachDiskInputs).To(Equal([]fakebivm.DetachDiskInput{{Disk: disk}}))
+			Expect(fakeVM.DeleteCalled).To(Equal(0))
+
+			Expect(fakeStage.PerformCalls).To(Equal([]*fakebiui.PerformCall{
+				{Name: "Waiting for the agent on VM 'fake-vm-cid'"},
+				{Name: "Stopping jobs on instance 'fake-job-name/0'"},
+				{Name: "Unmounting disk 'fake-disk'"},
+				{Name: "Detaching disk 'fake-disk' from VM 'fake-vm-cid'"},
+			}))
+		})
+
+		Context("when agent fails to respond", func() {
+			BeforeEach(func() {
+				fakeVM.WaitUntilReadyErr = bosherr.Error("fake-wait-error")
+			})
+
+			It("is not reachable and leaves the disks attached", func() {
+				disks, reachable, err := instance.Detach(pingTimeout, pingDelay, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reachable).To(BeFalse())
+				Expect(disks).To(BeEmpty())
+
+				Expect(fakeVM.DetachDiskInputs).To(BeEmpty())
+			})
+		})
+	})
+
 	Describe("UpdateJobs", func() {
 		var (
 			deploymentManifest bideplmanifest.Manifest
@@ -278,7 +321,7 @@ var _ = Describe("Instance", func() {
 		)
 
 		BeforeEach(func() {
-			// manifest is only being used for the Update.UpdateWatchTime, otherwise it's just being passed through to the StateBuilder
+			// manifest is only being used for the watch times, otherwise it's just being passed through to the StateBuilder
 			deploymentManifest = bideplmanifest.Manifest{
 				Name: "fake-deployment-name",
 				Update: bideplmanifest.Update{
@@ -335,6 +378,23 @@ var _ = Describe("Instance", func() {
 			}))
 		})
 
+		Context("when the instance is a canary", func() {
+			BeforeEach(func() {
+				deploymentManifest.Update.Canaries = 1
+				deploymentManifest.Update.CanaryWatchTime = bideplmanifest.WatchTime{Start: 0, End: 2478}
+			})
+
+			It("waits for the canary watch time", func() {
+				err := instance.UpdateJobs(deploymentManifest, fakeStage)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeVM.WaitToBeRunningInputs).To(ContainElement(fakebivm.WaitInput{
+					MaxAttempts: 2,
+					Delay:       1 * time.Second,
+				}))
+			})
+		})
+
 		It("logs start and stop events to the eventLogger", func() {
 			err := instance.UpdateJobs(deploymentManifest, fakeStage)
 			Expect(err).NotTo(HaveOccurred())
@@ -398,6 +458,29 @@ var _ = Describe("Instance", func() {
 			})
 		})
 
+		Context("when a script has a timeout", func() {
+			BeforeEach(func() {
+				deploymentManifest.Update.ScriptTimeouts = map[string]time.Duration{
+					"pre-start": 10 * time.Millisecond,
+				}
+			})
+
+			It("runs the script with its timeout", func() {
+				err := instance.UpdateJobs(deploymentManifest, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(fakeVM.RunScriptTimeouts).To(Equal(map[string]time.Duration{"pre-start": 10 * time.Millisecond}))
+			})
+
+			It("returns the error when the script does not finish within its timeout", func() {
+				fakeVM.RunScriptErrors["pre-start"] = bosherr.Error("fake-timeout-error")
+
+				err := instance.UpdateJobs(deploymentManifest, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Running the pre-start script: fake-timeout-error"))
+				Expect(fakeVM.StartCalled).To(Equal(0))
+			})
+		})
+
 		Context("when running the post-start script fails", func() {
 			BeforeEach(func() {
 				fakeVM.RunScriptErrors["post-start"] = bosherr.Error("fake-run-script-error-poststart")
@@ -451,11 +534,40 @@ var _ = Describe("Instance", func() {
 		})
 	})
 
+	Describe("RunPostDeployScript", func() {
+		It("tells the agent to run the post-deploy scripts", func() {
+			err := instance.RunPostDeployScript(bideplmanifest.Manifest{}, fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeVM.RunScriptInputs).To(Equal([]string{"post-deploy"}))
+			Expect(fakeStage.PerformCalls).To(Equal([]*fakebiui.PerformCall{
+				{Name: "Running the post-deploy scripts 'fake-job-name/0'"},
+			}))
+		})
+
+		It("returns an error when the script fails", func() {
+			fakeVM.RunScriptErrors["post-deploy"] = bosherr.Error("fake-run-script-error")
+
+			err := instance.RunPostDeployScript(bideplmanifest.Manifest{}, fakeStage)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Running the post-deploy script: fake-run-script-error"))
+		})
+	})
+
 	Describe("WaitUntilReady", func() {
 		var (
 			registryConfig biinstallmanifest.Registry
+			agentWait      bideplmanifest.AgentWait
 		)
 
+		BeforeEach(func() {
+			agentWait = bideplmanifest.AgentWait{
+				Timeout:      10 * time.Minute,
+				PollInterval: 500 * time.Millisecond,
+				MaxErrors:    20,
+			}
+		})
+
 		Context("When raw private key is provided", func() {
 			BeforeEach(func() {
 				registryConfig = biinstallmanifest.Registry{
@@ -471,7 +583,7 @@ var _ = Describe("Instance", func() {
 			})
 
 			It("starts & stops the SSH tunnel", func() {
-				err := instance.WaitUntilReady(registryConfig, fakeStage)
+				err := instance.WaitUntilReady(registryConfig, agentWait, fakeStage)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(fakeSSHTunnelFactory.NewSSHTunnelOptions).To(Equal(bisshtunnel.Options{
 					User:              "fake-ssh-username",
@@ -486,16 +598,17 @@ var _ = Describe("Instance", func() {
 			})
 
 			It("waits for the vm", func() {
-				err := instance.WaitUntilReady(registryConfig, fakeStage)
+				err := instance.WaitUntilReady(registryConfig, agentWait, fakeStage)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(fakeVM.WaitUntilReadyInputs).To(ContainElement(fakebivm.WaitUntilReadyInput{
-					Timeout: 10 * time.Minute,
-					Delay:   500 * time.Millisecond,
+					Timeout:   10 * time.Minute,
+					Delay:     500 * time.Millisecond,
+					MaxErrors: 20,
 				}))
 			})
 
 			It("logs start and stop events to the eventLogger", func() {
-				err := instance.WaitUntilReady(registryConfig, fakeStage)
+				err := instance.WaitUntilReady(registryConfig, agentWait, fakeStage)
 				Expect(err).NotTo(HaveOccurred())
 
 				Expect(fakeStage.PerformCalls).To(Equal([]*fakebiui.PerformCall{
@@ -509,7 +622,7 @@ var _ = Describe("Instance", func() {
 				})
 
 				It("does not start ssh tunnel", func() {
-					err := instance.WaitUntilReady(registryConfig, fakeStage)
+					err := instance.WaitUntilReady(registryConfig, agentWait, fakeStage)
 					Expect(err).ToNot(HaveOccurred())
 					Expect(fakeSSHTunnel.Started).To(BeFalse())
 				})
@@ -521,7 +634,7 @@ var _ = Describe("Instance", func() {
 				})
 
 				It("does not start ssh tunnel", func() {
-					err := instance.WaitUntilReady(registryConfig, fakeStage)
+					err := instance.WaitUntilReady(registryConfig, agentWait, fakeStage)
 					Expect(err).ToNot(HaveOccurred())
 					Expect(fakeSSHTunnel.Started).To(BeFalse())
 				})
@@ -533,7 +646,7 @@ var _ = Describe("Instance", func() {
 				})
 
 				It("returns an error", func() {
-					err := instance.WaitUntilReady(registryConfig, fakeStage)
+					err := instance.WaitUntilReady(registryConfig, agentWait, fakeStage)
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(ContainSubstring("fake-ssh-tunnel-start-error"))
 				})
@@ -548,7 +661,7 @@ var _ = Describe("Instance", func() {
 				})
 
 				It("logs start and stop events to the eventLogger", func() {
-					err := instance.WaitUntilReady(registryConfig, fakeStage)
+					err := instance.WaitUntilReady(registryConfig, agentWait, fakeStage)
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(ContainSubstring("fake-wait-error"))
 
@@ -577,7 +690,7 @@ var _ = Describe("Instance", func() {
 			})
 
 			It("sets the SSHTunnel options", func() {
-				err := instance.WaitUntilReady(registryConfig, fakeStage)
+				err := instance.WaitUntilReady(registryConfig, agentWait, fakeStage)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(fakeSSHTunnelFactory.NewSSHTunnelOptions).To(Equal(bisshtunnel.Options{
 					User:              "fake-ssh-username",
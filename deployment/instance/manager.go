@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	biagentclient "github.com/cloudfoundry/bosh-agent/agentclient"
 	biblobstore "github.com/cloudfoundry/bosh-cli/blobstore"
 	bicloud "github.com/cloudfoundry/bosh-cli/cloud"
 	bidisk "github.com/cloudfoundry/bosh-cli/deployment/disk"
@@ -27,6 +28,23 @@ type Manager interface {
 		registryConfig biinstallmanifest.Registry,
 		eventLoggerStage biui.Stage,
 	) (Instance, []bidisk.Disk, error)
+	Replace(
+		jobName string,
+		id int,
+		deploymentManifest bideplmanifest.Manifest,
+		cloudStemcell bistemcell.CloudStemcell,
+		registryConfig biinstallmanifest.Registry,
+		pingTimeout time.Duration,
+		pingDelay time.Duration,
+		eventLoggerStage biui.Stage,
+	) (Instance, []bidisk.Disk, error)
+	Resume(
+		jobName string,
+		id int,
+		deploymentManifest bideplmanifest.Manifest,
+		registryConfig biinstallmanifest.Registry,
+		eventLoggerStage biui.Stage,
+	) (Instance, []bidisk.Disk, bool, error)
 	DeleteAll(
 		pingTimeout time.Duration,
 		pingDelay time.Duration,
@@ -89,6 +107,25 @@ func (m *manager) FindCurrent() ([]Instance, error) {
 		instances = append(instances, instance)
 	}
 
+	// a VM replaced by a deploy that did not finish is still deployed
+	replacedVM, replacedFound, err := m.vmManager.FindReplaced()
+	if err != nil {
+		return instances, bosherr.WrapError(err, "Finding replaced instances")
+	}
+
+	if replacedFound && (!found || replacedVM.CID() != vm.CID()) {
+		instance := m.instanceFactory.NewInstance(
+			"unknown",
+			0,
+			replacedVM,
+			m.vmManager,
+			m.sshTunnelFactory,
+			m.blobstore,
+			m.logger,
+		)
+		instances = append(instances, instance)
+	}
+
 	return instances, nil
 }
 
@@ -100,37 +137,310 @@ func (m *manager) Create(
 	registryConfig biinstallmanifest.Registry,
 	eventLoggerStage biui.Stage,
 ) (Instance, []bidisk.Disk, error) {
-	var vm bivm.VM
-	stepName := fmt.Sprintf("Creating VM for instance '%s/%d' from stemcell '%s'", jobName, id, cloudStemcell.CID())
-	err := eventLoggerStage.Perform(stepName, func() error {
-		var err error
-		vm, err = m.vmManager.Create(cloudStemcell, deploymentManifest)
-		if err != nil {
-			return bosherr.WrapError(err, "Creating VM")
+	vm, err := m.createVM(jobName, id, deploymentManifest, cloudStemcell, eventLoggerStage)
+	if err != nil {
+		return nil, []bidisk.Disk{}, err
+	}
+
+	instance := m.instanceFactory.NewInstance(jobName, id, vm, m.vmManager, m.sshTunnelFactory, m.blobstore, m.logger)
+
+	if err := instance.WaitUntilReady(registryConfig, deploymentManifest.Update.AgentWait, eventLoggerStage); err != nil {
+		return instance, []bidisk.Disk{}, bosherr.WrapError(err, "Waiting until instance is ready")
+	}
+
+	disks, err := instance.UpdateDisks(deploymentManifest, eventLoggerStage)
+	if err != nil {
+		return instance, disks, bosherr.WrapError(err, "Updating instance disks")
+	}
+
+	return instance, disks, err
+}
+
+// Replace creates a new VM for the instance before deleting the current VM,
+// which is kept until the new VM is ready so that the instance can go back
+// to it. The jobs on the current VM are stopped and its disks are detached
+// first, to be attached to the new VM. As the new VM is reached at the same
+// mbus URL, its agent is only taken to be ready once it reports other
+// addresses than the agent on the current VM. When the new VM does not
+// become ready, it is deleted and the current VM gets its disks and jobs
+// back.
+//
+// The current VM is recorded as replaced until it is deleted, so that it is
+// not lost when the deploy stops. A replaced VM left by an earlier deploy is
+// deleted first, keeping the VM that replaced it.
+func (m *manager) Replace(
+	jobName string,
+	id int,
+	deploymentManifest bideplmanifest.Manifest,
+	cloudStemcell bistemcell.CloudStemcell,
+	registryConfig biinstallmanifest.Registry,
+	pingTimeout time.Duration,
+	pingDelay time.Duration,
+	eventLoggerStage biui.Stage,
+) (Instance, []bidisk.Disk, error) {
+	err := m.deleteReplacedVM(eventLoggerStage)
+	if err != nil {
+		return nil, []bidisk.Disk{}, err
+	}
+
+	oldVM, found, err := m.vmManager.FindCurrent()
+	if err != nil {
+		return nil, []bidisk.Disk{}, bosherr.WrapError(err, "Finding current VM")
+	}
+
+	if !found {
+		return m.Create(jobName, id, deploymentManifest, cloudStemcell, registryConfig, eventLoggerStage)
+	}
+
+	oldInstance := m.instanceFactory.NewInstance(jobName, id, oldVM, m.vmManager, m.sshTunnelFactory, m.blobstore, m.logger)
+
+	oldDisks, reachable, err := oldInstance.Detach(pingTimeout, pingDelay, eventLoggerStage)
+	if err != nil {
+		return nil, []bidisk.Disk{}, bosherr.WrapErrorf(err, "Detaching disks of instance '%s/%d'", jobName, id)
+	}
+
+	if !reachable {
+		// without a responding agent, the current VM cannot be gone back to
+		if err = m.deleteVM(oldVM, eventLoggerStage); err != nil {
+			return nil, []bidisk.Disk{}, err
 		}
 
-		if err = cloudStemcell.PromoteAsCurrent(); err != nil {
-			return bosherr.WrapErrorf(err, "Promoting stemcell as current '%s'", cloudStemcell.CID())
+		return m.Create(jobName, id, deploymentManifest, cloudStemcell, registryConfig, eventLoggerStage)
+	}
+
+	oldState, err := oldVM.GetState()
+	if err != nil {
+		err = bosherr.WrapErrorf(err, "Getting state of VM '%s'", oldVM.CID())
+		return nil, []bidisk.Disk{}, m.keepReplacedVM(oldVM, nil, oldDisks, err, eventLoggerStage)
+	}
+
+	if err = m.vmManager.MarkReplaced(oldVM); err != nil {
+		return nil, []bidisk.Disk{}, m.keepReplacedVM(oldVM, nil, oldDisks, err, eventLoggerStage)
+	}
+
+	vm, err := m.createVM(jobName, id, deploymentManifest, cloudStemcell, eventLoggerStage)
+	if err != nil {
+		return nil, []bidisk.Disk{}, m.keepReplacedVM(oldVM, vm, oldDisks, err, eventLoggerStage)
+	}
+
+	instance := m.instanceFactory.NewInstance(jobName, id, vm, m.vmManager, m.sshTunnelFactory, m.blobstore, m.logger)
+
+	if err = instance.WaitUntilReady(registryConfig, deploymentManifest.Update.AgentWait, eventLoggerStage); err != nil {
+		err = bosherr.WrapError(err, "Waiting until instance is ready")
+		return nil, []bidisk.Disk{}, m.keepReplacedVM(oldVM, vm, oldDisks, err, eventLoggerStage)
+	}
+
+	newState, err := vm.GetState()
+	if err == nil && sameAddresses(oldState, newState) {
+		err = bosherr.Errorf("The agent at the mbus URL is still the agent on VM '%s'", oldVM.CID())
+	}
+	if err != nil {
+		err = bosherr.WrapErrorf(err, "Checking the agent on VM '%s'", vm.CID())
+		return nil, []bidisk.Disk{}, m.keepReplacedVM(oldVM, vm, oldDisks, err, eventLoggerStage)
+	}
+
+	disks, err := instance.UpdateDisks(deploymentManifest, eventLoggerStage)
+	if err != nil {
+		err = bosherr.WrapError(err, "Updating instance disks")
+		return nil, []bidisk.Disk{}, m.keepReplacedVM(oldVM, vm, oldDisks, err, eventLoggerStage)
+	}
+
+	// a replaced VM that fails to be deleted stays recorded, to be deleted
+	// by the next deploy
+	stepName := fmt.Sprintf("Deleting replaced VM '%s'", oldVM.CID())
+	err = eventLoggerStage.Perform(stepName, func() error {
+		err := m.vmManager.DeleteReplaced(oldVM)
+		cloudErr, ok := err.(bicloud.Error)
+		if ok && cloudErr.Type() == bicloud.VMNotFoundError {
+			return biui.NewSkipStageError(cloudErr, "VM not found")
 		}
+		return err
+	})
+	if err != nil {
+		return instance, disks, err
+	}
+
+	return instance, disks, nil
+}
 
+// deleteReplacedVM deletes the VM recorded as replaced by a deploy that did
+// not finish. The VM that replaced it was already given its disks, unless
+// it was never created, in which case the replaced VM is kept as current.
+func (m *manager) deleteReplacedVM(eventLoggerStage biui.Stage) error {
+	replacedVM, found, err := m.vmManager.FindReplaced()
+	if err != nil {
+		return bosherr.WrapError(err, "Finding replaced VM")
+	}
+
+	if !found {
 		return nil
+	}
+
+	currentVM, found, err := m.vmManager.FindCurrent()
+	if err != nil {
+		return bosherr.WrapError(err, "Finding current VM")
+	}
+
+	if found && currentVM.CID() == replacedVM.CID() {
+		return m.vmManager.PromoteAsCurrent(replacedVM)
+	}
+
+	stepName := fmt.Sprintf("Deleting replaced VM '%s'", replacedVM.CID())
+	return eventLoggerStage.Perform(stepName, func() error {
+		err := m.vmManager.DeleteReplaced(replacedVM)
+		cloudErr, ok := err.(bicloud.Error)
+		if ok && cloudErr.Type() == bicloud.VMNotFoundError {
+			return biui.NewSkipStageError(cloudErr, "VM not found")
+		}
+		return err
+	})
+}
+
+// keepReplacedVM deletes the VM created to replace oldVM, if any, records
+// oldVM as the current VM again, and gives it back its disks and jobs. It
+// returns replaceErr, along with the errors of going back to oldVM.
+func (m *manager) keepReplacedVM(oldVM bivm.VM, newVM bivm.VM, disks []bidisk.Disk, replaceErr error, eventLoggerStage biui.Stage) error {
+	if newVM == nil {
+		// the new VM may have been recorded before creating it failed
+		currentVM, found, err := m.vmManager.FindCurrent()
+		if err != nil {
+			return bosherr.NewMultiError(replaceErr, bosherr.WrapError(err, "Finding current VM"))
+		}
+
+		if found && currentVM.CID() != oldVM.CID() {
+			newVM = currentVM
+		}
+	}
+
+	if newVM != nil {
+		if err := m.deleteVM(newVM, eventLoggerStage); err != nil {
+			return bosherr.NewMultiError(replaceErr, err)
+		}
+	}
+
+	err := m.vmManager.PromoteAsCurrent(oldVM)
+	if err != nil {
+		return bosherr.NewMultiError(replaceErr, bosherr.WrapErrorf(err, "Keeping VM '%s'", oldVM.CID()))
+	}
+
+	for _, disk := range disks {
+		stepName := fmt.Sprintf("Attaching disk '%s' to VM '%s'", disk.CID(), oldVM.CID())
+		err = eventLoggerStage.Perform(stepName, func() error {
+			return oldVM.AttachDisk(disk)
+		})
+		if err != nil {
+			return bosherr.NewMultiError(replaceErr, err)
+		}
+	}
+
+	stepName := fmt.Sprintf("Starting jobs on VM '%s'", oldVM.CID())
+	err = eventLoggerStage.Perform(stepName, func() error {
+		return oldVM.Start()
 	})
 	if err != nil {
-		return nil, []bidisk.Disk{}, err
+		return bosherr.NewMultiError(replaceErr, err)
+	}
+
+	return replaceErr
+}
+
+func (m *manager) deleteVM(vm bivm.VM, eventLoggerStage biui.Stage) error {
+	stepName := fmt.Sprintf("Deleting VM '%s'", vm.CID())
+	return eventLoggerStage.Perform(stepName, func() error {
+		err := vm.Delete()
+		cloudErr, ok := err.(bicloud.Error)
+		if ok && cloudErr.Type() == bicloud.VMNotFoundError {
+			return biui.NewSkipStageError(cloudErr, "VM not found")
+		}
+		return err
+	})
+}
+
+// sameAddresses returns whether both agents report an address in common,
+// i.e. are most likely the same agent.
+func sameAddresses(oldState biagentclient.AgentState, newState biagentclient.AgentState) bool {
+	oldIPs := map[string]bool{}
+	for _, networkSpec := range oldState.NetworkSpecs {
+		if networkSpec.IP != "" {
+			oldIPs[networkSpec.IP] = true
+		}
+	}
+
+	for _, networkSpec := range newState.NetworkSpecs {
+		if oldIPs[networkSpec.IP] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resume picks up the current VM, created by a deploy that did not finish,
+// as the instance instead of creating a new VM. It is not found when the VM
+// is gone.
+func (m *manager) Resume(
+	jobName string,
+	id int,
+	deploymentManifest bideplmanifest.Manifest,
+	registryConfig biinstallmanifest.Registry,
+	eventLoggerStage biui.Stage,
+) (Instance, []bidisk.Disk, bool, error) {
+	vm, found, err := m.vmManager.FindCurrent()
+	if err != nil {
+		return nil, []bidisk.Disk{}, false, bosherr.WrapError(err, "Finding current VM")
+	}
+
+	if !found {
+		return nil, []bidisk.Disk{}, false, nil
+	}
+
+	exists, err := vm.Exists()
+	if err != nil {
+		return nil, []bidisk.Disk{}, false, bosherr.WrapErrorf(err, "Checking existence of VM '%s'", vm.CID())
+	}
+
+	if !exists {
+		return nil, []bidisk.Disk{}, false, nil
 	}
 
 	instance := m.instanceFactory.NewInstance(jobName, id, vm, m.vmManager, m.sshTunnelFactory, m.blobstore, m.logger)
 
-	if err := instance.WaitUntilReady(registryConfig, eventLoggerStage); err != nil {
-		return instance, []bidisk.Disk{}, bosherr.WrapError(err, "Waiting until instance is ready")
+	if err := instance.WaitUntilReady(registryConfig, deploymentManifest.Update.AgentWait, eventLoggerStage); err != nil {
+		return instance, []bidisk.Disk{}, true, bosherr.WrapError(err, "Waiting until instance is ready")
 	}
 
 	disks, err := instance.UpdateDisks(deploymentManifest, eventLoggerStage)
 	if err != nil {
-		return instance, disks, bosherr.WrapError(err, "Updating instance disks")
+		return instance, disks, true, bosherr.WrapError(err, "Updating instance disks")
 	}
 
-	return instance, disks, err
+	return instance, disks, true, nil
+}
+
+func (m *manager) createVM(
+	jobName string,
+	id int,
+	deploymentManifest bideplmanifest.Manifest,
+	cloudStemcell bistemcell.CloudStemcell,
+	eventLoggerStage biui.Stage,
+) (bivm.VM, error) {
+	var vm bivm.VM
+	stepName := fmt.Sprintf("Creating VM for instance '%s/%d' from stemcell '%s'", jobName, id, cloudStemcell.CID())
+	err := eventLoggerStage.Perform(stepName, func() error {
+		var err error
+		vm, err = m.vmManager.Create(cloudStemcell, deploymentManifest)
+		if err != nil {
+			return bosherr.WrapError(err, "Creating VM")
+		}
+
+		if err = cloudStemcell.PromoteAsCurrent(); err != nil {
+			return bosherr.WrapErrorf(err, "Promoting stemcell as current '%s'", cloudStemcell.CID())
+		}
+
+		return nil
+	})
+
+	return vm, err
 }
 
 func (m *manager) DeleteAll(
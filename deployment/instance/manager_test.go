@@ -18,6 +18,7 @@ import (
 	bidisk "github.com/cloudfoundry/bosh-cli/deployment/disk"
 	bideplmanifest "github.com/cloudfoundry/bosh-cli/deployment/manifest"
 	bisshtunnel "github.com/cloudfoundry/bosh-cli/deployment/sshtunnel"
+	bivm "github.com/cloudfoundry/bosh-cli/deployment/vm"
 	biinstallmanifest "github.com/cloudfoundry/bosh-cli/installation/manifest"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	biproperty "github.com/cloudfoundry/bosh-utils/property"
@@ -93,6 +94,39 @@ var _ = Describe("Manager", func() {
 		)
 	})
 
+	Describe("FindCurrent", func() {
+		var fakeVM *fakebivm.FakeVM
+
+		BeforeEach(func() {
+			fakeVM = fakebivm.NewFakeVM("fake-vm-cid")
+			fakeVM.AgentClientReturn = mock_agentclient.NewMockAgentClient(mockCtrl)
+			fakeVMManager.SetFindCurrentBehavior(fakeVM, true, nil)
+
+			mockStateBuilderFactory.EXPECT().NewBuilder(mockBlobstore, gomock.Any(), gomock.Any()).Return(mockStateBuilder).AnyTimes()
+		})
+
+		It("returns the instance of the current VM", func() {
+			instances, err := manager.FindCurrent()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instances).To(Equal([]Instance{
+				NewInstance("unknown", 0, fakeVM, fakeVMManager, fakeSSHTunnelFactory, mockStateBuilder, logger),
+			}))
+		})
+
+		It("also returns an instance for a VM recorded as replaced", func() {
+			fakeReplacedVM := fakebivm.NewFakeVM("fake-replaced-vm-cid")
+			fakeReplacedVM.AgentClientReturn = mock_agentclient.NewMockAgentClient(mockCtrl)
+			fakeVMManager.ReplacedVM = fakeReplacedVM
+
+			instances, err := manager.FindCurrent()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instances).To(Equal([]Instance{
+				NewInstance("unknown", 0, fakeVM, fakeVMManager, fakeSSHTunnelFactory, mockStateBuilder, logger),
+				NewInstance("unknown", 0, fakeReplacedVM, fakeVMManager, fakeSSHTunnelFactory, mockStateBuilder, logger),
+			}))
+		})
+	})
+
 	Describe("Create", func() {
 		var (
 			mockAgentClient    *mock_agentclient.MockAgentClient
@@ -132,7 +166,7 @@ var _ = Describe("Manager", func() {
 			fakeAgentState := agentclient.AgentState{}
 			fakeVM.GetStateResult = fakeAgentState
 
-			mockStateBuilderFactory.EXPECT().NewBuilder(mockBlobstore, mockAgentClient).Return(mockStateBuilder).AnyTimes()
+			mockStateBuilderFactory.EXPECT().NewBuilder(mockBlobstore, mockAgentClient, gomock.Any()).Return(mockStateBuilder).AnyTimes()
 			mockStateBuilder.EXPECT().Build(jobName, jobIndex, deploymentManifest, fakeStage, fakeAgentState).Return(mockState, nil).AnyTimes()
 			mockState.EXPECT().ToApplySpec().Return(applySpec).AnyTimes()
 		}
@@ -152,6 +186,10 @@ var _ = Describe("Manager", func() {
 						Start: 0,
 						End:   5478,
 					},
+					AgentWait: bideplmanifest.AgentWait{
+						Timeout:      10 * time.Minute,
+						PollInterval: 500 * time.Millisecond,
+					},
 				},
 				DiskPools: []bideplmanifest.DiskPool{
 					diskPool,
@@ -296,8 +334,9 @@ var _ = Describe("Manager", func() {
 
 			Expect(fakeVM.UpdateDisksInputs).To(Equal([]fakebivm.UpdateDisksInput{
 				{
-					DiskPool: diskPool,
-					Stage:    fakeStage,
+					DiskPool:   diskPool,
+					NamedDisks: []bideplmanifest.NamedDisk{},
+					Stage:      fakeStage,
 				},
 			}))
 		})
@@ -411,4 +450,313 @@ var _ = Describe("Manager", func() {
 			})
 		})
 	})
+
+	Describe("Resume", func() {
+		var (
+			mockAgentClient    *mock_agentclient.MockAgentClient
+			fakeVM             *fakebivm.FakeVM
+			deploymentManifest bideplmanifest.Manifest
+			registry           biinstallmanifest.Registry
+			expectedDisk       *fakebidisk.FakeDisk
+		)
+
+		BeforeEach(func() {
+			deploymentManifest = bideplmanifest.Manifest{
+				Jobs: []bideplmanifest.Job{
+					{
+						Name:      "fake-job-name",
+						Instances: 1,
+					},
+				},
+			}
+			registry = biinstallmanifest.Registry{}
+
+			fakeVM = fakebivm.NewFakeVM("fake-vm-cid")
+			mockAgentClient = mock_agentclient.NewMockAgentClient(mockCtrl)
+			fakeVM.AgentClientReturn = mockAgentClient
+
+			expectedDisk = fakebidisk.NewFakeDisk("fake-disk-cid")
+			fakeVM.UpdateDisksDisks = []bidisk.Disk{expectedDisk}
+
+			mockStateBuilderFactory.EXPECT().NewBuilder(mockBlobstore, mockAgentClient, gomock.Any()).Return(mockStateBuilder).AnyTimes()
+		})
+
+		Context("when the current VM exists", func() {
+			BeforeEach(func() {
+				fakeVMManager.SetFindCurrentBehavior(fakeVM, true, nil)
+			})
+
+			It("returns an Instance that wraps the current VM without creating a VM", func() {
+				instance, disks, found, err := manager.Resume("fake-job-name", 0, deploymentManifest, registry, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeTrue())
+				Expect(instance).To(Equal(NewInstance(
+					"fake-job-name",
+					0,
+					fakeVM,
+					fakeVMManager,
+					fakeSSHTunnelFactory,
+					mockStateBuilder,
+					logger,
+				)))
+				Expect(disks).To(Equal([]bidisk.Disk{expectedDisk}))
+
+				Expect(fakeVMManager.CreateInput).To(Equal(fakebivm.CreateInput{}))
+				Expect(fakeStage.PerformCalls).To(Equal([]*fakebiui.PerformCall{
+					{Name: "Waiting for the agent on VM 'fake-vm-cid' to be ready"},
+				}))
+			})
+
+			Context("when waiting for the agent fails", func() {
+				BeforeEach(func() {
+					fakeVM.WaitUntilReadyErr = errors.New("fake-wait-error")
+				})
+
+				It("returns an error", func() {
+					_, _, found, err := manager.Resume("fake-job-name", 0, deploymentManifest, registry, fakeStage)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-wait-error"))
+					Expect(found).To(BeTrue())
+				})
+			})
+		})
+
+		Context("when the current VM no longer exists", func() {
+			BeforeEach(func() {
+				fakeVM.ExistsFound = false
+				fakeVMManager.SetFindCurrentBehavior(fakeVM, true, nil)
+			})
+
+			It("is not found", func() {
+				_, _, found, err := manager.Resume("fake-job-name", 0, deploymentManifest, registry, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeFalse())
+			})
+		})
+
+		Context("when there is no current VM", func() {
+			BeforeEach(func() {
+				fakeVMManager.SetFindCurrentBehavior(nil, false, nil)
+			})
+
+			It("is not found", func() {
+				_, _, found, err := manager.Resume("fake-job-name", 0, deploymentManifest, registry, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("Replace", func() {
+		var (
+			mockAgentClient    *mock_agentclient.MockAgentClient
+			fakeOldVM          *fakebivm.FakeVM
+			fakeVM             *fakebivm.FakeVM
+			deploymentManifest bideplmanifest.Manifest
+			fakeCloudStemcell  *fakebistemcell.FakeCloudStemcell
+			registry           biinstallmanifest.Registry
+			oldDisk            *fakebidisk.FakeDisk
+			expectedDisk       *fakebidisk.FakeDisk
+			pingTimeout        = 10 * time.Second
+			pingDelay          = 500 * time.Millisecond
+		)
+
+		BeforeEach(func() {
+			deploymentManifest = bideplmanifest.Manifest{
+				Jobs: []bideplmanifest.Job{
+					{
+						Name:      "fake-job-name",
+						Instances: 1,
+					},
+				},
+			}
+			fakeCloudStemcell = fakebistemcell.NewFakeCloudStemcell("fake-stemcell-cid", "fake-stemcell-name", "fake-stemcell-version")
+			registry = biinstallmanifest.Registry{}
+
+			fakeOldVM = fakebivm.NewFakeVM("fake-old-vm-cid")
+			fakeOldVM.AgentClientReturn = mock_agentclient.NewMockAgentClient(mockCtrl)
+			fakeOldVM.GetStateResult = agentclient.AgentState{
+				NetworkSpecs: map[string]agentclient.NetworkSpec{"network-1": {IP: "10.0.0.1"}},
+			}
+			oldDisk = fakebidisk.NewFakeDisk("fake-disk-cid")
+			fakeOldVM.ListDisksDisks = []bidisk.Disk{oldDisk}
+			fakeVMManager.SetFindCurrentBehavior(fakeOldVM, true, nil)
+
+			fakeVM = fakebivm.NewFakeVM("fake-vm-cid")
+			fakeVMManager.CreateVM = fakeVM
+			mockAgentClient = mock_agentclient.NewMockAgentClient(mockCtrl)
+			fakeVM.AgentClientReturn = mockAgentClient
+			fakeVM.GetStateResult = agentclient.AgentState{
+				NetworkSpecs: map[string]agentclient.NetworkSpec{"network-1": {IP: "10.0.0.2"}},
+			}
+
+			expectedDisk = fakebidisk.NewFakeDisk("fake-disk-cid")
+			fakeVM.UpdateDisksDisks = []bidisk.Disk{expectedDisk}
+
+			mockStateBuilderFactory.EXPECT().NewBuilder(mockBlobstore, gomock.Any(), gomock.Any()).Return(mockStateBuilder).AnyTimes()
+		})
+
+		It("moves the disks to the new VM and deletes the current VM once the new VM is ready", func() {
+			instance, disks, err := manager.Replace("fake-job-name", 0, deploymentManifest, fakeCloudStemcell, registry, pingTimeout, pingDelay, fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance).To(Equal(NewInstance(
+				"fake-job-name",
+				0,
+				fakeVM,
+				fakeVMManager,
+				fakeSSHTunnelFactory,
+				mockStateBuilder,
+				logger,
+			)))
+			Expect(disks).To(Equal([]bidisk.Disk{expectedDisk}))
+
+			Expect(fakeOldVM.StopCalled).To(Equal(1))
+			Expect(fakeOldVM.UnmountDiskInputs).To(Equal([]fakebivm.UnmountDiskInput{{Disk: oldDisk}}))
+			Expect(fakeOldVM.DetachDiskInputs).To(Equal([]fakebivm.DetachDiskInput{{Disk: oldDisk}}))
+			Expect(fakeVMManager.DeletedReplaced).To(Equal([]bivm.VM{fakeOldVM}))
+			Expect(fakeVMManager.ReplacedVM).To(BeNil())
+			Expect(fakeVMManager.PromoteAsCurrentVMs).To(BeEmpty())
+
+			Expect(fakeStage.PerformCalls).To(Equal([]*fakebiui.PerformCall{
+				{Name: "Waiting for the agent on VM 'fake-old-vm-cid'"},
+				{Name: "Stopping jobs on instance 'fake-job-name/0'"},
+				{Name: "Unmounting disk 'fake-disk-cid'"},
+				{Name: "Detaching disk 'fake-disk-cid' from VM 'fake-old-vm-cid'"},
+				{Name: "Creating VM for instance 'fake-job-name/0' from stemcell 'fake-stemcell-cid'"},
+				{Name: "Waiting for the agent on VM 'fake-vm-cid' to be ready"},
+				{Name: "Deleting replaced VM 'fake-old-vm-cid'"},
+			}))
+		})
+
+		It("records the current VM as replaced before creating the new VM", func() {
+			fakeVMManager.CreateErr = errors.New("fake-create-vm-error")
+			fakeVMManager.PromoteAsCurrentErr = errors.New("fake-promote-error")
+
+			_, _, err := manager.Replace("fake-job-name", 0, deploymentManifest, fakeCloudStemcell, registry, pingTimeout, pingDelay, fakeStage)
+			Expect(err).To(HaveOccurred())
+
+			Expect(fakeVMManager.ReplacedVM).To(Equal(fakeOldVM))
+		})
+
+		Context("when the agent on the new VM does not become ready", func() {
+			BeforeEach(func() {
+				fakeVM.WaitUntilReadyErr = errors.New("fake-wait-error")
+			})
+
+			It("deletes the new VM and gives the disks and jobs back to the current VM", func() {
+				_, _, err := manager.Replace("fake-job-name", 0, deploymentManifest, fakeCloudStemcell, registry, pingTimeout, pingDelay, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-wait-error"))
+
+				Expect(fakeVM.DeleteCalled).To(Equal(1))
+				Expect(fakeOldVM.DeleteCalled).To(Equal(0))
+				Expect(fakeVMManager.PromoteAsCurrentVMs).To(Equal([]bivm.VM{fakeOldVM}))
+				Expect(fakeVMManager.ReplacedVM).To(BeNil())
+				Expect(fakeOldVM.AttachDiskInputs).To(Equal([]fakebivm.AttachDiskInput{{Disk: oldDisk}}))
+				Expect(fakeOldVM.StartCalled).To(Equal(1))
+			})
+		})
+
+		Context("when the agent at the mbus URL is still the agent on the current VM", func() {
+			BeforeEach(func() {
+				fakeVM.GetStateResult = fakeOldVM.GetStateResult
+			})
+
+			It("deletes the new VM and keeps the current VM", func() {
+				_, _, err := manager.Replace("fake-job-name", 0, deploymentManifest, fakeCloudStemcell, registry, pingTimeout, pingDelay, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("still the agent on VM 'fake-old-vm-cid'"))
+
+				Expect(fakeVM.DeleteCalled).To(Equal(1))
+				Expect(fakeVMManager.DeletedReplaced).To(BeEmpty())
+				Expect(fakeVMManager.PromoteAsCurrentVMs).To(Equal([]bivm.VM{fakeOldVM}))
+				Expect(fakeOldVM.StartCalled).To(Equal(1))
+			})
+		})
+
+		Context("when creating the new VM fails", func() {
+			BeforeEach(func() {
+				fakeVMManager.CreateErr = errors.New("fake-create-vm-error")
+			})
+
+			It("keeps the current VM", func() {
+				_, _, err := manager.Replace("fake-job-name", 0, deploymentManifest, fakeCloudStemcell, registry, pingTimeout, pingDelay, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-create-vm-error"))
+
+				Expect(fakeVMManager.DeletedReplaced).To(BeEmpty())
+				Expect(fakeVMManager.PromoteAsCurrentVMs).To(Equal([]bivm.VM{fakeOldVM}))
+				Expect(fakeOldVM.AttachDiskInputs).To(Equal([]fakebivm.AttachDiskInput{{Disk: oldDisk}}))
+				Expect(fakeOldVM.StartCalled).To(Equal(1))
+			})
+		})
+
+		Context("when deleting the current VM fails", func() {
+			BeforeEach(func() {
+				fakeVMManager.DeleteReplacedErr = errors.New("fake-delete-vm-error")
+			})
+
+			It("keeps the current VM recorded as replaced", func() {
+				instance, _, err := manager.Replace("fake-job-name", 0, deploymentManifest, fakeCloudStemcell, registry, pingTimeout, pingDelay, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-delete-vm-error"))
+				Expect(instance).ToNot(BeNil())
+
+				Expect(fakeVMManager.ReplacedVM).To(Equal(fakeOldVM))
+				Expect(fakeVMManager.PromoteAsCurrentVMs).To(BeEmpty())
+			})
+		})
+
+		Context("when the agent on the current VM does not respond", func() {
+			BeforeEach(func() {
+				fakeOldVM.WaitUntilReadyErr = errors.New("fake-wait-error")
+			})
+
+			It("deletes the current VM before creating the new VM", func() {
+				_, _, err := manager.Replace("fake-job-name", 0, deploymentManifest, fakeCloudStemcell, registry, pingTimeout, pingDelay, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeOldVM.DeleteCalled).To(Equal(1))
+				Expect(fakeVMManager.DeletedReplaced).To(BeEmpty())
+				Expect(fakeVMManager.CreateInput).To(Equal(fakebivm.CreateInput{
+					Stemcell: fakeCloudStemcell,
+					Manifest: deploymentManifest,
+				}))
+			})
+		})
+
+		Context("when a VM replaced by an earlier deploy is recorded", func() {
+			var fakeReplacedVM *fakebivm.FakeVM
+
+			BeforeEach(func() {
+				fakeReplacedVM = fakebivm.NewFakeVM("fake-replaced-vm-cid")
+				fakeVMManager.ReplacedVM = fakeReplacedVM
+			})
+
+			It("deletes the replaced VM first", func() {
+				_, _, err := manager.Replace("fake-job-name", 0, deploymentManifest, fakeCloudStemcell, registry, pingTimeout, pingDelay, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeVMManager.DeletedReplaced).To(Equal([]bivm.VM{fakeReplacedVM, fakeOldVM}))
+				Expect(fakeStage.PerformCalls[0].Name).To(Equal("Deleting replaced VM 'fake-replaced-vm-cid'"))
+			})
+		})
+
+		Context("when there is no current VM", func() {
+			BeforeEach(func() {
+				fakeVMManager.SetFindCurrentBehavior(nil, false, nil)
+			})
+
+			It("creates a VM", func() {
+				_, _, err := manager.Replace("fake-job-name", 0, deploymentManifest, fakeCloudStemcell, registry, pingTimeout, pingDelay, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeVMManager.CreateInput).To(Equal(fakebivm.CreateInput{
+					Stemcell: fakeCloudStemcell,
+					Manifest: deploymentManifest,
+				}))
+				Expect(fakeVMManager.DeletedReplaced).To(BeEmpty())
+			})
+		})
+	})
 })
@@ -45,6 +45,18 @@ func (_mr *_MockInstanceRecorder) Delete(arg0, arg1, arg2 interface{}) *gomock.C
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Delete", arg0, arg1, arg2)
 }
 
+func (_m *MockInstance) Detach(_param0 time.Duration, _param1 time.Duration, _param2 ui.Stage) ([]disk.Disk, bool, error) {
+	ret := _m.ctrl.Call(_m, "Detach", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]disk.Disk)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+func (_mr *_MockInstanceRecorder) Detach(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Detach", arg0, arg1, arg2)
+}
+
 func (_m *MockInstance) Disks() ([]disk.Disk, error) {
 	ret := _m.ctrl.Call(_m, "Disks")
 	ret0, _ := ret[0].([]disk.Disk)
@@ -87,6 +99,16 @@ func (_mr *_MockInstanceRecorder) UpdateDisks(arg0, arg1 interface{}) *gomock.Ca
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdateDisks", arg0, arg1)
 }
 
+func (_m *MockInstance) RunPostDeployScript(_param0 manifest.Manifest, _param1 ui.Stage) error {
+	ret := _m.ctrl.Call(_m, "RunPostDeployScript", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockInstanceRecorder) RunPostDeployScript(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RunPostDeployScript", arg0, arg1)
+}
+
 func (_m *MockInstance) UpdateJobs(_param0 manifest.Manifest, _param1 ui.Stage) error {
 	ret := _m.ctrl.Call(_m, "UpdateJobs", _param0, _param1)
 	ret0, _ := ret[0].(error)
@@ -97,14 +119,14 @@ func (_mr *_MockInstanceRecorder) UpdateJobs(arg0, arg1 interface{}) *gomock.Cal
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdateJobs", arg0, arg1)
 }
 
-func (_m *MockInstance) WaitUntilReady(_param0 manifest0.Registry, _param1 ui.Stage) error {
-	ret := _m.ctrl.Call(_m, "WaitUntilReady", _param0, _param1)
+func (_m *MockInstance) WaitUntilReady(_param0 manifest0.Registry, _param1 manifest.AgentWait, _param2 ui.Stage) error {
+	ret := _m.ctrl.Call(_m, "WaitUntilReady", _param0, _param1, _param2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-func (_mr *_MockInstanceRecorder) WaitUntilReady(arg0, arg1 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "WaitUntilReady", arg0, arg1)
+func (_mr *_MockInstanceRecorder) WaitUntilReady(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "WaitUntilReady", arg0, arg1, arg2)
 }
 
 // Mock of Manager interface
@@ -140,6 +162,31 @@ func (_mr *_MockManagerRecorder) Create(arg0, arg1, arg2, arg3, arg4, arg5 inter
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Create", arg0, arg1, arg2, arg3, arg4, arg5)
 }
 
+func (_m *MockManager) Replace(_param0 string, _param1 int, _param2 manifest.Manifest, _param3 stemcell.CloudStemcell, _param4 manifest0.Registry, _param5 time.Duration, _param6 time.Duration, _param7 ui.Stage) (instance.Instance, []disk.Disk, error) {
+	ret := _m.ctrl.Call(_m, "Replace", _param0, _param1, _param2, _param3, _param4, _param5, _param6, _param7)
+	ret0, _ := ret[0].(instance.Instance)
+	ret1, _ := ret[1].([]disk.Disk)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+func (_mr *_MockManagerRecorder) Replace(arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Replace", arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7)
+}
+
+func (_m *MockManager) Resume(_param0 string, _param1 int, _param2 manifest.Manifest, _param3 manifest0.Registry, _param4 ui.Stage) (instance.Instance, []disk.Disk, bool, error) {
+	ret := _m.ctrl.Call(_m, "Resume", _param0, _param1, _param2, _param3, _param4)
+	ret0, _ := ret[0].(instance.Instance)
+	ret1, _ := ret[1].([]disk.Disk)
+	ret2, _ := ret[2].(bool)
+	ret3, _ := ret[3].(error)
+	return ret0, ret1, ret2, ret3
+}
+
+func (_mr *_MockManagerRecorder) Resume(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Resume", arg0, arg1, arg2, arg3, arg4)
+}
+
 func (_m *MockManager) DeleteAll(_param0 time.Duration, _param1 time.Duration, _param2 ui.Stage) error {
 	ret := _m.ctrl.Call(_m, "DeleteAll", _param0, _param1, _param2)
 	ret0, _ := ret[0].(error)
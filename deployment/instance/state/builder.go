@@ -68,22 +68,72 @@ func (b *builder) Build(jobName string, instanceID int, deploymentManifest bidep
 		return nil, bosherr.Errorf("Job '%s' not found in deployment manifest", jobName)
 	}
 
-	releaseJobs, err := b.resolveJobs(deploymentJob.Templates)
-	if err != nil {
-		return nil, bosherr.WrapErrorf(err, "Resolving jobs for instance '%s/%d'", jobName, instanceID)
-	}
+	jobRefs := append([]bideplmanifest.ReleaseJobRef{}, deploymentJob.Templates...)
 
 	releaseJobProperties := make(map[string]*biproperty.Map)
 	for _, releaseJob := range deploymentJob.Templates {
 		releaseJobProperties[releaseJob.Name] = releaseJob.Properties
 	}
 
+	releaseJobLinks, err := deploymentManifest.ResolveLinks(jobName, b.releaseJobResolver)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Resolving links for instance '%s/%d'", jobName, instanceID)
+	}
+
+	// Errands are colocated on the instance: their release jobs are rendered
+	// with the properties and links of the errand and installed with the
+	// others, and the agent only runs them when asked to.
+	for _, errandJob := range deploymentManifest.ErrandJobs() {
+		errandProperties, err := deploymentManifest.ResolvedJobProperties(errandJob.Name)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Resolving properties of errand '%s'", errandJob.Name)
+		}
+
+		errandLinks, err := deploymentManifest.ResolveLinks(errandJob.Name, b.releaseJobResolver)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Resolving links of errand '%s'", errandJob.Name)
+		}
+
+		for _, releaseJob := range errandJob.Templates {
+			jobRefs = append(jobRefs, releaseJob)
+
+			if releaseJob.Properties != nil {
+				releaseJobProperties[releaseJob.Name] = releaseJob.Properties
+			} else {
+				releaseJobProperties[releaseJob.Name] = &errandProperties
+			}
+
+			releaseJobLinks[releaseJob.Name] = errandLinks[releaseJob.Name]
+		}
+	}
+
+	releaseJobs, err := b.resolveJobs(jobRefs)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Resolving jobs for instance '%s/%d'", jobName, instanceID)
+	}
+
 	defaultAddress, err := b.defaultAddress(initialState.NetworkInterfaces(), agentState)
 	if err != nil {
 		return nil, err
 	}
 
-	renderedJobTemplates, err := b.renderJobTemplates(releaseJobs, releaseJobProperties, deploymentJob.Properties, deploymentManifest.Properties, deploymentManifest.Name, defaultAddress, stage)
+	stemcell, err := deploymentManifest.Stemcell(jobName)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Finding stemcell for instance '%s/%d'", jobName, instanceID)
+	}
+
+	instance := bitemplate.InstanceSpec{
+		Name:     jobName,
+		Index:    instanceID,
+		AZ:       deploymentJob.InstanceAZ(instanceID),
+		Address:  defaultAddress,
+		Networks: b.networkSpecs(initialState.NetworkInterfaces(), agentState),
+
+		DNSDomainName:   deploymentManifest.Features.DNSDomainName,
+		UseDNSAddresses: deploymentManifest.Features.UseDNSAddresses,
+	}
+
+	renderedJobTemplates, err := b.renderJobTemplates(releaseJobs, releaseJobProperties, releaseJobLinks, deploymentJob.Properties, deploymentManifest.Properties, deploymentManifest.Name, instance, stemcell.OS, stage)
 	if err != nil {
 		return nil, bosherr.WrapErrorf(err, "Rendering job templates for instance '%s/%d'", jobName, instanceID)
 	}
@@ -138,7 +188,7 @@ func (b *builder) BuildInitialState(jobName string, instanceID int, deploymentMa
 		return nil, bosherr.Errorf("Job '%s' not found in deployment manifest", jobName)
 	}
 
-	networkInterfaces, err := deploymentManifest.NetworkInterfaces(deploymentJob.Name)
+	networkInterfaces, err := deploymentManifest.InstanceNetworkInterfaces(deploymentJob.Name, instanceID)
 	if err != nil {
 		return nil, bosherr.WrapErrorf(err, "Finding networks for job '%s", jobName)
 	}
@@ -183,10 +233,12 @@ func (b *builder) resolveJobs(jobRefs []bideplmanifest.ReleaseJobRef) ([]bireljo
 func (b *builder) renderJobTemplates(
 	releaseJobs []bireljob.Job,
 	releaseJobProperties map[string]*biproperty.Map,
+	releaseJobLinks map[string]biproperty.Map,
 	jobProperties biproperty.Map,
 	globalProperties biproperty.Map,
 	deploymentName string,
-	address string,
+	instance bitemplate.InstanceSpec,
+	stemcellOS string,
 	stage biui.Stage,
 ) (renderedJobs, error) {
 	var (
@@ -194,7 +246,7 @@ func (b *builder) renderJobTemplates(
 		blobID                 string
 	)
 	err := stage.Perform("Rendering job templates", func() error {
-		renderedJobList, err := b.jobListRenderer.Render(releaseJobs, releaseJobProperties, jobProperties, globalProperties, deploymentName, address)
+		renderedJobList, err := b.jobListRenderer.Render(releaseJobs, releaseJobProperties, releaseJobLinks, jobProperties, globalProperties, deploymentName, instance, stemcellOS)
 		if err != nil {
 			return err
 		}
@@ -248,6 +300,24 @@ func (b *builder) defaultAddress(networkRefs []NetworkRef, agentState agentclien
 	return "", errors.New("Must specify default network")
 }
 
+// networkSpecs describes the networks of the instance to templates, with the
+// IPs of dynamic networks as reported by the agent.
+func (b *builder) networkSpecs(networkRefs []NetworkRef, agentState agentclient.AgentState) map[string]bitemplate.NetworkSpec {
+	networkInterfaces := map[string]biproperty.Map{}
+
+	for _, ref := range networkRefs {
+		networkInterface := biproperty.Map{}
+		for k, v := range ref.Interface {
+			networkInterface[k] = v
+		}
+
+		networkInterface["ip"] = networkIp(ref, agentState)
+		networkInterfaces[ref.Name] = networkInterface
+	}
+
+	return bitemplate.NewNetworkSpecs(networkInterfaces)
+}
+
 func networkIp(networkRef NetworkRef, agentState agentclient.AgentState) string {
 	if "dynamic" == networkRef.Interface["type"].(string) {
 		return agentState.NetworkSpecs[networkRef.Name].IP
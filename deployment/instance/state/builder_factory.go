@@ -3,6 +3,7 @@ package state
 import (
 	biagentclient "github.com/cloudfoundry/bosh-agent/agentclient"
 	biblobstore "github.com/cloudfoundry/bosh-cli/blobstore"
+	biconfig "github.com/cloudfoundry/bosh-cli/config"
 	bideplrel "github.com/cloudfoundry/bosh-cli/deployment/release"
 	bistatejob "github.com/cloudfoundry/bosh-cli/state/job"
 	bistatepkg "github.com/cloudfoundry/bosh-cli/state/pkg"
@@ -11,11 +12,14 @@ import (
 )
 
 type BuilderFactory interface {
-	NewBuilder(biblobstore.Blobstore, biagentclient.AgentClient) Builder
+	NewBuilder(blobstore biblobstore.Blobstore, agentClient biagentclient.AgentClient, vmCID string) Builder
 }
 
 type builderFactory struct {
-	packageRepo               bistatepkg.CompiledPackageRepo
+	packageCache              bistatepkg.CompiledPackageCache
+	stemcellRepo              biconfig.StemcellRepo
+	checkpointRepo            biconfig.CheckpointRepo
+	compileWorkers            int
 	releaseJobResolver        bideplrel.JobResolver
 	jobRenderer               bitemplate.JobListRenderer
 	renderedJobListCompressor bitemplate.RenderedJobListCompressor
@@ -23,14 +27,20 @@ type builderFactory struct {
 }
 
 func NewBuilderFactory(
-	packageRepo bistatepkg.CompiledPackageRepo,
+	packageCache bistatepkg.CompiledPackageCache,
+	stemcellRepo biconfig.StemcellRepo,
+	checkpointRepo biconfig.CheckpointRepo,
+	compileWorkers int,
 	releaseJobResolver bideplrel.JobResolver,
 	jobRenderer bitemplate.JobListRenderer,
 	renderedJobListCompressor bitemplate.RenderedJobListCompressor,
 	logger boshlog.Logger,
 ) BuilderFactory {
 	return &builderFactory{
-		packageRepo:               packageRepo,
+		packageCache:              packageCache,
+		stemcellRepo:              stemcellRepo,
+		checkpointRepo:            checkpointRepo,
+		compileWorkers:            compileWorkers,
 		releaseJobResolver:        releaseJobResolver,
 		jobRenderer:               jobRenderer,
 		renderedJobListCompressor: renderedJobListCompressor,
@@ -38,9 +48,16 @@ func NewBuilderFactory(
 	}
 }
 
-func (f *builderFactory) NewBuilder(blobstore biblobstore.Blobstore, agentClient biagentclient.AgentClient) Builder {
-	packageCompiler := NewRemotePackageCompiler(blobstore, agentClient, f.packageRepo)
-	jobDependencyCompiler := bistatejob.NewDependencyCompiler(packageCompiler, f.logger)
+// NewBuilder returns a builder compiling packages on the VM of the agent. The
+// compiled packages are recorded per VM with the deploy checkpoint, as they
+// are stored in the blobstore of that VM, and are not compiled again when an
+// interrupted deploy resumes on the same VM.
+func (f *builderFactory) NewBuilder(blobstore biblobstore.Blobstore, agentClient biagentclient.AgentClient, vmCID string) Builder {
+	packageRepo := bistatepkg.NewCompiledPackageRepo(f.checkpointRepo.CompiledPackageIndex(vmCID))
+	packageCompiler := NewRemotePackageCompiler(blobstore, agentClient, packageRepo)
+	packageCompiler = NewCachingPackageCompiler(packageCompiler, blobstore, packageRepo, f.packageCache, f.stemcellRepo, f.logger)
+	packageCompiler = NewResumingPackageCompiler(packageCompiler, packageRepo)
+	jobDependencyCompiler := bistatejob.NewParallelDependencyCompiler(packageCompiler, f.compileWorkers, f.logger)
 
 	return NewBuilder(
 		f.releaseJobResolver,
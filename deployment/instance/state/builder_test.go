@@ -18,6 +18,7 @@ import (
 	. "github.com/cloudfoundry/bosh-cli/release/resource"
 	bistatejob "github.com/cloudfoundry/bosh-cli/state/job"
 	mock_state_job "github.com/cloudfoundry/bosh-cli/state/job/mocks"
+	bitemplate "github.com/cloudfoundry/bosh-cli/templatescompiler"
 	mock_template "github.com/cloudfoundry/bosh-cli/templatescompiler/mocks"
 	fakebiui "github.com/cloudfoundry/bosh-cli/ui/fakes"
 )
@@ -70,7 +71,8 @@ var _ = Describe("Builder", func() {
 				Name: "fake-deployment-name",
 				Jobs: []bideplmanifest.Job{
 					{
-						Name: "fake-deployment-job-name",
+						Name:         "fake-deployment-job-name",
+						ResourcePool: "fake-resource-pool-name",
 						Networks: []bideplmanifest.JobNetwork{
 							{
 								Name:      "fake-network-name",
@@ -91,6 +93,14 @@ var _ = Describe("Builder", func() {
 						},
 					},
 				},
+				ResourcePools: []bideplmanifest.ResourcePool{
+					{
+						Name: "fake-resource-pool-name",
+						Stemcell: bideplmanifest.StemcellRef{
+							OS: "ubuntu-trusty",
+						},
+					},
+				},
 				Networks: []bideplmanifest.Network{
 					{
 						Name: "fake-network-name",
@@ -143,6 +153,17 @@ var _ = Describe("Builder", func() {
 				ConfigurationHash: "unused-configuration-hash",
 			}))
 		})
+
+		It("uses the static IPs of the instance", func() {
+			deploymentManifest.Jobs[0].Networks[0].StaticIPs = []string{"1.2.3.4", "1.2.3.5"}
+
+			state, err := stateBuilder.BuildInitialState(jobName, 1, deploymentManifest)
+			Expect(err).ToNot(HaveOccurred())
+
+			applySpec := state.ToApplySpec()
+			Expect(applySpec.Index).To(Equal(1))
+			Expect(applySpec.Networks["fake-network-name"].(map[string]interface{})["ip"]).To(Equal("1.2.3.5"))
+		})
 	})
 
 	Describe("Build", func() {
@@ -156,11 +177,14 @@ var _ = Describe("Builder", func() {
 			fakeStage          *fakebiui.FakeStage
 
 			agentState            biac.AgentState
-			expectedIP            string
+			expectedInstance      bitemplate.InstanceSpec
 			releasePackageLibyaml *boshpkg.Package
 			releasePackageRuby    *boshpkg.Package
 			releasePackageCPI     *boshpkg.Package
 
+			errandReleaseJobs          []boshjob.Job
+			errandReleaseJobProperties map[string]*biproperty.Map
+
 			expectCompile *gomock.Call
 		)
 
@@ -170,13 +194,22 @@ var _ = Describe("Builder", func() {
 
 			jobName = "fake-deployment-job-name"
 			instanceID = 0
-			expectedIP = "1.2.3.4"
+			errandReleaseJobs = []boshjob.Job{}
+			errandReleaseJobProperties = map[string]*biproperty.Map{}
+			expectedInstance = bitemplate.InstanceSpec{
+				Name:    "fake-deployment-job-name",
+				Address: "1.2.3.4",
+				Networks: map[string]bitemplate.NetworkSpec{
+					"fake-network-name": {IP: "1.2.3.4", Default: []string{"dns", "gateway"}},
+				},
+			}
 
 			deploymentManifest = bideplmanifest.Manifest{
 				Name: "fake-deployment-name",
 				Jobs: []bideplmanifest.Job{
 					{
-						Name: "fake-deployment-job-name",
+						Name:         "fake-deployment-job-name",
+						ResourcePool: "fake-resource-pool-name",
 						Networks: []bideplmanifest.JobNetwork{
 							{
 								Name:      "fake-network-name",
@@ -197,6 +230,14 @@ var _ = Describe("Builder", func() {
 						},
 					},
 				},
+				ResourcePools: []bideplmanifest.ResourcePool{
+					{
+						Name: "fake-resource-pool-name",
+						Stemcell: bideplmanifest.StemcellRef{
+							OS: "ubuntu-trusty",
+						},
+					},
+				},
 				Networks: []bideplmanifest.Network{
 					{
 						Name: "fake-network-name",
@@ -246,9 +287,10 @@ var _ = Describe("Builder", func() {
 			releaseJob := *boshjob.NewJob(NewResource("job-name", "job-fp", nil))
 			releaseJob.AttachPackages([]*boshpkg.Package{releasePackageCPI, releasePackageRuby})
 
-			mockReleaseJobResolver.EXPECT().Resolve("job-name", "fake-release-name").Return(releaseJob, nil)
+			// the job is resolved again to resolve links
+			mockReleaseJobResolver.EXPECT().Resolve("job-name", "fake-release-name").Return(releaseJob, nil).AnyTimes()
 
-			releaseJobs := []boshjob.Job{releaseJob}
+			releaseJobs := append([]boshjob.Job{releaseJob}, errandReleaseJobs...)
 			compiledPackageRefs := []bistatejob.CompiledPackageRef{
 				{
 					Name:        "libyaml",
@@ -284,7 +326,16 @@ var _ = Describe("Builder", func() {
 				"fake-job-property": "fake-global-property-value",
 			}
 
-			mockJobListRenderer.EXPECT().Render(releaseJobs, releaseJobProperties, jobProperties, globalProperties, "fake-deployment-name", expectedIP).Return(mockRenderedJobList, nil)
+			releaseJobLinks := map[string]biproperty.Map{
+				"job-name": biproperty.Map{},
+			}
+
+			for _, errandReleaseJob := range errandReleaseJobs {
+				releaseJobProperties[errandReleaseJob.Name()] = errandReleaseJobProperties[errandReleaseJob.Name()]
+				releaseJobLinks[errandReleaseJob.Name()] = biproperty.Map{}
+			}
+
+			mockJobListRenderer.EXPECT().Render(releaseJobs, releaseJobProperties, releaseJobLinks, jobProperties, globalProperties, "fake-deployment-name", expectedInstance, "ubuntu-trusty").Return(mockRenderedJobList, nil)
 
 			mockRenderedJobList.EXPECT().DeleteSilently()
 
@@ -328,7 +379,10 @@ var _ = Describe("Builder", func() {
 				BeforeEach(func() {
 					deploymentManifest.Jobs[0].Networks[0].StaticIPs = nil
 					deploymentManifest.Networks[0].Type = "dynamic"
-					expectedIP = "1.2.3.5"
+					expectedInstance.Address = "1.2.3.5"
+					expectedInstance.Networks = map[string]bitemplate.NetworkSpec{
+						"fake-network-name": {IP: "1.2.3.5", Default: []string{"dns", "gateway"}},
+					}
 				})
 
 				It("should not fail", func() {
@@ -351,7 +405,11 @@ var _ = Describe("Builder", func() {
 
 			Context("multiple networks", func() {
 				BeforeEach(func() {
-					expectedIP = "1.2.3.6"
+					expectedInstance.Address = "1.2.3.6"
+					expectedInstance.Networks = map[string]bitemplate.NetworkSpec{
+						"fake-network-name":         {IP: "1.2.3.4"},
+						"fake-dynamic-network-name": {IP: "1.2.3.6", Default: []string{"dns", "gateway"}},
+					}
 					deploymentManifest.Networks = append(
 						deploymentManifest.Networks,
 						bideplmanifest.Network{
@@ -393,6 +451,52 @@ var _ = Describe("Builder", func() {
 			})
 		})
 
+		Context("when the instance group has AZs", func() {
+			BeforeEach(func() {
+				deploymentManifest.Jobs[0].AZs = []string{"z1", "z2"}
+				expectedInstance.AZ = "z1"
+			})
+
+			It("renders the job templates for the AZ of the instance", func() {
+				_, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, fakeStage, agentState)
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the manifest has errands", func() {
+			BeforeEach(func() {
+				deploymentManifest.Jobs = append(deploymentManifest.Jobs, bideplmanifest.Job{
+					Name:      "smoke-tests",
+					Lifecycle: bideplmanifest.JobLifecycleErrand,
+					Templates: []bideplmanifest.ReleaseJobRef{
+						{Name: "smoke-tests", Release: "fake-release-name"},
+					},
+					Properties: biproperty.Map{
+						"fake-errand-property": "fake-errand-property-value",
+					},
+				})
+
+				errandReleaseJob := *boshjob.NewJob(NewResource("smoke-tests", "smoke-tests-fp", nil))
+				mockReleaseJobResolver.EXPECT().Resolve("smoke-tests", "fake-release-name").Return(errandReleaseJob, nil).AnyTimes()
+
+				errandReleaseJobs = []boshjob.Job{errandReleaseJob}
+				errandReleaseJobProperties["smoke-tests"] = &biproperty.Map{
+					"fake-job-property":    "fake-global-property-value",
+					"fake-errand-property": "fake-errand-property-value",
+				}
+			})
+
+			It("installs the errand release jobs rendered with the properties of the errand", func() {
+				state, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, fakeStage, agentState)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(state.RenderedJobs()).To(Equal([]JobRef{
+					{Name: "job-name", Version: "job-fp"},
+					{Name: "smoke-tests", Version: "smoke-tests-fp"},
+				}))
+			})
+		})
+
 		It("builds a new instance state with zero-to-many rendered jobs from one or more releases", func() {
 			state, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, fakeStage, agentState)
 			Expect(err).ToNot(HaveOccurred())
@@ -0,0 +1,106 @@
+package state
+
+import (
+	"fmt"
+
+	biblobstore "github.com/cloudfoundry/bosh-cli/blobstore"
+	biconfig "github.com/cloudfoundry/bosh-cli/config"
+	birelpkg "github.com/cloudfoundry/bosh-cli/release/pkg"
+	bistatepkg "github.com/cloudfoundry/bosh-cli/state/pkg"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+type cachingPackageCompiler struct {
+	compiler     bistatepkg.Compiler
+	blobstore    biblobstore.Blobstore
+	packageRepo  bistatepkg.CompiledPackageRepo
+	packageCache bistatepkg.CompiledPackageCache
+	stemcellRepo biconfig.StemcellRepo
+	logger       boshlog.Logger
+	logTag       string
+}
+
+// NewCachingPackageCompiler reuses packages compiled for the current stemcell
+// by earlier deployments instead of compiling them again, and caches packages
+// that the wrapped compiler compiles.
+func NewCachingPackageCompiler(
+	compiler bistatepkg.Compiler,
+	blobstore biblobstore.Blobstore,
+	packageRepo bistatepkg.CompiledPackageRepo,
+	packageCache bistatepkg.CompiledPackageCache,
+	stemcellRepo biconfig.StemcellRepo,
+	logger boshlog.Logger,
+) bistatepkg.Compiler {
+	return &cachingPackageCompiler{
+		compiler:     compiler,
+		blobstore:    blobstore,
+		packageRepo:  packageRepo,
+		packageCache: packageCache,
+		stemcellRepo: stemcellRepo,
+		logger:       logger,
+		logTag:       "cachingPackageCompiler",
+	}
+}
+
+func (c *cachingPackageCompiler) Compile(pkg birelpkg.Compilable) (bistatepkg.CompiledPackageRecord, bool, error) {
+	if pkg.IsCompiled() {
+		return c.compiler.Compile(pkg)
+	}
+
+	stemcellRecord, found, err := c.stemcellRepo.FindCurrent()
+	if err != nil {
+		return bistatepkg.CompiledPackageRecord{}, false, bosherr.WrapError(err, "Finding current stemcell")
+	}
+
+	if !found {
+		return c.compiler.Compile(pkg)
+	}
+
+	stemcell := fmt.Sprintf("%s/%s", stemcellRecord.Name, stemcellRecord.Version)
+
+	cachedPath, cachedSHA1, found := c.packageCache.Get(pkg, stemcell)
+	if found {
+		blobID, err := c.blobstore.Add(cachedPath)
+		if err != nil {
+			return bistatepkg.CompiledPackageRecord{}, false, bosherr.WrapErrorf(err, "Adding cached compiled package '%s' to blobstore", pkg.Name())
+		}
+
+		record := bistatepkg.CompiledPackageRecord{BlobID: blobID, BlobSHA1: cachedSHA1}
+
+		err = c.packageRepo.Save(pkg, record)
+		if err != nil {
+			return record, true, bosherr.WrapErrorf(err, "Saving compiled package record '%#v' of package '%#v'", record, pkg)
+		}
+
+		return record, true, nil
+	}
+
+	record, isAlreadyCompiled, err := c.compiler.Compile(pkg)
+	if err != nil || isAlreadyCompiled {
+		return record, isAlreadyCompiled, err
+	}
+
+	// Failing to cache only means the package is compiled again next time
+	err = c.save(pkg, stemcell, record)
+	if err != nil {
+		c.logger.Warn(c.logTag, "Failed to cache compiled package '%s/%s': %s", pkg.Name(), pkg.Fingerprint(), err.Error())
+	}
+
+	return record, false, nil
+}
+
+func (c *cachingPackageCompiler) save(pkg birelpkg.Compilable, stemcell string, record bistatepkg.CompiledPackageRecord) error {
+	localBlob, err := c.blobstore.Get(record.BlobID)
+	if err != nil {
+		return err
+	}
+
+	err = c.packageCache.Save(localBlob.Path(), record.BlobSHA1, pkg, stemcell)
+	if err != nil {
+		localBlob.DeleteSilently()
+		return err
+	}
+
+	return nil
+}
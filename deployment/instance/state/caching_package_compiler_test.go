@@ -0,0 +1,151 @@
+package state_test
+
+import (
+	"errors"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	biblobstore "github.com/cloudfoundry/bosh-cli/blobstore"
+	mock_blobstore "github.com/cloudfoundry/bosh-cli/blobstore/mocks"
+	biconfig "github.com/cloudfoundry/bosh-cli/config"
+	fakebiconfig "github.com/cloudfoundry/bosh-cli/config/fakes"
+	. "github.com/cloudfoundry/bosh-cli/deployment/instance/state"
+	biindex "github.com/cloudfoundry/bosh-cli/index"
+	boshpkg "github.com/cloudfoundry/bosh-cli/release/pkg"
+	. "github.com/cloudfoundry/bosh-cli/release/resource"
+	bistatepkg "github.com/cloudfoundry/bosh-cli/state/pkg"
+	mock_state_package "github.com/cloudfoundry/bosh-cli/state/pkg/mocks"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("CachingPackageCompiler", func() {
+	var mockCtrl *gomock.Controller
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	var (
+		fs           *fakesys.FakeFileSystem
+		logger       boshlog.Logger
+		packageRepo  bistatepkg.CompiledPackageRepo
+		packageCache bistatepkg.CompiledPackageCache
+		stemcellRepo *fakebiconfig.FakeStemcellRepo
+
+		mockCompiler  *mock_state_package.MockCompiler
+		mockBlobstore *mock_blobstore.MockBlobstore
+
+		pkg *boshpkg.Package
+
+		compiler bistatepkg.Compiler
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+		packageRepo = bistatepkg.NewCompiledPackageRepo(biindex.NewInMemoryIndex())
+		packageCache = bistatepkg.NewCompiledPackageCache("/cache", fs, logger)
+		stemcellRepo = fakebiconfig.NewFakeStemcellRepo()
+
+		mockCompiler = mock_state_package.NewMockCompiler(mockCtrl)
+		mockBlobstore = mock_blobstore.NewMockBlobstore(mockCtrl)
+
+		pkg = boshpkg.NewPackage(NewResourceWithBuiltArchive(
+			"fake-package-name", "fake-package-fingerprint", "fake-archive-path", "fake-source-package-sha1"), nil)
+
+		compiler = NewCachingPackageCompiler(mockCompiler, mockBlobstore, packageRepo, packageCache, stemcellRepo, logger)
+	})
+
+	Context("when there is a current stemcell", func() {
+		BeforeEach(func() {
+			stemcellRepo.SetFindCurrentBehavior(biconfig.StemcellRecord{Name: "fake-stemcell-name", Version: "fake-stemcell-version"}, true, nil)
+		})
+
+		Context("when the package has not been compiled before", func() {
+			It("compiles the package and caches the compiled package", func() {
+				record := bistatepkg.CompiledPackageRecord{BlobID: "fake-compiled-blob-id", BlobSHA1: "fake-compiled-sha1"}
+				mockCompiler.EXPECT().Compile(pkg).Return(record, false, nil)
+
+				fs.WriteFileString("/tmp/compiled-blob", "compiled")
+				mockBlobstore.EXPECT().Get("fake-compiled-blob-id").Return(biblobstore.NewLocalBlob("/tmp/compiled-blob", fs, logger), nil)
+
+				compiledRecord, isAlreadyCompiled, err := compiler.Compile(pkg)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(isAlreadyCompiled).To(BeFalse())
+				Expect(compiledRecord).To(Equal(record))
+
+				cachedPath, cachedSHA1, found := packageCache.Get(pkg, "fake-stemcell-name/fake-stemcell-version")
+				Expect(found).To(BeTrue())
+				Expect(cachedSHA1).To(Equal("fake-compiled-sha1"))
+				Expect(fs.ReadFileString(cachedPath)).To(Equal("compiled"))
+			})
+
+			It("does not fail when caching the compiled package fails", func() {
+				record := bistatepkg.CompiledPackageRecord{BlobID: "fake-compiled-blob-id", BlobSHA1: "fake-compiled-sha1"}
+				mockCompiler.EXPECT().Compile(pkg).Return(record, false, nil)
+				mockBlobstore.EXPECT().Get("fake-compiled-blob-id").Return(nil, errors.New("fake-get-error"))
+
+				compiledRecord, _, err := compiler.Compile(pkg)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(compiledRecord).To(Equal(record))
+			})
+
+			It("returns an error when compiling fails", func() {
+				mockCompiler.EXPECT().Compile(pkg).Return(bistatepkg.CompiledPackageRecord{}, false, errors.New("fake-compile-error"))
+
+				_, _, err := compiler.Compile(pkg)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-compile-error"))
+			})
+		})
+
+		Context("when the package has been compiled for the stemcell before", func() {
+			BeforeEach(func() {
+				fs.WriteFileString("/tmp/compiled-blob", "compiled")
+				err := packageCache.Save("/tmp/compiled-blob", "fake-cached-sha1", pkg, "fake-stemcell-name/fake-stemcell-version")
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("uploads the cached compiled package instead of compiling it", func() {
+				cachedPath, _, _ := packageCache.Get(pkg, "fake-stemcell-name/fake-stemcell-version")
+				mockBlobstore.EXPECT().Add(cachedPath).Return("fake-cached-blob-id", nil)
+
+				compiledRecord, isAlreadyCompiled, err := compiler.Compile(pkg)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(isAlreadyCompiled).To(BeTrue())
+				Expect(compiledRecord).To(Equal(bistatepkg.CompiledPackageRecord{BlobID: "fake-cached-blob-id", BlobSHA1: "fake-cached-sha1"}))
+
+				repoRecord, found, err := packageRepo.Find(pkg)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeTrue())
+				Expect(repoRecord).To(Equal(compiledRecord))
+			})
+
+			It("returns an error when uploading the cached compiled package fails", func() {
+				mockBlobstore.EXPECT().Add(gomock.Any()).Return("", errors.New("fake-add-error"))
+
+				_, _, err := compiler.Compile(pkg)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-add-error"))
+			})
+		})
+	})
+
+	Context("when there is no current stemcell", func() {
+		It("compiles without the cache", func() {
+			record := bistatepkg.CompiledPackageRecord{BlobID: "fake-compiled-blob-id", BlobSHA1: "fake-compiled-sha1"}
+			mockCompiler.EXPECT().Compile(pkg).Return(record, false, nil)
+
+			compiledRecord, _, err := compiler.Compile(pkg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(compiledRecord).To(Equal(record))
+		})
+	})
+})
@@ -34,14 +34,14 @@ func (_m *MockBuilderFactory) EXPECT() *_MockBuilderFactoryRecorder {
 	return _m.recorder
 }
 
-func (_m *MockBuilderFactory) NewBuilder(_param0 blobstore.Blobstore, _param1 agentclient.AgentClient) state.Builder {
-	ret := _m.ctrl.Call(_m, "NewBuilder", _param0, _param1)
+func (_m *MockBuilderFactory) NewBuilder(_param0 blobstore.Blobstore, _param1 agentclient.AgentClient, _param2 string) state.Builder {
+	ret := _m.ctrl.Call(_m, "NewBuilder", _param0, _param1, _param2)
 	ret0, _ := ret[0].(state.Builder)
 	return ret0
 }
 
-func (_mr *_MockBuilderFactoryRecorder) NewBuilder(arg0, arg1 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "NewBuilder", arg0, arg1)
+func (_mr *_MockBuilderFactoryRecorder) NewBuilder(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "NewBuilder", arg0, arg1, arg2)
 }
 
 // Mock of Builder interface
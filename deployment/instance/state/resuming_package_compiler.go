@@ -0,0 +1,38 @@
+package state
+
+import (
+	birelpkg "github.com/cloudfoundry/bosh-cli/release/pkg"
+	bistatepkg "github.com/cloudfoundry/bosh-cli/state/pkg"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type resumingPackageCompiler struct {
+	compiler    bistatepkg.Compiler
+	packageRepo bistatepkg.CompiledPackageRepo
+}
+
+// NewResumingPackageCompiler skips packages that are already recorded in the
+// repo, i.e. that an interrupted deploy compiled on the same VM, and compiles
+// the others with the wrapped compiler.
+func NewResumingPackageCompiler(
+	compiler bistatepkg.Compiler,
+	packageRepo bistatepkg.CompiledPackageRepo,
+) bistatepkg.Compiler {
+	return &resumingPackageCompiler{
+		compiler:    compiler,
+		packageRepo: packageRepo,
+	}
+}
+
+func (c *resumingPackageCompiler) Compile(pkg birelpkg.Compilable) (bistatepkg.CompiledPackageRecord, bool, error) {
+	record, found, err := c.packageRepo.Find(pkg)
+	if err != nil {
+		return record, false, bosherr.WrapErrorf(err, "Finding compiled package '%s/%s'", pkg.Name(), pkg.Fingerprint())
+	}
+
+	if found {
+		return record, true, nil
+	}
+
+	return c.compiler.Compile(pkg)
+}
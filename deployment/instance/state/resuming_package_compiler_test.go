@@ -0,0 +1,66 @@
+package state_test
+
+import (
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/deployment/instance/state"
+	biindex "github.com/cloudfoundry/bosh-cli/index"
+	boshpkg "github.com/cloudfoundry/bosh-cli/release/pkg"
+	. "github.com/cloudfoundry/bosh-cli/release/resource"
+	bistatepkg "github.com/cloudfoundry/bosh-cli/state/pkg"
+	mock_state_package "github.com/cloudfoundry/bosh-cli/state/pkg/mocks"
+)
+
+var _ = Describe("ResumingPackageCompiler", func() {
+	var mockCtrl *gomock.Controller
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	var (
+		packageRepo  bistatepkg.CompiledPackageRepo
+		mockCompiler *mock_state_package.MockCompiler
+
+		pkg *boshpkg.Package
+
+		compiler bistatepkg.Compiler
+	)
+
+	BeforeEach(func() {
+		packageRepo = bistatepkg.NewCompiledPackageRepo(biindex.NewInMemoryIndex())
+		mockCompiler = mock_state_package.NewMockCompiler(mockCtrl)
+
+		pkg = boshpkg.NewPackage(NewResourceWithBuiltArchive(
+			"fake-package-name", "fake-package-fingerprint", "fake-archive-path", "fake-source-package-sha1"), nil)
+
+		compiler = NewResumingPackageCompiler(mockCompiler, packageRepo)
+	})
+
+	It("compiles packages that have not been compiled", func() {
+		record := bistatepkg.CompiledPackageRecord{BlobID: "fake-compiled-blob-id", BlobSHA1: "fake-compiled-sha1"}
+		mockCompiler.EXPECT().Compile(pkg).Return(record, false, nil)
+
+		compiledRecord, isAlreadyCompiled, err := compiler.Compile(pkg)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(isAlreadyCompiled).To(BeFalse())
+		Expect(compiledRecord).To(Equal(record))
+	})
+
+	It("skips packages that have been compiled before", func() {
+		record := bistatepkg.CompiledPackageRecord{BlobID: "fake-compiled-blob-id", BlobSHA1: "fake-compiled-sha1"}
+		err := packageRepo.Save(pkg, record)
+		Expect(err).ToNot(HaveOccurred())
+
+		compiledRecord, isAlreadyCompiled, err := compiler.Compile(pkg)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(isAlreadyCompiled).To(BeTrue())
+		Expect(compiledRecord).To(Equal(record))
+	})
+})
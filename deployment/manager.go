@@ -1,6 +1,8 @@
 package deployment
 
 import (
+	"sort"
+
 	bidisk "github.com/cloudfoundry/bosh-cli/deployment/disk"
 	biinstance "github.com/cloudfoundry/bosh-cli/deployment/instance"
 	bistemcell "github.com/cloudfoundry/bosh-cli/stemcell"
@@ -45,6 +47,21 @@ func (m *manager) FindCurrent() (deployment Deployment, found bool, err error) {
 		return nil, false, bosherr.WrapError(err, "Finding current deployment disks")
 	}
 
+	namedDisks, err := m.diskManager.FindCurrentNamed()
+	if err != nil {
+		return nil, false, bosherr.WrapError(err, "Finding current deployment named disks")
+	}
+
+	diskNames := make([]string, 0, len(namedDisks))
+	for name := range namedDisks {
+		diskNames = append(diskNames, name)
+	}
+	sort.Strings(diskNames)
+
+	for _, name := range diskNames {
+		disks = append(disks, namedDisks[name])
+	}
+
 	stemcells, err := m.stemcellManager.FindCurrent()
 	if err != nil {
 		return nil, false, bosherr.WrapError(err, "Finding current deployment stemcells")
@@ -73,6 +73,7 @@ var _ = Describe("Manager", func() {
 		JustBeforeEach(func() {
 			mockInstanceManager.EXPECT().FindCurrent().Return(expectedInstances, nil)
 			mockDiskManager.EXPECT().FindCurrent().Return(expectedDisks, nil)
+			mockDiskManager.EXPECT().FindCurrentNamed().Return(map[string]bidisk.Disk{}, nil)
 			mockStemcellManager.EXPECT().FindCurrent().Return(expectedStemcells, nil)
 
 			expectNewDeployment = mockDeploymentFactory.EXPECT().NewDeployment(expectedInstances, expectedDisks, expectedStemcells).Return(mockDeployment).AnyTimes()
@@ -185,7 +186,7 @@ var _ = Describe("Manager", func() {
 
 		JustBeforeEach(func() {
 			diskManagerFactory := bidisk.NewManagerFactory(diskRepo, logger)
-			diskDeployer := bivm.NewDiskDeployer(diskManagerFactory, diskRepo, logger)
+			diskDeployer := bivm.NewDiskDeployer(diskManagerFactory, diskRepo, false, logger)
 
 			vmManagerFactory := bivm.NewManagerFactory(vmRepo, stemcellRepo, diskDeployer, fakeUUIDGenerator, fs, logger)
 			sshTunnelFactory := bisshtunnel.NewFactory(logger)
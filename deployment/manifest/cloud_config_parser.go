@@ -0,0 +1,112 @@
+package manifest
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"github.com/cppforlife/go-patch/patch"
+
+	bidepltpl "github.com/cloudfoundry/bosh-cli/deployment/template"
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+)
+
+// CloudConfigParser parses a deployment manifest together with a standalone
+// cloud-config holding the infrastructure sections of the deployment.
+type CloudConfigParser interface {
+	ParseWithCloudConfig(manifestPath, cloudConfigPath string) (Manifest, error)
+}
+
+func NewCloudConfigParser(fs boshsys.FileSystem, logger boshlog.Logger) CloudConfigParser {
+	return &parser{
+		fs:     fs,
+		logger: logger,
+		logTag: "deploymentParser",
+	}
+}
+
+// ParseWithCloudConfig merges the cloud-config into the deployment manifest
+// the same way the config template factory does for --cloud-config, then
+// checks that the manifest references resolve against the merged sections.
+func (p *parser) ParseWithCloudConfig(manifestPath, cloudConfigPath string) (Manifest, error) {
+	template, err := bidepltpl.NewCloudConfigTemplateFactory(p.fs, cloudConfigPath).NewDeploymentTemplateFromPath(manifestPath)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	interpolatedTemplate, err := template.Evaluate(boshtpl.StaticVariables{}, patch.Ops{})
+	if err != nil {
+		return Manifest{}, bosherr.WrapError(err, "Evaluating deployment manifest")
+	}
+
+	deploymentManifest, err := p.Parse(interpolatedTemplate, manifestPath)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	err = p.validateReferences(deploymentManifest)
+	if err != nil {
+		return Manifest{}, bosherr.WrapErrorf(err, "Merging cloud config '%s'", cloudConfigPath)
+	}
+
+	return deploymentManifest, nil
+}
+
+func (p *parser) validateReferences(deploymentManifest Manifest) error {
+	errs := []error{}
+
+	networks := map[string]bool{}
+	for _, network := range deploymentManifest.Networks {
+		networks[network.Name] = true
+	}
+
+	resourcePools := map[string]bool{}
+	for idx, resourcePool := range deploymentManifest.ResourcePools {
+		resourcePools[resourcePool.Name] = true
+
+		if resourcePool.Network != "" && !networks[resourcePool.Network] {
+			errs = append(errs, bosherr.Errorf("resource_pools[%d].network '%s' must refer to a network", idx, resourcePool.Network))
+		}
+	}
+
+	diskPools := map[string]bool{}
+	for _, diskPool := range deploymentManifest.DiskPools {
+		diskPools[diskPool.Name] = true
+	}
+
+	for idx, job := range deploymentManifest.Jobs {
+		if job.ResourcePool != "" && !resourcePools[job.ResourcePool] {
+			errs = append(errs, bosherr.Errorf("jobs[%d].resource_pool '%s' must refer to a resource pool", idx, job.ResourcePool))
+		}
+
+		if job.PersistentDiskPool != "" && !diskPools[job.PersistentDiskPool] {
+			errs = append(errs, bosherr.Errorf("jobs[%d].persistent_disk_pool '%s' must refer to a disk pool", idx, job.PersistentDiskPool))
+		}
+
+		for diskIdx, persistentDisk := range job.PersistentDisks {
+			if persistentDisk.DiskPool != "" && !diskPools[persistentDisk.DiskPool] {
+				errs = append(errs, bosherr.Errorf("jobs[%d].persistent_disks[%d].disk_pool '%s' must refer to a disk pool", idx, diskIdx, persistentDisk.DiskPool))
+			}
+		}
+
+		for networkIdx, jobNetwork := range job.Networks {
+			if !networks[jobNetwork.Name] {
+				errs = append(errs, bosherr.Errorf("jobs[%d].networks[%d].name '%s' must refer to a network", idx, networkIdx, jobNetwork.Name))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return bosherr.NewMultiError(errs...)
+	}
+
+	return nil
+}
+
+func (p *parser) hasResourcePool(rawResourcePools []resourcePool, name string) bool {
+	for _, rawResourcePool := range rawResourcePools {
+		if rawResourcePool.Name == name {
+			return true
+		}
+	}
+	return false
+}
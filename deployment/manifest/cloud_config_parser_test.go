@@ -0,0 +1,122 @@
+package manifest_test
+
+import (
+	. "github.com/cloudfoundry/bosh-cli/deployment/manifest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("CloudConfigParser", func() {
+	var (
+		fakeFs *fakesys.FakeFileSystem
+		parser CloudConfigParser
+	)
+
+	BeforeEach(func() {
+		fakeFs = fakesys.NewFakeFileSystem()
+		parser = NewCloudConfigParser(fakeFs, boshlog.NewLogger(boshlog.LevelNone))
+
+		fakeFs.WriteFileString("/cloud-config.yml", `---
+networks:
+- name: fake-network-name
+  type: dynamic
+  cloud_properties: {subnet: cloud-subnet}
+- name: fake-other-network-name
+  type: dynamic
+resource_pools:
+- name: fake-resource-pool-name
+  network: fake-network-name
+  stemcell:
+    url: http://fake-stemcell-url
+disk_pools:
+- name: fake-disk-pool-name
+  disk_size: 2048
+`)
+	})
+
+	It("merges cloud config sections into the deployment manifest", func() {
+		fakeFs.WriteFileString("/manifest.yml", `---
+name: fake-deployment-name
+jobs:
+- name: fake-job-name
+  instances: 1
+  resource_pool: fake-resource-pool-name
+  persistent_disk_pool: fake-disk-pool-name
+  networks:
+  - name: fake-other-network-name
+`)
+
+		deploymentManifest, err := parser.ParseWithCloudConfig("/manifest.yml", "/cloud-config.yml")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(deploymentManifest.Name).To(Equal("fake-deployment-name"))
+		Expect(deploymentManifest.Networks).To(Equal([]Network{
+			{
+				Name:            "fake-network-name",
+				Type:            Dynamic,
+				CloudProperties: biproperty.Map{"subnet": "cloud-subnet"},
+			},
+			{
+				Name:            "fake-other-network-name",
+				Type:            Dynamic,
+				CloudProperties: biproperty.Map{},
+			},
+		}))
+		Expect(deploymentManifest.ResourcePools).To(HaveLen(1))
+		Expect(deploymentManifest.ResourcePools[0].Network).To(Equal("fake-network-name"))
+		Expect(deploymentManifest.DiskPools).To(HaveLen(1))
+		Expect(deploymentManifest.DiskPools[0].DiskSize).To(Equal(2048))
+	})
+
+	It("prefers manifest declarations over cloud config ones with the same name", func() {
+		fakeFs.WriteFileString("/manifest.yml", `---
+name: fake-deployment-name
+networks:
+- name: fake-network-name
+  type: dynamic
+  cloud_properties: {subnet: manifest-subnet}
+disk_pools:
+- name: fake-disk-pool-name
+  disk_size: 4096
+`)
+
+		deploymentManifest, err := parser.ParseWithCloudConfig("/manifest.yml", "/cloud-config.yml")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(deploymentManifest.Networks).To(HaveLen(2))
+		Expect(deploymentManifest.Networks[0].CloudProperties).To(Equal(biproperty.Map{"subnet": "manifest-subnet"}))
+		Expect(deploymentManifest.DiskPools).To(HaveLen(1))
+		Expect(deploymentManifest.DiskPools[0].DiskSize).To(Equal(4096))
+	})
+
+	It("returns an error when references do not resolve after merging", func() {
+		fakeFs.WriteFileString("/manifest.yml", `---
+name: fake-deployment-name
+jobs:
+- name: fake-job-name
+  instances: 1
+  resource_pool: fake-missing-resource-pool
+  persistent_disk_pool: fake-missing-disk-pool
+  networks:
+  - name: fake-missing-network
+`)
+
+		_, err := parser.ParseWithCloudConfig("/manifest.yml", "/cloud-config.yml")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("jobs[0].resource_pool 'fake-missing-resource-pool' must refer to a resource pool"))
+		Expect(err.Error()).To(ContainSubstring("jobs[0].persistent_disk_pool 'fake-missing-disk-pool' must refer to a disk pool"))
+		Expect(err.Error()).To(ContainSubstring("jobs[0].networks[0].name 'fake-missing-network' must refer to a network"))
+	})
+
+	It("returns an error when the cloud config cannot be read", func() {
+		fakeFs.WriteFileString("/manifest.yml", "name: fake-deployment-name")
+
+		_, err := parser.ParseWithCloudConfig("/manifest.yml", "/missing-cloud-config.yml")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Reading cloud config '/missing-cloud-config.yml'"))
+	})
+})
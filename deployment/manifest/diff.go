@@ -0,0 +1,223 @@
+package manifest
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+)
+
+// Change is a single difference between two manifests. Old is nil for
+// additions and New is nil for removals.
+type Change struct {
+	Path string
+	Old  interface{}
+	New  interface{}
+}
+
+// RedactedValue replaces the values of redacted changes.
+const RedactedValue = "<redacted>"
+
+// Redacted returns the change with its values replaced by RedactedValue when
+// they may hold credentials: properties, env and release job properties, as
+// well as whole jobs and resource pools.
+func (c Change) Redacted() Change {
+	segments := strings.Split(c.Path, "/")
+
+	var sensitive bool
+	switch {
+	case segments[0] == "properties":
+		sensitive = true
+	case len(segments) == 2:
+		sensitive = segments[0] == "jobs" || segments[0] == "resource_pools"
+	case len(segments) > 2:
+		sensitive = segments[2] == "properties" || segments[2] == "env" || segments[2] == "templates"
+	}
+
+	if sensitive {
+		if c.Old != nil {
+			c.Old = RedactedValue
+		}
+		if c.New != nil {
+			c.New = RedactedValue
+		}
+	}
+
+	return c
+}
+
+type differ struct {
+	changes []Change
+}
+
+// Diff compares two manifests section by section (name, update, networks,
+// resource_pools, disk_pools, jobs, properties). Entries in each section are
+// matched by name and visited in name order, so the result is stable.
+func Diff(old, new Manifest) []Change {
+	d := &differ{changes: []Change{}}
+
+	d.value("name", old.Name, new.Name)
+	d.value("update/update_watch_time", old.Update.UpdateWatchTime, new.Update.UpdateWatchTime)
+	d.value("update/canary_watch_time", old.Update.CanaryWatchTime, new.Update.CanaryWatchTime)
+	d.value("update/canaries", old.Update.Canaries, new.Update.Canaries)
+	d.value("update/max_in_flight", old.Update.MaxInFlight, new.Update.MaxInFlight)
+	d.value("update/serial", old.Update.Serial, new.Update.Serial)
+	d.value("update/script_timeouts", old.Update.ScriptTimeouts, new.Update.ScriptTimeouts)
+	d.value("update/agent_wait_timeout", old.Update.AgentWait.Timeout, new.Update.AgentWait.Timeout)
+	d.value("update/agent_poll_interval", old.Update.AgentWait.PollInterval, new.Update.AgentWait.PollInterval)
+	d.value("update/agent_max_errors", old.Update.AgentWait.MaxErrors, new.Update.AgentWait.MaxErrors)
+	d.value("update/vm_strategy", old.Update.VMStrategy, new.Update.VMStrategy)
+
+	oldNetworks, newNetworks := map[string]Network{}, map[string]Network{}
+	for _, network := range old.Networks {
+		oldNetworks[network.Name] = network
+	}
+	for _, network := range new.Networks {
+		newNetworks[network.Name] = network
+	}
+	for _, name := range d.names(oldNetworks, newNetworks) {
+		path := "networks/" + name
+		oldNetwork, inOld := oldNetworks[name]
+		newNetwork, inNew := newNetworks[name]
+		if d.presence(path, inOld, inNew, oldNetwork, newNetwork) {
+			d.value(path+"/type", oldNetwork.Type, newNetwork.Type)
+			d.value(path+"/dns", oldNetwork.DNS, newNetwork.DNS)
+			d.value(path+"/subnets", oldNetwork.Subnets, newNetwork.Subnets)
+			d.properties(path+"/cloud_properties", oldNetwork.CloudProperties, newNetwork.CloudProperties)
+		}
+	}
+
+	oldResourcePools, newResourcePools := map[string]ResourcePool{}, map[string]ResourcePool{}
+	for _, resourcePool := range old.ResourcePools {
+		oldResourcePools[resourcePool.Name] = resourcePool
+	}
+	for _, resourcePool := range new.ResourcePools {
+		newResourcePools[resourcePool.Name] = resourcePool
+	}
+	for _, name := range d.names(oldResourcePools, newResourcePools) {
+		path := "resource_pools/" + name
+		oldResourcePool, inOld := oldResourcePools[name]
+		newResourcePool, inNew := newResourcePools[name]
+		if d.presence(path, inOld, inNew, oldResourcePool, newResourcePool) {
+			d.value(path+"/network", oldResourcePool.Network, newResourcePool.Network)
+			d.value(path+"/cpi", oldResourcePool.CPI, newResourcePool.CPI)
+			d.value(path+"/stemcell", oldResourcePool.Stemcell, newResourcePool.Stemcell)
+			d.properties(path+"/cloud_properties", oldResourcePool.CloudProperties, newResourcePool.CloudProperties)
+			d.properties(path+"/env", oldResourcePool.Env, newResourcePool.Env)
+		}
+	}
+
+	oldDiskPools, newDiskPools := map[string]DiskPool{}, map[string]DiskPool{}
+	for _, diskPool := range old.DiskPools {
+		oldDiskPools[diskPool.Name] = diskPool
+	}
+	for _, diskPool := range new.DiskPools {
+		newDiskPools[diskPool.Name] = diskPool
+	}
+	for _, name := range d.names(oldDiskPools, newDiskPools) {
+		path := "disk_pools/" + name
+		oldDiskPool, inOld := oldDiskPools[name]
+		newDiskPool, inNew := newDiskPools[name]
+		if d.presence(path, inOld, inNew, oldDiskPool, newDiskPool) {
+			d.value(path+"/disk_size", oldDiskPool.DiskSize, newDiskPool.DiskSize)
+			d.properties(path+"/cloud_properties", oldDiskPool.CloudProperties, newDiskPool.CloudProperties)
+		}
+	}
+
+	oldJobs, newJobs := map[string]Job{}, map[string]Job{}
+	for _, job := range old.Jobs {
+		oldJobs[job.Name] = job
+	}
+	for _, job := range new.Jobs {
+		newJobs[job.Name] = job
+	}
+	for _, name := range d.names(oldJobs, newJobs) {
+		path := "jobs/" + name
+		oldJob, inOld := oldJobs[name]
+		newJob, inNew := newJobs[name]
+		if d.presence(path, inOld, inNew, oldJob, newJob) {
+			d.value(path+"/instances", oldJob.Instances, newJob.Instances)
+			d.value(path+"/lifecycle", oldJob.Lifecycle, newJob.Lifecycle)
+			d.value(path+"/resource_pool", oldJob.ResourcePool, newJob.ResourcePool)
+			d.value(path+"/persistent_disk", oldJob.PersistentDisk, newJob.PersistentDisk)
+			d.value(path+"/persistent_disk_pool", oldJob.PersistentDiskPool, newJob.PersistentDiskPool)
+			d.value(path+"/persistent_disks", oldJob.PersistentDisks, newJob.PersistentDisks)
+			d.value(path+"/templates", oldJob.Templates, newJob.Templates)
+			d.value(path+"/networks", oldJob.Networks, newJob.Networks)
+			d.value(path+"/update/update_watch_time", optionalWatchTime(oldJob.Update.UpdateWatchTime), optionalWatchTime(newJob.Update.UpdateWatchTime))
+			d.value(path+"/update/canary_watch_time", optionalWatchTime(oldJob.Update.CanaryWatchTime), optionalWatchTime(newJob.Update.CanaryWatchTime))
+			d.properties(path+"/properties", oldJob.Properties, newJob.Properties)
+		}
+	}
+
+	d.properties("properties", old.Properties, new.Properties)
+
+	return d.changes
+}
+
+func (d *differ) value(path string, old, new interface{}) {
+	if !reflect.DeepEqual(old, new) {
+		d.changes = append(d.changes, Change{Path: path, Old: old, New: new})
+	}
+}
+
+// presence records additions and removals and returns true when the entry
+// exists on both sides and its fields still need comparing.
+func (d *differ) presence(path string, inOld, inNew bool, old, new interface{}) bool {
+	switch {
+	case inOld && !inNew:
+		d.changes = append(d.changes, Change{Path: path, Old: old})
+	case !inOld && inNew:
+		d.changes = append(d.changes, Change{Path: path, New: new})
+	}
+
+	return inOld && inNew
+}
+
+// properties diffs property maps deeply, reporting changed leaves by path.
+func (d *differ) properties(path string, old, new biproperty.Map) {
+	for _, key := range d.names(old, new) {
+		keyPath := path + "/" + key
+		oldValue, inOld := old[key]
+		newValue, inNew := new[key]
+
+		oldMap, oldIsMap := oldValue.(biproperty.Map)
+		newMap, newIsMap := newValue.(biproperty.Map)
+
+		if inOld && inNew && oldIsMap && newIsMap {
+			d.properties(keyPath, oldMap, newMap)
+		} else if d.presence(keyPath, inOld, inNew, oldValue, newValue) {
+			d.value(keyPath, oldValue, newValue)
+		}
+	}
+}
+
+// names returns the sorted union of the keys of two string keyed maps.
+func (d *differ) names(old, new interface{}) []string {
+	names := []string{}
+	seen := map[string]struct{}{}
+
+	for _, m := range []reflect.Value{reflect.ValueOf(old), reflect.ValueOf(new)} {
+		for _, key := range m.MapKeys() {
+			name := key.String()
+			if _, found := seen[name]; !found {
+				seen[name] = struct{}{}
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// optionalWatchTime returns the watch time, or nil when it is not set, so
+// that it is printed as a value and not as a pointer.
+func optionalWatchTime(watchTime *WatchTime) interface{} {
+	if watchTime == nil {
+		return nil
+	}
+	return *watchTime
+}
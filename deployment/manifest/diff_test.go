@@ -0,0 +1,103 @@
+package manifest_test
+
+import (
+	. "github.com/cloudfoundry/bosh-cli/deployment/manifest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+)
+
+var _ = Describe("Diff", func() {
+	var (
+		oldManifest Manifest
+	)
+
+	BeforeEach(func() {
+		oldManifest = Manifest{
+			Name: "fake-deployment-name",
+			Networks: []Network{
+				{Name: "fake-network-name", Type: Dynamic},
+			},
+			DiskPools: []DiskPool{
+				{Name: "fake-disk-pool-name", DiskSize: 1024},
+			},
+			Jobs: []Job{
+				{
+					Name:      "api",
+					Instances: 1,
+					Properties: biproperty.Map{
+						"db": biproperty.Map{"host": "old-host", "port": 5432},
+					},
+				},
+				{Name: "worker", Instances: 2},
+			},
+			Properties: biproperty.Map{"region": "us-east-1"},
+		}
+	})
+
+	It("returns no changes for identical manifests", func() {
+		Expect(Diff(oldManifest, oldManifest)).To(BeEmpty())
+	})
+
+	It("reports changed, added and removed entries in a stable order", func() {
+		newManifest := oldManifest
+		newManifest.Networks = []Network{
+			{Name: "fake-network-name", Type: Manual},
+		}
+		newManifest.DiskPools = []DiskPool{
+			{Name: "fake-other-disk-pool-name", DiskSize: 2048},
+		}
+		newManifest.Jobs = []Job{
+			{Name: "worker", Instances: 2},
+			{
+				Name:      "api",
+				Instances: 3,
+				Properties: biproperty.Map{
+					"db": biproperty.Map{"host": "new-host", "port": 5432, "ssl": true},
+				},
+			},
+		}
+		newManifest.Properties = biproperty.Map{}
+
+		Expect(Diff(oldManifest, newManifest)).To(Equal([]Change{
+			{Path: "networks/fake-network-name/type", Old: Dynamic, New: Manual},
+			{Path: "disk_pools/fake-disk-pool-name", Old: DiskPool{Name: "fake-disk-pool-name", DiskSize: 1024}},
+			{Path: "disk_pools/fake-other-disk-pool-name", New: DiskPool{Name: "fake-other-disk-pool-name", DiskSize: 2048}},
+			{Path: "jobs/api/instances", Old: 1, New: 3},
+			{Path: "jobs/api/properties/db/host", Old: "old-host", New: "new-host"},
+			{Path: "jobs/api/properties/db/ssl", New: true},
+			{Path: "properties/region", Old: "us-east-1"},
+		}))
+	})
+
+	It("reports update watch time changes", func() {
+		newManifest := oldManifest
+		newManifest.Update = Update{UpdateWatchTime: WatchTime{Start: 0, End: 1000}}
+
+		Expect(Diff(oldManifest, newManifest)).To(Equal([]Change{
+			{Path: "update/update_watch_time", Old: WatchTime{}, New: WatchTime{Start: 0, End: 1000}},
+		}))
+	})
+
+	Describe("Change.Redacted", func() {
+		It("redacts values of properties, env and whole jobs and resource pools", func() {
+			Expect(Change{Path: "properties/region", Old: "us-east-1", New: "us-west-1"}.Redacted()).To(Equal(
+				Change{Path: "properties/region", Old: RedactedValue, New: RedactedValue}))
+			Expect(Change{Path: "jobs/api/properties/db/ssl", New: true}.Redacted()).To(Equal(
+				Change{Path: "jobs/api/properties/db/ssl", New: RedactedValue}))
+			Expect(Change{Path: "resource_pools/fake-resource-pool-name/env/bosh/password", Old: "old"}.Redacted()).To(Equal(
+				Change{Path: "resource_pools/fake-resource-pool-name/env/bosh/password", Old: RedactedValue}))
+			Expect(Change{Path: "jobs/worker", New: Job{Name: "worker"}}.Redacted()).To(Equal(
+				Change{Path: "jobs/worker", New: RedactedValue}))
+		})
+
+		It("keeps other values", func() {
+			change := Change{Path: "jobs/properties/instances", Old: 1, New: 3}
+			Expect(change.Redacted()).To(Equal(change))
+
+			change = Change{Path: "disk_pools/fake-disk-pool-name/disk_size", Old: 1024, New: 2048}
+			Expect(change.Redacted()).To(Equal(change))
+		})
+	})
+})
@@ -0,0 +1,98 @@
+package manifest
+
+import (
+	"net"
+
+	binet "github.com/cloudfoundry/bosh-cli/common/net"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// IaaSNetworkPolicy describes addresses an IaaS implicitly reserves in a subnet
+// beyond the generic network/gateway/broadcast rules, e.g. AWS keeps the first four addresses.
+type IaaSNetworkPolicy interface {
+	ReservedIPs(subnet Subnet) ([]net.IP, error)
+}
+
+type noopIaaSNetworkPolicy struct{}
+
+// NewNoopIaaSNetworkPolicy returns a policy that reserves no additional addresses.
+func NewNoopIaaSNetworkPolicy() IaaSNetworkPolicy {
+	return noopIaaSNetworkPolicy{}
+}
+
+func (p noopIaaSNetworkPolicy) ReservedIPs(subnet Subnet) ([]net.IP, error) {
+	return []net.IP{}, nil
+}
+
+type awsIaaSNetworkPolicy struct{}
+
+// NewAWSIaaSNetworkPolicy returns a policy matching AWS VPC subnets, which reserve
+// the first four addresses and the last address of every subnet.
+func NewAWSIaaSNetworkPolicy() IaaSNetworkPolicy {
+	return awsIaaSNetworkPolicy{}
+}
+
+func (p awsIaaSNetworkPolicy) ReservedIPs(subnet Subnet) ([]net.IP, error) {
+	_, ipNet, err := net.ParseCIDR(subnet.Range)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Parsing subnet range '%s'", subnet.Range)
+	}
+
+	reserved := []net.IP{}
+	ip := ipNet.IP
+	for i := 0; i < 4 && ipNet.Contains(ip); i++ {
+		reserved = append(reserved, ip)
+		ip = binet.NextAddress(ip)
+	}
+
+	return append(reserved, binet.LastAddress(ipNet)), nil
+}
+
+// ValidateNetworkingForIaaS checks that no job static IP on a manual network
+// is an address the IaaS reserves according to the given policy.
+func (d Manifest) ValidateNetworkingForIaaS(policy IaaSNetworkPolicy) error {
+	errs := []error{}
+	networkMap := d.networkMap()
+
+	for jobIdx, job := range d.Jobs {
+		for networkIdx, jobNetwork := range job.Networks {
+			network, found := networkMap[jobNetwork.Name]
+			if !found || network.Type != Manual {
+				continue
+			}
+
+			for _, subnet := range network.Subnets {
+				_, ipNet, err := net.ParseCIDR(subnet.Range)
+				if err != nil {
+					continue
+				}
+
+				reservedIPs, err := policy.ReservedIPs(subnet)
+				if err != nil {
+					return bosherr.WrapErrorf(err, "Determining IaaS reserved IPs for network '%s'", network.Name)
+				}
+
+				for _, ip := range jobNetwork.StaticIPs {
+					parsedIP := net.ParseIP(ip)
+					if parsedIP == nil || !ipNet.Contains(parsedIP) {
+						continue
+					}
+
+					for _, reservedIP := range reservedIPs {
+						if reservedIP.Equal(parsedIP) {
+							errs = append(errs, bosherr.Errorf(
+								"jobs[%d].networks[%d] static ip '%s' is reserved by the IaaS in subnet '%s'",
+								jobIdx, networkIdx, ip, subnet.Range))
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return bosherr.NewMultiError(errs...)
+	}
+
+	return nil
+}
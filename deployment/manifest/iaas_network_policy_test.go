@@ -0,0 +1,96 @@
+package manifest_test
+
+import (
+	"net"
+
+	. "github.com/cloudfoundry/bosh-cli/deployment/manifest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	birelsetmanifest "github.com/cloudfoundry/bosh-cli/release/set/manifest"
+)
+
+var _ = Describe("IaaSNetworkPolicy", func() {
+	subnet := Subnet{Range: "10.0.0.0/24", Gateway: "10.0.0.1"}
+
+	Describe("NewNoopIaaSNetworkPolicy", func() {
+		It("reserves no addresses", func() {
+			reservedIPs, err := NewNoopIaaSNetworkPolicy().ReservedIPs(subnet)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reservedIPs).To(BeEmpty())
+		})
+	})
+
+	Describe("NewAWSIaaSNetworkPolicy", func() {
+		It("reserves the first four and the last address of the subnet", func() {
+			reservedIPs, err := NewAWSIaaSNetworkPolicy().ReservedIPs(subnet)
+			Expect(err).ToNot(HaveOccurred())
+
+			reserved := []string{}
+			for _, ip := range reservedIPs {
+				reserved = append(reserved, ip.String())
+			}
+			Expect(reserved).To(Equal([]string{"10.0.0.0", "10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.255"}))
+		})
+
+		It("returns an error when the subnet range is invalid", func() {
+			_, err := NewAWSIaaSNetworkPolicy().ReservedIPs(Subnet{Range: "fake-range"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Parsing subnet range 'fake-range'"))
+		})
+	})
+
+	Describe("Manifest.ValidateNetworkingForIaaS", func() {
+		var deploymentManifest Manifest
+
+		BeforeEach(func() {
+			deploymentManifest = Manifest{
+				Networks: []Network{
+					{Name: "fake-manual-network", Type: Manual, Subnets: []Subnet{subnet}},
+					{Name: "fake-vip-network", Type: VIP},
+				},
+				Jobs: []Job{
+					{
+						Name: "fake-job-name",
+						Networks: []JobNetwork{
+							{Name: "fake-manual-network", StaticIPs: []string{"10.0.0.3", "10.0.0.4"}},
+							{Name: "fake-vip-network", StaticIPs: []string{"10.0.0.2"}},
+						},
+					},
+				},
+			}
+		})
+
+		It("returns an error for static IPs reserved by an AWS-style policy", func() {
+			err := deploymentManifest.ValidateNetworkingForIaaS(NewAWSIaaSNetworkPolicy())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("jobs[0].networks[0] static ip '10.0.0.3' is reserved by the IaaS in subnet '10.0.0.0/24'"))
+		})
+
+		It("does not error with the no-op policy", func() {
+			err := deploymentManifest.ValidateNetworkingForIaaS(NewNoopIaaSNetworkPolicy())
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("is consulted by the validator", func() {
+			deploymentManifest.Name = "fake-deployment-name"
+			validator := NewValidatorWithIaaSNetworkPolicy(nil, fakeIaaSNetworkPolicy{reserved: []string{"10.0.0.4"}})
+
+			err := validator.Validate(deploymentManifest, birelsetmanifest.Manifest{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("jobs[0].networks[0] static ip '10.0.0.4' is reserved by the IaaS in subnet '10.0.0.0/24'"))
+		})
+	})
+})
+
+type fakeIaaSNetworkPolicy struct {
+	reserved []string
+}
+
+func (p fakeIaaSNetworkPolicy) ReservedIPs(subnet Subnet) ([]net.IP, error) {
+	ips := []net.IP{}
+	for _, ip := range p.reserved {
+		ips = append(ips, net.ParseIP(ip))
+	}
+	return ips, nil
+}
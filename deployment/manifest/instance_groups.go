@@ -0,0 +1,189 @@
+package manifest
+
+import (
+	birelmanifest "github.com/cloudfoundry/bosh-cli/release/manifest"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type az struct {
+	Name            string                      `yaml:"name"`
+	CloudProperties map[interface{}]interface{} `yaml:"cloud_properties"`
+}
+
+type vmType struct {
+	Name            string                      `yaml:"name"`
+	CloudProperties map[interface{}]interface{} `yaml:"cloud_properties"`
+}
+
+type stemcell struct {
+	Alias   string `yaml:"alias"`
+	OS      string `yaml:"os"`
+	Version string `yaml:"version"`
+	URL     string `yaml:"url"`
+	SHA1    string `yaml:"sha1"`
+
+	Signature birelmanifest.SignatureRef `yaml:"signature"`
+}
+
+// resolveInstanceGroups maps the v2 manifest schema onto the legacy one: every
+// instance group using vm_type gets a resource pool of its own, named after the
+// instance group, whose cloud_properties merge its azs, its vm_type and its
+// vm_extensions in that order. persistent_disk_type is an alias of
+// persistent_disk_pool, and the type of persistent_disks an alias of their
+// disk_pool.
+func (p *parser) resolveInstanceGroups(depManifest manifest) (manifest, error) {
+	errs := []error{}
+
+	azs := map[string]az{}
+	for _, rawAZ := range depManifest.AZs {
+		azs[rawAZ.Name] = rawAZ
+	}
+
+	vmTypes := map[string]vmType{}
+	for _, rawVMType := range depManifest.VMTypes {
+		vmTypes[rawVMType.Name] = rawVMType
+	}
+
+	vmExtensions := map[string]vmType{}
+	for _, rawVMExtension := range depManifest.VMExtensions {
+		vmExtensions[rawVMExtension.Name] = rawVMExtension
+	}
+
+	stemcells := map[string]stemcell{}
+	for _, rawStemcell := range depManifest.Stemcells {
+		stemcells[rawStemcell.Alias] = rawStemcell
+	}
+
+	for _, rawNetwork := range depManifest.Networks {
+		for idx, rawSubnet := range rawNetwork.Subnets {
+			for _, azName := range rawSubnet.azNames() {
+				if _, found := azs[azName]; !found {
+					errs = append(errs, bosherr.Errorf("Network '%s' subnets[%d] references unknown az '%s'", rawNetwork.Name, idx, azName))
+				}
+			}
+		}
+	}
+
+	resourcePools := append([]resourcePool{}, depManifest.ResourcePools...)
+
+	rawJobs := depManifest.Jobs
+	if len(depManifest.InstanceGroups) > 0 {
+		rawJobs = depManifest.InstanceGroups
+	}
+
+	resolvedJobs := make([]job, len(rawJobs), len(rawJobs))
+
+	for i, rawJob := range rawJobs {
+		if rawJob.PersistentDiskType != "" {
+			if rawJob.PersistentDiskPool != "" {
+				errs = append(errs, bosherr.Errorf("Instance group '%s' specifies both persistent_disk_pool and persistent_disk_type, only one is allowed", rawJob.Name))
+			}
+			rawJob.PersistentDiskPool = rawJob.PersistentDiskType
+		}
+
+		if len(rawJob.PersistentDisks) > 0 {
+			persistentDisks := make([]jobPersistentDisk, len(rawJob.PersistentDisks))
+			for diskIdx, rawDisk := range rawJob.PersistentDisks {
+				if rawDisk.Type != "" {
+					if rawDisk.DiskPool != "" {
+						errs = append(errs, bosherr.Errorf("Instance group '%s' persistent disk '%s' specifies both disk_pool and type, only one is allowed", rawJob.Name, rawDisk.Name))
+					}
+					rawDisk.DiskPool = rawDisk.Type
+				}
+				persistentDisks[diskIdx] = rawDisk
+			}
+			rawJob.PersistentDisks = persistentDisks
+		}
+
+		resolvedJobs[i] = rawJob
+
+		if rawJob.VMType == "" {
+			continue
+		}
+
+		if rawJob.ResourcePool != "" {
+			errs = append(errs, bosherr.Errorf("Instance group '%s' specifies both resource_pool and vm_type, only one is allowed", rawJob.Name))
+			continue
+		}
+
+		if p.hasResourcePool(resourcePools, rawJob.Name) {
+			errs = append(errs, bosherr.Errorf("Instance group '%s' uses vm_type, but a resource pool with the same name already exists", rawJob.Name))
+			continue
+		}
+
+		rawVMType, found := vmTypes[rawJob.VMType]
+		if !found {
+			errs = append(errs, bosherr.Errorf("Instance group '%s' references unknown vm_type '%s'", rawJob.Name, rawJob.VMType))
+			continue
+		}
+
+		rawStemcell, found := stemcells[rawJob.Stemcell]
+		if !found {
+			errs = append(errs, bosherr.Errorf("Instance group '%s' references unknown stemcell '%s'", rawJob.Name, rawJob.Stemcell))
+			continue
+		}
+
+		cloudProperties := map[interface{}]interface{}{}
+
+		for _, azName := range rawJob.AZs {
+			rawAZ, found := azs[azName]
+			if !found {
+				errs = append(errs, bosherr.Errorf("Instance group '%s' references unknown az '%s'", rawJob.Name, azName))
+				continue
+			}
+			p.mergeCloudProperties(cloudProperties, rawAZ.CloudProperties)
+		}
+
+		p.mergeCloudProperties(cloudProperties, rawVMType.CloudProperties)
+
+		for _, extensionName := range rawJob.VMExtensions {
+			rawVMExtension, found := vmExtensions[extensionName]
+			if !found {
+				errs = append(errs, bosherr.Errorf("Instance group '%s' references unknown vm_extension '%s'", rawJob.Name, extensionName))
+				continue
+			}
+			p.mergeCloudProperties(cloudProperties, rawVMExtension.CloudProperties)
+		}
+
+		var networkName string
+		if len(rawJob.Networks) > 0 {
+			networkName = rawJob.Networks[0].Name
+		}
+
+		resourcePools = append(resourcePools, resourcePool{
+			Name:            rawJob.Name,
+			Network:         networkName,
+			CloudProperties: cloudProperties,
+			Env:             rawJob.Env,
+			Stemcell:        stemcellRef{URL: rawStemcell.URL, SHA1: rawStemcell.SHA1, Signature: rawStemcell.Signature},
+		})
+
+		resolvedJobs[i].ResourcePool = rawJob.Name
+	}
+
+	if len(errs) > 0 {
+		return manifest{}, bosherr.NewMultiError(errs...)
+	}
+
+	depManifest.ResourcePools = resourcePools
+	if len(depManifest.InstanceGroups) > 0 {
+		depManifest.InstanceGroups = resolvedJobs
+	} else if len(depManifest.Jobs) > 0 {
+		depManifest.Jobs = resolvedJobs
+	}
+
+	return depManifest, nil
+}
+
+func (p *parser) mergeCloudProperties(merged, cloudProperties map[interface{}]interface{}) {
+	for key, value := range cloudProperties {
+		merged[key] = value
+	}
+}
+
+func (s subnet) azNames() []string {
+	if s.AZ != "" {
+		return append([]string{s.AZ}, s.AZs...)
+	}
+	return s.AZs
+}
@@ -0,0 +1,133 @@
+package manifest
+
+import (
+	"net"
+
+	binet "github.com/cloudfoundry/bosh-cli/common/net"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// InstanceIPAllocator picks the addresses of the instances on manual networks
+// that the job gives no static IPs for.
+type InstanceIPAllocator interface {
+	Allocate(jobs []Job, networks []Network) error
+}
+
+type instanceIPAllocator struct{}
+
+func NewInstanceIPAllocator() InstanceIPAllocator {
+	return instanceIPAllocator{}
+}
+
+// Allocate fills in AllocatedIPs on every job network of a manual network that has no static IPs,
+// one address per instance. Addresses are taken in order from the subnets in the job's azs, skipping
+// the network, gateway and IPv4 broadcast addresses, reserved and static ranges, and static IPs of any job,
+// so the same manifest always gets the same addresses.
+func (a instanceIPAllocator) Allocate(jobs []Job, networks []Network) error {
+	taken := map[string]struct{}{}
+	for _, job := range jobs {
+		for _, jobNetwork := range job.Networks {
+			for _, ip := range jobNetwork.StaticIPs {
+				if parsedIP := net.ParseIP(ip); parsedIP != nil {
+					taken[parsedIP.String()] = struct{}{}
+				}
+			}
+		}
+	}
+
+	networkMap := map[string]Network{}
+	for _, network := range networks {
+		networkMap[network.Name] = network
+	}
+
+	for jobIdx, job := range jobs {
+		for networkIdx, jobNetwork := range job.Networks {
+			network, found := networkMap[jobNetwork.Name]
+			if !found || network.Type != Manual || len(jobNetwork.StaticIPs) > 0 || job.Instances == 0 {
+				continue
+			}
+
+			allocated := []string{}
+			for _, subnet := range network.Subnets {
+				if len(allocated) == job.Instances {
+					break
+				}
+
+				if !subnet.availableIn(job.AZs) {
+					continue
+				}
+
+				ips, err := a.allocateFromSubnet(subnet, job.Instances-len(allocated), taken)
+				if err != nil {
+					return bosherr.WrapErrorf(err, "Allocating IPs for job '%s' from network '%s'", job.Name, network.Name)
+				}
+				allocated = append(allocated, ips...)
+			}
+
+			if len(allocated) < job.Instances {
+				return bosherr.Errorf(
+					"Job '%s' requires %d IPs from network '%s' but only %d are available",
+					job.Name, job.Instances, network.Name, len(allocated))
+			}
+
+			jobs[jobIdx].Networks[networkIdx].AllocatedIPs = allocated
+		}
+	}
+
+	return nil
+}
+
+func (a instanceIPAllocator) allocateFromSubnet(subnet Subnet, count int, taken map[string]struct{}) ([]string, error) {
+	_, ipNet, err := net.ParseCIDR(subnet.Range)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Parsing subnet range '%s'", subnet.Range)
+	}
+
+	excludedRanges := [][]net.IP{}
+	for _, ipRange := range append(append([]string{}, subnet.Reserved...), subnet.Static...) {
+		first, last, err := binet.ParseIPRangeBounds(ipRange)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Parsing subnet '%s' reserved and static ranges", subnet.Range)
+		}
+		excludedRanges = append(excludedRanges, []net.IP{first, last})
+	}
+
+	gateway := net.ParseIP(subnet.Gateway)
+	broadcast := binet.LastAddress(ipNet)
+	isIPv4 := ipNet.IP.To4() != nil
+
+	allocated := []string{}
+
+	// the network address itself is never allocated
+	ip := binet.NextAddress(ipNet.IP)
+	for ipNet.Contains(ip) && len(allocated) < count {
+		if lastExcluded := a.excludedUntil(ip, excludedRanges); lastExcluded != nil {
+			ip = binet.NextAddress(lastExcluded)
+			continue
+		}
+
+		if isIPv4 && ip.Equal(broadcast) {
+			break
+		}
+
+		if _, found := taken[ip.String()]; !found && !ip.Equal(gateway) {
+			taken[ip.String()] = struct{}{}
+			allocated = append(allocated, ip.String())
+		}
+
+		ip = binet.NextAddress(ip)
+	}
+
+	return allocated, nil
+}
+
+// excludedUntil returns the last address of the excluded range containing ip,
+// so that a large reserved range is skipped in one step.
+func (a instanceIPAllocator) excludedUntil(ip net.IP, excludedRanges [][]net.IP) net.IP {
+	for _, excludedRange := range excludedRanges {
+		if binet.InRange(ip, excludedRange[0], excludedRange[1]) {
+			return excludedRange[1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package manifest_test
+
+import (
+	. "github.com/cloudfoundry/bosh-cli/deployment/manifest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("InstanceIPAllocator", func() {
+	var (
+		allocator InstanceIPAllocator
+		networks  []Network
+	)
+
+	BeforeEach(func() {
+		allocator = NewInstanceIPAllocator()
+		networks = []Network{
+			{
+				Name: "fake-network-name",
+				Type: Manual,
+				Subnets: []Subnet{
+					{
+						Range:    "10.0.0.0/29",
+						Gateway:  "10.0.0.1",
+						Reserved: []string{"10.0.0.2 - 10.0.0.3"},
+						Static:   []string{"10.0.0.5"},
+						AZs:      []string{"z1"},
+					},
+					{
+						Range:   "10.0.1.0/29",
+						Gateway: "10.0.1.1",
+						AZs:     []string{"z2"},
+					},
+				},
+			},
+			{
+				Name: "fake-dynamic-network-name",
+				Type: Dynamic,
+			},
+		}
+	})
+
+	It("allocates an IP per instance that is not reserved, static or the gateway, in order", func() {
+		jobs := []Job{
+			{
+				Name:      "fake-job-name",
+				Instances: 3,
+				Networks:  []JobNetwork{{Name: "fake-network-name"}},
+			},
+		}
+
+		err := allocator.Allocate(jobs, networks)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(jobs[0].Networks[0].AllocatedIPs).To(Equal([]string{"10.0.0.4", "10.0.0.6", "10.0.1.2"}))
+	})
+
+	It("only allocates from the subnets in the azs of the job", func() {
+		jobs := []Job{
+			{
+				Name:      "fake-job-name",
+				Instances: 1,
+				AZs:       []string{"z2"},
+				Networks:  []JobNetwork{{Name: "fake-network-name"}},
+			},
+		}
+
+		err := allocator.Allocate(jobs, networks)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(jobs[0].Networks[0].AllocatedIPs).To(Equal([]string{"10.0.1.2"}))
+	})
+
+	It("skips the static IPs of other jobs and does not allocate for jobs with static IPs", func() {
+		jobs := []Job{
+			{
+				Name:      "fake-static-job",
+				Instances: 1,
+				Networks:  []JobNetwork{{Name: "fake-network-name", StaticIPs: []string{"10.0.0.4"}}},
+			},
+			{
+				Name:      "fake-allocated-job",
+				Instances: 1,
+				Networks:  []JobNetwork{{Name: "fake-network-name"}},
+			},
+		}
+
+		err := allocator.Allocate(jobs, networks)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(jobs[0].Networks[0].AllocatedIPs).To(BeNil())
+		Expect(jobs[1].Networks[0].AllocatedIPs).To(Equal([]string{"10.0.0.6"}))
+	})
+
+	It("does not allocate on networks other than manual ones", func() {
+		jobs := []Job{
+			{
+				Name:      "fake-job-name",
+				Instances: 1,
+				Networks:  []JobNetwork{{Name: "fake-dynamic-network-name"}},
+			},
+		}
+
+		err := allocator.Allocate(jobs, networks)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(jobs[0].Networks[0].AllocatedIPs).To(BeNil())
+	})
+
+	It("skips large reserved IPv6 ranges", func() {
+		networks[0].Subnets = []Subnet{
+			{
+				Range:    "2001:db8::/64",
+				Gateway:  "2001:db8::1",
+				Reserved: []string{"2001:db8::2 - 2001:db8::ffff:ffff:ffff"},
+			},
+		}
+		jobs := []Job{
+			{
+				Name:      "fake-job-name",
+				Instances: 1,
+				Networks:  []JobNetwork{{Name: "fake-network-name"}},
+			},
+		}
+
+		err := allocator.Allocate(jobs, networks)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(jobs[0].Networks[0].AllocatedIPs).To(Equal([]string{"2001:db8:0:0:1::"}))
+	})
+
+	It("returns an error when the subnets do not have enough unreserved IPs", func() {
+		jobs := []Job{
+			{
+				Name:      "fake-job-name",
+				Instances: 10,
+				Networks:  []JobNetwork{{Name: "fake-network-name"}},
+			},
+		}
+
+		err := allocator.Allocate(jobs, networks)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Job 'fake-job-name' requires 10 IPs from network 'fake-network-name' but only 7 are available"))
+	})
+})
@@ -7,13 +7,51 @@ import (
 type Job struct {
 	Name               string
 	Instances          int
+	AZs                []string
 	Lifecycle          JobLifecycle
 	Templates          []ReleaseJobRef
 	Networks           []JobNetwork
 	PersistentDisk     int
 	PersistentDiskPool string
+	PersistentDisks    []JobPersistentDisk
 	ResourcePool       string
 	Properties         biproperty.Map
+
+	// Update overrides the watch times of the update section of the
+	// deployment for the instances of the job.
+	Update JobUpdate
+}
+
+// JobUpdate holds the watch times set in the update section of a job. They
+// are nil when the job does not set them.
+type JobUpdate struct {
+	UpdateWatchTime *WatchTime
+	CanaryWatchTime *WatchTime
+}
+
+// InstanceAZ returns the AZ of the instance with the given index, which
+// spreads instances over the AZs of the job in order. It is empty when the
+// job has no AZs.
+func (j Job) InstanceAZ(index int) string {
+	if len(j.AZs) == 0 {
+		return ""
+	}
+
+	return j.AZs[index%len(j.AZs)]
+}
+
+// IsErrand returns true when the job only runs when asked to with
+// run-errand-env. Its release jobs are colocated on the instance of the
+// service job.
+func (j Job) IsErrand() bool {
+	return j.Lifecycle == JobLifecycleErrand
+}
+
+// JobPersistentDisk is a named persistent disk of a job. The first disk of a
+// job is mounted as its persistent disk, the others are only attached.
+type JobPersistentDisk struct {
+	Name     string
+	DiskPool string
 }
 
 type JobLifecycle string
@@ -27,12 +65,51 @@ type ReleaseJobRef struct {
 	Name       string
 	Release    string
 	Properties *biproperty.Map
+
+	// Consumes maps the names of consumed links to the name of the provided
+	// link to use, set with `consumes: {<name>: {from: <provided name>}}`
+	Consumes map[string]string
+
+	// Provides maps the names of provided links to the name they are provided
+	// as, set with `provides: {<name>: {as: <provided name>}}`
+	Provides map[string]string
+}
+
+// ConsumedLinkName returns the name of the provided link that the consumed
+// link resolves to.
+func (r ReleaseJobRef) ConsumedLinkName(linkName string) string {
+	if from, found := r.Consumes[linkName]; found {
+		return from
+	}
+	return linkName
+}
+
+// ProvidedLinkName returns the name the link is provided as.
+func (r ReleaseJobRef) ProvidedLinkName(linkName string) string {
+	if as, found := r.Provides[linkName]; found {
+		return as
+	}
+	return linkName
 }
 
 type JobNetwork struct {
-	Name      string
-	Defaults  []NetworkDefault
-	StaticIPs []string
+	Name          string
+	Defaults      []NetworkDefault
+	StaticIPs     []string
+	StaticIPCount int
+
+	// AllocatedIPs are the addresses of the instances on a manual network
+	// without static IPs, picked from the unreserved addresses of its subnets.
+	AllocatedIPs []string
+}
+
+// InstanceIPs returns the static IPs of the job network, or its allocated
+// IPs when it has no static IPs, in order of instance index.
+func (n JobNetwork) InstanceIPs() []string {
+	if len(n.StaticIPs) > 0 {
+		return n.StaticIPs
+	}
+	return n.AllocatedIPs
 }
 
 type NetworkDefault string
@@ -0,0 +1,272 @@
+package manifest
+
+import (
+	"strings"
+
+	bireljob "github.com/cloudfoundry/bosh-cli/release/job"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+)
+
+// ReleaseJobResolver finds the release jobs referenced by the manifest, e.g.
+// the deployment/release JobResolver.
+type ReleaseJobResolver interface {
+	Resolve(jobName, releaseName string) (bireljob.Job, error)
+}
+
+type providedLink struct {
+	name          string
+	definition    bireljob.LinkDefinition
+	instanceGroup Job
+	releaseJob    bireljob.Job
+	jobRef        ReleaseJobRef
+}
+
+// ResolveLinks builds the links consumed by the release jobs of an instance
+// group from the links provided by the release jobs of all instance groups in
+// the deployment. It returns the link specs keyed by release job name and
+// consumed link name, as exposed to templates with link().
+//
+// A consumed link is matched by name, or by type when no link has that name
+// and no other name was chosen with `from`. Optional links that are not
+// provided are left out.
+func (d Manifest) ResolveLinks(instanceGroupName string, jobResolver ReleaseJobResolver) (map[string]biproperty.Map, error) {
+	instanceGroup, found := d.FindJobByName(instanceGroupName)
+	if !found {
+		return nil, bosherr.Errorf("Instance group '%s' not found in deployment manifest", instanceGroupName)
+	}
+
+	providedLinks, err := d.findProvidedLinks(jobResolver)
+	if err != nil {
+		return nil, err
+	}
+
+	releaseJobLinks := map[string]biproperty.Map{}
+
+	for _, jobRef := range instanceGroup.Templates {
+		releaseJob, err := jobResolver.Resolve(jobRef.Name, jobRef.Release)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Resolving job '%s' in release '%s'", jobRef.Name, jobRef.Release)
+		}
+
+		links := biproperty.Map{}
+
+		for _, consumed := range releaseJob.Consumes {
+			provided, err := findProvidedLink(providedLinks, jobRef, consumed)
+			if err != nil {
+				return nil, bosherr.WrapErrorf(err, "Resolving link '%s' consumed by job '%s'", consumed.Name, jobRef.Name)
+			}
+
+			if provided == nil {
+				continue
+			}
+
+			links[consumed.Name] = d.linkSpec(*provided)
+		}
+
+		releaseJobLinks[jobRef.Name] = links
+	}
+
+	return releaseJobLinks, nil
+}
+
+func (d Manifest) findProvidedLinks(jobResolver ReleaseJobResolver) ([]providedLink, error) {
+	providedLinks := []providedLink{}
+
+	for _, instanceGroup := range d.Jobs {
+		for _, jobRef := range instanceGroup.Templates {
+			releaseJob, err := jobResolver.Resolve(jobRef.Name, jobRef.Release)
+			if err != nil {
+				return nil, bosherr.WrapErrorf(err, "Resolving job '%s' in release '%s'", jobRef.Name, jobRef.Release)
+			}
+
+			providedLinks = append(providedLinks, newProvidedLinks(instanceGroup, jobRef, releaseJob)...)
+		}
+	}
+
+	return providedLinks, nil
+}
+
+// newProvidedLinks returns the links provided by a release job of an
+// instance group, named as chosen with `as` in the manifest.
+func newProvidedLinks(instanceGroup Job, jobRef ReleaseJobRef, releaseJob bireljob.Job) []providedLink {
+	providedLinks := []providedLink{}
+
+	for _, definition := range releaseJob.Provides {
+		providedLinks = append(providedLinks, providedLink{
+			name:          jobRef.ProvidedLinkName(definition.Name),
+			definition:    definition,
+			instanceGroup: instanceGroup,
+			releaseJob:    releaseJob,
+			jobRef:        jobRef,
+		})
+	}
+
+	return providedLinks
+}
+
+// matchProvidedLinks returns the provided links that a link consumed by a
+// release job matches, by name, or by type when none has that name and no
+// other name was chosen with `from`.
+func matchProvidedLinks(providedLinks []providedLink, jobRef ReleaseJobRef, consumed bireljob.LinkDefinition) []providedLink {
+	name := jobRef.ConsumedLinkName(consumed.Name)
+
+	matches := []providedLink{}
+
+	for _, provided := range providedLinks {
+		if provided.name == name {
+			matches = append(matches, provided)
+		}
+	}
+
+	_, hasFrom := jobRef.Consumes[consumed.Name]
+
+	if len(matches) == 0 && !hasFrom && consumed.Type != "" {
+		for _, provided := range providedLinks {
+			if provided.definition.Type == consumed.Type {
+				matches = append(matches, provided)
+			}
+		}
+	}
+
+	return matches
+}
+
+func findProvidedLink(providedLinks []providedLink, jobRef ReleaseJobRef, consumed bireljob.LinkDefinition) (*providedLink, error) {
+	name := jobRef.ConsumedLinkName(consumed.Name)
+	matches := matchProvidedLinks(providedLinks, jobRef, consumed)
+
+	switch len(matches) {
+	case 0:
+		if consumed.Optional {
+			return nil, nil
+		}
+		return nil, bosherr.Errorf("No job in the deployment provides link '%s' of type '%s'", name, consumed.Type)
+
+	case 1:
+		return &matches[0], nil
+	}
+
+	return nil, bosherr.Errorf(
+		"Multiple jobs provide link '%s' of type '%s' (%s), choose one with 'from'",
+		name, consumed.Type, linkProviders(matches))
+}
+
+func linkProviders(providedLinks []providedLink) string {
+	providers := []string{}
+	for _, provided := range providedLinks {
+		providers = append(providers, provided.instanceGroup.Name+"/"+provided.releaseJob.Name())
+	}
+	return strings.Join(providers, ", ")
+}
+
+// linkSpec describes the instances of the instance group providing the link
+// and the link properties, following the link specs of the BOSH director.
+func (d Manifest) linkSpec(provided providedLink) biproperty.Map {
+	instanceGroup := provided.instanceGroup
+
+	networks := biproperty.List{}
+	for _, network := range instanceGroup.Networks {
+		networks = append(networks, network.Name)
+	}
+
+	instances := biproperty.List{}
+	for index := 0; index < instanceGroup.Instances; index++ {
+		address, _ := d.InstanceIP(instanceGroup.Name, index)
+
+		instances = append(instances, biproperty.Map{
+			"name":      instanceGroup.Name,
+			"index":     index,
+			"az":        instanceGroup.InstanceAZ(index),
+			"address":   address,
+			"bootstrap": index == 0,
+		})
+	}
+
+	return biproperty.Map{
+		"deployment_name": d.Name,
+		"instance_group":  instanceGroup.Name,
+		"default_network": defaultNetworkName(instanceGroup),
+		"networks":        networks,
+		"instances":       instances,
+		"properties":      d.linkProperties(provided),
+	}
+}
+
+// linkProperties returns the properties shared by the provided link, with
+// the values the providing job is rendered with.
+func (d Manifest) linkProperties(provided providedLink) biproperty.Map {
+	var jobProperties biproperty.Map
+	if provided.jobRef.Properties != nil {
+		jobProperties = *provided.jobRef.Properties
+	} else {
+		jobProperties = mergeProperties(d.Properties, provided.instanceGroup.Properties)
+	}
+
+	properties := biproperty.Map{}
+
+	for _, name := range provided.definition.Properties {
+		value, found := lookupLinkProperty(jobProperties, name)
+		if !found {
+			value = provided.releaseJob.Properties[name].Default
+		}
+
+		setLinkProperty(properties, name, value)
+	}
+
+	return properties
+}
+
+// lookupLinkProperty finds a dotted property name such as 'db.port' in
+// nested property maps. A property set to null is not found.
+func lookupLinkProperty(properties biproperty.Map, name string) (interface{}, bool) {
+	var current interface{} = properties
+
+	for _, key := range strings.Split(name, ".") {
+		currentMap, ok := current.(biproperty.Map)
+		if !ok {
+			return nil, false
+		}
+
+		current, ok = currentMap[key]
+		if !ok || current == nil {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// setLinkProperty sets a dotted property name such as 'db.port' in nested
+// property maps.
+func setLinkProperty(properties biproperty.Map, name string, value interface{}) {
+	keys := strings.Split(name, ".")
+
+	current := properties
+	for _, key := range keys[:len(keys)-1] {
+		nested, ok := current[key].(biproperty.Map)
+		if !ok {
+			nested = biproperty.Map{}
+			current[key] = nested
+		}
+		current = nested
+	}
+
+	current[keys[len(keys)-1]] = value
+}
+
+func defaultNetworkName(instanceGroup Job) string {
+	if len(instanceGroup.Networks) == 1 {
+		return instanceGroup.Networks[0].Name
+	}
+
+	for _, network := range instanceGroup.Networks {
+		for _, networkDefault := range network.Defaults {
+			if networkDefault == NetworkDefaultGateway {
+				return network.Name
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,175 @@
+package manifest_test
+
+import (
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/deployment/manifest"
+	bideplrel "github.com/cloudfoundry/bosh-cli/deployment/release"
+	boshinst "github.com/cloudfoundry/bosh-cli/installation"
+	boshjob "github.com/cloudfoundry/bosh-cli/release/job"
+	fakerel "github.com/cloudfoundry/bosh-cli/release/releasefakes"
+	. "github.com/cloudfoundry/bosh-cli/release/resource"
+)
+
+var _ = Describe("Manifest", func() {
+	Describe("ResolveLinks", func() {
+		var (
+			consumer           *boshjob.Job
+			provider           *boshjob.Job
+			otherProvider      *boshjob.Job
+			jobResolver        bideplrel.JobResolver
+			deploymentManifest Manifest
+		)
+
+		BeforeEach(func() {
+			consumer = boshjob.NewJob(NewResource("consumer", "", nil))
+			consumer.Consumes = []boshjob.LinkDefinition{{Name: "db", Type: "database"}}
+
+			provider = boshjob.NewJob(NewResource("provider", "", nil))
+			provider.Provides = []boshjob.LinkDefinition{{Name: "db", Type: "database", Properties: []string{"db.port", "db.name", "db.user"}}}
+			provider.Properties = map[string]boshjob.PropertyDefinition{
+				"db.port": {Default: 5432},
+				"db.name": {},
+				"db.user": {Default: "admin"},
+			}
+
+			otherProvider = boshjob.NewJob(NewResource("other-provider", "", nil))
+			otherProvider.Provides = []boshjob.LinkDefinition{{Name: "other-db", Type: "database"}}
+
+			release := &fakerel.FakeRelease{}
+			release.NameReturns("fake-release")
+			release.FindJobByNameStub = func(name string) (boshjob.Job, bool) {
+				for _, job := range []*boshjob.Job{consumer, provider, otherProvider} {
+					if job.Name() == name {
+						return *job, true
+					}
+				}
+				return boshjob.Job{}, false
+			}
+
+			releaseManager := boshinst.NewReleaseManager(boshlog.NewLogger(boshlog.LevelNone))
+			releaseManager.Add(release)
+			jobResolver = bideplrel.NewJobResolver(releaseManager)
+
+			deploymentManifest = Manifest{
+				Name:       "fake-deployment",
+				Properties: biproperty.Map{"db": biproperty.Map{"name": "fake-global-name"}},
+				Networks: []Network{
+					{Name: "fake-network", Type: "manual"},
+				},
+				Jobs: []Job{
+					{
+						Name:      "web",
+						Instances: 1,
+						Templates: []ReleaseJobRef{{Name: "consumer", Release: "fake-release"}},
+					},
+					{
+						Name:      "db",
+						Instances: 2,
+						AZs:       []string{"z1"},
+						Templates: []ReleaseJobRef{{Name: "provider", Release: "fake-release"}},
+						Networks: []JobNetwork{
+							{Name: "fake-network", StaticIPs: []string{"10.0.0.5", "10.0.0.6"}},
+						},
+						Properties: biproperty.Map{"db": biproperty.Map{"port": 5433}},
+					},
+				},
+			}
+		})
+
+		It("builds the specs of the consumed links from the providing instance groups", func() {
+			links, err := deploymentManifest.ResolveLinks("web", jobResolver)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(links).To(Equal(map[string]biproperty.Map{
+				"consumer": {
+					"db": biproperty.Map{
+						"deployment_name": "fake-deployment",
+						"instance_group":  "db",
+						"default_network": "fake-network",
+						"networks":        biproperty.List{"fake-network"},
+						"instances": biproperty.List{
+							biproperty.Map{"name": "db", "index": 0, "az": "z1", "address": "10.0.0.5", "bootstrap": true},
+							biproperty.Map{"name": "db", "index": 1, "az": "z1", "address": "10.0.0.6", "bootstrap": false},
+						},
+						"properties": biproperty.Map{
+							"db": biproperty.Map{
+								"port": 5433,
+								"name": "fake-global-name",
+								"user": "admin",
+							},
+						},
+					},
+				},
+			}))
+		})
+
+		It("uses the properties of the providing release job when it has its own", func() {
+			deploymentManifest.Jobs[1].Templates[0].Properties = &biproperty.Map{
+				"db": biproperty.Map{"name": "fake-job-name"},
+			}
+
+			links, err := deploymentManifest.ResolveLinks("web", jobResolver)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(links["consumer"]["db"].(biproperty.Map)["properties"]).To(Equal(biproperty.Map{
+				"db": biproperty.Map{
+					"port": 5432,
+					"name": "fake-job-name",
+					"user": "admin",
+				},
+			}))
+		})
+
+		It("matches links by type when no link has the consumed name", func() {
+			consumer.Consumes[0].Name = "database"
+
+			links, err := deploymentManifest.ResolveLinks("web", jobResolver)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(links["consumer"]["database"].(biproperty.Map)["instance_group"]).To(Equal("db"))
+		})
+
+		It("returns an error when several jobs provide the link", func() {
+			consumer.Consumes[0].Name = "database"
+			deploymentManifest.Jobs[1].Templates = append(deploymentManifest.Jobs[1].Templates,
+				ReleaseJobRef{Name: "other-provider", Release: "fake-release"})
+
+			_, err := deploymentManifest.ResolveLinks("web", jobResolver)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Multiple jobs provide link 'database' of type 'database' (db/provider, db/other-provider), choose one with 'from'"))
+		})
+
+		It("uses the names given with 'as' and 'from'", func() {
+			deploymentManifest.Jobs[1].Templates = append(deploymentManifest.Jobs[1].Templates,
+				ReleaseJobRef{Name: "other-provider", Release: "fake-release", Provides: map[string]string{"other-db": "primary-db"}})
+			deploymentManifest.Jobs[0].Templates[0].Consumes = map[string]string{"db": "primary-db"}
+
+			links, err := deploymentManifest.ResolveLinks("web", jobResolver)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(links["consumer"]["db"].(biproperty.Map)["properties"]).To(Equal(biproperty.Map{}))
+		})
+
+		It("leaves out optional links that are not provided", func() {
+			consumer.Consumes = append(consumer.Consumes, boshjob.LinkDefinition{Name: "cache", Optional: true})
+
+			links, err := deploymentManifest.ResolveLinks("web", jobResolver)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(links["consumer"]).To(HaveKey("db"))
+			Expect(links["consumer"]).ToNot(HaveKey("cache"))
+		})
+
+		It("returns an error when a consumed link is not provided", func() {
+			deploymentManifest.Jobs = deploymentManifest.Jobs[:1]
+
+			_, err := deploymentManifest.ResolveLinks("web", jobResolver)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("No job in the deployment provides link 'db' of type 'database'"))
+		})
+	})
+})
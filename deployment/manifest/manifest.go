@@ -1,6 +1,8 @@
 package manifest
 
 import (
+	"time"
+
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	biproperty "github.com/cloudfoundry/bosh-utils/property"
 )
@@ -14,16 +16,120 @@ type Manifest struct {
 	ResourcePools []ResourcePool
 	Update        Update
 	Tags          map[string]string
+	Features      Features
+}
+
+// Features changes how job templates are rendered, like the features section
+// of director deployment manifests.
+type Features struct {
+	// UseDNSAddresses renders spec.address as the DNS name of the instance on
+	// its default network instead of its IP.
+	UseDNSAddresses bool
+
+	// DNSDomainName ends the DNS names of instances, e.g.
+	// 0.my-instance-group.my-network.my-deployment.bosh.
+	DNSDomainName string
 }
 
 type Update struct {
 	UpdateWatchTime WatchTime
+
+	// CanaryWatchTime replaces UpdateWatchTime for canaries. It is
+	// UpdateWatchTime when the manifest does not set it.
+	CanaryWatchTime WatchTime
+
+	// Canaries is the number of instances updated first, one at a time,
+	// before the others are updated MaxInFlight at a time.
+	Canaries    int
+	MaxInFlight int
+
+	// Serial updates the instances of a batch one after the other instead of
+	// all at once.
+	Serial bool
+
+	// ScriptTimeouts limits how long the agent may run the job lifecycle
+	// scripts, keyed by script name. Scripts without a timeout are waited for
+	// as long as the agent task runs.
+	ScriptTimeouts map[string]time.Duration
+
+	// AgentWait is how long to wait for the agent to respond after its VM is
+	// created or found.
+	AgentWait AgentWait
+
+	// VMStrategy is how the VM of an instance is replaced.
+	VMStrategy VMStrategy
+}
+
+// VMStrategy is how the VM of an instance is replaced when it is recreated.
+type VMStrategy string
+
+const (
+	// VMStrategyDeleteCreate deletes the old VM before creating the new VM.
+	VMStrategyDeleteCreate VMStrategy = "delete-create"
+
+	// VMStrategyCreateSwapDelete creates the new VM and waits for its agent
+	// before the old VM is deleted and its disks are attached to the new VM.
+	VMStrategyCreateSwapDelete VMStrategy = "create-swap-delete"
+)
+
+// AgentWait configures waiting for the agent on a new VM: it is pinged every
+// PollInterval until it responds, Timeout passes, or MaxErrors pings in a
+// row have failed. A MaxErrors of 0 does not limit the number of failed
+// pings.
+type AgentWait struct {
+	Timeout      time.Duration
+	PollInterval time.Duration
+	MaxErrors    int
+}
+
+// Job lifecycle scripts run by the agent, in the order they run when
+// deploying.
+const (
+	ScriptPreStart   = "pre-start"
+	ScriptPostStart  = "post-start"
+	ScriptPostDeploy = "post-deploy"
+)
+
+var lifecycleScripts = []string{ScriptPreStart, ScriptPostStart, ScriptPostDeploy}
+
+// Batches returns the indexes of the instances in the order they are
+// updated: every canary in a batch of its own, then the other instances in
+// batches of up to MaxInFlight.
+func (u Update) Batches(instances int) [][]int {
+	batches := [][]int{}
+
+	index := 0
+	for ; index < instances && index < u.Canaries; index++ {
+		batches = append(batches, []int{index})
+	}
+
+	maxInFlight := u.MaxInFlight
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+
+	for index < instances {
+		batch := []int{}
+		for ; index < instances && len(batch) < maxInFlight; index++ {
+			batch = append(batch, index)
+		}
+		batches = append(batches, batch)
+	}
+
+	return batches
 }
 
 // NetworkInterfaces returns a map of network names to network interfaces.
 // We can't use map[string]NetworkInterface, because it's impossible to down-cast to what the cloud client requires.
-//TODO: refactor to NetworkInterfaces(Job) and use FindJobByName before using (then remove error)
+// TODO: refactor to NetworkInterfaces(Job) and use FindJobByName before using (then remove error)
 func (d Manifest) NetworkInterfaces(jobName string) (map[string]biproperty.Map, error) {
+	return d.InstanceNetworkInterfaces(jobName, 0)
+}
+
+// InstanceNetworkInterfaces returns the network interfaces of the instance
+// with the given index, which gets the static IP at that index of each
+// network.
+func (d Manifest) InstanceNetworkInterfaces(jobName string, index int) (map[string]biproperty.Map, error) {
 	job, found := d.FindJobByName(jobName)
 	if !found {
 		return map[string]biproperty.Map{}, bosherr.Errorf("Could not find job with name: %s", jobName)
@@ -35,7 +141,13 @@ func (d Manifest) NetworkInterfaces(jobName string) (map[string]biproperty.Map,
 	var err error
 	for _, jobNetwork := range job.Networks {
 		network := networkMap[jobNetwork.Name]
-		ifaceMap[jobNetwork.Name], err = network.Interface(jobNetwork.StaticIPs, jobNetwork.Defaults)
+
+		var ips []string
+		if instanceIPs := jobNetwork.InstanceIPs(); index < len(instanceIPs) {
+			ips = instanceIPs[index:]
+		}
+
+		ifaceMap[jobNetwork.Name], err = network.Interface(ips, jobNetwork.Defaults)
 		if err != nil {
 			return map[string]biproperty.Map{}, bosherr.WrapError(err, "Building network interface")
 		}
@@ -47,11 +159,79 @@ func (d Manifest) NetworkInterfaces(jobName string) (map[string]biproperty.Map,
 	return ifaceMap, nil
 }
 
+// InstanceIP returns the first static or allocated IP of the instance with
+// the given index, which is the address its agent is reached on.
+func (d Manifest) InstanceIP(jobName string, index int) (string, bool) {
+	job, found := d.FindJobByName(jobName)
+	if !found {
+		return "", false
+	}
+
+	for _, jobNetwork := range job.Networks {
+		if instanceIPs := jobNetwork.InstanceIPs(); index < len(instanceIPs) {
+			return instanceIPs[index], true
+		}
+	}
+
+	return "", false
+}
+
+// VMStrategy returns how the VMs of the job are replaced. Jobs on a manual
+// or vip network fall back to delete-create, as the IP of an instance on
+// these networks is fixed and the new VM cannot get it while the old VM has
+// it.
+func (d Manifest) VMStrategy(jobName string) VMStrategy {
+	if d.Update.VMStrategy != VMStrategyCreateSwapDelete {
+		return VMStrategyDeleteCreate
+	}
+
+	job, found := d.FindJobByName(jobName)
+	if !found {
+		return VMStrategyDeleteCreate
+	}
+
+	networks := d.networkMap()
+	for _, jobNetwork := range job.Networks {
+		if networkType := networks[jobNetwork.Name].Type; networkType == Manual || networkType == VIP {
+			return VMStrategyDeleteCreate
+		}
+	}
+
+	return VMStrategyCreateSwapDelete
+}
+
 func (d Manifest) JobName() string {
-	// Currently we deploy only one job
+	// Currently we deploy only one job, errands are colocated on its instance
+	for _, job := range d.Jobs {
+		if !job.IsErrand() {
+			return job.Name
+		}
+	}
 	return d.Jobs[0].Name
 }
 
+// ServiceJobs returns the jobs that are deployed to instances of their own.
+func (d Manifest) ServiceJobs() []Job {
+	jobs := []Job{}
+	for _, job := range d.Jobs {
+		if !job.IsErrand() {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+// ErrandJobs returns the jobs with lifecycle errand.
+func (d Manifest) ErrandJobs() []Job {
+	jobs := []Job{}
+	for _, job := range d.Jobs {
+		if job.IsErrand() {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
 func (d Manifest) Stemcell(jobName string) (StemcellRef, error) {
 	resourcePool, err := d.ResourcePool(jobName)
 	if err != nil {
@@ -60,6 +240,21 @@ func (d Manifest) Stemcell(jobName string) (StemcellRef, error) {
 	return resourcePool.Stemcell, nil
 }
 
+// WithStemcellOS returns a copy of the manifest whose resource pools use a
+// stemcell with the given operating system.
+func (d Manifest) WithStemcellOS(os string) Manifest {
+	resourcePools := make([]ResourcePool, len(d.ResourcePools))
+
+	for i, resourcePool := range d.ResourcePools {
+		resourcePool.Stemcell.OS = os
+		resourcePools[i] = resourcePool
+	}
+
+	d.ResourcePools = resourcePools
+
+	return d
+}
+
 func (d Manifest) ResourcePool(jobName string) (ResourcePool, error) {
 	job, found := d.FindJobByName(jobName)
 	if !found {
@@ -75,20 +270,20 @@ func (d Manifest) ResourcePool(jobName string) (ResourcePool, error) {
 	return ResourcePool{}, err
 }
 
+// DiskPool returns the disk pool of the disk mounted as the persistent disk
+// of the job, which is the first of its persistent_disks if it has any.
 func (d Manifest) DiskPool(jobName string) (DiskPool, error) {
 	job, found := d.FindJobByName(jobName)
 	if !found {
 		return DiskPool{}, bosherr.Errorf("Could not find job with name: %s", jobName)
 	}
 
+	if len(job.PersistentDisks) > 0 {
+		return d.findDiskPool(job.PersistentDisks[0].DiskPool, jobName)
+	}
+
 	if job.PersistentDiskPool != "" {
-		for _, diskPool := range d.DiskPools {
-			if diskPool.Name == job.PersistentDiskPool {
-				return diskPool, nil
-			}
-		}
-		err := bosherr.Errorf("Could not find persistent disk pool '%s' for job '%s'", job.PersistentDiskPool, jobName)
-		return DiskPool{}, err
+		return d.findDiskPool(job.PersistentDiskPool, jobName)
 	}
 
 	if job.PersistentDisk > 0 {
@@ -102,6 +297,60 @@ func (d Manifest) DiskPool(jobName string) (DiskPool, error) {
 	return DiskPool{}, nil
 }
 
+// NamedDisk is a persistent disk of a job that is attached to its VM in
+// addition to the disk mounted as its persistent disk.
+type NamedDisk struct {
+	Name     string
+	DiskPool DiskPool
+}
+
+// NamedDisks returns the persistent_disks of the job after the first, in
+// order.
+func (d Manifest) NamedDisks(jobName string) ([]NamedDisk, error) {
+	job, found := d.FindJobByName(jobName)
+	if !found {
+		return []NamedDisk{}, bosherr.Errorf("Could not find job with name: %s", jobName)
+	}
+
+	namedDisks := []NamedDisk{}
+
+	for i, persistentDisk := range job.PersistentDisks {
+		if i == 0 {
+			continue
+		}
+
+		diskPool, err := d.findDiskPool(persistentDisk.DiskPool, jobName)
+		if err != nil {
+			return []NamedDisk{}, err
+		}
+
+		namedDisks = append(namedDisks, NamedDisk{Name: persistentDisk.Name, DiskPool: diskPool})
+	}
+
+	return namedDisks, nil
+}
+
+func (d Manifest) findDiskPool(name string, jobName string) (DiskPool, error) {
+	for _, diskPool := range d.DiskPools {
+		if diskPool.Name == name {
+			return diskPool, nil
+		}
+	}
+
+	return DiskPool{}, bosherr.Errorf("Could not find persistent disk pool '%s' for job '%s'", name, jobName)
+}
+
+// ResolvedJobProperties returns the effective properties for a job:
+// global deployment properties deep-merged under the job's own properties (job wins on conflict).
+func (d Manifest) ResolvedJobProperties(jobName string) (biproperty.Map, error) {
+	job, found := d.FindJobByName(jobName)
+	if !found {
+		return biproperty.Map{}, bosherr.Errorf("Could not find job with name: %s", jobName)
+	}
+
+	return mergeProperties(d.Properties, job.Properties), nil
+}
+
 func (d Manifest) networkMap() map[string]Network {
 	result := map[string]Network{}
 	for _, network := range d.Networks {
@@ -110,6 +359,34 @@ func (d Manifest) networkMap() map[string]Network {
 	return result
 }
 
+// WatchTime returns how long to wait for the jobs on the instance of the job
+// with the given index to be running after they are updated: the canary watch
+// time for canaries and the update watch time for the other instances, as
+// overridden by the update section of the job.
+func (d Manifest) WatchTime(jobName string, index int) WatchTime {
+	canary := index < d.Update.Canaries
+
+	watchTime := d.Update.UpdateWatchTime
+	if canary {
+		watchTime = d.Update.CanaryWatchTime
+	}
+
+	job, found := d.FindJobByName(jobName)
+	if !found {
+		return watchTime
+	}
+
+	if canary && job.Update.CanaryWatchTime != nil {
+		return *job.Update.CanaryWatchTime
+	}
+
+	if !canary && job.Update.UpdateWatchTime != nil {
+		return *job.Update.UpdateWatchTime
+	}
+
+	return watchTime
+}
+
 func (d Manifest) FindJobByName(jobName string) (Job, bool) {
 	for _, job := range d.Jobs {
 		if job.Name == jobName {
@@ -156,6 +156,28 @@ var _ = Describe("Manifest", func() {
 				})
 			})
 
+			It("gives each instance the static IPs at its index", func() {
+				deploymentManifest.Jobs[0].Networks[1].StaticIPs = []string{"5.6.7.8", "5.6.7.18"}
+
+				Expect(deploymentManifest.InstanceNetworkInterfaces("fake-job-name", 1)).To(Equal(map[string]biproperty.Map{
+					"fake-network-name": biproperty.Map{
+						"type":             "dynamic",
+						"ip":               "5.6.7.18",
+						"cloud_properties": biproperty.Map{},
+					},
+					"fake-manual-network-name": biproperty.Map{
+						"type":             "manual",
+						"netmask":          "255.255.252.0",
+						"gateway":          "1.1.1.1",
+						"cloud_properties": biproperty.Map{},
+					},
+					"vip": biproperty.Map{
+						"type":             "vip",
+						"cloud_properties": biproperty.Map{},
+					},
+				}))
+			})
+
 			It("returns an error when the deployment does not have a job with requested name", func() {
 				networkInterfaces, err := deploymentManifest.NetworkInterfaces("non-existant-job")
 				Expect(networkInterfaces).To(Equal(map[string]biproperty.Map{}))
@@ -186,6 +208,170 @@ var _ = Describe("Manifest", func() {
 		})
 	})
 
+	Describe("InstanceIP", func() {
+		BeforeEach(func() {
+			deploymentManifest = Manifest{
+				Jobs: []Job{
+					{
+						Name: "fake-job-name",
+						Networks: []JobNetwork{
+							{Name: "dynamic"},
+							{Name: "private", StaticIPs: []string{"10.0.0.6", "10.0.0.7"}},
+						},
+					},
+				},
+			}
+		})
+
+		It("is the first static IP at the index of the instance", func() {
+			ip, found := deploymentManifest.InstanceIP("fake-job-name", 1)
+			Expect(found).To(BeTrue())
+			Expect(ip).To(Equal("10.0.0.7"))
+		})
+
+		It("is not found when the instance has no static IP", func() {
+			_, found := deploymentManifest.InstanceIP("fake-job-name", 2)
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Describe("errands", func() {
+		BeforeEach(func() {
+			deploymentManifest = Manifest{
+				Jobs: []Job{
+					{Name: "smoke-tests", Lifecycle: JobLifecycleErrand},
+					{Name: "fake-job-name"},
+					{Name: "other-errand", Lifecycle: JobLifecycleErrand},
+				},
+			}
+		})
+
+		It("deploys the first job that is not an errand", func() {
+			Expect(deploymentManifest.JobName()).To(Equal("fake-job-name"))
+			Expect(deploymentManifest.ServiceJobs()).To(Equal([]Job{{Name: "fake-job-name"}}))
+		})
+
+		It("returns the errand jobs", func() {
+			Expect(deploymentManifest.ErrandJobs()).To(Equal([]Job{
+				{Name: "smoke-tests", Lifecycle: JobLifecycleErrand},
+				{Name: "other-errand", Lifecycle: JobLifecycleErrand},
+			}))
+		})
+	})
+
+	Describe("Update.Batches", func() {
+		It("updates every canary on its own, then the others max in flight at a time", func() {
+			update := Update{Canaries: 1, MaxInFlight: 2}
+			Expect(update.Batches(4)).To(Equal([][]int{{0}, {1, 2}, {3}}))
+		})
+
+		It("updates at most as many canaries as instances", func() {
+			update := Update{Canaries: 2, MaxInFlight: 1}
+			Expect(update.Batches(1)).To(Equal([][]int{{0}}))
+		})
+
+		It("updates one instance at a time without max in flight", func() {
+			update := Update{}
+			Expect(update.Batches(2)).To(Equal([][]int{{0}, {1}}))
+		})
+	})
+
+	Describe("WatchTime", func() {
+		var deploymentManifest Manifest
+
+		BeforeEach(func() {
+			deploymentManifest = Manifest{
+				Update: Update{
+					UpdateWatchTime: WatchTime{Start: 0, End: 1000},
+					CanaryWatchTime: WatchTime{Start: 0, End: 2000},
+					Canaries:        1,
+				},
+				Jobs: []Job{
+					{Name: "fake-job-name"},
+					{
+						Name: "fake-job-with-update",
+						Update: JobUpdate{
+							UpdateWatchTime: &WatchTime{Start: 0, End: 3000},
+							CanaryWatchTime: &WatchTime{Start: 0, End: 4000},
+						},
+					},
+					{
+						Name: "fake-job-with-update-watch-time",
+						Update: JobUpdate{
+							UpdateWatchTime: &WatchTime{Start: 0, End: 3000},
+						},
+					},
+				},
+			}
+		})
+
+		It("returns the watch times of the deployment", func() {
+			Expect(deploymentManifest.WatchTime("fake-job-name", 0)).To(Equal(WatchTime{Start: 0, End: 2000}))
+			Expect(deploymentManifest.WatchTime("fake-job-name", 1)).To(Equal(WatchTime{Start: 0, End: 1000}))
+		})
+
+		It("returns the watch times of the job when it sets them", func() {
+			Expect(deploymentManifest.WatchTime("fake-job-with-update", 0)).To(Equal(WatchTime{Start: 0, End: 4000}))
+			Expect(deploymentManifest.WatchTime("fake-job-with-update", 1)).To(Equal(WatchTime{Start: 0, End: 3000}))
+		})
+
+		It("returns the watch times of the deployment the job does not set", func() {
+			Expect(deploymentManifest.WatchTime("fake-job-with-update-watch-time", 0)).To(Equal(WatchTime{Start: 0, End: 2000}))
+			Expect(deploymentManifest.WatchTime("fake-job-with-update-watch-time", 1)).To(Equal(WatchTime{Start: 0, End: 3000}))
+		})
+	})
+
+	Describe("VMStrategy", func() {
+		var deploymentManifest Manifest
+
+		BeforeEach(func() {
+			deploymentManifest = Manifest{
+				Update: Update{VMStrategy: VMStrategyCreateSwapDelete},
+				Networks: []Network{
+					{Name: "fake-manual-network", Type: Manual},
+					{Name: "fake-dynamic-network", Type: Dynamic},
+					{Name: "fake-vip-network", Type: VIP},
+				},
+				Jobs: []Job{
+					{
+						Name: "fake-dynamic-job",
+						Networks: []JobNetwork{
+							{Name: "fake-dynamic-network"},
+						},
+					},
+					{
+						Name: "fake-vip-job",
+						Networks: []JobNetwork{
+							{Name: "fake-dynamic-network"},
+							{Name: "fake-vip-network", StaticIPs: []string{"1.2.3.4"}},
+						},
+					},
+					{
+						Name: "fake-manual-job",
+						Networks: []JobNetwork{
+							{Name: "fake-manual-network"},
+						},
+					},
+				},
+			}
+		})
+
+		It("returns the VM strategy of the deployment", func() {
+			Expect(deploymentManifest.VMStrategy("fake-dynamic-job")).To(Equal(VMStrategyCreateSwapDelete))
+
+			deploymentManifest.Update.VMStrategy = VMStrategyDeleteCreate
+			Expect(deploymentManifest.VMStrategy("fake-dynamic-job")).To(Equal(VMStrategyDeleteCreate))
+		})
+
+		It("falls back to delete-create for jobs on a manual network", func() {
+			Expect(deploymentManifest.VMStrategy("fake-manual-job")).To(Equal(VMStrategyDeleteCreate))
+		})
+
+		It("falls back to delete-create for jobs on a vip network", func() {
+			Expect(deploymentManifest.VMStrategy("fake-vip-job")).To(Equal(VMStrategyDeleteCreate))
+		})
+	})
+
 	Describe("ResourcePool", func() {
 		BeforeEach(func() {
 			deploymentManifest = Manifest{
@@ -228,6 +414,36 @@ var _ = Describe("Manifest", func() {
 		})
 	})
 
+	Describe("WithStemcellOS", func() {
+		BeforeEach(func() {
+			deploymentManifest = Manifest{
+				ResourcePools: []ResourcePool{
+					{
+						Name:     "fake-resource-pool-name",
+						Stemcell: StemcellRef{URL: "file://stemcell.tgz"},
+					},
+				},
+				Jobs: []Job{
+					{
+						Name:         "fake-job-name",
+						ResourcePool: "fake-resource-pool-name",
+					},
+				},
+			}
+		})
+
+		It("sets the operating system of the stemcell of every resource pool", func() {
+			stemcell, err := deploymentManifest.WithStemcellOS("windows2012R2").Stemcell("fake-job-name")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stemcell).To(Equal(StemcellRef{URL: "file://stemcell.tgz", OS: "windows2012R2"}))
+		})
+
+		It("does not modify the manifest", func() {
+			deploymentManifest.WithStemcellOS("windows2012R2")
+			Expect(deploymentManifest.ResourcePools[0].Stemcell.OS).To(BeEmpty())
+		})
+	})
+
 	Describe("DiskPool", func() {
 		Context("when the deployment has disk_pools", func() {
 			BeforeEach(func() {
@@ -367,6 +583,88 @@ var _ = Describe("Manifest", func() {
 				Expect(diskPool).To(Equal(DiskPool{}))
 			})
 		})
+
+		Context("when job has persistent_disks", func() {
+			BeforeEach(func() {
+				deploymentManifest = Manifest{
+					DiskPools: []DiskPool{
+						{Name: "fake-disk-pool-name-1", DiskSize: 1024},
+						{Name: "fake-disk-pool-name-2", DiskSize: 2048},
+					},
+					Jobs: []Job{
+						{
+							Name: "fake-job-name",
+							PersistentDisks: []JobPersistentDisk{
+								{Name: "fake-disk-name-1", DiskPool: "fake-disk-pool-name-2"},
+								{Name: "fake-disk-name-2", DiskPool: "fake-disk-pool-name-1"},
+							},
+						},
+					},
+				}
+			})
+
+			It("returns the disk pool of the first disk", func() {
+				diskPool, err := deploymentManifest.DiskPool("fake-job-name")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(diskPool).To(Equal(DiskPool{Name: "fake-disk-pool-name-2", DiskSize: 2048}))
+			})
+		})
+	})
+
+	Describe("NamedDisks", func() {
+		It("returns the persistent disks after the first with their disk pools", func() {
+			deploymentManifest = Manifest{
+				DiskPools: []DiskPool{
+					{Name: "fake-disk-pool-name-1", DiskSize: 1024},
+					{Name: "fake-disk-pool-name-2", DiskSize: 2048},
+				},
+				Jobs: []Job{
+					{
+						Name: "fake-job-name",
+						PersistentDisks: []JobPersistentDisk{
+							{Name: "fake-disk-name-1", DiskPool: "fake-disk-pool-name-1"},
+							{Name: "fake-disk-name-2", DiskPool: "fake-disk-pool-name-2"},
+							{Name: "fake-disk-name-3", DiskPool: "fake-disk-pool-name-1"},
+						},
+					},
+				},
+			}
+
+			namedDisks, err := deploymentManifest.NamedDisks("fake-job-name")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(namedDisks).To(Equal([]NamedDisk{
+				{Name: "fake-disk-name-2", DiskPool: DiskPool{Name: "fake-disk-pool-name-2", DiskSize: 2048}},
+				{Name: "fake-disk-name-3", DiskPool: DiskPool{Name: "fake-disk-pool-name-1", DiskSize: 1024}},
+			}))
+		})
+
+		It("returns no disks when the job uses persistent_disk", func() {
+			deploymentManifest = Manifest{
+				Jobs: []Job{{Name: "fake-job-name", PersistentDisk: 1024}},
+			}
+
+			namedDisks, err := deploymentManifest.NamedDisks("fake-job-name")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(namedDisks).To(BeEmpty())
+		})
+
+		It("returns an error when a disk pool does not exist", func() {
+			deploymentManifest = Manifest{
+				Jobs: []Job{
+					{
+						Name: "fake-job-name",
+						PersistentDisks: []JobPersistentDisk{
+							{Name: "fake-disk-name-1", DiskPool: "fake-disk-pool-name-1"},
+							{Name: "fake-disk-name-2", DiskPool: "fake-missing-disk-pool"},
+						},
+					},
+				},
+			}
+
+			_, err := deploymentManifest.NamedDisks("fake-job-name")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Could not find persistent disk pool 'fake-missing-disk-pool' for job 'fake-job-name'"))
+		})
 	})
 
 	Describe("Tags", func() {
@@ -380,4 +678,88 @@ var _ = Describe("Manifest", func() {
 			Expect(deploymentManifest.Tags["custom-tag"]).To(Equal("custom-value"))
 		})
 	})
+
+	Describe("ResolvedJobProperties", func() {
+		BeforeEach(func() {
+			deploymentManifest = Manifest{
+				Properties: biproperty.Map{
+					"global-only": "global-value",
+					"overridden":  "global-value",
+					"nested": biproperty.Map{
+						"global-key": "global-value",
+						"shared-key": "global-value",
+						"deeper": biproperty.Map{
+							"global-key": "global-value",
+						},
+					},
+					"replaced-map": biproperty.Map{
+						"global-key": "global-value",
+					},
+				},
+				Jobs: []Job{
+					{
+						Name: "fake-job-name",
+						Properties: biproperty.Map{
+							"job-only":   "job-value",
+							"overridden": "job-value",
+							"nested": biproperty.Map{
+								"shared-key": "job-value",
+								"deeper": biproperty.Map{
+									"job-key": "job-value",
+								},
+							},
+							"replaced-map": "job-scalar",
+						},
+					},
+					{
+						Name: "job-without-properties",
+					},
+				},
+			}
+		})
+
+		It("deep-merges global properties under job properties", func() {
+			properties, err := deploymentManifest.ResolvedJobProperties("fake-job-name")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(properties).To(Equal(biproperty.Map{
+				"global-only": "global-value",
+				"job-only":    "job-value",
+				"overridden":  "job-value",
+				"nested": biproperty.Map{
+					"global-key": "global-value",
+					"shared-key": "job-value",
+					"deeper": biproperty.Map{
+						"global-key": "global-value",
+						"job-key":    "job-value",
+					},
+				},
+				"replaced-map": "job-scalar",
+			}))
+		})
+
+		It("does not modify the manifest properties", func() {
+			_, err := deploymentManifest.ResolvedJobProperties("fake-job-name")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentManifest.Properties["overridden"]).To(Equal("global-value"))
+			Expect(deploymentManifest.Properties["nested"]).To(Equal(biproperty.Map{
+				"global-key": "global-value",
+				"shared-key": "global-value",
+				"deeper": biproperty.Map{
+					"global-key": "global-value",
+				},
+			}))
+		})
+
+		It("returns global properties for a job without properties", func() {
+			properties, err := deploymentManifest.ResolvedJobProperties("job-without-properties")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(properties).To(Equal(deploymentManifest.Properties))
+		})
+
+		It("returns an error when the job does not exist", func() {
+			_, err := deploymentManifest.ResolvedJobProperties("fake-missing-job")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Could not find job with name: fake-missing-job"))
+		})
+	})
 })
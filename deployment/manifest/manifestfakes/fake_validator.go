@@ -11,8 +11,10 @@ import (
 type FakeValidator struct {
 	ValidateInputs             []ValidateInput
 	ValidateReleaseJobsInputs  []ValidateReleaseJobsInput
+	ValidateSchemaInputs       [][]byte
 	validateOutputs            []ValidateOutput
 	validateReleaseJobsOutputs []ValidateReleaseJobsOutput
+	ValidateSchemaErr          error
 }
 
 func NewFakeValidator() *FakeValidator {
@@ -70,6 +72,11 @@ func (v *FakeValidator) ValidateReleaseJobs(manifest bideplmanifest.Manifest, re
 	return validateReleaseJobsOutput.Err
 }
 
+func (v *FakeValidator) ValidateSchema(contents []byte) error {
+	v.ValidateSchemaInputs = append(v.ValidateSchemaInputs, contents)
+	return v.ValidateSchemaErr
+}
+
 func (v *FakeValidator) SetValidateBehavior(outputs []ValidateOutput) {
 	v.validateOutputs = outputs
 }
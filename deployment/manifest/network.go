@@ -1,8 +1,6 @@
 package manifest
 
 import (
-	"encoding/hex"
-	"fmt"
 	"net"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -27,16 +25,99 @@ type Network struct {
 	CloudProperties biproperty.Map
 	DNS             []string
 	Subnets         []Subnet
+
+	// IP, Netmask and Gateway are given on networks without subnets. The
+	// parser turns those of a manual network into its only subnet.
+	IP      string
+	Netmask string
+	Gateway string
 }
 
 type Subnet struct {
-	Range           string
-	Gateway         string
-	DNS             []string
+	Range    string
+	Gateway  string
+	DNS      []string
+	Static   []string
+	Reserved []string
+
+	// AZs lists the availability zones the subnet is in; a subnet without
+	// AZs is available to every job.
+	AZs             []string
 	CloudProperties biproperty.Map
 }
 
+// flatSubnetRange returns the range of the only subnet of a network given
+// with a netmask and an address in the subnet instead of subnets. IPv6
+// networks give their netmask in address notation, e.g. 'ffff:ffff:ffff:ffff::'.
+func flatSubnetRange(netmask string, address string) (*net.IPNet, error) {
+	netmaskIP := net.ParseIP(netmask)
+	addressIP := net.ParseIP(address)
+	if netmaskIP == nil || addressIP == nil || (netmaskIP.To4() == nil) != (addressIP.To4() == nil) {
+		return nil, bosherr.Error("netmask and gateway must be IPs of the same IP version")
+	}
+
+	mask := net.IPMask(netmaskIP)
+	if addressIP.To4() != nil {
+		mask = net.IPMask(netmaskIP.To4())
+		addressIP = addressIP.To4()
+	}
+
+	if _, bits := mask.Size(); bits == 0 {
+		return nil, bosherr.Error("netmask must be contiguous")
+	}
+
+	return &net.IPNet{IP: addressIP.Mask(mask), Mask: mask}, nil
+}
+
+func (s Subnet) availableIn(azs []string) bool {
+	if len(s.AZs) == 0 || len(azs) == 0 {
+		return true
+	}
+
+	for _, subnetAZ := range s.AZs {
+		for _, az := range azs {
+			if subnetAZ == az {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// subnetFor returns the subnet whose range contains ip, or the first subnet
+// when ip is empty or in none of them.
+func (n Network) subnetFor(ip string) Subnet {
+	parsedIP := net.ParseIP(ip)
+	if parsedIP != nil {
+		for _, subnet := range n.Subnets {
+			_, ipNet, err := net.ParseCIDR(subnet.Range)
+			if err == nil && ipNet.Contains(parsedIP) {
+				return subnet
+			}
+		}
+	}
+
+	return n.Subnets[0]
+}
+
+// Subnet returns the parsed range of the network's first subnet.
+func (n Network) Subnet() (*net.IPNet, error) {
+	if len(n.Subnets) == 0 {
+		return nil, bosherr.Errorf("Network '%s' does not have any subnets", n.Name)
+	}
+
+	_, ipNet, err := net.ParseCIDR(n.Subnets[0].Range)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Parsing network '%s' subnet range", n.Name)
+	}
+
+	return ipNet, nil
+}
+
 // Interface returns a property map representing a generic network interface.
+// Manual networks take the gateway, dns, netmask and cloud properties of the
+// subnet that contains the first of the given IPs.
 // Expected Keys: ip, type, cloud properties.
 // Optional Keys: netmask, gateway, dns
 func (n Network) Interface(staticIPs []string, networkDefaults []NetworkDefault) (biproperty.Map, error) {
@@ -45,22 +126,25 @@ func (n Network) Interface(staticIPs []string, networkDefaults []NetworkDefault)
 	}
 
 	if n.Type == Manual {
-		networkInterface["gateway"] = n.Subnets[0].Gateway
-		if len(n.Subnets[0].DNS) > 0 {
-			networkInterface["dns"] = n.Subnets[0].DNS
+		var ip string
+		if len(staticIPs) > 0 {
+			ip = staticIPs[0]
 		}
+		subnet := n.subnetFor(ip)
 
-		_, ipNet, err := net.ParseCIDR(n.Subnets[0].Range)
-		if err != nil {
-			return biproperty.Map{}, bosherr.WrapError(err, "Failed to parse subnet range")
+		networkInterface["gateway"] = subnet.Gateway
+		if len(subnet.DNS) > 0 {
+			networkInterface["dns"] = subnet.DNS
 		}
-		ipParts, err := hex.DecodeString(ipNet.Mask.String())
+
+		_, ipNet, err := net.ParseCIDR(subnet.Range)
 		if err != nil {
-			return biproperty.Map{}, bosherr.WrapError(err, "Failed to convert subnet range to IP string")
+			return biproperty.Map{}, bosherr.WrapError(err, "Failed to parse subnet range")
 		}
-		networkInterface["netmask"] = fmt.Sprintf("%v.%v.%v.%v", ipParts[0], ipParts[1], ipParts[2], ipParts[3])
+		// IPv4 masks are 4 bytes long and format as dotted quads, IPv6 masks as IPv6 addresses
+		networkInterface["netmask"] = net.IP(ipNet.Mask).String()
 
-		networkInterface["cloud_properties"] = n.Subnets[0].CloudProperties
+		networkInterface["cloud_properties"] = subnet.CloudProperties
 	} else {
 		networkInterface["cloud_properties"] = n.CloudProperties
 	}
@@ -65,6 +65,45 @@ var _ = Describe("Network", func() {
 				}))
 			})
 
+			Context("when the network has more than one subnet", func() {
+				BeforeEach(func() {
+					network.Subnets = append(network.Subnets, Subnet{
+						Range:           "5.6.7.0/24",
+						Gateway:         "5.6.7.1",
+						CloudProperties: biproperty.Map{"cp_key": "other_cp_value"},
+					})
+				})
+
+				It("uses the subnet that contains the ip", func() {
+					iface, err := network.Interface([]string{"5.6.7.9"}, []NetworkDefault{})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(iface).To(Equal(biproperty.Map{
+						"type":    "manual",
+						"ip":      "5.6.7.9",
+						"gateway": "5.6.7.1",
+						"netmask": "255.255.255.0",
+						"cloud_properties": biproperty.Map{
+							"cp_key": "other_cp_value",
+						},
+					}))
+				})
+			})
+
+			Context("when the range is IPv6", func() {
+				BeforeEach(func() {
+					network.Subnets[0].Range = "2001:db8:1::/64"
+					network.Subnets[0].Gateway = "2001:db8:1::1"
+				})
+
+				It("includes the netmask in IPv6 notation", func() {
+					iface, err := network.Interface([]string{"2001:db8:1::10"}, []NetworkDefault{})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(iface["ip"]).To(Equal("2001:db8:1::10"))
+					Expect(iface["gateway"]).To(Equal("2001:db8:1::1"))
+					Expect(iface["netmask"]).To(Equal("ffff:ffff:ffff:ffff::"))
+				})
+			})
+
 			Context("when range is invalid", func() {
 				BeforeEach(func() {
 					network.Subnets[0].Range = "invalid-range"
@@ -103,4 +142,25 @@ var _ = Describe("Network", func() {
 			})
 		})
 	})
+
+	Describe("Subnet", func() {
+		It("returns the parsed range of the first subnet", func() {
+			network = Network{
+				Name:    "fake-manual-network-name",
+				Subnets: []Subnet{{Range: "10.0.1.0/24"}, {Range: "10.0.2.0/24"}},
+			}
+
+			ipNet, err := network.Subnet()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ipNet.String()).To(Equal("10.0.1.0/24"))
+		})
+
+		It("returns an error when the network has no subnets", func() {
+			network = Network{Name: "fake-dynamic-network-name"}
+
+			_, err := network.Subnet()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Network 'fake-dynamic-network-name' does not have any subnets"))
+		})
+	})
 })
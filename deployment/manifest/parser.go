@@ -1,8 +1,16 @@
 package manifest
 
 import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	binet "github.com/cloudfoundry/bosh-cli/common/net"
 	biutil "github.com/cloudfoundry/bosh-cli/common/util"
 	bidepltpl "github.com/cloudfoundry/bosh-cli/deployment/template"
+	birelmanifest "github.com/cloudfoundry/bosh-cli/release/manifest"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	biproperty "github.com/cloudfoundry/bosh-utils/property"
@@ -18,6 +26,13 @@ type parser struct {
 	fs     boshsys.FileSystem
 	logger boshlog.Logger
 	logTag string
+	opts   ParserOpts
+}
+
+type ParserOpts struct {
+	// DefaultGatewayFromCIDR fills in a blank manual network gateway with the
+	// first host address of its subnet instead of rejecting the network.
+	DefaultGatewayFromCIDR bool
 }
 
 type manifest struct {
@@ -26,14 +41,37 @@ type manifest struct {
 	Networks       []network
 	ResourcePools  []resourcePool `yaml:"resource_pools"`
 	DiskPools      []diskPool     `yaml:"disk_pools"`
+	DiskTypes      []diskPool     `yaml:"disk_types"`
 	Jobs           []job
-	InstanceGroups []job `yaml:"instance_groups"`
+	InstanceGroups []job      `yaml:"instance_groups"`
+	AZs            []az       `yaml:"azs"`
+	VMTypes        []vmType   `yaml:"vm_types"`
+	VMExtensions   []vmType   `yaml:"vm_extensions"`
+	Stemcells      []stemcell `yaml:"stemcells"`
 	Properties     map[interface{}]interface{}
 	Tags           map[string]string
+	Features       FeaturesSpec
+}
+
+type FeaturesSpec struct {
+	UseDNSAddresses *bool   `yaml:"use_dns_addresses"`
+	DNSDomainName   *string `yaml:"dns_domain_name"`
 }
 
 type UpdateSpec struct {
 	UpdateWatchTime *string `yaml:"update_watch_time"`
+	CanaryWatchTime *string `yaml:"canary_watch_time"`
+	Canaries        *int    `yaml:"canaries"`
+	MaxInFlight     *int    `yaml:"max_in_flight"`
+	Serial          *bool   `yaml:"serial"`
+
+	ScriptTimeouts map[string]string `yaml:"script_timeouts"`
+
+	AgentWaitTimeout  *string `yaml:"agent_wait_timeout"`
+	AgentPollInterval *string `yaml:"agent_poll_interval"`
+	AgentMaxErrors    *int    `yaml:"agent_max_errors"`
+
+	VMStrategy *string `yaml:"vm_strategy"`
 }
 
 type network struct {
@@ -51,6 +89,10 @@ type subnet struct {
 	Range           string                      `yaml:"range"`
 	Gateway         string                      `yaml:"gateway"`
 	DNS             []string                    `yaml:"dns"`
+	Static          []string                    `yaml:"static"`
+	Reserved        []string                    `yaml:"reserved"`
+	AZ              string                      `yaml:"az"`
+	AZs             []string                    `yaml:"azs"`
 	CloudProperties map[interface{}]interface{} `yaml:"cloud_properties"`
 }
 
@@ -60,25 +102,50 @@ type resourcePool struct {
 	CloudProperties map[interface{}]interface{} `yaml:"cloud_properties"`
 	Env             map[interface{}]interface{} `yaml:"env"`
 	Stemcell        stemcellRef                 `yaml:"stemcell"`
+	CPI             string                      `yaml:"cpi"`
 }
 
 type diskPool struct {
 	Name            string                      `yaml:"name"`
-	DiskSize        int                         `yaml:"disk_size"`
+	DiskSize        int64                       `yaml:"disk_size"`
 	CloudProperties map[interface{}]interface{} `yaml:"cloud_properties"`
 }
 
 type job struct {
 	Name               string
-	Instances          int
+	Instances          int64
 	Lifecycle          string
 	Templates          []releaseJobRef
 	Jobs               []releaseJobRef `yaml:"jobs"`
 	Networks           []jobNetwork
-	PersistentDisk     int    `yaml:"persistent_disk"`
+	PersistentDisk     int64  `yaml:"persistent_disk"`
 	PersistentDiskPool string `yaml:"persistent_disk_pool"`
+	PersistentDiskType string `yaml:"persistent_disk_type"`
 	ResourcePool       string `yaml:"resource_pool"`
 	Properties         map[interface{}]interface{}
+	Update             *jobUpdate `yaml:"update"`
+
+	PersistentDisks []jobPersistentDisk `yaml:"persistent_disks"`
+
+	// v2 schema fields, see resolveInstanceGroups
+	VMType       string                      `yaml:"vm_type"`
+	VMExtensions []string                    `yaml:"vm_extensions"`
+	Stemcell     string                      `yaml:"stemcell"`
+	AZs          []string                    `yaml:"azs"`
+	Env          map[interface{}]interface{} `yaml:"env"`
+}
+
+type jobUpdate struct {
+	UpdateWatchTime *string `yaml:"update_watch_time"`
+	CanaryWatchTime *string `yaml:"canary_watch_time"`
+}
+
+type jobPersistentDisk struct {
+	Name     string
+	DiskPool string `yaml:"disk_pool"`
+
+	// v2 schema field, see resolveInstanceGroups
+	Type string `yaml:"type"`
 }
 
 type releaseJobRef struct {
@@ -88,42 +155,89 @@ type releaseJobRef struct {
 	// This is a pointer so we can differentiate between `properties: {}`
 	// and not specifying the key at all.
 	Properties *map[interface{}]interface{}
+
+	Consumes map[string]*linkRef `yaml:"consumes"`
+	Provides map[string]*linkRef `yaml:"provides"`
+}
+
+type linkRef struct {
+	From string `yaml:"from"`
+	As   string `yaml:"as"`
 }
 
 type stemcellRef struct {
-	URL  string
-	SHA1 string
+	URL       string
+	SHA1      string
+	Signature birelmanifest.SignatureRef
 }
 
 type jobNetwork struct {
-	Name      string
-	Defaults  []string `yaml:"default"`
-	StaticIPs []string `yaml:"static_ips"`
+	Name          string
+	Defaults      []string `yaml:"default"`
+	StaticIPs     []string `yaml:"static_ips"`
+	StaticIPCount int      `yaml:"static_ip_count"`
 }
 
+const (
+	// MaxInstances is the exclusive upper bound for a job's instances.
+	MaxInstances = 10000
+
+	// MaxDiskSize is the exclusive upper bound for disk sizes, in MiB (1 PiB).
+	MaxDiskSize = 1024 * 1024 * 1024
+)
+
+// dnsDomainNamePattern matches domain names made of DNS labels, e.g. bosh or
+// internal.example.com.
+var dnsDomainNamePattern = regexp.MustCompile(`^(?i)[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+
 var boshDeploymentDefaults = Manifest{
 	Update: Update{
 		UpdateWatchTime: WatchTime{
 			Start: 0,
 			End:   300000,
 		},
+		CanaryWatchTime: WatchTime{
+			Start: 0,
+			End:   300000,
+		},
+		Canaries:    1,
+		MaxInFlight: 1,
+		Serial:      true,
+		AgentWait: AgentWait{
+			Timeout:      10 * time.Minute,
+			PollInterval: 500 * time.Millisecond,
+		},
+		VMStrategy: VMStrategyDeleteCreate,
+	},
+	Features: Features{
+		DNSDomainName: "bosh",
 	},
 }
 
 func NewParser(fs boshsys.FileSystem, logger boshlog.Logger) Parser {
+	return NewParserWithOpts(fs, logger, ParserOpts{})
+}
+
+func NewParserWithOpts(fs boshsys.FileSystem, logger boshlog.Logger, opts ParserOpts) Parser {
 	return &parser{
 		fs:     fs,
 		logger: logger,
 		logTag: "deploymentParser",
+		opts:   opts,
 	}
 }
 
 func (p *parser) Parse(interpolatedTemplate bidepltpl.InterpolatedTemplate, path string) (Manifest, error) {
 	bytes := interpolatedTemplate.Content()
 
+	err := NewValidator(p.logger).ValidateSchema(bytes)
+	if err != nil {
+		return Manifest{}, bosherr.WrapError(err, "Validating BOSH deployment manifest schema")
+	}
+
 	comboManifest := manifest{}
 
-	err := yaml.Unmarshal(bytes, &comboManifest)
+	err = yaml.Unmarshal(bytes, &comboManifest)
 	if err != nil {
 		return Manifest{}, bosherr.WrapError(err, "Unmarshalling BOSH deployment manifest")
 	}
@@ -139,10 +253,21 @@ func (p *parser) Parse(interpolatedTemplate bidepltpl.InterpolatedTemplate, path
 }
 
 func (p *parser) parseDeploymentManifest(depManifest manifest, path string) (Manifest, error) {
+	depManifest, err := p.resolveInstanceGroups(depManifest)
+	if err != nil {
+		return Manifest{}, bosherr.WrapError(err, "Resolving instance groups")
+	}
+
 	deployment := boshDeploymentDefaults
 	deployment.Name = depManifest.Name
 	deployment.Tags = depManifest.Tags
 
+	features, err := p.parseFeatures(depManifest.Features)
+	if err != nil {
+		return Manifest{}, err
+	}
+	deployment.Features = features
+
 	networks, err := p.parseNetworkManifests(depManifest.Networks)
 	if err != nil {
 		return Manifest{}, bosherr.WrapErrorf(err, "Parsing networks: %#v", depManifest.Networks)
@@ -156,7 +281,7 @@ func (p *parser) parseDeploymentManifest(depManifest manifest, path string) (Man
 
 	deployment.ResourcePools = resourcePools
 
-	diskPools, err := p.parseDiskPoolManifests(depManifest.DiskPools)
+	diskPools, err := p.parseDiskPoolManifests(depManifest.DiskPools, depManifest.DiskTypes)
 	if err != nil {
 		return Manifest{}, bosherr.WrapErrorf(err, "Parsing disk_pools: %#v", depManifest.DiskPools)
 	}
@@ -176,6 +301,19 @@ func (p *parser) parseDeploymentManifest(depManifest manifest, path string) (Man
 	}
 	deployment.Jobs = jobs
 
+	err = NewStaticIPAllocator().Allocate(deployment.Jobs, deployment.Networks)
+	if err != nil {
+		return Manifest{}, bosherr.WrapError(err, "Allocating static IPs")
+	}
+
+	err = NewInstanceIPAllocator().Allocate(deployment.Jobs, deployment.Networks)
+	if err != nil {
+		return Manifest{}, bosherr.WrapError(err, "Allocating instance IPs")
+	}
+
+	// Property values keep the types yaml.v2 resolved them to; BuildMap does not coerce
+	// scalars. yaml.v2 follows YAML 1.1, so unquoted 0123 is octal 83, 1.10 is the float 1.1
+	// and yes/no are booleans. Values that must stay strings have to be quoted.
 	properties, err := biproperty.BuildMap(depManifest.Properties)
 	if err != nil {
 		return Manifest{}, bosherr.WrapErrorf(err, "Parsing global manifest properties: %#v", depManifest.Properties)
@@ -188,175 +326,530 @@ func (p *parser) parseDeploymentManifest(depManifest manifest, path string) (Man
 			return Manifest{}, bosherr.WrapError(err, "Parsing update watch time")
 		}
 
-		deployment.Update = Update{
-			UpdateWatchTime: updateWatchTime,
+		deployment.Update.UpdateWatchTime = updateWatchTime
+		deployment.Update.CanaryWatchTime = updateWatchTime
+	}
+
+	if depManifest.Update.CanaryWatchTime != nil {
+		canaryWatchTime, err := NewWatchTime(*depManifest.Update.CanaryWatchTime)
+		if err != nil {
+			return Manifest{}, bosherr.WrapError(err, "Parsing canary watch time")
+		}
+
+		deployment.Update.CanaryWatchTime = canaryWatchTime
+	}
+
+	if depManifest.Update.Canaries != nil {
+		if *depManifest.Update.Canaries < 0 {
+			return Manifest{}, bosherr.Error("update.canaries must be >= 0")
 		}
+		deployment.Update.Canaries = *depManifest.Update.Canaries
 	}
 
+	if depManifest.Update.MaxInFlight != nil {
+		if *depManifest.Update.MaxInFlight < 1 {
+			return Manifest{}, bosherr.Error("update.max_in_flight must be > 0")
+		}
+		deployment.Update.MaxInFlight = *depManifest.Update.MaxInFlight
+	}
+
+	if depManifest.Update.Serial != nil {
+		deployment.Update.Serial = *depManifest.Update.Serial
+	}
+
+	scriptTimeouts, err := p.parseScriptTimeouts(depManifest.Update.ScriptTimeouts)
+	if err != nil {
+		return Manifest{}, err
+	}
+	deployment.Update.ScriptTimeouts = scriptTimeouts
+
+	agentWait, err := p.parseAgentWait(depManifest.Update)
+	if err != nil {
+		return Manifest{}, err
+	}
+	deployment.Update.AgentWait = agentWait
+
+	vmStrategy, err := p.parseVMStrategy(depManifest.Update)
+	if err != nil {
+		return Manifest{}, err
+	}
+	deployment.Update.VMStrategy = vmStrategy
+
 	return deployment, nil
 }
 
+func (p *parser) parseVMStrategy(update UpdateSpec) (VMStrategy, error) {
+	if update.VMStrategy == nil {
+		return boshDeploymentDefaults.Update.VMStrategy, nil
+	}
+
+	vmStrategy := VMStrategy(*update.VMStrategy)
+	if vmStrategy != VMStrategyDeleteCreate && vmStrategy != VMStrategyCreateSwapDelete {
+		return "", bosherr.Errorf("update.vm_strategy must be %s or %s ('%s' given)",
+			VMStrategyDeleteCreate, VMStrategyCreateSwapDelete, *update.VMStrategy)
+	}
+
+	return vmStrategy, nil
+}
+
+// parseScriptTimeouts parses the timeouts of lifecycle scripts given as
+// durations, e.g. `post-deploy: 10m`.
+func (p *parser) parseScriptTimeouts(rawTimeouts map[string]string) (map[string]time.Duration, error) {
+	if len(rawTimeouts) == 0 {
+		return nil, nil
+	}
+
+	timeouts := map[string]time.Duration{}
+
+	for script, rawTimeout := range rawTimeouts {
+		known := false
+		for _, lifecycleScript := range lifecycleScripts {
+			known = known || script == lifecycleScript
+		}
+		if !known {
+			return nil, bosherr.Errorf("update.script_timeouts.%s must be one of %s", script, strings.Join(lifecycleScripts, ", "))
+		}
+
+		timeout, err := time.ParseDuration(rawTimeout)
+		if err != nil || timeout <= 0 {
+			return nil, bosherr.Errorf("update.script_timeouts.%s must be a positive duration, e.g. 5m ('%s' given)", script, rawTimeout)
+		}
+
+		timeouts[script] = timeout
+	}
+
+	return timeouts, nil
+}
+
+// parseFeatures overrides the default features with the features section.
+func (p *parser) parseFeatures(rawFeatures FeaturesSpec) (Features, error) {
+	features := boshDeploymentDefaults.Features
+
+	if rawFeatures.UseDNSAddresses != nil {
+		features.UseDNSAddresses = *rawFeatures.UseDNSAddresses
+	}
+
+	if rawFeatures.DNSDomainName != nil {
+		if !dnsDomainNamePattern.MatchString(*rawFeatures.DNSDomainName) {
+			return Features{}, bosherr.Errorf("features.dns_domain_name must be a DNS domain name, e.g. bosh ('%s' given)", *rawFeatures.DNSDomainName)
+		}
+		features.DNSDomainName = *rawFeatures.DNSDomainName
+	}
+
+	return features, nil
+}
+
+// parseAgentWait overrides the default wait for the agent with the
+// agent_wait_timeout, agent_poll_interval and agent_max_errors update keys.
+func (p *parser) parseAgentWait(update UpdateSpec) (AgentWait, error) {
+	agentWait := boshDeploymentDefaults.Update.AgentWait
+
+	if update.AgentWaitTimeout != nil {
+		timeout, err := time.ParseDuration(*update.AgentWaitTimeout)
+		if err != nil || timeout <= 0 {
+			return AgentWait{}, bosherr.Errorf("update.agent_wait_timeout must be a positive duration, e.g. 10m ('%s' given)", *update.AgentWaitTimeout)
+		}
+		agentWait.Timeout = timeout
+	}
+
+	if update.AgentPollInterval != nil {
+		pollInterval, err := time.ParseDuration(*update.AgentPollInterval)
+		if err != nil || pollInterval <= 0 {
+			return AgentWait{}, bosherr.Errorf("update.agent_poll_interval must be a positive duration, e.g. 500ms ('%s' given)", *update.AgentPollInterval)
+		}
+		agentWait.PollInterval = pollInterval
+	}
+
+	if update.AgentMaxErrors != nil {
+		if *update.AgentMaxErrors < 0 {
+			return AgentWait{}, bosherr.Error("update.agent_max_errors must be >= 0")
+		}
+		agentWait.MaxErrors = *update.AgentMaxErrors
+	}
+
+	return agentWait, nil
+}
+
+// parseJobUpdate parses the watch times of the update section of a job. Each
+// watch time it does not give is taken from the update section of the
+// deployment, like the director merges the two sections.
+func (p *parser) parseJobUpdate(rawUpdate jobUpdate) (JobUpdate, error) {
+	jobUpdate := JobUpdate{}
+
+	if rawUpdate.UpdateWatchTime != nil {
+		updateWatchTime, err := NewWatchTime(*rawUpdate.UpdateWatchTime)
+		if err != nil {
+			return JobUpdate{}, bosherr.WrapError(err, "Parsing update watch time")
+		}
+
+		jobUpdate.UpdateWatchTime = &updateWatchTime
+	}
+
+	if rawUpdate.CanaryWatchTime != nil {
+		canaryWatchTime, err := NewWatchTime(*rawUpdate.CanaryWatchTime)
+		if err != nil {
+			return JobUpdate{}, bosherr.WrapError(err, "Parsing canary watch time")
+		}
+
+		jobUpdate.CanaryWatchTime = &canaryWatchTime
+	}
+
+	return jobUpdate, nil
+}
+
 func (p *parser) parseJobManifests(rawJobs []job) ([]Job, error) {
 	jobs := make([]Job, len(rawJobs), len(rawJobs))
 	for i, rawJob := range rawJobs {
-		job := Job{
-			Name:               rawJob.Name,
-			Instances:          rawJob.Instances,
-			Lifecycle:          JobLifecycle(rawJob.Lifecycle),
-			PersistentDisk:     rawJob.PersistentDisk,
-			PersistentDiskPool: rawJob.PersistentDiskPool,
-			ResourcePool:       rawJob.ResourcePool,
+		job, err := p.parseJobManifest(i, rawJob)
+		if err != nil {
+			return jobs, err
 		}
+		jobs[i] = job
+	}
 
-		if len(rawJob.Templates) > 0 && len(rawJob.Jobs) > 0 {
-			return jobs, bosherr.Error("Deployment specifies both templates and jobs keys for instance_group " + job.Name + ", only one is allowed")
-		}
+	return jobs, nil
+}
 
-		templates := rawJob.Templates
-		if len(rawJob.Jobs) > 0 {
-			templates = rawJob.Jobs
+func (p *parser) parseJobManifest(i int, rawJob job) (Job, error) {
+	instances, err := p.boundedInt(fmt.Sprintf("jobs[%d].instances", i), rawJob.Instances, MaxInstances)
+	if err != nil {
+		return Job{}, err
+	}
+
+	persistentDisk, err := p.boundedInt(fmt.Sprintf("jobs[%d].persistent_disk", i), rawJob.PersistentDisk, MaxDiskSize)
+	if err != nil {
+		return Job{}, err
+	}
+
+	job := Job{
+		Name:               rawJob.Name,
+		Instances:          instances,
+		AZs:                rawJob.AZs,
+		Lifecycle:          JobLifecycle(rawJob.Lifecycle),
+		PersistentDisk:     persistentDisk,
+		PersistentDiskPool: rawJob.PersistentDiskPool,
+		ResourcePool:       rawJob.ResourcePool,
+	}
+
+	if rawJob.Update != nil {
+		jobUpdate, err := p.parseJobUpdate(*rawJob.Update)
+		if err != nil {
+			return Job{}, bosherr.WrapErrorf(err, "Parsing update of job '%s'", rawJob.Name)
 		}
+		job.Update = jobUpdate
+	}
 
-		if templates != nil {
-			releaseJobRefs := make([]ReleaseJobRef, len(templates), len(templates))
-			for i, rawJobRef := range templates {
-				ref := ReleaseJobRef{
-					Name:    rawJobRef.Name,
-					Release: rawJobRef.Release,
-				}
+	for _, rawDisk := range rawJob.PersistentDisks {
+		job.PersistentDisks = append(job.PersistentDisks, JobPersistentDisk{
+			Name:     rawDisk.Name,
+			DiskPool: rawDisk.DiskPool,
+		})
+	}
 
-				if rawJobRef.Properties != nil {
-					properties, err := biproperty.BuildMap(*rawJobRef.Properties)
-					if err != nil {
-						return []Job{}, bosherr.WrapErrorf(err, "Parsing release job properties: %#v", rawJobRef.Properties)
-					}
+	if len(rawJob.Templates) > 0 && len(rawJob.Jobs) > 0 {
+		return Job{}, bosherr.Error("Deployment specifies both templates and jobs keys for instance_group " + job.Name + ", only one is allowed")
+	}
 
-					ref.Properties = &properties
-				}
+	templates := rawJob.Templates
+	if len(rawJob.Jobs) > 0 {
+		templates = rawJob.Jobs
+	}
 
-				releaseJobRefs[i] = ref
+	if templates != nil {
+		releaseJobRefs := make([]ReleaseJobRef, len(templates), len(templates))
+		for i, rawJobRef := range templates {
+			ref := ReleaseJobRef{
+				Name:    rawJobRef.Name,
+				Release: rawJobRef.Release,
 			}
-			job.Templates = releaseJobRefs
-		}
 
-		if rawJob.Networks != nil {
-			jobNetworks := make([]JobNetwork, len(rawJob.Networks), len(rawJob.Networks))
-			for i, rawJobNetwork := range rawJob.Networks {
-				jobNetwork := JobNetwork{
-					Name:      rawJobNetwork.Name,
-					StaticIPs: rawJobNetwork.StaticIPs,
+			if rawJobRef.Properties != nil {
+				properties, err := biproperty.BuildMap(*rawJobRef.Properties)
+				if err != nil {
+					return Job{}, bosherr.WrapErrorf(err, "Parsing release job properties: %#v", rawJobRef.Properties)
 				}
 
-				if rawJobNetwork.Defaults != nil {
-					networkDefaults := make([]NetworkDefault, len(rawJobNetwork.Defaults), len(rawJobNetwork.Defaults))
-					for i, rawDefaults := range rawJobNetwork.Defaults {
-						networkDefaults[i] = NetworkDefault(rawDefaults)
+				ref.Properties = &properties
+			}
+
+			for linkName, rawLinkRef := range rawJobRef.Consumes {
+				if rawLinkRef != nil && rawLinkRef.From != "" {
+					if ref.Consumes == nil {
+						ref.Consumes = map[string]string{}
 					}
-					jobNetwork.Defaults = networkDefaults
+					ref.Consumes[linkName] = rawLinkRef.From
 				}
+			}
 
-				jobNetworks[i] = jobNetwork
+			for linkName, rawLinkRef := range rawJobRef.Provides {
+				if rawLinkRef != nil && rawLinkRef.As != "" {
+					if ref.Provides == nil {
+						ref.Provides = map[string]string{}
+					}
+					ref.Provides[linkName] = rawLinkRef.As
+				}
 			}
-			job.Networks = jobNetworks
+
+			releaseJobRefs[i] = ref
 		}
+		job.Templates = releaseJobRefs
+	}
 
-		if rawJob.Properties != nil {
-			properties, err := biproperty.BuildMap(rawJob.Properties)
-			if err != nil {
-				return jobs, bosherr.WrapErrorf(err, "Parsing job '%s' properties: %#v", rawJob.Name, rawJob.Properties)
+	if rawJob.Networks != nil {
+		jobNetworks := make([]JobNetwork, len(rawJob.Networks), len(rawJob.Networks))
+		for i, rawJobNetwork := range rawJob.Networks {
+			jobNetwork := JobNetwork{
+				Name:          rawJobNetwork.Name,
+				StaticIPs:     rawJobNetwork.StaticIPs,
+				StaticIPCount: rawJobNetwork.StaticIPCount,
+			}
+
+			if rawJobNetwork.Defaults != nil {
+				networkDefaults := make([]NetworkDefault, len(rawJobNetwork.Defaults), len(rawJobNetwork.Defaults))
+				for i, rawDefaults := range rawJobNetwork.Defaults {
+					networkDefaults[i] = NetworkDefault(rawDefaults)
+				}
+				jobNetwork.Defaults = networkDefaults
 			}
-			job.Properties = properties
+
+			jobNetworks[i] = jobNetwork
 		}
+		job.Networks = jobNetworks
+	}
 
-		jobs[i] = job
+	if rawJob.Properties != nil {
+		properties, err := biproperty.BuildMap(rawJob.Properties)
+		if err != nil {
+			return Job{}, bosherr.WrapErrorf(err, "Parsing job '%s' properties: %#v", rawJob.Name, rawJob.Properties)
+		}
+		job.Properties = properties
 	}
 
-	return jobs, nil
+	return job, nil
 }
 
 func (p *parser) parseNetworkManifests(rawNetworks []network) ([]Network, error) {
 	networks := make([]Network, len(rawNetworks), len(rawNetworks))
 	for i, rawNetwork := range rawNetworks {
-		network := Network{
-			Name: rawNetwork.Name,
-			Type: NetworkType(rawNetwork.Type),
-			DNS:  rawNetwork.DNS,
+		network, err := p.parseNetworkManifest(rawNetwork)
+		if err != nil {
+			return networks, err
 		}
+		networks[i] = network
+	}
 
-		cloudProperties, err := biproperty.BuildMap(rawNetwork.CloudProperties)
+	return networks, nil
+}
+
+func (p *parser) parseNetworkManifest(rawNetwork network) (Network, error) {
+	network := Network{
+		Name:    rawNetwork.Name,
+		Type:    NetworkType(rawNetwork.Type),
+		DNS:     rawNetwork.DNS,
+		IP:      rawNetwork.IP,
+		Netmask: rawNetwork.Netmask,
+		Gateway: rawNetwork.Gateway,
+	}
+
+	cloudProperties, err := biproperty.BuildMap(rawNetwork.CloudProperties)
+	if err != nil {
+		return Network{}, bosherr.WrapErrorf(err, "Parsing network '%s' cloud_properties: %#v", rawNetwork.Name, rawNetwork.CloudProperties)
+	}
+	network.CloudProperties = cloudProperties
+
+	for _, subnet := range rawNetwork.Subnets {
+		cloudProperties, err := biproperty.BuildMap(subnet.CloudProperties)
 		if err != nil {
-			return networks, bosherr.WrapErrorf(err, "Parsing network '%s' cloud_properties: %#v", rawNetwork.Name, rawNetwork.CloudProperties)
+			return Network{}, bosherr.WrapErrorf(err, "Parsing network subnet '%s' cloud_properties: %#v", rawNetwork.Name, subnet.CloudProperties)
 		}
-		network.CloudProperties = cloudProperties
 
-		for _, subnet := range rawNetwork.Subnets {
-			cloudProperties, err := biproperty.BuildMap(subnet.CloudProperties)
+		gateway := subnet.Gateway
+		if gateway == "" && p.opts.DefaultGatewayFromCIDR && network.Type == Manual {
+			gateway, err = p.defaultGateway(subnet.Range)
 			if err != nil {
-				return networks, bosherr.WrapErrorf(err, "Parsing network subnet '%s' cloud_properties: %#v", rawNetwork.Name, subnet.CloudProperties)
+				return Network{}, bosherr.WrapErrorf(err, "Defaulting gateway for network '%s'", rawNetwork.Name)
 			}
+		}
+
+		network.Subnets = append(network.Subnets, Subnet{
+			Range:           subnet.Range,
+			Gateway:         gateway,
+			DNS:             subnet.DNS,
+			Static:          subnet.Static,
+			Reserved:        subnet.Reserved,
+			AZs:             subnet.azNames(),
+			CloudProperties: cloudProperties,
+		})
+	}
 
-			network.Subnets = append(network.Subnets, Subnet{
-				Range:           subnet.Range,
-				Gateway:         subnet.Gateway,
-				DNS:             subnet.DNS,
-				CloudProperties: cloudProperties,
-			})
+	if network.Type == Manual && len(rawNetwork.Subnets) == 0 {
+		flatSubnet, found := p.flatManualSubnet(network)
+		if found {
+			network.Subnets = []Subnet{flatSubnet}
 		}
+	}
 
-		networks[i] = network
+	return network, nil
+}
+
+// flatManualSubnet converts a manual network declared with top-level netmask/gateway into a single subnet.
+// When gateway defaulting is enabled the subnet may instead be derived from the network's ip.
+// Networks whose netmask or gateway are missing or invalid are left without subnets for the validator to report.
+func (p *parser) flatManualSubnet(network Network) (Subnet, bool) {
+	address := network.Gateway
+	if address == "" && p.opts.DefaultGatewayFromCIDR {
+		address = network.IP
 	}
 
-	return networks, nil
+	ipNet, err := flatSubnetRange(network.Netmask, address)
+	if err != nil {
+		return Subnet{}, false
+	}
+
+	gateway := network.Gateway
+	if gateway == "" {
+		gateway = binet.NextAddress(ipNet.IP).String()
+	}
+
+	return Subnet{
+		Range:           ipNet.String(),
+		Gateway:         gateway,
+		DNS:             network.DNS,
+		CloudProperties: network.CloudProperties,
+	}, true
+}
+
+// defaultGateway returns the conventional gateway of a subnet, its first host address.
+func (p *parser) defaultGateway(subnetRange string) (string, error) {
+	_, ipNet, err := net.ParseCIDR(subnetRange)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Parsing subnet range '%s'", subnetRange)
+	}
+
+	return binet.NextAddress(ipNet.IP).String(), nil
 }
 
 func (p *parser) parseResourcePoolManifests(rawResourcePools []resourcePool, path string) ([]ResourcePool, error) {
 	resourcePools := make([]ResourcePool, len(rawResourcePools), len(rawResourcePools))
 	for i, rawResourcePool := range rawResourcePools {
-		resourcePool := ResourcePool{
-			Name:     rawResourcePool.Name,
-			Network:  rawResourcePool.Network,
-			Stemcell: StemcellRef(rawResourcePool.Stemcell),
-		}
-
-		cloudProperties, err := biproperty.BuildMap(rawResourcePool.CloudProperties)
+		resourcePool, err := p.parseResourcePoolManifest(rawResourcePool, path)
 		if err != nil {
-			return resourcePools, bosherr.WrapErrorf(err, "Parsing resource_pool '%s' cloud_properties: %#v", rawResourcePool.Name, rawResourcePool.CloudProperties)
+			return resourcePools, err
 		}
-		resourcePool.CloudProperties = cloudProperties
+		resourcePools[i] = resourcePool
+	}
+
+	return resourcePools, nil
+}
+
+func (p *parser) parseResourcePoolManifest(rawResourcePool resourcePool, path string) (ResourcePool, error) {
+	resourcePool := ResourcePool{
+		Name:    rawResourcePool.Name,
+		Network: rawResourcePool.Network,
+		CPI:     rawResourcePool.CPI,
+		Stemcell: StemcellRef{
+			URL:       rawResourcePool.Stemcell.URL,
+			SHA1:      rawResourcePool.Stemcell.SHA1,
+			Signature: rawResourcePool.Stemcell.Signature,
+		},
+	}
+
+	cloudProperties, err := biproperty.BuildMap(rawResourcePool.CloudProperties)
+	if err != nil {
+		return ResourcePool{}, bosherr.WrapErrorf(err, "Parsing resource_pool '%s' cloud_properties: %#v", rawResourcePool.Name, rawResourcePool.CloudProperties)
+	}
+	resourcePool.CloudProperties = cloudProperties
+
+	env, err := biproperty.BuildMap(rawResourcePool.Env)
+	if err != nil {
+		return ResourcePool{}, bosherr.WrapErrorf(err, "Parsing resource_pool '%s' env: %#v", rawResourcePool.Name, rawResourcePool.Env)
+	}
+	resourcePool.Env = env
+
+	resourcePool.Stemcell.URL, err = biutil.AbsolutifyPath(path, resourcePool.Stemcell.URL, p.fs)
+	if err != nil {
+		return ResourcePool{}, bosherr.WrapErrorf(err, "Resolving stemcell path '%s", resourcePool.Stemcell.URL)
+	}
 
-		env, err := biproperty.BuildMap(rawResourcePool.Env)
+	if resourcePool.Stemcell.Signature.URL != "" {
+		resourcePool.Stemcell.Signature.URL, err = biutil.AbsolutifyPath(path, resourcePool.Stemcell.Signature.URL, p.fs)
 		if err != nil {
-			return resourcePools, bosherr.WrapErrorf(err, "Parsing resource_pool '%s' env: %#v", rawResourcePool.Name, rawResourcePool.Env)
+			return ResourcePool{}, bosherr.WrapErrorf(err, "Resolving stemcell signature path '%s", resourcePool.Stemcell.Signature.URL)
 		}
-		resourcePool.Env = env
+	}
 
-		resourcePool.Stemcell.URL, err = biutil.AbsolutifyPath(path, resourcePool.Stemcell.URL, p.fs)
-		if err != nil {
-			return resourcePools, bosherr.WrapErrorf(err, "Resolving stemcell path '%s", resourcePool.Stemcell.URL)
+	return resourcePool, nil
+}
+
+// parseDiskPoolManifests accepts both the legacy disk_pools and the newer
+// disk_types sections, which describe the same thing.
+func (p *parser) parseDiskPoolManifests(rawDiskPools []diskPool, rawDiskTypes []diskPool) ([]DiskPool, error) {
+	if len(rawDiskPools) > 0 {
+		legacyNames := []string{}
+		for _, rawDiskPool := range rawDiskPools {
+			legacyNames = append(legacyNames, rawDiskPool.Name)
 		}
+		p.logger.Warn(p.logTag, "Deprecated: disk_pools %v should be declared under disk_types", legacyNames)
+	}
 
-		resourcePools[i] = resourcePool
+	diskPoolNames := map[string]bool{}
+	for _, rawDiskPool := range rawDiskPools {
+		diskPoolNames[rawDiskPool.Name] = true
 	}
 
-	return resourcePools, nil
-}
+	for _, rawDiskType := range rawDiskTypes {
+		if diskPoolNames[rawDiskType.Name] {
+			return []DiskPool{}, bosherr.Errorf("Disk '%s' is declared in both disk_pools and disk_types", rawDiskType.Name)
+		}
+	}
+
+	diskPools := make([]DiskPool, 0, len(rawDiskPools)+len(rawDiskTypes))
 
-func (p *parser) parseDiskPoolManifests(rawDiskPools []diskPool) ([]DiskPool, error) {
-	diskPools := make([]DiskPool, len(rawDiskPools), len(rawDiskPools))
 	for i, rawDiskPool := range rawDiskPools {
-		diskPool := DiskPool{
-			Name:     rawDiskPool.Name,
-			DiskSize: rawDiskPool.DiskSize,
+		diskPool, err := p.parseDiskPoolManifest(fmt.Sprintf("disk_pools[%d]", i), rawDiskPool)
+		if err != nil {
+			return diskPools, err
 		}
+		diskPools = append(diskPools, diskPool)
+	}
 
-		cloudProperties, err := biproperty.BuildMap(rawDiskPool.CloudProperties)
+	for i, rawDiskType := range rawDiskTypes {
+		diskPool, err := p.parseDiskPoolManifest(fmt.Sprintf("disk_types[%d]", i), rawDiskType)
 		if err != nil {
-			return diskPools, bosherr.WrapErrorf(err, "Parsing disk_pool '%s' cloud_properties: %#v", rawDiskPool.Name, rawDiskPool.CloudProperties)
+			return diskPools, err
 		}
-		diskPool.CloudProperties = cloudProperties
-
-		diskPools[i] = diskPool
+		diskPools = append(diskPools, diskPool)
 	}
 
 	return diskPools, nil
 }
+
+func (p *parser) parseDiskPoolManifest(field string, rawDiskPool diskPool) (DiskPool, error) {
+	diskSize, err := p.boundedInt(field+".disk_size", rawDiskPool.DiskSize, MaxDiskSize)
+	if err != nil {
+		return DiskPool{}, err
+	}
+
+	diskPool := DiskPool{
+		Name:     rawDiskPool.Name,
+		DiskSize: diskSize,
+	}
+
+	cloudProperties, err := biproperty.BuildMap(rawDiskPool.CloudProperties)
+	if err != nil {
+		return DiskPool{}, bosherr.WrapErrorf(err, "Parsing disk_pool '%s' cloud_properties: %#v", rawDiskPool.Name, rawDiskPool.CloudProperties)
+	}
+	diskPool.CloudProperties = cloudProperties
+
+	return diskPool, nil
+}
+
+// boundedInt converts a parsed numeric field to int, erroring when it is not below max
+// so that typos (e.g. extra zeros) and values that would overflow int are caught early.
+func (p *parser) boundedInt(field string, value int64, max int64) (int, error) {
+	if value >= max {
+		return 0, bosherr.Errorf("%s value %d must be less than %d", field, value, max)
+	}
+
+	return int(value), nil
+}
@@ -1,11 +1,16 @@
 package manifest_test
 
 import (
+	"bytes"
+	"strings"
+	"time"
+
 	. "github.com/cloudfoundry/bosh-cli/deployment/manifest"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	bidepltpl "github.com/cloudfoundry/bosh-cli/deployment/template"
+	birelmanifest "github.com/cloudfoundry/bosh-cli/release/manifest"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	biproperty "github.com/cloudfoundry/bosh-utils/property"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
@@ -47,6 +52,7 @@ resource_pools:
       password: secret
   stemcell:
     url: http://fake-stemcell-url
+  cpi: fake-cpi-name
 networks:
 - name: fake-network-name
   type: dynamic
@@ -99,7 +105,17 @@ properties:
 						Start: 2000,
 						End:   7000,
 					},
+					CanaryWatchTime: WatchTime{
+						Start: 2000,
+						End:   7000,
+					},
+					Canaries:    1,
+					MaxInFlight: 1,
+					Serial:      true,
+					AgentWait:   AgentWait{Timeout: 10 * time.Minute, PollInterval: 500 * time.Millisecond},
+					VMStrategy:  VMStrategyDeleteCreate,
 				},
+				Features: Features{DNSDomainName: "bosh"},
 				Networks: []Network{
 					{
 						Name: "fake-network-name",
@@ -142,6 +158,7 @@ properties:
 						Stemcell: StemcellRef{
 							URL: "http://fake-stemcell-url",
 						},
+						CPI: "fake-cpi-name",
 					},
 				},
 				DiskPools: []DiskPool{
@@ -225,7 +242,14 @@ resource_pools:
 					},
 					Update: Update{
 						UpdateWatchTime: WatchTime{Start: 0, End: 300000},
+						CanaryWatchTime: WatchTime{Start: 0, End: 300000},
+						Canaries:        1,
+						MaxInFlight:     1,
+						Serial:          true,
+						AgentWait:       AgentWait{Timeout: 10 * time.Minute, PollInterval: 500 * time.Millisecond},
+						VMStrategy:      VMStrategyDeleteCreate,
 					},
+					Features: Features{DNSDomainName: "bosh"},
 				}))
 			})
 		})
@@ -268,7 +292,14 @@ resource_pools:
 						},
 						Update: Update{
 							UpdateWatchTime: WatchTime{Start: 0, End: 300000},
+							CanaryWatchTime: WatchTime{Start: 0, End: 300000},
+							Canaries:        1,
+							MaxInFlight:     1,
+							Serial:          true,
+							AgentWait:       AgentWait{Timeout: 10 * time.Minute, PollInterval: 500 * time.Millisecond},
+							VMStrategy:      VMStrategyDeleteCreate,
 						},
+						Features: Features{DNSDomainName: "bosh"},
 					}))
 				})
 			})
@@ -311,7 +342,14 @@ resource_pools:
 						},
 						Update: Update{
 							UpdateWatchTime: WatchTime{Start: 0, End: 300000},
+							CanaryWatchTime: WatchTime{Start: 0, End: 300000},
+							Canaries:        1,
+							MaxInFlight:     1,
+							Serial:          true,
+							AgentWait:       AgentWait{Timeout: 10 * time.Minute, PollInterval: 500 * time.Millisecond},
+							VMStrategy:      VMStrategyDeleteCreate,
 						},
+						Features: Features{DNSDomainName: "bosh"},
 					}))
 				})
 			})
@@ -355,16 +393,121 @@ resource_pools:
 						},
 						Update: Update{
 							UpdateWatchTime: WatchTime{Start: 0, End: 300000},
+							CanaryWatchTime: WatchTime{Start: 0, End: 300000},
+							Canaries:        1,
+							MaxInFlight:     1,
+							Serial:          true,
+							AgentWait:       AgentWait{Timeout: 10 * time.Minute, PollInterval: 500 * time.Millisecond},
+							VMStrategy:      VMStrategyDeleteCreate,
 						},
+						Features: Features{DNSDomainName: "bosh"},
 					}))
 				})
 			})
+
+			Context("with a signature", func() {
+				BeforeEach(func() {
+					manifestPath = "/path/to/fake-deployment-yml"
+					contents := `
+---
+name: fake-deployment-manifest
+
+resource_pools:
+- name: fake-resource-pool-name
+  stemcell:
+    url: https://fake-stemcell-url
+    sha1: sha256:fake-stemcell-sha256
+    signature:
+      url: file://fake-relative-signature-path
+      public_key: fake-public-key
+`
+					interpolatedTemplate = bidepltpl.NewInterpolatedTemplate([]byte(contents), "fake-sha")
+				})
+
+				It("parses the signature and expands its path to be relative to the manifest path", func() {
+					deploymentManifest, err := parser.Parse(interpolatedTemplate, manifestPath)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(deploymentManifest.ResourcePools[0].Stemcell).To(Equal(StemcellRef{
+						URL:  "https://fake-stemcell-url",
+						SHA1: "sha256:fake-stemcell-sha256",
+						Signature: birelmanifest.SignatureRef{
+							URL:       "file:///path/to/fake-relative-signature-path",
+							PublicKey: "fake-public-key",
+						},
+					}))
+				})
+			})
+		})
+
+		Context("when properties contain typed scalars", func() {
+			BeforeEach(func() {
+				contents := `
+---
+name: fake-deployment-name
+properties:
+  db:
+    port: 5432
+    ratio: 0.5
+    ssl: true
+    version: "0123"
+    release: "1.10"
+    octal: 0123
+    enabled: yes
+  servers:
+  - host: a
+    port: 80
+  - host: b
+    tags: [1, "2"]
+`
+				interpolatedTemplate = bidepltpl.NewInterpolatedTemplate([]byte(contents), "fake-sha")
+			})
+
+			It("preserves the YAML scalar types in nested maps and lists", func() {
+				deploymentManifest, err := parser.Parse(interpolatedTemplate, manifestPath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentManifest.Properties).To(Equal(biproperty.Map{
+					"db": biproperty.Map{
+						"port":    5432,
+						"ratio":   0.5,
+						"ssl":     true,
+						"version": "0123",
+						"release": "1.10",
+						"octal":   83,
+						"enabled": true,
+					},
+					"servers": biproperty.List{
+						biproperty.Map{"host": "a", "port": 80},
+						biproperty.Map{"host": "b", "tags": biproperty.List{1, "2"}},
+					},
+				}))
+			})
+		})
+
+		Context("when the manifest does not match the schema", func() {
+			BeforeEach(func() {
+				contents := `
+---
+name: fake-deployment-name
+resource_pools:
+- name: fake-resource-pool
+  unknown_key: fake-value
+`
+				interpolatedTemplate = bidepltpl.NewInterpolatedTemplate([]byte(contents), "fake-sha")
+			})
+
+			It("returns an error", func() {
+				_, err := parser.Parse(interpolatedTemplate, manifestPath)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Validating BOSH deployment manifest schema"))
+				Expect(err.Error()).To(ContainSubstring("line 6, column 3: resource_pools[0].unknown_key is not a known key"))
+			})
 		})
 
 		Context("when global property keys are not strings", func() {
 			BeforeEach(func() {
 				contents := `
 ---
+name: fake-deployment-name
 properties:
   1: foo
 `
@@ -382,6 +525,7 @@ properties:
 			BeforeEach(func() {
 				contents := `
 ---
+name: fake-deployment-name
 jobs:
 - name: fake-deployment-job
   properties:
@@ -401,6 +545,7 @@ jobs:
 			BeforeEach(func() {
 				contents := `
 ---
+name: fake-deployment-name
 resource_pools:
 - name: fake-resource-pool
   cloud_properties:
@@ -419,8 +564,10 @@ resource_pools:
 			BeforeEach(func() {
 				contents := `
 ---
+name: fake-deployment-name
 disk_pools:
 - name: fake-disk-pool
+  disk_size: 1024
   cloud_properties:
     123: fake-property-value
 `
@@ -450,6 +597,237 @@ name: fake-deployment-name
 				Expect(deploymentManifest.Name).To(Equal("fake-deployment-name"))
 				Expect(deploymentManifest.Update.UpdateWatchTime.Start).To(Equal(0))
 				Expect(deploymentManifest.Update.UpdateWatchTime.End).To(Equal(300000))
+				Expect(deploymentManifest.Update.Canaries).To(Equal(1))
+				Expect(deploymentManifest.Update.MaxInFlight).To(Equal(1))
+				Expect(deploymentManifest.Update.Serial).To(BeTrue())
+			})
+		})
+
+		Context("when canaries, max_in_flight and serial are set", func() {
+			parse := func(contents string) (Manifest, error) {
+				return parser.Parse(bidepltpl.NewInterpolatedTemplate([]byte(contents), "fake-sha"), manifestPath)
+			}
+
+			It("parses them", func() {
+				deploymentManifest, err := parse(`
+---
+name: fake-deployment-name
+update:
+  canaries: 0
+  max_in_flight: 3
+  serial: false
+`)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentManifest.Update.Canaries).To(Equal(0))
+				Expect(deploymentManifest.Update.MaxInFlight).To(Equal(3))
+				Expect(deploymentManifest.Update.Serial).To(BeFalse())
+			})
+
+			It("returns an error when canaries is negative", func() {
+				_, err := parse(`
+---
+name: fake-deployment-name
+update:
+  canaries: -1
+`)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("update.canaries must be >= 0"))
+			})
+
+			It("returns an error when max_in_flight is not positive", func() {
+				_, err := parse(`
+---
+name: fake-deployment-name
+update:
+  max_in_flight: 0
+`)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("update.max_in_flight must be > 0"))
+			})
+
+			It("parses the canary watch time", func() {
+				deploymentManifest, err := parse(`
+---
+name: fake-deployment-name
+update:
+  update_watch_time: 1000-5000
+  canary_watch_time: 2000-9000
+`)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentManifest.Update.UpdateWatchTime).To(Equal(WatchTime{Start: 1000, End: 5000}))
+				Expect(deploymentManifest.Update.CanaryWatchTime).To(Equal(WatchTime{Start: 2000, End: 9000}))
+			})
+
+			It("watches canaries for the update watch time when the canary watch time is not set", func() {
+				deploymentManifest, err := parse(`
+---
+name: fake-deployment-name
+update:
+  update_watch_time: 1000-5000
+`)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentManifest.Update.CanaryWatchTime).To(Equal(WatchTime{Start: 1000, End: 5000}))
+			})
+
+			It("parses the watch times of jobs", func() {
+				deploymentManifest, err := parse(`
+---
+name: fake-deployment-name
+jobs:
+- name: fake-job-name
+  update:
+    update_watch_time: 1000-5000
+- name: other-job-name
+  update:
+    canary_watch_time: 2000-9000
+`)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentManifest.Jobs[0].Update).To(Equal(JobUpdate{
+					UpdateWatchTime: &WatchTime{Start: 1000, End: 5000},
+				}))
+				Expect(deploymentManifest.Jobs[1].Update).To(Equal(JobUpdate{
+					CanaryWatchTime: &WatchTime{Start: 2000, End: 9000},
+				}))
+			})
+
+			It("returns an error when the watch time of a job is not a range", func() {
+				_, err := parse(`
+---
+name: fake-deployment-name
+jobs:
+- name: fake-job-name
+  update:
+    canary_watch_time: 5000
+`)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Parsing update of job 'fake-job-name': Parsing canary watch time: Invalid watch time range '5000'"))
+			})
+
+			It("parses the script timeouts", func() {
+				deploymentManifest, err := parse(`
+---
+name: fake-deployment-name
+update:
+  script_timeouts:
+    pre-start: 90s
+    post-deploy: 10m
+`)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentManifest.Update.ScriptTimeouts).To(Equal(map[string]time.Duration{
+					"pre-start":   90 * time.Second,
+					"post-deploy": 10 * time.Minute,
+				}))
+			})
+
+			It("returns an error when a script timeout is not a duration", func() {
+				_, err := parse(`
+---
+name: fake-deployment-name
+update:
+  script_timeouts:
+    post-start: 300
+`)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("update.script_timeouts.post-start must be a positive duration, e.g. 5m ('300' given)"))
+			})
+
+			It("returns an error when a script timeout is for an unknown script", func() {
+				_, err := parse(`
+---
+name: fake-deployment-name
+update:
+  script_timeouts:
+    pre-stop: 5m
+`)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("update.script_timeouts.pre-stop must be one of pre-start, post-start, post-deploy"))
+			})
+
+			It("parses the agent wait", func() {
+				deploymentManifest, err := parse(`
+---
+name: fake-deployment-name
+update:
+  agent_wait_timeout: 20m
+  agent_poll_interval: 2s
+  agent_max_errors: 30
+`)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentManifest.Update.AgentWait).To(Equal(AgentWait{
+					Timeout:      20 * time.Minute,
+					PollInterval: 2 * time.Second,
+					MaxErrors:    30,
+				}))
+			})
+
+			It("returns an error when the agent wait timeout is not a duration", func() {
+				_, err := parse(`
+---
+name: fake-deployment-name
+update:
+  agent_wait_timeout: 600
+`)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("update.agent_wait_timeout must be a positive duration, e.g. 10m ('600' given)"))
+			})
+
+			It("returns an error when agent_max_errors is negative", func() {
+				_, err := parse(`
+---
+name: fake-deployment-name
+update:
+  agent_max_errors: -1
+`)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("update.agent_max_errors must be >= 0"))
+			})
+
+			It("parses the VM strategy", func() {
+				deploymentManifest, err := parse(`
+---
+name: fake-deployment-name
+update:
+  vm_strategy: create-swap-delete
+`)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentManifest.Update.VMStrategy).To(Equal(VMStrategyCreateSwapDelete))
+			})
+
+			It("returns an error when the VM strategy is unknown", func() {
+				_, err := parse(`
+---
+name: fake-deployment-name
+update:
+  vm_strategy: swap
+`)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("update.vm_strategy must be delete-create or create-swap-delete ('swap' given)"))
+			})
+
+			It("parses the features", func() {
+				deploymentManifest, err := parse(`
+---
+name: fake-deployment-name
+features:
+  use_dns_addresses: true
+  dns_domain_name: internal.example.com
+`)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentManifest.Features).To(Equal(Features{
+					UseDNSAddresses: true,
+					DNSDomainName:   "internal.example.com",
+				}))
+			})
+
+			It("returns an error when the DNS domain name is not a domain name", func() {
+				_, err := parse(`
+---
+name: fake-deployment-name
+features:
+  dns_domain_name: my_domain
+`)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("features.dns_domain_name must be a DNS domain name, e.g. bosh ('my_domain' given)"))
 			})
 		})
 
@@ -457,6 +835,7 @@ name: fake-deployment-name
 			BeforeEach(func() {
 				contents := `
 ---
+name: fake-deployment-name
 instance_groups:
 - name: jobby
 `
@@ -469,10 +848,360 @@ instance_groups:
 				Expect(deploymentManifest.Jobs[0].Name).To(Equal("jobby"))
 			})
 		})
+		Context("when numeric fields are out of bounds", func() {
+			parse := func(contents string) (Manifest, error) {
+				return parser.Parse(bidepltpl.NewInterpolatedTemplate([]byte(contents), "fake-sha"), manifestPath)
+			}
+
+			It("accepts instances just below the maximum", func() {
+				deploymentManifest, err := parse(`
+---
+name: fake-deployment-name
+jobs:
+- name: fake-job-name
+  instances: 9999
+`)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentManifest.Jobs[0].Instances).To(Equal(9999))
+			})
+
+			It("returns an error when instances reaches the maximum", func() {
+				_, err := parse(`
+---
+name: fake-deployment-name
+jobs:
+- name: fake-job-name
+  instances: 10000
+`)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("jobs[0].instances value 10000 must be less than 10000"))
+			})
+
+			It("accepts disk sizes just below the maximum", func() {
+				deploymentManifest, err := parse(`
+---
+name: fake-deployment-name
+disk_pools:
+- name: fake-disk-pool-name
+  disk_size: 1073741823
+jobs:
+- name: fake-job-name
+  persistent_disk: 1073741823
+`)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentManifest.DiskPools[0].DiskSize).To(Equal(1073741823))
+				Expect(deploymentManifest.Jobs[0].PersistentDisk).To(Equal(1073741823))
+			})
+
+			It("returns an error when disk_size reaches the maximum", func() {
+				_, err := parse(`
+---
+name: fake-deployment-name
+disk_pools:
+- name: fake-disk-pool-name
+  disk_size: 1073741824
+`)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("disk_pools[0].disk_size value 1073741824 must be less than 1073741824"))
+			})
+
+			It("returns an error when persistent_disk reaches the maximum", func() {
+				_, err := parse(`
+---
+name: fake-deployment-name
+jobs:
+- name: fake-job-name
+  persistent_disk: 10240000000000
+`)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("jobs[0].persistent_disk value 10240000000000 must be less than 1073741824"))
+			})
+
+			It("returns an error when the value does not fit in an integer", func() {
+				_, err := parse(`
+---
+name: fake-deployment-name
+disk_pools:
+- name: fake-disk-pool-name
+  disk_size: 100000000000000000000000
+`)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Unmarshalling BOSH deployment manifest"))
+			})
+		})
+
+		Context("when declaring disk_types", func() {
+			var (
+				logBuffer *bytes.Buffer
+			)
+
+			BeforeEach(func() {
+				logBuffer = bytes.NewBufferString("")
+				parser = NewParser(fakeFs, boshlog.NewWriterLogger(boshlog.LevelWarn, logBuffer, logBuffer))
+			})
+
+			It("parses disk_types the same way as disk_pools without warning", func() {
+				interpolatedTemplate = bidepltpl.NewInterpolatedTemplate([]byte(`
+---
+name: fake-deployment-name
+disk_types:
+- name: fake-disk-type-name
+  disk_size: 2048
+  cloud_properties: {type: gp2}
+`), "fake-sha")
+
+				deploymentManifest, err := parser.Parse(interpolatedTemplate, manifestPath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentManifest.DiskPools).To(Equal([]DiskPool{
+					{
+						Name:            "fake-disk-type-name",
+						DiskSize:        2048,
+						CloudProperties: biproperty.Map{"type": "gp2"},
+					},
+				}))
+				Expect(logBuffer.String()).To(BeEmpty())
+			})
+
+			It("warns once naming every legacy disk pool", func() {
+				interpolatedTemplate = bidepltpl.NewInterpolatedTemplate([]byte(`
+---
+name: fake-deployment-name
+disk_pools:
+- name: fake-disk-pool-1
+  disk_size: 1024
+- name: fake-disk-pool-2
+  disk_size: 1024
+disk_types:
+- name: fake-disk-type-name
+  disk_size: 2048
+`), "fake-sha")
+
+				deploymentManifest, err := parser.Parse(interpolatedTemplate, manifestPath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentManifest.DiskPools).To(HaveLen(3))
+				Expect(strings.Count(logBuffer.String(), "Deprecated")).To(Equal(1))
+				Expect(logBuffer.String()).To(ContainSubstring("Deprecated: disk_pools [fake-disk-pool-1 fake-disk-pool-2] should be declared under disk_types"))
+			})
+
+			It("returns an error when a name is declared in both disk_pools and disk_types", func() {
+				interpolatedTemplate = bidepltpl.NewInterpolatedTemplate([]byte(`
+---
+name: fake-deployment-name
+disk_pools:
+- name: fake-disk-name
+  disk_size: 1024
+disk_types:
+- name: fake-disk-name
+  disk_size: 2048
+`), "fake-sha")
+
+				_, err := parser.Parse(interpolatedTemplate, manifestPath)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Disk 'fake-disk-name' is declared in both disk_pools and disk_types"))
+			})
+		})
+
+		Context("when defaulting gateways from CIDR", func() {
+			var networkManifest string
+
+			BeforeEach(func() {
+				networkManifest = `
+---
+name: fake-deployment-name
+networks:
+- name: fake-flat-network
+  type: manual
+  ip: 10.0.1.17
+  netmask: 255.255.255.0
+- name: fake-subnet-network
+  type: manual
+  subnets:
+  - range: 10.0.2.0/24
+- name: fake-explicit-network
+  type: manual
+  subnets:
+  - range: 10.0.3.0/24
+    gateway: 10.0.3.254
+`
+			})
+
+			It("does not default gateways by default", func() {
+				interpolatedTemplate = bidepltpl.NewInterpolatedTemplate([]byte(networkManifest), "fake-sha")
+
+				deploymentManifest, err := parser.Parse(interpolatedTemplate, manifestPath)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(deploymentManifest.Networks[0].Subnets).To(BeEmpty())
+				Expect(deploymentManifest.Networks[1].Subnets[0].Gateway).To(BeEmpty())
+			})
+
+			It("uses the first host of the subnet when enabled", func() {
+				parser = NewParserWithOpts(fakeFs, boshlog.NewLogger(boshlog.LevelNone), ParserOpts{DefaultGatewayFromCIDR: true})
+				interpolatedTemplate = bidepltpl.NewInterpolatedTemplate([]byte(networkManifest), "fake-sha")
+
+				deploymentManifest, err := parser.Parse(interpolatedTemplate, manifestPath)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(deploymentManifest.Networks[0].Subnets[0].Range).To(Equal("10.0.1.0/24"))
+				Expect(deploymentManifest.Networks[0].Subnets[0].Gateway).To(Equal("10.0.1.1"))
+				Expect(deploymentManifest.Networks[1].Subnets[0].Gateway).To(Equal("10.0.2.1"))
+				Expect(deploymentManifest.Networks[2].Subnets[0].Gateway).To(Equal("10.0.3.254"))
+			})
+		})
+
+		Context("when parsing networks of each type", func() {
+			It("leaves a manual network with an invalid netmask and gateway without subnets for the validator", func() {
+				interpolatedTemplate = bidepltpl.NewInterpolatedTemplate([]byte(`
+---
+name: fake-deployment-name
+networks:
+- name: fake-network-name
+  type: manual
+  netmask: 255.255.255.0
+  gateway: "2001:db8:1::1"
+`), "fake-sha")
+
+				deploymentManifest, err := parser.Parse(interpolatedTemplate, manifestPath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentManifest.Networks[0].Subnets).To(BeEmpty())
+				Expect(deploymentManifest.Networks[0].Netmask).To(Equal("255.255.255.0"))
+				Expect(deploymentManifest.Networks[0].Gateway).To(Equal("2001:db8:1::1"))
+			})
+
+			It("converts a manual network with top-level netmask and gateway into a subnet", func() {
+				interpolatedTemplate = bidepltpl.NewInterpolatedTemplate([]byte(`
+---
+name: fake-deployment-name
+networks:
+- name: fake-network-name
+  type: manual
+  netmask: 255.255.255.0
+  gateway: 10.0.0.1
+  dns: [8.8.8.8]
+`), "fake-sha")
+
+				deploymentManifest, err := parser.Parse(interpolatedTemplate, manifestPath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentManifest.Networks[0].Subnets).To(Equal([]Subnet{
+					{
+						Range:           "10.0.0.0/24",
+						Gateway:         "10.0.0.1",
+						DNS:             []string{"8.8.8.8"},
+						CloudProperties: biproperty.Map{},
+					},
+				}))
+			})
+
+			It("parses the reserved ranges and azs of every subnet", func() {
+				interpolatedTemplate = bidepltpl.NewInterpolatedTemplate([]byte(`
+---
+name: fake-deployment-name
+azs:
+- name: z1
+- name: z2
+- name: z3
+networks:
+- name: fake-network-name
+  type: manual
+  subnets:
+  - range: 10.0.0.0/24
+    gateway: 10.0.0.1
+    reserved: [10.0.0.2 - 10.0.0.9]
+    az: z1
+    cloud_properties: {subnet: subnet-1}
+  - range: 10.0.1.0/24
+    gateway: 10.0.1.1
+    azs: [z2, z3]
+    cloud_properties: {subnet: subnet-2}
+`), "fake-sha")
+
+				deploymentManifest, err := parser.Parse(interpolatedTemplate, manifestPath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentManifest.Networks[0].Subnets).To(Equal([]Subnet{
+					{
+						Range:           "10.0.0.0/24",
+						Gateway:         "10.0.0.1",
+						Reserved:        []string{"10.0.0.2 - 10.0.0.9"},
+						AZs:             []string{"z1"},
+						CloudProperties: biproperty.Map{"subnet": "subnet-1"},
+					},
+					{
+						Range:           "10.0.1.0/24",
+						Gateway:         "10.0.1.1",
+						AZs:             []string{"z2", "z3"},
+						CloudProperties: biproperty.Map{"subnet": "subnet-2"},
+					},
+				}))
+			})
+
+			It("converts an IPv6 manual network with top-level netmask and gateway into a subnet", func() {
+				interpolatedTemplate = bidepltpl.NewInterpolatedTemplate([]byte(`
+---
+name: fake-deployment-name
+networks:
+- name: fake-network-name
+  type: manual
+  netmask: "ffff:ffff:ffff:ffff::"
+  gateway: "2001:db8:1::1"
+`), "fake-sha")
+
+				deploymentManifest, err := parser.Parse(interpolatedTemplate, manifestPath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentManifest.Networks[0].Subnets[0].Range).To(Equal("2001:db8:1::/64"))
+				Expect(deploymentManifest.Networks[0].Subnets[0].Gateway).To(Equal("2001:db8:1::1"))
+			})
+
+			It("keeps the ip of a vip network for the validator", func() {
+				interpolatedTemplate = bidepltpl.NewInterpolatedTemplate([]byte(`
+---
+name: fake-deployment-name
+networks:
+- name: fake-vip-name
+  type: vip
+  ip: 1.2.3.4
+`), "fake-sha")
+
+				deploymentManifest, err := parser.Parse(interpolatedTemplate, manifestPath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentManifest.Networks[0].IP).To(Equal("1.2.3.4"))
+			})
+		})
+
+		Context("when a job network specifies static_ip_count", func() {
+			BeforeEach(func() {
+				contents := `
+---
+name: fake-deployment-name
+networks:
+- name: fake-network-name
+  type: manual
+  subnets:
+  - range: 10.0.0.0/24
+    gateway: 10.0.0.1
+    static: [10.0.0.10 - 10.0.0.20]
+jobs:
+- name: fake-job-name
+  networks:
+  - name: fake-network-name
+    static_ip_count: 2
+`
+				interpolatedTemplate = bidepltpl.NewInterpolatedTemplate([]byte(contents), "fake-sha")
+			})
+
+			It("allocates static IPs from the network's static pool", func() {
+				deploymentManifest, err := parser.Parse(interpolatedTemplate, manifestPath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentManifest.Networks[0].Subnets[0].Static).To(Equal([]string{"10.0.0.10 - 10.0.0.20"}))
+				Expect(deploymentManifest.Jobs[0].Networks[0].StaticIPCount).To(Equal(2))
+				Expect(deploymentManifest.Jobs[0].Networks[0].StaticIPs).To(Equal([]string{"10.0.0.10", "10.0.0.11"}))
+			})
+		})
+
 		Context("when jobs is defined inside an instance_group, treats it as templates", func() {
 			BeforeEach(func() {
 				contents := `
 ---
+name: fake-deployment-name
 instance_groups:
 - name: jobby
   jobs:
@@ -494,6 +1223,7 @@ instance_groups:
 			BeforeEach(func() {
 				contents := `
 ---
+name: fake-deployment-name
 instance_groups:
 - name: jobby
   jobs:
@@ -511,10 +1241,43 @@ instance_groups:
 			})
 		})
 
+		Context("when job is defined inside an instance_group with links", func() {
+			BeforeEach(func() {
+				contents := `
+---
+name: fake-deployment-name
+instance_groups:
+- name: jobby
+  jobs:
+  - name: job1
+    consumes:
+      db: {from: primary-db}
+      cache: {}
+    provides:
+      web: {as: public-web}
+`
+				interpolatedTemplate = bidepltpl.NewInterpolatedTemplate([]byte(contents), "fake-sha")
+			})
+
+			It("parses the link names", func() {
+				deploymentManifest, err := parser.Parse(interpolatedTemplate, manifestPath)
+				Expect(err).ToNot(HaveOccurred())
+
+				jobRef := deploymentManifest.Jobs[0].Templates[0]
+				Expect(jobRef.Consumes).To(Equal(map[string]string{"db": "primary-db"}))
+				Expect(jobRef.Provides).To(Equal(map[string]string{"web": "public-web"}))
+
+				Expect(jobRef.ConsumedLinkName("db")).To(Equal("primary-db"))
+				Expect(jobRef.ConsumedLinkName("cache")).To(Equal("cache"))
+				Expect(jobRef.ProvidedLinkName("web")).To(Equal("public-web"))
+			})
+		})
+
 		Context("when job is defined inside an instance_group with empty properties", func() {
 			BeforeEach(func() {
 				contents := `
 ---
+name: fake-deployment-name
 instance_groups:
 - name: jobby
   jobs:
@@ -535,6 +1298,7 @@ instance_groups:
 			BeforeEach(func() {
 				contents := `
 ---
+name: fake-deployment-name
 instance_groups:
 - name: jobby
   jobs:
@@ -554,6 +1318,7 @@ instance_groups:
 			BeforeEach(func() {
 				contents := `
 ---
+name: fake-deployment-name
 jobs:
 - name: jobby
 
@@ -575,6 +1340,7 @@ instance_groups:
 			BeforeEach(func() {
 				contents := `
 ---
+name: fake-deployment-name
 jobs:
 - name: jobby
   templates:
@@ -593,5 +1359,161 @@ jobs:
 			})
 		})
 
+		Context("when the manifest uses vm_types, azs, vm_extensions and stemcells", func() {
+			parse := func(contents string) (Manifest, error) {
+				return parser.Parse(bidepltpl.NewInterpolatedTemplate([]byte(contents), "fake-sha"), manifestPath)
+			}
+
+			It("maps each instance group onto a resource pool of its own", func() {
+				deploymentManifest, err := parse(`
+---
+name: fake-deployment-name
+azs:
+- name: z1
+  cloud_properties: {availability_zone: us-east-1a, type: az-type}
+vm_types:
+- name: default
+  cloud_properties: {type: m4.large}
+vm_extensions:
+- name: public-lbs
+  cloud_properties: {elbs: [lb]}
+stemcells:
+- alias: default
+  os: ubuntu-trusty
+  url: http://fake-stemcell-url
+  sha1: fake-stemcell-sha1
+disk_types:
+- name: fake-disk-type
+  disk_size: 1024
+networks:
+- name: fake-network-name
+  type: manual
+  subnets:
+  - range: 10.0.0.0/24
+    gateway: 10.0.0.1
+    az: z1
+instance_groups:
+- name: fake-instance-group
+  instances: 1
+  azs: [z1]
+  vm_type: default
+  vm_extensions: [public-lbs]
+  stemcell: default
+  persistent_disk_type: fake-disk-type
+  env:
+    bosh: {password: secret}
+  networks:
+  - name: fake-network-name
+`)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(deploymentManifest.ResourcePools).To(Equal([]ResourcePool{
+					{
+						Name:    "fake-instance-group",
+						Network: "fake-network-name",
+						CloudProperties: biproperty.Map{
+							"availability_zone": "us-east-1a",
+							"type":              "m4.large",
+							"elbs":              biproperty.List{"lb"},
+						},
+						Env: biproperty.Map{
+							"bosh": biproperty.Map{"password": "secret"},
+						},
+						Stemcell: StemcellRef{
+							URL:  "http://fake-stemcell-url",
+							SHA1: "fake-stemcell-sha1",
+						},
+					},
+				}))
+				Expect(deploymentManifest.Jobs[0].ResourcePool).To(Equal("fake-instance-group"))
+				Expect(deploymentManifest.Jobs[0].PersistentDiskPool).To(Equal("fake-disk-type"))
+			})
+
+			It("maps the type of persistent_disks to their disk pool", func() {
+				deploymentManifest, err := parse(`
+---
+name: fake-deployment-name
+vm_types:
+- name: default
+stemcells:
+- alias: default
+  url: http://fake-stemcell-url
+disk_types:
+- name: fake-disk-type
+  disk_size: 1024
+disk_pools:
+- name: fake-disk-pool
+  disk_size: 2048
+networks:
+- name: fake-network-name
+  type: dynamic
+instance_groups:
+- name: fake-instance-group
+  vm_type: default
+  stemcell: default
+  persistent_disks:
+  - name: fake-disk-name-1
+    type: fake-disk-type
+  - name: fake-disk-name-2
+    disk_pool: fake-disk-pool
+  networks:
+  - name: fake-network-name
+`)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(deploymentManifest.Jobs[0].PersistentDisks).To(Equal([]JobPersistentDisk{
+					{Name: "fake-disk-name-1", DiskPool: "fake-disk-type"},
+					{Name: "fake-disk-name-2", DiskPool: "fake-disk-pool"},
+				}))
+			})
+
+			It("returns an error for unknown vm_types, stemcells, azs and vm_extensions", func() {
+				_, err := parse(`
+---
+name: fake-deployment-name
+vm_types:
+- name: default
+stemcells:
+- alias: default
+  url: http://fake-stemcell-url
+networks:
+- name: fake-network-name
+  type: dynamic
+  subnets:
+  - azs: [z9]
+instance_groups:
+- name: fake-unknown-vm-type
+  vm_type: missing
+  stemcell: default
+- name: fake-unknown-stemcell
+  vm_type: default
+  stemcell: missing
+- name: fake-unknown-az-and-extension
+  vm_type: default
+  stemcell: default
+  azs: [z1]
+  vm_extensions: [missing]
+`)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Network 'fake-network-name' subnets[0] references unknown az 'z9'"))
+				Expect(err.Error()).To(ContainSubstring("Instance group 'fake-unknown-vm-type' references unknown vm_type 'missing'"))
+				Expect(err.Error()).To(ContainSubstring("Instance group 'fake-unknown-stemcell' references unknown stemcell 'missing'"))
+				Expect(err.Error()).To(ContainSubstring("Instance group 'fake-unknown-az-and-extension' references unknown az 'z1'"))
+				Expect(err.Error()).To(ContainSubstring("Instance group 'fake-unknown-az-and-extension' references unknown vm_extension 'missing'"))
+			})
+
+			It("returns an error when an instance group specifies both resource_pool and vm_type", func() {
+				_, err := parse(`
+---
+name: fake-deployment-name
+instance_groups:
+- name: fake-instance-group
+  resource_pool: fake-resource-pool-name
+  vm_type: default
+`)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Instance group 'fake-instance-group' specifies both resource_pool and vm_type, only one is allowed"))
+			})
+		})
 	})
 })
@@ -0,0 +1,80 @@
+package manifest
+
+import (
+	"reflect"
+	"sort"
+
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+)
+
+// mergeProperties deep-merges override on top of base without modifying either.
+// Nested maps are merged key by key; any other value in override replaces the one in base.
+func mergeProperties(base, override biproperty.Map) biproperty.Map {
+	result, _ := mergePropertiesReportingConflicts(base, override, "")
+	return result
+}
+
+// mergePropertiesReportingConflicts behaves like mergeProperties and additionally
+// returns the dotted key paths where override replaced a different value from base.
+func mergePropertiesReportingConflicts(base, override biproperty.Map, prefix string) (biproperty.Map, []string) {
+	result := copyProperties(base)
+	conflicts := []string{}
+
+	for _, key := range sortedKeys(override) {
+		overrideValue := override[key]
+		keyPath := key
+		if prefix != "" {
+			keyPath = prefix + "." + key
+		}
+
+		baseValue, baseFound := result[key]
+		overrideMap, overrideIsMap := overrideValue.(biproperty.Map)
+		baseMap, baseIsMap := baseValue.(biproperty.Map)
+
+		if overrideIsMap && baseIsMap {
+			merged, nestedConflicts := mergePropertiesReportingConflicts(baseMap, overrideMap, keyPath)
+			result[key] = merged
+			conflicts = append(conflicts, nestedConflicts...)
+			continue
+		}
+
+		if baseFound && !reflect.DeepEqual(baseValue, overrideValue) {
+			conflicts = append(conflicts, keyPath)
+		}
+		result[key] = copyProperty(overrideValue)
+	}
+
+	return result, conflicts
+}
+
+func copyProperties(properties biproperty.Map) biproperty.Map {
+	result := make(biproperty.Map, len(properties))
+	for key, value := range properties {
+		result[key] = copyProperty(value)
+	}
+	return result
+}
+
+func copyProperty(value biproperty.Property) biproperty.Property {
+	switch typedValue := value.(type) {
+	case biproperty.Map:
+		return copyProperties(typedValue)
+	case biproperty.List:
+		result := make(biproperty.List, len(typedValue))
+		for i, item := range typedValue {
+			result[i] = copyProperty(item)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+func sortedKeys(properties biproperty.Map) []string {
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,108 @@
+package manifest
+
+import (
+	bidepltpl "github.com/cloudfoundry/bosh-cli/deployment/template"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"gopkg.in/yaml.v2"
+)
+
+// PropertyFilesParser parses a deployment manifest and deep-merges additional
+// YAML property files into its global properties.
+type PropertyFilesParser interface {
+	ParseWithPropertyFiles(interpolatedTemplate bidepltpl.InterpolatedTemplate, path string, propertyPaths []string) (Manifest, error)
+}
+
+type PropertyFilesOpts struct {
+	// FilesWin makes property file values override the manifest's own properties.
+	// By default the manifest wins. Later files always override earlier ones.
+	FilesWin bool
+
+	// Strict turns any conflicting key into an error naming the file and key path.
+	Strict bool
+}
+
+type propertyFilesParser struct {
+	parser Parser
+	fs     boshsys.FileSystem
+	opts   PropertyFilesOpts
+}
+
+func NewPropertyFilesParser(parser Parser, fs boshsys.FileSystem, opts PropertyFilesOpts) PropertyFilesParser {
+	return propertyFilesParser{
+		parser: parser,
+		fs:     fs,
+		opts:   opts,
+	}
+}
+
+type propertySource struct {
+	path       string
+	properties biproperty.Map
+}
+
+func (p propertyFilesParser) ParseWithPropertyFiles(interpolatedTemplate bidepltpl.InterpolatedTemplate, path string, propertyPaths []string) (Manifest, error) {
+	deploymentManifest, err := p.parser.Parse(interpolatedTemplate, path)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	sources := []propertySource{}
+	for _, propertyPath := range propertyPaths {
+		properties, err := p.readPropertyFile(propertyPath)
+		if err != nil {
+			return Manifest{}, err
+		}
+		sources = append(sources, propertySource{path: propertyPath, properties: properties})
+	}
+
+	manifestSource := propertySource{path: path, properties: deploymentManifest.Properties}
+	if p.opts.FilesWin {
+		sources = append([]propertySource{manifestSource}, sources...)
+	} else {
+		sources = append(sources, manifestSource)
+	}
+
+	merged := biproperty.Map{}
+	errs := []error{}
+	for _, source := range sources {
+		var conflicts []string
+		merged, conflicts = mergePropertiesReportingConflicts(merged, source.properties, "")
+
+		if p.opts.Strict {
+			for _, keyPath := range conflicts {
+				errs = append(errs, bosherr.Errorf("Property '%s' from '%s' conflicts with a previously defined value", keyPath, source.path))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return Manifest{}, bosherr.WrapError(bosherr.NewMultiError(errs...), "Merging property files")
+	}
+
+	deploymentManifest.Properties = merged
+
+	return deploymentManifest, nil
+}
+
+func (p propertyFilesParser) readPropertyFile(path string) (biproperty.Map, error) {
+	contents, err := p.fs.ReadFile(path)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Reading property file '%s'", path)
+	}
+
+	rawProperties := map[interface{}]interface{}{}
+
+	err = yaml.Unmarshal(contents, &rawProperties)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Unmarshalling property file '%s'", path)
+	}
+
+	properties, err := biproperty.BuildMap(rawProperties)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Parsing property file '%s'", path)
+	}
+
+	return properties, nil
+}
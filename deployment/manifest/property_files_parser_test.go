@@ -0,0 +1,107 @@
+package manifest_test
+
+import (
+	. "github.com/cloudfoundry/bosh-cli/deployment/manifest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	bidepltpl "github.com/cloudfoundry/bosh-cli/deployment/template"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("PropertyFilesParser", func() {
+	var (
+		fakeFs               *fakesys.FakeFileSystem
+		interpolatedTemplate bidepltpl.InterpolatedTemplate
+		opts                 PropertyFilesOpts
+	)
+
+	BeforeEach(func() {
+		fakeFs = fakesys.NewFakeFileSystem()
+		opts = PropertyFilesOpts{}
+
+		interpolatedTemplate = bidepltpl.NewInterpolatedTemplate([]byte(`
+---
+name: fake-deployment-name
+properties:
+  shared:
+    key: manifest-value
+  manifest-only: manifest-value
+`), "fake-sha")
+
+		err := fakeFs.WriteFileString("/fake-props-1.yml", `
+shared:
+  key: file-1-value
+  file-1-key: file-1-value
+overlap: file-1-value
+`)
+		Expect(err).ToNot(HaveOccurred())
+
+		err = fakeFs.WriteFileString("/fake-props-2.yml", `
+overlap: file-2-value
+file-2-only: file-2-value
+`)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	parse := func() (Manifest, error) {
+		parser := NewParser(fakeFs, boshlog.NewLogger(boshlog.LevelNone))
+		return NewPropertyFilesParser(parser, fakeFs, opts).ParseWithPropertyFiles(
+			interpolatedTemplate, "/fake-manifest.yml", []string{"/fake-props-1.yml", "/fake-props-2.yml"})
+	}
+
+	It("deep-merges property files with the manifest values winning", func() {
+		deploymentManifest, err := parse()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deploymentManifest.Properties).To(Equal(biproperty.Map{
+			"shared": biproperty.Map{
+				"key":        "manifest-value",
+				"file-1-key": "file-1-value",
+			},
+			"manifest-only": "manifest-value",
+			"overlap":       "file-2-value",
+			"file-2-only":   "file-2-value",
+		}))
+	})
+
+	It("lets property files win when configured", func() {
+		opts.FilesWin = true
+
+		deploymentManifest, err := parse()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deploymentManifest.Properties["shared"]).To(Equal(biproperty.Map{
+			"key":        "file-1-value",
+			"file-1-key": "file-1-value",
+		}))
+		Expect(deploymentManifest.Properties["overlap"]).To(Equal("file-2-value"))
+	})
+
+	It("reports the file and key path of every conflict in strict mode", func() {
+		opts.Strict = true
+
+		_, err := parse()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Property 'overlap' from '/fake-props-2.yml' conflicts with a previously defined value"))
+		Expect(err.Error()).To(ContainSubstring("Property 'shared.key' from '/fake-manifest.yml' conflicts with a previously defined value"))
+	})
+
+	It("returns an error when a property file cannot be read", func() {
+		fakeFs.RegisterReadFileError("/fake-props-1.yml", bosherr.Error("fake-read-error"))
+
+		_, err := parse()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Reading property file '/fake-props-1.yml'"))
+	})
+
+	It("returns an error when a property file is not valid YAML", func() {
+		err := fakeFs.WriteFileString("/fake-props-2.yml", "-")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = parse()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Unmarshalling property file '/fake-props-2.yml'"))
+	})
+})
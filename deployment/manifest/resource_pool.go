@@ -1,6 +1,7 @@
 package manifest
 
 import (
+	birelmanifest "github.com/cloudfoundry/bosh-cli/release/manifest"
 	biproperty "github.com/cloudfoundry/bosh-utils/property"
 )
 
@@ -10,11 +11,20 @@ type ResourcePool struct {
 	CloudProperties biproperty.Map
 	Env             biproperty.Map
 	Stemcell        StemcellRef
+
+	// CPI is the name of the CPI from the cpis section that creates the VMs
+	// of the resource pool, empty for the cloud_provider CPI.
+	CPI string
 }
 
 type StemcellRef struct {
-	URL  string
-	SHA1 string
+	URL       string
+	SHA1      string
+	Signature birelmanifest.SignatureRef
+
+	// OS is not part of the manifest; it is filled in from the stemcell
+	// tarball so that jobs are rendered for the right operating system.
+	OS string
 }
 
 func (s StemcellRef) GetURL() string {
@@ -25,6 +35,14 @@ func (s StemcellRef) GetSHA1() string {
 	return s.SHA1
 }
 
+func (s StemcellRef) GetSignatureURL() string {
+	return s.Signature.URL
+}
+
+func (s StemcellRef) GetSignaturePublicKey() string {
+	return s.Signature.PublicKey
+}
+
 func (s StemcellRef) Description() string {
 	return "stemcell"
 }
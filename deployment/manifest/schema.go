@@ -0,0 +1,469 @@
+package manifest
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// SchemaError is a deployment manifest value that does not match the schema.
+// Line and Column are 1-based, and zero when the location is not known, for
+// example inside flow style collections.
+type SchemaError struct {
+	Path    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e SchemaError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s %s", e.Path, e.Message)
+	}
+	return fmt.Sprintf("line %d, column %d: %s %s", e.Line, e.Column, e.Path, e.Message)
+}
+
+type schemaKind int
+
+const (
+	schemaAny schemaKind = iota
+	schemaScalar
+	schemaInt
+	schemaBool
+	schemaMap
+	schemaList
+)
+
+type schema struct {
+	kind schemaKind
+
+	// fields lists the allowed keys of a map; a nil fields allows any key.
+	fields map[string]schemaField
+
+	// items is the schema of each list entry.
+	items *schema
+}
+
+type schemaField struct {
+	schema   *schema
+	required bool
+}
+
+var (
+	anySchema    = &schema{kind: schemaAny}
+	scalarSchema = &schema{kind: schemaScalar}
+	intSchema    = &schema{kind: schemaInt}
+	boolSchema   = &schema{kind: schemaBool}
+	mapSchema    = &schema{kind: schemaMap}
+)
+
+func listOf(items *schema) *schema {
+	return &schema{kind: schemaList, items: items}
+}
+
+func mapOf(fields map[string]schemaField) *schema {
+	return &schema{kind: schemaMap, fields: fields}
+}
+
+func optional(s *schema) schemaField { return schemaField{schema: s} }
+func required(s *schema) schemaField { return schemaField{schema: s, required: true} }
+
+var (
+	diskPoolSchema = listOf(mapOf(map[string]schemaField{
+		"name":             required(scalarSchema),
+		"disk_size":        required(intSchema),
+		"cloud_properties": optional(mapSchema),
+	}))
+
+	signatureSchema = mapOf(map[string]schemaField{
+		"url":        required(scalarSchema),
+		"public_key": required(scalarSchema),
+	})
+
+	cloudPropertiesSchema = listOf(mapOf(map[string]schemaField{
+		"name":             required(scalarSchema),
+		"cloud_properties": optional(mapSchema),
+	}))
+
+	releaseJobRefSchema = listOf(mapOf(map[string]schemaField{
+		"name":       required(scalarSchema),
+		"release":    optional(scalarSchema),
+		"properties": optional(mapSchema),
+		"consumes":   optional(mapSchema),
+		"provides":   optional(mapSchema),
+	}))
+
+	jobSchema = listOf(mapOf(map[string]schemaField{
+		"name":                 required(scalarSchema),
+		"instances":            optional(intSchema),
+		"lifecycle":            optional(scalarSchema),
+		"templates":            optional(releaseJobRefSchema),
+		"jobs":                 optional(releaseJobRefSchema),
+		"persistent_disk":      optional(intSchema),
+		"persistent_disk_pool": optional(scalarSchema),
+		"resource_pool":        optional(scalarSchema),
+		"properties":           optional(mapSchema),
+		"persistent_disk_type": optional(scalarSchema),
+		"vm_type":              optional(scalarSchema),
+		"vm_extensions":        optional(listOf(scalarSchema)),
+		"stemcell":             optional(scalarSchema),
+		"azs":                  optional(listOf(scalarSchema)),
+		"env":                  optional(mapSchema),
+		"update": optional(mapOf(map[string]schemaField{
+			"update_watch_time": optional(scalarSchema),
+			"canary_watch_time": optional(scalarSchema),
+		})),
+		"persistent_disks": optional(listOf(mapOf(map[string]schemaField{
+			"name":      required(scalarSchema),
+			"disk_pool": optional(scalarSchema),
+			"type":      optional(scalarSchema),
+		}))),
+		"networks": optional(listOf(mapOf(map[string]schemaField{
+			"name":            required(scalarSchema),
+			"default":         optional(listOf(scalarSchema)),
+			"static_ips":      optional(listOf(scalarSchema)),
+			"static_ip_count": optional(intSchema),
+		}))),
+	}))
+
+	// deploymentSchema describes the sections read by the Parser. Sections read
+	// by the release set and installation parsers are accepted as is.
+	deploymentSchema = mapOf(map[string]schemaField{
+		"name": required(scalarSchema),
+		"update": optional(mapOf(map[string]schemaField{
+			"update_watch_time":   optional(scalarSchema),
+			"canary_watch_time":   optional(scalarSchema),
+			"canaries":            optional(intSchema),
+			"max_in_flight":       optional(intSchema),
+			"serial":              optional(boolSchema),
+			"script_timeouts":     optional(mapSchema),
+			"agent_wait_timeout":  optional(scalarSchema),
+			"agent_poll_interval": optional(scalarSchema),
+			"agent_max_errors":    optional(intSchema),
+			"vm_strategy":         optional(scalarSchema),
+		})),
+		"networks": optional(listOf(mapOf(map[string]schemaField{
+			"name":             required(scalarSchema),
+			"type":             optional(scalarSchema),
+			"cloud_properties": optional(mapSchema),
+			"ip":               optional(scalarSchema),
+			"netmask":          optional(scalarSchema),
+			"gateway":          optional(scalarSchema),
+			"dns":              optional(listOf(scalarSchema)),
+			"subnets": optional(listOf(mapOf(map[string]schemaField{
+				"range":            optional(scalarSchema),
+				"gateway":          optional(scalarSchema),
+				"dns":              optional(listOf(scalarSchema)),
+				"static":           optional(listOf(scalarSchema)),
+				"reserved":         optional(listOf(scalarSchema)),
+				"az":               optional(scalarSchema),
+				"azs":              optional(listOf(scalarSchema)),
+				"cloud_properties": optional(mapSchema),
+			}))),
+		}))),
+		"resource_pools": optional(listOf(mapOf(map[string]schemaField{
+			"name":             required(scalarSchema),
+			"network":          optional(scalarSchema),
+			"cloud_properties": optional(mapSchema),
+			"env":              optional(mapSchema),
+			"cpi":              optional(scalarSchema),
+			"stemcell": optional(mapOf(map[string]schemaField{
+				"url":       required(scalarSchema),
+				"sha1":      optional(scalarSchema),
+				"signature": optional(signatureSchema),
+			})),
+		}))),
+		"disk_pools":      optional(diskPoolSchema),
+		"disk_types":      optional(diskPoolSchema),
+		"jobs":            optional(jobSchema),
+		"instance_groups": optional(jobSchema),
+		"azs":             optional(cloudPropertiesSchema),
+		"vm_types":        optional(cloudPropertiesSchema),
+		"vm_extensions":   optional(cloudPropertiesSchema),
+		"stemcells": optional(listOf(mapOf(map[string]schemaField{
+			"alias":     required(scalarSchema),
+			"os":        optional(scalarSchema),
+			"name":      optional(scalarSchema),
+			"version":   optional(scalarSchema),
+			"url":       optional(scalarSchema),
+			"sha1":      optional(scalarSchema),
+			"signature": optional(signatureSchema),
+		}))),
+		"properties": optional(mapSchema),
+		"tags":       optional(mapSchema),
+		"features": optional(mapOf(map[string]schemaField{
+			"use_dns_addresses": optional(boolSchema),
+			"dns_domain_name":   optional(scalarSchema),
+		})),
+		"releases":       optional(anySchema),
+		"cloud_provider": optional(anySchema),
+		"cpis":           optional(anySchema),
+		"variables":      optional(anySchema),
+	})
+)
+
+// ValidateSchema checks the deployment manifest for unknown keys, missing
+// required keys and values of the wrong type, which the Parser would otherwise
+// silently drop or fail on much later.
+func (v *validator) ValidateSchema(contents []byte) error {
+	var document interface{}
+
+	err := yaml.Unmarshal(contents, &document)
+	if err != nil {
+		return bosherr.WrapError(err, "Unmarshalling BOSH deployment manifest")
+	}
+
+	walker := schemaWalker{locator: newYAMLLocator(string(contents))}
+	walker.walk(deploymentSchema, document, []interface{}{})
+
+	if len(walker.errs) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(walker.errs, func(i, j int) bool {
+		return walker.errs[i].Line != 0 && (walker.errs[j].Line == 0 || walker.errs[i].Line < walker.errs[j].Line)
+	})
+
+	errs := []error{}
+	for _, schemaErr := range walker.errs {
+		errs = append(errs, schemaErr)
+	}
+
+	return bosherr.NewMultiError(errs...)
+}
+
+type schemaWalker struct {
+	locator *yamlLocator
+	errs    []SchemaError
+}
+
+func (w *schemaWalker) walk(s *schema, value interface{}, path []interface{}) {
+	if value == nil {
+		return
+	}
+
+	switch s.kind {
+	case schemaScalar:
+		switch value.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			w.fail(path, "must be a scalar value")
+		}
+
+	case schemaInt:
+		switch typedValue := value.(type) {
+		case int, int64, uint64:
+		case float64:
+			if typedValue != math.Trunc(typedValue) {
+				w.fail(path, "must be an integer")
+			}
+		default:
+			w.fail(path, "must be an integer")
+		}
+
+	case schemaBool:
+		if _, ok := value.(bool); !ok {
+			w.fail(path, "must be a boolean")
+		}
+
+	case schemaList:
+		list, ok := value.([]interface{})
+		if !ok {
+			w.fail(path, "must be a list")
+			return
+		}
+		for i, item := range list {
+			w.walk(s.items, item, w.child(path, i))
+		}
+
+	case schemaMap:
+		m, ok := value.(map[interface{}]interface{})
+		if !ok {
+			w.fail(path, "must be a map")
+			return
+		}
+		if s.fields == nil {
+			return
+		}
+
+		keys := []string{}
+		for key := range m {
+			keys = append(keys, fmt.Sprintf("%v", key))
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			field, found := s.fields[key]
+			if !found {
+				w.fail(w.child(path, key), "is not a known key")
+				continue
+			}
+			w.walk(field.schema, m[key], w.child(path, key))
+		}
+
+		names := []string{}
+		for name := range s.fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if s.fields[name].required && m[name] == nil {
+				// missing keys are reported at the map that should contain them
+				schemaErr := w.error(w.child(path, name), "must be provided")
+				schemaErr.Line, schemaErr.Column = w.locator.locate(path)
+				w.errs = append(w.errs, schemaErr)
+			}
+		}
+	}
+}
+
+func (w *schemaWalker) child(path []interface{}, segment interface{}) []interface{} {
+	childPath := make([]interface{}, len(path), len(path)+1)
+	copy(childPath, path)
+	return append(childPath, segment)
+}
+
+func (w *schemaWalker) fail(path []interface{}, message string) {
+	schemaErr := w.error(path, message)
+	schemaErr.Line, schemaErr.Column = w.locator.locate(path)
+	w.errs = append(w.errs, schemaErr)
+}
+
+func (w *schemaWalker) error(path []interface{}, message string) SchemaError {
+	pathStr := ""
+	for _, segment := range path {
+		switch typedSegment := segment.(type) {
+		case int:
+			pathStr += fmt.Sprintf("[%d]", typedSegment)
+		default:
+			if pathStr != "" {
+				pathStr += "."
+			}
+			pathStr += fmt.Sprintf("%v", typedSegment)
+		}
+	}
+
+	return SchemaError{Path: pathStr, Message: message}
+}
+
+// yamlToken is a list entry dash or a map key of a block style YAML document.
+type yamlToken struct {
+	line   int
+	column int
+	dash   bool
+	key    string
+}
+
+// yamlLocator finds the line and column of a path in block style YAML without
+// a full YAML parser. Keys and list entries nested in flow style collections
+// are not located.
+type yamlLocator struct {
+	tokens []yamlToken
+}
+
+var (
+	yamlKeyPattern         = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s#{}\[\]"'][^:#]*?)\s*:(\s|$)`)
+	yamlBlockScalarPattern = regexp.MustCompile(`:\s*[|>][-+0-9]*\s*(#.*)?$`)
+)
+
+func newYAMLLocator(contents string) *yamlLocator {
+	locator := &yamlLocator{}
+	blockScalarIndent := -1
+
+	for i, line := range strings.Split(contents, "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+
+		if strings.TrimSpace(trimmed) == "" {
+			continue
+		}
+		if blockScalarIndent >= 0 && indent > blockScalarIndent {
+			continue
+		}
+		blockScalarIndent = -1
+
+		if strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "---") {
+			continue
+		}
+
+		column := indent
+		for trimmed == "-" || strings.HasPrefix(trimmed, "- ") {
+			locator.tokens = append(locator.tokens, yamlToken{line: i + 1, column: column + 1, dash: true})
+			rest := strings.TrimLeft(trimmed[1:], " ")
+			column += len(trimmed) - len(rest)
+			trimmed = rest
+		}
+
+		if matches := yamlKeyPattern.FindStringSubmatch(trimmed); matches != nil {
+			key := strings.Trim(matches[1], `"'`)
+			locator.tokens = append(locator.tokens, yamlToken{line: i + 1, column: column + 1, key: key})
+		}
+
+		if yamlBlockScalarPattern.MatchString(trimmed) {
+			blockScalarIndent = indent
+		}
+	}
+
+	return locator
+}
+
+// locate returns the position of the key or list entry at path, or the
+// position of its closest located ancestor.
+func (l *yamlLocator) locate(path []interface{}) (int, int) {
+	from, to := 0, len(l.tokens)
+	line, column := 0, 0
+
+	for _, segment := range path {
+		if from >= to {
+			return line, column
+		}
+
+		blockColumn := l.tokens[from].column
+		found := -1
+		entry := 0
+
+		for i := from; i < to; i++ {
+			token := l.tokens[i]
+			if token.column != blockColumn {
+				continue
+			}
+
+			if idx, ok := segment.(int); ok && token.dash {
+				if entry == idx {
+					found = i
+					break
+				}
+				entry++
+			} else if key, ok := segment.(string); ok && !token.dash && token.key == key {
+				found = i
+				break
+			}
+		}
+
+		if found < 0 {
+			return line, column
+		}
+
+		line, column = l.tokens[found].line, l.tokens[found].column
+
+		// the value of a key may be a list whose dashes share the key's column
+		parent := l.tokens[found]
+		from = found + 1
+		end := from
+		for end < to {
+			token := l.tokens[end]
+			if token.column <= parent.column && !(token.dash && !parent.dash && token.column == parent.column) {
+				break
+			}
+			end++
+		}
+		to = end
+	}
+
+	return line, column
+}
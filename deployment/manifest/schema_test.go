@@ -0,0 +1,160 @@
+package manifest_test
+
+import (
+	. "github.com/cloudfoundry/bosh-cli/deployment/manifest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("Validator", func() {
+	var validator Validator
+
+	BeforeEach(func() {
+		validator = NewValidator(boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	Describe("ValidateSchema", func() {
+		schemaErrors := func(err error) []SchemaError {
+			Expect(err).To(HaveOccurred())
+
+			multiErr, ok := err.(bosherr.MultiError)
+			Expect(ok).To(BeTrue())
+
+			schemaErrs := []SchemaError{}
+			for _, e := range multiErr.Errors {
+				schemaErrs = append(schemaErrs, e.(SchemaError))
+			}
+			return schemaErrs
+		}
+
+		It("accepts a valid manifest including sections read by other parsers", func() {
+			err := validator.ValidateSchema([]byte(`---
+name: fake-deployment-name
+releases:
+- name: fake-release-name
+  url: file://fake-release-url
+update:
+  update_watch_time: 1000-30000
+  canaries: 1
+  max_in_flight: 2
+  serial: false
+networks:
+- name: fake-network-name
+  type: manual
+  subnets:
+  - range: 10.0.0.0/24
+    gateway: 10.0.0.1
+    static: [10.0.0.10]
+jobs:
+- name: fake-job-name
+  instances: 1
+  templates:
+  - {name: fake-template-name, release: fake-release-name}
+  properties:
+    anything: {goes: here}
+cloud_provider:
+  template: {name: cpi, release: cpi-release}
+`))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("reports unknown keys with their line and column", func() {
+			err := validator.ValidateSchema([]byte(`---
+name: fake-deployment-name
+jobs:
+- name: fake-job-name
+  instnaces: 1
+  networks:
+  - name: fake-network-name
+    static_ip: [10.0.0.10]
+`))
+
+			Expect(schemaErrors(err)).To(Equal([]SchemaError{
+				{Path: "jobs[0].instnaces", Line: 5, Column: 3, Message: "is not a known key"},
+				{Path: "jobs[0].networks[0].static_ip", Line: 8, Column: 5, Message: "is not a known key"},
+			}))
+			Expect(err.Error()).To(ContainSubstring("line 5, column 3: jobs[0].instnaces is not a known key"))
+		})
+
+		It("reports values of the wrong type", func() {
+			err := validator.ValidateSchema([]byte(`---
+name: fake-deployment-name
+networks:
+  name: fake-network-name
+jobs:
+- name: fake-job-name
+  instances: many
+  persistent_disk: 1.5
+`))
+
+			Expect(schemaErrors(err)).To(Equal([]SchemaError{
+				{Path: "networks", Line: 3, Column: 1, Message: "must be a list"},
+				{Path: "jobs[0].instances", Line: 7, Column: 3, Message: "must be an integer"},
+				{Path: "jobs[0].persistent_disk", Line: 8, Column: 3, Message: "must be an integer"},
+			}))
+		})
+
+		It("reports update values of the wrong type", func() {
+			err := validator.ValidateSchema([]byte(`---
+name: fake-deployment-name
+update:
+  max_in_flight: 50%
+  serial: "no"
+`))
+
+			Expect(schemaErrors(err)).To(Equal([]SchemaError{
+				{Path: "update.max_in_flight", Line: 4, Column: 3, Message: "must be an integer"},
+				{Path: "update.serial", Line: 5, Column: 3, Message: "must be a boolean"},
+			}))
+		})
+
+		It("reports missing required keys at the enclosing entry", func() {
+			err := validator.ValidateSchema([]byte(`---
+name: fake-deployment-name
+disk_pools:
+- name: fake-disk-pool-name
+  disk_size: 1024
+- cloud_properties: {type: gp2}
+`))
+
+			Expect(schemaErrors(err)).To(Equal([]SchemaError{
+				{Path: "disk_pools[1].disk_size", Line: 6, Column: 1, Message: "must be provided"},
+				{Path: "disk_pools[1].name", Line: 6, Column: 1, Message: "must be provided"},
+			}))
+		})
+
+		It("ignores block scalar contents when locating keys", func() {
+			err := validator.ValidateSchema([]byte(`---
+name: fake-deployment-name
+properties:
+  cert: |
+    key: value
+jobs:
+- name: fake-job-name
+  key: value
+`))
+
+			Expect(schemaErrors(err)).To(Equal([]SchemaError{
+				{Path: "jobs[0].key", Line: 8, Column: 3, Message: "is not a known key"},
+			}))
+		})
+
+		It("omits the location when it cannot be determined", func() {
+			err := validator.ValidateSchema([]byte(`{name: fake-deployment-name, jobs: [{name: fake-job-name, bogus: true}]}`))
+
+			Expect(schemaErrors(err)).To(Equal([]SchemaError{
+				{Path: "jobs[0].bogus", Message: "is not a known key"},
+			}))
+			Expect(err.Error()).To(ContainSubstring("jobs[0].bogus is not a known key"))
+		})
+
+		It("returns an error when the manifest is not valid YAML", func() {
+			err := validator.ValidateSchema([]byte("name: [unclosed"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unmarshalling BOSH deployment manifest"))
+		})
+	})
+})
@@ -0,0 +1,115 @@
+package manifest
+
+import (
+	"net"
+
+	binet "github.com/cloudfoundry/bosh-cli/common/net"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// StaticIPAllocator resolves job networks that request a number of static IPs
+// (static_ip_count) into concrete addresses taken from the network's static pool.
+type StaticIPAllocator interface {
+	Allocate(jobs []Job, networks []Network) error
+}
+
+type staticIPAllocator struct{}
+
+func NewStaticIPAllocator() StaticIPAllocator {
+	return staticIPAllocator{}
+}
+
+// Allocate fills in StaticIPs on every job network that only specifies StaticIPCount.
+// Explicit static_ips take precedence; addresses already listed explicitly by any job are never handed out again.
+func (a staticIPAllocator) Allocate(jobs []Job, networks []Network) error {
+	taken := map[string]struct{}{}
+	for _, job := range jobs {
+		for _, jobNetwork := range job.Networks {
+			for _, ip := range jobNetwork.StaticIPs {
+				if parsedIP := net.ParseIP(ip); parsedIP != nil {
+					taken[parsedIP.String()] = struct{}{}
+				}
+			}
+		}
+	}
+
+	for jobIdx, job := range jobs {
+		for networkIdx, jobNetwork := range job.Networks {
+			if jobNetwork.StaticIPCount == 0 {
+				continue
+			}
+
+			if jobNetwork.StaticIPCount < 0 {
+				return bosherr.Errorf("Job '%s' network '%s' static_ip_count must be >= 0", job.Name, jobNetwork.Name)
+			}
+
+			if len(jobNetwork.StaticIPs) > 0 {
+				if len(jobNetwork.StaticIPs) != jobNetwork.StaticIPCount {
+					return bosherr.Errorf(
+						"Job '%s' network '%s' specifies %d static_ips but static_ip_count is %d",
+						job.Name, jobNetwork.Name, len(jobNetwork.StaticIPs), jobNetwork.StaticIPCount)
+				}
+				continue
+			}
+
+			pool, err := a.staticPool(jobNetwork.Name, networks)
+			if err != nil {
+				return err
+			}
+
+			// ranges are walked address by address instead of being expanded,
+			// so that large static ranges are only walked as far as needed
+			allocated := []string{}
+			for _, staticRange := range pool {
+				for ip := staticRange.first; len(allocated) < jobNetwork.StaticIPCount; ip = binet.NextAddress(ip) {
+					if _, found := taken[ip.String()]; !found {
+						taken[ip.String()] = struct{}{}
+						allocated = append(allocated, ip.String())
+					}
+
+					if ip.Equal(staticRange.last) {
+						break
+					}
+				}
+			}
+
+			if len(allocated) < jobNetwork.StaticIPCount {
+				return bosherr.Errorf(
+					"Job '%s' requires %d static IPs from network '%s' but only %d are available",
+					job.Name, jobNetwork.StaticIPCount, jobNetwork.Name, len(allocated))
+			}
+
+			jobs[jobIdx].Networks[networkIdx].StaticIPs = allocated
+		}
+	}
+
+	return nil
+}
+
+// staticIPRange is an inclusive range of addresses of a static pool
+type staticIPRange struct {
+	first net.IP
+	last  net.IP
+}
+
+func (a staticIPAllocator) staticPool(networkName string, networks []Network) ([]staticIPRange, error) {
+	for _, network := range networks {
+		if network.Name != networkName {
+			continue
+		}
+
+		pool := []staticIPRange{}
+		for _, subnet := range network.Subnets {
+			for _, staticRange := range subnet.Static {
+				first, last, err := binet.ParseIPRangeBounds(staticRange)
+				if err != nil {
+					return nil, bosherr.WrapErrorf(err, "Parsing network '%s' static pool", networkName)
+				}
+				pool = append(pool, staticIPRange{first: first, last: last})
+			}
+		}
+		return pool, nil
+	}
+
+	return nil, bosherr.Errorf("Could not find network '%s' to allocate static IPs from", networkName)
+}
@@ -0,0 +1,154 @@
+package manifest_test
+
+import (
+	. "github.com/cloudfoundry/bosh-cli/deployment/manifest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StaticIPAllocator", func() {
+	var (
+		allocator StaticIPAllocator
+		networks  []Network
+	)
+
+	BeforeEach(func() {
+		allocator = NewStaticIPAllocator()
+		networks = []Network{
+			{
+				Name: "fake-network-name",
+				Type: Manual,
+				Subnets: []Subnet{
+					{
+						Range:   "10.0.0.0/24",
+						Gateway: "10.0.0.1",
+						Static:  []string{"10.0.0.10 - 10.0.0.12", "10.0.0.20"},
+					},
+				},
+			},
+		}
+	})
+
+	It("allocates the requested number of IPs from the static pool in order", func() {
+		jobs := []Job{
+			{
+				Name:     "fake-job-name",
+				Networks: []JobNetwork{{Name: "fake-network-name", StaticIPCount: 3}},
+			},
+		}
+
+		err := allocator.Allocate(jobs, networks)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(jobs[0].Networks[0].StaticIPs).To(Equal([]string{"10.0.0.10", "10.0.0.11", "10.0.0.12"}))
+	})
+
+	It("allocates from a large static range without expanding it", func() {
+		networks[0].Subnets = []Subnet{
+			{
+				Range:   "2001:db8::/64",
+				Gateway: "2001:db8::1",
+				Static:  []string{"2001:db8::10 - 2001:db8::ffff:ffff:ffff:ffff"},
+			},
+		}
+		jobs := []Job{
+			{
+				Name:     "fake-job-name",
+				Networks: []JobNetwork{{Name: "fake-network-name", StaticIPCount: 2}},
+			},
+		}
+
+		err := allocator.Allocate(jobs, networks)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(jobs[0].Networks[0].StaticIPs).To(Equal([]string{"2001:db8::10", "2001:db8::11"}))
+	})
+
+	It("skips IPs explicitly assigned to other jobs", func() {
+		jobs := []Job{
+			{
+				Name:     "fake-explicit-job",
+				Networks: []JobNetwork{{Name: "fake-network-name", StaticIPs: []string{"10.0.0.11"}}},
+			},
+			{
+				Name:     "fake-counted-job",
+				Networks: []JobNetwork{{Name: "fake-network-name", StaticIPCount: 3}},
+			},
+		}
+
+		err := allocator.Allocate(jobs, networks)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(jobs[0].Networks[0].StaticIPs).To(Equal([]string{"10.0.0.11"}))
+		Expect(jobs[1].Networks[0].StaticIPs).To(Equal([]string{"10.0.0.10", "10.0.0.12", "10.0.0.20"}))
+	})
+
+	It("does not hand out the same IP to two counted jobs", func() {
+		jobs := []Job{
+			{
+				Name:     "fake-job-1",
+				Networks: []JobNetwork{{Name: "fake-network-name", StaticIPCount: 2}},
+			},
+			{
+				Name:     "fake-job-2",
+				Networks: []JobNetwork{{Name: "fake-network-name", StaticIPCount: 2}},
+			},
+		}
+
+		err := allocator.Allocate(jobs, networks)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(jobs[0].Networks[0].StaticIPs).To(Equal([]string{"10.0.0.10", "10.0.0.11"}))
+		Expect(jobs[1].Networks[0].StaticIPs).To(Equal([]string{"10.0.0.12", "10.0.0.20"}))
+	})
+
+	It("returns an error when the static pool is too small", func() {
+		jobs := []Job{
+			{
+				Name:     "fake-job-name",
+				Networks: []JobNetwork{{Name: "fake-network-name", StaticIPCount: 5}},
+			},
+		}
+
+		err := allocator.Allocate(jobs, networks)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Job 'fake-job-name' requires 5 static IPs from network 'fake-network-name' but only 4 are available"))
+	})
+
+	Context("when both static_ips and static_ip_count are specified", func() {
+		It("uses the explicit static_ips when they agree with the count", func() {
+			jobs := []Job{
+				{
+					Name:     "fake-job-name",
+					Networks: []JobNetwork{{Name: "fake-network-name", StaticIPs: []string{"10.0.0.20"}, StaticIPCount: 1}},
+				},
+			}
+
+			err := allocator.Allocate(jobs, networks)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(jobs[0].Networks[0].StaticIPs).To(Equal([]string{"10.0.0.20"}))
+		})
+
+		It("returns an error when they conflict", func() {
+			jobs := []Job{
+				{
+					Name:     "fake-job-name",
+					Networks: []JobNetwork{{Name: "fake-network-name", StaticIPs: []string{"10.0.0.20"}, StaticIPCount: 2}},
+				},
+			}
+
+			err := allocator.Allocate(jobs, networks)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Job 'fake-job-name' network 'fake-network-name' specifies 1 static_ips but static_ip_count is 2"))
+		})
+	})
+
+	It("returns an error when the network does not exist", func() {
+		jobs := []Job{
+			{
+				Name:     "fake-job-name",
+				Networks: []JobNetwork{{Name: "fake-missing-network", StaticIPCount: 1}},
+			},
+		}
+
+		err := allocator.Allocate(jobs, networks)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Could not find network 'fake-missing-network'"))
+	})
+})
@@ -0,0 +1,248 @@
+package manifest
+
+import (
+	"fmt"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"gopkg.in/yaml.v2"
+
+	birelmanifest "github.com/cloudfoundry/bosh-cli/release/manifest"
+	birelsetmanifest "github.com/cloudfoundry/bosh-cli/release/set/manifest"
+)
+
+// ValidatingParser reports every problem found in a deployment manifest in a
+// single pass, without requiring access to releases or infrastructure.
+type ValidatingParser interface {
+	Validate(path string) []error
+}
+
+type releaseNames struct {
+	Releases []struct {
+		Name string
+	}
+}
+
+func NewValidatingParser(fs boshsys.FileSystem, logger boshlog.Logger) ValidatingParser {
+	return &parser{
+		fs:     fs,
+		logger: logger,
+		logTag: "deploymentParser",
+	}
+}
+
+// Validate parses each section of the manifest independently so that a problem
+// in one section does not hide problems in the others, then runs the structural
+// and cross-reference checks of the Validator on whatever could be parsed.
+func (p *parser) Validate(path string) []error {
+	contents, err := p.fs.ReadFile(path)
+	if err != nil {
+		return []error{bosherr.WrapErrorf(err, "Reading deployment manifest '%s'", path)}
+	}
+
+	errs := []error{}
+	validator := NewValidator(p.logger)
+
+	err = validator.ValidateSchema(contents)
+	if multiErr, ok := err.(bosherr.MultiError); ok {
+		errs = append(errs, multiErr.Errors...)
+	} else if err != nil {
+		return []error{err}
+	}
+
+	comboManifest := manifest{}
+
+	err = yaml.Unmarshal(contents, &comboManifest)
+	if err != nil {
+		return append(errs, bosherr.WrapError(err, "Unmarshalling BOSH deployment manifest"))
+	}
+
+	rawReleases := releaseNames{}
+
+	err = yaml.Unmarshal(contents, &rawReleases)
+	if err != nil {
+		return []error{bosherr.WrapError(err, "Unmarshalling BOSH deployment manifest releases")}
+	}
+
+	resolvedManifest, err := p.resolveInstanceGroups(comboManifest)
+	if multiErr, ok := err.(bosherr.MultiError); ok {
+		errs = append(errs, multiErr.Errors...)
+	} else {
+		comboManifest = resolvedManifest
+	}
+
+	deployment := boshDeploymentDefaults
+	deployment.Name = comboManifest.Name
+	deployment.Tags = comboManifest.Tags
+
+	features, err := p.parseFeatures(comboManifest.Features)
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		deployment.Features = features
+	}
+
+	for idx, rawNetwork := range comboManifest.Networks {
+		network, err := p.parseNetworkManifest(rawNetwork)
+		if err != nil {
+			errs = append(errs, bosherr.WrapErrorf(err, "networks[%d]", idx))
+			continue
+		}
+		deployment.Networks = append(deployment.Networks, network)
+	}
+
+	for idx, rawResourcePool := range comboManifest.ResourcePools {
+		resourcePool, err := p.parseResourcePoolManifest(rawResourcePool, path)
+		if err != nil {
+			errs = append(errs, bosherr.WrapErrorf(err, "resource_pools[%d]", idx))
+			continue
+		}
+		deployment.ResourcePools = append(deployment.ResourcePools, resourcePool)
+	}
+
+	diskPoolNames := map[string]bool{}
+	for _, rawDiskPool := range comboManifest.DiskPools {
+		diskPoolNames[rawDiskPool.Name] = true
+	}
+
+	for idx, rawDiskType := range comboManifest.DiskTypes {
+		if diskPoolNames[rawDiskType.Name] {
+			errs = append(errs, bosherr.Errorf("disk_types[%d] '%s' is also declared in disk_pools", idx, rawDiskType.Name))
+		}
+	}
+
+	for _, section := range []struct {
+		name      string
+		diskPools []diskPool
+	}{{"disk_pools", comboManifest.DiskPools}, {"disk_types", comboManifest.DiskTypes}} {
+		for idx, rawDiskPool := range section.diskPools {
+			diskPool, err := p.parseDiskPoolManifest(fmt.Sprintf("%s[%d]", section.name, idx), rawDiskPool)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			deployment.DiskPools = append(deployment.DiskPools, diskPool)
+		}
+	}
+
+	if len(comboManifest.Jobs) > 0 && len(comboManifest.InstanceGroups) > 0 {
+		errs = append(errs, bosherr.Error("Deployment specifies both jobs and instance_groups keys, only one is allowed"))
+	}
+
+	rawJobs := comboManifest.Jobs
+	if len(comboManifest.InstanceGroups) > 0 {
+		rawJobs = comboManifest.InstanceGroups
+	}
+
+	for idx, rawJob := range rawJobs {
+		job, err := p.parseJobManifest(idx, rawJob)
+		if err != nil {
+			errs = append(errs, bosherr.WrapErrorf(err, "jobs[%d] '%s'", idx, rawJob.Name))
+			job = p.placeholderJob(idx, rawJob)
+		}
+		deployment.Jobs = append(deployment.Jobs, job)
+	}
+
+	err = NewStaticIPAllocator().Allocate(deployment.Jobs, deployment.Networks)
+	if err != nil {
+		errs = append(errs, bosherr.WrapError(err, "Allocating static IPs"))
+	}
+
+	err = NewInstanceIPAllocator().Allocate(deployment.Jobs, deployment.Networks)
+	if err != nil {
+		errs = append(errs, bosherr.WrapError(err, "Allocating instance IPs"))
+	}
+
+	properties, err := biproperty.BuildMap(comboManifest.Properties)
+	if err != nil {
+		errs = append(errs, bosherr.WrapError(err, "Parsing global manifest properties"))
+	}
+	deployment.Properties = properties
+
+	if comboManifest.Update.UpdateWatchTime != nil {
+		_, err := NewWatchTime(*comboManifest.Update.UpdateWatchTime)
+		if err != nil {
+			errs = append(errs, bosherr.WrapError(err, "Parsing update watch time"))
+		}
+	}
+
+	if comboManifest.Update.CanaryWatchTime != nil {
+		_, err := NewWatchTime(*comboManifest.Update.CanaryWatchTime)
+		if err != nil {
+			errs = append(errs, bosherr.WrapError(err, "Parsing canary watch time"))
+		}
+	}
+
+	if comboManifest.Update.Canaries != nil && *comboManifest.Update.Canaries < 0 {
+		errs = append(errs, bosherr.Error("update.canaries must be >= 0"))
+	}
+
+	if comboManifest.Update.MaxInFlight != nil && *comboManifest.Update.MaxInFlight < 1 {
+		errs = append(errs, bosherr.Error("update.max_in_flight must be > 0"))
+	}
+
+	_, err = p.parseVMStrategy(comboManifest.Update)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	releaseSetManifest := birelsetmanifest.Manifest{}
+	for _, release := range rawReleases.Releases {
+		releaseSetManifest.Releases = append(releaseSetManifest.Releases, birelmanifest.ReleaseRef{Name: release.Name})
+	}
+
+	err = validator.Validate(deployment, releaseSetManifest)
+	if multiErr, ok := err.(bosherr.MultiError); ok {
+		errs = append(errs, multiErr.Errors...)
+	} else if err != nil {
+		errs = append(errs, err)
+	}
+
+	return p.uniqueErrors(errs)
+}
+
+// uniqueErrors drops errors reported by both the schema and the Validator,
+// such as a missing name.
+func (p *parser) uniqueErrors(errs []error) []error {
+	unique := []error{}
+	seen := map[string]bool{}
+
+	for _, err := range errs {
+		if !seen[err.Error()] {
+			seen[err.Error()] = true
+			unique = append(unique, err)
+		}
+	}
+
+	return unique
+}
+
+// placeholderJob keeps a job that failed to parse in the list, so that indexes
+// in errors reported by the Validator still match the manifest. Only fields
+// that cannot fail to parse are kept.
+func (p *parser) placeholderJob(idx int, rawJob job) Job {
+	rawJob.Instances = 0
+	rawJob.PersistentDisk = 0
+	rawJob.Properties = nil
+
+	if len(rawJob.Templates) > 0 {
+		rawJob.Jobs = nil
+	}
+
+	for i := range rawJob.Templates {
+		rawJob.Templates[i].Properties = nil
+	}
+
+	for i := range rawJob.Jobs {
+		rawJob.Jobs[i].Properties = nil
+	}
+
+	job, err := p.parseJobManifest(idx, rawJob)
+	if err != nil {
+		return Job{Name: rawJob.Name}
+	}
+
+	return job
+}
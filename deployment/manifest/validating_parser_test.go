@@ -0,0 +1,120 @@
+package manifest_test
+
+import (
+	. "github.com/cloudfoundry/bosh-cli/deployment/manifest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("ValidatingParser", func() {
+	var (
+		fakeFs *fakesys.FakeFileSystem
+		parser ValidatingParser
+	)
+
+	BeforeEach(func() {
+		fakeFs = fakesys.NewFakeFileSystem()
+		parser = NewValidatingParser(fakeFs, boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	errorStrings := func(errs []error) []string {
+		strs := []string{}
+		for _, err := range errs {
+			strs = append(strs, err.Error())
+		}
+		return strs
+	}
+
+	It("returns no errors for a valid manifest", func() {
+		fakeFs.WriteFileString("/manifest.yml", `---
+name: fake-deployment-name
+releases:
+- name: fake-release-name
+networks:
+- name: fake-network-name
+  type: manual
+  subnets:
+  - range: 10.0.0.0/24
+    gateway: 10.0.0.1
+resource_pools:
+- name: fake-resource-pool-name
+  network: fake-network-name
+  stemcell:
+    url: file://fake-stemcell-url
+jobs:
+- name: fake-job-name
+  instances: 1
+  resource_pool: fake-resource-pool-name
+  templates:
+  - {name: fake-template-name, release: fake-release-name}
+  networks:
+  - name: fake-network-name
+    static_ips: [10.0.0.10]
+`)
+
+		Expect(parser.Validate("/manifest.yml")).To(BeEmpty())
+	})
+
+	It("reports problems from every section in a single pass", func() {
+		fakeFs.WriteFileString("/manifest.yml", `---
+update:
+  update_watch_time: 5000-1000
+networks:
+- name: fake-bad-network
+  type: fake-type
+- name: fake-network-name
+  type: manual
+  subnets:
+  - range: 10.0.0.0/24
+    gateway: 10.0.0.1
+disk_pools:
+- name: fake-disk-pool-name
+  disk_size: 1073741824
+jobs:
+- name: fake-job-name
+  instances: 10000
+- name: fake-other-job-name
+  instances: 1
+  resource_pool: fake-missing-resource-pool
+  templates:
+  - {name: fake-template-name, release: fake-missing-release}
+  networks:
+  - name: fake-network-name
+    static_ips: [10.0.1.10]
+`)
+
+		errs := errorStrings(parser.Validate("/manifest.yml"))
+		Expect(errs).To(ContainElement("networks[0].type must be 'manual', 'dynamic', or 'vip'"))
+		Expect(errs).To(ContainElement(ContainSubstring("disk_pools[0].disk_size value 1073741824 must be less than 1073741824")))
+		Expect(errs).To(ContainElement(ContainSubstring("jobs[0] 'fake-job-name': jobs[0].instances value 10000 must be less than 10000")))
+		Expect(errs).To(ContainElement(ContainSubstring("Parsing update watch time")))
+		Expect(errs).To(ContainElement("name must be provided"))
+		Expect(errs).To(ContainElement("jobs[1].resource_pool must be the name of a resource pool"))
+		Expect(errs).To(ContainElement("jobs[1].templates[0].release 'fake-missing-release' must refer to release in releases"))
+		Expect(errs).To(ContainElement("jobs[1].networks[0] static ip '10.0.1.10' must be within subnet range"))
+	})
+
+	It("reports unknown keys and mistyped values with their location", func() {
+		fakeFs.WriteFileString("/manifest.yml", `---
+jobs:
+- name: fake-job-name
+  instances: many
+  resource_pol: fake-resource-pool-name
+`)
+
+		errs := errorStrings(parser.Validate("/manifest.yml"))
+		Expect(errs).To(ContainElement("line 4, column 3: jobs[0].instances must be an integer"))
+		Expect(errs).To(ContainElement("line 5, column 3: jobs[0].resource_pol is not a known key"))
+		Expect(errs).To(ContainElement(ContainSubstring("Unmarshalling BOSH deployment manifest")))
+		Expect(errs).To(ContainElement("name must be provided"))
+	})
+
+	It("returns an error when the manifest cannot be read", func() {
+		errs := errorStrings(parser.Validate("/missing.yml"))
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0]).To(ContainSubstring("Reading deployment manifest '/missing.yml'"))
+	})
+})
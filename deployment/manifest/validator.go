@@ -1,6 +1,7 @@
 package manifest
 
 import (
+	"fmt"
 	"net"
 	"regexp"
 	"strings"
@@ -9,22 +10,31 @@ import (
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 
 	binet "github.com/cloudfoundry/bosh-cli/common/net"
+	biutil "github.com/cloudfoundry/bosh-cli/common/util"
 	boshinst "github.com/cloudfoundry/bosh-cli/installation"
+	bireljob "github.com/cloudfoundry/bosh-cli/release/job"
 	birelsetmanifest "github.com/cloudfoundry/bosh-cli/release/set/manifest"
 )
 
 type Validator interface {
 	Validate(Manifest, birelsetmanifest.Manifest) error
 	ValidateReleaseJobs(Manifest, boshinst.ReleaseManager) error
+	ValidateSchema(contents []byte) error
 }
 
 type validator struct {
-	logger boshlog.Logger
+	logger            boshlog.Logger
+	iaasNetworkPolicy IaaSNetworkPolicy
 }
 
 func NewValidator(logger boshlog.Logger) Validator {
+	return NewValidatorWithIaaSNetworkPolicy(logger, NewNoopIaaSNetworkPolicy())
+}
+
+func NewValidatorWithIaaSNetworkPolicy(logger boshlog.Logger, iaasNetworkPolicy IaaSNetworkPolicy) Validator {
 	return &validator{
-		logger: logger,
+		logger:            logger,
+		iaasNetworkPolicy: iaasNetworkPolicy,
 	}
 }
 
@@ -51,14 +61,29 @@ func (v *validator) Validate(deploymentManifest Manifest, releaseSetManifest bir
 			errs = append(errs, bosherr.Errorf("resource_pools[%d].stemcell.url must be provided", idx))
 		}
 
-		matched, err := regexp.MatchString("^(file|http|https)://", resourcePool.Stemcell.URL)
+		matched, err := regexp.MatchString("^(file|http|https|s3|gs)://", resourcePool.Stemcell.URL)
 		if err != nil || !matched {
-			errs = append(errs, bosherr.Errorf("resource_pools[%d].stemcell.url must be a valid URL (file:// or http(s)://)", idx))
+			errs = append(errs, bosherr.Errorf("resource_pools[%d].stemcell.url must be a valid URL (file://, http(s)://, s3:// or gs://)", idx))
 		}
 
 		if strings.HasPrefix(resourcePool.Stemcell.URL, "http") && v.isBlank(resourcePool.Stemcell.SHA1) {
 			errs = append(errs, bosherr.Errorf("resource_pools[%d].stemcell.sha1 must be provided for http URL", idx))
 		}
+
+		if biutil.IsObjectStoreURL(resourcePool.Stemcell.URL) && v.isBlank(resourcePool.Stemcell.SHA1) {
+			errs = append(errs, bosherr.Errorf("resource_pools[%d].stemcell.sha1 must be provided for s3 and gs URLs", idx))
+		}
+
+		if !v.isBlank(resourcePool.Stemcell.Signature.URL) {
+			matched, err := regexp.MatchString("^(file|http|https)://", resourcePool.Stemcell.Signature.URL)
+			if err != nil || !matched {
+				errs = append(errs, bosherr.Errorf("resource_pools[%d].stemcell.signature.url must be a valid URL (file:// or http(s)://)", idx))
+			}
+
+			if v.isBlank(resourcePool.Stemcell.Signature.PublicKey) {
+				errs = append(errs, bosherr.Errorf("resource_pools[%d].stemcell.signature.public_key must be provided", idx))
+			}
+		}
 	}
 
 	for idx, diskPool := range deploymentManifest.DiskPools {
@@ -70,7 +95,7 @@ func (v *validator) Validate(deploymentManifest Manifest, releaseSetManifest bir
 		}
 	}
 
-	if len(deploymentManifest.Jobs) > 1 {
+	if len(deploymentManifest.ServiceJobs()) > 1 {
 		errs = append(errs, bosherr.Error("jobs must be of size 1"))
 	}
 
@@ -86,14 +111,20 @@ func (v *validator) Validate(deploymentManifest Manifest, releaseSetManifest bir
 				errs = append(errs, bosherr.Errorf("jobs[%d].persistent_disk_pool must be the name of a disk pool", idx))
 			}
 		}
+		errs = append(errs, v.validatePersistentDisks(job, deploymentManifest, idx)...)
 		if job.Instances < 0 {
 			errs = append(errs, bosherr.Errorf("jobs[%d].instances must be >= 0", idx))
 		}
-		if len(job.Networks) == 0 {
+		if job.Instances > 1 {
+			errs = append(errs, v.validateInstanceStaticIPs(job, idx)...)
+		}
+		if len(job.Networks) == 0 && !job.IsErrand() {
 			errs = append(errs, bosherr.Errorf("jobs[%d].networks must be a non-empty array", idx))
 		}
 		if v.isBlank(job.ResourcePool) {
-			errs = append(errs, bosherr.Errorf("jobs[%d].resource_pool must be provided", idx))
+			if !job.IsErrand() {
+				errs = append(errs, bosherr.Errorf("jobs[%d].resource_pool must be provided", idx))
+			}
 		} else {
 			if _, ok := v.resourcePoolNames(deploymentManifest)[job.ResourcePool]; !ok {
 				errs = append(errs, bosherr.Errorf("jobs[%d].resource_pool must be the name of a resource pool", idx))
@@ -102,8 +133,8 @@ func (v *validator) Validate(deploymentManifest Manifest, releaseSetManifest bir
 
 		errs = append(errs, v.validateJobNetworks(job.Networks, deploymentManifest.Networks, idx)...)
 
-		if job.Lifecycle != "" && job.Lifecycle != JobLifecycleService {
-			errs = append(errs, bosherr.Errorf("jobs[%d].lifecycle must be 'service' ('%s' not supported)", idx, job.Lifecycle))
+		if job.Lifecycle != "" && job.Lifecycle != JobLifecycleService && job.Lifecycle != JobLifecycleErrand {
+			errs = append(errs, bosherr.Errorf("jobs[%d].lifecycle must be 'service' or 'errand' ('%s' not supported)", idx, job.Lifecycle))
 		}
 
 		templateNames := map[string]struct{}{}
@@ -127,6 +158,14 @@ func (v *validator) Validate(deploymentManifest Manifest, releaseSetManifest bir
 		}
 	}
 
+	errs = append(errs, v.validateStaticIPConflicts(deploymentManifest.Jobs)...)
+	errs = append(errs, v.validateColocatedErrands(deploymentManifest.Jobs)...)
+
+	err := deploymentManifest.ValidateNetworkingForIaaS(v.iaasNetworkPolicy)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
 	if len(errs) > 0 {
 		return bosherr.NewMultiError(errs...)
 	}
@@ -136,6 +175,7 @@ func (v *validator) Validate(deploymentManifest Manifest, releaseSetManifest bir
 
 func (v *validator) ValidateReleaseJobs(deploymentManifest Manifest, releaseManager boshinst.ReleaseManager) error {
 	errs := []error{}
+	releaseJobs := map[string]bireljob.Job{}
 
 	for idx, job := range deploymentManifest.Jobs {
 		for templateIdx, template := range job.Templates {
@@ -143,14 +183,18 @@ func (v *validator) ValidateReleaseJobs(deploymentManifest Manifest, releaseMana
 			if !found {
 				errs = append(errs, bosherr.Errorf("jobs[%d].templates[%d].release '%s' must refer to release in releases", idx, templateIdx, template.Release))
 			} else {
-				_, found := release.FindJobByName(template.Name)
+				releaseJob, found := release.FindJobByName(template.Name)
 				if !found {
 					errs = append(errs, bosherr.Errorf("jobs[%d].templates[%d] must refer to a job in '%s', but there is no job named '%s'", idx, templateIdx, release.Name(), template.Name))
+				} else {
+					releaseJobs[fmt.Sprintf("%d/%d", idx, templateIdx)] = releaseJob
 				}
 			}
 		}
 	}
 
+	errs = append(errs, v.validateLinks(deploymentManifest, releaseJobs)...)
+
 	if len(errs) > 0 {
 		return bosherr.NewMultiError(errs...)
 	}
@@ -158,6 +202,76 @@ func (v *validator) ValidateReleaseJobs(deploymentManifest Manifest, releaseMana
 	return nil
 }
 
+// validateLinks requires every non-optional consumed link to be provided by
+// exactly one release job in the deployment, matched the way ResolveLinks
+// matches them. Consumed links that are never referenced from the job's
+// templates are only logged.
+func (v *validator) validateLinks(deploymentManifest Manifest, releaseJobs map[string]bireljob.Job) []error {
+	errs := []error{}
+	providedLinks := []providedLink{}
+
+	for idx, job := range deploymentManifest.Jobs {
+		for templateIdx, template := range job.Templates {
+			releaseJob, found := releaseJobs[fmt.Sprintf("%d/%d", idx, templateIdx)]
+			if !found {
+				continue
+			}
+
+			providedLinks = append(providedLinks, newProvidedLinks(job, template, releaseJob)...)
+		}
+	}
+
+	for idx, job := range deploymentManifest.Jobs {
+		for templateIdx, template := range job.Templates {
+			releaseJob, found := releaseJobs[fmt.Sprintf("%d/%d", idx, templateIdx)]
+			if !found {
+				continue
+			}
+
+			for _, link := range releaseJob.Consumes {
+				matches := matchProvidedLinks(providedLinks, template, link)
+
+				if len(matches) == 0 && !link.Optional {
+					errs = append(errs, bosherr.Errorf("jobs[%d].templates[%d] '%s' consumes link '%s' of type '%s', but no job in the deployment provides it", idx, templateIdx, releaseJob.Name(), template.ConsumedLinkName(link.Name), link.Type))
+				}
+
+				if len(matches) > 1 {
+					errs = append(errs, bosherr.Errorf("jobs[%d].templates[%d] '%s' consumes link '%s' of type '%s', but multiple jobs provide it (%s), choose one with 'from'", idx, templateIdx, releaseJob.Name(), template.ConsumedLinkName(link.Name), link.Type, linkProviders(matches)))
+				}
+
+				if referenced, scanned := v.templatesReferenceLink(releaseJob, link.Name); scanned && !referenced {
+					v.logger.Warn("validator", "Job '%s' consumes link '%s' but none of its templates reference it", releaseJob.Name(), link.Name)
+				}
+			}
+		}
+	}
+
+	return errs
+}
+
+// templatesReferenceLink scans template sources for link("name") or
+// if_link("name"). The second return value is false when no template source
+// could be read, in which case nothing is known about references.
+func (v *validator) templatesReferenceLink(releaseJob bireljob.Job, linkName string) (bool, bool) {
+	scanned := false
+	pattern := regexp.MustCompile(`(^|[^\w])(if_)?link\(\s*['"]` + regexp.QuoteMeta(linkName) + `['"]`)
+
+	for template := range releaseJob.Templates {
+		contents, err := releaseJob.ReadTemplate(template)
+		if err != nil {
+			continue
+		}
+
+		scanned = true
+
+		if pattern.MatchString(contents) {
+			return true, true
+		}
+	}
+
+	return false, scanned
+}
+
 func (v *validator) isBlank(str string) bool {
 	return str == "" || strings.TrimSpace(str) == ""
 }
@@ -209,14 +323,14 @@ func (in *somethingIpNet) Try(fn func(*net.IPNet) error) error {
 	return fn(in.ipNet)
 }
 
-func (v *validator) validateRange(idx int, ipRange string) ([]error, maybeIPNet) {
+func (v *validator) validateRange(idx, subnetIdx int, ipRange string) ([]error, maybeIPNet) {
 	if v.isBlank(ipRange) {
-		return []error{bosherr.Errorf("networks[%d].subnets[0].range must be provided", idx)}, &nothingIpNet{}
+		return []error{bosherr.Errorf("networks[%d].subnets[%d].range must be provided", idx, subnetIdx)}, &nothingIpNet{}
 	}
 
 	_, ipNet, err := net.ParseCIDR(ipRange)
 	if err != nil {
-		return []error{bosherr.Errorf("networks[%d].subnets[0].range must be an ip range", idx)}, &nothingIpNet{}
+		return []error{bosherr.Errorf("networks[%d].subnets[%d].range must be an ip range", idx, subnetIdx)}, &nothingIpNet{}
 	}
 
 	return []error{}, &somethingIpNet{ipNet: ipNet}
@@ -244,23 +358,126 @@ func (v *validator) validateNetwork(network Network, networkIdx int) []error {
 		errs = append(errs, bosherr.Errorf("networks[%d].type must be 'manual', 'dynamic', or 'vip'", networkIdx))
 	}
 
+	if network.Type == VIP && !v.isBlank(network.IP) {
+		errs = append(errs, bosherr.Errorf("networks[%d].ip must not be declared on network '%s' of type '%s', use the job network's static_ips instead", networkIdx, network.Name, network.Type))
+	}
+
 	if network.Type == Manual {
-		if len(network.Subnets) != 1 {
-			errs = append(errs, bosherr.Errorf("networks[%d].subnets must be of size 1", networkIdx))
-		} else {
-			ipRange := network.Subnets[0].Range
-			rangeErrors, maybeIpNet := v.validateRange(networkIdx, ipRange)
+		if len(network.Subnets) == 0 {
+			errs = append(errs, v.validateFlatSubnet(network, networkIdx)...)
+		}
+
+		ipNets := []*net.IPNet{}
+
+		for subnetIdx, subnet := range network.Subnets {
+			rangeErrors, maybeIpNet := v.validateRange(networkIdx, subnetIdx, subnet.Range)
 			errs = append(errs, rangeErrors...)
 
-			gateway := network.Subnets[0].Gateway
-			gatewayErrors := v.validateGateway(networkIdx, gateway, maybeIpNet)
+			gatewayErrors := v.validateGateway(networkIdx, subnetIdx, subnet.Gateway, maybeIpNet)
 			errs = append(errs, gatewayErrors...)
+
+			staticErrors := v.validateSubnetIPRanges(networkIdx, subnetIdx, "static", subnet.Static, maybeIpNet)
+			errs = append(errs, staticErrors...)
+
+			reservedErrors := v.validateSubnetIPRanges(networkIdx, subnetIdx, "reserved", subnet.Reserved, maybeIpNet)
+			errs = append(errs, reservedErrors...)
+
+			var ipNet *net.IPNet
+			_ = maybeIpNet.Try(func(n *net.IPNet) error {
+				ipNet = n
+				return nil
+			})
+
+			for otherIdx, otherIPNet := range ipNets {
+				if ipNet != nil && otherIPNet != nil && (otherIPNet.Contains(ipNet.IP) || ipNet.Contains(otherIPNet.IP)) {
+					errs = append(errs, bosherr.Errorf("networks[%d].subnets[%d].range overlaps networks[%d].subnets[%d].range", networkIdx, subnetIdx, networkIdx, otherIdx))
+				}
+			}
+			ipNets = append(ipNets, ipNet)
 		}
 	}
 
 	return errs
 }
 
+// validateFlatSubnet reports why a manual network without subnets could not
+// be turned into a subnet from its netmask and gateway.
+func (v *validator) validateFlatSubnet(network Network, networkIdx int) []error {
+	if v.isBlank(network.Netmask) && v.isBlank(network.Gateway) {
+		return []error{bosherr.Errorf("networks[%d].subnets must not be empty, or netmask and gateway must be provided for network '%s' of type '%s'", networkIdx, network.Name, network.Type)}
+	}
+
+	errs := []error{}
+
+	if v.isBlank(network.Netmask) {
+		errs = append(errs, bosherr.Errorf("networks[%d].netmask must be provided for network '%s' of type '%s' without subnets", networkIdx, network.Name, network.Type))
+	}
+
+	if v.isBlank(network.Gateway) {
+		errs = append(errs, bosherr.Errorf("networks[%d].gateway must be provided for network '%s' of type '%s' without subnets", networkIdx, network.Name, network.Type))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	_, err := flatSubnetRange(network.Netmask, network.Gateway)
+	if err != nil {
+		return []error{bosherr.Errorf("networks[%d].%s for network '%s' of type '%s'", networkIdx, err.Error(), network.Name, network.Type)}
+	}
+
+	return errs
+}
+
+// validateInstanceStaticIPs makes sure every instance of a job with several
+// instances gets its own static IP, which its agent is reached on.
+func (v *validator) validatePersistentDisks(job Job, deploymentManifest Manifest, jobIdx int) []error {
+	errs := []error{}
+
+	if len(job.PersistentDisks) > 0 && (job.PersistentDisk > 0 || job.PersistentDiskPool != "") {
+		errs = append(errs, bosherr.Errorf("jobs[%d].persistent_disks cannot be combined with persistent_disk or persistent_disk_pool", jobIdx))
+	}
+
+	diskNames := map[string]struct{}{}
+	for diskIdx, persistentDisk := range job.PersistentDisks {
+		if v.isBlank(persistentDisk.Name) {
+			errs = append(errs, bosherr.Errorf("jobs[%d].persistent_disks[%d].name must be provided", jobIdx, diskIdx))
+		} else if _, found := diskNames[persistentDisk.Name]; found {
+			errs = append(errs, bosherr.Errorf("jobs[%d].persistent_disks[%d].name '%s' must be unique", jobIdx, diskIdx, persistentDisk.Name))
+		}
+		diskNames[persistentDisk.Name] = struct{}{}
+
+		if _, ok := v.diskPoolNames(deploymentManifest)[persistentDisk.DiskPool]; !ok {
+			errs = append(errs, bosherr.Errorf("jobs[%d].persistent_disks[%d].disk_pool must be the name of a disk pool", jobIdx, diskIdx))
+		}
+	}
+
+	return errs
+}
+
+func (v *validator) validateInstanceStaticIPs(job Job, jobIdx int) []error {
+	errs := []error{}
+	withStaticIPs := false
+
+	for networkIdx, jobNetwork := range job.Networks {
+		if len(jobNetwork.StaticIPs) == 0 {
+			continue
+		}
+
+		withStaticIPs = true
+
+		if len(jobNetwork.StaticIPs) < job.Instances {
+			errs = append(errs, bosherr.Errorf("jobs[%d].networks[%d].static_ips must have an IP for each of the %d instances", jobIdx, networkIdx, job.Instances))
+		}
+	}
+
+	if !withStaticIPs {
+		errs = append(errs, bosherr.Errorf("jobs[%d] with %d instances must have static IPs on at least one network", jobIdx, job.Instances))
+	}
+
+	return errs
+}
+
 func (v *validator) validateJobNetworks(jobNetworks []JobNetwork, networks []Network, jobIdx int) []error {
 	errs := []error{}
 	defaultCounts := make(map[NetworkDefault]int)
@@ -327,11 +544,28 @@ func (v *validator) validateStaticIP(ip string, jobNetwork JobNetwork, network N
 		return []error{}
 	}
 
+	parsedIP := net.ParseIP(ip)
 	foundInSubnetRange := false
 	for _, subnet := range network.Subnets {
 		_, rangeNet, err := net.ParseCIDR(subnet.Range)
-		if err == nil && rangeNet.Contains(net.ParseIP(ip)) {
+		if err != nil {
+			continue
+		}
+
+		if isIPv4(rangeNet.IP) != isIPv4(parsedIP) {
+			return []error{bosherr.Errorf("jobs[%d].networks[%d] static ip '%s' must be of the same IP version as the subnet range", jobIdx, networkIdx, ip)}
+		}
+
+		if rangeNet.Contains(parsedIP) {
 			foundInSubnetRange = true
+
+			if v.inIPRanges(parsedIP, subnet.Reserved) {
+				return []error{bosherr.Errorf("jobs[%d].networks[%d] static ip '%s' must not be reserved", jobIdx, networkIdx, ip)}
+			}
+
+			if len(subnet.Static) > 0 && !v.inIPRanges(parsedIP, subnet.Static) {
+				return []error{bosherr.Errorf("jobs[%d].networks[%d] static ip '%s' must be within the static ranges of subnet '%s'", jobIdx, networkIdx, ip, subnet.Range)}
+			}
 		}
 	}
 
@@ -342,9 +576,9 @@ func (v *validator) validateStaticIP(ip string, jobNetwork JobNetwork, network N
 	return []error{bosherr.Errorf("jobs[%d].networks[%d] static ip '%s' must be within subnet range", jobIdx, networkIdx, ip)}
 }
 
-func (v *validator) validateGateway(idx int, gateway string, ipNet maybeIPNet) []error {
+func (v *validator) validateGateway(idx, subnetIdx int, gateway string, ipNet maybeIPNet) []error {
 	if v.isBlank(gateway) {
-		return []error{bosherr.Errorf("networks[%d].subnets[0].gateway must be provided", idx)}
+		return []error{bosherr.Errorf("networks[%d].subnets[%d].gateway must be provided", idx, subnetIdx)}
 	}
 
 	errors := []error{}
@@ -352,7 +586,12 @@ func (v *validator) validateGateway(idx int, gateway string, ipNet maybeIPNet) [
 	_ = ipNet.Try(func(ipNet *net.IPNet) error {
 		gatewayIp := net.ParseIP(gateway)
 		if gatewayIp == nil {
-			errors = append(errors, bosherr.Errorf("networks[%d].subnets[0].gateway must be an ip", idx))
+			errors = append(errors, bosherr.Errorf("networks[%d].subnets[%d].gateway must be an ip", idx, subnetIdx))
+		}
+
+		if gatewayIp != nil && isIPv4(gatewayIp) != isIPv4(ipNet.IP) {
+			errors = append(errors, bosherr.Errorf("networks[%d].subnets[%d].gateway must be of the same IP version as the range", idx, subnetIdx))
+			return nil
 		}
 
 		if !ipNet.Contains(gatewayIp) {
@@ -363,7 +602,8 @@ func (v *validator) validateGateway(idx int, gateway string, ipNet maybeIPNet) [
 			errors = append(errors, bosherr.Errorf("subnet gateway can't be the network address '%s'", gatewayIp))
 		}
 
-		if binet.LastAddress(ipNet).Equal(gatewayIp) {
+		// IPv6 has no broadcast address, the last address of the range is usable
+		if isIPv4(ipNet.IP) && binet.LastAddress(ipNet).Equal(gatewayIp) {
 			errors = append(errors, bosherr.Errorf("subnet gateway can't be the broadcast address '%s'", gatewayIp))
 		}
 
@@ -372,3 +612,102 @@ func (v *validator) validateGateway(idx int, gateway string, ipNet maybeIPNet) [
 
 	return errors
 }
+
+// validateSubnetIPRanges checks the static or reserved entries of a subnet,
+// each an ip or an ip range, named by key in the errors.
+func (v *validator) validateSubnetIPRanges(idx, subnetIdx int, key string, ipRanges []string, ipNet maybeIPNet) []error {
+	errors := []error{}
+
+	for rangeIdx, ipRange := range ipRanges {
+		first, last, err := binet.ParseIPRangeBounds(ipRange)
+		if err != nil {
+			errors = append(errors, bosherr.Errorf("networks[%d].subnets[%d].%s[%d] must be an ip or an ip range", idx, subnetIdx, key, rangeIdx))
+			continue
+		}
+
+		_ = ipNet.Try(func(ipNet *net.IPNet) error {
+			if isIPv4(first) != isIPv4(ipNet.IP) {
+				errors = append(errors, bosherr.Errorf("networks[%d].subnets[%d].%s[%d] must be of the same IP version as the range", idx, subnetIdx, key, rangeIdx))
+			} else if !ipNet.Contains(first) || !ipNet.Contains(last) {
+				errors = append(errors, bosherr.Errorf("networks[%d].subnets[%d].%s[%d] '%s' must be within the range '%s'", idx, subnetIdx, key, rangeIdx, ipRange, ipNet))
+			}
+			return nil
+		})
+	}
+
+	return errors
+}
+
+func (v *validator) inIPRanges(ip net.IP, ipRanges []string) bool {
+	for _, ipRange := range ipRanges {
+		first, last, err := binet.ParseIPRangeBounds(ipRange)
+		if err == nil && binet.InRange(ip, first, last) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateStaticIPConflicts makes sure no static IP is given to more than one
+// instance, whether of the same job or of different jobs.
+func (v *validator) validateStaticIPConflicts(jobs []Job) []error {
+	errs := []error{}
+	assigned := map[string]string{}
+
+	for jobIdx, job := range jobs {
+		for networkIdx, jobNetwork := range job.Networks {
+			for ipIdx, ip := range jobNetwork.StaticIPs {
+				parsedIP := net.ParseIP(ip)
+				if parsedIP == nil {
+					continue
+				}
+
+				path := fmt.Sprintf("jobs[%d].networks[%d].static_ips[%d]", jobIdx, networkIdx, ipIdx)
+				if firstPath, found := assigned[parsedIP.String()]; found {
+					errs = append(errs, bosherr.Errorf("%s '%s' is already assigned to %s", path, ip, firstPath))
+					continue
+				}
+				assigned[parsedIP.String()] = path
+			}
+		}
+	}
+
+	return errs
+}
+
+// validateColocatedErrands requires the release jobs of errands to be named
+// differently from the release jobs of other jobs, because errands are
+// installed on the same instance.
+func (v *validator) validateColocatedErrands(jobs []Job) []error {
+	errs := []error{}
+	owners := map[string]string{}
+
+	for _, job := range jobs {
+		if job.IsErrand() {
+			continue
+		}
+		for _, template := range job.Templates {
+			owners[template.Name] = job.Name
+		}
+	}
+
+	for idx, job := range jobs {
+		if !job.IsErrand() {
+			continue
+		}
+		for templateIdx, template := range job.Templates {
+			if owner, found := owners[template.Name]; found {
+				errs = append(errs, bosherr.Errorf("jobs[%d].templates[%d].name '%s' is already used by job '%s'", idx, templateIdx, template.Name, owner))
+				continue
+			}
+			owners[template.Name] = job.Name
+		}
+	}
+
+	return errs
+}
+
+// isIPv4 tells IPv4 from IPv6 addresses, also when an IPv4 address is held in 16 bytes.
+func isIPv4(ip net.IP) bool {
+	return ip.To4() != nil
+}
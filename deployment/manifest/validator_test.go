@@ -1,8 +1,11 @@
 package manifest_test
 
 import (
+	"bytes"
+
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	biproperty "github.com/cloudfoundry/bosh-utils/property"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -230,7 +233,7 @@ var _ = Describe("Validator", func() {
 
 			err = validator.Validate(deploymentManifest, validReleaseSetManifest)
 			Expect(err).To(HaveOccurred())
-			Expect(err.Error()).To(ContainSubstring("resource_pools[0].stemcell.url must be a valid URL (file:// or http(s)://)"))
+			Expect(err.Error()).To(ContainSubstring("resource_pools[0].stemcell.url must be a valid URL (file://, http(s)://, s3:// or gs://)"))
 
 			deploymentManifest = Manifest{
 				ResourcePools: []ResourcePool{
@@ -245,6 +248,39 @@ var _ = Describe("Validator", func() {
 			err = validator.Validate(deploymentManifest, validReleaseSetManifest)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("resource_pools[0].stemcell.sha1 must be provided for http URL"))
+
+			deploymentManifest = Manifest{
+				ResourcePools: []ResourcePool{
+					{
+						Stemcell: StemcellRef{
+							URL: "s3://fake-bucket/fake-stemcell.tgz",
+						},
+					},
+				},
+			}
+
+			err = validator.Validate(deploymentManifest, validReleaseSetManifest)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).ToNot(ContainSubstring("stemcell.url must be a valid URL"))
+			Expect(err.Error()).To(ContainSubstring("resource_pools[0].stemcell.sha1 must be provided for s3 and gs URLs"))
+		})
+
+		It("validates stemcell signatures have a valid url and a public key", func() {
+			deploymentManifest := Manifest{
+				ResourcePools: []ResourcePool{
+					{
+						Stemcell: StemcellRef{
+							URL:       "file://fake-stemcell",
+							Signature: birelmanifest.SignatureRef{URL: "invalid-url"},
+						},
+					},
+				},
+			}
+
+			err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("resource_pools[0].stemcell.signature.url must be a valid URL (file:// or http(s)://)"))
+			Expect(err.Error()).To(ContainSubstring("resource_pools[0].stemcell.signature.public_key must be provided"))
 		})
 
 		It("validates disk pool name", func() {
@@ -360,8 +396,19 @@ var _ = Describe("Validator", func() {
 				Expect(err.Error()).ToNot(ContainSubstring(typeError))
 			})
 
+			It("validates that vip networks do not declare an ip", func() {
+				err := validator.Validate(Manifest{
+					Networks: []Network{
+						{Name: "fake-vip-name", Type: "vip", IP: "1.2.3.4"},
+					},
+				},
+					validReleaseSetManifest)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("networks[0].ip must not be declared on network 'fake-vip-name' of type 'vip', use the job network's static_ips instead"))
+			})
+
 			Context("manual networks", func() {
-				It("validates that there is exactly 1 subnet", func() {
+				It("validates that there is at least 1 subnet", func() {
 					deploymentManifest := Manifest{
 						Networks: []Network{
 							{
@@ -373,7 +420,186 @@ var _ = Describe("Validator", func() {
 
 					err := validator.Validate(deploymentManifest, validReleaseSetManifest)
 					Expect(err).To(HaveOccurred())
-					Expect(err.Error()).To(ContainSubstring("networks[0].subnets must be of size 1"))
+					Expect(err.Error()).To(ContainSubstring("networks[0].subnets must not be empty"))
+				})
+
+				It("validates the netmask and gateway of a network without subnets", func() {
+					deploymentManifest := Manifest{
+						Networks: []Network{
+							{Name: "fake-no-gateway", Type: "manual", Netmask: "255.255.255.0"},
+							{Name: "fake-no-netmask", Type: "manual", Gateway: "10.0.0.1"},
+							{Name: "fake-mixed", Type: "manual", Netmask: "255.255.255.0", Gateway: "2001:db8:1::1"},
+							{Name: "fake-holes", Type: "manual", Netmask: "255.0.255.0", Gateway: "10.0.0.1"},
+						},
+					}
+
+					err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("networks[0].gateway must be provided for network 'fake-no-gateway' of type 'manual' without subnets"))
+					Expect(err.Error()).To(ContainSubstring("networks[1].netmask must be provided for network 'fake-no-netmask' of type 'manual' without subnets"))
+					Expect(err.Error()).To(ContainSubstring("networks[2].netmask and gateway must be IPs of the same IP version for network 'fake-mixed' of type 'manual'"))
+					Expect(err.Error()).To(ContainSubstring("networks[3].netmask must be contiguous for network 'fake-holes' of type 'manual'"))
+				})
+
+				It("validates every subnet", func() {
+					deploymentManifest := Manifest{
+						Networks: []Network{
+							{
+								Type: "manual",
+								Subnets: []Subnet{
+									{Range: "10.10.0.0/24", Gateway: "10.10.0.1"},
+									{Range: "10.10.1.0/24"},
+								},
+							},
+						},
+					}
+
+					err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("networks[0].subnets[1].gateway must be provided"))
+					Expect(err.Error()).ToNot(ContainSubstring("networks[0].subnets[0]"))
+				})
+
+				It("validates that subnet ranges do not overlap", func() {
+					deploymentManifest := Manifest{
+						Networks: []Network{
+							{
+								Type: "manual",
+								Subnets: []Subnet{
+									{Range: "10.10.0.0/16", Gateway: "10.10.0.1"},
+									{Range: "10.10.1.0/24", Gateway: "10.10.1.1"},
+								},
+							},
+						},
+					}
+
+					err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("networks[0].subnets[1].range overlaps networks[0].subnets[0].range"))
+				})
+
+				It("validates that reserved and static ranges are within the range", func() {
+					deploymentManifest := Manifest{
+						Networks: []Network{
+							{
+								Type: "manual",
+								Subnets: []Subnet{
+									{
+										Range:    "10.10.0.0/24",
+										Gateway:  "10.10.0.1",
+										Reserved: []string{"10.10.0.2 - 10.10.1.10"},
+										Static:   []string{"not-an-ip"},
+									},
+								},
+							},
+						},
+					}
+
+					err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("networks[0].subnets[0].reserved[0] '10.10.0.2 - 10.10.1.10' must be within the range '10.10.0.0/24'"))
+					Expect(err.Error()).To(ContainSubstring("networks[0].subnets[0].static[0] must be an ip or an ip range"))
+				})
+
+				It("validates that static IPs are within the static ranges of the subnet when it has some", func() {
+					deploymentManifest := Manifest{
+						Networks: []Network{
+							{
+								Name: "fake-network-name",
+								Type: "manual",
+								Subnets: []Subnet{
+									{
+										Range:   "10.10.0.0/24",
+										Gateway: "10.10.0.1",
+										Static:  []string{"10.10.0.10 - 10.10.0.20"},
+									},
+								},
+							},
+						},
+						Jobs: []Job{
+							{
+								Networks: []JobNetwork{
+									{
+										Name:      "fake-network-name",
+										StaticIPs: []string{"10.10.0.15", "10.10.0.30"},
+									},
+								},
+							},
+						},
+					}
+
+					err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("jobs[0].networks[0] static ip '10.10.0.30' must be within the static ranges of subnet '10.10.0.0/24'"))
+					Expect(err.Error()).ToNot(ContainSubstring("'10.10.0.15'"))
+				})
+
+				It("validates that a static IP is not assigned more than once", func() {
+					deploymentManifest := Manifest{
+						Networks: []Network{
+							{
+								Name:    "fake-network-name",
+								Type:    "manual",
+								Subnets: []Subnet{{Range: "10.10.0.0/24", Gateway: "10.10.0.1"}},
+							},
+						},
+						Jobs: []Job{
+							{
+								Networks: []JobNetwork{
+									{
+										Name:      "fake-network-name",
+										StaticIPs: []string{"10.10.0.5", "10.10.0.5"},
+									},
+								},
+							},
+							{
+								Networks: []JobNetwork{
+									{
+										Name:      "fake-network-name",
+										StaticIPs: []string{"10.10.0.6", "10.10.0.5"},
+									},
+								},
+							},
+						},
+					}
+
+					err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("jobs[0].networks[0].static_ips[1] '10.10.0.5' is already assigned to jobs[0].networks[0].static_ips[0]"))
+					Expect(err.Error()).To(ContainSubstring("jobs[1].networks[0].static_ips[1] '10.10.0.5' is already assigned to jobs[0].networks[0].static_ips[0]"))
+					Expect(err.Error()).ToNot(ContainSubstring("'10.10.0.6' is already assigned"))
+				})
+
+				It("validates that static IPs are not reserved", func() {
+					deploymentManifest := Manifest{
+						Networks: []Network{
+							{
+								Name: "fake-network-name",
+								Type: "manual",
+								Subnets: []Subnet{
+									{
+										Range:    "10.10.0.0/24",
+										Gateway:  "10.10.0.1",
+										Reserved: []string{"10.10.0.2 - 10.10.0.10"},
+									},
+								},
+							},
+						},
+						Jobs: []Job{
+							{
+								Networks: []JobNetwork{
+									{
+										Name:      "fake-network-name",
+										StaticIPs: []string{"10.10.0.5"},
+									},
+								},
+							},
+						},
+					}
+
+					err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("jobs[0].networks[0] static ip '10.10.0.5' must not be reserved"))
 				})
 
 				It("validates that range is present", func() {
@@ -519,6 +745,55 @@ var _ = Describe("Validator", func() {
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(ContainSubstring("subnet gateway can't be the broadcast address '10.10.0.255'"))
 				})
+
+				Context("when the range is IPv6", func() {
+					It("allows the last ip in the range as the gateway", func() {
+						err := validator.Validate(Manifest{
+							Networks: []Network{
+								{
+									Type: "manual",
+									Subnets: []Subnet{{
+										Range:   "2001:db8::/120",
+										Gateway: "2001:db8::ff",
+									}},
+								},
+							},
+						}, validReleaseSetManifest)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).ToNot(ContainSubstring("subnet gateway"))
+					})
+
+					It("validates that the gateway, static ranges and static ips are IPv6", func() {
+						err := validator.Validate(Manifest{
+							Networks: []Network{
+								{
+									Name: "fake-network-name",
+									Type: "manual",
+									Subnets: []Subnet{{
+										Range:   "2001:db8::/64",
+										Gateway: "10.10.0.1",
+										Static:  []string{"2001:db8::10 - 2001:db8::20", "10.10.0.10"},
+									}},
+								},
+							},
+							Jobs: []Job{
+								{
+									Networks: []JobNetwork{
+										{
+											Name:      "fake-network-name",
+											StaticIPs: []string{"10.10.0.10"},
+										},
+									},
+								},
+							},
+						}, validReleaseSetManifest)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("networks[0].subnets[0].gateway must be of the same IP version as the range"))
+						Expect(err.Error()).To(ContainSubstring("networks[0].subnets[0].static[1] must be of the same IP version as the range"))
+						Expect(err.Error()).ToNot(ContainSubstring("networks[0].subnets[0].static[0]"))
+						Expect(err.Error()).To(ContainSubstring("jobs[0].networks[0] static ip '10.10.0.10' must be of the same IP version as the subnet range"))
+					})
+				})
 			})
 
 			Context("dynamic networks", func() {
@@ -647,6 +922,34 @@ var _ = Describe("Validator", func() {
 			Expect(err.Error()).To(ContainSubstring("jobs[0].persistent_disk_pool must be the name of a disk pool"))
 		})
 
+		It("validates job persistent_disks", func() {
+			deploymentManifest := Manifest{
+				Jobs: []Job{
+					{
+						PersistentDisk: 1024,
+						PersistentDisks: []JobPersistentDisk{
+							{Name: "fake-disk-name", DiskPool: "fake-disk-pool"},
+							{Name: "fake-disk-name", DiskPool: "fake-disk-pool"},
+							{Name: "", DiskPool: "non-existent-disk-pool"},
+						},
+					},
+				},
+				DiskPools: []DiskPool{
+					{
+						Name: "fake-disk-pool",
+					},
+				},
+			}
+
+			err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("jobs[0].persistent_disks cannot be combined with persistent_disk or persistent_disk_pool"))
+			Expect(err.Error()).To(ContainSubstring("jobs[0].persistent_disks[1].name 'fake-disk-name' must be unique"))
+			Expect(err.Error()).To(ContainSubstring("jobs[0].persistent_disks[2].name must be provided"))
+			Expect(err.Error()).To(ContainSubstring("jobs[0].persistent_disks[2].disk_pool must be the name of a disk pool"))
+			Expect(err.Error()).ToNot(ContainSubstring("jobs[0].persistent_disks[0]"))
+		})
+
 		It("validates job resource pool is provided", func() {
 			deploymentManifest := Manifest{
 				Jobs: []Job{{}},
@@ -690,6 +993,42 @@ var _ = Describe("Validator", func() {
 			Expect(err.Error()).To(ContainSubstring("jobs[0].instances must be >= 0"))
 		})
 
+		Context("when a job has more than one instance", func() {
+			It("validates that every instance has a static IP on a network", func() {
+				deploymentManifest := Manifest{
+					Jobs: []Job{
+						{
+							Instances: 2,
+							Networks: []JobNetwork{
+								{Name: "fake-network-name", StaticIPs: []string{"10.10.0.42"}},
+							},
+						},
+					},
+				}
+
+				err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("jobs[0].networks[0].static_ips must have an IP for each of the 2 instances"))
+			})
+
+			It("validates that at least one network has static IPs", func() {
+				deploymentManifest := Manifest{
+					Jobs: []Job{
+						{
+							Instances: 2,
+							Networks: []JobNetwork{
+								{Name: "fake-network-name"},
+							},
+						},
+					},
+				}
+
+				err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("jobs[0] with 2 instances must have static IPs on at least one network"))
+			})
+		})
+
 		Describe("job networks", func() {
 			It("validates job networks", func() {
 				deploymentManifest := Manifest{
@@ -934,14 +1273,43 @@ var _ = Describe("Validator", func() {
 			deploymentManifest := Manifest{
 				Jobs: []Job{
 					{
-						Lifecycle: "errand",
+						Lifecycle: "batch",
 					},
 				},
 			}
 
 			err := validator.Validate(deploymentManifest, validReleaseSetManifest)
 			Expect(err).To(HaveOccurred())
-			Expect(err.Error()).To(ContainSubstring("jobs[0].lifecycle must be 'service' ('errand' not supported)"))
+			Expect(err.Error()).To(ContainSubstring("jobs[0].lifecycle must be 'service' or 'errand' ('batch' not supported)"))
+		})
+
+		Context("when the manifest has errands", func() {
+			var deploymentManifest Manifest
+
+			BeforeEach(func() {
+				deploymentManifest = validManifest
+				deploymentManifest.Jobs = append([]Job{}, validManifest.Jobs...)
+				deploymentManifest.Jobs = append(deploymentManifest.Jobs, Job{
+					Name:      "smoke-tests",
+					Lifecycle: JobLifecycleErrand,
+					Templates: []ReleaseJobRef{
+						{Name: "smoke-tests", Release: "fake-release-name"},
+					},
+				})
+			})
+
+			It("allows errands without networks or resource pool next to the job", func() {
+				err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("validates that errand release jobs are not used by the job", func() {
+				deploymentManifest.Jobs[1].Templates[0].Name = deploymentManifest.Jobs[0].Templates[0].Name
+
+				err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("jobs[1].templates[0].name 'fake-job-name' is already used by job 'fake-job-name'"))
+			})
 		})
 
 		It("permits job templates to reference an undeclared release", func() {
@@ -1034,5 +1402,125 @@ var _ = Describe("Validator", func() {
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("jobs[0].templates[0] must refer to a job in 'fake-release-name', but there is no job named 'fake-other-job-name'"))
 		})
+
+		Context("when release jobs declare links", func() {
+			var (
+				fs                 *fakesys.FakeFileSystem
+				logBuffer          *bytes.Buffer
+				deploymentManifest Manifest
+				consumer           *boshjob.Job
+				provider           *boshjob.Job
+			)
+
+			BeforeEach(func() {
+				fs = fakesys.NewFakeFileSystem()
+				logBuffer = bytes.NewBufferString("")
+				validator = NewValidator(boshlog.NewWriterLogger(boshlog.LevelWarn, logBuffer, logBuffer))
+
+				deploymentManifest = validManifest
+				deploymentManifest.Jobs[0].Templates = []ReleaseJobRef{
+					{Name: "consumer", Release: "fake-release-name"},
+					{Name: "provider", Release: "fake-release-name"},
+				}
+
+				consumer = boshjob.NewExtractedJob(NewResource("consumer", "", nil), "/consumer", fs)
+				consumer.Templates = map[string]string{"config.erb": "config"}
+				consumer.Consumes = []boshjob.LinkDefinition{{Name: "db", Type: "database"}}
+
+				provider = boshjob.NewJob(NewResource("provider", "", nil))
+
+				release.FindJobByNameStub = func(name string) (boshjob.Job, bool) {
+					for _, job := range []*boshjob.Job{consumer, provider} {
+						if job.Name() == name {
+							return *job, true
+						}
+					}
+					return boshjob.Job{}, false
+				}
+			})
+
+			It("accepts consumed links that are provided by name", func() {
+				provider.Provides = []boshjob.LinkDefinition{{Name: "db", Type: "other"}}
+				fs.WriteFileString("/consumer/templates/config.erb", `<%= link("db").address %>`)
+
+				err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(logBuffer.String()).To(BeEmpty())
+			})
+
+			It("accepts consumed links that are provided by type", func() {
+				provider.Provides = []boshjob.LinkDefinition{{Name: "primary-db", Type: "database"}}
+				fs.WriteFileString("/consumer/templates/config.erb", `<% if_link("db") do |db| %><% end %>`)
+
+				err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(logBuffer.String()).To(BeEmpty())
+			})
+
+			It("returns an error naming the job and link when no job provides a consumed link", func() {
+				fs.WriteFileString("/consumer/templates/config.erb", `<%= link("db").address %>`)
+
+				err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("jobs[0].templates[0] 'consumer' consumes link 'db' of type 'database', but no job in the deployment provides it"))
+			})
+
+			It("matches links by the names given with 'as' and 'from'", func() {
+				provider.Provides = []boshjob.LinkDefinition{{Name: "db", Type: "other"}}
+				deploymentManifest.Jobs[0].Templates[0].Consumes = map[string]string{"db": "primary-db"}
+				deploymentManifest.Jobs[0].Templates[1].Provides = map[string]string{"db": "primary-db"}
+				fs.WriteFileString("/consumer/templates/config.erb", `<%= link("db").address %>`)
+
+				err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("does not match links by type when 'from' is given", func() {
+				provider.Provides = []boshjob.LinkDefinition{{Name: "db", Type: "database"}}
+				deploymentManifest.Jobs[0].Templates[0].Consumes = map[string]string{"db": "other-db"}
+				fs.WriteFileString("/consumer/templates/config.erb", `<%= link("db").address %>`)
+
+				err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("jobs[0].templates[0] 'consumer' consumes link 'other-db' of type 'database', but no job in the deployment provides it"))
+			})
+
+			It("returns an error naming the providers when multiple jobs provide a consumed link by type", func() {
+				provider.Provides = []boshjob.LinkDefinition{
+					{Name: "primary-db", Type: "database"},
+					{Name: "secondary-db", Type: "database"},
+				}
+				fs.WriteFileString("/consumer/templates/config.erb", `<%= link("db").address %>`)
+
+				err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("jobs[0].templates[0] 'consumer' consumes link 'db' of type 'database', but multiple jobs provide it (fake-job-name/provider, fake-job-name/provider), choose one with 'from'"))
+			})
+
+			It("does not require optional links to be provided", func() {
+				consumer.Consumes[0].Optional = true
+				fs.WriteFileString("/consumer/templates/config.erb", `<% if_link("db") do |db| %><% end %>`)
+
+				err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("warns when consumed links are never referenced from templates", func() {
+				provider.Provides = []boshjob.LinkDefinition{{Name: "db", Type: "database"}}
+				fs.WriteFileString("/consumer/templates/config.erb", `<%= p("db.address") %>`)
+
+				err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(logBuffer.String()).To(ContainSubstring("Job 'consumer' consumes link 'db' but none of its templates reference it"))
+			})
+
+			It("does not warn when template sources are not available", func() {
+				provider.Provides = []boshjob.LinkDefinition{{Name: "db", Type: "database"}}
+
+				err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(logBuffer.String()).To(BeEmpty())
+			})
+		})
 	})
 })
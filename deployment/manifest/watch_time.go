@@ -1,6 +1,7 @@
 package manifest
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -40,3 +41,9 @@ func NewWatchTime(timeRange string) (WatchTime, error) {
 		End:   end,
 	}, nil
 }
+
+// String returns the watch time as it is written in manifests, e.g.
+// 0-300000.
+func (w WatchTime) String() string {
+	return fmt.Sprintf("%d-%d", w.Start, w.End)
+}
@@ -0,0 +1,231 @@
+package deployment
+
+import (
+	"fmt"
+	"reflect"
+
+	biconfig "github.com/cloudfoundry/bosh-cli/config"
+	bideplmanifest "github.com/cloudfoundry/bosh-cli/deployment/manifest"
+	bistemcell "github.com/cloudfoundry/bosh-cli/stemcell"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// PlannedCall is a CPI call that deploying would make.
+type PlannedCall struct {
+	Method string
+	Reason string
+}
+
+func (c PlannedCall) String() string {
+	return fmt.Sprintf("%s: %s", c.Method, c.Reason)
+}
+
+// plannedInstance is what the deployment state records of an instance.
+type plannedInstance struct {
+	index        int
+	vmCID        string
+	diskID       string
+	namedDiskIDs map[string]string
+}
+
+// Plan lists, in order, the CPI calls that deploying the manifest with the
+// stemcell would make against the current deployment state. It mirrors the
+// decisions taken by the Deployer and the stemcell and disk managers, without
+// talking to the CPI. skipDiskMigration is the --skip-disk-migration option
+// of the deploy.
+func Plan(deploymentManifest bideplmanifest.Manifest, deploymentState biconfig.DeploymentState, stemcellManifest bistemcell.Manifest, skipDiskMigration bool) ([]PlannedCall, error) {
+	calls := []PlannedCall{}
+	stemcellName := fmt.Sprintf("%s/%s", stemcellManifest.Name, stemcellManifest.Version)
+
+	var currentStemcell *biconfig.StemcellRecord
+	for i, stemcellRecord := range deploymentState.Stemcells {
+		if stemcellRecord.Name == stemcellManifest.Name && stemcellRecord.Version == stemcellManifest.Version {
+			currentStemcell = &deploymentState.Stemcells[i]
+		}
+	}
+
+	if currentStemcell == nil {
+		calls = append(calls, PlannedCall{Method: "create_stemcell", Reason: fmt.Sprintf("Stemcell '%s' has not been uploaded", stemcellName)})
+	}
+
+	jobName := deploymentManifest.JobName()
+
+	job, found := deploymentManifest.FindJobByName(jobName)
+	if !found {
+		return calls, bosherr.Errorf("Could not find job '%s'", jobName)
+	}
+
+	resourcePool, err := deploymentManifest.ResourcePool(jobName)
+	if err != nil {
+		return calls, bosherr.WrapError(err, "Finding resource pool")
+	}
+
+	diskPool, err := deploymentManifest.DiskPool(jobName)
+	if err != nil {
+		return calls, bosherr.WrapError(err, "Finding disk pool")
+	}
+
+	namedDisks, err := deploymentManifest.NamedDisks(jobName)
+	if err != nil {
+		return calls, bosherr.WrapError(err, "Finding named disks")
+	}
+
+	for _, instanceRecord := range deploymentState.Instances {
+		if instanceRecord.Index >= job.Instances && instanceRecord.VMCID != "" {
+			calls = append(calls, PlannedCall{Method: "delete_vm", Reason: fmt.Sprintf("Job '%s' instance %d is no longer in the manifest", jobName, instanceRecord.Index)})
+		}
+	}
+
+	instances := []plannedInstance{}
+	for index := 0; index < job.Instances; index++ {
+		instances = append(instances, planInstance(deploymentState, index))
+	}
+
+	swapVMs := deploymentManifest.VMStrategy(jobName) == bideplmanifest.VMStrategyCreateSwapDelete
+
+	// with delete-create, the current VMs of all instances are deleted first
+	if !swapVMs {
+		for _, instance := range instances {
+			if instance.vmCID != "" {
+				calls = append(calls, PlannedCall{Method: "delete_vm", Reason: fmt.Sprintf("Replacing VM '%s'", instance.vmCID)})
+			}
+		}
+	}
+
+	createReason := fmt.Sprintf("resource pool '%s' and stemcell '%s'", resourcePool.Name, stemcellName)
+	if resourcePool.CPI != "" {
+		createReason = fmt.Sprintf("%s on CPI '%s'", createReason, resourcePool.CPI)
+	}
+
+	for _, instance := range instances {
+		var currentDisk *biconfig.DiskRecord
+		for i, diskRecord := range deploymentState.Disks {
+			if diskRecord.ID == instance.diskID {
+				currentDisk = &deploymentState.Disks[i]
+			}
+		}
+
+		// with create-swap-delete, the current VM is deleted once its disks
+		// are attached to the new VM
+		swapVM := swapVMs && instance.vmCID != ""
+
+		if swapVM && currentDisk != nil {
+			calls = append(calls, PlannedCall{Method: "detach_disk", Reason: fmt.Sprintf("Moving disk '%s' to the new VM", currentDisk.CID)})
+		}
+
+		calls = append(calls, PlannedCall{Method: "create_vm", Reason: fmt.Sprintf("Job '%s' instance %d with %s", jobName, instance.index, createReason)})
+
+		calls = append(calls, planDisk(diskPool, currentDisk, skipDiskMigration)...)
+		calls = append(calls, planNamedDisks(namedDisks, instance, deploymentState, skipDiskMigration)...)
+
+		if swapVM {
+			calls = append(calls, PlannedCall{Method: "delete_vm", Reason: fmt.Sprintf("VM '%s' has been replaced", instance.vmCID)})
+		}
+	}
+
+	for _, stemcellRecord := range deploymentState.Stemcells {
+		if currentStemcell == nil || stemcellRecord.ID != currentStemcell.ID {
+			calls = append(calls, PlannedCall{Method: "delete_stemcell", Reason: fmt.Sprintf("Stemcell '%s' is no longer used", stemcellRecord.CID)})
+		}
+	}
+
+	return calls, nil
+}
+
+// planInstance returns what the deployment state records of the instance with
+// the given index. The first instance is recorded at the top level.
+func planInstance(deploymentState biconfig.DeploymentState, index int) plannedInstance {
+	if index == 0 {
+		return plannedInstance{
+			vmCID:        deploymentState.CurrentVMCID,
+			diskID:       deploymentState.CurrentDiskID,
+			namedDiskIDs: deploymentState.CurrentNamedDiskIDs,
+		}
+	}
+
+	for _, instanceRecord := range deploymentState.Instances {
+		if instanceRecord.Index == index {
+			return plannedInstance{
+				index:        index,
+				vmCID:        instanceRecord.VMCID,
+				diskID:       instanceRecord.DiskID,
+				namedDiskIDs: instanceRecord.NamedDiskIDs,
+			}
+		}
+	}
+
+	return plannedInstance{index: index}
+}
+
+// planDisk plans attaching the persistent disk of an instance. Replaced disks
+// are orphaned instead of deleted, which needs no CPI call.
+func planDisk(diskPool bideplmanifest.DiskPool, currentDisk *biconfig.DiskRecord, skipDiskMigration bool) []PlannedCall {
+	switch {
+	case diskPool.DiskSize == 0:
+		return []PlannedCall{}
+
+	case currentDisk == nil:
+		return []PlannedCall{
+			{Method: "create_disk", Reason: fmt.Sprintf("Persistent disk of %d MiB", diskPool.DiskSize)},
+			{Method: "attach_disk", Reason: "Attaching new persistent disk"},
+		}
+
+	case !skipDiskMigration && diskNeedsMigration(*currentDisk, diskPool):
+		return []PlannedCall{
+			{Method: "attach_disk", Reason: fmt.Sprintf("Attaching current disk '%s'", currentDisk.CID)},
+			{Method: "create_disk", Reason: fmt.Sprintf("Migrating to a persistent disk of %d MiB", diskPool.DiskSize)},
+			{Method: "attach_disk", Reason: "Attaching new persistent disk"},
+			{Method: "detach_disk", Reason: fmt.Sprintf("Disk '%s' has been migrated", currentDisk.CID)},
+		}
+
+	default:
+		return []PlannedCall{
+			{Method: "attach_disk", Reason: fmt.Sprintf("Attaching current disk '%s'", currentDisk.CID)},
+		}
+	}
+}
+
+// planNamedDisks plans attaching the named disks of an instance. Named disks
+// that are no longer in the manifest are orphaned, which needs no CPI call.
+func planNamedDisks(namedDisks []bideplmanifest.NamedDisk, instance plannedInstance, deploymentState biconfig.DeploymentState, skipDiskMigration bool) []PlannedCall {
+	calls := []PlannedCall{}
+
+	currentDisks := map[string]biconfig.DiskRecord{}
+	for name, diskID := range instance.namedDiskIDs {
+		for _, diskRecord := range deploymentState.Disks {
+			if diskRecord.ID == diskID {
+				currentDisks[name] = diskRecord
+			}
+		}
+	}
+
+	for _, namedDisk := range namedDisks {
+		currentDisk, found := currentDisks[namedDisk.Name]
+
+		switch {
+		case !found:
+			calls = append(calls,
+				PlannedCall{Method: "create_disk", Reason: fmt.Sprintf("Persistent disk '%s' of %d MiB", namedDisk.Name, namedDisk.DiskPool.DiskSize)},
+				PlannedCall{Method: "attach_disk", Reason: fmt.Sprintf("Attaching new persistent disk '%s'", namedDisk.Name)},
+			)
+
+		case !skipDiskMigration && diskNeedsMigration(currentDisk, namedDisk.DiskPool):
+			calls = append(calls,
+				PlannedCall{Method: "attach_disk", Reason: fmt.Sprintf("Attaching current disk '%s' of '%s'", currentDisk.CID, namedDisk.Name)},
+				PlannedCall{Method: "create_disk", Reason: fmt.Sprintf("Migrating '%s' to a persistent disk of %d MiB", namedDisk.Name, namedDisk.DiskPool.DiskSize)},
+				PlannedCall{Method: "attach_disk", Reason: fmt.Sprintf("Attaching new persistent disk '%s'", namedDisk.Name)},
+				PlannedCall{Method: "detach_disk", Reason: fmt.Sprintf("Disk '%s' has been migrated", currentDisk.CID)},
+			)
+
+		default:
+			calls = append(calls, PlannedCall{Method: "attach_disk", Reason: fmt.Sprintf("Attaching current disk '%s' of '%s'", currentDisk.CID, namedDisk.Name)})
+		}
+	}
+
+	return calls
+}
+
+// diskNeedsMigration mirrors Disk.NeedsMigration for a disk record.
+func diskNeedsMigration(diskRecord biconfig.DiskRecord, diskPool bideplmanifest.DiskPool) bool {
+	return diskRecord.Size != diskPool.DiskSize || !reflect.DeepEqual(diskRecord.CloudProperties, diskPool.CloudProperties)
+}
@@ -0,0 +1,289 @@
+package deployment_test
+
+import (
+	. "github.com/cloudfoundry/bosh-cli/deployment"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	biconfig "github.com/cloudfoundry/bosh-cli/config"
+	bideplmanifest "github.com/cloudfoundry/bosh-cli/deployment/manifest"
+	bistemcell "github.com/cloudfoundry/bosh-cli/stemcell"
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+)
+
+var _ = Describe("Plan", func() {
+	var (
+		deploymentManifest bideplmanifest.Manifest
+		deploymentState    biconfig.DeploymentState
+		stemcellManifest   bistemcell.Manifest
+		skipDiskMigration  bool
+	)
+
+	BeforeEach(func() {
+		deploymentManifest = bideplmanifest.Manifest{
+			Name: "fake-deployment-name",
+			ResourcePools: []bideplmanifest.ResourcePool{
+				{Name: "fake-resource-pool-name"},
+			},
+			DiskPools: []bideplmanifest.DiskPool{
+				{Name: "fake-disk-pool-name", DiskSize: 1024, CloudProperties: biproperty.Map{"fake-disk-property": "fake-value"}},
+			},
+			Jobs: []bideplmanifest.Job{
+				{
+					Name:               "fake-job-name",
+					Instances:          1,
+					ResourcePool:       "fake-resource-pool-name",
+					PersistentDiskPool: "fake-disk-pool-name",
+				},
+			},
+		}
+
+		deploymentState = biconfig.DeploymentState{}
+
+		stemcellManifest = bistemcell.Manifest{Name: "fake-stemcell-name", Version: "fake-stemcell-version"}
+		skipDiskMigration = false
+	})
+
+	methods := func(calls []PlannedCall) []string {
+		names := []string{}
+		for _, call := range calls {
+			names = append(names, call.Method)
+		}
+		return names
+	}
+
+	Context("when nothing has been deployed", func() {
+		It("plans uploading the stemcell and creating the vm and disk", func() {
+			calls, err := Plan(deploymentManifest, deploymentState, stemcellManifest, skipDiskMigration)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(calls).To(Equal([]PlannedCall{
+				{Method: "create_stemcell", Reason: "Stemcell 'fake-stemcell-name/fake-stemcell-version' has not been uploaded"},
+				{Method: "create_vm", Reason: "Job 'fake-job-name' instance 0 with resource pool 'fake-resource-pool-name' and stemcell 'fake-stemcell-name/fake-stemcell-version'"},
+				{Method: "create_disk", Reason: "Persistent disk of 1024 MiB"},
+				{Method: "attach_disk", Reason: "Attaching new persistent disk"},
+			}))
+		})
+	})
+
+	Context("when a previous deployment exists", func() {
+		BeforeEach(func() {
+			deploymentState = biconfig.DeploymentState{
+				CurrentVMCID:  "fake-vm-cid",
+				CurrentDiskID: "fake-disk-id",
+				Disks: []biconfig.DiskRecord{
+					{ID: "fake-disk-id", CID: "fake-disk-cid", Size: 1024, CloudProperties: biproperty.Map{"fake-disk-property": "fake-value"}},
+				},
+				Stemcells: []biconfig.StemcellRecord{
+					{ID: "fake-stemcell-id", Name: "fake-stemcell-name", Version: "fake-stemcell-version", CID: "fake-stemcell-cid"},
+				},
+			}
+		})
+
+		It("plans replacing the vm and reattaching the current disk", func() {
+			calls, err := Plan(deploymentManifest, deploymentState, stemcellManifest, skipDiskMigration)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(methods(calls)).To(Equal([]string{"delete_vm", "create_vm", "attach_disk"}))
+		})
+
+		It("plans deleting the vm after moving the disk to the new vm with create-swap-delete", func() {
+			deploymentManifest.Update.VMStrategy = bideplmanifest.VMStrategyCreateSwapDelete
+
+			calls, err := Plan(deploymentManifest, deploymentState, stemcellManifest, skipDiskMigration)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(calls).To(Equal([]PlannedCall{
+				{Method: "detach_disk", Reason: "Moving disk 'fake-disk-cid' to the new VM"},
+				{Method: "create_vm", Reason: "Job 'fake-job-name' instance 0 with resource pool 'fake-resource-pool-name' and stemcell 'fake-stemcell-name/fake-stemcell-version'"},
+				{Method: "attach_disk", Reason: "Attaching current disk 'fake-disk-cid'"},
+				{Method: "delete_vm", Reason: "VM 'fake-vm-cid' has been replaced"},
+			}))
+		})
+
+		It("plans migrating the disk when its size changes", func() {
+			deploymentManifest.DiskPools[0].DiskSize = 2048
+
+			calls, err := Plan(deploymentManifest, deploymentState, stemcellManifest, skipDiskMigration)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(methods(calls)).To(Equal([]string{
+				"delete_vm", "create_vm", "attach_disk", "create_disk", "attach_disk", "detach_disk",
+			}))
+		})
+
+		It("plans only reattaching the disk when its size changes and disk migration is skipped", func() {
+			deploymentManifest.DiskPools[0].DiskSize = 2048
+			skipDiskMigration = true
+
+			calls, err := Plan(deploymentManifest, deploymentState, stemcellManifest, skipDiskMigration)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(calls).To(Equal([]PlannedCall{
+				{Method: "delete_vm", Reason: "Replacing VM 'fake-vm-cid'"},
+				{Method: "create_vm", Reason: "Job 'fake-job-name' instance 0 with resource pool 'fake-resource-pool-name' and stemcell 'fake-stemcell-name/fake-stemcell-version'"},
+				{Method: "attach_disk", Reason: "Attaching current disk 'fake-disk-cid'"},
+			}))
+		})
+
+		It("does not plan deleting the disk when it is no longer used", func() {
+			deploymentManifest.Jobs[0].PersistentDiskPool = ""
+
+			calls, err := Plan(deploymentManifest, deploymentState, stemcellManifest, skipDiskMigration)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(methods(calls)).To(Equal([]string{"delete_vm", "create_vm"}))
+		})
+
+		It("plans deleting the previous stemcell when the stemcell changes", func() {
+			stemcellManifest.Version = "fake-new-stemcell-version"
+
+			calls, err := Plan(deploymentManifest, deploymentState, stemcellManifest, skipDiskMigration)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(methods(calls)).To(Equal([]string{"create_stemcell", "delete_vm", "create_vm", "attach_disk", "delete_stemcell"}))
+		})
+	})
+
+	Context("when the job has several instances", func() {
+		BeforeEach(func() {
+			deploymentManifest.Jobs[0].Instances = 2
+
+			deploymentState = biconfig.DeploymentState{
+				CurrentVMCID:  "fake-vm-cid-0",
+				CurrentDiskID: "fake-disk-id-0",
+				Instances: []biconfig.InstanceRecord{
+					{Index: 1, VMCID: "fake-vm-cid-1", DiskID: "fake-disk-id-1"},
+					{Index: 2, VMCID: "fake-vm-cid-2", DiskID: "fake-disk-id-2"},
+				},
+				Disks: []biconfig.DiskRecord{
+					{ID: "fake-disk-id-0", CID: "fake-disk-cid-0", Size: 1024, CloudProperties: biproperty.Map{"fake-disk-property": "fake-value"}},
+					{ID: "fake-disk-id-1", CID: "fake-disk-cid-1", Size: 1024, CloudProperties: biproperty.Map{"fake-disk-property": "fake-value"}},
+					{ID: "fake-disk-id-2", CID: "fake-disk-cid-2", Size: 1024, CloudProperties: biproperty.Map{"fake-disk-property": "fake-value"}},
+				},
+				Stemcells: []biconfig.StemcellRecord{
+					{ID: "fake-stemcell-id", Name: "fake-stemcell-name", Version: "fake-stemcell-version", CID: "fake-stemcell-cid"},
+				},
+			}
+		})
+
+		It("plans deleting the removed instances and all vms before creating each instance", func() {
+			calls, err := Plan(deploymentManifest, deploymentState, stemcellManifest, skipDiskMigration)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(calls).To(Equal([]PlannedCall{
+				{Method: "delete_vm", Reason: "Job 'fake-job-name' instance 2 is no longer in the manifest"},
+				{Method: "delete_vm", Reason: "Replacing VM 'fake-vm-cid-0'"},
+				{Method: "delete_vm", Reason: "Replacing VM 'fake-vm-cid-1'"},
+				{Method: "create_vm", Reason: "Job 'fake-job-name' instance 0 with resource pool 'fake-resource-pool-name' and stemcell 'fake-stemcell-name/fake-stemcell-version'"},
+				{Method: "attach_disk", Reason: "Attaching current disk 'fake-disk-cid-0'"},
+				{Method: "create_vm", Reason: "Job 'fake-job-name' instance 1 with resource pool 'fake-resource-pool-name' and stemcell 'fake-stemcell-name/fake-stemcell-version'"},
+				{Method: "attach_disk", Reason: "Attaching current disk 'fake-disk-cid-1'"},
+			}))
+		})
+
+		It("plans replacing each instance in turn with create-swap-delete", func() {
+			deploymentManifest.Update.VMStrategy = bideplmanifest.VMStrategyCreateSwapDelete
+
+			calls, err := Plan(deploymentManifest, deploymentState, stemcellManifest, skipDiskMigration)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(methods(calls)).To(Equal([]string{
+				"delete_vm",
+				"detach_disk", "create_vm", "attach_disk", "delete_vm",
+				"detach_disk", "create_vm", "attach_disk", "delete_vm",
+			}))
+		})
+
+		It("plans creating the vms and disks of new instances", func() {
+			deploymentManifest.Jobs[0].Instances = 4
+
+			calls, err := Plan(deploymentManifest, deploymentState, stemcellManifest, skipDiskMigration)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(calls[len(calls)-3:]).To(Equal([]PlannedCall{
+				{Method: "create_vm", Reason: "Job 'fake-job-name' instance 3 with resource pool 'fake-resource-pool-name' and stemcell 'fake-stemcell-name/fake-stemcell-version'"},
+				{Method: "create_disk", Reason: "Persistent disk of 1024 MiB"},
+				{Method: "attach_disk", Reason: "Attaching new persistent disk"},
+			}))
+		})
+	})
+
+	It("names the CPI of the resource pool", func() {
+		deploymentManifest.ResourcePools[0].CPI = "fake-cpi-name"
+
+		calls, err := Plan(deploymentManifest, deploymentState, stemcellManifest, skipDiskMigration)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(calls).To(ContainElement(PlannedCall{
+			Method: "create_vm",
+			Reason: "Job 'fake-job-name' instance 0 with resource pool 'fake-resource-pool-name' and stemcell 'fake-stemcell-name/fake-stemcell-version' on CPI 'fake-cpi-name'",
+		}))
+	})
+
+	Context("when the job has named disks", func() {
+		BeforeEach(func() {
+			deploymentManifest.Jobs[0].PersistentDiskPool = ""
+			deploymentManifest.Jobs[0].PersistentDisks = []bideplmanifest.JobPersistentDisk{
+				{Name: "fake-disk-name-1", DiskPool: "fake-disk-pool-name"},
+				{Name: "fake-disk-name-2", DiskPool: "fake-disk-pool-name"},
+			}
+
+			deploymentState = biconfig.DeploymentState{
+				CurrentVMCID:  "fake-vm-cid",
+				CurrentDiskID: "fake-disk-id",
+				CurrentNamedDiskIDs: map[string]string{
+					"fake-disk-name-2": "fake-named-disk-id-2",
+					"fake-disk-name-3": "fake-named-disk-id-3",
+				},
+				Disks: []biconfig.DiskRecord{
+					{ID: "fake-disk-id", CID: "fake-disk-cid", Size: 1024, CloudProperties: biproperty.Map{"fake-disk-property": "fake-value"}},
+					{ID: "fake-named-disk-id-2", CID: "fake-named-disk-cid-2", Size: 1024, CloudProperties: biproperty.Map{"fake-disk-property": "fake-value"}},
+					{ID: "fake-named-disk-id-3", CID: "fake-named-disk-cid-3", Size: 1024, CloudProperties: biproperty.Map{"fake-disk-property": "fake-value"}},
+				},
+			}
+		})
+
+		It("plans attaching the current named disks without deleting the removed ones", func() {
+			calls, err := Plan(deploymentManifest, deploymentState, stemcellManifest, skipDiskMigration)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(calls[len(calls)-1]).To(Equal(
+				PlannedCall{Method: "attach_disk", Reason: "Attaching current disk 'fake-named-disk-cid-2' of 'fake-disk-name-2'"},
+			))
+			Expect(methods(calls)).ToNot(ContainElement("delete_disk"))
+		})
+
+		It("plans migrating the named disks whose disk pool changed", func() {
+			deploymentManifest.DiskPools[0].DiskSize = 2048
+
+			calls, err := Plan(deploymentManifest, deploymentState, stemcellManifest, skipDiskMigration)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(calls[len(calls)-4:]).To(Equal([]PlannedCall{
+				{Method: "attach_disk", Reason: "Attaching current disk 'fake-named-disk-cid-2' of 'fake-disk-name-2'"},
+				{Method: "create_disk", Reason: "Migrating 'fake-disk-name-2' to a persistent disk of 2048 MiB"},
+				{Method: "attach_disk", Reason: "Attaching new persistent disk 'fake-disk-name-2'"},
+				{Method: "detach_disk", Reason: "Disk 'fake-named-disk-cid-2' has been migrated"},
+			}))
+		})
+
+		It("plans only reattaching the named disks whose disk pool changed when disk migration is skipped", func() {
+			deploymentManifest.DiskPools[0].DiskSize = 2048
+			skipDiskMigration = true
+
+			calls, err := Plan(deploymentManifest, deploymentState, stemcellManifest, skipDiskMigration)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(calls[len(calls)-1]).To(Equal(
+				PlannedCall{Method: "attach_disk", Reason: "Attaching current disk 'fake-named-disk-cid-2' of 'fake-disk-name-2'"},
+			))
+			Expect(methods(calls)).ToNot(ContainElement("detach_disk"))
+		})
+
+		It("plans creating the named disks that do not exist", func() {
+			deploymentManifest.Jobs[0].PersistentDisks = append(deploymentManifest.Jobs[0].PersistentDisks,
+				bideplmanifest.JobPersistentDisk{Name: "fake-disk-name-4", DiskPool: "fake-disk-pool-name"})
+
+			calls, err := Plan(deploymentManifest, deploymentState, stemcellManifest, skipDiskMigration)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(calls).To(ContainElement(PlannedCall{Method: "create_disk", Reason: "Persistent disk 'fake-disk-name-4' of 1024 MiB"}))
+			Expect(calls).To(ContainElement(PlannedCall{Method: "attach_disk", Reason: "Attaching new persistent disk 'fake-disk-name-4'"}))
+		})
+	})
+
+	It("returns an error when the job's resource pool cannot be found", func() {
+		deploymentManifest.ResourcePools = []bideplmanifest.ResourcePool{}
+
+		_, err := Plan(deploymentManifest, deploymentState, stemcellManifest, skipDiskMigration)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Finding resource pool"))
+	})
+})
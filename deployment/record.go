@@ -11,6 +11,8 @@ type Record interface {
 	IsDeployed(manifestSHA string, releases []birel.Release, stemcell bistemcell.ExtractedStemcell) (bool, error)
 	Clear() error
 	Update(manifestSHA string, releases []birel.Release) error
+	FindManifest() (manifest []byte, found bool, err error)
+	UpdateManifest(manifest []byte) error
 }
 
 type deploymentRecord struct {
@@ -114,3 +116,25 @@ func (v *deploymentRecord) Update(manifestSHA string, releases []birel.Release)
 
 	return nil
 }
+
+// FindManifest returns the manifest of the last successful deploy, with its
+// variables left as ((references)).
+// Unlike the manifest sha, it is kept when the record is cleared, so that it
+// can be diffed against while a deploy is being retried.
+func (v *deploymentRecord) FindManifest() ([]byte, bool, error) {
+	manifest, found, err := v.deploymentRepo.FindCurrentManifest()
+	if err != nil {
+		return nil, false, bosherr.WrapError(err, "Finding currently deployed manifest")
+	}
+
+	return manifest, found, nil
+}
+
+func (v *deploymentRecord) UpdateManifest(manifest []byte) error {
+	err := v.deploymentRepo.UpdateCurrentManifest(manifest)
+	if err != nil {
+		return bosherr.WrapError(err, "Saving deployed manifest")
+	}
+
+	return nil
+}
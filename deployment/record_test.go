@@ -361,4 +361,39 @@ var _ = Describe("Record", func() {
 			})
 		})
 	})
+
+	Describe("UpdateManifest", func() {
+		It("saves the deployed manifest", func() {
+			err := deploymentRecord.UpdateManifest([]byte("fake-manifest"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentRepo.UpdateCurrentManifestContents).To(Equal([]byte("fake-manifest")))
+		})
+
+		It("returns an error when saving fails", func() {
+			deploymentRepo.UpdateCurrentManifestErr = errors.New("fake-update-error")
+
+			err := deploymentRecord.UpdateManifest([]byte("fake-manifest"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-update-error"))
+		})
+	})
+
+	Describe("FindManifest", func() {
+		It("returns the deployed manifest", func() {
+			deploymentRepo.SetFindCurrentManifestBehavior([]byte("fake-manifest"), true, nil)
+
+			manifest, found, err := deploymentRecord.FindManifest()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(manifest).To(Equal([]byte("fake-manifest")))
+		})
+
+		It("returns an error when finding fails", func() {
+			deploymentRepo.SetFindCurrentManifestBehavior(nil, false, errors.New("fake-find-error"))
+
+			_, _, err := deploymentRecord.FindManifest()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-find-error"))
+		})
+	})
 })
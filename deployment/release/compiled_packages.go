@@ -0,0 +1,46 @@
+package release
+
+import (
+	bireljob "github.com/cloudfoundry/bosh-cli/release/job"
+	birelpkg "github.com/cloudfoundry/bosh-cli/release/pkg"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// ValidateCompiledPackages checks that every compiled package the jobs depend
+// on, directly or transitively, was compiled against the stemcell being
+// deployed (e.g. 'ubuntu-trusty/3421.11'). Compiled packages are uploaded as
+// is instead of being compiled on the VM, so they must match the stemcell.
+func ValidateCompiledPackages(jobs []bireljob.Job, stemcellOSAndVersion string) error {
+	errs := []error{}
+	visited := map[string]bool{}
+
+	var validate func(pkgs []birelpkg.Compilable)
+
+	validate = func(pkgs []birelpkg.Compilable) {
+		for _, pkg := range pkgs {
+			if visited[pkg.Name()] {
+				continue
+			}
+			visited[pkg.Name()] = true
+
+			compiledPkg, ok := pkg.(*birelpkg.CompiledPackage)
+			if ok && compiledPkg.OSVersionSlug() != stemcellOSAndVersion {
+				errs = append(errs, bosherr.Errorf(
+					"Package '%s/%s' is compiled against stemcell '%s', but the deployment uses stemcell '%s'",
+					pkg.Name(), pkg.Fingerprint(), compiledPkg.OSVersionSlug(), stemcellOSAndVersion))
+			}
+
+			validate(pkg.Deps())
+		}
+	}
+
+	for _, job := range jobs {
+		validate(job.Packages)
+	}
+
+	if len(errs) > 0 {
+		return bosherr.NewMultiError(errs...)
+	}
+
+	return nil
+}
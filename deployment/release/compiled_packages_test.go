@@ -0,0 +1,52 @@
+package release_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/deployment/release"
+	bireljob "github.com/cloudfoundry/bosh-cli/release/job"
+	birelpkg "github.com/cloudfoundry/bosh-cli/release/pkg"
+	. "github.com/cloudfoundry/bosh-cli/release/resource"
+)
+
+var _ = Describe("ValidateCompiledPackages", func() {
+	var (
+		job    *bireljob.Job
+		dep    *birelpkg.CompiledPackage
+		parent *birelpkg.CompiledPackage
+	)
+
+	BeforeEach(func() {
+		dep = birelpkg.NewCompiledPackageWithoutArchive("dep", "dep-fp", "ubuntu-trusty/3421.11", "dep-sha1", nil)
+		parent = birelpkg.NewCompiledPackageWithoutArchive("parent", "parent-fp", "ubuntu-trusty/3421.11", "parent-sha1", []string{"dep"})
+		err := parent.AttachDependencies([]*birelpkg.CompiledPackage{dep})
+		Expect(err).ToNot(HaveOccurred())
+
+		job = bireljob.NewJob(NewResource("job0", "job0-fp", nil))
+		job.Packages = []birelpkg.Compilable{parent}
+	})
+
+	It("does not error when packages are compiled against the stemcell", func() {
+		err := ValidateCompiledPackages([]bireljob.Job{*job}, "ubuntu-trusty/3421.11")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("does not error for source packages", func() {
+		job.Packages = []birelpkg.Compilable{
+			birelpkg.NewPackage(NewResource("src", "src-fp", nil), nil),
+		}
+
+		err := ValidateCompiledPackages([]bireljob.Job{*job}, "ubuntu-trusty/3421.11")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("returns an error when a package is compiled against another stemcell", func() {
+		err := ValidateCompiledPackages([]bireljob.Job{*job}, "ubuntu-xenial/97")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(
+			"Package 'parent/parent-fp' is compiled against stemcell 'ubuntu-trusty/3421.11', but the deployment uses stemcell 'ubuntu-xenial/97'"))
+		Expect(err.Error()).To(ContainSubstring(
+			"Package 'dep/dep-fp' is compiled against stemcell 'ubuntu-trusty/3421.11', but the deployment uses stemcell 'ubuntu-xenial/97'"))
+	})
+})
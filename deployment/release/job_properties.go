@@ -0,0 +1,213 @@
+package release
+
+import (
+	"sort"
+	"strings"
+
+	bireljob "github.com/cloudfoundry/bosh-cli/release/job"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+)
+
+// ValidateJobProperties checks the properties of an instance group against
+// the property definitions in the specs of its release jobs. Properties are
+// resolved the way templates are rendered: the properties of a release job in
+// the instance group when there are any, otherwise the instance group
+// properties merged over the global properties.
+//
+// It returns an error listing properties without a default that are not
+// set, properties that no release job declares, and properties that do not
+// have the type declared in the spec. Global properties are shared by all
+// instance groups, so they are not reported when no release job declares
+// them.
+func ValidateJobProperties(
+	releaseJobs []bireljob.Job,
+	releaseJobProperties map[string]*biproperty.Map,
+	jobProperties biproperty.Map,
+	globalProperties biproperty.Map,
+) error {
+	errs := []error{}
+
+	// instance group properties are shared by release jobs without
+	// properties of their own
+	sharedNames := map[string]bool{}
+	sharedUsed := false
+
+	for _, releaseJob := range releaseJobs {
+		ownProperties := releaseJobProperties[releaseJob.Name()]
+
+		for _, name := range sortedPropertyNames(releaseJob.Properties) {
+			definition := releaseJob.Properties[name]
+
+			var value interface{}
+			var found bool
+
+			if ownProperties != nil {
+				value, found = lookupProperty(*ownProperties, name)
+			} else {
+				sharedNames[name] = true
+
+				value, found = lookupProperty(jobProperties, name)
+				if !found {
+					value, found = lookupProperty(globalProperties, name)
+				}
+			}
+
+			if !found {
+				if definition.Default == nil {
+					errs = append(errs, bosherr.Errorf(
+						"Job '%s' property '%s' is missing and has no default", releaseJob.Name(), name))
+				}
+				continue
+			}
+
+			if !propertyHasType(value, definition.Type) {
+				errs = append(errs, bosherr.Errorf(
+					"Job '%s' property '%s' must be of type '%s'", releaseJob.Name(), name, definition.Type))
+			}
+		}
+
+		if ownProperties != nil {
+			for _, path := range unknownPropertyPaths(*ownProperties, propertyNameSet(releaseJob.Properties)) {
+				errs = append(errs, bosherr.Errorf(
+					"Job '%s' property '%s' is not declared in the job spec", releaseJob.Name(), path))
+			}
+		} else {
+			sharedUsed = true
+		}
+	}
+
+	if sharedUsed {
+		for _, path := range unknownPropertyPaths(jobProperties, sharedNames) {
+			errs = append(errs, bosherr.Errorf(
+				"Instance group property '%s' is not declared by any job spec", path))
+		}
+	}
+
+	if len(errs) > 0 {
+		return bosherr.NewMultiError(errs...)
+	}
+
+	return nil
+}
+
+func sortedPropertyNames(definitions map[string]bireljob.PropertyDefinition) []string {
+	names := []string{}
+	for name := range definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func propertyNameSet(definitions map[string]bireljob.PropertyDefinition) map[string]bool {
+	names := map[string]bool{}
+	for name := range definitions {
+		names[name] = true
+	}
+	return names
+}
+
+// lookupProperty finds a dotted property name such as 'db.port' in nested
+// property maps. A property set to null is not found.
+func lookupProperty(properties biproperty.Map, name string) (interface{}, bool) {
+	var current interface{} = properties
+
+	for _, key := range strings.Split(name, ".") {
+		currentMap, ok := current.(biproperty.Map)
+		if !ok {
+			return nil, false
+		}
+
+		current, ok = currentMap[key]
+		if !ok || current == nil {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// unknownPropertyPaths returns the paths of the properties that are not
+// within any of the declared property names, sorted.
+func unknownPropertyPaths(properties biproperty.Map, declaredNames map[string]bool) []string {
+	paths := []string{}
+
+	var walk func(prefix string, properties biproperty.Map)
+
+	walk = func(prefix string, properties biproperty.Map) {
+		for key, value := range properties {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+
+			if declaredNames[path] {
+				continue
+			}
+
+			nested, ok := value.(biproperty.Map)
+			if ok && declaresWithin(declaredNames, path) {
+				walk(path, nested)
+				continue
+			}
+
+			paths = append(paths, path)
+		}
+	}
+
+	walk("", properties)
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+func declaresWithin(declaredNames map[string]bool, path string) bool {
+	for name := range declaredNames {
+		if strings.HasPrefix(name, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// propertyHasType checks values against the types used in job specs. Types
+// that are not known, and properties without type, are not checked.
+func propertyHasType(value interface{}, propertyType string) bool {
+	switch propertyType {
+	case "string", "password":
+		_, ok := value.(string)
+		return ok
+
+	case "integer":
+		switch v := value.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			return true
+		case float64:
+			return v == float64(int64(v))
+		}
+		return false
+
+	case "number":
+		switch value.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			return true
+		}
+		return false
+
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+
+	case "array":
+		_, ok := value.(biproperty.List)
+		return ok
+
+	case "hash", "certificate", "rsa", "ssh":
+		_, ok := value.(biproperty.Map)
+		return ok
+	}
+
+	return true
+}
@@ -0,0 +1,139 @@
+package release_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/deployment/release"
+	bireljob "github.com/cloudfoundry/bosh-cli/release/job"
+	. "github.com/cloudfoundry/bosh-cli/release/resource"
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+)
+
+var _ = Describe("ValidateJobProperties", func() {
+	var (
+		job                  *bireljob.Job
+		releaseJobProperties map[string]*biproperty.Map
+		jobProperties        biproperty.Map
+		globalProperties     biproperty.Map
+	)
+
+	BeforeEach(func() {
+		job = bireljob.NewJob(NewResource("job0", "job0-fp", nil))
+		job.Properties = map[string]bireljob.PropertyDefinition{
+			"db.host":  {},
+			"db.port":  {Default: 5432, Type: "integer"},
+			"db.users": {Default: biproperty.List{}, Type: "array"},
+			"tls":      {Type: "certificate"},
+		}
+
+		releaseJobProperties = map[string]*biproperty.Map{}
+		jobProperties = biproperty.Map{
+			"db": biproperty.Map{
+				"host": "fake-host",
+				"port": 5433,
+			},
+			"tls": biproperty.Map{
+				"certificate": "fake-cert",
+			},
+		}
+		globalProperties = biproperty.Map{}
+	})
+
+	validate := func() error {
+		return ValidateJobProperties([]bireljob.Job{*job}, releaseJobProperties, jobProperties, globalProperties)
+	}
+
+	It("does not error when the properties match the job spec", func() {
+		Expect(validate()).ToNot(HaveOccurred())
+	})
+
+	It("uses global properties that are not set on the instance group", func() {
+		delete(jobProperties, "tls")
+		globalProperties["tls"] = biproperty.Map{"certificate": "fake-cert"}
+
+		Expect(validate()).ToNot(HaveOccurred())
+	})
+
+	It("does not report global properties that no job declares", func() {
+		globalProperties["other"] = "fake-value"
+
+		Expect(validate()).ToNot(HaveOccurred())
+	})
+
+	It("returns an error when a property without default is missing", func() {
+		delete(jobProperties["db"].(biproperty.Map), "host")
+
+		err := validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Job 'job0' property 'db.host' is missing and has no default"))
+	})
+
+	It("treats properties set to null as missing", func() {
+		jobProperties["db"].(biproperty.Map)["host"] = nil
+
+		err := validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Job 'job0' property 'db.host' is missing and has no default"))
+	})
+
+	It("returns an error for properties that no job declares", func() {
+		jobProperties["db"].(biproperty.Map)["name"] = "fake-name"
+		jobProperties["other"] = biproperty.Map{"key": "fake-value"}
+
+		err := validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Instance group property 'db.name' is not declared by any job spec"))
+		Expect(err.Error()).To(ContainSubstring("Instance group property 'other' is not declared by any job spec"))
+	})
+
+	It("returns an error for properties with the wrong type", func() {
+		jobProperties["db"].(biproperty.Map)["port"] = "5433"
+		jobProperties["db"].(biproperty.Map)["users"] = "fake-user"
+		jobProperties["tls"] = "fake-cert"
+
+		err := validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Job 'job0' property 'db.port' must be of type 'integer'"))
+		Expect(err.Error()).To(ContainSubstring("Job 'job0' property 'db.users' must be of type 'array'"))
+		Expect(err.Error()).To(ContainSubstring("Job 'job0' property 'tls' must be of type 'certificate'"))
+	})
+
+	It("does not check properties with unknown types", func() {
+		job.Properties["tls"] = bireljob.PropertyDefinition{Type: "fake-type"}
+		jobProperties["tls"] = "fake-cert"
+
+		Expect(validate()).ToNot(HaveOccurred())
+	})
+
+	Context("when the job has properties of its own", func() {
+		BeforeEach(func() {
+			releaseJobProperties["job0"] = &biproperty.Map{
+				"db":  biproperty.Map{"host": "fake-job-host"},
+				"tls": biproperty.Map{"certificate": "fake-cert"},
+			}
+		})
+
+		It("validates only the properties of the job", func() {
+			jobProperties["other"] = "fake-value"
+
+			Expect(validate()).ToNot(HaveOccurred())
+		})
+
+		It("does not fall back to instance group properties", func() {
+			delete(*releaseJobProperties["job0"], "tls")
+
+			err := validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Job 'job0' property 'tls' is missing and has no default"))
+		})
+
+		It("returns an error for job properties that the job spec does not declare", func() {
+			(*releaseJobProperties["job0"])["other"] = "fake-value"
+
+			err := validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Job 'job0' property 'other' is not declared in the job spec"))
+		})
+	})
+})
@@ -0,0 +1,125 @@
+package template
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"gopkg.in/yaml.v2"
+
+	biutil "github.com/cloudfoundry/bosh-cli/common/util"
+)
+
+// cloudConfigSections are the IaaS sections taken from a cloud config.
+// Other sections, such as compilation, do not apply to bosh-init style
+// deployments and are ignored so that a director cloud config can be reused.
+var cloudConfigSections = []string{
+	"azs",
+	"networks",
+	"resource_pools",
+	"vm_types",
+	"vm_extensions",
+	"disk_pools",
+	"disk_types",
+}
+
+type cloudConfigTemplateFactory struct {
+	fs              boshsys.FileSystem
+	cloudConfigPath string
+}
+
+// NewCloudConfigTemplateFactory returns a factory whose templates are the
+// deployment manifest with the cloud config sections merged in before any
+// variables or ops are applied. Entries declared in the manifest win over
+// cloud config entries with the same name.
+func NewCloudConfigTemplateFactory(fs boshsys.FileSystem, cloudConfigPath string) DeploymentTemplateFactory {
+	return cloudConfigTemplateFactory{fs: fs, cloudConfigPath: cloudConfigPath}
+}
+
+func (t cloudConfigTemplateFactory) NewDeploymentTemplateFromPath(path string) (DeploymentTemplate, error) {
+	contents, err := biutil.ReadManifest(path, t.fs)
+	if err != nil {
+		return DeploymentTemplate{}, err
+	}
+
+	cloudConfigContents, err := biutil.ReadManifest(t.cloudConfigPath, t.fs)
+	if err != nil {
+		return DeploymentTemplate{}, bosherr.WrapErrorf(err, "Reading cloud config '%s'", t.cloudConfigPath)
+	}
+
+	var manifest map[interface{}]interface{}
+
+	err = yaml.Unmarshal(contents, &manifest)
+	if err != nil {
+		return DeploymentTemplate{}, bosherr.WrapErrorf(err, "Unmarshalling deployment manifest '%s'", path)
+	}
+
+	var cloudConfig map[interface{}]interface{}
+
+	err = yaml.Unmarshal(cloudConfigContents, &cloudConfig)
+	if err != nil {
+		return DeploymentTemplate{}, bosherr.WrapErrorf(err, "Unmarshalling cloud config '%s'", t.cloudConfigPath)
+	}
+
+	if manifest == nil {
+		manifest = map[interface{}]interface{}{}
+	}
+
+	for _, section := range cloudConfigSections {
+		err = mergeNamedSection(manifest, cloudConfig, section)
+		if err != nil {
+			return DeploymentTemplate{}, bosherr.WrapErrorf(err, "Merging cloud config '%s'", t.cloudConfigPath)
+		}
+	}
+
+	mergedContents, err := yaml.Marshal(manifest)
+	if err != nil {
+		return DeploymentTemplate{}, bosherr.WrapError(err, "Marshalling merged deployment manifest")
+	}
+
+	return NewDeploymentTemplate(mergedContents), nil
+}
+
+func mergeNamedSection(manifest, config map[interface{}]interface{}, section string) error {
+	configEntries, found := config[section]
+	if !found || configEntries == nil {
+		return nil
+	}
+
+	configList, ok := configEntries.([]interface{})
+	if !ok {
+		return bosherr.Errorf("Expected '%s' to be a list", section)
+	}
+
+	var manifestList []interface{}
+
+	if manifestEntries, found := manifest[section]; found && manifestEntries != nil {
+		manifestList, ok = manifestEntries.([]interface{})
+		if !ok {
+			return bosherr.Errorf("Expected manifest '%s' to be a list", section)
+		}
+	}
+
+	names := map[interface{}]bool{}
+
+	for _, entry := range manifestList {
+		if entryMap, ok := entry.(map[interface{}]interface{}); ok {
+			names[entryMap["name"]] = true
+		}
+	}
+
+	for _, entry := range configList {
+		entryMap, ok := entry.(map[interface{}]interface{})
+		if !ok {
+			return bosherr.Errorf("Expected '%s' entries to be hashes", section)
+		}
+
+		if names[entryMap["name"]] {
+			continue
+		}
+
+		manifestList = append(manifestList, entry)
+	}
+
+	manifest[section] = manifestList
+
+	return nil
+}
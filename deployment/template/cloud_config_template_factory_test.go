@@ -0,0 +1,95 @@
+package template_test
+
+import (
+	"github.com/cppforlife/go-patch/patch"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/deployment/template"
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("CloudConfigTemplateFactory", func() {
+	var (
+		fakeFs          *fakesys.FakeFileSystem
+		templateFactory DeploymentTemplateFactory
+	)
+
+	BeforeEach(func() {
+		fakeFs = fakesys.NewFakeFileSystem()
+		templateFactory = NewCloudConfigTemplateFactory(fakeFs, "/path/to/cloud-config.yml")
+
+		fakeFs.WriteFileString("/path/to/deployment.yml", `---
+name: fake-deployment
+networks:
+- name: private
+  type: manual
+jobs:
+- name: bosh
+  resource_pool: vms
+`)
+	})
+
+	It("merges the IaaS sections of the cloud config before evaluating variables and ops", func() {
+		fakeFs.WriteFileString("/path/to/cloud-config.yml", `---
+networks:
+- name: private
+  type: dynamic
+- name: public
+  type: vip
+resource_pools:
+- name: vms
+  cloud_properties:
+    instance_type: ((instance_type))
+disk_pools:
+- name: disks
+  disk_size: 1024
+compilation:
+  workers: 5
+`)
+
+		template, err := templateFactory.NewDeploymentTemplateFromPath("/path/to/deployment.yml")
+		Expect(err).ToNot(HaveOccurred())
+
+		vars := boshtpl.StaticVariables{"instance_type": "m4.large"}
+		ops := patch.Ops{
+			patch.ReplaceOp{Path: patch.MustNewPointerFromString("/disk_pools/name=disks/disk_size"), Value: 2048},
+		}
+
+		interpolatedTemplate, err := template.Evaluate(vars, ops)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(string(interpolatedTemplate.Content())).To(Equal(`disk_pools:
+- disk_size: 2048
+  name: disks
+jobs:
+- name: bosh
+  resource_pool: vms
+name: fake-deployment
+networks:
+- name: private
+  type: manual
+- name: public
+  type: vip
+resource_pools:
+- cloud_properties:
+    instance_type: m4.large
+  name: vms
+`))
+	})
+
+	It("returns an error when a cloud config section is not a list", func() {
+		fakeFs.WriteFileString("/path/to/cloud-config.yml", "networks: {}")
+
+		_, err := templateFactory.NewDeploymentTemplateFromPath("/path/to/deployment.yml")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Expected 'networks' to be a list"))
+	})
+
+	It("returns an error when the cloud config cannot be read", func() {
+		_, err := templateFactory.NewDeploymentTemplateFromPath("/path/to/deployment.yml")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Reading file /path/to/cloud-config.yml"))
+	})
+})
@@ -11,6 +11,10 @@ import (
 
 type DeploymentTemplate struct {
 	template boshtpl.Template
+
+	// varSources describes where variables are looked up, for errors about
+	// missing variables
+	varSources []string
 }
 
 func NewDeploymentTemplate(content []byte) DeploymentTemplate {
@@ -18,7 +22,7 @@ func NewDeploymentTemplate(content []byte) DeploymentTemplate {
 }
 
 func (t DeploymentTemplate) Evaluate(vars boshtpl.Variables, op patch.Op) (InterpolatedTemplate, error) {
-	bytes, err := t.template.Evaluate(vars, op, boshtpl.EvaluateOpts{ExpectAllKeys: true})
+	bytes, err := t.template.Evaluate(vars, op, boshtpl.EvaluateOpts{ExpectAllKeys: true, VarSources: t.varSources})
 	if err != nil {
 		return InterpolatedTemplate{}, err
 	}
@@ -32,5 +36,13 @@ func (t DeploymentTemplate) Evaluate(vars boshtpl.Variables, op patch.Op) (Inter
 
 	shaSumString := fmt.Sprintf("%x", sha512.Sum(nil))
 
-	return NewInterpolatedTemplate(bytes, shaSumString), nil
+	templateBytes, err := t.template.Evaluate(boshtpl.StaticVariables{}, op, boshtpl.EvaluateOpts{})
+	if err != nil {
+		return InterpolatedTemplate{}, err
+	}
+
+	interpolatedTemplate := NewInterpolatedTemplate(bytes, shaSumString)
+	interpolatedTemplate.template = templateBytes
+
+	return interpolatedTemplate, nil
 }
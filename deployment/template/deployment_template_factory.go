@@ -1,8 +1,9 @@
 package template
 
 import (
-	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
+
+	biutil "github.com/cloudfoundry/bosh-cli/common/util"
 )
 
 type DeploymentTemplateFactory interface {
@@ -18,9 +19,9 @@ func NewDeploymentTemplateFactory(fs boshsys.FileSystem) DeploymentTemplateFacto
 }
 
 func (t templateFactory) NewDeploymentTemplateFromPath(path string) (DeploymentTemplate, error) {
-	contents, err := t.fs.ReadFile(path)
+	contents, err := biutil.ReadManifest(path, t.fs)
 	if err != nil {
-		return DeploymentTemplate{}, bosherr.WrapErrorf(err, "Reading file %s", path)
+		return DeploymentTemplate{}, err
 	}
 
 	return NewDeploymentTemplate(contents), nil
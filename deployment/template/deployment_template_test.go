@@ -45,4 +45,17 @@ var _ = Describe("DeploymentTemplate", func() {
 		asString := result.SHA()
 		Expect(asString).To(Equal("0cf9180a764aba863a67b6d72f0918bc131c6772642cb2dce5a34f0a702f9470ddc2bf125c12198b1995c233c34b4afd346c54a2334c350a948a51b6e8b4e6b6"))
 	})
+
+	It("keeps the variables of the template with the ops applied", func() {
+		deploymentTemplate := NewDeploymentTemplate([]byte("password: ((password))"))
+		vars := boshtpl.StaticVariables{"password": "fake-password"}
+		ops := patch.Ops{
+			patch.ReplaceOp{Path: patch.MustNewPointerFromString("/name?"), Value: "fake-name"},
+		}
+
+		result, err := deploymentTemplate.Evaluate(vars, ops)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(result.Content())).To(Equal("name: fake-name\npassword: fake-password\n"))
+		Expect(string(result.Template())).To(Equal("name: fake-name\npassword: ((password))\n"))
+	})
 })
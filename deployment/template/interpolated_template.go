@@ -3,8 +3,9 @@ package template
 import ()
 
 type InterpolatedTemplate struct {
-	content []byte
-	sha     string
+	content  []byte
+	sha      string
+	template []byte
 }
 
 func (t *InterpolatedTemplate) Content() []byte {
@@ -15,6 +16,12 @@ func (t *InterpolatedTemplate) SHA() string {
 	return t.sha
 }
 
+// Template returns the manifest with the ops applied but its variables left
+// as ((references)), so that it can be recorded without their values.
+func (t *InterpolatedTemplate) Template() []byte {
+	return t.template
+}
+
 func NewInterpolatedTemplate(content []byte, shaSumString string) InterpolatedTemplate {
 	return InterpolatedTemplate{
 		content: content,
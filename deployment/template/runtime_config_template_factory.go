@@ -0,0 +1,247 @@
+package template
+
+import (
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"github.com/cppforlife/go-patch/patch"
+	"gopkg.in/yaml.v2"
+
+	biutil "github.com/cloudfoundry/bosh-cli/common/util"
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+)
+
+// instanceGroupSections are the manifest sections listing instance groups
+// and the key their release jobs are listed under by default.
+var instanceGroupSections = [][2]string{
+	{"jobs", "templates"},
+	{"instance_groups", "jobs"},
+}
+
+type runtimeConfigTemplateFactory struct {
+	fs                boshsys.FileSystem
+	templateFactory   DeploymentTemplateFactory
+	runtimeConfigPath string
+}
+
+// NewRuntimeConfigTemplateFactory returns a factory whose templates are the
+// templates of the given factory with the runtime config merged in before any
+// variables or ops are applied, so that ops files apply to the addons too.
+//
+// Runtime config releases are added unless the manifest declares a release
+// with the same name. Addon jobs are added to every instance group.
+func NewRuntimeConfigTemplateFactory(fs boshsys.FileSystem, templateFactory DeploymentTemplateFactory, runtimeConfigPath string) DeploymentTemplateFactory {
+	return runtimeConfigTemplateFactory{
+		fs:                fs,
+		templateFactory:   templateFactory,
+		runtimeConfigPath: runtimeConfigPath,
+	}
+}
+
+func (t runtimeConfigTemplateFactory) NewDeploymentTemplateFromPath(path string) (DeploymentTemplate, error) {
+	template, err := t.templateFactory.NewDeploymentTemplateFromPath(path)
+	if err != nil {
+		return DeploymentTemplate{}, err
+	}
+
+	// variables are left as ((references)) to be evaluated with the ops
+	contents, err := template.template.Evaluate(boshtpl.StaticVariables{}, patch.Ops{}, boshtpl.EvaluateOpts{})
+	if err != nil {
+		return DeploymentTemplate{}, bosherr.WrapErrorf(err, "Reading deployment manifest '%s'", path)
+	}
+
+	runtimeConfigContents, err := biutil.ReadManifest(t.runtimeConfigPath, t.fs)
+	if err != nil {
+		return DeploymentTemplate{}, bosherr.WrapErrorf(err, "Reading runtime config '%s'", t.runtimeConfigPath)
+	}
+
+	var manifest map[interface{}]interface{}
+
+	err = yaml.Unmarshal(contents, &manifest)
+	if err != nil {
+		return DeploymentTemplate{}, bosherr.WrapErrorf(err, "Unmarshalling deployment manifest '%s'", path)
+	}
+
+	var runtimeConfig map[interface{}]interface{}
+
+	err = yaml.Unmarshal(runtimeConfigContents, &runtimeConfig)
+	if err != nil {
+		return DeploymentTemplate{}, bosherr.WrapErrorf(err, "Unmarshalling runtime config '%s'", t.runtimeConfigPath)
+	}
+
+	if manifest == nil {
+		manifest = map[interface{}]interface{}{}
+	}
+
+	err = t.mergeReleases(manifest, runtimeConfig)
+	if err != nil {
+		return DeploymentTemplate{}, bosherr.WrapErrorf(err, "Merging runtime config '%s'", t.runtimeConfigPath)
+	}
+
+	err = mergeAddons(manifest, runtimeConfig)
+	if err != nil {
+		return DeploymentTemplate{}, bosherr.WrapErrorf(err, "Merging runtime config '%s'", t.runtimeConfigPath)
+	}
+
+	mergedContents, err := yaml.Marshal(manifest)
+	if err != nil {
+		return DeploymentTemplate{}, bosherr.WrapError(err, "Marshalling merged deployment manifest")
+	}
+
+	return NewDeploymentTemplate(mergedContents), nil
+}
+
+// mergeReleases adds the runtime config releases missing from the manifest.
+// Their local paths are resolved against the runtime config as they would
+// otherwise be resolved against the manifest.
+func (t runtimeConfigTemplateFactory) mergeReleases(manifest, runtimeConfig map[interface{}]interface{}) error {
+	releases, err := listSection(runtimeConfig, "releases")
+	if err != nil || releases == nil {
+		return err
+	}
+
+	for _, release := range releases {
+		releaseMap, ok := release.(map[interface{}]interface{})
+		if !ok {
+			return bosherr.Error("Expected 'releases' entries to be hashes")
+		}
+
+		err = t.absolutifyURL(releaseMap)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Resolving release '%v' path", releaseMap["name"])
+		}
+
+		if signature, ok := releaseMap["signature"].(map[interface{}]interface{}); ok {
+			err = t.absolutifyURL(signature)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Resolving release '%v' signature path", releaseMap["name"])
+			}
+		}
+	}
+
+	return mergeNamedSection(manifest, runtimeConfig, "releases")
+}
+
+func (t runtimeConfigTemplateFactory) absolutifyURL(entry map[interface{}]interface{}) error {
+	url, ok := entry["url"].(string)
+	if !ok || strings.HasPrefix(url, "((") {
+		return nil
+	}
+
+	absURL, err := biutil.AbsolutifyPath(t.runtimeConfigPath, url, t.fs)
+	if err != nil {
+		return err
+	}
+
+	entry["url"] = absURL
+
+	return nil
+}
+
+// mergeAddons adds the jobs of every addon to every instance group. Addon
+// properties apply to addon jobs that do not declare their own.
+func mergeAddons(manifest, runtimeConfig map[interface{}]interface{}) error {
+	addons, err := listSection(runtimeConfig, "addons")
+	if err != nil || addons == nil {
+		return err
+	}
+
+	addonJobs := []interface{}{}
+
+	for _, addon := range addons {
+		addonMap, ok := addon.(map[interface{}]interface{})
+		if !ok {
+			return bosherr.Error("Expected 'addons' entries to be hashes")
+		}
+
+		if addonMap["include"] != nil || addonMap["exclude"] != nil {
+			return bosherr.Errorf("Addon '%v' include and exclude rules are not supported", addonMap["name"])
+		}
+
+		jobs, err := listSection(addonMap, "jobs")
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Reading addon '%v'", addonMap["name"])
+		}
+
+		for _, job := range jobs {
+			jobMap, ok := job.(map[interface{}]interface{})
+			if !ok {
+				return bosherr.Errorf("Expected addon '%v' jobs to be hashes", addonMap["name"])
+			}
+
+			addonJob := map[interface{}]interface{}{}
+			for key, value := range jobMap {
+				addonJob[key] = value
+			}
+
+			if addonJob["properties"] == nil && addonMap["properties"] != nil {
+				addonJob["properties"] = addonMap["properties"]
+			}
+
+			addonJobs = append(addonJobs, addonJob)
+		}
+	}
+
+	for _, section := range instanceGroupSections {
+		groups, err := listSection(manifest, section[0])
+		if err != nil {
+			return bosherr.WrapError(err, "Reading manifest")
+		}
+
+		for _, group := range groups {
+			groupMap, ok := group.(map[interface{}]interface{})
+			if !ok {
+				return bosherr.Errorf("Expected manifest '%s' entries to be hashes", section[0])
+			}
+
+			jobsKey := section[1]
+			if groupMap["templates"] != nil {
+				jobsKey = "templates"
+			} else if groupMap["jobs"] != nil {
+				jobsKey = "jobs"
+			}
+
+			jobs, err := listSection(groupMap, jobsKey)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Reading instance group '%v'", groupMap["name"])
+			}
+
+			names := map[interface{}]bool{}
+
+			for _, job := range jobs {
+				if jobMap, ok := job.(map[interface{}]interface{}); ok {
+					names[jobMap["name"]] = true
+				}
+			}
+
+			for _, addonJob := range addonJobs {
+				name := addonJob.(map[interface{}]interface{})["name"]
+				if names[name] {
+					return bosherr.Errorf("Addon job '%v' collides with a job in instance group '%v'", name, groupMap["name"])
+				}
+
+				names[name] = true
+				jobs = append(jobs, addonJob)
+			}
+
+			groupMap[jobsKey] = jobs
+		}
+	}
+
+	return nil
+}
+
+func listSection(config map[interface{}]interface{}, section string) ([]interface{}, error) {
+	entries, found := config[section]
+	if !found || entries == nil {
+		return nil, nil
+	}
+
+	list, ok := entries.([]interface{})
+	if !ok {
+		return nil, bosherr.Errorf("Expected '%s' to be a list", section)
+	}
+
+	return list, nil
+}
@@ -0,0 +1,215 @@
+package template_test
+
+import (
+	"github.com/cppforlife/go-patch/patch"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/deployment/template"
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("RuntimeConfigTemplateFactory", func() {
+	var (
+		fakeFs          *fakesys.FakeFileSystem
+		templateFactory DeploymentTemplateFactory
+	)
+
+	BeforeEach(func() {
+		fakeFs = fakesys.NewFakeFileSystem()
+		templateFactory = NewRuntimeConfigTemplateFactory(fakeFs, NewDeploymentTemplateFactory(fakeFs), "/path/to/configs/runtime-config.yml")
+
+		fakeFs.WriteFileString("/path/to/deployment.yml", `---
+name: fake-deployment
+releases:
+- name: bosh
+  url: file://bosh.tgz
+jobs:
+- name: bosh
+  templates:
+  - name: director
+    release: bosh
+instance_groups:
+- name: other
+  jobs:
+  - name: nats
+    release: bosh
+`)
+	})
+
+	It("adds the addon jobs to every instance group", func() {
+		fakeFs.WriteFileString("/path/to/configs/runtime-config.yml", `---
+addons:
+- name: os-configuration
+  jobs:
+  - name: user_add
+    release: os-conf
+  - name: login_banner
+    release: os-conf
+    properties:
+      login_banner: {text: own}
+  properties:
+    users: ((users))
+`)
+
+		template, err := templateFactory.NewDeploymentTemplateFromPath("/path/to/deployment.yml")
+		Expect(err).ToNot(HaveOccurred())
+
+		interpolatedTemplate, err := template.Evaluate(boshtpl.StaticVariables{"users": "fake-users"}, patch.Ops{})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(string(interpolatedTemplate.Content())).To(Equal(`instance_groups:
+- jobs:
+  - name: nats
+    release: bosh
+  - name: user_add
+    properties:
+      users: fake-users
+    release: os-conf
+  - name: login_banner
+    properties:
+      login_banner:
+        text: own
+    release: os-conf
+  name: other
+jobs:
+- name: bosh
+  templates:
+  - name: director
+    release: bosh
+  - name: user_add
+    properties:
+      users: fake-users
+    release: os-conf
+  - name: login_banner
+    properties:
+      login_banner:
+        text: own
+    release: os-conf
+name: fake-deployment
+releases:
+- name: bosh
+  url: file://bosh.tgz
+`))
+	})
+
+	It("adds the releases missing from the manifest, resolving their paths against the runtime config", func() {
+		fakeFs.WriteFileString("/path/to/configs/runtime-config.yml", `---
+releases:
+- name: bosh
+  url: file://other-bosh.tgz
+- name: os-conf
+  url: file://os-conf.tgz
+  signature: {url: os-conf.tgz.sig}
+- name: syslog
+  url: ((syslog_url))
+`)
+
+		template, err := templateFactory.NewDeploymentTemplateFromPath("/path/to/deployment.yml")
+		Expect(err).ToNot(HaveOccurred())
+
+		interpolatedTemplate, err := template.Evaluate(boshtpl.StaticVariables{"syslog_url": "https://syslog.tgz"}, patch.Ops{})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(string(interpolatedTemplate.Content())).To(ContainSubstring(`releases:
+- name: bosh
+  url: file://bosh.tgz
+- name: os-conf
+  signature:
+    url: /path/to/configs/os-conf.tgz.sig
+  url: file:///path/to/configs/os-conf.tgz
+- name: syslog
+  url: https://syslog.tgz
+`))
+	})
+
+	It("applies the ops to the merged runtime config", func() {
+		fakeFs.WriteFileString("/path/to/configs/runtime-config.yml", `---
+releases:
+- name: os-conf
+  url: https://os-conf.tgz
+addons:
+- name: os-configuration
+  jobs:
+  - name: user_add
+    release: os-conf
+`)
+
+		template, err := templateFactory.NewDeploymentTemplateFromPath("/path/to/deployment.yml")
+		Expect(err).ToNot(HaveOccurred())
+
+		ops := patch.Ops{
+			patch.ReplaceOp{Path: patch.MustNewPointerFromString("/releases/name=os-conf/url"), Value: "https://other-os-conf.tgz"},
+			patch.ReplaceOp{Path: patch.MustNewPointerFromString("/instance_groups/name=other/jobs/name=user_add/properties?"), Value: map[interface{}]interface{}{"users": "fake-users"}},
+		}
+
+		interpolatedTemplate, err := template.Evaluate(boshtpl.StaticVariables{}, ops)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(string(interpolatedTemplate.Content())).To(ContainSubstring(`  - name: user_add
+    properties:
+      users: fake-users
+    release: os-conf
+  name: other
+`))
+		Expect(string(interpolatedTemplate.Content())).To(ContainSubstring(`- name: os-conf
+  url: https://other-os-conf.tgz
+`))
+	})
+
+	It("merges the runtime config into the cloud config merged manifest", func() {
+		fakeFs.WriteFileString("/path/to/cloud-config.yml", `---
+networks:
+- name: private
+  type: dynamic
+`)
+		fakeFs.WriteFileString("/path/to/configs/runtime-config.yml", "addons: []")
+
+		templateFactory = NewRuntimeConfigTemplateFactory(fakeFs, NewCloudConfigTemplateFactory(fakeFs, "/path/to/cloud-config.yml"), "/path/to/configs/runtime-config.yml")
+
+		template, err := templateFactory.NewDeploymentTemplateFromPath("/path/to/deployment.yml")
+		Expect(err).ToNot(HaveOccurred())
+
+		interpolatedTemplate, err := template.Evaluate(boshtpl.StaticVariables{}, patch.Ops{})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(string(interpolatedTemplate.Content())).To(ContainSubstring(`networks:
+- name: private
+  type: dynamic
+`))
+	})
+
+	It("returns an error when an addon job collides with an instance group job", func() {
+		fakeFs.WriteFileString("/path/to/configs/runtime-config.yml", `---
+addons:
+- name: extra
+  jobs:
+  - name: director
+    release: os-conf
+`)
+
+		_, err := templateFactory.NewDeploymentTemplateFromPath("/path/to/deployment.yml")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Addon job 'director' collides with a job in instance group 'bosh'"))
+	})
+
+	It("returns an error when an addon has include or exclude rules", func() {
+		fakeFs.WriteFileString("/path/to/configs/runtime-config.yml", `---
+addons:
+- name: extra
+  exclude: {deployments: [bosh]}
+  jobs: []
+`)
+
+		_, err := templateFactory.NewDeploymentTemplateFromPath("/path/to/deployment.yml")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Addon 'extra' include and exclude rules are not supported"))
+	})
+
+	It("returns an error when the runtime config cannot be read", func() {
+		_, err := templateFactory.NewDeploymentTemplateFromPath("/path/to/deployment.yml")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Reading runtime config '/path/to/configs/runtime-config.yml'"))
+	})
+})
@@ -0,0 +1,27 @@
+package template
+
+type varSourcesTemplateFactory struct {
+	templateFactory DeploymentTemplateFactory
+	varSources      []string
+}
+
+// NewVarSourcesTemplateFactory returns a factory whose templates are the
+// templates of the given factory listing the variable sources, e.g. the
+// variable flags given, in errors about missing variables.
+func NewVarSourcesTemplateFactory(templateFactory DeploymentTemplateFactory, varSources []string) DeploymentTemplateFactory {
+	return varSourcesTemplateFactory{
+		templateFactory: templateFactory,
+		varSources:      varSources,
+	}
+}
+
+func (t varSourcesTemplateFactory) NewDeploymentTemplateFromPath(path string) (DeploymentTemplate, error) {
+	template, err := t.templateFactory.NewDeploymentTemplateFromPath(path)
+	if err != nil {
+		return DeploymentTemplate{}, err
+	}
+
+	template.varSources = t.varSources
+
+	return template, nil
+}
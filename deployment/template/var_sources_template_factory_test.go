@@ -0,0 +1,50 @@
+package template_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/deployment/template"
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("VarSourcesTemplateFactory", func() {
+	var (
+		fakeFs          *fakesys.FakeFileSystem
+		templateFactory DeploymentTemplateFactory
+	)
+
+	BeforeEach(func() {
+		fakeFs = fakesys.NewFakeFileSystem()
+		templateFactory = NewVarSourcesTemplateFactory(NewDeploymentTemplateFactory(fakeFs), []string{"--var", "--vars-file"})
+
+		fakeFs.WriteFileString("/path/to/deployment.yml", `---
+name: ((name))
+director_uuid: ((director_uuid))
+`)
+	})
+
+	It("lists the variable sources in errors about missing variables", func() {
+		template, err := templateFactory.NewDeploymentTemplateFromPath("/path/to/deployment.yml")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = template.Evaluate(boshtpl.StaticVariables{"name": "fake-deployment"}, nil)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Expected to find variables (searched --var, --vars-file): director_uuid"))
+	})
+
+	It("evaluates the templates of the given factory", func() {
+		template, err := templateFactory.NewDeploymentTemplateFromPath("/path/to/deployment.yml")
+		Expect(err).ToNot(HaveOccurred())
+
+		interpolatedTemplate, err := template.Evaluate(boshtpl.StaticVariables{"name": "fake-deployment", "director_uuid": "fake-uuid"}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(interpolatedTemplate.Content())).To(Equal("director_uuid: fake-uuid\nname: fake-deployment\n"))
+	})
+
+	It("returns an error when the given factory fails", func() {
+		_, err := templateFactory.NewDeploymentTemplateFromPath("/path/to/missing.yml")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -15,20 +15,26 @@ import (
 // DiskDeployer is in the vm package to avoid a [disk -> vm -> disk] dependency cycle
 type DiskDeployer interface {
 	Deploy(diskPool bideplmanifest.DiskPool, cloud bicloud.Cloud, vm VM, eventLoggerStage biui.Stage) ([]bidisk.Disk, error)
+	DeployNamed(namedDisks []bideplmanifest.NamedDisk, cloud bicloud.Cloud, vm VM, eventLoggerStage biui.Stage) ([]bidisk.Disk, error)
 }
 
 type diskDeployer struct {
 	diskRepo           biconfig.DiskRepo
 	diskManagerFactory bidisk.ManagerFactory
 	diskManager        bidisk.Manager
+	skipMigration      bool
 	logger             boshlog.Logger
 	logTag             string
 }
 
-func NewDiskDeployer(diskManagerFactory bidisk.ManagerFactory, diskRepo biconfig.DiskRepo, logger boshlog.Logger) DiskDeployer {
+// NewDiskDeployer returns a DiskDeployer that migrates the content of the
+// current disk to a new disk when the disk pool changes, unless
+// skipMigration is set, in which case the current disk is kept as it is.
+func NewDiskDeployer(diskManagerFactory bidisk.ManagerFactory, diskRepo biconfig.DiskRepo, skipMigration bool, logger boshlog.Logger) DiskDeployer {
 	return &diskDeployer{
 		diskManagerFactory: diskManagerFactory,
 		diskRepo:           diskRepo,
+		skipMigration:      skipMigration,
 		logger:             logger,
 		logTag:             "diskDeployer",
 	}
@@ -61,7 +67,7 @@ func (d *diskDeployer) Deploy(diskPool bideplmanifest.DiskPool, cloud bicloud.Cl
 		}
 	}
 
-	err = d.diskManager.DeleteUnused(stage)
+	err = d.diskManager.OrphanUnused(stage)
 	if err != nil {
 		return disks, err
 	}
@@ -69,6 +75,78 @@ func (d *diskDeployer) Deploy(diskPool bideplmanifest.DiskPool, cloud bicloud.Cl
 	return disks, nil
 }
 
+// DeployNamed attaches and mounts the named disks in addition to the current
+// disk, creating the ones that do not exist yet and migrating the ones whose
+// disk pool changed, and orphans the named disks that are no longer in the
+// manifest. The disks are returned in the order of the manifest.
+func (d *diskDeployer) DeployNamed(namedDisks []bideplmanifest.NamedDisk, cloud bicloud.Cloud, vm VM, stage biui.Stage) ([]bidisk.Disk, error) {
+	disks := []bidisk.Disk{}
+
+	d.diskManager = d.diskManagerFactory.NewManager(cloud)
+	currentDisks, err := d.diskManager.FindCurrentNamed()
+	if err != nil {
+		return disks, bosherr.WrapError(err, "Finding existing named disks")
+	}
+
+	manifestDiskNames := map[string]bool{}
+
+	for _, namedDisk := range namedDisks {
+		manifestDiskNames[namedDisk.Name] = true
+
+		disk, found := currentDisks[namedDisk.Name]
+		if found {
+			disk, err = d.deployExistingNamedDisk(namedDisk, disk, vm, stage)
+			if err != nil {
+				return disks, err
+			}
+
+			disks = append(disks, disk)
+			continue
+		}
+
+		stageName := fmt.Sprintf("Creating disk '%s'", namedDisk.Name)
+		err = stage.Perform(stageName, func() error {
+			disk, err = d.diskManager.Create(namedDisk.DiskPool, vm.CID())
+			return err
+		})
+		if err != nil {
+			return disks, err
+		}
+
+		err = d.attachDisk(disk, vm, stage)
+		if err != nil {
+			return disks, err
+		}
+
+		disks = append(disks, disk)
+
+		err = d.updateCurrentNamedDiskRecord(namedDisk.Name, disk)
+		if err != nil {
+			return disks, err
+		}
+	}
+
+	removedDisks := false
+	for name := range currentDisks {
+		if !manifestDiskNames[name] {
+			err = d.diskRepo.ClearCurrentNamed(name)
+			if err != nil {
+				return disks, bosherr.WrapErrorf(err, "Clearing current disk record of disk '%s'", name)
+			}
+			removedDisks = true
+		}
+	}
+
+	if removedDisks {
+		err = d.diskManager.OrphanUnused(stage)
+		if err != nil {
+			return disks, err
+		}
+	}
+
+	return disks, nil
+}
+
 func (d *diskDeployer) deployExistingDisk(disk bidisk.Disk, diskPool bideplmanifest.DiskPool, vm VM, stage biui.Stage) ([]bidisk.Disk, error) {
 	disks := []bidisk.Disk{}
 
@@ -82,7 +160,12 @@ func (d *diskDeployer) deployExistingDisk(disk bidisk.Disk, diskPool bideplmanif
 	}
 
 	if disk.NeedsMigration(diskPool.DiskSize, diskPool.CloudProperties) {
-		disk, err = d.migrateDisk(disk, diskPool, vm, stage)
+		if d.skipMigration {
+			err = d.skipMigrationOf(disk, diskPool, stage)
+			return disks, err
+		}
+
+		disk, err = d.migrateDisk(disk, diskPool, vm, stage, d.updateCurrentDiskRecord)
 		if err != nil {
 			return disks, err
 		}
@@ -94,6 +177,43 @@ func (d *diskDeployer) deployExistingDisk(disk bidisk.Disk, diskPool bideplmanif
 	return disks, nil
 }
 
+func (d *diskDeployer) deployExistingNamedDisk(namedDisk bideplmanifest.NamedDisk, disk bidisk.Disk, vm VM, stage biui.Stage) (bidisk.Disk, error) {
+	// attach is idempotent
+	err := d.attachDisk(disk, vm, stage)
+	if err != nil {
+		return disk, err
+	}
+
+	if !disk.NeedsMigration(namedDisk.DiskPool.DiskSize, namedDisk.DiskPool.CloudProperties) {
+		return disk, nil
+	}
+
+	if d.skipMigration {
+		return disk, d.skipMigrationOf(disk, namedDisk.DiskPool, stage)
+	}
+
+	return d.migrateDisk(disk, namedDisk.DiskPool, vm, stage, func(newDisk bidisk.Disk) error {
+		return d.updateCurrentNamedDiskRecord(namedDisk.Name, newDisk)
+	})
+}
+
+// skipMigrationOf keeps the disk as it is, but records the size and cloud
+// properties of the changed disk pool, so that the deployment state matches
+// the manifest and later deploys do not migrate the disk either.
+func (d *diskDeployer) skipMigrationOf(disk bidisk.Disk, diskPool bideplmanifest.DiskPool, stage biui.Stage) error {
+	d.logger.Warn(d.logTag, "Skipping migration of disk '%s' to the changed disk pool", disk.CID())
+
+	stageName := fmt.Sprintf("Skipping migration of disk '%s'", disk.CID())
+	return stage.Perform(stageName, func() error {
+		err := d.diskRepo.Update(disk.CID(), diskPool.DiskSize, diskPool.CloudProperties)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Updating disk record of disk '%s'", disk.CID())
+		}
+
+		return nil
+	})
+}
+
 func (d *diskDeployer) deployNewDisk(diskPool bideplmanifest.DiskPool, vm VM, stage biui.Stage) ([]bidisk.Disk, error) {
 	disks := []bidisk.Disk{}
 
@@ -123,9 +243,21 @@ func (d *diskDeployer) migrateDisk(
 	diskPool bideplmanifest.DiskPool,
 	vm VM,
 	stage biui.Stage,
+	updateDiskRecord func(bidisk.Disk) error,
 ) (newDisk bidisk.Disk, err error) {
 	d.logger.Debug(d.logTag, "Migrating disk '%s'", originalDisk.CID())
 
+	// migrating from a disk that is not mounted would copy nothing before the disk is orphaned
+	err = d.verifyMounted(vm, originalDisk.CID(), "before migrating its content")
+	if err != nil {
+		return nil, err
+	}
+
+	originalChecksum, err := vm.DiskChecksum(originalDisk)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Verifying content of disk '%s' before migrating it", originalDisk.CID())
+	}
+
 	err = stage.Perform("Creating disk", func() error {
 		newDisk, err = d.diskManager.Create(diskPool, vm.CID())
 		return err
@@ -144,13 +276,23 @@ func (d *diskDeployer) migrateDisk(
 
 	stageName = fmt.Sprintf("Migrating disk content from '%s' to '%s'", originalDisk.CID(), newDisk.CID())
 	err = stage.Perform(stageName, func() error {
-		return vm.MigrateDisk()
+		err := vm.MigrateDisk()
+		if err != nil {
+			return err
+		}
+
+		err = d.verifyMounted(vm, newDisk.CID(), "after migrating content to it")
+		if err != nil {
+			return err
+		}
+
+		return d.verifyContent(vm, newDisk, originalDisk, originalChecksum)
 	})
 	if err != nil {
 		return newDisk, err
 	}
 
-	err = d.updateCurrentDiskRecord(newDisk)
+	err = updateDiskRecord(newDisk)
 	if err != nil {
 		return newDisk, err
 	}
@@ -163,10 +305,8 @@ func (d *diskDeployer) migrateDisk(
 		return newDisk, err
 	}
 
-	stageName = fmt.Sprintf("Deleting disk '%s'", originalDisk.CID())
-	err = stage.Perform(stageName, func() error {
-		return originalDisk.Delete()
-	})
+	stageName = fmt.Sprintf("Orphaning disk '%s'", originalDisk.CID())
+	err = stage.Perform(stageName, originalDisk.Orphan)
 	if err != nil {
 		return newDisk, err
 	}
@@ -174,6 +314,40 @@ func (d *diskDeployer) migrateDisk(
 	return newDisk, nil
 }
 
+// verifyMounted asks the agent for the mounted disks, so that the original
+// disk is only orphaned once the agent confirms the migrated content is in use.
+func (d *diskDeployer) verifyMounted(vm VM, diskCID string, when string) error {
+	mountedDisks, err := vm.Disks()
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Verifying disk '%s' is mounted %s", diskCID, when)
+	}
+
+	for _, disk := range mountedDisks {
+		if disk.CID() == diskCID {
+			return nil
+		}
+	}
+
+	return bosherr.Errorf("Expected disk '%s' to be mounted %s, the agent reports it is not", diskCID, when)
+}
+
+// verifyContent compares the checksum of the migrated content with the one of
+// the original disk, so that the original disk is only orphaned once the
+// agent confirms the content was copied unchanged.
+func (d *diskDeployer) verifyContent(vm VM, newDisk bidisk.Disk, originalDisk bidisk.Disk, originalChecksum string) error {
+	checksum, err := vm.DiskChecksum(newDisk)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Verifying content of disk '%s' after migrating content to it", newDisk.CID())
+	}
+
+	if checksum != originalChecksum {
+		return bosherr.Errorf("Expected content of disk '%s' to match disk '%s' after migrating content to it, the checksums differ ('%s' != '%s')",
+			newDisk.CID(), originalDisk.CID(), checksum, originalChecksum)
+	}
+
+	return nil
+}
+
 func (d *diskDeployer) updateCurrentDiskRecord(disk bidisk.Disk) error {
 	savedDiskRecord, found, err := d.diskRepo.Find(disk.CID())
 	if err != nil {
@@ -192,6 +366,24 @@ func (d *diskDeployer) updateCurrentDiskRecord(disk bidisk.Disk) error {
 	return nil
 }
 
+func (d *diskDeployer) updateCurrentNamedDiskRecord(name string, disk bidisk.Disk) error {
+	savedDiskRecord, found, err := d.diskRepo.Find(disk.CID())
+	if err != nil {
+		return bosherr.WrapError(err, "Finding disk record")
+	}
+
+	if !found {
+		return bosherr.Errorf("Failed to find disk record for new disk '%s'", name)
+	}
+
+	err = d.diskRepo.UpdateCurrentNamed(name, savedDiskRecord.ID)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Updating current disk record of disk '%s'", name)
+	}
+
+	return nil
+}
+
 func (d *diskDeployer) createDisk(diskPool bideplmanifest.DiskPool, vm VM, stage biui.Stage) (disk bidisk.Disk, err error) {
 	err = stage.Perform("Creating disk", func() error {
 		disk, err = d.diskManager.Create(diskPool, vm.CID())
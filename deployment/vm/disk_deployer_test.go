@@ -47,6 +47,7 @@ var _ = Describe("DiskDeployer", func() {
 		diskDeployer = NewDiskDeployer(
 			fakeDiskManagerFactory,
 			fakeDiskRepo,
+			false,
 			logger,
 		)
 
@@ -119,6 +120,8 @@ var _ = Describe("DiskDeployer", func() {
 					}
 
 					fakeDiskRepo.SetFindBehavior("fake-secondary-disk-cid", secondaryDiskRecord, true, nil)
+
+					fakeVM.ListDisksDisks = []bidisk.Disk{existingDisk, secondaryDisk}
 				})
 
 				It("creates secondary disk", func() {
@@ -173,6 +176,17 @@ var _ = Describe("DiskDeployer", func() {
 					}))
 				})
 
+				It("orphans the existing disk instead of deleting it", func() {
+					_, err := diskDeployer.Deploy(diskPool, cloud, fakeVM, fakeStage)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(existingDisk.OrphanCalledTimes).To(Equal(1))
+					Expect(existingDisk.DeleteCalledTimes).To(Equal(0))
+
+					Expect(fakeStage.PerformCalls[5]).To(Equal(&fakebiui.PerformCall{
+						Name: "Orphaning disk 'fake-existing-disk-cid'",
+					}))
+				})
+
 				It("promotes secondary disk as primary", func() {
 					_, err := diskDeployer.Deploy(diskPool, cloud, fakeVM, fakeStage)
 					Expect(err).NotTo(HaveOccurred())
@@ -250,6 +264,115 @@ var _ = Describe("DiskDeployer", func() {
 					})
 				})
 
+				Context("when the existing disk is not mounted", func() {
+					BeforeEach(func() {
+						fakeVM.ListDisksDisks = []bidisk.Disk{}
+					})
+
+					It("returns error without creating a disk", func() {
+						_, err := diskDeployer.Deploy(diskPool, cloud, fakeVM, fakeStage)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("Expected disk 'fake-existing-disk-cid' to be mounted before migrating its content"))
+						Expect(fakeDiskManager.CreateInputs).To(BeEmpty())
+						Expect(fakeVM.MigrateDiskCalledTimes).To(Equal(0))
+					})
+				})
+
+				Context("when the new disk is not mounted after migration", func() {
+					BeforeEach(func() {
+						fakeVM.ListDisksDisks = []bidisk.Disk{existingDisk}
+					})
+
+					It("returns error and keeps the existing disk as current", func() {
+						_, err := diskDeployer.Deploy(diskPool, cloud, fakeVM, fakeStage)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("Expected disk 'fake-secondary-disk-cid' to be mounted after migrating content to it"))
+						Expect(fakeVM.DetachDiskInputs).To(Equal([]fakebivm.DetachDiskInput{}))
+						Expect(fakeDiskRepo.UpdateCurrentInputs).To(BeEmpty())
+						Expect(existingDisk.DeleteCalledTimes).To(Equal(0))
+					})
+				})
+
+				It("compares the checksums of the disks before and after migrating", func() {
+					_, err := diskDeployer.Deploy(diskPool, cloud, fakeVM, fakeStage)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(fakeVM.DiskChecksumInputs).To(Equal([]string{"fake-existing-disk-cid", "fake-secondary-disk-cid"}))
+				})
+
+				Context("when the migrated content does not match the existing disk", func() {
+					BeforeEach(func() {
+						fakeVM.DiskChecksums = map[string]string{
+							"fake-existing-disk-cid":  "fake-existing-checksum",
+							"fake-secondary-disk-cid": "fake-secondary-checksum",
+						}
+					})
+
+					It("returns error and keeps the existing disk as current", func() {
+						_, err := diskDeployer.Deploy(diskPool, cloud, fakeVM, fakeStage)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("Expected content of disk 'fake-secondary-disk-cid' to match disk 'fake-existing-disk-cid' after migrating content to it"))
+						Expect(fakeVM.DetachDiskInputs).To(Equal([]fakebivm.DetachDiskInput{}))
+						Expect(fakeDiskRepo.UpdateCurrentInputs).To(BeEmpty())
+						Expect(existingDisk.OrphanCalledTimes).To(Equal(0))
+					})
+				})
+
+				Context("when the checksum of the existing disk cannot be computed", func() {
+					BeforeEach(func() {
+						fakeVM.DiskChecksumErr = bosherr.Error("fake-checksum-error")
+					})
+
+					It("returns error without creating a disk", func() {
+						_, err := diskDeployer.Deploy(diskPool, cloud, fakeVM, fakeStage)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("fake-checksum-error"))
+						Expect(fakeDiskManager.CreateInputs).To(BeEmpty())
+					})
+				})
+
+				Context("when listing the mounted disks fails", func() {
+					BeforeEach(func() {
+						fakeVM.ListDisksErr = bosherr.Error("fake-list-disks-error")
+					})
+
+					It("returns error", func() {
+						_, err := diskDeployer.Deploy(diskPool, cloud, fakeVM, fakeStage)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("fake-list-disks-error"))
+						Expect(fakeDiskManager.CreateInputs).To(BeEmpty())
+					})
+				})
+
+				Context("when disk migration is skipped", func() {
+					BeforeEach(func() {
+						logger := boshlog.NewLogger(boshlog.LevelNone)
+						fakeDiskManagerFactory := fakebidisk.NewFakeManagerFactory()
+						fakeDiskManagerFactory.NewManagerManager = fakeDiskManager
+						diskDeployer = NewDiskDeployer(fakeDiskManagerFactory, fakeDiskRepo, true, logger)
+					})
+
+					It("keeps the existing disk", func() {
+						disks, err := diskDeployer.Deploy(diskPool, cloud, fakeVM, fakeStage)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(disks).To(Equal([]bidisk.Disk{existingDisk}))
+
+						Expect(fakeDiskManager.CreateInputs).To(BeEmpty())
+						Expect(fakeVM.MigrateDiskCalledTimes).To(Equal(0))
+						Expect(fakeStage.PerformCalls).To(Equal([]*fakebiui.PerformCall{
+							{Name: "Attaching disk 'fake-existing-disk-cid' to VM 'fake-vm-cid'"},
+							{Name: "Skipping migration of disk 'fake-existing-disk-cid'"},
+						}))
+					})
+
+					It("records the size and cloud properties of the changed disk pool", func() {
+						_, err := diskDeployer.Deploy(diskPool, cloud, fakeVM, fakeStage)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(fakeDiskRepo.UpdateInputs).To(Equal([]fakebiconfig.DiskRepoSaveInput{
+							{CID: "fake-existing-disk-cid", Size: 1024, CloudProperties: diskPool.CloudProperties},
+						}))
+					})
+				})
+
 				Context("when migration to the new disk fails", func() {
 					var (
 						migrateError = bosherr.Error("fake-migrate-disk-error")
@@ -332,22 +455,23 @@ var _ = Describe("DiskDeployer", func() {
 			}))
 		})
 
-		It("removes unused disks", func() {
+		It("orphans unused disks instead of deleting them", func() {
 			_, err := diskDeployer.Deploy(diskPool, cloud, fakeVM, fakeStage)
 			Expect(err).ToNot(HaveOccurred())
 
-			Expect(fakeDiskManager.DeleteUnusedCalledTimes).To(Equal(1))
+			Expect(fakeDiskManager.OrphanUnusedCalledTimes).To(Equal(1))
+			Expect(fakeDiskManager.DeleteUnusedCalledTimes).To(Equal(0))
 		})
 
-		Context("when removing unused disk fails", func() {
+		Context("when orphaning unused disks fails", func() {
 			BeforeEach(func() {
-				fakeDiskManager.DeleteUnusedErr = bosherr.Error("fake-delete-error")
+				fakeDiskManager.OrphanUnusedErr = bosherr.Error("fake-orphan-error")
 			})
 
 			It("returns an error", func() {
 				_, err := diskDeployer.Deploy(diskPool, cloud, fakeVM, fakeStage)
 				Expect(err).To(HaveOccurred())
-				Expect(err.Error()).To(ContainSubstring("fake-delete-error"))
+				Expect(err.Error()).To(ContainSubstring("fake-orphan-error"))
 			})
 		})
 
@@ -422,4 +546,146 @@ var _ = Describe("DiskDeployer", func() {
 			Expect(fakeDiskManager.CreateInputs).To(BeEmpty())
 		})
 	})
+
+	Describe("DeployNamed", func() {
+		var namedDisks []bideplmanifest.NamedDisk
+
+		BeforeEach(func() {
+			namedDisks = []bideplmanifest.NamedDisk{
+				{
+					Name: "fake-disk-name",
+					DiskPool: bideplmanifest.DiskPool{
+						Name:     "fake-disk-pool-name",
+						DiskSize: 2048,
+						CloudProperties: biproperty.Map{
+							"fake-disk-pool-cloud-property-key": "fake-disk-pool-cloud-property-value",
+						},
+					},
+				},
+			}
+		})
+
+		Context("when the named disk does not exist", func() {
+			It("creates the disk, attaches and mounts it and sets it as current", func() {
+				disks, err := diskDeployer.DeployNamed(namedDisks, cloud, fakeVM, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(disks).To(Equal([]bidisk.Disk{fakeDisk}))
+
+				Expect(fakeDiskManager.CreateInputs).To(Equal([]fakebidisk.CreateInput{
+					{
+						DiskPool:   namedDisks[0].DiskPool,
+						InstanceID: "fake-vm-cid",
+					},
+				}))
+				Expect(fakeVM.AttachDiskInputs).To(Equal([]fakebivm.AttachDiskInput{
+					{Disk: fakeDisk},
+				}))
+
+				Expect(fakeDiskRepo.UpdateCurrentNamedInputs).To(Equal([]fakebiconfig.DiskRepoUpdateCurrentNamedInput{
+					{Name: "fake-disk-name", DiskID: "fake-new-disk-id"},
+				}))
+
+				Expect(fakeStage.PerformCalls).To(Equal([]*fakebiui.PerformCall{
+					{Name: "Creating disk 'fake-disk-name'"},
+					{Name: "Attaching disk 'fake-new-disk-cid' to VM 'fake-vm-cid'"},
+				}))
+			})
+
+			Context("when attaching the disk fails", func() {
+				BeforeEach(func() {
+					fakeVM.SetAttachDiskBehavior(fakeDisk, bosherr.Error("fake-attach-disk-error"))
+				})
+
+				It("returns an error and does not set the disk as current", func() {
+					_, err := diskDeployer.DeployNamed(namedDisks, cloud, fakeVM, fakeStage)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-attach-disk-error"))
+					Expect(fakeDiskRepo.UpdateCurrentNamedInputs).To(BeEmpty())
+				})
+			})
+		})
+
+		Context("when the named disk exists", func() {
+			var existingDisk *fakebidisk.FakeDisk
+
+			BeforeEach(func() {
+				existingDisk = fakebidisk.NewFakeDisk("fake-existing-disk-cid")
+				existingDisk.SetNeedsMigrationBehavior(false)
+				fakeDiskManager.FindCurrentNamedDisks = map[string]bidisk.Disk{"fake-disk-name": existingDisk}
+			})
+
+			It("attaches the existing disk", func() {
+				disks, err := diskDeployer.DeployNamed(namedDisks, cloud, fakeVM, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(disks).To(Equal([]bidisk.Disk{existingDisk}))
+
+				Expect(fakeDiskManager.CreateInputs).To(BeEmpty())
+				Expect(fakeVM.AttachDiskInputs).To(Equal([]fakebivm.AttachDiskInput{
+					{Disk: existingDisk},
+				}))
+				Expect(fakeDiskRepo.UpdateCurrentNamedInputs).To(BeEmpty())
+			})
+
+			Context("when its disk pool changed", func() {
+				BeforeEach(func() {
+					existingDisk.SetNeedsMigrationBehavior(true)
+					fakeVM.ListDisksDisks = []bidisk.Disk{existingDisk, fakeDisk}
+				})
+
+				It("migrates the disk and sets the new disk as current", func() {
+					disks, err := diskDeployer.DeployNamed(namedDisks, cloud, fakeVM, fakeStage)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(disks).To(Equal([]bidisk.Disk{fakeDisk}))
+
+					Expect(fakeVM.AttachDiskInputs).To(Equal([]fakebivm.AttachDiskInput{
+						{Disk: existingDisk},
+						{Disk: fakeDisk},
+					}))
+					Expect(fakeVM.MigrateDiskCalledTimes).To(Equal(1))
+					Expect(fakeVM.DetachDiskInputs).To(Equal([]fakebivm.DetachDiskInput{
+						{Disk: existingDisk},
+					}))
+					Expect(fakeDiskRepo.UpdateCurrentNamedInputs).To(Equal([]fakebiconfig.DiskRepoUpdateCurrentNamedInput{
+						{Name: "fake-disk-name", DiskID: "fake-new-disk-id"},
+					}))
+					Expect(fakeDiskRepo.UpdateCurrentInputs).To(BeEmpty())
+				})
+
+				It("keeps the disk when disk migration is skipped", func() {
+					fakeDiskManagerFactory := fakebidisk.NewFakeManagerFactory()
+					fakeDiskManagerFactory.NewManagerManager = fakeDiskManager
+					diskDeployer = NewDiskDeployer(fakeDiskManagerFactory, fakeDiskRepo, true, boshlog.NewLogger(boshlog.LevelNone))
+
+					disks, err := diskDeployer.DeployNamed(namedDisks, cloud, fakeVM, fakeStage)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(disks).To(Equal([]bidisk.Disk{existingDisk}))
+
+					Expect(fakeDiskManager.CreateInputs).To(BeEmpty())
+					Expect(fakeVM.MigrateDiskCalledTimes).To(Equal(0))
+				})
+			})
+
+			Context("when it is no longer in the manifest", func() {
+				It("clears it as current and orphans unused disks", func() {
+					disks, err := diskDeployer.DeployNamed([]bideplmanifest.NamedDisk{}, cloud, fakeVM, fakeStage)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(disks).To(BeEmpty())
+
+					Expect(fakeDiskRepo.ClearCurrentNamedInputs).To(Equal([]string{"fake-disk-name"}))
+					Expect(fakeDiskManager.OrphanUnusedCalledTimes).To(Equal(1))
+				})
+			})
+		})
+
+		Context("when there are no named disks", func() {
+			It("does nothing", func() {
+				disks, err := diskDeployer.DeployNamed([]bideplmanifest.NamedDisk{}, cloud, fakeVM, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(disks).To(BeEmpty())
+
+				Expect(fakeStage.PerformCalls).To(BeEmpty())
+				Expect(fakeDiskManager.OrphanUnusedCalledTimes).To(Equal(0))
+			})
+		})
+	})
 })
@@ -11,6 +11,17 @@ import (
 type FakeDiskDeployer struct {
 	DeployInputs  []DeployInput
 	deployOutputs deployOutput
+
+	DeployNamedInputs []DeployNamedInput
+	DeployNamedDisks  []bidisk.Disk
+	DeployNamedErr    error
+}
+
+type DeployNamedInput struct {
+	NamedDisks       []bideplmanifest.NamedDisk
+	Cloud            bicloud.Cloud
+	VM               bivm.VM
+	EventLoggerStage biui.Stage
 }
 
 type DeployInput struct {
@@ -27,7 +38,8 @@ type deployOutput struct {
 
 func NewFakeDiskDeployer() *FakeDiskDeployer {
 	return &FakeDiskDeployer{
-		DeployInputs: []DeployInput{},
+		DeployInputs:      []DeployInput{},
+		DeployNamedInputs: []DeployNamedInput{},
 	}
 }
 
@@ -47,6 +59,22 @@ func (d *FakeDiskDeployer) Deploy(
 	return d.deployOutputs.disks, d.deployOutputs.err
 }
 
+func (d *FakeDiskDeployer) DeployNamed(
+	namedDisks []bideplmanifest.NamedDisk,
+	cloud bicloud.Cloud,
+	vm bivm.VM,
+	eventLoggerStage biui.Stage,
+) ([]bidisk.Disk, error) {
+	d.DeployNamedInputs = append(d.DeployNamedInputs, DeployNamedInput{
+		NamedDisks:       namedDisks,
+		Cloud:            cloud,
+		VM:               vm,
+		EventLoggerStage: eventLoggerStage,
+	})
+
+	return d.DeployNamedDisks, d.DeployNamedErr
+}
+
 func (d *FakeDiskDeployer) SetDeployBehavior(disks []bidisk.Disk, err error) {
 	d.deployOutputs = deployOutput{
 		disks: disks,
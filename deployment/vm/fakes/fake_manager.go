@@ -16,6 +16,16 @@ type FakeManager struct {
 	CreateVM    bivm.VM
 	CreateErr   error
 
+	PromoteAsCurrentVMs []bivm.VM
+	PromoteAsCurrentErr error
+
+	// ReplacedVM is set by MarkReplaced and returned by FindReplaced
+	ReplacedVM        bivm.VM
+	MarkReplacedErr   error
+	FindReplacedErr   error
+	DeletedReplaced   []bivm.VM
+	DeleteReplacedErr error
+
 	findCurrentBehaviour findCurrentOutput
 }
 
@@ -43,6 +53,33 @@ func (m *FakeManager) Create(stemcell bistemcell.CloudStemcell, deploymentManife
 	return m.CreateVM, m.CreateErr
 }
 
+func (m *FakeManager) PromoteAsCurrent(vm bivm.VM) error {
+	m.PromoteAsCurrentVMs = append(m.PromoteAsCurrentVMs, vm)
+	if m.PromoteAsCurrentErr == nil && m.ReplacedVM == vm {
+		m.ReplacedVM = nil
+	}
+	return m.PromoteAsCurrentErr
+}
+
+func (m *FakeManager) MarkReplaced(vm bivm.VM) error {
+	if m.MarkReplacedErr == nil {
+		m.ReplacedVM = vm
+	}
+	return m.MarkReplacedErr
+}
+
+func (m *FakeManager) FindReplaced() (bivm.VM, bool, error) {
+	return m.ReplacedVM, m.ReplacedVM != nil, m.FindReplacedErr
+}
+
+func (m *FakeManager) DeleteReplaced(vm bivm.VM) error {
+	m.DeletedReplaced = append(m.DeletedReplaced, vm)
+	if m.DeleteReplacedErr == nil && m.ReplacedVM == vm {
+		m.ReplacedVM = nil
+	}
+	return m.DeleteReplacedErr
+}
+
 func (m *FakeManager) SetFindCurrentBehavior(vm bivm.VM, found bool, err error) {
 	m.findCurrentBehaviour = findCurrentOutput{
 		vm:    vm,
@@ -56,8 +56,13 @@ type FakeVM struct {
 	MigrateDiskCalledTimes int
 	MigrateDiskErr         error
 
-	RunScriptInputs []string
-	RunScriptErrors map[string]error
+	DiskChecksumInputs []string
+	DiskChecksums      map[string]string
+	DiskChecksumErr    error
+
+	RunScriptInputs   []string
+	RunScriptErrors   map[string]error
+	RunScriptTimeouts map[string]time.Duration
 
 	GetStateResult biagentclient.AgentState
 	GetStateCalled int
@@ -65,8 +70,9 @@ type FakeVM struct {
 }
 
 type UpdateDisksInput struct {
-	DiskPool bideplmanifest.DiskPool
-	Stage    biui.Stage
+	DiskPool   bideplmanifest.DiskPool
+	NamedDisks []bideplmanifest.NamedDisk
+	Stage      biui.Stage
 }
 
 type ApplyInput struct {
@@ -74,8 +80,9 @@ type ApplyInput struct {
 }
 
 type WaitUntilReadyInput struct {
-	Timeout time.Duration
-	Delay   time.Duration
+	Timeout   time.Duration
+	Delay     time.Duration
+	MaxErrors int
 }
 
 type WaitInput struct {
@@ -108,6 +115,7 @@ func NewFakeVM(cid string) *FakeVM {
 		detachDiskBehavior:    map[string]error{},
 		cid:                   cid,
 		RunScriptErrors:       map[string]error{},
+		RunScriptTimeouts:     map[string]time.Duration{},
 	}
 }
 
@@ -124,18 +132,20 @@ func (vm *FakeVM) AgentClient() biagentclient.AgentClient {
 	return vm.AgentClientReturn
 }
 
-func (vm *FakeVM) WaitUntilReady(timeout time.Duration, delay time.Duration) error {
+func (vm *FakeVM) WaitUntilReady(timeout time.Duration, delay time.Duration, maxErrors int) error {
 	vm.WaitUntilReadyInputs = append(vm.WaitUntilReadyInputs, WaitUntilReadyInput{
-		Timeout: timeout,
-		Delay:   delay,
+		Timeout:   timeout,
+		Delay:     delay,
+		MaxErrors: maxErrors,
 	})
 	return vm.WaitUntilReadyErr
 }
 
-func (vm *FakeVM) UpdateDisks(diskPool bideplmanifest.DiskPool, eventLoggerStage biui.Stage) ([]bidisk.Disk, error) {
+func (vm *FakeVM) UpdateDisks(diskPool bideplmanifest.DiskPool, namedDisks []bideplmanifest.NamedDisk, eventLoggerStage biui.Stage) ([]bidisk.Disk, error) {
 	vm.UpdateDisksInputs = append(vm.UpdateDisksInputs, UpdateDisksInput{
-		DiskPool: diskPool,
-		Stage:    eventLoggerStage,
+		DiskPool:   diskPool,
+		NamedDisks: namedDisks,
+		Stage:      eventLoggerStage,
 	})
 	return vm.UpdateDisksDisks, vm.UpdateDisksErr
 }
@@ -191,6 +201,16 @@ func (vm *FakeVM) MigrateDisk() error {
 	return vm.MigrateDiskErr
 }
 
+func (vm *FakeVM) DiskChecksum(disk bidisk.Disk) (string, error) {
+	vm.DiskChecksumInputs = append(vm.DiskChecksumInputs, disk.CID())
+
+	checksum, found := vm.DiskChecksums[disk.CID()]
+	if !found {
+		checksum = "fake-checksum"
+	}
+	return checksum, vm.DiskChecksumErr
+}
+
 func (vm *FakeVM) Stop() error {
 	vm.StopCalled++
 	return vm.StopErr
@@ -205,6 +225,11 @@ func (vm *FakeVM) RunScript(script string, options map[string]interface{}) error
 	return vm.RunScriptErrors[script]
 }
 
+func (vm *FakeVM) RunScriptWithTimeout(script string, options map[string]interface{}, timeout time.Duration) error {
+	vm.RunScriptTimeouts[script] = timeout
+	return vm.RunScript(script, options)
+}
+
 func (vm *FakeVM) Delete() error {
 	vm.DeleteCalled++
 	return vm.DeleteErr
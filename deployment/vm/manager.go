@@ -1,6 +1,8 @@
 package vm
 
 import (
+	"strconv"
+
 	biagentclient "github.com/cloudfoundry/bosh-agent/agentclient"
 	bihttpagent "github.com/cloudfoundry/bosh-agent/agentclient/http"
 	bicloud "github.com/cloudfoundry/bosh-cli/cloud"
@@ -18,6 +20,17 @@ import (
 type Manager interface {
 	FindCurrent() (VM, bool, error)
 	Create(bistemcell.CloudStemcell, bideplmanifest.Manifest) (VM, error)
+
+	// PromoteAsCurrent records a VM found or created by the manager as the
+	// current VM again, e.g. after the VM created to replace it is deleted.
+	PromoteAsCurrent(VM) error
+
+	// MarkReplaced records the current VM as replaced before the VM that
+	// replaces it is created, until the replaced VM is deleted or promoted
+	// as current again.
+	MarkReplaced(VM) error
+	FindReplaced() (VM, bool, error)
+	DeleteReplaced(VM) error
 }
 
 type manager struct {
@@ -31,6 +44,10 @@ type manager struct {
 	fs                 boshsys.FileSystem
 	logger             boshlog.Logger
 	logTag             string
+	index              int
+
+	// agentIDs are the agent IDs of the VMs found or created, by VM CID
+	agentIDs map[string]string
 }
 
 func NewManager(
@@ -42,6 +59,7 @@ func NewManager(
 	uuidGenerator boshuuid.Generator,
 	fs boshsys.FileSystem,
 	logger boshlog.Logger,
+	index int,
 ) Manager {
 	return &manager{
 		cloud:         cloud,
@@ -53,6 +71,8 @@ func NewManager(
 		fs:            fs,
 		logger:        logger,
 		logTag:        "vmManager",
+		index:         index,
+		agentIDs:      map[string]string{},
 	}
 }
 
@@ -66,6 +86,16 @@ func (m *manager) FindCurrent() (VM, bool, error) {
 		return nil, false, nil
 	}
 
+	agentID, found, err := m.vmRepo.FindCurrentAgentID()
+	if err != nil {
+		return nil, false, bosherr.WrapError(err, "Finding agent ID of currently deployed vm")
+	}
+
+	if found {
+		m.agentIDs[vmCID] = agentID
+		m.setAgentID(agentID)
+	}
+
 	vm := NewVM(
 		vmCID,
 		m.vmRepo,
@@ -83,7 +113,7 @@ func (m *manager) FindCurrent() (VM, bool, error) {
 
 func (m *manager) Create(stemcell bistemcell.CloudStemcell, deploymentManifest bideplmanifest.Manifest) (VM, error) {
 	jobName := deploymentManifest.JobName()
-	networkInterfaces, err := deploymentManifest.NetworkInterfaces(jobName)
+	networkInterfaces, err := deploymentManifest.InstanceNetworkInterfaces(jobName, m.index)
 	m.logger.Debug(m.logTag, "Creating VM with network interfaces: %#v", networkInterfaces)
 	if err != nil {
 		return nil, bosherr.WrapError(err, "Getting network spec")
@@ -104,10 +134,13 @@ func (m *manager) Create(stemcell bistemcell.CloudStemcell, deploymentManifest b
 		return nil, err
 	}
 
+	m.agentIDs[cid] = agentID
+	m.setAgentID(agentID)
+
 	metadata := bicloud.VMMetadata{
 		"deployment": deploymentManifest.Name,
 		"job":        deploymentManifest.JobName(),
-		"index":      "0",
+		"index":      strconv.Itoa(m.index),
 		"director":   "bosh-init",
 	}
 
@@ -140,6 +173,114 @@ func (m *manager) Create(stemcell bistemcell.CloudStemcell, deploymentManifest b
 	return vm, nil
 }
 
+func (m *manager) PromoteAsCurrent(vm VM) error {
+	err := m.vmRepo.UpdateCurrent(vm.CID())
+	if err != nil {
+		return bosherr.WrapError(err, "Updating current vm record")
+	}
+
+	agentID, found := m.agentIDs[vm.CID()]
+	if found {
+		err = m.vmRepo.UpdateCurrentAgentID(agentID)
+		if err != nil {
+			return bosherr.WrapError(err, "Updating current agent ID record")
+		}
+
+		m.setAgentID(agentID)
+	}
+
+	return m.clearReplaced(vm)
+}
+
+func (m *manager) MarkReplaced(vm VM) error {
+	err := m.vmRepo.UpdateReplaced(vm.CID(), m.agentIDs[vm.CID()])
+	if err != nil {
+		return bosherr.WrapError(err, "Updating replaced vm record")
+	}
+
+	return nil
+}
+
+// FindReplaced returns the VM recorded as replaced. Deleting it clears the
+// record instead of the current VM record.
+func (m *manager) FindReplaced() (VM, bool, error) {
+	vmCID, agentID, found, err := m.vmRepo.FindReplaced()
+	if err != nil {
+		return nil, false, bosherr.WrapError(err, "Finding replaced vm")
+	}
+
+	if !found {
+		return nil, false, nil
+	}
+
+	if agentID != "" {
+		m.agentIDs[vmCID] = agentID
+	}
+
+	vm := NewVM(
+		vmCID,
+		replacedVMRepo{m.vmRepo},
+		m.stemcellRepo,
+		m.diskDeployer,
+		m.agentClient,
+		m.cloud,
+		clock.NewClock(),
+		m.fs,
+		m.logger,
+	)
+
+	return vm, true, nil
+}
+
+// DeleteReplaced deletes the replaced VM in the cloud and clears its record,
+// leaving the current VM and stemcell records as they are. It returns
+// bicloud.Error only if it is a VMNotFoundError.
+func (m *manager) DeleteReplaced(vm VM) error {
+	deleteErr := m.cloud.DeleteVM(vm.CID())
+	if deleteErr != nil {
+		// allow VMNotFoundError for idempotency
+		cloudErr, ok := deleteErr.(bicloud.Error)
+		if !ok || cloudErr.Type() != bicloud.VMNotFoundError {
+			return bosherr.WrapError(deleteErr, "Deleting replaced vm in the cloud")
+		}
+	}
+
+	err := m.clearReplaced(vm)
+	if err != nil {
+		return err
+	}
+
+	return deleteErr
+}
+
+func (m *manager) clearReplaced(vm VM) error {
+	vmCID, _, found, err := m.vmRepo.FindReplaced()
+	if err != nil {
+		return bosherr.WrapError(err, "Finding replaced vm")
+	}
+
+	if !found || vmCID != vm.CID() {
+		return nil
+	}
+
+	err = m.vmRepo.ClearReplaced()
+	if err != nil {
+		return bosherr.WrapError(err, "Clearing replaced vm record")
+	}
+
+	return nil
+}
+
+// replacedVMRepo is the VM repo of a replaced VM, which clears the replaced
+// VM record when the VM is deleted.
+type replacedVMRepo struct {
+	biconfig.VMRepo
+}
+
+func (r replacedVMRepo) ClearCurrent() error {
+	return r.VMRepo.ClearReplaced()
+}
+
 func (m *manager) createAndRecordVM(agentID string, stemcell bistemcell.CloudStemcell, resourcePool bideplmanifest.ResourcePool, networkInterfaces map[string]biproperty.Map) (string, error) {
 	cid, err := m.cloud.CreateVM(agentID, stemcell.CID(), resourcePool.CloudProperties, networkInterfaces, resourcePool.Env)
 	if err != nil {
@@ -152,5 +293,22 @@ func (m *manager) createAndRecordVM(agentID string, stemcell bistemcell.CloudSte
 		return "", bosherr.WrapError(err, "Updating current vm record")
 	}
 
+	err = m.vmRepo.UpdateCurrentAgentID(agentID)
+	if err != nil {
+		return "", bosherr.WrapError(err, "Updating current agent ID record")
+	}
+
 	return cid, nil
 }
+
+// agentIDSetter is implemented by agent clients that address the agent by
+// its ID instead of by its host, such as the NATS agent client.
+type agentIDSetter interface {
+	SetAgentID(agentID string)
+}
+
+func (m *manager) setAgentID(agentID string) {
+	if setter, ok := m.agentClient.(agentIDSetter); ok {
+		setter.SetAgentID(agentID)
+	}
+}
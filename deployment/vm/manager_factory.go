@@ -20,6 +20,7 @@ type managerFactory struct {
 	uuidGenerator boshuuid.Generator
 	fs            boshsys.FileSystem
 	logger        boshlog.Logger
+	index         int
 }
 
 func NewManagerFactory(
@@ -29,6 +30,21 @@ func NewManagerFactory(
 	uuidGenerator boshuuid.Generator,
 	fs boshsys.FileSystem,
 	logger boshlog.Logger,
+) ManagerFactory {
+	return NewInstanceManagerFactory(vmRepo, stemcellRepo, diskDeployer, uuidGenerator, fs, logger, 0)
+}
+
+// NewInstanceManagerFactory returns a factory of managers of the VM of the
+// instance with the given index. The VM repo and disk deployer must be scoped
+// to the same instance.
+func NewInstanceManagerFactory(
+	vmRepo biconfig.VMRepo,
+	stemcellRepo biconfig.StemcellRepo,
+	diskDeployer DiskDeployer,
+	uuidGenerator boshuuid.Generator,
+	fs boshsys.FileSystem,
+	logger boshlog.Logger,
+	index int,
 ) ManagerFactory {
 	return &managerFactory{
 		vmRepo:        vmRepo,
@@ -37,6 +53,7 @@ func NewManagerFactory(
 		uuidGenerator: uuidGenerator,
 		fs:            fs,
 		logger:        logger,
+		index:         index,
 	}
 }
 
@@ -50,5 +67,6 @@ func (f *managerFactory) NewManager(cloud bicloud.Cloud, agentClient biagentclie
 		f.uuidGenerator,
 		f.fs,
 		f.logger,
+		f.index,
 	)
 }
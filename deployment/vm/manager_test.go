@@ -113,6 +113,29 @@ var _ = Describe("Manager", func() {
 		stemcell = bistemcell.NewCloudStemcell(stemcellRecord, stemcellRepo, fakeCloud)
 	})
 
+	Describe("FindCurrent", func() {
+		It("tells agent clients addressing agents by ID the recorded agent ID", func() {
+			fakeVMRepo.SetFindCurrentBehavior("fake-vm-cid", true, nil)
+			fakeVMRepo.CurrentAgentID = "fake-agent-id"
+
+			agentClient := &agentIDAgentClient{FakeAgentClient: fakeAgentClient}
+			manager = NewManagerFactory(
+				fakeVMRepo,
+				stemcellRepo,
+				fakeDiskDeployer,
+				&fakeuuid.FakeGenerator{},
+				fs,
+				logger,
+			).NewManager(fakeCloud, agentClient)
+
+			vm, found, err := manager.FindCurrent()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(vm.CID()).To(Equal("fake-vm-cid"))
+			Expect(agentClient.agentID).To(Equal("fake-agent-id"))
+		})
+	})
+
 	Describe("Create", func() {
 		It("creates a VM", func() {
 			vm, err := manager.Create(stemcell, deploymentManifest)
@@ -154,6 +177,30 @@ var _ = Describe("Manager", func() {
 			}))
 		})
 
+		Context("when the manager is for an instance other than the first", func() {
+			BeforeEach(func() {
+				deploymentManifest.Jobs[0].Networks[0].StaticIPs = []string{"fake-ip", "fake-other-ip"}
+
+				manager = NewInstanceManagerFactory(
+					fakeVMRepo,
+					stemcellRepo,
+					fakeDiskDeployer,
+					&fakeuuid.FakeGenerator{},
+					fs,
+					logger,
+					1,
+				).NewManager(fakeCloud, fakeAgentClient)
+			})
+
+			It("creates the VM with the static IP and index of the instance", func() {
+				_, err := manager.Create(stemcell, deploymentManifest)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCloud.CreateVMInput.NetworksInterfaces["fake-network-name"]["ip"]).To(Equal("fake-other-ip"))
+				Expect(fakeCloud.SetVMMetadataMetadata["index"]).To(Equal("1"))
+			})
+		})
+
 		Context("deployment-configured tags", func() {
 			It("sets additional tags on vms", func() {
 				deploymentManifest.Tags = map[string]string{
@@ -201,6 +248,36 @@ var _ = Describe("Manager", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(fakeVMRepo.UpdateCurrentCID).To(Equal("fake-vm-cid"))
+			Expect(fakeVMRepo.UpdateCurrentAgentIDAgentID).To(Equal("fake-uuid-0"))
+		})
+
+		It("tells agent clients addressing agents by ID the new agent ID", func() {
+			agentClient := &agentIDAgentClient{FakeAgentClient: fakeAgentClient}
+			manager = NewManagerFactory(
+				fakeVMRepo,
+				stemcellRepo,
+				fakeDiskDeployer,
+				&fakeuuid.FakeGenerator{},
+				fs,
+				logger,
+			).NewManager(fakeCloud, agentClient)
+
+			_, err := manager.Create(stemcell, deploymentManifest)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(agentClient.agentID).To(Equal("fake-uuid-0"))
+		})
+
+		Context("when updating the current agent id record fails", func() {
+			BeforeEach(func() {
+				fakeVMRepo.UpdateCurrentAgentIDErr = errors.New("fake-update-error")
+			})
+
+			It("returns an error", func() {
+				_, err := manager.Create(stemcell, deploymentManifest)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-update-error"))
+			})
 		})
 
 		Context("when setting vm metadata fails", func() {
@@ -245,4 +322,139 @@ var _ = Describe("Manager", func() {
 			})
 		})
 	})
+
+	Describe("PromoteAsCurrent", func() {
+		It("records a VM found before as the current VM with its agent ID", func() {
+			fakeVMRepo.SetFindCurrentBehavior("fake-old-vm-cid", true, nil)
+			fakeVMRepo.CurrentAgentID = "fake-old-agent-id"
+
+			oldVM, found, err := manager.FindCurrent()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+
+			_, err = manager.Create(stemcell, deploymentManifest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeVMRepo.UpdateCurrentCID).To(Equal("fake-vm-cid"))
+
+			err = manager.PromoteAsCurrent(oldVM)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeVMRepo.UpdateCurrentCID).To(Equal("fake-old-vm-cid"))
+			Expect(fakeVMRepo.UpdateCurrentAgentIDAgentID).To(Equal("fake-old-agent-id"))
+		})
+
+		It("returns an error when updating the current vm record fails", func() {
+			vm, err := manager.Create(stemcell, deploymentManifest)
+			Expect(err).ToNot(HaveOccurred())
+
+			fakeVMRepo.UpdateCurrentErr = errors.New("fake-update-error")
+
+			err = manager.PromoteAsCurrent(vm)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-update-error"))
+		})
+
+		It("clears the replaced vm record when promoting the replaced vm", func() {
+			fakeVMRepo.SetFindCurrentBehavior("fake-old-vm-cid", true, nil)
+
+			oldVM, _, err := manager.FindCurrent()
+			Expect(err).ToNot(HaveOccurred())
+
+			err = manager.MarkReplaced(oldVM)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = manager.PromoteAsCurrent(oldVM)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeVMRepo.ClearReplacedCalled).To(BeTrue())
+		})
+	})
+
+	Describe("MarkReplaced", func() {
+		It("records the vm as replaced with its agent ID", func() {
+			fakeVMRepo.SetFindCurrentBehavior("fake-old-vm-cid", true, nil)
+			fakeVMRepo.CurrentAgentID = "fake-old-agent-id"
+
+			oldVM, _, err := manager.FindCurrent()
+			Expect(err).ToNot(HaveOccurred())
+
+			err = manager.MarkReplaced(oldVM)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeVMRepo.ReplacedCID).To(Equal("fake-old-vm-cid"))
+			Expect(fakeVMRepo.ReplacedAgentID).To(Equal("fake-old-agent-id"))
+
+			replacedVM, found, err := manager.FindReplaced()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(replacedVM.CID()).To(Equal("fake-old-vm-cid"))
+		})
+	})
+
+	Describe("FindReplaced", func() {
+		It("is not found when no vm is replaced", func() {
+			_, found, err := manager.FindReplaced()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		It("returns a vm that clears the replaced vm record when deleted", func() {
+			fakeVMRepo.UpdateReplaced("fake-old-vm-cid", "")
+
+			replacedVM, _, err := manager.FindReplaced()
+			Expect(err).ToNot(HaveOccurred())
+
+			err = replacedVM.Delete()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeVMRepo.ClearReplacedCalled).To(BeTrue())
+			Expect(fakeVMRepo.ClearCurrentCalled).To(BeFalse())
+		})
+	})
+
+	Describe("DeleteReplaced", func() {
+		var replacedVM VM
+
+		BeforeEach(func() {
+			fakeVMRepo.UpdateReplaced("fake-old-vm-cid", "")
+
+			var err error
+			replacedVM, _, err = manager.FindReplaced()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("deletes the vm in the cloud and clears only the replaced vm record", func() {
+			err := manager.DeleteReplaced(replacedVM)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCloud.DeleteVMInput).To(Equal(fakebicloud.DeleteVMInput{VMCID: "fake-old-vm-cid"}))
+			Expect(fakeVMRepo.ClearReplacedCalled).To(BeTrue())
+			Expect(fakeVMRepo.ClearCurrentCalled).To(BeFalse())
+		})
+
+		It("clears the record when the vm is already gone", func() {
+			fakeCloud.DeleteVMErr = cloud.NewCPIError("delete_vm", cloud.CmdError{
+				Type:    cloud.VMNotFoundError,
+				Message: "fake-vm-error",
+			})
+
+			err := manager.DeleteReplaced(replacedVM)
+			Expect(err).To(HaveOccurred())
+			Expect(fakeVMRepo.ClearReplacedCalled).To(BeTrue())
+		})
+
+		It("keeps the record when deleting the vm fails", func() {
+			fakeCloud.DeleteVMErr = errors.New("fake-delete-error")
+
+			err := manager.DeleteReplaced(replacedVM)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-delete-error"))
+			Expect(fakeVMRepo.ClearReplacedCalled).To(BeFalse())
+		})
+	})
 })
+
+type agentIDAgentClient struct {
+	*fakebiagentclient.FakeAgentClient
+	agentID string
+}
+
+func (c *agentIDAgentClient) SetAgentID(agentID string) {
+	c.agentID = agentID
+}
@@ -1,12 +1,14 @@
 package vm
 
 import (
+	"crypto/x509"
 	"time"
 
 	biagentclient "github.com/cloudfoundry/bosh-agent/agentclient"
 	bias "github.com/cloudfoundry/bosh-agent/agentclient/applyspec"
 	bicloud "github.com/cloudfoundry/bosh-cli/cloud"
 	biconfig "github.com/cloudfoundry/bosh-cli/config"
+	biagent "github.com/cloudfoundry/bosh-cli/deployment/agent"
 	bidisk "github.com/cloudfoundry/bosh-cli/deployment/disk"
 	bideplmanifest "github.com/cloudfoundry/bosh-cli/deployment/manifest"
 	biui "github.com/cloudfoundry/bosh-cli/ui"
@@ -25,18 +27,20 @@ type VM interface {
 	CID() string
 	Exists() (bool, error)
 	AgentClient() biagentclient.AgentClient
-	WaitUntilReady(timeout time.Duration, delay time.Duration) error
+	WaitUntilReady(timeout time.Duration, delay time.Duration, maxErrors int) error
 	Start() error
 	Stop() error
 	Apply(bias.ApplySpec) error
-	UpdateDisks(bideplmanifest.DiskPool, biui.Stage) ([]bidisk.Disk, error)
+	UpdateDisks(bideplmanifest.DiskPool, []bideplmanifest.NamedDisk, biui.Stage) ([]bidisk.Disk, error)
 	WaitToBeRunning(maxAttempts int, delay time.Duration) error
 	AttachDisk(bidisk.Disk) error
 	DetachDisk(bidisk.Disk) error
 	Disks() ([]bidisk.Disk, error)
 	UnmountDisk(bidisk.Disk) error
 	MigrateDisk() error
+	DiskChecksum(bidisk.Disk) (string, error)
 	RunScript(script string, options map[string]interface{}) error
+	RunScriptWithTimeout(script string, options map[string]interface{}, timeout time.Duration) error
 	Delete() error
 	GetState() (biagentclient.AgentState, error)
 }
@@ -52,6 +56,10 @@ type vm struct {
 	fs           boshsys.FileSystem
 	logger       boshlog.Logger
 	logTag       string
+
+	// namedDisks are the CIDs of the named disks attached by UpdateDisks,
+	// by name, which are sent to the agent with the apply spec
+	namedDisks map[string]string
 }
 
 func NewVM(
@@ -95,10 +103,54 @@ func (vm *vm) AgentClient() biagentclient.AgentClient {
 	return vm.agentClient
 }
 
-func (vm *vm) WaitUntilReady(timeout time.Duration, delay time.Duration) error {
-	agentPingRetryable := biagentclient.NewPingRetryable(vm.agentClient)
-	agentPingRetryStrategy := boshretry.NewTimeoutRetryStrategy(timeout, delay, agentPingRetryable, vm.timeService, vm.logger)
-	return agentPingRetryStrategy.Try()
+// WaitUntilReady pings the agent every delay until it responds. It gives up
+// after timeout, or after maxErrors failed pings in a row when maxErrors is
+// not 0, and returns the last error of the agent.
+func (vm *vm) WaitUntilReady(timeout time.Duration, delay time.Duration, maxErrors int) error {
+	deadlineMinusDelay := vm.timeService.Now().Add(timeout).Add(-1 * delay)
+
+	for attempt := 1; ; attempt++ {
+		// the mbus client sends pings once, so every attempt is one poll
+		_, err := vm.agentClient.Ping()
+		if err == nil {
+			return nil
+		}
+
+		if !isRetryablePingError(err) {
+			return err
+		}
+
+		vm.logger.Debug(vm.logTag, "Agent did not respond to ping #%d: %s", attempt, err.Error())
+
+		if maxErrors > 0 && attempt >= maxErrors {
+			return bosherr.WrapErrorf(err, "Agent did not respond after %d attempts", attempt)
+		}
+
+		if vm.timeService.Now().After(deadlineMinusDelay) {
+			return bosherr.WrapErrorf(err, "Agent did not respond within %s", timeout)
+		}
+
+		vm.timeService.Sleep(delay)
+	}
+}
+
+// isRetryablePingError tells whether the agent may respond to a later ping.
+// Certificate errors are not going away while waiting.
+func isRetryablePingError(err error) bool {
+	for {
+		if complexErr, ok := err.(bosherr.ComplexError); ok {
+			err = complexErr.Cause
+		} else {
+			break
+		}
+	}
+
+	if mbusErr, ok := err.(biagent.MbusError); ok {
+		return mbusErr.Category != biagent.MbusTLSError
+	}
+
+	_, ok := err.(x509.CertificateInvalidError)
+	return !ok
 }
 
 func (vm *vm) Start() error {
@@ -123,7 +175,13 @@ func (vm *vm) Stop() error {
 
 func (vm *vm) Apply(newState bias.ApplySpec) error {
 	vm.logger.Debug(vm.logTag, "Sending apply message to the agent with '%#v'", newState)
-	err := vm.agentClient.Apply(newState)
+
+	var err error
+	if len(vm.namedDisks) > 0 {
+		err = vm.applyWithNamedDisks(newState)
+	} else {
+		err = vm.agentClient.Apply(newState)
+	}
 	if err != nil {
 		return bosherr.WrapError(err, "Sending apply spec to agent")
 	}
@@ -131,11 +189,39 @@ func (vm *vm) Apply(newState bias.ApplySpec) error {
 	return nil
 }
 
-func (vm *vm) UpdateDisks(diskPool bideplmanifest.DiskPool, eventLoggerStage biui.Stage) ([]bidisk.Disk, error) {
+// namedDiskApplier is implemented by agent clients that can send the named
+// disks of the instance with the apply spec.
+type namedDiskApplier interface {
+	ApplyWithNamedDisks(spec bias.ApplySpec, namedDisks map[string]string) error
+}
+
+func (vm *vm) applyWithNamedDisks(newState bias.ApplySpec) error {
+	applier, ok := vm.agentClient.(namedDiskApplier)
+	if !ok {
+		return bosherr.Error("Agent client cannot send the named disks of the instance to the agent")
+	}
+
+	return applier.ApplyWithNamedDisks(newState, vm.namedDisks)
+}
+
+func (vm *vm) UpdateDisks(diskPool bideplmanifest.DiskPool, namedDisks []bideplmanifest.NamedDisk, eventLoggerStage biui.Stage) ([]bidisk.Disk, error) {
 	disks, err := vm.diskDeployer.Deploy(diskPool, vm.cloud, vm, eventLoggerStage)
 	if err != nil {
 		return disks, bosherr.WrapError(err, "Deploying disk")
 	}
+
+	namedDiskList, err := vm.diskDeployer.DeployNamed(namedDisks, vm.cloud, vm, eventLoggerStage)
+	disks = append(disks, namedDiskList...)
+	if err != nil {
+		return disks, bosherr.WrapError(err, "Deploying named disks")
+	}
+
+	// the named disks are deployed in the order of the manifest
+	vm.namedDisks = map[string]string{}
+	for i, disk := range namedDiskList {
+		vm.namedDisks[namedDisks[i].Name] = disk.CID()
+	}
+
 	return disks, nil
 }
 
@@ -146,16 +232,23 @@ func (vm *vm) WaitToBeRunning(maxAttempts int, delay time.Duration) error {
 }
 
 func (vm *vm) AttachDisk(disk bidisk.Disk) error {
-	err := vm.cloud.AttachDisk(vm.cid, disk.CID())
+	diskHint, err := vm.cloud.AttachDisk(vm.cid, disk.CID())
 	if err != nil {
 		return bosherr.WrapError(err, "Attaching disk in the cloud")
 	}
 
-	err = vm.WaitUntilReady(10*time.Minute, 500*time.Millisecond)
+	err = vm.WaitUntilReady(10*time.Minute, 500*time.Millisecond, 0)
 	if err != nil {
 		return bosherr.WrapError(err, "Waiting for agent to be accessible after attaching disk")
 	}
 
+	if diskHint != nil {
+		err = vm.addPersistentDisk(disk, diskHint)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = vm.agentClient.MountDisk(disk.CID())
 	if err != nil {
 		return bosherr.WrapError(err, "Mounting disk")
@@ -164,13 +257,35 @@ func (vm *vm) AttachDisk(disk bidisk.Disk) error {
 	return nil
 }
 
+// persistentDiskAdder is implemented by agent clients that can tell the
+// agent where to find a disk.
+type persistentDiskAdder interface {
+	AddPersistentDisk(diskCID string, diskHint interface{}) error
+}
+
+// addPersistentDisk passes the disk hint returned by the CPI to the agent,
+// which would otherwise read it from the registry.
+func (vm *vm) addPersistentDisk(disk bidisk.Disk, diskHint interface{}) error {
+	adder, ok := vm.agentClient.(persistentDiskAdder)
+	if !ok {
+		return bosherr.Errorf("Agent client cannot pass the hint of disk '%s' to the agent", disk.CID())
+	}
+
+	err := adder.AddPersistentDisk(disk.CID(), diskHint)
+	if err != nil {
+		return bosherr.WrapError(err, "Adding persistent disk to the agent")
+	}
+
+	return nil
+}
+
 func (vm *vm) DetachDisk(disk bidisk.Disk) error {
 	err := vm.cloud.DetachDisk(vm.cid, disk.CID())
 	if err != nil {
 		return bosherr.WrapError(err, "Detaching disk in the cloud")
 	}
 
-	err = vm.WaitUntilReady(10*time.Minute, 500*time.Millisecond)
+	err = vm.WaitUntilReady(10*time.Minute, 500*time.Millisecond, 0)
 	if err != nil {
 		return bosherr.WrapError(err, "Waiting for agent to be accessible after detaching disk")
 	}
@@ -202,10 +317,45 @@ func (vm *vm) MigrateDisk() error {
 	return vm.agentClient.MigrateDisk()
 }
 
+// diskChecksummer is implemented by agent clients that can ask the agent for
+// a checksum of the content of a disk.
+type diskChecksummer interface {
+	DiskChecksum(diskCID string) (string, error)
+}
+
+func (vm *vm) DiskChecksum(disk bidisk.Disk) (string, error) {
+	checksummer, ok := vm.agentClient.(diskChecksummer)
+	if !ok {
+		return "", bosherr.Errorf("Agent client cannot ask the agent for the checksum of disk '%s'", disk.CID())
+	}
+
+	checksum, err := checksummer.DiskChecksum(disk.CID())
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Computing checksum of disk '%s'", disk.CID())
+	}
+
+	return checksum, nil
+}
+
 func (vm *vm) RunScript(script string, options map[string]interface{}) error {
 	return vm.agentClient.RunScript(script, options)
 }
 
+type scriptTimeoutRunner interface {
+	RunScriptWithTimeout(script string, options map[string]interface{}, timeout time.Duration) error
+}
+
+// RunScriptWithTimeout runs the script like RunScript, but stops it once the
+// timeout expires.
+func (vm *vm) RunScriptWithTimeout(script string, options map[string]interface{}, timeout time.Duration) error {
+	runner, ok := vm.agentClient.(scriptTimeoutRunner)
+	if !ok {
+		return bosherr.Errorf("Agent client cannot stop script '%s' after a timeout", script)
+	}
+
+	return runner.RunScriptWithTimeout(script, options, timeout)
+}
+
 func (vm *vm) Delete() error {
 	deleteErr := vm.cloud.DeleteVM(vm.cid)
 	if deleteErr != nil {
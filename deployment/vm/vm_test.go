@@ -12,8 +12,10 @@ import (
 	bias "github.com/cloudfoundry/bosh-agent/agentclient/applyspec"
 	bicloud "github.com/cloudfoundry/bosh-cli/cloud"
 	biconfig "github.com/cloudfoundry/bosh-cli/config"
+	biagent "github.com/cloudfoundry/bosh-cli/deployment/agent"
 	bidisk "github.com/cloudfoundry/bosh-cli/deployment/disk"
 	bideplmanifest "github.com/cloudfoundry/bosh-cli/deployment/manifest"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	biproperty "github.com/cloudfoundry/bosh-utils/property"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
@@ -115,7 +117,7 @@ var _ = Describe("VM", func() {
 		It("delegates to DiskDeployer.Deploy", func() {
 			fakeStage := fakebiui.NewFakeStage()
 
-			disks, err := vm.UpdateDisks(diskPool, fakeStage)
+			disks, err := vm.UpdateDisks(diskPool, []bideplmanifest.NamedDisk{}, fakeStage)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(disks).To(Equal(expectedDisks))
 
@@ -128,6 +130,29 @@ var _ = Describe("VM", func() {
 				},
 			}))
 		})
+
+		It("deploys the named disks after the current disk", func() {
+			fakeStage := fakebiui.NewFakeStage()
+			namedDisk := fakebidisk.NewFakeDisk("fake-named-disk-cid")
+			fakeDiskDeployer.DeployNamedDisks = []bidisk.Disk{namedDisk}
+
+			namedDisks := []bideplmanifest.NamedDisk{
+				{Name: "fake-disk-name", DiskPool: diskPool},
+			}
+
+			disks, err := vm.UpdateDisks(diskPool, namedDisks, fakeStage)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(disks).To(Equal(append(expectedDisks, namedDisk)))
+
+			Expect(fakeDiskDeployer.DeployNamedInputs).To(Equal([]fakebivm.DeployNamedInput{
+				{
+					NamedDisks:       namedDisks,
+					Cloud:            fakeCloud,
+					VM:               vm,
+					EventLoggerStage: fakeStage,
+				},
+			}))
+		})
 	})
 
 	Describe("Stop", func() {
@@ -157,6 +182,38 @@ var _ = Describe("VM", func() {
 			Expect(fakeAgentClient.ApplyArgsForCall(0)).To(Equal(applySpec))
 		})
 
+		Context("when named disks have been deployed", func() {
+			var namedDisks []bideplmanifest.NamedDisk
+
+			BeforeEach(func() {
+				fakeDiskDeployer.DeployNamedDisks = []bidisk.Disk{fakebidisk.NewFakeDisk("fake-named-disk-cid")}
+				namedDisks = []bideplmanifest.NamedDisk{{Name: "fake-disk-name", DiskPool: diskPool}}
+			})
+
+			It("sends the named disks with the apply spec", func() {
+				diskAgentClient := &persistentDiskAgentClient{FakeAgentClient: fakeAgentClient}
+				vm = NewVM("fake-vm-cid", fakeVMRepo, fakeStemcellRepo, fakeDiskDeployer, diskAgentClient, fakeCloud, timeService, fs, logger)
+
+				_, err := vm.UpdateDisks(diskPool, namedDisks, fakebiui.NewFakeStage())
+				Expect(err).ToNot(HaveOccurred())
+
+				err = vm.Apply(applySpec)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(diskAgentClient.AppliedSpecs).To(Equal([]bias.ApplySpec{applySpec}))
+				Expect(diskAgentClient.AppliedNamedDisks).To(Equal([]map[string]string{{"fake-disk-name": "fake-named-disk-cid"}}))
+				Expect(fakeAgentClient.ApplyCallCount()).To(Equal(0))
+			})
+
+			It("returns an error when the agent client cannot send them", func() {
+				_, err := vm.UpdateDisks(diskPool, namedDisks, fakebiui.NewFakeStage())
+				Expect(err).ToNot(HaveOccurred())
+
+				err = vm.Apply(applySpec)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("cannot send the named disks"))
+			})
+		})
+
 		Context("when sending apply spec to the agent fails", func() {
 			BeforeEach(func() {
 				fakeAgentClient.ApplyReturns(errors.New("fake-agent-apply-err"))
@@ -170,6 +227,46 @@ var _ = Describe("VM", func() {
 		})
 	})
 
+	Describe("DiskChecksum", func() {
+		var disk *fakebidisk.FakeDisk
+
+		BeforeEach(func() {
+			disk = fakebidisk.NewFakeDisk("fake-disk-cid")
+		})
+
+		It("asks the agent for the checksum of the disk", func() {
+			diskAgentClient := &persistentDiskAgentClient{FakeAgentClient: fakeAgentClient}
+			vm = NewVM("fake-vm-cid", fakeVMRepo, fakeStemcellRepo, fakeDiskDeployer, diskAgentClient, fakeCloud, timeService, fs, logger)
+
+			checksum, err := vm.DiskChecksum(disk)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(checksum).To(Equal("fake-checksum-of-fake-disk-cid"))
+		})
+
+		It("returns an error when the agent client cannot ask for checksums", func() {
+			_, err := vm.DiskChecksum(disk)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("cannot ask the agent for the checksum of disk 'fake-disk-cid'"))
+		})
+	})
+
+	Describe("RunScriptWithTimeout", func() {
+		It("runs the script with the timeout", func() {
+			diskAgentClient := &persistentDiskAgentClient{FakeAgentClient: fakeAgentClient}
+			vm = NewVM("fake-vm-cid", fakeVMRepo, fakeStemcellRepo, fakeDiskDeployer, diskAgentClient, fakeCloud, timeService, fs, logger)
+
+			err := vm.RunScriptWithTimeout("pre-start", map[string]interface{}{}, time.Minute)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(diskAgentClient.ScriptTimeouts).To(Equal(map[string]time.Duration{"pre-start": time.Minute}))
+		})
+
+		It("returns an error when the agent client cannot stop scripts", func() {
+			err := vm.RunScriptWithTimeout("pre-start", map[string]interface{}{}, time.Minute)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("cannot stop script 'pre-start' after a timeout"))
+		})
+	})
+
 	Describe("Start", func() {
 		It("starts agent services", func() {
 			err := vm.Start()
@@ -215,6 +312,54 @@ var _ = Describe("VM", func() {
 		})
 	})
 
+	Describe("WaitUntilReady", func() {
+		It("returns once the agent responds to a ping", func() {
+			err := vm.WaitUntilReady(time.Minute, time.Second, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeAgentClient.PingCallCount()).To(Equal(1))
+		})
+
+		Context("when the agent does not respond", func() {
+			BeforeEach(func() {
+				fakeAgentClient.PingReturns("", errors.New("fake-ping-error"))
+			})
+
+			It("returns the last error after the timeout", func() {
+				err := vm.WaitUntilReady(time.Minute, time.Second, 0)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Agent did not respond within 1m0s: fake-ping-error"))
+				Expect(fakeAgentClient.PingCallCount()).To(Equal(1))
+			})
+
+			It("returns the last error after max errors failed pings in a row", func() {
+				now := time.Now()
+				timeService.Times = []time.Time{now, now, now}
+
+				err := vm.WaitUntilReady(time.Minute, time.Second, 3)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Agent did not respond after 3 attempts: fake-ping-error"))
+				Expect(fakeAgentClient.PingCallCount()).To(Equal(3))
+			})
+		})
+
+		Context("when the agent certificate is not trusted", func() {
+			BeforeEach(func() {
+				mbusErr := biagent.MbusError{Category: biagent.MbusTLSError, Attempts: 1, Err: errors.New("fake-tls-error")}
+				fakeAgentClient.PingReturns("", bosherr.WrapError(mbusErr, "Sending ping to the agent"))
+			})
+
+			It("returns the error without pinging again", func() {
+				now := time.Now()
+				timeService.Times = []time.Time{now, now, now}
+
+				err := vm.WaitUntilReady(time.Minute, time.Second, 0)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-tls-error"))
+				Expect(fakeAgentClient.PingCallCount()).To(Equal(1))
+			})
+		})
+	})
+
 	Describe("AttachDisk", func() {
 		var disk *fakebidisk.FakeDisk
 
@@ -264,6 +409,35 @@ var _ = Describe("VM", func() {
 			})
 		})
 
+		Context("when the cpi returns a disk hint", func() {
+			var diskAgentClient *persistentDiskAgentClient
+
+			BeforeEach(func() {
+				fakeCloud.AttachDiskHint = map[string]interface{}{"path": "/dev/sdc"}
+
+				diskAgentClient = &persistentDiskAgentClient{FakeAgentClient: fakeAgentClient}
+				vm = NewVM("fake-vm-cid", fakeVMRepo, fakeStemcellRepo, fakeDiskDeployer, diskAgentClient, fakeCloud, timeService, fs, logger)
+			})
+
+			It("passes the disk hint to the agent before mounting the disk", func() {
+				err := vm.AttachDisk(disk)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(diskAgentClient.AddedDiskCIDs).To(Equal([]string{"fake-disk-cid"}))
+				Expect(diskAgentClient.AddedDiskHints).To(Equal([]interface{}{map[string]interface{}{"path": "/dev/sdc"}}))
+				Expect(fakeAgentClient.MountDiskArgsForCall(0)).To(Equal("fake-disk-cid"))
+			})
+
+			It("returns an error when the agent cannot add the disk", func() {
+				diskAgentClient.AddErr = errors.New("fake-add-error")
+
+				err := vm.AttachDisk(disk)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-add-error"))
+				Expect(fakeAgentClient.MountDiskCallCount()).To(Equal(0))
+			})
+		})
+
 		It("returns an error if pinging fails", func() {
 			fakeAgentClient.PingReturns("", errors.New("fake-error"))
 
@@ -479,3 +653,36 @@ func (c *FakeClock) Now() time.Time {
 	c.Times = c.Times[1:]
 	return t1
 }
+
+type persistentDiskAgentClient struct {
+	*fakebiagentclient.FakeAgentClient
+	AddedDiskCIDs  []string
+	AddedDiskHints []interface{}
+	AddErr         error
+
+	AppliedSpecs      []bias.ApplySpec
+	AppliedNamedDisks []map[string]string
+
+	ScriptTimeouts map[string]time.Duration
+}
+
+func (c *persistentDiskAgentClient) RunScriptWithTimeout(script string, options map[string]interface{}, timeout time.Duration) error {
+	c.ScriptTimeouts = map[string]time.Duration{script: timeout}
+	return nil
+}
+
+func (c *persistentDiskAgentClient) AddPersistentDisk(diskCID string, diskHint interface{}) error {
+	c.AddedDiskCIDs = append(c.AddedDiskCIDs, diskCID)
+	c.AddedDiskHints = append(c.AddedDiskHints, diskHint)
+	return c.AddErr
+}
+
+func (c *persistentDiskAgentClient) DiskChecksum(diskCID string) (string, error) {
+	return "fake-checksum-of-" + diskCID, nil
+}
+
+func (c *persistentDiskAgentClient) ApplyWithNamedDisks(spec bias.ApplySpec, namedDisks map[string]string) error {
+	c.AppliedSpecs = append(c.AppliedSpecs, spec)
+	c.AppliedNamedDisks = append(c.AppliedNamedDisks, namedDisks)
+	return nil
+}
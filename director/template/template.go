@@ -20,6 +20,10 @@ type EvaluateOpts struct {
 	ExpectAllVarsUsed     bool
 	PostVarSubstitutionOp patch.Op
 	UnescapedMultiline    bool
+
+	// VarSources describes where variables were looked up, in precedence order.
+	// It is only used to make missing variable errors more helpful.
+	VarSources []string
 }
 
 func NewTemplate(bytes []byte) Template {
@@ -41,7 +45,10 @@ func (t Template) Evaluate(vars Variables, op patch.Op, opts EvaluateOpts) ([]by
 		}
 	}
 
-	obj, err = t.interpolateRoot(obj, newVarsTracker(vars, opts.ExpectAllKeys, opts.ExpectAllVarsUsed))
+	tracker := newVarsTracker(vars, opts.ExpectAllKeys, opts.ExpectAllVarsUsed)
+	tracker.sources = opts.VarSources
+
+	obj, err = t.interpolateRoot(obj, tracker)
 	if err != nil {
 		return []byte{}, err
 	}
@@ -198,6 +205,8 @@ type varsTracker struct {
 	expectAllFound bool
 	expectAllUsed  bool
 
+	sources []string // describes searched variable sources for errors
+
 	missing    map[string]struct{} // track missing var names
 	visited    map[string]struct{}
 	visitedAll map[string]struct{} // track all var names that were accessed
@@ -345,6 +354,10 @@ func (t varsTracker) MissingError() error {
 		return nil
 	}
 
+	if len(t.sources) > 0 {
+		return bosherr.WrapErrorf(t.multiErr(t.missing), "Expected to find variables (searched %s)", strings.Join(t.sources, ", "))
+	}
+
 	return bosherr.WrapError(t.multiErr(t.missing), "Expected to find variables")
 }
 
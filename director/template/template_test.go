@@ -103,6 +103,18 @@ dup-key: ((key3))
 		Expect(result).To(Equal([]byte("((key)): ((key2))\nfoo: 2\n")))
 	})
 
+	It("lists searched variable sources in missing variable errors", func() {
+		template := NewTemplate([]byte("((key)): ((key2))"))
+		vars := StaticVariables{"key": "foo"}
+
+		_, err := template.Evaluate(vars, nil, EvaluateOpts{
+			ExpectAllKeys: true,
+			VarSources:    []string{"--var", "--vars-file"},
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Expected to find variables (searched --var, --vars-file): key2"))
+	})
+
 	It("return errors if there are unused variable keys and ExpectAllVarsUsed is true", func() {
 		template := NewTemplate([]byte("((key2))"))
 		vars := StaticVariables{"key1": "1", "key2": "2", "key3": "3"}
@@ -0,0 +1,218 @@
+package eventlog
+
+import (
+	"time"
+
+	"github.com/cloudfoundry/bosh-agent/agentclient"
+	"github.com/cloudfoundry/bosh-agent/agentclient/applyspec"
+	bihttpagent "github.com/cloudfoundry/bosh-agent/agentclient/http"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"github.com/pivotal-golang/clock"
+)
+
+type agentClientFactory struct {
+	factory     bihttpagent.AgentClientFactory
+	log         Log
+	timeService clock.Clock
+}
+
+// NewAgentClientFactory returns an AgentClientFactory whose agent clients
+// record every call to the agent.
+func NewAgentClientFactory(factory bihttpagent.AgentClientFactory, log Log, timeService clock.Clock) bihttpagent.AgentClientFactory {
+	return agentClientFactory{
+		factory:     factory,
+		log:         log,
+		timeService: timeService,
+	}
+}
+
+func (f agentClientFactory) NewAgentClient(directorID, mbusURL, caCert string) (agentclient.AgentClient, error) {
+	client, err := f.factory.NewAgentClient(directorID, mbusURL, caCert)
+	if err != nil {
+		return nil, err
+	}
+
+	return agentClient{client: client, log: f.log, timeService: f.timeService}, nil
+}
+
+type agentClient struct {
+	client      agentclient.AgentClient
+	log         Log
+	timeService clock.Clock
+}
+
+// SetAgentID passes the agent ID on to agent clients that address the agent
+// by its ID, such as NATS agent clients.
+func (c agentClient) SetAgentID(agentID string) {
+	if setter, ok := c.client.(interface {
+		SetAgentID(agentID string)
+	}); ok {
+		setter.SetAgentID(agentID)
+	}
+}
+
+// AddPersistentDisk passes the disk hint on to agent clients that can tell
+// the agent where to find a disk.
+func (c agentClient) AddPersistentDisk(diskCID string, diskHint interface{}) error {
+	adder, ok := c.client.(interface {
+		AddPersistentDisk(diskCID string, diskHint interface{}) error
+	})
+	if !ok {
+		return bosherr.Errorf("Agent client cannot pass the hint of disk '%s' to the agent", diskCID)
+	}
+
+	startTime := c.timeService.Now()
+	err := adder.AddPersistentDisk(diskCID, diskHint)
+	c.record("add_persistent_disk", startTime, err)
+	return err
+}
+
+// ApplyWithNamedDisks passes the named disks on to agent clients that can
+// send them with the apply spec.
+func (c agentClient) ApplyWithNamedDisks(spec applyspec.ApplySpec, namedDisks map[string]string) error {
+	applier, ok := c.client.(interface {
+		ApplyWithNamedDisks(spec applyspec.ApplySpec, namedDisks map[string]string) error
+	})
+	if !ok {
+		return bosherr.Error("Agent client cannot send the named disks of the instance to the agent")
+	}
+
+	startTime := c.timeService.Now()
+	err := applier.ApplyWithNamedDisks(spec, namedDisks)
+	c.record("apply", startTime, err)
+	return err
+}
+
+// DiskChecksum passes the request on to agent clients that can ask the agent
+// for the checksum of a disk.
+func (c agentClient) DiskChecksum(diskCID string) (string, error) {
+	checksummer, ok := c.client.(interface {
+		DiskChecksum(diskCID string) (string, error)
+	})
+	if !ok {
+		return "", bosherr.Errorf("Agent client cannot ask the agent for the checksum of disk '%s'", diskCID)
+	}
+
+	startTime := c.timeService.Now()
+	checksum, err := checksummer.DiskChecksum(diskCID)
+	c.record("disk_checksum", startTime, err)
+	return checksum, err
+}
+
+// RunScriptWithTimeout passes the request on to agent clients that can stop
+// a script running longer than the timeout.
+func (c agentClient) RunScriptWithTimeout(scriptName string, options map[string]interface{}, timeout time.Duration) error {
+	runner, ok := c.client.(interface {
+		RunScriptWithTimeout(scriptName string, options map[string]interface{}, timeout time.Duration) error
+	})
+	if !ok {
+		return bosherr.Errorf("Agent client cannot stop script '%s' after a timeout", scriptName)
+	}
+
+	startTime := c.timeService.Now()
+	err := runner.RunScriptWithTimeout(scriptName, options, timeout)
+	c.record("run_script", startTime, err)
+	return err
+}
+
+func (c agentClient) record(method string, startTime time.Time, err error) {
+	event := Event{Type: AgentCall}
+	if err != nil {
+		event = NewErrorEvent(AgentCall, err)
+	}
+
+	event.Method = method
+	event.Duration = Seconds(c.timeService, startTime)
+	c.log.Record(event)
+}
+
+func (c agentClient) Ping() (string, error) {
+	startTime := c.timeService.Now()
+	state, err := c.client.Ping()
+	c.record("ping", startTime, err)
+	return state, err
+}
+
+func (c agentClient) Stop() error {
+	startTime := c.timeService.Now()
+	err := c.client.Stop()
+	c.record("stop", startTime, err)
+	return err
+}
+
+func (c agentClient) Apply(spec applyspec.ApplySpec) error {
+	startTime := c.timeService.Now()
+	err := c.client.Apply(spec)
+	c.record("apply", startTime, err)
+	return err
+}
+
+func (c agentClient) Start() error {
+	startTime := c.timeService.Now()
+	err := c.client.Start()
+	c.record("start", startTime, err)
+	return err
+}
+
+func (c agentClient) GetState() (agentclient.AgentState, error) {
+	startTime := c.timeService.Now()
+	state, err := c.client.GetState()
+	c.record("get_state", startTime, err)
+	return state, err
+}
+
+func (c agentClient) MountDisk(diskCID string) error {
+	startTime := c.timeService.Now()
+	err := c.client.MountDisk(diskCID)
+	c.record("mount_disk", startTime, err)
+	return err
+}
+
+func (c agentClient) UnmountDisk(diskCID string) error {
+	startTime := c.timeService.Now()
+	err := c.client.UnmountDisk(diskCID)
+	c.record("unmount_disk", startTime, err)
+	return err
+}
+
+func (c agentClient) ListDisk() ([]string, error) {
+	startTime := c.timeService.Now()
+	disks, err := c.client.ListDisk()
+	c.record("list_disk", startTime, err)
+	return disks, err
+}
+
+func (c agentClient) MigrateDisk() error {
+	startTime := c.timeService.Now()
+	err := c.client.MigrateDisk()
+	c.record("migrate_disk", startTime, err)
+	return err
+}
+
+func (c agentClient) CompilePackage(packageSource agentclient.BlobRef, compiledPackageDependencies []agentclient.BlobRef) (agentclient.BlobRef, error) {
+	startTime := c.timeService.Now()
+	compiledPackageRef, err := c.client.CompilePackage(packageSource, compiledPackageDependencies)
+	c.record("compile_package", startTime, err)
+	return compiledPackageRef, err
+}
+
+func (c agentClient) DeleteARPEntries(ips []string) error {
+	startTime := c.timeService.Now()
+	err := c.client.DeleteARPEntries(ips)
+	c.record("delete_arp_entries", startTime, err)
+	return err
+}
+
+func (c agentClient) SyncDNS(blobID, sha1 string, version uint64) (string, error) {
+	startTime := c.timeService.Now()
+	result, err := c.client.SyncDNS(blobID, sha1, version)
+	c.record("sync_dns", startTime, err)
+	return result, err
+}
+
+func (c agentClient) RunScript(scriptName string, options map[string]interface{}) error {
+	startTime := c.timeService.Now()
+	err := c.client.RunScript(scriptName, options)
+	c.record("run_script", startTime, err)
+	return err
+}
@@ -0,0 +1,121 @@
+package eventlog_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cloudfoundry/bosh-agent/agentclient/applyspec"
+	fakeagentclient "github.com/cloudfoundry/bosh-agent/agentclient/fakes"
+	mock_httpagent "github.com/cloudfoundry/bosh-agent/agentclient/http/mocks"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/cloudfoundry/bosh-cli/eventlog"
+	fakebieventlog "github.com/cloudfoundry/bosh-cli/eventlog/fakes"
+)
+
+var _ = Describe("AgentClientFactory", func() {
+	var (
+		mockCtrl               *gomock.Controller
+		mockAgentClientFactory *mock_httpagent.MockAgentClientFactory
+		fakeAgentClient        *fakeagentclient.FakeAgentClient
+		fakeLog                *fakebieventlog.FakeLog
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockAgentClientFactory = mock_httpagent.NewMockAgentClientFactory(mockCtrl)
+		fakeAgentClient = &fakeagentclient.FakeAgentClient{}
+		fakeLog = fakebieventlog.NewFakeLog()
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("returns agent clients that record agent calls", func() {
+		mockAgentClientFactory.EXPECT().NewAgentClient("fake-director-id", "fake-mbus-url", "fake-ca-cert").Return(fakeAgentClient, nil)
+		fakeAgentClient.PingReturns("running", nil)
+		fakeAgentClient.StopReturns(errors.New("fake-stop-error"))
+
+		factory := NewAgentClientFactory(mockAgentClientFactory, fakeLog, fakeclock.NewFakeClock(time.Now()))
+		agentClient, err := factory.NewAgentClient("fake-director-id", "fake-mbus-url", "fake-ca-cert")
+		Expect(err).ToNot(HaveOccurred())
+
+		state, err := agentClient.Ping()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state).To(Equal("running"))
+
+		err = agentClient.Stop()
+		Expect(err).To(MatchError("fake-stop-error"))
+
+		Expect(fakeLog.Events).To(Equal([]Event{
+			{Type: AgentCall, Method: "ping"},
+			{Type: AgentCall, Method: "stop", Error: "fake-stop-error", Causes: []string{"fake-stop-error"}},
+		}))
+	})
+
+	It("passes disk hints and named disks on to agent clients that support them", func() {
+		diskAgentClient := &persistentDiskAgentClient{FakeAgentClient: fakeAgentClient}
+		mockAgentClientFactory.EXPECT().NewAgentClient("fake-director-id", "fake-mbus-url", "").Return(diskAgentClient, nil)
+
+		factory := NewAgentClientFactory(mockAgentClientFactory, fakeLog, fakeclock.NewFakeClock(time.Now()))
+		agentClient, err := factory.NewAgentClient("fake-director-id", "fake-mbus-url", "")
+		Expect(err).ToNot(HaveOccurred())
+
+		err = agentClient.(persistentDiskClient).AddPersistentDisk("fake-disk-cid", "fake-disk-hint")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(diskAgentClient.AddedDiskHints).To(Equal(map[string]interface{}{"fake-disk-cid": "fake-disk-hint"}))
+
+		err = agentClient.(persistentDiskClient).ApplyWithNamedDisks(applyspec.ApplySpec{}, map[string]string{"fake-disk-name": "fake-disk-cid"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(diskAgentClient.AppliedNamedDisks).To(Equal(map[string]string{"fake-disk-name": "fake-disk-cid"}))
+
+		Expect(fakeLog.Events).To(Equal([]Event{
+			{Type: AgentCall, Method: "add_persistent_disk"},
+			{Type: AgentCall, Method: "apply"},
+		}))
+	})
+
+	It("returns an error passing disk hints to agent clients that do not support them", func() {
+		mockAgentClientFactory.EXPECT().NewAgentClient("fake-director-id", "fake-mbus-url", "").Return(fakeAgentClient, nil)
+
+		factory := NewAgentClientFactory(mockAgentClientFactory, fakeLog, fakeclock.NewFakeClock(time.Now()))
+		agentClient, err := factory.NewAgentClient("fake-director-id", "fake-mbus-url", "")
+		Expect(err).ToNot(HaveOccurred())
+
+		err = agentClient.(persistentDiskClient).AddPersistentDisk("fake-disk-cid", "fake-disk-hint")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns errors creating agent clients", func() {
+		mockAgentClientFactory.EXPECT().NewAgentClient("fake-director-id", "fake-mbus-url", "").Return(nil, errors.New("fake-new-err"))
+
+		factory := NewAgentClientFactory(mockAgentClientFactory, fakeLog, fakeclock.NewFakeClock(time.Now()))
+		_, err := factory.NewAgentClient("fake-director-id", "fake-mbus-url", "")
+		Expect(err).To(MatchError("fake-new-err"))
+	})
+})
+
+type persistentDiskClient interface {
+	AddPersistentDisk(diskCID string, diskHint interface{}) error
+	ApplyWithNamedDisks(spec applyspec.ApplySpec, namedDisks map[string]string) error
+}
+
+type persistentDiskAgentClient struct {
+	*fakeagentclient.FakeAgentClient
+	AddedDiskHints    map[string]interface{}
+	AppliedNamedDisks map[string]string
+}
+
+func (c *persistentDiskAgentClient) AddPersistentDisk(diskCID string, diskHint interface{}) error {
+	c.AddedDiskHints = map[string]interface{}{diskCID: diskHint}
+	return nil
+}
+
+func (c *persistentDiskAgentClient) ApplyWithNamedDisks(spec applyspec.ApplySpec, namedDisks map[string]string) error {
+	c.AppliedNamedDisks = namedDisks
+	return nil
+}
@@ -0,0 +1,153 @@
+package eventlog
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"github.com/pivotal-golang/clock"
+
+	bilog "github.com/cloudfoundry/bosh-cli/logger"
+)
+
+const (
+	RunStarted    = "run_started"
+	RunFinished   = "run_finished"
+	StageStarted  = "stage_started"
+	StageFinished = "stage_finished"
+	StageSkipped  = "stage_skipped"
+	StageFailed   = "stage_failed"
+	CPICall       = "cpi_call"
+	AgentCall     = "agent_call"
+	RunError      = "error"
+)
+
+// Event is a single line of the event log. Durations are in seconds.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	Stage    string    `json:"stage,omitempty"`
+	Method   string    `json:"method,omitempty"`
+	Duration float64   `json:"duration,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Causes   []string  `json:"causes,omitempty"`
+}
+
+// Log records the events of a run for post-mortem analysis and for
+// external progress UIs. Failing to record an event never fails the run.
+type Log interface {
+	Record(Event)
+	Close() error
+}
+
+type fileLog struct {
+	file        boshsys.File
+	timeService clock.Clock
+	redactor    *bilog.Redactor
+	lock        sync.Mutex
+
+	logTag string
+	logger boshlog.Logger
+}
+
+// NewFileLog truncates the file at path and writes each recorded event to it
+// as a line of JSON. Secrets in error messages are masked with redactor.
+func NewFileLog(path string, fs boshsys.FileSystem, timeService clock.Clock, redactor *bilog.Redactor, logger boshlog.Logger) (Log, error) {
+	file, err := fs.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Opening event log '%s'", path)
+	}
+
+	return &fileLog{
+		file:        file,
+		timeService: timeService,
+		redactor:    redactor,
+
+		logTag: "eventLog",
+		logger: logger,
+	}, nil
+}
+
+func (l *fileLog) Record(event Event) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if event.Time.IsZero() {
+		event.Time = l.timeService.Now()
+	}
+
+	event.Error = l.redactor.Redact(event.Error)
+
+	if event.Causes != nil {
+		causes := make([]string, len(event.Causes))
+		for i, cause := range event.Causes {
+			causes[i] = l.redactor.Redact(cause)
+		}
+		event.Causes = causes
+	}
+
+	bytes, err := json.Marshal(event)
+	if err != nil {
+		l.logger.Error(l.logTag, "Marshalling event %#v: %s", event, err.Error())
+		return
+	}
+
+	_, err = l.file.Write(append(bytes, '\n'))
+	if err != nil {
+		l.logger.Error(l.logTag, "Writing event: %s", err.Error())
+	}
+}
+
+func (l *fileLog) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.file.Close()
+}
+
+type noopLog struct{}
+
+// NewNoopLog returns a Log for runs without an event log.
+func NewNoopLog() Log {
+	return noopLog{}
+}
+
+func (noopLog) Record(Event) {}
+
+func (noopLog) Close() error { return nil }
+
+// NewErrorEvent returns an event of the given type for err, with the
+// messages of the errors err wraps as its causes.
+func NewErrorEvent(eventType string, err error) Event {
+	return Event{
+		Type:   eventType,
+		Error:  err.Error(),
+		Causes: causes(err),
+	}
+}
+
+func causes(err error) []string {
+	switch typedErr := err.(type) {
+	case bosherr.ComplexError:
+		return append([]string{typedErr.Err.Error()}, causes(typedErr.Cause)...)
+
+	case bosherr.MultiError:
+		messages := []string{}
+		for _, err := range typedErr.Errors {
+			messages = append(messages, causes(err)...)
+		}
+		return messages
+
+	default:
+		return []string{err.Error()}
+	}
+}
+
+// Seconds returns the duration since start in seconds, for Event.Duration.
+func Seconds(timeService clock.Clock, start time.Time) float64 {
+	return timeService.Now().Sub(start).Seconds()
+}
@@ -0,0 +1,32 @@
+package fakes
+
+import (
+	"sync"
+
+	bieventlog "github.com/cloudfoundry/bosh-cli/eventlog"
+)
+
+type FakeLog struct {
+	Events []bieventlog.Event
+	Closed bool
+
+	CloseErr error
+
+	lock sync.Mutex
+}
+
+func NewFakeLog() *FakeLog {
+	return &FakeLog{}
+}
+
+func (l *FakeLog) Record(event bieventlog.Event) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.Events = append(l.Events, event)
+}
+
+func (l *FakeLog) Close() error {
+	l.Closed = true
+	return l.CloseErr
+}
@@ -0,0 +1,73 @@
+package eventlog
+
+import (
+	"github.com/pivotal-golang/clock"
+
+	biui "github.com/cloudfoundry/bosh-cli/ui"
+)
+
+type stage struct {
+	stage       biui.Stage
+	log         Log
+	timeService clock.Clock
+}
+
+// NewStage returns a Stage that records the start and the end of every step
+// of the wrapped stage and its sub-stages.
+func NewStage(wrappedStage biui.Stage, log Log, timeService clock.Clock) biui.Stage {
+	return &stage{
+		stage:       wrappedStage,
+		log:         log,
+		timeService: timeService,
+	}
+}
+
+func (s *stage) Perform(name string, closure func() error) error {
+	return s.stage.Perform(name, func() error {
+		return s.record(name, closure)
+	})
+}
+
+func (s *stage) PerformWithProgress(name string, closure func(biui.Progress) error) error {
+	return s.stage.PerformWithProgress(name, func(progress biui.Progress) error {
+		return s.record(name, func() error {
+			return closure(progress)
+		})
+	})
+}
+
+func (s *stage) PerformComplex(name string, closure func(biui.Stage) error) error {
+	return s.stage.PerformComplex(name, func(subStage biui.Stage) error {
+		return s.record(name, func() error {
+			return closure(NewStage(subStage, s.log, s.timeService))
+		})
+	})
+}
+
+func (s *stage) NewBufferedStage() (biui.Stage, func()) {
+	buffered, flush := biui.NewBufferedStage(s.stage)
+
+	return NewStage(buffered, s.log, s.timeService), flush
+}
+
+func (s *stage) record(name string, closure func() error) error {
+	s.log.Record(Event{Type: StageStarted, Stage: name})
+	startTime := s.timeService.Now()
+
+	err := closure()
+	if err != nil {
+		if _, skipped := err.(biui.SkipStageError); skipped {
+			s.log.Record(Event{Type: StageSkipped, Stage: name, Duration: Seconds(s.timeService, startTime)})
+			return err
+		}
+
+		event := NewErrorEvent(StageFailed, err)
+		event.Stage = name
+		event.Duration = Seconds(s.timeService, startTime)
+		s.log.Record(event)
+		return err
+	}
+
+	s.log.Record(Event{Type: StageFinished, Stage: name, Duration: Seconds(s.timeService, startTime)})
+	return nil
+}
@@ -0,0 +1,116 @@
+package eventlog_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/cloudfoundry/bosh-cli/eventlog"
+	fakebieventlog "github.com/cloudfoundry/bosh-cli/eventlog/fakes"
+	biui "github.com/cloudfoundry/bosh-cli/ui"
+	fakebiui "github.com/cloudfoundry/bosh-cli/ui/fakes"
+)
+
+var _ = Describe("Stage", func() {
+	var (
+		fakeStage *fakebiui.FakeStage
+		fakeLog   *fakebieventlog.FakeLog
+		fakeClock *fakeclock.FakeClock
+		stage     biui.Stage
+	)
+
+	BeforeEach(func() {
+		fakeStage = fakebiui.NewFakeStage()
+		fakeLog = fakebieventlog.NewFakeLog()
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		stage = NewStage(fakeStage, fakeLog, fakeClock)
+	})
+
+	It("records the start and finish of steps", func() {
+		err := stage.Perform("fake-step", func() error {
+			fakeClock.Increment(2 * time.Second)
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(fakeStage.PerformCalls).To(HaveLen(1))
+		Expect(fakeLog.Events).To(Equal([]Event{
+			{Type: StageStarted, Stage: "fake-step"},
+			{Type: StageFinished, Stage: "fake-step", Duration: 2},
+		}))
+	})
+
+	It("records failed steps with their error", func() {
+		err := stage.Perform("fake-step", func() error {
+			return errors.New("fake-step-error")
+		})
+		Expect(err).To(MatchError("fake-step-error"))
+
+		Expect(fakeLog.Events).To(Equal([]Event{
+			{Type: StageStarted, Stage: "fake-step"},
+			{Type: StageFailed, Stage: "fake-step", Error: "fake-step-error", Causes: []string{"fake-step-error"}},
+		}))
+	})
+
+	It("records skipped steps", func() {
+		err := stage.Perform("fake-step", func() error {
+			return biui.NewSkipStageError(errors.New("fake-cause"), "fake-skip-message")
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(fakeLog.Events).To(Equal([]Event{
+			{Type: StageStarted, Stage: "fake-step"},
+			{Type: StageSkipped, Stage: "fake-step"},
+		}))
+	})
+
+	It("records the start and finish of steps with progress", func() {
+		err := stage.PerformWithProgress("fake-step", func(progress biui.Progress) error {
+			progress.SetTotal(1)
+			progress.Add(1)
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(fakeStage.PerformCalls[0].Progress).To(Equal(&fakebiui.FakeProgress{Total: 1, Done: 1}))
+		Expect(fakeLog.Events).To(Equal([]Event{
+			{Type: StageStarted, Stage: "fake-step"},
+			{Type: StageFinished, Stage: "fake-step"},
+		}))
+	})
+
+	It("records the steps of sub-stages", func() {
+		err := stage.PerformComplex("fake-complex-stage", func(subStage biui.Stage) error {
+			return subStage.Perform("fake-sub-step", func() error { return nil })
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(fakeLog.Events).To(Equal([]Event{
+			{Type: StageStarted, Stage: "fake-complex-stage"},
+			{Type: StageStarted, Stage: "fake-sub-step"},
+			{Type: StageFinished, Stage: "fake-sub-step"},
+			{Type: StageFinished, Stage: "fake-complex-stage"},
+		}))
+	})
+
+	It("records the steps of buffered stages as they are performed", func() {
+		buffered, flush := biui.NewBufferedStage(stage)
+
+		err := buffered.Perform("fake-step", func() error { return nil })
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(fakeLog.Events).To(Equal([]Event{
+			{Type: StageStarted, Stage: "fake-step"},
+			{Type: StageFinished, Stage: "fake-step"},
+		}))
+		Expect(fakeStage.PerformCalls).To(BeEmpty())
+
+		flush()
+
+		Expect(fakeStage.PerformCalls).To(HaveLen(1))
+		Expect(fakeStage.PerformCalls[0].Name).To(Equal("fake-step"))
+	})
+})
@@ -2,12 +2,14 @@ package index
 
 import (
 	"encoding/json"
+	"sync"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
 type inMemoryIndex struct {
 	entryMap map[string][]byte
+	lock     sync.RWMutex
 }
 
 func NewInMemoryIndex() Index {
@@ -22,7 +24,10 @@ func (ri *inMemoryIndex) Find(key interface{}, valuePtr interface{}) error {
 		return bosherr.WrapErrorf(err, "Marshalling key %#v", key)
 	}
 
+	ri.lock.RLock()
 	valueBytes, exists := ri.entryMap[string(keyBytes)]
+	ri.lock.RUnlock()
+
 	if !exists {
 		return ErrNotFound
 	}
@@ -46,7 +51,9 @@ func (ri *inMemoryIndex) Save(key interface{}, value interface{}) error {
 		return bosherr.WrapErrorf(err, "Marshalling value %#v", value)
 	}
 
+	ri.lock.Lock()
 	ri.entryMap[string(keyBytes)] = valueBytes
+	ri.lock.Unlock()
 
 	return nil
 }
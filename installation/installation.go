@@ -11,6 +11,7 @@ import (
 type Installation interface {
 	Target() Target
 	Job() InstalledJob
+	CPICalls() biinstallmanifest.CPICalls
 	WithRunningRegistry(boshlog.Logger, biui.Stage, func() error) error
 	StartRegistry() error
 	StopRegistry() error
@@ -47,6 +48,10 @@ func (i *installation) Job() InstalledJob {
 	return i.job
 }
 
+func (i *installation) CPICalls() biinstallmanifest.CPICalls {
+	return i.manifest.CPICalls
+}
+
 func (i *installation) WithRunningRegistry(logger boshlog.Logger, stage biui.Stage, fn func() error) error {
 	err := stage.Perform("Starting registry", func() error {
 		return i.StartRegistry()
@@ -73,7 +78,8 @@ func (i *installation) StartRegistry() error {
 			return bosherr.Error("Registry already started")
 		}
 		config := i.manifest.Registry
-		registryServer, err := i.registryServerManager.Start(config.Username, config.Password, config.Host, config.Port)
+		tlsConfig := biregistry.TLSConfig{Certificate: config.TLS.Certificate, PrivateKey: config.TLS.PrivateKey}
+		registryServer, err := i.registryServerManager.Start(config.Username, config.Password, config.ListenAddress(), config.Port, tlsConfig)
 		if err != nil {
 			return bosherr.WrapError(err, "Starting registry")
 		}
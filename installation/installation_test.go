@@ -7,6 +7,7 @@ import (
 	. "github.com/cloudfoundry/bosh-cli/installation"
 
 	biinstallmanifest "github.com/cloudfoundry/bosh-cli/installation/manifest"
+	biregistry "github.com/cloudfoundry/bosh-cli/registry"
 	mock_registry "github.com/cloudfoundry/bosh-cli/registry/mocks"
 	test_support_mocks "github.com/cloudfoundry/bosh-cli/test_support/mocks"
 	fakebiui "github.com/cloudfoundry/bosh-cli/ui/fakes"
@@ -70,7 +71,7 @@ var _ = Describe("Installation", func() {
 			}
 
 			spy = test_support_mocks.NewMockSpy(mockCtrl)
-			startCall = mockRegistryServerManager.EXPECT().Start("fake-username", "fake-password", "fake-host", 123).Return(mockRegistryServer, nil)
+			startCall = mockRegistryServerManager.EXPECT().Start("fake-username", "fake-password", "fake-host", 123, biregistry.TLSConfig{}).Return(mockRegistryServer, nil)
 			spyCall = spy.EXPECT().Record()
 			stopCall = mockRegistryServer.EXPECT().Stop().Return(nil)
 
@@ -184,7 +185,20 @@ var _ = Describe("Installation", func() {
 			})
 
 			It("starts the registry", func() {
-				mockRegistryServerManager.EXPECT().Start("fake-username", "fake-password", "fake-host", 123).Return(mockRegistryServer, nil)
+				mockRegistryServerManager.EXPECT().Start("fake-username", "fake-password", "fake-host", 123, biregistry.TLSConfig{}).Return(mockRegistryServer, nil)
+
+				err := newInstalation().StartRegistry()
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("starts the registry on the bind address with the TLS certificate", func() {
+				manifest.Registry.BindAddress = "0.0.0.0"
+				manifest.Registry.TLS = biinstallmanifest.RegistryTLS{Certificate: "fake-certificate", PrivateKey: "fake-private-key"}
+
+				mockRegistryServerManager.EXPECT().Start("fake-username", "fake-password", "0.0.0.0", 123, biregistry.TLSConfig{
+					Certificate: "fake-certificate",
+					PrivateKey:  "fake-private-key",
+				}).Return(mockRegistryServer, nil)
 
 				err := newInstalation().StartRegistry()
 				Expect(err).NotTo(HaveOccurred())
@@ -192,7 +206,7 @@ var _ = Describe("Installation", func() {
 
 			Context("when starting registry fails", func() {
 				BeforeEach(func() {
-					mockRegistryServerManager.EXPECT().Start("fake-username", "fake-password", "fake-host", 123).Return(nil, errors.New("fake-registry-start-error"))
+					mockRegistryServerManager.EXPECT().Start("fake-username", "fake-password", "fake-host", 123, biregistry.TLSConfig{}).Return(nil, errors.New("fake-registry-start-error"))
 				})
 
 				It("returns an error", func() {
@@ -229,7 +243,7 @@ var _ = Describe("Installation", func() {
 
 				installation = newInstalation()
 
-				mockRegistryServerManager.EXPECT().Start("fake-username", "fake-password", "fake-host", 123).Return(mockRegistryServer, nil)
+				mockRegistryServerManager.EXPECT().Start("fake-username", "fake-password", "fake-host", 123, biregistry.TLSConfig{}).Return(mockRegistryServer, nil)
 				err := installation.StartRegistry()
 				Expect(err).ToNot(HaveOccurred())
 			})
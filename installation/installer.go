@@ -72,8 +72,8 @@ func (i *installer) Install(manifest biinstallmanifest.Manifest, stage biui.Stag
 		return nil, err
 	}
 
-	err = stage.Perform("Installing packages", func() error {
-		return i.installPackages(compiledPackages)
+	err = stage.PerformWithProgress("Installing packages", func(progress biui.Progress) error {
+		return i.installPackages(compiledPackages, progress)
 	})
 	if err != nil {
 		return nil, err
@@ -103,12 +103,16 @@ func (i *installer) Cleanup(installation Installation) error {
 	return i.blobExtractor.Cleanup(job.BlobstoreID, job.Path)
 }
 
-func (i *installer) installPackages(compiledPackages []CompiledPackageRef) error {
+func (i *installer) installPackages(compiledPackages []CompiledPackageRef, progress biui.Progress) error {
+	progress.SetTotal(int64(len(compiledPackages)))
+
 	for _, pkg := range compiledPackages {
 		err := i.blobExtractor.Extract(pkg.BlobstoreID, pkg.SHA1, filepath.Join(i.target.PackagesPath(), pkg.Name))
 		if err != nil {
 			return bosherr.WrapErrorf(err, "Installing package '%s'", pkg.Name)
 		}
+
+		progress.Add(1)
 	}
 	return nil
 }
@@ -103,7 +103,7 @@ type installerFactoryContext struct {
 
 func (c *installerFactoryContext) JobRenderer() JobRenderer {
 
-	erbRenderer := bierbrenderer.NewERBRenderer(c.fs, c.runner, c.logger)
+	erbRenderer := bierbrenderer.NewGoFirstERBRenderer(c.fs, c.runner, c.logger)
 	jobRenderer := bitemplate.NewJobRenderer(erbRenderer, c.fs, c.uuidGenerator, c.logger)
 	jobListRenderer := bitemplate.NewJobListRenderer(jobRenderer, c.logger)
 
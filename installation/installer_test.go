@@ -113,6 +113,14 @@ var _ = Describe("Installer", func() {
 				Expect(err).NotTo(HaveOccurred())
 			})
 
+			It("reports the packages installed out of the total", func() {
+				_, err := installer.Install(installationManifest, fakeStage)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeStage.PerformCalls[0].Name).To(Equal("Installing packages"))
+				Expect(fakeStage.PerformCalls[0].Progress).To(Equal(&fakebiui.FakeProgress{Total: 1, Done: 1}))
+			})
+
 			It("installs the rendered jobs", func() {
 				_, err := installer.Install(installationManifest, fakeStage)
 				Expect(err).NotTo(HaveOccurred())
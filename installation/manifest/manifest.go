@@ -1,6 +1,12 @@
 package manifest
 
 import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	biproperty "github.com/cloudfoundry/bosh-utils/property"
 )
 
@@ -11,6 +17,21 @@ type Manifest struct {
 	Mbus       string
 	Cert       Certificate
 	Registry   Registry
+	Blobstore  Blobstore
+	CPICalls   CPICalls
+
+	// CPIs are further CPIs that resource pools can choose by name instead
+	// of the cloud_provider CPI. CPIName is the name of the chosen one.
+	CPIs    []CPI
+	CPIName string
+}
+
+// CPI is a named CPI job with its own properties. It shares the registry,
+// mbus and blobstore of the cloud_provider.
+type CPI struct {
+	Name       string
+	Template   ReleaseJobRef
+	Properties biproperty.Map
 }
 
 type Certificate struct {
@@ -22,18 +43,93 @@ type ReleaseJobRef struct {
 	Release string
 }
 
+// Registry is the registry started while deploying. Host is the address the
+// CPI and the agent reach it at, BindAddress the address it listens on.
 type Registry struct {
-	Username  string
-	Password  string
-	Host      string
-	Port      int
-	SSHTunnel SSHTunnel
+	Username    string
+	Password    string
+	Host        string
+	Port        int
+	BindAddress string
+	TLS         RegistryTLS
+	SSHTunnel   SSHTunnel
+}
+
+// RegistryTLS has the PEM encoded certificate and private key of a registry
+// serving HTTPS.
+type RegistryTLS struct {
+	Certificate string
+	PrivateKey  string `yaml:"private_key"`
+}
+
+func (t RegistryTLS) IsEmpty() bool {
+	return t == RegistryTLS{}
+}
+
+// ListenAddress is the address the registry listens on, the host unless a
+// bind address is configured.
+func (r Registry) ListenAddress() string {
+	if r.BindAddress != "" {
+		return r.BindAddress
+	}
+	return r.Host
 }
 
 func (r Registry) IsEmpty() bool {
 	return r == Registry{}
 }
 
+// Blobstore is a remote blobstore used instead of the agent's own blobstore
+// for compiled packages and rendered jobs. The agent has to be configured
+// with the same blobstore through the CPI properties.
+type Blobstore struct {
+	Provider string
+	Options  biproperty.Map
+}
+
+func (b Blobstore) IsEmpty() bool {
+	return b.Provider == "" && len(b.Options) == 0
+}
+
+// CPICalls limits how long calls to the CPI may run and how often calls
+// failing with an error the CPI marks as ok_to_retry are retried. Methods
+// override the defaults for single CPI methods.
+type CPICalls struct {
+	CPICallPolicy
+	Methods map[string]CPICallPolicy
+}
+
+// CPICallPolicy is the policy of a CPI call. Zero values mean no timeout and
+// a single attempt.
+type CPICallPolicy struct {
+	Timeout     time.Duration
+	MaxAttempts int
+	RetryDelay  time.Duration
+}
+
+// For returns the policy of the given CPI method, falling back to the
+// defaults for anything the method does not set.
+func (c CPICalls) For(method string) CPICallPolicy {
+	policy := c.CPICallPolicy
+
+	methodPolicy, found := c.Methods[method]
+	if !found {
+		return policy
+	}
+
+	if methodPolicy.Timeout != 0 {
+		policy.Timeout = methodPolicy.Timeout
+	}
+	if methodPolicy.MaxAttempts != 0 {
+		policy.MaxAttempts = methodPolicy.MaxAttempts
+	}
+	if methodPolicy.RetryDelay != 0 {
+		policy.RetryDelay = methodPolicy.RetryDelay
+	}
+
+	return policy
+}
+
 type SSHTunnel struct {
 	User       string
 	Host       string
@@ -42,6 +138,36 @@ type SSHTunnel struct {
 	PrivateKey string `yaml:"private_key"`
 }
 
+// ForCPI returns the manifest with the template and properties of the named
+// CPI, or the manifest itself for an empty name.
+func (m Manifest) ForCPI(name string) (Manifest, error) {
+	if name == "" {
+		return m, nil
+	}
+
+	for _, cpi := range m.CPIs {
+		if cpi.Name != name {
+			continue
+		}
+
+		properties := biproperty.Map{}
+		for key, value := range cpi.Properties {
+			properties[key] = value
+		}
+		if registryProperties, found := m.Properties["registry"]; found {
+			properties["registry"] = registryProperties
+		}
+
+		m.Template = cpi.Template
+		m.Properties = properties
+		m.CPIName = cpi.Name
+
+		return m, nil
+	}
+
+	return Manifest{}, bosherr.Errorf("CPI '%s' not found in cpis", name)
+}
+
 func (m *Manifest) PopulateRegistry(username string, password string, host string, port int, sshTunnel SSHTunnel) {
 	m.Properties["registry"] = biproperty.Map{
 		"host":     host,
@@ -57,3 +183,13 @@ func (m *Manifest) PopulateRegistry(username string, password string, host strin
 		SSHTunnel: sshTunnel,
 	}
 }
+
+// PopulateRegistryTLS makes the registry serve HTTPS and points the CPI to
+// its https endpoint.
+func (m *Manifest) PopulateRegistryTLS(tls RegistryTLS) {
+	m.Registry.TLS = tls
+
+	if registryProperties, ok := m.Properties["registry"].(biproperty.Map); ok {
+		registryProperties["endpoint"] = fmt.Sprintf("https://%s", net.JoinHostPort(m.Registry.Host, strconv.Itoa(m.Registry.Port)))
+	}
+}
@@ -3,6 +3,7 @@ package manifest
 import (
 	"encoding/pem"
 	"strings"
+	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -27,49 +28,123 @@ type parser struct {
 	logger        boshlog.Logger
 	logTag        string
 	validator     Validator
+	opts          ParserOpts
+}
+
+type ParserOpts struct {
+	// VarKVs are one-off variables that take precedence over the variables
+	// manifests are parsed with.
+	VarKVs map[string]string
+
+	// VarSources describes where the variables manifests are parsed with are
+	// looked up, in precedence order, for errors about missing variables.
+	VarSources []string
 }
 
 type manifest struct {
 	Name          string
 	CloudProvider installation `yaml:"cloud_provider"`
+	CPIs          []cpi        `yaml:"cpis"`
+}
+
+type cpi struct {
+	Name       string
+	Template   template
+	Properties map[interface{}]interface{}
 }
 
 type installation struct {
 	Template   template
 	Properties map[interface{}]interface{}
 	SSHTunnel  SSHTunnel `yaml:"ssh_tunnel"`
+	Registry   registry
 	Mbus       string
 	Cert       Certificate
+	Blobstore  blobstore
+	CPI        cpiCalls
+}
+
+type cpiCalls struct {
+	cpiCallPolicy `yaml:",inline"`
+	Methods       map[string]cpiCallPolicy
+}
+
+// cpiCallPolicy has its timeout and retry delay in seconds
+type cpiCallPolicy struct {
+	Timeout     int
+	MaxAttempts int `yaml:"max_attempts"`
+	RetryDelay  int `yaml:"retry_delay"`
+}
+
+func (p cpiCallPolicy) toPolicy() CPICallPolicy {
+	return CPICallPolicy{
+		Timeout:     time.Duration(p.Timeout) * time.Second,
+		MaxAttempts: p.MaxAttempts,
+		RetryDelay:  time.Duration(p.RetryDelay) * time.Second,
+	}
+}
+
+type registry struct {
+	Host        string
+	Port        int
+	BindAddress string `yaml:"bind_address"`
+	TLS         RegistryTLS
+}
+
+type blobstore struct {
+	Provider string
+	Options  map[interface{}]interface{}
 }
 
 func (i installation) HasSSHTunnel() bool {
 	return i.SSHTunnel != SSHTunnel{}
 }
 
+func (i installation) HasRegistry() bool {
+	return i.Registry != registry{}
+}
+
+func (i installation) HasBlobstore() bool {
+	return i.Blobstore.Provider != "" || len(i.Blobstore.Options) > 0
+}
+
 type template struct {
 	Name    string
 	Release string
 }
 
 func NewParser(fs boshsys.FileSystem, uuidGenerator boshuuid.Generator, logger boshlog.Logger, validator Validator) Parser {
+	return NewParserWithOpts(fs, uuidGenerator, logger, validator, ParserOpts{})
+}
+
+// NewParserWithVarKVs returns a parser overriding the variables manifests are
+// parsed with by the given variables.
+func NewParserWithVarKVs(fs boshsys.FileSystem, uuidGenerator boshuuid.Generator, logger boshlog.Logger, validator Validator, varKVs map[string]string) Parser {
+	return NewParserWithOpts(fs, uuidGenerator, logger, validator, ParserOpts{VarKVs: varKVs})
+}
+
+func NewParserWithOpts(fs boshsys.FileSystem, uuidGenerator boshuuid.Generator, logger boshlog.Logger, validator Validator, opts ParserOpts) Parser {
 	return &parser{
 		fs:            fs,
 		uuidGenerator: uuidGenerator,
 		logger:        logger,
 		logTag:        "deploymentParser",
 		validator:     validator,
+		opts:          opts,
 	}
 }
 
 func (p *parser) Parse(path string, vars boshtpl.Variables, op patch.Op, releaseSetManifest birelsetmanifest.Manifest) (Manifest, error) {
-	contents, err := p.fs.ReadFile(path)
+	contents, err := biutil.ReadManifest(path, p.fs)
 	if err != nil {
-		return Manifest{}, bosherr.WrapErrorf(err, "Reading file %s", path)
+		return Manifest{}, err
 	}
 
 	tpl := boshtpl.NewTemplate(contents)
 
-	bytes, err := tpl.Evaluate(vars, op, boshtpl.EvaluateOpts{ExpectAllKeys: true})
+	vars, varSources := p.overrideVars(vars)
+
+	bytes, err := tpl.Evaluate(vars, op, boshtpl.EvaluateOpts{ExpectAllKeys: true, VarSources: varSources})
 	if err != nil {
 		return Manifest{}, bosherr.WrapErrorf(err, "Evaluating manifest")
 	}
@@ -117,6 +192,16 @@ func (p *parser) Parse(path string, vars boshtpl.Variables, op patch.Op, release
 		},
 		Mbus: comboManifest.CloudProvider.Mbus,
 		Cert: comboManifest.CloudProvider.Cert,
+		CPICalls: CPICalls{
+			CPICallPolicy: comboManifest.CloudProvider.CPI.toPolicy(),
+		},
+	}
+
+	if len(comboManifest.CloudProvider.CPI.Methods) > 0 {
+		installationManifest.CPICalls.Methods = map[string]CPICallPolicy{}
+		for method, policy := range comboManifest.CloudProvider.CPI.Methods {
+			installationManifest.CPICalls.Methods[method] = policy.toPolicy()
+		}
 	}
 
 	properties, err := biproperty.BuildMap(comboManifest.CloudProvider.Properties)
@@ -125,12 +210,54 @@ func (p *parser) Parse(path string, vars boshtpl.Variables, op patch.Op, release
 	}
 	installationManifest.Properties = properties
 
-	if comboManifest.CloudProvider.HasSSHTunnel() {
+	for _, rawCPI := range comboManifest.CPIs {
+		cpiProperties, err := biproperty.BuildMap(rawCPI.Properties)
+		if err != nil {
+			return Manifest{}, bosherr.WrapErrorf(err, "Parsing cpis '%s' properties: %#v", rawCPI.Name, rawCPI.Properties)
+		}
+
+		installationManifest.CPIs = append(installationManifest.CPIs, CPI{
+			Name: rawCPI.Name,
+			Template: ReleaseJobRef{
+				Name:    rawCPI.Template.Name,
+				Release: rawCPI.Template.Release,
+			},
+			Properties: cpiProperties,
+		})
+	}
+
+	if comboManifest.CloudProvider.HasBlobstore() {
+		blobstoreOptions, err := biproperty.BuildMap(comboManifest.CloudProvider.Blobstore.Options)
+		if err != nil {
+			return Manifest{}, bosherr.WrapErrorf(err, "Parsing cloud_provider manifest blobstore options: %#v", comboManifest.CloudProvider.Blobstore.Options)
+		}
+		installationManifest.Blobstore = Blobstore{
+			Provider: comboManifest.CloudProvider.Blobstore.Provider,
+			Options:  blobstoreOptions,
+		}
+	}
+
+	if comboManifest.CloudProvider.HasSSHTunnel() || comboManifest.CloudProvider.HasRegistry() {
+		// a new password every run, so that it is never reused between deploys
 		password, err := p.uuidGenerator.Generate()
 		if err != nil {
 			return Manifest{}, bosherr.WrapError(err, "Generating registry password")
 		}
-		installationManifest.PopulateRegistry("registry", password, "127.0.0.1", 6901, comboManifest.CloudProvider.SSHTunnel)
+
+		registryConfig := comboManifest.CloudProvider.Registry
+		if registryConfig.Host == "" {
+			registryConfig.Host = "127.0.0.1"
+		}
+		if registryConfig.Port == 0 {
+			registryConfig.Port = 6901
+		}
+
+		installationManifest.PopulateRegistry("registry", password, registryConfig.Host, registryConfig.Port, comboManifest.CloudProvider.SSHTunnel)
+		installationManifest.Registry.BindAddress = registryConfig.BindAddress
+
+		if !registryConfig.TLS.IsEmpty() {
+			installationManifest.PopulateRegistryTLS(registryConfig.TLS)
+		}
 	}
 
 	err = p.validator.Validate(installationManifest, releaseSetManifest)
@@ -140,3 +267,20 @@ func (p *parser) Parse(path string, vars boshtpl.Variables, op patch.Op, release
 
 	return installationManifest, nil
 }
+
+// overrideVars returns the variables with the var KVs of the parser taking
+// precedence, and the sources they are looked up in.
+func (p *parser) overrideVars(vars boshtpl.Variables) (boshtpl.Variables, []string) {
+	if len(p.opts.VarKVs) == 0 {
+		return vars, p.opts.VarSources
+	}
+
+	varKVs := boshtpl.StaticVariables{}
+	for name, value := range p.opts.VarKVs {
+		varKVs[name] = value
+	}
+
+	varSources := append([]string{"parser var KVs"}, p.opts.VarSources...)
+
+	return boshtpl.NewMultiVars([]boshtpl.Variables{varKVs, vars}), varSources
+}
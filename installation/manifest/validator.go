@@ -1,6 +1,10 @@
 package manifest
 
 import (
+	"crypto/tls"
+	"net"
+	"net/url"
+	"strconv"
 	"strings"
 
 	birelsetmanifest "github.com/cloudfoundry/bosh-cli/release/set/manifest"
@@ -40,6 +44,28 @@ func (v *validator) Validate(manifest Manifest, releaseSetManifest birelsetmanif
 		errs = append(errs, bosherr.Errorf("cloud_provider.template.release '%s' must refer to a release in releases", cpiReleaseName))
 	}
 
+	if !manifest.Blobstore.IsEmpty() {
+		switch manifest.Blobstore.Provider {
+		case "dav", "s3", "gcs":
+		default:
+			errs = append(errs, bosherr.Errorf("cloud_provider.blobstore.provider must be one of dav, s3 or gcs, got '%s'", manifest.Blobstore.Provider))
+		}
+	} else if strings.HasPrefix(manifest.Mbus, "nats://") {
+		// the agent blobstore is only reachable through its https mbus
+		errs = append(errs, bosherr.Error("cloud_provider.blobstore must be provided when cloud_provider.mbus is a nats:// URL"))
+	}
+
+	if !manifest.Registry.IsEmpty() {
+		errs = append(errs, v.validateRegistry(manifest)...)
+	}
+
+	errs = append(errs, v.validateCPICallPolicy("cloud_provider.cpi", manifest.CPICalls.CPICallPolicy)...)
+	for method, policy := range manifest.CPICalls.Methods {
+		errs = append(errs, v.validateCPICallPolicy("cloud_provider.cpi.methods."+method, policy)...)
+	}
+
+	errs = append(errs, v.validateCPIs(manifest.CPIs, releaseSetManifest)...)
+
 	if len(errs) > 0 {
 		return bosherr.NewMultiError(errs...)
 	}
@@ -47,6 +73,94 @@ func (v *validator) Validate(manifest Manifest, releaseSetManifest birelsetmanif
 	return nil
 }
 
+func (v *validator) validateCPIs(cpis []CPI, releaseSetManifest birelsetmanifest.Manifest) []error {
+	errs := []error{}
+	names := map[string]bool{}
+
+	for i, cpi := range cpis {
+		if v.isBlank(cpi.Name) {
+			errs = append(errs, bosherr.Errorf("cpis[%d].name must be provided", i))
+		} else if names[cpi.Name] {
+			errs = append(errs, bosherr.Errorf("cpis[%d].name '%s' must be unique", i, cpi.Name))
+		}
+		names[cpi.Name] = true
+
+		if v.isBlank(cpi.Template.Name) {
+			errs = append(errs, bosherr.Errorf("cpis[%d].template.name must be provided", i))
+		}
+
+		if v.isBlank(cpi.Template.Release) {
+			errs = append(errs, bosherr.Errorf("cpis[%d].template.release must be provided", i))
+		} else if _, found := releaseSetManifest.FindByName(cpi.Template.Release); !found {
+			errs = append(errs, bosherr.Errorf("cpis[%d].template.release '%s' must refer to a release in releases", i, cpi.Template.Release))
+		}
+	}
+
+	return errs
+}
+
+func (v *validator) validateCPICallPolicy(key string, policy CPICallPolicy) []error {
+	errs := []error{}
+
+	if policy.Timeout < 0 {
+		errs = append(errs, bosherr.Errorf("%s.timeout must not be negative", key))
+	}
+
+	if policy.MaxAttempts < 0 {
+		errs = append(errs, bosherr.Errorf("%s.max_attempts must not be negative", key))
+	}
+
+	if policy.RetryDelay < 0 {
+		errs = append(errs, bosherr.Errorf("%s.retry_delay must not be negative", key))
+	}
+
+	return errs
+}
+
+func (v *validator) validateRegistry(manifest Manifest) []error {
+	errs := []error{}
+	registry := manifest.Registry
+
+	if registry.Port < 1 || registry.Port > 65535 {
+		errs = append(errs, bosherr.Errorf("cloud_provider.registry.port must be between 1 and 65535, got %d", registry.Port))
+	}
+
+	var bindIP net.IP
+	if registry.BindAddress != "" {
+		bindIP = net.ParseIP(registry.BindAddress)
+		if bindIP == nil {
+			errs = append(errs, bosherr.Errorf("cloud_provider.registry.bind_address must be an IP address, got '%s'", registry.BindAddress))
+		}
+	}
+
+	// the SSH tunnel forwards the registry port on the VM to the local loopback interface
+	if registry.SSHTunnel != (SSHTunnel{}) {
+		if bindIP != nil && !bindIP.IsLoopback() && !bindIP.IsUnspecified() {
+			errs = append(errs, bosherr.Error("cloud_provider.registry.bind_address must be a loopback or unspecified address when cloud_provider.ssh_tunnel is configured"))
+		}
+
+		if registry.Port == registry.SSHTunnel.Port {
+			errs = append(errs, bosherr.Errorf("cloud_provider.registry.port %d conflicts with cloud_provider.ssh_tunnel.port", registry.Port))
+		}
+
+		if mbusURL, err := url.Parse(manifest.Mbus); err == nil {
+			_, mbusPort, err := net.SplitHostPort(mbusURL.Host)
+			if err == nil && mbusPort == strconv.Itoa(registry.Port) {
+				errs = append(errs, bosherr.Errorf("cloud_provider.registry.port %d conflicts with the port of cloud_provider.mbus", registry.Port))
+			}
+		}
+	}
+
+	if !registry.TLS.IsEmpty() {
+		_, err := tls.X509KeyPair([]byte(registry.TLS.Certificate), []byte(registry.TLS.PrivateKey))
+		if err != nil {
+			errs = append(errs, bosherr.WrapError(err, "cloud_provider.registry.tls must have a valid certificate and private_key"))
+		}
+	}
+
+	return errs
+}
+
 func (v *validator) isBlank(str string) bool {
 	return str == "" || strings.TrimSpace(str) == ""
 }
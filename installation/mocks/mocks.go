@@ -33,6 +33,16 @@ func (_m *MockInstallation) EXPECT() *_MockInstallationRecorder {
 	return _m.recorder
 }
 
+func (_m *MockInstallation) CPICalls() manifest.CPICalls {
+	ret := _m.ctrl.Call(_m, "CPICalls")
+	ret0, _ := ret[0].(manifest.CPICalls)
+	return ret0
+}
+
+func (_mr *_MockInstallationRecorder) CPICalls() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "CPICalls")
+}
+
 func (_m *MockInstallation) Job() installation.InstalledJob {
 	ret := _m.ctrl.Call(_m, "Job")
 	ret0, _ := ret[0].(installation.InstalledJob)
@@ -30,12 +30,29 @@ func NewReleaseFetcher(
 }
 
 func (f ReleaseFetcher) DownloadAndExtract(releaseRef manifest.ReleaseRef, stage ui.Stage) error {
-	releasePath, err := f.tarballProvider.Get(releaseRef, stage)
+	releasePath, err := f.Download(releaseRef, stage)
 	if err != nil {
 		return err
 	}
 
-	err = stage.Perform(fmt.Sprintf("Validating release '%s'", releaseRef.Name), func() error {
+	return f.Extract(releaseRef, releasePath, stage)
+}
+
+// Download returns the local path of the release tarball, downloading it
+// first when it is not a local file.
+func (f ReleaseFetcher) Download(releaseRef manifest.ReleaseRef, stage ui.Stage) (string, error) {
+	return f.tarballProvider.Get(releaseRef, stage)
+}
+
+// Verify checks the release tarball against the digest of the release.
+func (f ReleaseFetcher) Verify(releaseRef manifest.ReleaseRef, releasePath string) error {
+	return f.tarballProvider.Verify(releaseRef, releasePath)
+}
+
+// Extract reads the release from its tarball and adds it to the release
+// manager.
+func (f ReleaseFetcher) Extract(releaseRef manifest.ReleaseRef, releasePath string, stage ui.Stage) error {
+	return stage.Perform(fmt.Sprintf("Validating release '%s'", releaseRef.Name), func() error {
 		release, err := f.releaseReader.Read(releasePath)
 		if err != nil {
 			return bosherr.WrapErrorf(err, "Extracting release '%s'", releasePath)
@@ -50,6 +67,4 @@ func (f ReleaseFetcher) DownloadAndExtract(releaseRef manifest.ReleaseRef, stage
 
 		return nil
 	})
-
-	return err
 }
@@ -0,0 +1,88 @@
+package installation
+
+import (
+	"github.com/dustin/go-humanize"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// FreeSpaceFunc returns the bytes available to the user in the file system
+// holding path.
+type FreeSpaceFunc func(path string) (uint64, error)
+
+// ScratchSpaceChecker makes sure that the temp file system can hold the
+// release and stemcell tarballs once extracted, before any is extracted.
+type ScratchSpaceChecker interface {
+	Check(tarballPaths []string) error
+}
+
+type scratchSpaceChecker struct {
+	fs        boshsys.FileSystem
+	freeSpace FreeSpaceFunc
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewScratchSpaceChecker(fs boshsys.FileSystem, freeSpace FreeSpaceFunc, logger boshlog.Logger) ScratchSpaceChecker {
+	return scratchSpaceChecker{
+		fs:        fs,
+		freeSpace: freeSpace,
+
+		logTag: "scratchSpaceChecker",
+		logger: logger,
+	}
+}
+
+// Check estimates the space needed by the extracted tarballs as the size of
+// the tarballs, as their contents are mostly compressed archives themselves.
+func (c scratchSpaceChecker) Check(tarballPaths []string) error {
+	var needed uint64
+
+	for _, path := range tarballPaths {
+		// missing tarballs are reported when they are extracted
+		if !c.fs.FileExists(path) {
+			continue
+		}
+
+		stat, err := c.fs.Stat(path)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Checking size of '%s'", path)
+		}
+
+		needed += uint64(stat.Size())
+	}
+
+	// the temp root is only known to the file system. Not being able to tell
+	// the free space should not prevent deploying
+	tmpDir, err := c.fs.TempDir("bosh-scratch-space")
+	if err != nil {
+		c.logger.Warn(c.logTag, "Creating temp directory to check free space: %s", err.Error())
+		return nil
+	}
+
+	defer func() {
+		err := c.fs.RemoveAll(tmpDir)
+		if err != nil {
+			c.logger.Warn(c.logTag, "Removing '%s': %s", tmpDir, err.Error())
+		}
+	}()
+
+	available, err := c.freeSpace(tmpDir)
+	if err != nil {
+		c.logger.Warn(c.logTag, "Checking free space in '%s': %s", tmpDir, err.Error())
+		return nil
+	}
+
+	c.logger.Debug(c.logTag, "Extracting needs about %d bytes, %d bytes are available", needed, available)
+
+	if needed > available {
+		return bosherr.Errorf(
+			"Not enough free space in temp directory '%s' to extract releases and stemcell: about %s needed, %s available. Use --tmp-dir to choose another directory",
+			tmpDir, humanize.IBytes(needed), humanize.IBytes(available))
+	}
+
+	return nil
+}
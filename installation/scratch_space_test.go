@@ -0,0 +1,70 @@
+package installation_test
+
+import (
+	"errors"
+	"path/filepath"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/installation"
+)
+
+var _ = Describe("ScratchSpaceChecker", func() {
+	var (
+		fs           *fakesys.FakeFileSystem
+		freeSpace    uint64
+		freeSpaceErr error
+		checkedPath  string
+		checker      ScratchSpaceChecker
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		fs.TempDirDir = filepath.Join("/", "fake-tmp", "scratch")
+
+		fs.WriteFileString(filepath.Join("/", "release.tgz"), "fake-release")
+		fs.WriteFileString(filepath.Join("/", "stemcell.tgz"), "fake-stemcell")
+
+		freeSpace = 1024
+		freeSpaceErr = nil
+
+		checker = NewScratchSpaceChecker(fs, func(path string) (uint64, error) {
+			checkedPath = path
+			return freeSpace, freeSpaceErr
+		}, boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	tarballPaths := []string{filepath.Join("/", "release.tgz"), filepath.Join("/", "stemcell.tgz")}
+
+	It("checks the free space in the temp root", func() {
+		Expect(checker.Check(tarballPaths)).To(Succeed())
+
+		Expect(checkedPath).To(Equal(filepath.Join("/", "fake-tmp", "scratch")))
+		Expect(fs.FileExists(checkedPath)).To(BeFalse())
+	})
+
+	It("returns an error when the tarballs are larger than the free space", func() {
+		freeSpace = 20
+
+		err := checker.Check(tarballPaths)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Not enough free space in temp directory '" + filepath.Join("/", "fake-tmp", "scratch") +
+			"' to extract releases and stemcell: about 25 B needed, 20 B available. Use --tmp-dir to choose another directory"))
+	})
+
+	It("does not count tarballs that do not exist", func() {
+		freeSpace = 12
+
+		Expect(checker.Check([]string{filepath.Join("/", "release.tgz"), filepath.Join("/", "missing.tgz")})).To(Succeed())
+	})
+
+	It("does not fail when the free space cannot be determined", func() {
+		freeSpace = 0
+		freeSpaceErr = errors.New("fake-free-space-err")
+
+		Expect(checker.Check(tarballPaths)).To(Succeed())
+	})
+})
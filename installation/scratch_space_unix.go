@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package installation
+
+import (
+	"syscall"
+)
+
+// FreeSpace returns the bytes available to the user in the file system
+// holding path.
+func FreeSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+
+	err := syscall.Statfs(path, &stat)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
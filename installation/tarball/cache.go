@@ -15,6 +15,9 @@ import (
 type Cache interface {
 	Get(source Source) (path string, found bool)
 	Path(source Source) (path string)
+	// PartialPath is where a download of the source is kept until it is
+	// complete, so that an interrupted download can be resumed
+	PartialPath(source Source) (path string)
 	Save(sourcePath string, source Source) error
 }
 
@@ -45,7 +48,7 @@ func (c *cache) Get(source Source) (string, bool) {
 }
 
 func (c *cache) Save(sourcePath string, source Source) error {
-	err := c.fs.MkdirAll(c.basePath, os.FileMode(0766))
+	err := c.fs.MkdirAll(c.basePath, os.FileMode(0755))
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Failed to create cache directory '%s'", c.basePath)
 	}
@@ -64,3 +67,7 @@ func (c *cache) Path(source Source) string {
 	filename := fmt.Sprintf("%x-%s", string(urlSHA1[:]), source.GetSHA1())
 	return filepath.Join(c.basePath, filename)
 }
+
+func (c *cache) PartialPath(source Source) string {
+	return c.Path(source) + ".part"
+}
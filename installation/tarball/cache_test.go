@@ -47,6 +47,21 @@ var _ = Describe("Cache", func() {
 		Expect(fs.FileExists(path)).To(BeTrue())
 	})
 
+	It("keeps partial downloads next to the cached tarballs, where they are not a cache hit", func() {
+		source := &fakeSource{
+			sha1:        "fake-sha1",
+			url:         "http://foo.bar.com",
+			description: "some tarball",
+		}
+
+		Expect(cache.PartialPath(source)).To(Equal(cache.Path(source) + ".part"))
+
+		fs.WriteFileString(cache.PartialPath(source), "")
+
+		_, found := cache.Get(source)
+		Expect(found).To(BeFalse())
+	})
+
 	It("is a cache miss when a tarball from a different url has been downloaded, even if SHA1 matches", func() {
 		fs.WriteFileString("source-path", "")
 
@@ -99,6 +114,7 @@ var _ = Describe("Cache", func() {
 			description: "some tarball",
 		})).To(Equal(filepath.Join("/", "fake-base-path", "587cd74a86333e7f1ebca70474a1f4456e4b5d3e-fake-sha1")))
 		Expect(fs.FileExists(filepath.Join("/", "fake-base-path", "587cd74a86333e7f1ebca70474a1f4456e4b5d3e-fake-sha1"))).To(BeTrue())
+		Expect(fs.GetFileTestStat(filepath.Join("/", "fake-base-path")).FileMode).To(Equal(os.FileMode(0755)))
 	})
 
 	It("saves files across devices when necessary", func() {
@@ -40,3 +40,13 @@ func (_m *MockProvider) Get(_param0 tarball.Source, _param1 ui.Stage) (string, e
 func (_mr *_MockProviderRecorder) Get(arg0, arg1 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Get", arg0, arg1)
 }
+
+func (_m *MockProvider) Verify(_param0 tarball.Source, _param1 string) error {
+	ret := _m.ctrl.Call(_m, "Verify", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockProviderRecorder) Verify(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Verify", arg0, arg1)
+}
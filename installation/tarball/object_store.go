@@ -0,0 +1,98 @@
+package tarball
+
+import (
+	gobytes "bytes"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	s3client "github.com/pivotal-golang/s3cli/client"
+	s3config "github.com/pivotal-golang/s3cli/config"
+)
+
+// ObjectStoreClient is the part of the s3cli client used to download
+// tarballs from S3 and GCS buckets.
+type ObjectStoreClient interface {
+	Get(src string, dest io.WriterAt) error
+}
+
+// ObjectStoreClientFactory builds clients for the bucket of a s3:// or gs:// URL.
+type ObjectStoreClientFactory interface {
+	New(scheme, bucket string) (ObjectStoreClient, error)
+}
+
+type objectStoreClientFactory struct {
+	opts DownloadOpts
+}
+
+// NewObjectStoreClientFactory talks to GCS through its S3 interoperable XML
+// API, like the gcs blobstore does, so GCS credentials are HMAC keys.
+// Buckets are read anonymously unless credentials are given, except for S3
+// where the credentials of the AWS environment variables or profile are used.
+func NewObjectStoreClientFactory(opts DownloadOpts) ObjectStoreClientFactory {
+	return objectStoreClientFactory{opts: opts}
+}
+
+func (f objectStoreClientFactory) New(scheme, bucket string) (ObjectStoreClient, error) {
+	options := map[string]string{"bucket_name": bucket}
+
+	switch scheme {
+	case "s3":
+		options["region"] = f.opts.S3Region
+
+		if f.opts.S3AccessKeyID != "" || f.opts.S3SecretAccessKey != "" {
+			options["access_key_id"] = f.opts.S3AccessKeyID
+			options["secret_access_key"] = f.opts.S3SecretAccessKey
+		} else {
+			options["credentials_source"] = "env_or_profile"
+		}
+
+	case "gs":
+		options["host"] = "storage.googleapis.com"
+		options["signature_version"] = "2"
+		options["access_key_id"] = f.opts.GCSAccessKeyID
+		options["secret_access_key"] = f.opts.GCSSecretAccessKey
+
+	default:
+		return nil, bosherr.Errorf("Unknown object store scheme '%s', expected s3 or gs", scheme)
+	}
+
+	optionsBytes, err := json.Marshal(options)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Marshalling %s options", scheme)
+	}
+
+	config, err := s3config.NewFromReader(gobytes.NewBuffer(optionsBytes))
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Reading %s options", scheme)
+	}
+
+	sdk, err := s3client.NewSDK(config)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Building %s client SDK", scheme)
+	}
+
+	client, err := s3client.New(sdk, &config)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Building %s client", scheme)
+	}
+
+	return &client, nil
+}
+
+// parseObjectStoreURL splits a s3://bucket/key or gs://bucket/key URL.
+func parseObjectStoreURL(sourceURL string) (scheme, bucket, key string, err error) {
+	parsedURL, err := url.Parse(sourceURL)
+	if err != nil {
+		return "", "", "", bosherr.WrapErrorf(err, "Parsing URL '%s'", sourceURL)
+	}
+
+	key = strings.TrimPrefix(parsedURL.Path, "/")
+	if parsedURL.Host == "" || key == "" {
+		return "", "", "", bosherr.Errorf("Expected URL '%s' to name a bucket and an object", sourceURL)
+	}
+
+	return parsedURL.Scheme, parsedURL.Host, key, nil
+}
@@ -0,0 +1,40 @@
+package tarball_test
+
+import (
+	. "github.com/cloudfoundry/bosh-cli/installation/tarball"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ObjectStoreClientFactory", func() {
+	Describe("New", func() {
+		It("builds clients for s3 buckets", func() {
+			factory := NewObjectStoreClientFactory(DownloadOpts{
+				S3AccessKeyID:     "fake-access-key-id",
+				S3SecretAccessKey: "fake-secret-access-key",
+				S3Region:          "us-east-1",
+			})
+
+			client, err := factory.New("s3", "fake-bucket")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client).ToNot(BeNil())
+		})
+
+		It("builds clients for gs buckets", func() {
+			factory := NewObjectStoreClientFactory(DownloadOpts{
+				GCSAccessKeyID:     "fake-access-key-id",
+				GCSSecretAccessKey: "fake-secret-access-key",
+			})
+
+			client, err := factory.New("gs", "fake-bucket")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client).ToNot(BeNil())
+		})
+
+		It("returns an error for other schemes", func() {
+			_, err := NewObjectStoreClientFactory(DownloadOpts{}).New("ftp", "fake-bucket")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unknown object store scheme 'ftp', expected s3 or gs"))
+		})
+	})
+})
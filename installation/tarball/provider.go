@@ -5,9 +5,13 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
+	biutil "github.com/cloudfoundry/bosh-cli/common/util"
 	biui "github.com/cloudfoundry/bosh-cli/ui"
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -19,49 +23,115 @@ import (
 
 type Source interface {
 	GetURL() string
+	// GetSHA1 returns the expected digest, either a bare SHA1 or
+	// prefixed with its algorithm, such as 'sha256:'
 	GetSHA1() string
+	// GetSignatureURL returns the location of a detached PGP signature
+	// of the tarball, or an empty string when it is not signed
+	GetSignatureURL() string
+	GetSignaturePublicKey() string
 	Description() string
 }
 
 type Provider interface {
 	Get(Source, biui.Stage) (path string, err error)
+	Verify(source Source, path string) error
 }
 
-var HTTPClient = &http.Client{
-	Transport: &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		Dial: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 0 * time.Second,
-		}).Dial,
-		TLSHandshakeTimeout: 10 * time.Second,
-	},
+// DownloadOpts changes how tarballs referenced by http(s), s3 and gs URLs are downloaded
+type DownloadOpts struct {
+	// ProxyURL is used instead of the proxy configured by the
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+	ProxyURL string
+
+	// RecreateCache downloads tarballs again even when they are cached
+	RecreateCache bool
+
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3Region          string
+
+	// GCSAccessKeyID and GCSSecretAccessKey are HMAC keys
+	GCSAccessKeyID     string
+	GCSSecretAccessKey string
+}
+
+var HTTPClient = NewHTTPClient(DownloadOpts{})
+
+func NewHTTPClient(opts DownloadOpts) *http.Client {
+	proxy := http.ProxyFromEnvironment
+
+	if opts.ProxyURL != "" {
+		proxy = func(*http.Request) (*url.URL, error) {
+			proxyURL, err := url.Parse(opts.ProxyURL)
+			if err != nil {
+				return nil, bosherr.WrapErrorf(err, "Parsing proxy URL '%s'", opts.ProxyURL)
+			}
+			return proxyURL, nil
+		}
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy: proxy,
+			Dial: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 0 * time.Second,
+			}).Dial,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
 }
 
 type provider struct {
-	cache            Cache
-	fs               boshsys.FileSystem
-	httpClient       bihttpclient.HTTPClient
-	downloadAttempts int
-	delayTimeout     time.Duration
-	logger           boshlog.Logger
-	logTag           string
+	cache                    Cache
+	fs                       boshsys.FileSystem
+	httpClient               bihttpclient.HTTPClient
+	objectStoreClientFactory ObjectStoreClientFactory
+	signatureVerifier        SignatureVerifier
+	downloadAttempts         int
+	delayTimeout             time.Duration
+	opts                     DownloadOpts
+	logger                   boshlog.Logger
+	logTag                   string
 }
 
 func NewProvider(
 	cache Cache,
 	fs boshsys.FileSystem,
 	httpClient bihttpclient.HTTPClient,
+	signatureVerifier SignatureVerifier,
 	downloadAttempts int,
 	delayTimeout time.Duration,
 	logger boshlog.Logger,
+) Provider {
+	opts := DownloadOpts{}
+	objectStoreClientFactory := NewObjectStoreClientFactory(opts)
+
+	return NewProviderWithOpts(
+		cache, fs, httpClient, objectStoreClientFactory, signatureVerifier, downloadAttempts, delayTimeout, logger, opts)
+}
+
+func NewProviderWithOpts(
+	cache Cache,
+	fs boshsys.FileSystem,
+	httpClient bihttpclient.HTTPClient,
+	objectStoreClientFactory ObjectStoreClientFactory,
+	signatureVerifier SignatureVerifier,
+	downloadAttempts int,
+	delayTimeout time.Duration,
+	logger boshlog.Logger,
+	opts DownloadOpts,
 ) Provider {
 	return &provider{
-		cache:            cache,
-		fs:               fs,
-		httpClient:       httpClient,
-		downloadAttempts: downloadAttempts,
-		delayTimeout:     delayTimeout,
+		cache:                    cache,
+		fs:                       fs,
+		httpClient:               httpClient,
+		objectStoreClientFactory: objectStoreClientFactory,
+		signatureVerifier:        signatureVerifier,
+		downloadAttempts:         downloadAttempts,
+		delayTimeout:             delayTimeout,
+		opts:                     opts,
 
 		logTag: "tarballProvider",
 		logger: logger,
@@ -78,27 +148,46 @@ func (p *provider) Get(source Source, stage biui.Stage) (string, error) {
 			return filePath, nil
 		}
 
+		err = p.verifySignature(source, expandedPath)
+		if err != nil {
+			return "", err
+		}
+
 		p.logger.Debug(p.logTag, "Using the tarball from file source: '%s'", filePath)
 		return expandedPath, nil
 	}
 
-	if !strings.HasPrefix(source.GetURL(), "http") {
-		return "", bosherr.Errorf("Invalid source URL: '%s', must be either file:// or http(s)://", source.GetURL())
+	if !strings.HasPrefix(source.GetURL(), "http") && !biutil.IsObjectStoreURL(source.GetURL()) {
+		return "", bosherr.Errorf("Invalid source URL: '%s', must be one of file://, http(s)://, s3:// or gs://", source.GetURL())
 	}
 
 	var cachedPath string
 
-	err := stage.Perform(fmt.Sprintf("Downloading %s", source.Description()), func() error {
+	err := stage.PerformWithProgress(fmt.Sprintf("Downloading %s", source.Description()), func(progress biui.Progress) error {
 		var found bool
 
 		cachedPath, found = p.cache.Get(source)
-		if found {
+		if found && !p.opts.RecreateCache {
+			// the signature or its public key may have changed since the
+			// tarball was cached
+			err := p.verifySignature(source, cachedPath)
+			if err != nil {
+				return bosherr.WrapError(err, "Verifying cached tarball")
+			}
+
 			p.logger.Debug(p.logTag, "Using the tarball from cache: '%s'", cachedPath)
 			return biui.NewSkipStageError(bosherr.Error("Already downloaded"), "Found in local cache")
 		}
 
+		if p.opts.RecreateCache {
+			err := p.fs.RemoveAll(p.cache.PartialPath(source))
+			if err != nil {
+				return bosherr.WrapError(err, "Removing partially downloaded tarball")
+			}
+		}
+
 		retryStrategy := boshretry.NewAttemptRetryStrategy(
-			p.downloadAttempts, p.delayTimeout, p.downloadRetryable(source), p.logger)
+			p.downloadAttempts, p.delayTimeout, p.downloadRetryable(source, progress), p.logger)
 
 		err := retryStrategy.Try()
 		if err != nil {
@@ -116,33 +205,47 @@ func (p *provider) Get(source Source, stage biui.Stage) (string, error) {
 	return p.cache.Path(source), nil
 }
 
-func (p *provider) downloadRetryable(source Source) boshretry.Retryable {
-	return boshretry.NewRetryable(func() (bool, error) {
-		downloadedFile, err := p.fs.TempFile("tarballProvider")
-		if err != nil {
-			return true, bosherr.WrapError(err, "Unable to create temporary file")
-		}
+// Verify checks the tarball at path against the digest of its source.
+// Downloaded tarballs are verified by Get, but local tarballs are used as
+// they are. Sources without a digest are not verified.
+func (p *provider) Verify(source Source, path string) error {
+	if source.GetSHA1() == "" {
+		return nil
+	}
 
-		defer func() {
-			if err = p.fs.RemoveAll(downloadedFile.Name()); err != nil {
-				p.logger.Warn(p.logTag, "Failed to remove downloaded file: %s", err.Error())
-			}
-		}()
+	digest, err := boshcrypto.ParseMultipleDigest(source.GetSHA1())
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Parsing digest of %s", source.Description())
+	}
 
-		response, err := p.httpClient.Get(source.GetURL())
+	err = digest.VerifyFilePath(path, p.fs)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Verifying digest of %s", source.Description())
+	}
+
+	return nil
+}
+
+// downloadRetryable downloads into the partial path of the cache, which is
+// only moved into the cache once the digest and signature are verified. A
+// signature that does not match is not retried, as downloading the same
+// tarball again would not change it.
+func (p *provider) downloadRetryable(source Source, progress biui.Progress) boshretry.Retryable {
+	return boshretry.NewRetryable(func() (bool, error) {
+		partialPath := p.cache.PartialPath(source)
+
+		err := p.fs.MkdirAll(filepath.Dir(partialPath), os.FileMode(0755))
 		if err != nil {
-			return true, bosherr.WrapError(err, "Unable to download")
+			return true, bosherr.WrapError(err, "Creating download directory")
 		}
 
-		defer func() {
-			if err = response.Body.Close(); err != nil {
-				p.logger.Warn(p.logTag, "Failed to close download response body: %s", err.Error())
-			}
-		}()
-
-		_, err = io.Copy(downloadedFile, response.Body)
+		if biutil.IsObjectStoreURL(source.GetURL()) {
+			err = p.downloadObject(source.GetURL(), partialPath, progress)
+		} else {
+			err = p.downloadHTTP(source.GetURL(), partialPath, progress)
+		}
 		if err != nil {
-			return true, bosherr.WrapError(err, "Saving downloaded bits to temporary file")
+			return true, err
 		}
 
 		digest, err := boshcrypto.ParseMultipleDigest(source.GetSHA1())
@@ -150,16 +253,229 @@ func (p *provider) downloadRetryable(source Source) boshretry.Retryable {
 			return true, err
 		}
 
-		err = digest.VerifyFilePath(downloadedFile.Name(), p.fs)
+		err = digest.VerifyFilePath(partialPath, p.fs)
 		if err != nil {
+			p.removePartial(partialPath)
 			return true, bosherr.WrapError(err, "Verifying digest for downloaded file")
 		}
 
-		err = p.cache.Save(downloadedFile.Name(), source)
+		signaturePath, removeSignature, err := p.fetchSignature(source)
+		if err != nil {
+			p.removePartial(partialPath)
+			return true, err
+		}
+
+		if signaturePath != "" {
+			err = p.signatureVerifier.Verify(partialPath, signaturePath, source.GetSignaturePublicKey())
+			removeSignature()
+			if err != nil {
+				p.removePartial(partialPath)
+				return false, err
+			}
+		}
+
+		err = p.cache.Save(partialPath, source)
 		if err != nil {
+			p.removePartial(partialPath)
 			return true, bosherr.WrapError(err, "Saving downloaded file in cache")
 		}
 
 		return false, nil
 	})
 }
+
+// downloadHTTP keeps what was received when a download breaks off, so that
+// the next attempt, or the next run, asks only for the rest of the tarball.
+func (p *provider) downloadHTTP(sourceURL, partialPath string, progress biui.Progress) error {
+	var offset int64
+	if p.fs.FileExists(partialPath) {
+		stat, err := p.fs.Stat(partialPath)
+		if err != nil {
+			return bosherr.WrapError(err, "Checking partial download")
+		}
+		offset = stat.Size()
+	}
+
+	response, err := p.httpClient.GetCustomized(sourceURL, func(request *http.Request) {
+		if offset > 0 {
+			request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+	})
+	if err != nil {
+		return bosherr.WrapError(err, "Unable to download")
+	}
+
+	defer func() {
+		if err = response.Body.Close(); err != nil {
+			p.logger.Warn(p.logTag, "Failed to close download response body: %s", err.Error())
+		}
+	}()
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+
+	switch {
+	case offset > 0 && response.StatusCode == http.StatusPartialContent:
+		p.logger.Debug(p.logTag, "Resuming download of '%s' at byte %d", sourceURL, offset)
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+
+	case response.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		p.removePartial(partialPath)
+		return bosherr.Errorf("Unable to resume download at byte %d", offset)
+
+	case response.StatusCode < 200 || response.StatusCode >= 300:
+		return bosherr.Errorf("Unable to download: server responded with status %d", response.StatusCode)
+
+	default:
+		// the server sends the whole tarball when it does not support ranges
+		offset = 0
+	}
+
+	// the length is -1 when unknown, which reports no progress
+	if response.ContentLength >= 0 {
+		progress.SetTotal(offset + response.ContentLength)
+	} else {
+		progress.SetTotal(-1)
+	}
+	progress.Add(offset)
+
+	partialFile, err := p.fs.OpenFile(partialPath, flags, os.FileMode(0644))
+	if err != nil {
+		return bosherr.WrapError(err, "Opening partial download")
+	}
+
+	_, err = io.Copy(io.MultiWriter(partialFile, biui.NewProgressWriter(progress)), response.Body)
+	if closeErr := partialFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return bosherr.WrapError(err, "Saving downloaded bits to partial download")
+	}
+
+	return nil
+}
+
+// downloadObject downloads a whole object from a S3 or GCS bucket; object
+// store downloads are not resumed.
+func (p *provider) downloadObject(sourceURL, partialPath string, progress biui.Progress) error {
+	scheme, bucket, key, err := parseObjectStoreURL(sourceURL)
+	if err != nil {
+		return err
+	}
+
+	client, err := p.objectStoreClientFactory.New(scheme, bucket)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Building client for bucket '%s'", bucket)
+	}
+
+	partialFile, err := p.fs.OpenFile(partialPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(0644))
+	if err != nil {
+		return bosherr.WrapError(err, "Opening partial download")
+	}
+
+	progress.SetTotal(-1)
+
+	err = client.Get(key, progressWriterAt{writerAt: partialFile, progress: progress})
+	if closeErr := partialFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Unable to download object '%s' from bucket '%s'", key, bucket)
+	}
+
+	return nil
+}
+
+type progressWriterAt struct {
+	writerAt io.WriterAt
+	progress biui.Progress
+}
+
+func (w progressWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	n, err := w.writerAt.WriteAt(p, off)
+	w.progress.Add(int64(n))
+	return n, err
+}
+
+func (p *provider) removePartial(partialPath string) {
+	if err := p.fs.RemoveAll(partialPath); err != nil {
+		p.logger.Warn(p.logTag, "Failed to remove partial download: %s", err.Error())
+	}
+}
+
+// verifySignature checks the detached signature of the source, if it has one,
+// so that a tarball is never cached or extracted before it is verified.
+func (p *provider) verifySignature(source Source, tarballPath string) error {
+	signaturePath, removeSignature, err := p.fetchSignature(source)
+	if err != nil {
+		return err
+	}
+
+	if signaturePath == "" {
+		return nil
+	}
+
+	defer removeSignature()
+
+	return p.signatureVerifier.Verify(tarballPath, signaturePath, source.GetSignaturePublicKey())
+}
+
+// fetchSignature returns the path of the detached signature of the source,
+// downloading it into a temporary file removed by the returned func, or an
+// empty path when the source is not signed.
+func (p *provider) fetchSignature(source Source) (string, func(), error) {
+	signatureURL := source.GetSignatureURL()
+	if signatureURL == "" {
+		return "", func() {}, nil
+	}
+
+	if strings.HasPrefix(signatureURL, "file://") {
+		signaturePath, err := p.fs.ExpandPath(strings.TrimPrefix(signatureURL, "file://"))
+		if err != nil {
+			return "", nil, bosherr.WrapErrorf(err, "Expanding signature path '%s'", signatureURL)
+		}
+
+		return signaturePath, func() {}, nil
+	}
+
+	signatureFile, err := p.fs.TempFile("tarballProvider-signature")
+	if err != nil {
+		return "", nil, bosherr.WrapError(err, "Unable to create temporary signature file")
+	}
+
+	removeSignature := func() {
+		if err := p.fs.RemoveAll(signatureFile.Name()); err != nil {
+			p.logger.Warn(p.logTag, "Failed to remove downloaded signature file: %s", err.Error())
+		}
+	}
+
+	err = p.downloadSignature(signatureURL, signatureFile)
+	if closeErr := signatureFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		removeSignature()
+		return "", nil, err
+	}
+
+	return signatureFile.Name(), removeSignature, nil
+}
+
+func (p *provider) downloadSignature(signatureURL string, signatureFile boshsys.File) error {
+	response, err := p.httpClient.Get(signatureURL)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Unable to download signature from '%s'", signatureURL)
+	}
+
+	defer func() {
+		if err = response.Body.Close(); err != nil {
+			p.logger.Warn(p.logTag, "Failed to close signature response body: %s", err.Error())
+		}
+	}()
+
+	_, err = io.Copy(signatureFile, response.Body)
+	if err != nil {
+		return bosherr.WrapError(err, "Saving downloaded signature to temporary file")
+	}
+
+	return nil
+}
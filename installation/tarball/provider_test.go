@@ -2,25 +2,62 @@ package tarball_test
 
 import (
 	"errors"
+	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	. "github.com/cloudfoundry/bosh-cli/installation/tarball"
 	fakebiui "github.com/cloudfoundry/bosh-cli/ui/fakes"
+	bihttpclient "github.com/cloudfoundry/bosh-utils/httpclient"
 	fakebihttpclient "github.com/cloudfoundry/bosh-utils/httpclient/fakes"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
+var _ = Describe("NewHTTPClient", func() {
+	It("uses the proxy from the environment by default", func() {
+		transport := NewHTTPClient(DownloadOpts{}).Transport.(*http.Transport)
+		Expect(transport.Proxy).ToNot(BeNil())
+	})
+
+	It("uses the given proxy for every request", func() {
+		transport := NewHTTPClient(DownloadOpts{ProxyURL: "http://fake-proxy:3128"}).Transport.(*http.Transport)
+
+		request, err := http.NewRequest("GET", "https://fake-url", nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		proxyURL, err := transport.Proxy(request)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(proxyURL.String()).To(Equal("http://fake-proxy:3128"))
+	})
+
+	It("returns an error for requests when the proxy cannot be parsed", func() {
+		transport := NewHTTPClient(DownloadOpts{ProxyURL: "://fake-proxy"}).Transport.(*http.Transport)
+
+		request, err := http.NewRequest("GET", "https://fake-url", nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = transport.Proxy(request)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Parsing proxy URL '://fake-proxy'"))
+	})
+})
+
 var _ = Describe("Provider", func() {
 	var (
 		provider   Provider
 		cache      Cache
 		fs         *fakesys.FakeFileSystem
 		httpClient *fakebihttpclient.FakeHTTPClient
+		cmdRunner  *fakesys.FakeCmdRunner
 		source     *fakeSource
 		fakeStage  *fakebiui.FakeStage
 	)
@@ -30,7 +67,10 @@ var _ = Describe("Provider", func() {
 		logger := boshlog.NewLogger(boshlog.LevelNone)
 		cache = NewCache(filepath.Join("/", "fake-base-path"), fs, logger)
 		httpClient = fakebihttpclient.NewFakeHTTPClient()
-		provider = NewProvider(cache, fs, httpClient, 3, 0, logger)
+		cmdRunner = fakesys.NewFakeCmdRunner()
+		fs.TempDirDir = "/fake-gpg-home"
+		signatureVerifier := NewGPGSignatureVerifier(cmdRunner, fs, logger)
+		provider = NewProvider(cache, fs, httpClient, signatureVerifier, 3, 0, logger)
 		fakeStage = fakebiui.NewFakeStage()
 	})
 
@@ -46,6 +86,32 @@ var _ = Describe("Provider", func() {
 				path, err := provider.Get(source, fakeStage)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(path).To(Equal("expanded-file-path"))
+				Expect(cmdRunner.RunCommands).To(BeEmpty())
+			})
+
+			Context("when the source has a signature", func() {
+				BeforeEach(func() {
+					source.signatureURL = "file://fake-signature"
+					source.signaturePublicKey = "fake-public-key"
+				})
+
+				It("verifies the signature of the file", func() {
+					_, err := provider.Get(source, fakeStage)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(cmdRunner.RunCommands).To(ContainElement(
+						[]string{"gpg", "--batch", "--homedir", "/fake-gpg-home", "--verify", "expanded-file-path", "expanded-file-path"},
+					))
+				})
+
+				It("returns an error when the signature does not match", func() {
+					cmdRunner.AddCmdResult("gpg --batch --homedir /fake-gpg-home --verify expanded-file-path expanded-file-path", fakesys.FakeCmdResult{
+						Error: errors.New("fake-verify-error"),
+					})
+
+					_, err := provider.Get(source, fakeStage)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-verify-error"))
+				})
 			})
 		})
 
@@ -73,22 +139,48 @@ var _ = Describe("Provider", func() {
 					Expect(fakeStage.PerformCalls[0].Name).To(Equal("Downloading fake-description"))
 					Expect(fakeStage.PerformCalls[0].SkipError.Error()).To(Equal("Found in local cache: Already downloaded"))
 				})
+
+				Context("when the source has a signature", func() {
+					var cachedPath string
+
+					BeforeEach(func() {
+						source.signatureURL = "file://fake-signature"
+						source.signaturePublicKey = "fake-public-key"
+						fs.ExpandPathExpanded = "expanded-signature-path"
+						cachedPath, _ = cache.Get(source)
+					})
+
+					It("verifies the cached tarball with it", func() {
+						_, err := provider.Get(source, fakeStage)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(cmdRunner.RunCommands).To(ContainElement(
+							[]string{"gpg", "--batch", "--homedir", "/fake-gpg-home", "--verify", "expanded-signature-path", cachedPath},
+						))
+						Expect(httpClient.GetInputs).To(BeEmpty())
+					})
+
+					It("returns an error without downloading when the signature does not match", func() {
+						cmdRunner.AddCmdResult("gpg --batch --homedir /fake-gpg-home --verify expanded-signature-path "+cachedPath, fakesys.FakeCmdResult{
+							Error: errors.New("fake-verify-error"),
+						})
+
+						_, err := provider.Get(source, fakeStage)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("Verifying cached tarball"))
+						Expect(err.Error()).To(ContainSubstring("fake-verify-error"))
+
+						Expect(httpClient.GetInputs).To(BeEmpty())
+					})
+				})
 			})
 
 			Context("when tarball is not present in cache", func() {
-				var (
-					tempDownloadFilePath string
-				)
+				var partialPath string
 
 				BeforeEach(func() {
-					tempDownloadFile, err := ioutil.TempFile("", "temp-download-file")
-					Expect(err).ToNot(HaveOccurred())
-					fs.ReturnTempFile = tempDownloadFile
-					tempDownloadFilePath = tempDownloadFile.Name()
-				})
-
-				AfterEach(func() {
-					os.RemoveAll(tempDownloadFilePath)
+					source = newFakeSource("http://fake-url", "fab3c263ec568e150550b814e84b7898d477c3c2", "fake-description")
+					partialPath = cache.PartialPath(source)
 				})
 
 				Context("when downloading succeds", func() {
@@ -101,30 +193,50 @@ var _ = Describe("Provider", func() {
 					It("downloads tarball from given URL and returns saved cache tarball path", func() {
 						path, err := provider.Get(source, fakeStage)
 						Expect(err).ToNot(HaveOccurred())
-						Expect(path).To(Equal(filepath.Join("/", "fake-base-path", "9db1fb7c47637e8709e944a232e1aa98ce6fec26-da39a3ee5e6b4b0d3255bfef95601890afd80709")))
+						Expect(path).To(Equal(filepath.Join("/", "fake-base-path", "9db1fb7c47637e8709e944a232e1aa98ce6fec26-fab3c263ec568e150550b814e84b7898d477c3c2")))
+						Expect(fs.ReadFileString(path)).To(Equal("fake-body"))
 
 						Expect(httpClient.GetInputs).To(HaveLen(1))
 						Expect(httpClient.GetInputs[0].Endpoint).To(Equal("http://fake-url"))
 					})
 
+					It("moves the partial download into the cache", func() {
+						_, err := provider.Get(source, fakeStage)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(fs.FileExists(partialPath)).To(BeFalse())
+					})
+
 					It("logs downloading stage", func() {
 						_, err := provider.Get(source, fakeStage)
 						Expect(err).ToNot(HaveOccurred())
 
 						Expect(fakeStage.PerformCalls).To(Equal([]*fakebiui.PerformCall{
-							{Name: "Downloading fake-description"},
+							{Name: "Downloading fake-description", Progress: &fakebiui.FakeProgress{Total: 0, Done: int64(len("fake-body"))}},
 						}))
 					})
 
+					Context("when a sha256 digest is given", func() {
+						BeforeEach(func() {
+							source = newFakeSource("http://fake-url", "sha256:7f2a9d1f9b4b5d8e96a4e6b5bdd8d7c0b1c0b4b6c3e2c1d6a54c1e2d3f4a5b6c", "fake-description")
+						})
+
+						It("verifies the downloaded file against it", func() {
+							_, err := provider.Get(source, fakeStage)
+							Expect(err).To(HaveOccurred())
+							Expect(err.Error()).To(ContainSubstring("Expected stream to have digest 'sha256:7f2a9d1f9b4b5d8e96a4e6b5bdd8d7c0b1c0b4b6c3e2c1d6a54c1e2d3f4a5b6c'"))
+						})
+					})
+
 					Context("when sha1 does not match", func() {
 						BeforeEach(func() {
 							source = newFakeSource("http://fake-url", "expectedsha1", "fake-description")
+							partialPath = cache.PartialPath(source)
 						})
 
 						It("returns an error", func() {
 							_, err := provider.Get(source, fakeStage)
 							Expect(err).To(HaveOccurred())
-							Expect(err.Error()).To(ContainSubstring("Failed to download from 'http://fake-url': Verifying digest for downloaded file: Expected stream to have digest 'expectedsha1' but was 'da39a3ee5e6b4b0d3255bfef95601890afd80709'"))
+							Expect(err.Error()).To(ContainSubstring("Failed to download from 'http://fake-url': Verifying digest for downloaded file: Expected stream to have digest 'expectedsha1'"))
 						})
 
 						It("retries downloading up to 3 times", func() {
@@ -134,16 +246,15 @@ var _ = Describe("Provider", func() {
 							Expect(httpClient.GetInputs).To(HaveLen(3))
 						})
 
-						It("removes the downloaded file", func() {
+						It("removes the partial download so that the next attempt starts over", func() {
 							_, err := provider.Get(source, fakeStage)
 							Expect(err).To(HaveOccurred())
-							Expect(fs.FileExists(tempDownloadFilePath)).To(BeFalse())
+							Expect(fs.FileExists(partialPath)).To(BeFalse())
 						})
 					})
 
-					Context("when saving to cache fails", func() {
+					Context("when the download directory cannot be created", func() {
 						BeforeEach(func() {
-							// Creating cache base directory fails
 							fs.MkdirAllError = errors.New("fake-mkdir-error")
 						})
 
@@ -152,12 +263,137 @@ var _ = Describe("Provider", func() {
 							Expect(err).To(HaveOccurred())
 							Expect(err.Error()).To(ContainSubstring("fake-mkdir-error"))
 						})
+					})
 
-						It("removes the downloaded file", func() {
+					Context("when saving to cache fails", func() {
+						BeforeEach(func() {
+							provider = newSingleAttemptProvider(cache, fs, httpClient, cmdRunner)
+							fs.RenameError = errors.New("fake-rename-error")
+							fs.CopyFileError = errors.New("fake-copy-error")
+						})
+
+						It("returns an error", func() {
 							_, err := provider.Get(source, fakeStage)
 							Expect(err).To(HaveOccurred())
-							Expect(fs.FileExists(tempDownloadFilePath)).To(BeFalse())
+							Expect(err.Error()).To(ContainSubstring("Saving downloaded file in cache"))
+						})
+
+						It("removes the partial download", func() {
+							_, err := provider.Get(source, fakeStage)
+							Expect(err).To(HaveOccurred())
+							Expect(fs.FileExists(partialPath)).To(BeFalse())
+						})
+					})
+
+					Context("when a partial download exists but the server sends the whole tarball", func() {
+						BeforeEach(func() {
+							fs.WriteFileString(partialPath, "stale")
+						})
+
+						It("replaces the partial download", func() {
+							path, err := provider.Get(source, fakeStage)
+							Expect(err).ToNot(HaveOccurred())
+							Expect(fs.ReadFileString(path)).To(Equal("fake-body"))
+						})
+					})
+				})
+
+				Context("when the partial download cannot be resumed", func() {
+					BeforeEach(func() {
+						fs.WriteFileString(partialPath, "stale-and-too-long")
+						httpClient.SetGetBehavior("", 416, nil)
+						httpClient.SetGetBehavior("fake-body", 200, nil)
+					})
+
+					It("removes the partial download and starts over", func() {
+						path, err := provider.Get(source, fakeStage)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(fs.ReadFileString(path)).To(Equal("fake-body"))
+
+						Expect(httpClient.GetInputs).To(HaveLen(2))
+					})
+				})
+
+				Context("when the server responds with an error status", func() {
+					BeforeEach(func() {
+						httpClient.SetGetBehavior("fake-error-page", 500, nil)
+						httpClient.SetGetBehavior("fake-error-page", 500, nil)
+						httpClient.SetGetBehavior("fake-error-page", 500, nil)
+					})
+
+					It("returns an error without keeping the response", func() {
+						_, err := provider.Get(source, fakeStage)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("Unable to download: server responded with status 500"))
+						Expect(fs.FileExists(partialPath)).To(BeFalse())
+					})
+				})
+
+				Context("when the source has a signature", func() {
+					var tempSignatureFilePath string
+
+					BeforeEach(func() {
+						source.signatureURL = "http://fake-signature-url"
+						source.signaturePublicKey = "fake-public-key"
+						provider = newSingleAttemptProvider(cache, fs, httpClient, cmdRunner)
+
+						tempSignatureFile, err := ioutil.TempFile("", "temp-signature-file")
+						Expect(err).ToNot(HaveOccurred())
+						tempSignatureFilePath = tempSignatureFile.Name()
+
+						fs.ReturnTempFilesByPrefix = map[string]boshsys.File{
+							"tarballProvider-signature": tempSignatureFile,
+						}
+
+						httpClient.SetGetBehavior("fake-body", 200, nil)
+						httpClient.SetGetBehavior("fake-signature", 200, nil)
+					})
+
+					AfterEach(func() {
+						os.RemoveAll(tempSignatureFilePath)
+					})
+
+					It("downloads the signature and verifies the downloaded file with it before caching it", func() {
+						path, err := provider.Get(source, fakeStage)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(fs.FileExists(path)).To(BeTrue())
+
+						Expect(httpClient.GetInputs).To(HaveLen(2))
+						Expect(httpClient.GetInputs[1].Endpoint).To(Equal("http://fake-signature-url"))
+
+						Expect(cmdRunner.RunCommands).To(ContainElement(
+							[]string{"gpg", "--batch", "--homedir", "/fake-gpg-home", "--verify", tempSignatureFilePath, partialPath},
+						))
+						Expect(fs.FileExists(tempSignatureFilePath)).To(BeFalse())
+					})
+
+					It("does not cache the tarball when the signature does not match", func() {
+						cmdRunner.AddCmdResult("gpg --batch --homedir /fake-gpg-home --verify "+tempSignatureFilePath+" "+partialPath, fakesys.FakeCmdResult{
+							Error:  errors.New("fake-verify-error"),
+							Sticky: true,
+						})
+
+						_, err := provider.Get(source, fakeStage)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("fake-verify-error"))
+
+						_, found := cache.Get(source)
+						Expect(found).To(BeFalse())
+						Expect(fs.FileExists(partialPath)).To(BeFalse())
+					})
+
+					It("does not retry downloading when the signature does not match", func() {
+						logger := boshlog.NewLogger(boshlog.LevelNone)
+						provider = NewProvider(cache, fs, httpClient, NewGPGSignatureVerifier(cmdRunner, fs, logger), 3, 0, logger)
+						cmdRunner.AddCmdResult("gpg --batch --homedir /fake-gpg-home --verify "+tempSignatureFilePath+" "+partialPath, fakesys.FakeCmdResult{
+							Error:  errors.New("fake-verify-error"),
+							Sticky: true,
 						})
+
+						_, err := provider.Get(source, fakeStage)
+						Expect(err).To(HaveOccurred())
+
+						Expect(httpClient.GetInputs).To(HaveLen(2))
 					})
 				})
 
@@ -175,17 +411,186 @@ var _ = Describe("Provider", func() {
 
 						Expect(httpClient.GetInputs).To(HaveLen(3))
 					})
+				})
+			})
 
-					It("removes the downloaded file", func() {
-						_, err := provider.Get(source, fakeStage)
-						Expect(err).To(HaveOccurred())
-						Expect(fs.FileExists(tempDownloadFilePath)).To(BeFalse())
-					})
+			Context("when the cache is recreated", func() {
+				BeforeEach(func() {
+					source = newFakeSource("http://fake-url", "fab3c263ec568e150550b814e84b7898d477c3c2", "fake-description")
+
+					fs.WriteFileString("fake-source-path", "fake-cached-body")
+					cache.Save("fake-source-path", source)
+
+					logger := boshlog.NewLogger(boshlog.LevelNone)
+					signatureVerifier := NewGPGSignatureVerifier(cmdRunner, fs, logger)
+					provider = NewProviderWithOpts(
+						cache, fs, httpClient, newFakeObjectStoreClientFactory(), signatureVerifier, 3, 0, logger, DownloadOpts{RecreateCache: true})
+
+					httpClient.SetGetBehavior("fake-body", 200, nil)
+				})
+
+				It("downloads the tarball again and replaces the cached one", func() {
+					path, err := provider.Get(source, fakeStage)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(fs.ReadFileString(path)).To(Equal("fake-body"))
+
+					Expect(httpClient.GetInputs).To(HaveLen(1))
+					Expect(fakeStage.PerformCalls[0].SkipError).To(BeNil())
+				})
+			})
+
+			Context("when resuming a partial download from a server supporting ranges", func() {
+				var (
+					server       *httptest.Server
+					rangeHeaders []string
+					tempDir      string
+					osCache      Cache
+				)
+
+				BeforeEach(func() {
+					rangeHeaders = nil
+					server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						rangeHeaders = append(rangeHeaders, r.Header.Get("Range"))
+						http.ServeContent(w, r, "", time.Time{}, strings.NewReader("fake-body"))
+					}))
+
+					var err error
+					tempDir, err = ioutil.TempDir("", "tarball-provider")
+					Expect(err).ToNot(HaveOccurred())
+
+					logger := boshlog.NewLogger(boshlog.LevelNone)
+					osFs := boshsys.NewOsFileSystem(logger)
+					osCache = NewCache(tempDir, osFs, logger)
+					signatureVerifier := NewGPGSignatureVerifier(cmdRunner, osFs, logger)
+					provider = NewProvider(osCache, osFs, bihttpclient.NewHTTPClient(http.DefaultClient, logger), signatureVerifier, 3, 0, logger)
+
+					source = newFakeSource(server.URL, "fab3c263ec568e150550b814e84b7898d477c3c2", "fake-description")
+
+					err = ioutil.WriteFile(osCache.PartialPath(source), []byte("fake-"), 0644)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				AfterEach(func() {
+					server.Close()
+					os.RemoveAll(tempDir)
+				})
+
+				It("asks only for the rest of the tarball and appends it", func() {
+					path, err := provider.Get(source, fakeStage)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(rangeHeaders).To(Equal([]string{"bytes=5-"}))
+
+					contents, err := ioutil.ReadFile(path)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(string(contents)).To(Equal("fake-body"))
+				})
+
+				It("reports the progress of the whole tarball", func() {
+					_, err := provider.Get(source, fakeStage)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(fakeStage.PerformCalls[0].Progress).To(Equal(&fakebiui.FakeProgress{Total: 9, Done: 9}))
 				})
 			})
 		})
 
-		Context("when URL does not start with either file:// or http(s)://", func() {
+		Context("when URL starts with s3:// or gs://", func() {
+			var (
+				clientFactory *fakeObjectStoreClientFactory
+				partialPath   string
+			)
+
+			BeforeEach(func() {
+				clientFactory = newFakeObjectStoreClientFactory()
+
+				logger := boshlog.NewLogger(boshlog.LevelNone)
+				signatureVerifier := NewGPGSignatureVerifier(cmdRunner, fs, logger)
+				provider = NewProviderWithOpts(cache, fs, httpClient, clientFactory, signatureVerifier, 3, 0, logger, DownloadOpts{})
+
+				// the fake file system ignores WriteAt, so the downloaded tarball is empty
+				source = newFakeSource("s3://fake-bucket/path/fake-release.tgz", "da39a3ee5e6b4b0d3255bfef95601890afd80709", "fake-description")
+				partialPath = cache.PartialPath(source)
+			})
+
+			It("downloads the object from the bucket and returns saved cache tarball path", func() {
+				path, err := provider.Get(source, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(path).To(Equal(cache.Path(source)))
+				Expect(fs.FileExists(path)).To(BeTrue())
+				Expect(fs.FileExists(partialPath)).To(BeFalse())
+
+				Expect(clientFactory.NewInputs).To(Equal([][]string{{"s3", "fake-bucket"}}))
+				Expect(clientFactory.client.GetInputs).To(Equal([]string{"path/fake-release.tgz"}))
+				Expect(httpClient.GetInputs).To(BeEmpty())
+			})
+
+			It("builds a gs client for gs:// URLs", func() {
+				source = newFakeSource("gs://fake-bucket/fake-stemcell.tgz", "da39a3ee5e6b4b0d3255bfef95601890afd80709", "fake-description")
+
+				_, err := provider.Get(source, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(clientFactory.NewInputs).To(Equal([][]string{{"gs", "fake-bucket"}}))
+				Expect(clientFactory.client.GetInputs).To(Equal([]string{"fake-stemcell.tgz"}))
+			})
+
+			It("reports the downloaded bytes as progress", func() {
+				_, err := provider.Get(source, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeStage.PerformCalls[0].Progress).To(Equal(&fakebiui.FakeProgress{Total: -1, Done: int64(len("fake-body"))}))
+			})
+
+			It("returns an error when the URL does not name an object", func() {
+				source = newFakeSource("s3://fake-bucket", "da39a3ee5e6b4b0d3255bfef95601890afd80709", "fake-description")
+
+				_, err := provider.Get(source, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Expected URL 's3://fake-bucket' to name a bucket and an object"))
+			})
+
+			It("returns an error when the client cannot be built", func() {
+				clientFactory.NewErr = errors.New("fake-new-err")
+
+				_, err := provider.Get(source, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Building client for bucket 'fake-bucket': fake-new-err"))
+			})
+
+			It("retries downloading up to 3 times", func() {
+				clientFactory.client.GetErr = errors.New("fake-get-err")
+
+				_, err := provider.Get(source, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Unable to download object 'path/fake-release.tgz' from bucket 'fake-bucket': fake-get-err"))
+
+				Expect(clientFactory.client.GetInputs).To(HaveLen(3))
+			})
+
+			It("saves the downloaded object", func() {
+				tempDir, err := ioutil.TempDir("", "tarball-provider")
+				Expect(err).ToNot(HaveOccurred())
+				defer os.RemoveAll(tempDir)
+
+				logger := boshlog.NewLogger(boshlog.LevelNone)
+				osFs := boshsys.NewOsFileSystem(logger)
+				osCache := NewCache(tempDir, osFs, logger)
+				provider = NewProviderWithOpts(
+					osCache, osFs, httpClient, clientFactory, NewGPGSignatureVerifier(cmdRunner, osFs, logger), 3, 0, logger, DownloadOpts{})
+
+				source = newFakeSource("s3://fake-bucket/path/fake-release.tgz", "fab3c263ec568e150550b814e84b7898d477c3c2", "fake-description")
+
+				path, err := provider.Get(source, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+
+				contents, err := ioutil.ReadFile(path)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(contents)).To(Equal("fake-body"))
+			})
+		})
+
+		Context("when URL does not start with file://, http(s)://, s3:// or gs://", func() {
 			BeforeEach(func() {
 				source = newFakeSource("invalid-url", "fake-sha1", "fake-description")
 			})
@@ -197,18 +602,88 @@ var _ = Describe("Provider", func() {
 			})
 		})
 	})
+
+	Describe("Verify", func() {
+		BeforeEach(func() {
+			fs.WriteFileString("fake-tarball-path", "fake-contents")
+		})
+
+		It("verifies the tarball against the digest of the source", func() {
+			source = newFakeSource("file://fake-file", "978ad524a02039f261773fe93d94973ae7de6470", "fake-description")
+
+			Expect(provider.Verify(source, "fake-tarball-path")).To(Succeed())
+		})
+
+		It("returns an error when the tarball does not match the digest", func() {
+			source = newFakeSource("file://fake-file", "0000000000000000000000000000000000000000", "fake-description")
+
+			err := provider.Verify(source, "fake-tarball-path")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Verifying digest of fake-description"))
+		})
+
+		It("does not verify sources without a digest", func() {
+			source = newFakeSource("file://fake-file", "", "fake-description")
+
+			Expect(provider.Verify(source, "fake-missing-path")).To(Succeed())
+		})
+	})
 })
 
+// newSingleAttemptProvider avoids retries in examples whose failures come after
+// the download, as the fake file system does not support reading a file twice
+func newSingleAttemptProvider(cache Cache, fs *fakesys.FakeFileSystem, httpClient *fakebihttpclient.FakeHTTPClient, cmdRunner *fakesys.FakeCmdRunner) Provider {
+	logger := boshlog.NewLogger(boshlog.LevelNone)
+	return NewProvider(cache, fs, httpClient, NewGPGSignatureVerifier(cmdRunner, fs, logger), 1, 0, logger)
+}
+
+type fakeObjectStoreClientFactory struct {
+	NewInputs [][]string
+	NewErr    error
+
+	client *fakeObjectStoreClient
+}
+
+func newFakeObjectStoreClientFactory() *fakeObjectStoreClientFactory {
+	return &fakeObjectStoreClientFactory{client: &fakeObjectStoreClient{}}
+}
+
+func (f *fakeObjectStoreClientFactory) New(scheme, bucket string) (ObjectStoreClient, error) {
+	f.NewInputs = append(f.NewInputs, []string{scheme, bucket})
+	if f.NewErr != nil {
+		return nil, f.NewErr
+	}
+	return f.client, nil
+}
+
+type fakeObjectStoreClient struct {
+	GetInputs []string
+	GetErr    error
+}
+
+func (c *fakeObjectStoreClient) Get(src string, dest io.WriterAt) error {
+	c.GetInputs = append(c.GetInputs, src)
+	if c.GetErr != nil {
+		return c.GetErr
+	}
+	_, err := dest.WriteAt([]byte("fake-body"), 0)
+	return err
+}
+
 type fakeSource struct {
-	url         string
-	sha1        string
-	description string
+	url                string
+	sha1               string
+	signatureURL       string
+	signaturePublicKey string
+	description        string
 }
 
 func newFakeSource(url, sha1, description string) *fakeSource {
-	return &fakeSource{url, sha1, description}
+	return &fakeSource{url: url, sha1: sha1, description: description}
 }
 
-func (s *fakeSource) GetURL() string      { return s.url }
-func (s *fakeSource) GetSHA1() string     { return s.sha1 }
-func (s *fakeSource) Description() string { return s.description }
+func (s *fakeSource) GetURL() string                { return s.url }
+func (s *fakeSource) GetSHA1() string               { return s.sha1 }
+func (s *fakeSource) GetSignatureURL() string       { return s.signatureURL }
+func (s *fakeSource) GetSignaturePublicKey() string { return s.signaturePublicKey }
+func (s *fakeSource) Description() string           { return s.description }
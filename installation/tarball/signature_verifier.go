@@ -0,0 +1,66 @@
+package tarball
+
+import (
+	"path/filepath"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// SignatureVerifier checks a detached PGP signature of a tarball
+// against an ASCII armored public key.
+type SignatureVerifier interface {
+	Verify(tarballPath, signaturePath, publicKey string) error
+}
+
+type gpgSignatureVerifier struct {
+	cmdRunner boshsys.CmdRunner
+	fs        boshsys.FileSystem
+	logger    boshlog.Logger
+	logTag    string
+}
+
+// NewGPGSignatureVerifier verifies signatures with the gpg executable,
+// importing the public key into a throwaway home directory so that the
+// user's keyrings are neither used nor modified.
+func NewGPGSignatureVerifier(cmdRunner boshsys.CmdRunner, fs boshsys.FileSystem, logger boshlog.Logger) SignatureVerifier {
+	return &gpgSignatureVerifier{
+		cmdRunner: cmdRunner,
+		fs:        fs,
+		logger:    logger,
+		logTag:    "gpgSignatureVerifier",
+	}
+}
+
+func (v *gpgSignatureVerifier) Verify(tarballPath, signaturePath, publicKey string) error {
+	homeDir, err := v.fs.TempDir("bosh-init-gpg")
+	if err != nil {
+		return bosherr.WrapError(err, "Creating gpg home directory")
+	}
+
+	defer func() {
+		if err := v.fs.RemoveAll(homeDir); err != nil {
+			v.logger.Warn(v.logTag, "Failed to remove gpg home directory: %s", err.Error())
+		}
+	}()
+
+	keyPath := filepath.Join(homeDir, "public-key.asc")
+
+	err = v.fs.WriteFileString(keyPath, publicKey)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing public key")
+	}
+
+	_, _, _, err = v.cmdRunner.RunCommand("gpg", "--batch", "--homedir", homeDir, "--import", keyPath)
+	if err != nil {
+		return bosherr.WrapError(err, "Importing public key")
+	}
+
+	_, _, _, err = v.cmdRunner.RunCommand("gpg", "--batch", "--homedir", homeDir, "--verify", signaturePath, tarballPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Verifying signature '%s'", signaturePath)
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package tarball_test
+
+import (
+	"errors"
+
+	. "github.com/cloudfoundry/bosh-cli/installation/tarball"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GPGSignatureVerifier", func() {
+	var (
+		verifier  SignatureVerifier
+		cmdRunner *fakesys.FakeCmdRunner
+		fs        *fakesys.FakeFileSystem
+	)
+
+	BeforeEach(func() {
+		cmdRunner = fakesys.NewFakeCmdRunner()
+		fs = fakesys.NewFakeFileSystem()
+		fs.TempDirDir = "/fake-gpg-home"
+		verifier = NewGPGSignatureVerifier(cmdRunner, fs, boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	Describe("Verify", func() {
+		It("imports the public key into a temporary home and verifies the signature with it", func() {
+			err := verifier.Verify("/fake-tarball", "/fake-signature", "fake-public-key")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(cmdRunner.RunCommands).To(Equal([][]string{
+				{"gpg", "--batch", "--homedir", "/fake-gpg-home", "--import", "/fake-gpg-home/public-key.asc"},
+				{"gpg", "--batch", "--homedir", "/fake-gpg-home", "--verify", "/fake-signature", "/fake-tarball"},
+			}))
+		})
+
+		It("writes the public key into the temporary home", func() {
+			var writtenKey string
+			cmdRunner.SetCmdCallback("gpg --batch --homedir /fake-gpg-home --import /fake-gpg-home/public-key.asc", func() {
+				writtenKey, _ = fs.ReadFileString("/fake-gpg-home/public-key.asc")
+			})
+
+			err := verifier.Verify("/fake-tarball", "/fake-signature", "fake-public-key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writtenKey).To(Equal("fake-public-key"))
+		})
+
+		It("removes the temporary home", func() {
+			err := verifier.Verify("/fake-tarball", "/fake-signature", "fake-public-key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fs.FileExists("/fake-gpg-home")).To(BeFalse())
+		})
+
+		It("returns an error when the public key cannot be imported", func() {
+			cmdRunner.AddCmdResult("gpg --batch --homedir /fake-gpg-home --import /fake-gpg-home/public-key.asc", fakesys.FakeCmdResult{
+				Error: errors.New("fake-import-error"),
+			})
+
+			err := verifier.Verify("/fake-tarball", "/fake-signature", "fake-public-key")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Importing public key: fake-import-error"))
+		})
+
+		It("returns an error when the signature does not match", func() {
+			cmdRunner.AddCmdResult("gpg --batch --homedir /fake-gpg-home --verify /fake-signature /fake-tarball", fakesys.FakeCmdResult{
+				Error: errors.New("fake-verify-error"),
+			})
+
+			err := verifier.Verify("/fake-tarball", "/fake-signature", "fake-public-key")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Verifying signature '/fake-signature': fake-verify-error"))
+		})
+	})
+})
@@ -5,12 +5,22 @@ import (
 )
 
 type Target struct {
-	path string
+	path    string
+	tmpPath string
 }
 
 func NewTarget(path string) Target {
 	return Target{
-		path,
+		path: path,
+	}
+}
+
+// NewTargetWithTmpPath returns a Target keeping its temporary files in
+// tmpPath instead of inside the installation.
+func NewTargetWithTmpPath(path, tmpPath string) Target {
+	return Target{
+		path:    path,
+		tmpPath: tmpPath,
 	}
 }
 
@@ -39,5 +49,8 @@ func (t Target) JobsPath() string {
 }
 
 func (t Target) TmpPath() string {
+	if t.tmpPath != "" {
+		return t.tmpPath
+	}
 	return filepath.Join(t.path, "tmp")
 }
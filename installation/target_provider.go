@@ -16,6 +16,7 @@ type targetProvider struct {
 	deploymentStateService biconfig.DeploymentStateService
 	uuidGenerator          boshuuid.Generator
 	installationsRootPath  string
+	tmpRootPath            string
 }
 
 func NewTargetProvider(
@@ -30,6 +31,22 @@ func NewTargetProvider(
 	}
 }
 
+// NewTargetProviderWithTmpRoot returns targets keeping their temporary files
+// in a directory named after the installation in tmpRootPath.
+func NewTargetProviderWithTmpRoot(
+	deploymentStateService biconfig.DeploymentStateService,
+	uuidGenerator boshuuid.Generator,
+	installationsRootPath string,
+	tmpRootPath string,
+) TargetProvider {
+	return &targetProvider{
+		deploymentStateService: deploymentStateService,
+		uuidGenerator:          uuidGenerator,
+		installationsRootPath:  installationsRootPath,
+		tmpRootPath:            tmpRootPath,
+	}
+}
+
 func (p *targetProvider) NewTarget() (Target, error) {
 	deploymentState, err := p.deploymentStateService.Load()
 	if err != nil {
@@ -50,5 +67,11 @@ func (p *targetProvider) NewTarget() (Target, error) {
 		}
 	}
 
-	return NewTarget(filepath.Join(p.installationsRootPath, installationID)), nil
+	installationPath := filepath.Join(p.installationsRootPath, installationID)
+
+	if p.tmpRootPath != "" {
+		return NewTargetWithTmpPath(installationPath, filepath.Join(p.tmpRootPath, installationID)), nil
+	}
+
+	return NewTarget(installationPath), nil
 }
@@ -49,6 +49,16 @@ var _ = Describe("TargetProvider", func() {
 			target, err := targetProvider.NewTarget()
 			Expect(err).ToNot(HaveOccurred())
 			Expect(target.Path()).To(Equal(filepath.Join("/", ".bosh", "installations", "12345")))
+			Expect(target.TmpPath()).To(Equal(filepath.Join("/", ".bosh", "installations", "12345", "tmp")))
+		})
+
+		It("keeps temporary files in the temp root when there is one", func() {
+			targetProvider = NewTargetProviderWithTmpRoot(deploymentStateService, fakeUUIDGenerator, installationsRootPath, filepath.Join("/", "fake-tmp"))
+
+			target, err := targetProvider.NewTarget()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(target.Path()).To(Equal(filepath.Join("/", ".bosh", "installations", "12345")))
+			Expect(target.TmpPath()).To(Equal(filepath.Join("/", "fake-tmp", "12345")))
 		})
 
 		It("does not change the saved installation_id", func() {
@@ -34,5 +34,10 @@ var _ = Describe("Target", func() {
 		It("returns the temp path", func() {
 			Expect(target.TmpPath()).To(Equal(filepath.Join("/", "home", "fake", "madcow", "tmp")))
 		})
+
+		It("returns the given temp path", func() {
+			target = NewTargetWithTmpPath("/home/fake/madcow", "/fake-tmp/madcow")
+			Expect(target.TmpPath()).To(Equal("/fake-tmp/madcow"))
+		})
 	})
 })
@@ -35,6 +35,7 @@ import (
 	biinstance "github.com/cloudfoundry/bosh-cli/deployment/instance"
 	mock_instance_state "github.com/cloudfoundry/bosh-cli/deployment/instance/state/mocks"
 	bideplmanifest "github.com/cloudfoundry/bosh-cli/deployment/manifest"
+	bideplrel "github.com/cloudfoundry/bosh-cli/deployment/release"
 	bisshtunnel "github.com/cloudfoundry/bosh-cli/deployment/sshtunnel"
 	bidepltpl "github.com/cloudfoundry/bosh-cli/deployment/template"
 	bivm "github.com/cloudfoundry/bosh-cli/deployment/vm"
@@ -316,7 +317,8 @@ cloud_provider:
 				Expect(fakeStage.SubStages).To(ContainElement(stage))
 			}).Return(installation, nil).AnyTimes()
 			mockInstaller.EXPECT().Cleanup(installation).AnyTimes()
-			mockCloudFactory.EXPECT().NewCloud(installation, directorID).Return(mockCloud, nil).AnyTimes()
+			mockCloudFactory.EXPECT().NewCloud(installation, directorID, 0).Return(mockCloud, nil).AnyTimes()
+			mockCloud.EXPECT().UsesRegistry().Return(true).AnyTimes()
 		}
 
 		var writeStemcellReleaseTarball = func() {
@@ -373,7 +375,7 @@ cloud_provider:
 
 			//TODO: use a real state builder
 
-			mockStateBuilderFactory.EXPECT().NewBuilder(mockBlobstore, mockAgentClient).Return(mockStateBuilder).AnyTimes()
+			mockStateBuilderFactory.EXPECT().NewBuilder(mockBlobstore, checksumAgentClient{mockAgentClient}, gomock.Any()).Return(mockStateBuilder).AnyTimes()
 			mockStateBuilder.EXPECT().Build(jobName, jobIndex, gomock.Any(), gomock.Any(), gomock.Any()).Return(mockState, nil).AnyTimes()
 			mockStateBuilder.EXPECT().BuildInitialState(jobName, jobIndex, gomock.Any()).Return(mockState, nil).AnyTimes()
 			mockState.EXPECT().ToApplySpec().Return(applySpec).AnyTimes()
@@ -397,7 +399,8 @@ cloud_provider:
 			pingDelay := 100 * time.Millisecond
 			deploymentFactory := bidepl.NewFactory(pingTimeout, pingDelay)
 
-			ui := biui.NewWriterUI(stdOut, stdErr, logger)
+			// manifest changes are confirmed as with --non-interactive
+			ui := biui.NewNonInteractiveUI(biui.NewWriterUI(stdOut, stdErr, logger))
 			doGet := func(deploymentManifestPath string, statePath string, deploymentVars boshtpl.Variables, deploymentOp patch.Op) DeploymentPreparer {
 				// todo: figure this out?
 				deploymentStateService = biconfig.NewFileSystemDeploymentStateService(fs, fakeUUIDGenerator, logger, biconfig.DeploymentStatePath(deploymentManifestPath, statePath))
@@ -409,19 +412,23 @@ cloud_provider:
 
 				legacyDeploymentStateMigrator = biconfig.NewLegacyDeploymentStateMigrator(deploymentStateService, fs, fakeUUIDGenerator, logger)
 				deploymentRecord := bidepl.NewRecord(deploymentRepo, releaseRepo, stemcellRepo)
+				checkpointRepo := biconfig.NewCheckpointRepo(deploymentStateService)
 				stemcellManagerFactory = bistemcell.NewManagerFactory(stemcellRepo)
 				diskManagerFactory = bidisk.NewManagerFactory(diskRepo, logger)
-				diskDeployer = bivm.NewDiskDeployer(diskManagerFactory, diskRepo, logger)
+				diskDeployer = bivm.NewDiskDeployer(diskManagerFactory, diskRepo, false, logger)
 				vmManagerFactory = bivm.NewManagerFactory(vmRepo, stemcellRepo, diskDeployer, fakeAgentIDGenerator, fs, logger)
 				deployer := bidepl.NewDeployer(
 					vmManagerFactory,
 					instanceManagerFactory,
 					deploymentFactory,
+					checkpointRepo,
+					biconfig.NewInstanceRepo(deploymentStateService),
 					logger,
 				)
 				fakeHTTPClient := fakebihttpclient.NewFakeHTTPClient()
 				tarballCache := bitarball.NewCache("fake-base-path", fs, logger)
-				tarballProvider := bitarball.NewProvider(tarballCache, fs, fakeHTTPClient, 1, 0, logger)
+				signatureVerifier := bitarball.NewGPGSignatureVerifier(fakesys.NewFakeCmdRunner(), fs, logger)
+				tarballProvider := bitarball.NewProvider(tarballCache, fs, fakeHTTPClient, signatureVerifier, 1, 0, logger)
 
 				cpiInstaller := bicpirel.CpiInstaller{
 					ReleaseManager:   releaseManager,
@@ -459,14 +466,16 @@ cloud_provider:
 					ui,
 					logger,
 					"deployCmd",
+					fs,
 					deploymentStateService,
 					legacyDeploymentStateMigrator,
 					releaseManager,
 					deploymentRecord,
+					checkpointRepo,
 					mockCloudFactory,
 					stemcellManagerFactory,
 					mockAgentClientFactory,
-					vmManagerFactory,
+					func(int) bivm.ManagerFactory { return vmManagerFactory },
 					mockBlobstoreFactory,
 					deployer,
 					deploymentManifestPath,
@@ -478,7 +487,12 @@ cloud_provider:
 					releaseSetAndInstallationManifestParser,
 					deploymentManifestParser,
 					tempRootConfigurator,
+					biinstall.NewScratchSpaceChecker(fs, biinstall.FreeSpace, logger),
 					targetProvider,
+					bideplrel.NewJobResolver(releaseManager),
+					nil,
+					false,
+					false,
 				)
 			}
 
@@ -512,6 +526,7 @@ cloud_provider:
 				mockAgentClient.EXPECT().Start(),
 				mockAgentClient.EXPECT().GetState().Return(agentRunningState, nil),
 				mockAgentClient.EXPECT().RunScript("post-start", map[string]interface{}{}),
+				mockAgentClient.EXPECT().RunScript("post-deploy", map[string]interface{}{}),
 			)
 		}
 
@@ -544,14 +559,15 @@ cloud_provider:
 				mockCloud.EXPECT().AttachDisk(newVMCID, oldDiskCID),
 				mockAgentClient.EXPECT().Ping().Return("any-state", nil),
 				mockAgentClient.EXPECT().MountDisk(oldDiskCID),
+				mockAgentClient.EXPECT().ListDisk().Return([]string{oldDiskCID}, nil),
 				mockCloud.EXPECT().CreateDisk(newDiskSize, diskCloudProperties, newVMCID).Return(newDiskCID, nil),
 				mockCloud.EXPECT().AttachDisk(newVMCID, newDiskCID),
 				mockAgentClient.EXPECT().Ping().Return("any-state", nil),
 				mockAgentClient.EXPECT().MountDisk(newDiskCID),
 				mockAgentClient.EXPECT().MigrateDisk(),
+				mockAgentClient.EXPECT().ListDisk().Return([]string{newDiskCID}, nil),
 				mockCloud.EXPECT().DetachDisk(newVMCID, oldDiskCID),
 				mockAgentClient.EXPECT().Ping().Return("any-state", nil),
-				mockCloud.EXPECT().DeleteDisk(oldDiskCID),
 
 				// start jobs & wait for running
 				mockAgentClient.EXPECT().Apply(applySpec),
@@ -562,6 +578,7 @@ cloud_provider:
 				mockAgentClient.EXPECT().Start(),
 				mockAgentClient.EXPECT().GetState().Return(agentRunningState, nil),
 				mockAgentClient.EXPECT().RunScript("post-start", map[string]interface{}{}),
+				mockAgentClient.EXPECT().RunScript("post-deploy", map[string]interface{}{}),
 			)
 		}
 
@@ -590,14 +607,15 @@ cloud_provider:
 				mockCloud.EXPECT().AttachDisk(newVMCID, oldDiskCID),
 				mockAgentClient.EXPECT().Ping().Return("any-state", nil),
 				mockAgentClient.EXPECT().MountDisk(oldDiskCID),
+				mockAgentClient.EXPECT().ListDisk().Return([]string{oldDiskCID}, nil),
 				mockCloud.EXPECT().CreateDisk(newDiskSize, diskCloudProperties, newVMCID).Return(newDiskCID, nil),
 				mockCloud.EXPECT().AttachDisk(newVMCID, newDiskCID),
 				mockAgentClient.EXPECT().Ping().Return("any-state", nil),
 				mockAgentClient.EXPECT().MountDisk(newDiskCID),
 				mockAgentClient.EXPECT().MigrateDisk(),
+				mockAgentClient.EXPECT().ListDisk().Return([]string{newDiskCID}, nil),
 				mockCloud.EXPECT().DetachDisk(newVMCID, oldDiskCID),
 				mockAgentClient.EXPECT().Ping().Return("any-state", nil),
-				mockCloud.EXPECT().DeleteDisk(oldDiskCID),
 
 				// start jobs & wait for running
 				mockAgentClient.EXPECT().Apply(applySpec),
@@ -608,6 +626,7 @@ cloud_provider:
 				mockAgentClient.EXPECT().Start(),
 				mockAgentClient.EXPECT().GetState().Return(agentRunningState, nil),
 				mockAgentClient.EXPECT().RunScript("post-start", map[string]interface{}{}),
+				mockAgentClient.EXPECT().RunScript("post-deploy", map[string]interface{}{}),
 			)
 		}
 
@@ -634,6 +653,7 @@ cloud_provider:
 
 				// attaching a missing disk will fail
 				mockCloud.EXPECT().AttachDisk(newVMCID, oldDiskCID).Return(
+					nil,
 					bicloud.NewCPIError("attach_disk", bicloud.CmdError{
 						Type:    bicloud.DiskNotFoundError,
 						Message: "fake-disk-not-found-message",
@@ -669,6 +689,7 @@ cloud_provider:
 				mockCloud.EXPECT().AttachDisk(newVMCID, oldDiskCID),
 				mockAgentClient.EXPECT().Ping().Return("any-state", nil),
 				mockAgentClient.EXPECT().MountDisk(oldDiskCID),
+				mockAgentClient.EXPECT().ListDisk().Return([]string{oldDiskCID}, nil),
 				mockCloud.EXPECT().CreateDisk(newDiskSize, diskCloudProperties, newVMCID).Return(newDiskCID, nil),
 				mockCloud.EXPECT().AttachDisk(newVMCID, newDiskCID),
 				mockAgentClient.EXPECT().Ping().Return("any-state", nil),
@@ -706,14 +727,15 @@ cloud_provider:
 				mockCloud.EXPECT().AttachDisk(newVMCID, oldDiskCID),
 				mockAgentClient.EXPECT().Ping().Return("any-state", nil),
 				mockAgentClient.EXPECT().MountDisk(oldDiskCID),
+				mockAgentClient.EXPECT().ListDisk().Return([]string{oldDiskCID}, nil),
 				mockCloud.EXPECT().CreateDisk(newDiskSize, diskCloudProperties, newVMCID).Return(newDiskCID, nil),
 				mockCloud.EXPECT().AttachDisk(newVMCID, newDiskCID),
 				mockAgentClient.EXPECT().Ping().Return("any-state", nil),
 				mockAgentClient.EXPECT().MountDisk(newDiskCID),
 				mockAgentClient.EXPECT().MigrateDisk(),
+				mockAgentClient.EXPECT().ListDisk().Return([]string{newDiskCID}, nil),
 				mockCloud.EXPECT().DetachDisk(newVMCID, oldDiskCID),
 				mockAgentClient.EXPECT().Ping().Return("any-state", nil),
-				mockCloud.EXPECT().DeleteDisk(oldDiskCID),
 
 				// start jobs & wait for running
 				mockAgentClient.EXPECT().Apply(applySpec),
@@ -724,6 +746,7 @@ cloud_provider:
 				mockAgentClient.EXPECT().Start(),
 				mockAgentClient.EXPECT().GetState().Return(agentRunningState, nil),
 				mockAgentClient.EXPECT().RunScript("post-start", map[string]interface{}{}),
+				mockAgentClient.EXPECT().RunScript("post-deploy", map[string]interface{}{}),
 			)
 		}
 
@@ -774,7 +797,7 @@ cloud_provider:
 			mockAgentClientFactory = mock_httpagent.NewMockAgentClientFactory(mockCtrl)
 			mockAgentClient = mock_agentclient.NewMockAgentClient(mockCtrl)
 
-			mockAgentClientFactory.EXPECT().NewAgentClient(directorID, mbusURL, caCert).Return(mockAgentClient, nil).AnyTimes()
+			mockAgentClientFactory.EXPECT().NewAgentClient(directorID, mbusURL, caCert).Return(checksumAgentClient{mockAgentClient}, nil).AnyTimes()
 
 			writeDeploymentManifest()
 			writeCPIReleaseTarball()
@@ -838,7 +861,7 @@ cloud_provider:
 				expectDeployFlow()
 
 				// new directorID will be generated
-				mockAgentClientFactory.EXPECT().NewAgentClient(gomock.Any(), mbusURL, caCert).Return(mockAgentClient, nil)
+				mockAgentClientFactory.EXPECT().NewAgentClient(gomock.Any(), mbusURL, caCert).Return(checksumAgentClient{mockAgentClient}, nil)
 
 				err := newCreateEnvCmd().Run(fakeStage, newDeployOpts(deploymentManifestPath, statePath))
 				Expect(err).ToNot(HaveOccurred())
@@ -943,11 +966,9 @@ cloud_provider:
 						Expect(diskRecords).To(HaveLen(2)) // current + unused
 					})
 
-					It("deletes unused disks", func() {
+					It("orphans unused disks", func() {
 						expectDeployWithDiskMigrationRepair()
 
-						mockCloud.EXPECT().DeleteDisk("fake-disk-cid-2")
-
 						err := newCreateEnvCmd().Run(fakeStage, newDeployOpts(deploymentManifestPath, ""))
 						Expect(err).ToNot(HaveOccurred())
 
@@ -956,9 +977,11 @@ cloud_provider:
 						Expect(found).To(BeTrue())
 						Expect(diskRecord.CID).To(Equal("fake-disk-cid-3"))
 
-						diskRecords, err := diskRepo.All()
+						orphanedDiskRecords, err := diskRepo.FindOrphaned()
 						Expect(err).ToNot(HaveOccurred())
-						Expect(diskRecords).To(Equal([]biconfig.DiskRecord{diskRecord}))
+						Expect(orphanedDiskRecords).To(HaveLen(2))
+						Expect(orphanedDiskRecords[0].CID).To(Equal("fake-disk-cid-1"))
+						Expect(orphanedDiskRecords[1].CID).To(Equal("fake-disk-cid-2"))
 					})
 				})
 			})
@@ -991,3 +1014,13 @@ cloud_provider:
 func newDeployOpts(manifestPath string, statePath string) CreateEnvOpts {
 	return CreateEnvOpts{StatePath: statePath, Args: CreateEnvArgs{Manifest: FileBytesWithPathArg{Path: manifestPath}}}
 }
+
+// checksumAgentClient reports the same checksum for every disk, so that
+// migrated disk content always matches.
+type checksumAgentClient struct {
+	*mock_agentclient.MockAgentClient
+}
+
+func (c checksumAgentClient) DiskChecksum(diskCID string) (string, error) {
+	return "fake-disk-checksum", nil
+}
@@ -0,0 +1,98 @@
+package logger
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+type redactingLogger struct {
+	boshlog.Logger
+	level       boshlog.LogLevel
+	forcedDebug int32
+	redactor    *Redactor
+}
+
+// NewRedactingLogger returns a logger that masks secrets in messages before
+// passing them to logger. level is the level of logger, so that messages it
+// would drop are neither formatted nor redacted.
+func NewRedactingLogger(logger boshlog.Logger, level boshlog.LogLevel, redactor *Redactor) boshlog.Logger {
+	return &redactingLogger{Logger: logger, level: level, redactor: redactor}
+}
+
+func (l *redactingLogger) Debug(tag, msg string, args ...interface{}) {
+	if l.enabled(boshlog.LevelDebug) {
+		l.Logger.Debug(tag, "%s", l.redact(msg, args))
+	}
+}
+
+func (l *redactingLogger) DebugWithDetails(tag, msg string, args ...interface{}) {
+	if l.enabled(boshlog.LevelDebug) {
+		l.Logger.DebugWithDetails(tag, "%s", l.redactWithDetails(msg, args)...)
+	}
+}
+
+func (l *redactingLogger) Info(tag, msg string, args ...interface{}) {
+	if l.enabled(boshlog.LevelInfo) {
+		l.Logger.Info(tag, "%s", l.redact(msg, args))
+	}
+}
+
+func (l *redactingLogger) Warn(tag, msg string, args ...interface{}) {
+	if l.enabled(boshlog.LevelWarn) {
+		l.Logger.Warn(tag, "%s", l.redact(msg, args))
+	}
+}
+
+func (l *redactingLogger) Error(tag, msg string, args ...interface{}) {
+	if l.enabled(boshlog.LevelError) {
+		l.Logger.Error(tag, "%s", l.redact(msg, args))
+	}
+}
+
+func (l *redactingLogger) ErrorWithDetails(tag, msg string, args ...interface{}) {
+	if l.enabled(boshlog.LevelError) {
+		l.Logger.ErrorWithDetails(tag, "%s", l.redactWithDetails(msg, args)...)
+	}
+}
+
+// ToggleForcedDebug makes the logger log messages of every level, as the
+// wrapped logger does once toggled, e.g. on SIGHUP.
+func (l *redactingLogger) ToggleForcedDebug() {
+	for {
+		forcedDebug := atomic.LoadInt32(&l.forcedDebug)
+		if atomic.CompareAndSwapInt32(&l.forcedDebug, forcedDebug, 1-forcedDebug) {
+			break
+		}
+	}
+
+	l.Logger.ToggleForcedDebug()
+}
+
+func (l *redactingLogger) enabled(level boshlog.LogLevel) bool {
+	return level >= l.level || atomic.LoadInt32(&l.forcedDebug) == 1
+}
+
+func (l *redactingLogger) redact(msg string, args []interface{}) string {
+	if len(args) == 0 {
+		return l.redactor.Redact(msg)
+	}
+
+	return l.redactor.Redact(fmt.Sprintf(msg, args...))
+}
+
+// redactWithDetails keeps the last argument separate since the *WithDetails
+// methods print it as a block of details after the message.
+func (l *redactingLogger) redactWithDetails(msg string, args []interface{}) []interface{} {
+	if len(args) == 0 {
+		return []interface{}{l.redactor.Redact(msg), ""}
+	}
+
+	details := args[len(args)-1]
+
+	return []interface{}{
+		l.redact(msg, args[:len(args)-1]),
+		l.redactor.Redact(fmt.Sprintf("%v", details)),
+	}
+}
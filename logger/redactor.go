@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"regexp"
+	"sync"
+)
+
+// RedactedValue replaces the values of secrets.
+const RedactedValue = "<redacted>"
+
+// secretKeyPattern matches names of properties that hold secrets, such as
+// password, director_secret, private_key, aws_access_key_id or ca_cert.
+const secretKeyPattern = `[\w.-]*(?i:password|passwd|secret|token|credential|private_key|access_key|_key\b|cert)[\w.-]*`
+
+var (
+	// Values of secret keys in JSON and Go syntax, e.g. CPI requests and %#v maps
+	quotedSecretRegexp = regexp.MustCompile(`("` + secretKeyPattern + `"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+	// Values of secret keys in YAML, e.g. `  password: secret`
+	yamlSecretRegexp = regexp.MustCompile(`(?m)^(\s*(?:-\s+)?` + secretKeyPattern + `:[ \t]+)[^\s|>].*$`)
+
+	// Certificates and keys, also when escaped in JSON strings
+	pemRegexp = regexp.MustCompile(`(?s)-----BEGIN ([A-Z ]+)-----.*?-----END [A-Z ]+-----`)
+
+	// Passwords in URLs, e.g. the mbus URL
+	urlPasswordRegexp = regexp.MustCompile(`(://[^:/@\s"']+:)[^@/\s"']+@`)
+)
+
+// Redactor masks the values of secrets in text that is logged or shown to
+// the user. Secrets are told apart by the name of the property holding them,
+// or by their format for certificates, keys and URL passwords.
+type Redactor struct {
+	disabled bool
+	mutex    sync.RWMutex
+}
+
+func NewRedactor() *Redactor {
+	return &Redactor{}
+}
+
+// SetEnabled turns redaction on or off, e.g. for local debugging.
+func (r *Redactor) SetEnabled(enabled bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.disabled = !enabled
+}
+
+func (r *Redactor) Redact(text string) string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.disabled {
+		return text
+	}
+
+	text = pemRegexp.ReplaceAllString(text, RedactedValue)
+	text = quotedSecretRegexp.ReplaceAllString(text, `$1"`+RedactedValue+`"`)
+	text = yamlSecretRegexp.ReplaceAllString(text, `${1}`+RedactedValue)
+	text = urlPasswordRegexp.ReplaceAllString(text, `${1}`+RedactedValue+`@`)
+
+	return text
+}
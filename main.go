@@ -19,7 +19,8 @@ import (
 )
 
 func main() {
-	logger := newLogger()
+	redactor := bilog.NewRedactor()
+	logger := newLogger(redactor)
 	defer handlePanic()
 
 	ui := boshui.NewConfUI(logger)
@@ -29,18 +30,20 @@ func main() {
 
 	cmd, err := cmdFactory.New(os.Args[1:])
 	if err != nil {
-		fail(err, ui, logger)
+		fail(err, redactor, ui, logger)
 	}
 
+	redactor.SetEnabled(cmd.BoshOpts.RedactOpt != "false")
+
 	err = cmd.Execute()
 	if err != nil {
-		fail(err, ui, logger)
+		fail(err, redactor, ui, logger)
 	} else {
 		success(ui, logger)
 	}
 }
 
-func newLogger() boshlog.Logger {
+func newLogger(redactor *bilog.Redactor) boshlog.Logger {
 	level := boshlog.LevelNone
 
 	logLevelString := os.Getenv("BOSH_LOG_LEVEL")
@@ -52,16 +55,16 @@ func newLogger() boshlog.Logger {
 			err = bosherr.WrapError(err, "Invalid BOSH_LOG_LEVEL value")
 			logger := boshlog.NewLogger(boshlog.LevelError)
 			ui := boshui.NewConsoleUI(logger)
-			fail(err, ui, logger)
+			fail(err, bilog.NewRedactor(), ui, logger)
 		}
 	}
 
 	logPath := os.Getenv("BOSH_LOG_PATH")
 	if logPath != "" {
-		return newSignalableFileLogger(logPath, level)
+		return newSignalableFileLogger(logPath, level, redactor)
 	}
 
-	return newSignalableLogger(boshlog.NewLogger(level))
+	return newSignalableLogger(bilog.NewRedactingLogger(boshlog.NewLogger(level), level, redactor))
 }
 
 func newSignalableLogger(logger boshlog.Logger) boshlog.Logger {
@@ -71,7 +74,7 @@ func newSignalableLogger(logger boshlog.Logger) boshlog.Logger {
 	return signalableLogger
 }
 
-func newSignalableFileLogger(logPath string, level boshlog.LogLevel) boshlog.Logger {
+func newSignalableFileLogger(logPath string, level boshlog.LogLevel, redactor *bilog.Redactor) boshlog.Logger {
 	// Log file logger errors to the STDERR logger
 	logger := boshlog.NewLogger(boshlog.LevelError)
 	fs := boshsys.NewOsFileSystem(logger)
@@ -82,10 +85,10 @@ func newSignalableFileLogger(logPath string, level boshlog.LogLevel) boshlog.Log
 	if err != nil {
 		logger := boshlog.NewLogger(boshlog.LevelError)
 		ui := boshui.NewConsoleUI(logger)
-		fail(err, ui, logger)
+		fail(err, bilog.NewRedactor(), ui, logger)
 	}
 
-	return newSignalableLogger(logfileLogger)
+	return newSignalableLogger(bilog.NewRedactingLogger(logfileLogger, level, redactor))
 }
 
 func handlePanic() {
@@ -110,18 +113,21 @@ func handlePanic() {
 		logger.ErrorWithDetails("CLI", "Panic: %s", msg, debug.Stack())
 
 		ui := boshui.NewConsoleUI(logger)
-		fail(nil, ui, logger)
+		fail(nil, bilog.NewRedactor(), ui, logger)
 	}
 }
 
-func fail(err error, ui boshui.UI, logger boshlog.Logger) {
+func fail(err error, redactor *bilog.Redactor, ui boshui.UI, logger boshlog.Logger) {
+	exitCode := boshcmd.ExitCodeError
+
 	if err != nil {
 		logger.Error("CLI", err.Error())
-		ui.ErrorLinef(boshuifmt.MultilineError(err))
+		ui.ErrorLinef("%s", redactor.Redact(boshuifmt.MultilineError(err)))
+		exitCode = boshcmd.ExitCode(err)
 	}
-	ui.ErrorLinef("Exit code 1")
+	ui.ErrorLinef("Exit code %d", exitCode)
 	ui.Flush() // todo make sure UI is flushed
-	os.Exit(1)
+	os.Exit(exitCode)
 }
 
 func success(ui boshui.UI, logger boshlog.Logger) {
@@ -60,13 +60,13 @@ func (_m *MockServerManager) EXPECT() *_MockServerManagerRecorder {
 	return _m.recorder
 }
 
-func (_m *MockServerManager) Start(_param0 string, _param1 string, _param2 string, _param3 int) (registry.Server, error) {
-	ret := _m.ctrl.Call(_m, "Start", _param0, _param1, _param2, _param3)
+func (_m *MockServerManager) Start(_param0 string, _param1 string, _param2 string, _param3 int, _param4 registry.TLSConfig) (registry.Server, error) {
+	ret := _m.ctrl.Call(_m, "Start", _param0, _param1, _param2, _param3, _param4)
 	ret0, _ := ret[0].(registry.Server)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockServerManagerRecorder) Start(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "Start", arg0, arg1, arg2, arg3)
+func (_mr *_MockServerManagerRecorder) Start(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Start", arg0, arg1, arg2, arg3, arg4)
 }
@@ -1,16 +1,28 @@
 package registry
 
 import (
-	"fmt"
+	"crypto/tls"
 	"net"
 	"net/http"
+	"strconv"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
 type ServerManager interface {
-	Start(string, string, string, int) (Server, error)
+	Start(string, string, string, int, TLSConfig) (Server, error)
+}
+
+// TLSConfig makes the registry serve HTTPS with the PEM encoded certificate
+// and private key. The registry serves plain HTTP when it is empty.
+type TLSConfig struct {
+	Certificate string
+	PrivateKey  string
+}
+
+func (c TLSConfig) IsEmpty() bool {
+	return c == TLSConfig{}
 }
 
 type serverManager struct {
@@ -27,14 +39,14 @@ func NewServerManager(logger boshlog.Logger) ServerManager {
 
 // Create starts a new server on a goroutine and returns it
 // The returned error is only for starting. Error while running is logged.
-func (s *serverManager) Start(username string, password string, host string, port int) (Server, error) {
+func (s *serverManager) Start(username string, password string, host string, port int, tlsConfig TLSConfig) (Server, error) {
 	startedCh := make(chan error)
 	server := &server{
 		logger: s.logger,
 		logTag: "registryServer",
 	}
 	go func() {
-		err := server.start(username, password, host, port, startedCh)
+		err := server.start(username, password, host, port, tlsConfig, startedCh)
 		if err != nil {
 			s.logger.Debug(s.logTag, "Registry error occurred: %s", err.Error())
 		}
@@ -67,15 +79,34 @@ func NewServer(logger boshlog.Logger) Server {
 	}
 }
 
-func (s *server) start(username string, password string, host string, port int, readyErrCh chan error) error {
+func (s *server) start(username string, password string, host string, port int, tlsConfig TLSConfig, readyErrCh chan error) error {
 	s.logger.Debug(s.logTag, "Starting registry server at %s:%d", host, port)
-	var err error
-	s.listener, err = net.Listen("tcp", fmt.Sprintf("%s:%d", host, port))
+
+	var certificates []tls.Certificate
+
+	if !tlsConfig.IsEmpty() {
+		certificate, err := tls.X509KeyPair([]byte(tlsConfig.Certificate), []byte(tlsConfig.PrivateKey))
+		if err != nil {
+			readyErrCh <- bosherr.WrapError(err, "Loading registry TLS certificate")
+			return nil
+		}
+		certificates = append(certificates, certificate)
+	}
+
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+
+	listener, err := net.Listen("tcp", address)
 	if err != nil {
-		readyErrCh <- bosherr.WrapError(err, "Starting registry listener")
+		readyErrCh <- bosherr.WrapErrorf(err, "Starting registry listener on '%s', make sure the port is not in use", address)
 		return nil
 	}
 
+	if len(certificates) > 0 {
+		listener = tls.NewListener(listener, &tls.Config{Certificates: certificates})
+	}
+
+	s.listener = listener
+
 	readyErrCh <- nil
 
 	httpServer := http.Server{}
@@ -1,7 +1,13 @@
 package release
 
 import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
 	"path/filepath"
+	"strings"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshcmd "github.com/cloudfoundry/bosh-utils/fileutil"
@@ -22,6 +28,10 @@ type ArchiveReader struct {
 	compressor boshcmd.Compressor
 	fs         boshsys.FileSystem
 
+	// streaming reads the release tarball in-process and writes only the
+	// entries the release manifest refers to, instead of extracting all of it
+	streaming bool
+
 	logTag string
 	logger boshlog.Logger
 }
@@ -45,6 +55,21 @@ func NewArchiveReader(
 	}
 }
 
+// NewStreamingArchiveReader returns an ArchiveReader that never extracts
+// the whole release tarball. Job, package and license archives are written
+// straight from the tarball stream, and only when release.MF refers to them.
+func NewStreamingArchiveReader(
+	jobArchiveReader boshjob.ArchiveReader,
+	pkgArchiveReader boshpkg.ArchiveReader,
+	compressor boshcmd.Compressor,
+	fs boshsys.FileSystem,
+	logger boshlog.Logger,
+) ArchiveReader {
+	reader := NewArchiveReader(jobArchiveReader, pkgArchiveReader, compressor, fs, logger)
+	reader.streaming = true
+	return reader
+}
+
 func (r ArchiveReader) Read(path string) (Release, error) {
 	extractPath, err := r.fs.TempDir("bosh-release")
 	if err != nil {
@@ -53,15 +78,13 @@ func (r ArchiveReader) Read(path string) (Release, error) {
 
 	r.logger.Info(r.logTag, "Extracting release tarball '%s' to '%s'", path, extractPath)
 
-	err = r.compressor.DecompressFileToDir(path, extractPath, boshcmd.CompressorOptions{})
-	if err != nil {
-		r.cleanUp(extractPath)
-		return nil, bosherr.WrapError(err, "Extracting release")
-	}
-
-	manifestPath := filepath.Join(extractPath, "release.MF")
+	var manifest boshman.Manifest
 
-	manifest, err := boshman.NewManifestFromPath(manifestPath, r.fs)
+	if r.streaming {
+		manifest, err = r.stream(path, extractPath)
+	} else {
+		manifest, err = r.extract(path, extractPath)
+	}
 	if err != nil {
 		r.cleanUp(extractPath)
 		return nil, err
@@ -76,6 +99,156 @@ func (r ArchiveReader) Read(path string) (Release, error) {
 	return release, nil
 }
 
+func (r ArchiveReader) extract(path, extractPath string) (boshman.Manifest, error) {
+	err := r.compressor.DecompressFileToDir(path, extractPath, boshcmd.CompressorOptions{})
+	if err != nil {
+		return boshman.Manifest{}, bosherr.WrapError(err, "Extracting release")
+	}
+
+	return boshman.NewManifestFromPath(filepath.Join(extractPath, "release.MF"), r.fs)
+}
+
+// stream writes the entries of the release tarball that a release is read
+// from to extractPath. Releases written by the CLI start with release.MF, so
+// archives it does not refer to are skipped without being written. Archives
+// coming before release.MF are written and removed afterwards when unused.
+func (r ArchiveReader) stream(tarballPath, extractPath string) (boshman.Manifest, error) {
+	file, err := r.fs.OpenFile(tarballPath, os.O_RDONLY, 0)
+	if err != nil {
+		return boshman.Manifest{}, bosherr.WrapErrorf(err, "Opening release '%s'", tarballPath)
+	}
+
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return boshman.Manifest{}, bosherr.WrapError(err, "Extracting release")
+	}
+
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+
+	var manifest *boshman.Manifest
+	var written []string
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return boshman.Manifest{}, bosherr.WrapError(err, "Extracting release")
+		}
+
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+
+		if name != "release.MF" && !isReleaseArchive(name) {
+			continue
+		}
+
+		if manifest != nil && !manifestRefersTo(*manifest, name) {
+			continue
+		}
+
+		err = r.writeEntry(tarReader, filepath.Join(extractPath, filepath.FromSlash(name)))
+		if err != nil {
+			return boshman.Manifest{}, bosherr.WrapErrorf(err, "Extracting '%s' from release", name)
+		}
+
+		if name == "release.MF" {
+			readManifest, err := boshman.NewManifestFromPath(filepath.Join(extractPath, name), r.fs)
+			if err != nil {
+				return boshman.Manifest{}, err
+			}
+
+			manifest = &readManifest
+		} else {
+			written = append(written, name)
+		}
+	}
+
+	if manifest == nil {
+		return boshman.Manifest{}, bosherr.Errorf("Extracting release: release.MF not found in '%s'", tarballPath)
+	}
+
+	for _, name := range written {
+		if !manifestRefersTo(*manifest, name) {
+			r.cleanUp(filepath.Join(extractPath, filepath.FromSlash(name)))
+		}
+	}
+
+	return *manifest, nil
+}
+
+func (r ArchiveReader) writeEntry(reader io.Reader, entryPath string) error {
+	err := r.fs.MkdirAll(filepath.Dir(entryPath), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	file, err := r.fs.OpenFile(entryPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	_, err = io.Copy(file, reader)
+
+	return err
+}
+
+// isReleaseArchive reports whether name is where a release tarball keeps a
+// job, package, compiled package or license archive.
+func isReleaseArchive(name string) bool {
+	if name == "license.tgz" {
+		return true
+	}
+
+	dir, base := path.Split(name)
+
+	switch dir {
+	case "jobs/", "packages/", "compiled_packages/":
+		return strings.HasSuffix(base, ".tgz")
+	}
+
+	return false
+}
+
+func manifestRefersTo(manifest boshman.Manifest, name string) bool {
+	dir, base := path.Split(name)
+	refName := strings.TrimSuffix(base, ".tgz")
+
+	switch dir {
+	case "jobs/":
+		for _, ref := range manifest.Jobs {
+			if ref.Name == refName {
+				return true
+			}
+		}
+	case "packages/":
+		for _, ref := range manifest.Packages {
+			if ref.Name == refName {
+				return true
+			}
+		}
+	case "compiled_packages/":
+		for _, ref := range manifest.CompiledPkgs {
+			if ref.Name == refName {
+				return true
+			}
+		}
+	default:
+		return name == "license.tgz" && manifest.License != nil
+	}
+
+	return false
+}
+
 func (r ArchiveReader) cleanUp(extractPath string) {
 	removeErr := r.fs.RemoveAll(extractPath)
 	if removeErr != nil {
@@ -1,6 +1,9 @@
 package release_test
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"errors"
 	"os"
 	"path/filepath"
@@ -401,4 +404,106 @@ compiled_packages:
 			})
 		})
 	})
+
+	Describe("Read when streaming", func() {
+		var tarballPath string
+
+		writeTarball := func(entries ...string) {
+			var buffer bytes.Buffer
+
+			gzipWriter := gzip.NewWriter(&buffer)
+			tarWriter := tar.NewWriter(gzipWriter)
+
+			for i := 0; i < len(entries); i += 2 {
+				err := tarWriter.WriteHeader(&tar.Header{
+					Name:     entries[i],
+					Mode:     0644,
+					Size:     int64(len(entries[i+1])),
+					Typeflag: tar.TypeReg,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = tarWriter.Write([]byte(entries[i+1]))
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			Expect(tarWriter.Close()).To(Succeed())
+			Expect(gzipWriter.Close()).To(Succeed())
+
+			Expect(fs.WriteFile(tarballPath, buffer.Bytes())).To(Succeed())
+		}
+
+		manifest := `---
+name: release
+version: version
+jobs:
+- name: job1
+  version: job1-version
+  fingerprint: job1-fp
+  sha1: job1-sha
+packages:
+- name: pkg1
+  version: pkg1-version
+  fingerprint: pkg1-fp
+  sha1: pkg1-sha
+`
+
+		BeforeEach(func() {
+			tarballPath = filepath.Join("/", "some", "release.tgz")
+			reader = NewStreamingArchiveReader(jobReader, pkgReader, compressor, fs, boshlog.NewLogger(boshlog.LevelNone))
+
+			jobReader.ReadStub = func(jobRef boshman.JobRef, path string) (*boshjob.Job, error) {
+				return boshjob.NewJob(NewResource(jobRef.Name, jobRef.Fingerprint, nil)), nil
+			}
+
+			pkgReader.ReadStub = func(pkgRef boshman.PackageRef, path string) (*boshpkg.Package, error) {
+				return boshpkg.NewPackage(NewResource(pkgRef.Name, pkgRef.Fingerprint, nil), nil), nil
+			}
+		})
+
+		It("writes only the archives referred to by the release manifest", func() {
+			writeTarball(
+				"./jobs/job-before-manifest.tgz", "job-before-manifest-archive",
+				"./release.MF", manifest,
+				"./jobs/job1.tgz", "job1-archive",
+				"./packages/pkg1.tgz", "pkg1-archive",
+				"./packages/other-pkg.tgz", "other-pkg-archive",
+				"./other-file", "other-file-content",
+			)
+
+			release, err := reader.Read(tarballPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(release.Name()).To(Equal("release"))
+			Expect(release.Jobs()).To(HaveLen(1))
+			Expect(release.Packages()).To(HaveLen(1))
+
+			extractPath := filepath.Join("/", "extracted", "release")
+
+			Expect(fs.ReadFileString(filepath.Join(extractPath, "jobs", "job1.tgz"))).To(Equal("job1-archive"))
+			Expect(fs.ReadFileString(filepath.Join(extractPath, "packages", "pkg1.tgz"))).To(Equal("pkg1-archive"))
+			Expect(fs.FileExists(filepath.Join(extractPath, "jobs", "job-before-manifest.tgz"))).To(BeFalse())
+			Expect(fs.FileExists(filepath.Join(extractPath, "packages", "other-pkg.tgz"))).To(BeFalse())
+			Expect(fs.FileExists(filepath.Join(extractPath, "other-file"))).To(BeFalse())
+
+			Expect(compressor.DecompressFileToDirTarballPaths).To(BeEmpty())
+		})
+
+		It("returns an error and deletes what was written when there is no release manifest", func() {
+			writeTarball("./jobs/job1.tgz", "job1-archive")
+
+			_, err := reader.Read(tarballPath)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("release.MF not found"))
+
+			Expect(fs.FileExists(filepath.Join("/", "extracted", "release"))).To(BeFalse())
+		})
+
+		It("returns an error when the release is not a gzipped tarball", func() {
+			Expect(fs.WriteFileString(tarballPath, "not-a-tarball")).To(Succeed())
+
+			_, err := reader.Read(tarballPath)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Extracting release"))
+		})
+	})
 })
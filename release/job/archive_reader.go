@@ -71,10 +71,19 @@ func (r ArchiveReaderImpl) Read(ref boshman.JobRef, path string) (*Job, error) {
 			properties[propertyName] = PropertyDefinition{
 				Description: rawPropertyDef.Description,
 				Default:     defaultValue,
+				Type:        rawPropertyDef.Type,
 			}
 		}
 
 		job.Properties = properties
+
+		for _, link := range manifest.Consumes {
+			job.Consumes = append(job.Consumes, LinkDefinition(link))
+		}
+
+		for _, link := range manifest.Provides {
+			job.Provides = append(job.Provides, LinkDefinition(link))
+		}
 	}
 
 	return job, nil
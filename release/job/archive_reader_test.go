@@ -49,6 +49,11 @@ properties:
   prop:
     description: prop-desc
     default: prop-default
+    type: string
+consumes:
+- {name: db, type: database, optional: true}
+provides:
+- {name: web, type: http, properties: [port]}
 `)
 
 			job, err := reader.Read(ref, "archive-path")
@@ -65,9 +70,13 @@ properties:
 				"prop": PropertyDefinition{
 					Description: "prop-desc",
 					Default:     biproperty.Property("prop-default"),
+					Type:        "string",
 				},
 			}))
 
+			Expect(job.Consumes).To(Equal([]LinkDefinition{{Name: "db", Type: "database", Optional: true}}))
+			Expect(job.Provides).To(Equal([]LinkDefinition{{Name: "web", Type: "http", Properties: []string{"port"}}}))
+
 			Expect(job.ExtractedPath()).To(Equal("/extracted/job"))
 
 			Expect(compressor.DecompressFileToDirTarballPaths).To(Equal([]string{"archive-path"}))
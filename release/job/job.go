@@ -1,6 +1,8 @@
 package job
 
 import (
+	"path/filepath"
+
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	biproperty "github.com/cloudfoundry/bosh-utils/property"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -24,6 +26,8 @@ type Job struct {
 	PackageNames []string
 	Packages     []boshpkg.Compilable
 	Properties   map[string]PropertyDefinition
+	Consumes     []LinkDefinition
+	Provides     []LinkDefinition
 
 	extractedPath string
 	fs            boshsys.FileSystem
@@ -32,6 +36,19 @@ type Job struct {
 type PropertyDefinition struct {
 	Description string
 	Default     biproperty.Property
+
+	// Type is the optional type of the property, e.g. 'string' or 'certificate'
+	Type string
+}
+
+type LinkDefinition struct {
+	Name     string
+	Type     string
+	Optional bool
+
+	// Properties are the names of the job properties shared with consumers of
+	// a provided link
+	Properties []string
 }
 
 func NewJob(resource Resource) *Job {
@@ -60,6 +77,15 @@ func (j Job) FindTemplateByValue(value string) (string, bool) {
 	return "", false
 }
 
+// ReadTemplate returns the source of a template from the extracted job
+func (j Job) ReadTemplate(template string) (string, error) {
+	if j.fs == nil || j.extractedPath == "" {
+		return "", bosherr.Errorf("Job '%s' has not been extracted", j.Name())
+	}
+
+	return j.fs.ReadFileString(filepath.Join(j.extractedPath, "templates", template))
+}
+
 // AttachPackages is left for testing convenience
 func (j *Job) AttachPackages(packages []*boshpkg.Package) error {
 	var coms []boshpkg.Compilable
@@ -80,6 +106,8 @@ func (j *Job) RehashWithCalculator(calculator crypto.DigestCalculator, archiveFi
 		PackageNames: j.PackageNames,
 		Packages:     j.Packages,
 		Properties:   j.Properties,
+		Consumes:     j.Consumes,
+		Provides:     j.Provides,
 
 		extractedPath: j.extractedPath,
 		fs:            j.fs,
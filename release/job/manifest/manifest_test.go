@@ -37,6 +37,17 @@ properties:
   prop1.prop2:
     description: prop2-desc
     default: prop2-default
+
+consumes:
+- name: db
+  type: database
+- name: cache
+  type: redis
+  optional: true
+
+provides:
+- name: web
+  type: http
 `
 
 		fs.WriteFileString("/path", contents)
@@ -60,6 +71,15 @@ properties:
 					Default:     "prop2-default",
 				},
 			},
+
+			Consumes: []LinkDefinition{
+				{Name: "db", Type: "database"},
+				{Name: "cache", Type: "redis", Optional: true},
+			},
+
+			Provides: []LinkDefinition{
+				{Name: "web", Type: "http"},
+			},
 		}))
 	})
 
@@ -5,14 +5,25 @@ import (
 )
 
 type ReleaseRef struct {
-	Name string
-	URL  string
-	SHA1 string
+	Name      string
+	URL       string
+	SHA1      string
+	Signature SignatureRef
+}
+
+// SignatureRef points at a detached PGP signature of a tarball and holds
+// the ASCII armored public key the signature must be made with.
+type SignatureRef struct {
+	URL       string `yaml:"url"`
+	PublicKey string `yaml:"public_key"`
 }
 
 func (r ReleaseRef) GetURL() string  { return r.URL }
 func (r ReleaseRef) GetSHA1() string { return r.SHA1 }
 
+func (r ReleaseRef) GetSignatureURL() string       { return r.Signature.URL }
+func (r ReleaseRef) GetSignaturePublicKey() string { return r.Signature.PublicKey }
+
 func (r ReleaseRef) Description() string {
 	return fmt.Sprintf("release '%s'", r.Name)
 }
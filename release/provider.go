@@ -55,6 +55,14 @@ func (p Provider) NewMultiReader(dirPath string) MultiReader {
 func (p Provider) NewExtractingArchiveReader() ArchiveReader { return p.archiveReader(true) }
 func (p Provider) NewArchiveReader() ArchiveReader           { return p.archiveReader(false) }
 
+// NewStreamingArchiveReader extracts jobs like NewExtractingArchiveReader,
+// without ever extracting the whole release tarball.
+func (p Provider) NewStreamingArchiveReader() ArchiveReader {
+	jobReader := boshjob.NewArchiveReaderImpl(true, p.compressor, p.fs)
+	pkgReader := boshpkg.NewArchiveReaderImpl(true, p.compressor, p.fs)
+	return NewStreamingArchiveReader(jobReader, pkgReader, p.compressor, p.fs, p.logger)
+}
+
 func (p Provider) archiveReader(extracting bool) ArchiveReader {
 	jobReader := boshjob.NewArchiveReaderImpl(extracting, p.compressor, p.fs)
 	pkgReader := boshpkg.NewArchiveReaderImpl(extracting, p.compressor, p.fs)
@@ -8,6 +8,7 @@ import (
 	"gopkg.in/yaml.v2"
 
 	biutil "github.com/cloudfoundry/bosh-cli/common/util"
+	bidepltpl "github.com/cloudfoundry/bosh-cli/deployment/template"
 	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
 	birelmanifest "github.com/cloudfoundry/bosh-cli/release/manifest"
 )
@@ -17,8 +18,9 @@ type Parser interface {
 }
 
 type parser struct {
-	fs        boshsys.FileSystem
-	validator Validator
+	fs              boshsys.FileSystem
+	templateFactory bidepltpl.DeploymentTemplateFactory
+	validator       Validator
 
 	logTag string
 	logger boshlog.Logger
@@ -29,9 +31,16 @@ type manifest struct {
 }
 
 func NewParser(fs boshsys.FileSystem, logger boshlog.Logger, validator Validator) Parser {
+	return NewTemplateParser(fs, bidepltpl.NewDeploymentTemplateFactory(fs), logger, validator)
+}
+
+// NewTemplateParser returns a parser reading manifests through the template
+// factory, e.g. to include the releases merged in from a runtime config.
+func NewTemplateParser(fs boshsys.FileSystem, templateFactory bidepltpl.DeploymentTemplateFactory, logger boshlog.Logger, validator Validator) Parser {
 	return &parser{
-		fs:        fs,
-		validator: validator,
+		fs:              fs,
+		templateFactory: templateFactory,
+		validator:       validator,
 
 		logTag: "releaseSetParser",
 		logger: logger,
@@ -39,18 +48,18 @@ func NewParser(fs boshsys.FileSystem, logger boshlog.Logger, validator Validator
 }
 
 func (p *parser) Parse(path string, vars boshtpl.Variables, op patch.Op) (Manifest, error) {
-	contents, err := p.fs.ReadFile(path)
+	tpl, err := p.templateFactory.NewDeploymentTemplateFromPath(path)
 	if err != nil {
-		return Manifest{}, bosherr.WrapErrorf(err, "Reading file %s", path)
+		return Manifest{}, err
 	}
 
-	tpl := boshtpl.NewTemplate(contents)
-
-	bytes, err := tpl.Evaluate(vars, op, boshtpl.EvaluateOpts{ExpectAllKeys: true})
+	interpolatedTemplate, err := tpl.Evaluate(vars, op)
 	if err != nil {
 		return Manifest{}, bosherr.WrapErrorf(err, "Evaluating manifest")
 	}
 
+	bytes := interpolatedTemplate.Content()
+
 	comboManifest := manifest{}
 
 	err = yaml.Unmarshal(bytes, &comboManifest)
@@ -65,6 +74,13 @@ func (p *parser) Parse(path string, vars boshtpl.Variables, op patch.Op) (Manife
 		if err != nil {
 			return Manifest{}, bosherr.WrapErrorf(err, "Resolving release path '%s", releaseRef.URL)
 		}
+
+		if releaseRef.Signature.URL != "" {
+			comboManifest.Releases[i].Signature.URL, err = biutil.AbsolutifyPath(path, releaseRef.Signature.URL, p.fs)
+			if err != nil {
+				return Manifest{}, bosherr.WrapErrorf(err, "Resolving release signature path '%s", releaseRef.Signature.URL)
+			}
+		}
 	}
 
 	releaseSetManifest := Manifest{
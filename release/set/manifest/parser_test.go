@@ -9,6 +9,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	bidepltpl "github.com/cloudfoundry/bosh-cli/deployment/template"
 	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
 	boshman "github.com/cloudfoundry/bosh-cli/release/manifest"
 	"github.com/cloudfoundry/bosh-cli/release/set/manifest"
@@ -222,6 +223,33 @@ releases:
 		}))
 	})
 
+	It("parses release signatures and expands their paths to be relative to the manifest path", func() {
+		fs.WriteFileString(comboManifestPath, `---
+releases:
+- name: release-name
+  url: https://fake-release-url
+  sha1: sha256:release-sha256
+  signature:
+    url: file://release.tgz.asc
+    public_key: fake-public-key
+`)
+
+		deploymentManifest, err := parser.Parse(comboManifestPath, boshtpl.StaticVariables{}, patch.Ops{})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(deploymentManifest.Releases).To(Equal([]boshman.ReleaseRef{
+			{
+				Name: "release-name",
+				URL:  "https://fake-release-url",
+				SHA1: "sha256:release-sha256",
+				Signature: boshman.SignatureRef{
+					URL:       "file:///path/to/manifest/release.tgz.asc",
+					PublicKey: "fake-public-key",
+				},
+			},
+		}))
+	})
+
 	It("returns an error if variable key is missing", func() {
 		fs.WriteFileString(comboManifestPath, `---
 releases:
@@ -247,4 +275,31 @@ releases:
 		Expect(err).To(HaveOccurred())
 		Expect(err.Error()).To(Equal("Validating release set manifest: couldn't validate that"))
 	})
+
+	It("parses the releases of the templates of the template factory", func() {
+		fs.WriteFileString("/path/to/runtime-config.yml", `---
+releases:
+- name: fake-release-name-1
+  url: http://other-url/fake-release-1.tgz
+- name: fake-runtime-release-name
+  url: http://fake-url/fake-runtime-release.tgz
+`)
+
+		templateFactory := bidepltpl.NewRuntimeConfigTemplateFactory(fs, bidepltpl.NewDeploymentTemplateFactory(fs), "/path/to/runtime-config.yml")
+		parser = manifest.NewTemplateParser(fs, templateFactory, boshlog.NewLogger(boshlog.LevelNone), validator)
+
+		ops := patch.Ops{
+			patch.ReplaceOp{Path: patch.MustNewPointerFromString("/releases/name=fake-runtime-release-name/sha1?"), Value: "fake-runtime-sha1"},
+		}
+
+		releaseSetManifest, err := parser.Parse(comboManifestPath, boshtpl.StaticVariables{}, ops)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(releaseSetManifest.Releases).To(HaveLen(5))
+		Expect(releaseSetManifest.Releases[0].URL).To(Equal("file://~/absolute-path/fake-release-1.tgz"))
+		Expect(releaseSetManifest.Releases[4]).To(Equal(boshman.ReleaseRef{
+			Name: "fake-runtime-release-name",
+			URL:  "http://fake-url/fake-runtime-release.tgz",
+			SHA1: "fake-runtime-sha1",
+		}))
+	})
 })
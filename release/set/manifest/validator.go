@@ -6,6 +6,8 @@ import (
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+
+	biutil "github.com/cloudfoundry/bosh-cli/common/util"
 )
 
 type Validator interface {
@@ -41,14 +43,29 @@ func (v *validator) Validate(manifest Manifest) error {
 			errs = append(errs, bosherr.Errorf("releases[%d].url must be provided", releaseIdx))
 		}
 
-		matched, err := regexp.MatchString("^(file|http|https)://", release.URL)
+		matched, err := regexp.MatchString("^(file|http|https|s3|gs)://", release.URL)
 		if err != nil || !matched {
-			errs = append(errs, bosherr.Errorf("releases[%d].url must be a valid URL (file:// or http(s)://)", releaseIdx))
+			errs = append(errs, bosherr.Errorf("releases[%d].url must be a valid URL (file://, http(s)://, s3:// or gs://)", releaseIdx))
 		}
 
 		if strings.HasPrefix(release.URL, "http") && v.isBlank(release.SHA1) {
 			errs = append(errs, bosherr.Errorf("releases[%d].sha1 must be provided for http URL", releaseIdx))
 		}
+
+		if biutil.IsObjectStoreURL(release.URL) && v.isBlank(release.SHA1) {
+			errs = append(errs, bosherr.Errorf("releases[%d].sha1 must be provided for s3 and gs URLs", releaseIdx))
+		}
+
+		if !v.isBlank(release.Signature.URL) {
+			matched, err := regexp.MatchString("^(file|http|https)://", release.Signature.URL)
+			if err != nil || !matched {
+				errs = append(errs, bosherr.Errorf("releases[%d].signature.url must be a valid URL (file:// or http(s)://)", releaseIdx))
+			}
+
+			if v.isBlank(release.Signature.PublicKey) {
+				errs = append(errs, bosherr.Errorf("releases[%d].signature.public_key must be provided", releaseIdx))
+			}
+		}
 	}
 
 	if len(errs) > 0 {
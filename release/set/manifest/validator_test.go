@@ -113,7 +113,39 @@ var _ = Describe("Validator", func() {
 
 			err := validator.Validate(manifest)
 			Expect(err).To(HaveOccurred())
-			Expect(err.Error()).To(ContainSubstring("releases[0].url must be a valid URL (file:// or http(s)://)"))
+			Expect(err.Error()).To(ContainSubstring("releases[0].url must be a valid URL (file://, http(s)://, s3:// or gs://)"))
+		})
+
+		It("validates releases with s3 and gs urls have sha1", func() {
+			manifest := Manifest{
+				Releases: []boshman.ReleaseRef{
+					{Name: "fake-release-name", URL: "s3://fake-bucket/fake-release.tgz"},
+					{Name: "fake-other-release-name", URL: "gs://fake-bucket/fake-release.tgz"},
+				},
+			}
+
+			err := validator.Validate(manifest)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).ToNot(ContainSubstring("must be a valid URL"))
+			Expect(err.Error()).To(ContainSubstring("releases[0].sha1 must be provided for s3 and gs URLs"))
+			Expect(err.Error()).To(ContainSubstring("releases[1].sha1 must be provided for s3 and gs URLs"))
+		})
+
+		It("validates release signatures have a valid url and a public key", func() {
+			manifest := Manifest{
+				Releases: []boshman.ReleaseRef{
+					{
+						Name:      "fake-release-name",
+						URL:       "file://fake-file",
+						Signature: boshman.SignatureRef{URL: "invalid-url"},
+					},
+				},
+			}
+
+			err := validator.Validate(manifest)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("releases[0].signature.url must be a valid URL (file:// or http(s)://)"))
+			Expect(err.Error()).To(ContainSubstring("releases[0].signature.public_key must be provided"))
 		})
 
 		It("validates releases are unique", func() {
@@ -129,4 +161,5 @@ var _ = Describe("Validator", func() {
 			Expect(err.Error()).To(ContainSubstring("releases[1].name 'fake-release-name' must be unique"))
 		})
 	})
+
 })
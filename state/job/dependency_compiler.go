@@ -3,6 +3,7 @@ package job
 import (
 	"fmt"
 	"strings"
+	"sync"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -26,14 +27,27 @@ type DependencyCompiler interface {
 
 type dependencyCompiler struct {
 	packageCompiler bistatepkg.Compiler
+	workers         int
 
 	logTag string
 	logger boshlog.Logger
 }
 
 func NewDependencyCompiler(packageCompiler bistatepkg.Compiler, logger boshlog.Logger) DependencyCompiler {
+	return NewParallelDependencyCompiler(packageCompiler, 1, logger)
+}
+
+// NewParallelDependencyCompiler returns a compiler that compiles up to workers
+// packages concurrently. A package is only compiled once all of its
+// dependencies have been compiled.
+func NewParallelDependencyCompiler(packageCompiler bistatepkg.Compiler, workers int, logger boshlog.Logger) DependencyCompiler {
+	if workers < 1 {
+		workers = 1
+	}
+
 	return &dependencyCompiler{
 		packageCompiler: packageCompiler,
+		workers:         workers,
 
 		logTag: "dependencyCompiler",
 		logger: logger,
@@ -104,39 +118,178 @@ func (c *dependencyCompiler) resolvePackageDependencies(releasePackage birelpkg.
 	}
 }
 
-// compilePackages compiles the specified packages, in the order specified, uploads them to the Blobstore, and returns the blob references
+// compilePackages compiles the specified packages, in the order specified, uploads them to the Blobstore, and returns the blob references.
+// The packages compiled out of the total are reported while compiling, and
+// each package is reported once all of them have been compiled.
 func (c *dependencyCompiler) compilePackages(requiredPackages []birelpkg.Compilable, stage biui.Stage) ([]CompiledPackageRef, error) {
-	packageRefs := make([]CompiledPackageRef, 0, len(requiredPackages))
+	if len(requiredPackages) == 0 {
+		return []CompiledPackageRef{}, nil
+	}
 
-	for _, pkg := range requiredPackages {
-		stepName := fmt.Sprintf("Compiling package '%s/%s'", pkg.Name(), pkg.Fingerprint())
+	var results []compilePackageResult
+	err := stage.PerformWithProgress("Compiling packages", func(progress biui.Progress) error {
+		progress.SetTotal(int64(len(requiredPackages)))
 
-		err := stage.Perform(stepName, func() error {
-			compiledPackageRecord, isAlreadyCompiled, err := c.packageCompiler.Compile(pkg)
-			if err != nil {
-				return err
+		results = c.compilePackagesInOrder(requiredPackages, progress)
+
+		for _, result := range results {
+			if result.err != nil {
+				return bosherr.Errorf("Compiling package '%s'", requiredPackages[result.index].Name())
 			}
+		}
+
+		return nil
+	})
+	if err != nil && len(results) == 0 {
+		return nil, err
+	}
+
+	compiled := make([]bool, len(requiredPackages))
+	packageRefs := make([]CompiledPackageRef, len(requiredPackages))
+	errs := []error{}
+
+	for _, result := range results {
+		result := result
+		pkg := requiredPackages[result.index]
+
+		stepName := fmt.Sprintf("Compiling package '%s/%s'", pkg.Name(), pkg.Fingerprint())
 
-			packageRef := CompiledPackageRef{
-				Name:        pkg.Name(),
-				Version:     pkg.Fingerprint(),
-				BlobstoreID: compiledPackageRecord.BlobID,
-				SHA1:        compiledPackageRecord.BlobSHA1,
+		err := stage.Perform(stepName, func() error {
+			if result.err != nil {
+				return result.err
 			}
-			packageRefs = append(packageRefs, packageRef)
 
-			if isAlreadyCompiled {
+			if result.isAlreadyCompiled {
 				return biui.NewSkipStageError(bosherr.Error(fmt.Sprintf("Package '%s' is already compiled. Skipped compilation", pkg.Name())), "Package already compiled")
 			}
 
 			return nil
 		})
 		if err != nil {
-			return nil, err
+			errs = append(errs, err)
+			continue
+		}
+
+		compiled[result.index] = true
+		packageRefs[result.index] = result.packageRef
+	}
+
+	if len(errs) > 0 {
+		return nil, bosherr.NewMultiError(errs...)
+	}
+
+	if len(results) < len(requiredPackages) {
+		return nil, bosherr.Error("Expected all package dependencies to be compiled")
+	}
+
+	compiledPackageRefs := make([]CompiledPackageRef, 0, len(requiredPackages))
+	for i, packageRef := range packageRefs {
+		if compiled[i] {
+			compiledPackageRefs = append(compiledPackageRefs, packageRef)
+		}
+	}
+
+	return compiledPackageRefs, nil
+}
+
+type compilePackageResult struct {
+	index             int
+	packageRef        CompiledPackageRef
+	isAlreadyCompiled bool
+	err               error
+}
+
+// compilePackagesInOrder schedules the packages as a dependency graph: each
+// package is handed to a worker as soon as the packages it depends on have
+// been compiled. No further packages are scheduled after a failure. It
+// returns the results in the order the packages were compiled.
+func (c *dependencyCompiler) compilePackagesInOrder(requiredPackages []birelpkg.Compilable, progress biui.Progress) []compilePackageResult {
+	indexByKey := map[string]int{}
+	for i, pkg := range requiredPackages {
+		indexByKey[c.pkgKey(pkg)] = i
+	}
+
+	pendingDeps := make([]int, len(requiredPackages))
+	dependents := make([][]int, len(requiredPackages))
+
+	for i, pkg := range requiredPackages {
+		for _, dep := range pkg.Deps() {
+			if j, found := indexByKey[c.pkgKey(dep)]; found && j != i {
+				pendingDeps[i]++
+				dependents[j] = append(dependents[j], i)
+			}
+		}
+	}
+
+	readyCh := make(chan int, len(requiredPackages))
+	resultCh := make(chan compilePackageResult)
+	wg := &sync.WaitGroup{}
+
+	for w := 0; w < c.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range readyCh {
+				resultCh <- c.compilePackage(i, requiredPackages[i])
+			}
+		}()
+	}
+
+	scheduled := 0
+	for i := range requiredPackages {
+		if pendingDeps[i] == 0 {
+			readyCh <- i
+			scheduled++
+		}
+	}
+
+	results := []compilePackageResult{}
+	failed := false
+
+	for completed := 0; completed < scheduled; completed++ {
+		result := <-resultCh
+		results = append(results, result)
+
+		if result.err != nil {
+			failed = true
+			continue
+		}
+
+		progress.Add(1)
+
+		if failed {
+			continue
+		}
+
+		for _, j := range dependents[result.index] {
+			pendingDeps[j]--
+			if pendingDeps[j] == 0 {
+				readyCh <- j
+				scheduled++
+			}
 		}
 	}
 
-	return packageRefs, nil
+	close(readyCh)
+	wg.Wait()
+
+	return results
+}
+
+func (c *dependencyCompiler) compilePackage(index int, pkg birelpkg.Compilable) compilePackageResult {
+	compiledPackageRecord, isAlreadyCompiled, err := c.packageCompiler.Compile(pkg)
+
+	return compilePackageResult{
+		index: index,
+		packageRef: CompiledPackageRef{
+			Name:        pkg.Name(),
+			Version:     pkg.Fingerprint(),
+			BlobstoreID: compiledPackageRecord.BlobID,
+			SHA1:        compiledPackageRecord.BlobSHA1,
+		},
+		isAlreadyCompiled: isAlreadyCompiled,
+		err:               err,
+	}
 }
 
 func (c *dependencyCompiler) pkgKey(pkg birelpkg.Compilable) string { return pkg.Name() }
@@ -1,6 +1,8 @@
 package job_test
 
 import (
+	"errors"
+
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
@@ -109,6 +111,7 @@ var _ = Describe("DependencyCompiler", func() {
 		Expect(err).ToNot(HaveOccurred())
 
 		Expect(stage.PerformCalls).To(Equal([]*fakeui.PerformCall{
+			{Name: "Compiling packages", Progress: &fakeui.FakeProgress{Total: 2, Done: 2}},
 			{Name: "Compiling package 'pkg1-name/pkg1-fp'"},
 			{Name: "Compiling package 'pkg2-name/pkg2-fp'"},
 		}))
@@ -158,7 +161,8 @@ var _ = Describe("DependencyCompiler", func() {
 			_, err := dependencyCompiler.Compile(jobs, stage)
 			Expect(err).ToNot(HaveOccurred())
 
-			for _, call := range stage.PerformCalls {
+			Expect(stage.PerformCalls[0].Name).To(Equal("Compiling packages"))
+			for _, call := range stage.PerformCalls[1:] {
 				Expect(call.SkipError).To(HaveOccurred())
 				Expect(call.SkipError.Error()).To(MatchRegexp("Package already compiled: Package 'pkg\\d-name' is already compiled. Skipped compilation"))
 			}
@@ -215,4 +219,71 @@ var _ = Describe("DependencyCompiler", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 	})
+
+	Context("when compiling with multiple workers", func() {
+		var (
+			pkg3, pkg4 *boshrelpkg.Package
+		)
+
+		BeforeEach(func() {
+			dependencyCompiler = NewParallelDependencyCompiler(mockPackageCompiler, 2, logger)
+
+			pkg3 = newPkg("pkg3-name", "pkg3-fp", nil)
+			pkg4 = newPkg("pkg4-name", "pkg4-fp", []string{"pkg3-name"})
+			pkg4.AttachDependencies([]*boshrelpkg.Package{pkg3})
+		})
+
+		It("compiles all the job dependencies (packages) such that no package is compiled before its dependencies", func() {
+			gomock.InOrder(
+				expectCompilePkg1.Times(1),
+				expectCompilePkg2.Times(1),
+			)
+
+			_, err := dependencyCompiler.Compile(jobs, stage)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns references to the compiled packages in dependency order", func() {
+			compiledPackageRefs, err := dependencyCompiler.Compile(jobs, stage)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(compiledPackageRefs).To(Equal([]CompiledPackageRef{
+				{
+					Name:        "pkg1-name",
+					Version:     "pkg1-fp",
+					BlobstoreID: "fake-compiled-package-blobstore-id-1",
+					SHA1:        "fake-compiled-package-sha1-1",
+				},
+				{
+					Name:        "pkg2-name",
+					Version:     "pkg2-fp",
+					BlobstoreID: "fake-compiled-package-blobstore-id-2",
+					SHA1:        "fake-compiled-package-sha1-2",
+				},
+			}))
+		})
+
+		Context("when a package fails to compile", func() {
+			BeforeEach(func() {
+				job.PackageNames = append(job.PackageNames, pkg4.Name())
+				job.AttachPackages([]*boshrelpkg.Package{pkg2, pkg4})
+				jobs = []boshreljob.Job{*job}
+			})
+
+			JustBeforeEach(func() {
+				mockPackageCompiler.EXPECT().Compile(pkg3).Return(bistatepkg.CompiledPackageRecord{}, false, errors.New("fake-compile-error")).Times(1)
+			})
+
+			It("returns an error and does not compile the packages depending on it", func() {
+				mockPackageCompiler.EXPECT().Compile(pkg4).Times(0)
+
+				_, err := dependencyCompiler.Compile(jobs, stage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-compile-error"))
+
+				Expect(stage.PerformCalls[0].Name).To(Equal("Compiling packages"))
+				Expect(stage.PerformCalls[0].Error).To(MatchError("Compiling package 'pkg3-name'"))
+			})
+		})
+	})
 })
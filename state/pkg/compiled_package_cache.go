@@ -0,0 +1,87 @@
+package pkg
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshfu "github.com/cloudfoundry/bosh-utils/fileutil"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+
+	birelpkg "github.com/cloudfoundry/bosh-cli/release/pkg"
+)
+
+// CompiledPackageCache keeps compiled package tarballs on the workstation so
+// that they can be reused by any deployment using the same stemcell.
+// Entries are keyed by package name, fingerprint and stemcell.
+type CompiledPackageCache interface {
+	Get(pkg birelpkg.Compilable, stemcell string) (path string, sha1 string, found bool)
+	Save(sourcePath string, sha1 string, pkg birelpkg.Compilable, stemcell string) error
+}
+
+type compiledPackageCache struct {
+	basePath string
+	fs       boshsys.FileSystem
+	logger   boshlog.Logger
+	logTag   string
+}
+
+func NewCompiledPackageCache(basePath string, fs boshsys.FileSystem, logger boshlog.Logger) CompiledPackageCache {
+	return &compiledPackageCache{
+		basePath: basePath,
+		fs:       fs,
+		logger:   logger,
+		logTag:   "compiledPackageCache",
+	}
+}
+
+func (c *compiledPackageCache) Get(pkg birelpkg.Compilable, stemcell string) (string, string, bool) {
+	cachedPath := c.path(pkg, stemcell)
+
+	if !c.fs.FileExists(cachedPath) {
+		return "", "", false
+	}
+
+	sha1, err := c.fs.ReadFileString(cachedPath + ".sha1")
+	if err != nil {
+		c.logger.Warn(c.logTag, "Ignoring cached compiled package at '%s' without digest: %s", cachedPath, err.Error())
+		return "", "", false
+	}
+
+	c.logger.Debug(c.logTag, "Found cached compiled package at: '%s'", cachedPath)
+
+	return cachedPath, strings.TrimSpace(sha1), true
+}
+
+func (c *compiledPackageCache) Save(sourcePath string, sha1 string, pkg birelpkg.Compilable, stemcell string) error {
+	err := c.fs.MkdirAll(c.basePath, os.FileMode(0755))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Failed to create cache directory '%s'", c.basePath)
+	}
+
+	cachedPath := c.path(pkg, stemcell)
+
+	err = boshfu.NewFileMover(c.fs).Move(sourcePath, cachedPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Failed to save compiled package '%s' in cache", sourcePath)
+	}
+
+	// The digest is written last so that Get never returns a partial entry
+	err = c.fs.WriteFileString(cachedPath+".sha1", sha1)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Failed to save compiled package digest in cache")
+	}
+
+	c.logger.Debug(c.logTag, "Saving compiled package in cache at: '%s'", cachedPath)
+
+	return nil
+}
+
+func (c *compiledPackageCache) path(pkg birelpkg.Compilable, stemcell string) string {
+	keySHA1 := sha1.Sum([]byte(fmt.Sprintf("%s/%s/%s", pkg.Name(), pkg.Fingerprint(), stemcell)))
+	return filepath.Join(c.basePath, fmt.Sprintf("%x", keySHA1[:]))
+}
@@ -0,0 +1,79 @@
+package pkg_test
+
+import (
+	"errors"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/state/pkg"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("CompiledPackageCache", func() {
+	var (
+		cache CompiledPackageCache
+		fs    *fakesys.FakeFileSystem
+	)
+
+	BeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		fs = fakesys.NewFakeFileSystem()
+		cache = NewCompiledPackageCache("/fake-base-path", fs, logger)
+	})
+
+	It("is a cache hit when the package has been compiled for the stemcell", func() {
+		fs.WriteFileString("source-path", "compiled")
+
+		err := cache.Save("source-path", "compiled-sha1", newPkg("pkg-name", "pkg-fp", nil), "stemcell-name/1")
+		Expect(err).ToNot(HaveOccurred())
+
+		path, sha1, found := cache.Get(newPkg("pkg-name", "pkg-fp", nil), "stemcell-name/1")
+		Expect(found).To(BeTrue())
+		Expect(sha1).To(Equal("compiled-sha1"))
+
+		contents, err := fs.ReadFileString(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(contents).To(Equal("compiled"))
+	})
+
+	It("creates the cache directory with mode 0755", func() {
+		fs.WriteFileString("source-path", "compiled")
+
+		err := cache.Save("source-path", "compiled-sha1", newPkg("pkg-name", "pkg-fp", nil), "stemcell-name/1")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(fs.GetFileTestStat("/fake-base-path").FileMode).To(Equal(os.FileMode(0755)))
+	})
+
+	It("is a cache miss when the package has been compiled for another stemcell", func() {
+		fs.WriteFileString("source-path", "compiled")
+
+		err := cache.Save("source-path", "compiled-sha1", newPkg("pkg-name", "pkg-fp", nil), "stemcell-name/1")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, _, found := cache.Get(newPkg("pkg-name", "pkg-fp", nil), "stemcell-name/2")
+		Expect(found).To(BeFalse())
+	})
+
+	It("is a cache miss when the package fingerprint has changed", func() {
+		fs.WriteFileString("source-path", "compiled")
+
+		err := cache.Save("source-path", "compiled-sha1", newPkg("pkg-name", "pkg-fp", nil), "stemcell-name/1")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, _, found := cache.Get(newPkg("pkg-name", "other-pkg-fp", nil), "stemcell-name/1")
+		Expect(found).To(BeFalse())
+	})
+
+	It("returns an error when moving the compiled package fails", func() {
+		fs.WriteFileString("source-path", "compiled")
+		fs.RenameError = errors.New("fake-rename-error")
+
+		err := cache.Save("source-path", "compiled-sha1", newPkg("pkg-name", "pkg-fp", nil), "stemcell-name/1")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Failed to save compiled package 'source-path' in cache"))
+	})
+})
@@ -13,27 +13,51 @@ type Fetcher struct {
 }
 
 func (s Fetcher) GetStemcell(deploymentManifest bideplmanifest.Manifest, stage biui.Stage) (ExtractedStemcell, error) {
-	stemcell, err := deploymentManifest.Stemcell(deploymentManifest.JobName())
+	stemcellTarballPath, err := s.GetTarball(deploymentManifest, stage)
 	if err != nil {
 		return nil, err
 	}
 
-	stemcellTarballPath, err := s.TarballProvider.Get(stemcell, stage)
+	var extractedStemcell ExtractedStemcell
+	err = stage.Perform("Validating stemcell", func() error {
+		extractedStemcell, err = s.Extract(stemcellTarballPath)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	var extractedStemcell ExtractedStemcell
-	err = stage.Perform("Validating stemcell", func() error {
-		extractedStemcell, err = s.StemcellExtractor.Extract(stemcellTarballPath)
-		if err != nil {
-			return bosherr.WrapErrorf(err, "Extracting stemcell from '%s'", stemcellTarballPath)
-		}
+	return extractedStemcell, nil
+}
 
-		return nil
-	})
+// GetTarball returns the local path of the stemcell tarball of the service
+// job, downloading it first when it is not a local file.
+func (s Fetcher) GetTarball(deploymentManifest bideplmanifest.Manifest, stage biui.Stage) (string, error) {
+	stemcell, err := deploymentManifest.Stemcell(deploymentManifest.JobName())
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+
+	return s.TarballProvider.Get(stemcell, stage)
+}
+
+// VerifyTarball checks the stemcell tarball against the digest of the
+// stemcell of the service job.
+func (s Fetcher) VerifyTarball(deploymentManifest bideplmanifest.Manifest, stemcellTarballPath string) error {
+	stemcell, err := deploymentManifest.Stemcell(deploymentManifest.JobName())
+	if err != nil {
+		return err
+	}
+
+	return s.TarballProvider.Verify(stemcell, stemcellTarballPath)
+}
+
+// Extract extracts the stemcell tarball without reporting a stage, so that
+// it can run while other stages are performed.
+func (s Fetcher) Extract(stemcellTarballPath string) (ExtractedStemcell, error) {
+	extractedStemcell, err := s.StemcellExtractor.Extract(stemcellTarballPath)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Extracting stemcell from '%s'", stemcellTarballPath)
 	}
 
 	return extractedStemcell, nil
@@ -0,0 +1,58 @@
+package stemcell
+
+import (
+	"os"
+	"time"
+
+	biui "github.com/cloudfoundry/bosh-cli/ui"
+)
+
+const imageProgressInterval = 500 * time.Millisecond
+
+// reportImageProgress reports the bytes of the image at imagePath that the
+// CPI has read while uploading it, until the returned func is called. The
+// CPI does not report its progress itself, so the bytes read are taken from
+// the processes that have the image open, on platforms where they can be
+// found (see imageBytesRead). Elsewhere nothing is reported.
+func reportImageProgress(imagePath string, progress biui.Progress) func() {
+	if !imageProgressSupported {
+		return func() {}
+	}
+
+	imageInfo, err := os.Stat(imagePath)
+	if err != nil {
+		return func() {}
+	}
+
+	progress.SetTotal(imageInfo.Size())
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(imageProgressInterval)
+		defer ticker.Stop()
+
+		var reported int64
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			read := imageBytesRead(imagePath)
+			if read > reported {
+				progress.Add(read - reported)
+				reported = read
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
@@ -0,0 +1,69 @@
+package stemcell
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const imageProgressSupported = true
+
+// imageBytesRead returns the largest offset in the image of the processes
+// that have it open, e.g. the CPI or a tool it runs to upload the image,
+// which read the image from start to end.
+func imageBytesRead(imagePath string) int64 {
+	// the links to open files name their target without symlinks
+	imagePath, err := filepath.EvalSymlinks(imagePath)
+	if err != nil {
+		return 0
+	}
+
+	imagePath, err = filepath.Abs(imagePath)
+	if err != nil {
+		return 0
+	}
+
+	fdPaths, err := filepath.Glob("/proc/[0-9]*/fd/*")
+	if err != nil {
+		return 0
+	}
+
+	var read int64
+	for _, fdPath := range fdPaths {
+		target, err := os.Readlink(fdPath)
+		if err != nil || target != imagePath {
+			continue
+		}
+
+		pos, found := fdPosition(strings.Replace(fdPath, "/fd/", "/fdinfo/", 1))
+		if found && pos > read {
+			read = pos
+		}
+	}
+
+	return read
+}
+
+// fdPosition returns the offset of an open file from its /proc fdinfo file.
+func fdPosition(fdInfoPath string) (int64, bool) {
+	fdInfo, err := os.Open(fdInfoPath)
+	if err != nil {
+		return 0, false
+	}
+	defer fdInfo.Close()
+
+	scanner := bufio.NewScanner(fdInfo)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[0] != "pos:" {
+			continue
+		}
+
+		pos, err := strconv.ParseInt(fields[1], 10, 64)
+		return pos, err == nil
+	}
+
+	return 0, false
+}
@@ -0,0 +1,92 @@
+package stemcell_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/cloudfoundry/bosh-cli/stemcell"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	biconfig "github.com/cloudfoundry/bosh-cli/config"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
+
+	fakebicloud "github.com/cloudfoundry/bosh-cli/cloud/fakes"
+	fakebiui "github.com/cloudfoundry/bosh-cli/ui/fakes"
+)
+
+var _ = Describe("Manager on linux", func() {
+	var (
+		stemcellRepo  biconfig.StemcellRepo
+		fs            *fakesys.FakeFileSystem
+		fakeStage     *fakebiui.FakeStage
+		extractedPath string
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		fakeUUIDGenerator := &fakeuuid.FakeGenerator{GeneratedUUID: "fake-stemcell-id-1"}
+		deploymentStateService := biconfig.NewFileSystemDeploymentStateService(fs, fakeUUIDGenerator, logger, "/fake/path")
+		stemcellRepo = biconfig.NewStemcellRepo(deploymentStateService, fakeUUIDGenerator)
+		fakeStage = fakebiui.NewFakeStage()
+
+		var err error
+		extractedPath, err = ioutil.TempDir("", "stemcell-manager-test")
+		Expect(err).ToNot(HaveOccurred())
+
+		err = ioutil.WriteFile(filepath.Join(extractedPath, "image"), make([]byte, 4096), 0644)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(extractedPath)
+	})
+
+	It("reports the bytes of the image read by the CPI while it uploads the image", func() {
+		fakeCloud := fakebicloud.NewFakeCloud()
+		fakeCloud.CreateStemcellCID = "fake-stemcell-cid"
+		manager := NewManager(stemcellRepo, imageReadingCloud{FakeCloud: fakeCloud, read: 1024})
+
+		extractedStemcell := NewExtractedStemcell(
+			Manifest{Name: "fake-stemcell-name", Version: "fake-stemcell-version"},
+			extractedPath,
+			nil,
+			fs,
+		)
+
+		_, err := manager.Upload(extractedStemcell, fakeStage)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(fakeStage.PerformCalls[0].Progress).To(Equal(&fakebiui.FakeProgress{Total: 4096, Done: 1024}))
+	})
+})
+
+// imageReadingCloud reads the given number of bytes of the image, like a CPI
+// uploading it, and keeps the image open until its progress is reported.
+type imageReadingCloud struct {
+	*fakebicloud.FakeCloud
+	read int64
+}
+
+func (c imageReadingCloud) CreateStemcell(imagePath string, cloudProperties biproperty.Map) (string, error) {
+	image, err := os.Open(imagePath)
+	if err != nil {
+		return "", err
+	}
+	defer image.Close()
+
+	_, err = image.Read(make([]byte, c.read))
+	if err != nil {
+		return "", err
+	}
+
+	time.Sleep(time.Second)
+
+	return c.FakeCloud.CreateStemcell(imagePath, cloudProperties)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package stemcell
+
+const imageProgressSupported = false
+
+func imageBytesRead(imagePath string) int64 {
+	return 0
+}
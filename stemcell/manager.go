@@ -45,23 +45,35 @@ func (m *manager) FindCurrent() ([]CloudStemcell, error) {
 }
 
 // Upload stemcell to an IAAS. It does the following steps:
-// 1) uploads the stemcell to the cloud (if needed),
+// 1) uploads the stemcell to the cloud (if needed), reporting its progress
 // 2) saves a record of the uploaded stemcell in the repo
 func (m *manager) Upload(extractedStemcell ExtractedStemcell, uploadStage biui.Stage) (cloudStemcell CloudStemcell, err error) {
 	manifest := extractedStemcell.Manifest()
 	stageName := fmt.Sprintf("Uploading stemcell '%s/%s'", manifest.Name, manifest.Version)
-	err = uploadStage.Perform(stageName, func() error {
+	err = uploadStage.PerformWithProgress(stageName, func(progress biui.Progress) error {
 		foundStemcellRecord, found, err := m.repo.Find(manifest.Name, manifest.Version)
 		if err != nil {
 			return bosherr.WrapError(err, "Finding existing stemcell record in repo")
 		}
 
 		if found {
+			// stemcells uploaded by older CLIs have no api_version recorded
+			if foundStemcellRecord.APIVersion != manifest.APIVersion {
+				foundStemcellRecord, err = m.updateAPIVersion(foundStemcellRecord, manifest.APIVersion)
+				if err != nil {
+					return err
+				}
+			}
+
 			cloudStemcell = NewCloudStemcell(foundStemcellRecord, m.repo, m.cloud)
 			return biui.NewSkipStageError(bosherr.Errorf("Found stemcell: %#v", foundStemcellRecord), "Stemcell already uploaded")
 		}
 
-		cid, err := m.cloud.CreateStemcell(extractedStemcell.GetExtractedPath()+"/image", manifest.CloudProperties)
+		imagePath := extractedStemcell.GetExtractedPath() + "/image"
+
+		stopReporting := reportImageProgress(imagePath, progress)
+		cid, err := m.cloud.CreateStemcell(imagePath, manifest.CloudProperties)
+		stopReporting()
 		if err != nil {
 			return bosherr.WrapErrorf(err, "creating stemcell (%s %s)", manifest.Name, manifest.Version)
 		}
@@ -72,6 +84,13 @@ func (m *manager) Upload(extractedStemcell ExtractedStemcell, uploadStage biui.S
 			return bosherr.WrapErrorf(err, "saving stemcell record in repo (cid=%s, stemcell=%s)", cid, extractedStemcell)
 		}
 
+		if manifest.APIVersion != 0 {
+			stemcellRecord, err = m.updateAPIVersion(stemcellRecord, manifest.APIVersion)
+			if err != nil {
+				return err
+			}
+		}
+
 		cloudStemcell = NewCloudStemcell(stemcellRecord, m.repo, m.cloud)
 		return nil
 	})
@@ -82,6 +101,16 @@ func (m *manager) Upload(extractedStemcell ExtractedStemcell, uploadStage biui.S
 	return cloudStemcell, nil
 }
 
+func (m *manager) updateAPIVersion(stemcellRecord biconfig.StemcellRecord, apiVersion int) (biconfig.StemcellRecord, error) {
+	err := m.repo.UpdateAPIVersion(stemcellRecord.ID, apiVersion)
+	if err != nil {
+		return stemcellRecord, bosherr.WrapErrorf(err, "Recording the api_version of stemcell '%s'", stemcellRecord.CID)
+	}
+
+	stemcellRecord.APIVersion = apiVersion
+	return stemcellRecord, nil
+}
+
 func (m *manager) FindUnused() ([]CloudStemcell, error) {
 	unusedStemcells := []CloudStemcell{}
 
@@ -109,12 +109,30 @@ var _ = Describe("Manager", func() {
 			}))
 		})
 
+		It("records the api_version of the stemcell", func() {
+			expectedExtractedStemcell = NewExtractedStemcell(
+				Manifest{Name: "fake-stemcell-name", Version: "fake-stemcell-version", APIVersion: 2},
+				tempExtractionDir,
+				nil,
+				fs,
+			)
+
+			cloudStemcell, err := manager.Upload(expectedExtractedStemcell, fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cloudStemcell.CID()).To(Equal("fake-stemcell-cid"))
+
+			stemcellRecords, err := stemcellRepo.All()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stemcellRecords).To(HaveLen(1))
+			Expect(stemcellRecords[0].APIVersion).To(Equal(2))
+		})
+
 		It("prints uploading ui stage", func() {
 			_, err := manager.Upload(expectedExtractedStemcell, fakeStage)
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(fakeStage.PerformCalls).To(Equal([]*fakebiui.PerformCall{
-				{Name: "Uploading stemcell 'fake-stemcell-name/fake-stemcell-version'"},
+				{Name: "Uploading stemcell 'fake-stemcell-name/fake-stemcell-version'", Progress: &fakebiui.FakeProgress{}},
 			}))
 		})
 
@@ -164,6 +182,23 @@ var _ = Describe("Manager", func() {
 				Expect(fakeCloud.CreateStemcellInputs).To(HaveLen(0))
 			})
 
+			It("records the api_version of the stemcell when it was not recorded", func() {
+				expectedExtractedStemcell = NewExtractedStemcell(
+					Manifest{Name: "fake-stemcell-name", Version: "fake-stemcell-version", APIVersion: 2},
+					tempExtractionDir,
+					nil,
+					fs,
+				)
+
+				_, err := manager.Upload(expectedExtractedStemcell, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+
+				stemcellRecord, found, err := stemcellRepo.Find("fake-stemcell-name", "fake-stemcell-version")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeTrue())
+				Expect(stemcellRecord.APIVersion).To(Equal(2))
+			})
+
 			It("logs skipping uploading events to the eventLogger", func() {
 				_, err := manager.Upload(expectedExtractedStemcell, fakeStage)
 				Expect(err).ToNot(HaveOccurred())
@@ -16,6 +16,7 @@ type manifest struct {
 	OS              string `yaml:"operating_system"`
 	SHA1            string
 	BoshProtocol    string                      `yaml:"bosh_protocol"`
+	APIVersion      int                         `yaml:"api_version"`
 	CloudProperties map[interface{}]interface{} `yaml:"cloud_properties"`
 }
 
@@ -59,6 +60,7 @@ func (s reader) Read(stemcellTarballPath string, extractedPath string) (Extracte
 		OS:           rawManifest.OS,
 		SHA1:         rawManifest.SHA1,
 		BoshProtocol: rawManifest.BoshProtocol,
+		APIVersion:   rawManifest.APIVersion,
 	}
 
 	cloudProperties, err := biproperty.BuildMap(rawManifest.CloudProperties)
@@ -109,5 +109,6 @@ type Manifest struct {
 	OS              string         `yaml:"operating_system"`
 	SHA1            string         `yaml:"sha1"`
 	BoshProtocol    string         `yaml:"bosh_protocol"`
+	APIVersion      int            `yaml:"api_version,omitempty"`
 	CloudProperties biproperty.Map `yaml:"cloud_properties"`
 }
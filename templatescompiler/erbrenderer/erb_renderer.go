@@ -18,6 +18,7 @@ type erbRenderer struct {
 	runner boshsys.CmdRunner
 	logger boshlog.Logger
 	logTag string
+	strict bool
 
 	rendererScript string
 }
@@ -37,6 +38,24 @@ func NewERBRenderer(
 	}
 }
 
+// NewStrictERBRenderer returns a renderer that fails on any lookup of a property
+// that is not declared by the job, instead of leaving the value empty.
+func NewStrictERBRenderer(
+	fs boshsys.FileSystem,
+	runner boshsys.CmdRunner,
+	logger boshlog.Logger,
+) ERBRenderer {
+	return erbRenderer{
+		fs:     fs,
+		runner: runner,
+		logger: logger,
+		logTag: "erbRenderer",
+		strict: true,
+
+		rendererScript: templateEvaluationContextRb,
+	}
+}
+
 func (r erbRenderer) Render(srcPath, dstPath string, context TemplateEvaluationContext) error {
 	r.logger.Debug(r.logTag, "Rendering template %s", dstPath)
 
@@ -67,6 +86,10 @@ func (r erbRenderer) Render(srcPath, dstPath string, context TemplateEvaluationC
 		Args: []string{rendererScriptPath, contextPath, srcPath, dstPath},
 	}
 
+	if r.strict {
+		command.Args = append(command.Args, "strict")
+	}
+
 	_, _, _, err = r.runner.RunComplexCommand(command)
 	if err != nil {
 		return bosherr.WrapError(err, "Running ruby to render templates")
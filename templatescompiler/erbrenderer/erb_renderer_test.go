@@ -47,6 +47,25 @@ var _ = Describe("ErbRenderer", func() {
 		}))
 	})
 
+	It("runs the renderer script in strict mode when the renderer is strict", func() {
+		erbRenderer = NewStrictERBRenderer(fs, runner, boshlog.NewLogger(boshlog.LevelNone))
+
+		err := erbRenderer.Render("fake-src-path", "fake-dst-path", context)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(runner.RunComplexCommands).To(Equal([]boshsys.Command{
+			boshsys.Command{
+				Name: "ruby",
+				Args: []string{
+					filepath.Join("fake-temp-dir", "erb-render.rb"),
+					filepath.Join("fake-temp-dir", "erb-context.json"),
+					"fake-src-path",
+					"fake-dst-path",
+					"strict",
+				},
+			},
+		}))
+	})
+
 	It("cleans up temporary directory", func() {
 		err := erbRenderer.Render("fake-src-path", "fake-dst-path", context)
 		Expect(err).ToNot(HaveOccurred())
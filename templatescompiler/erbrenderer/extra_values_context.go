@@ -0,0 +1,45 @@
+package erbrenderer
+
+import (
+	"encoding/json"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+)
+
+type extraValuesContext struct {
+	context     TemplateEvaluationContext
+	extraValues biproperty.Map
+}
+
+// NewContextWithExtraValues adds top-level values (e.g. per-instance metadata)
+// to the serialized context so that templates can read them through spec.
+// Keys already set by the wrapped context take precedence over extra values.
+func NewContextWithExtraValues(context TemplateEvaluationContext, extraValues biproperty.Map) TemplateEvaluationContext {
+	return extraValuesContext{
+		context:     context,
+		extraValues: extraValues,
+	}
+}
+
+func (c extraValuesContext) MarshalJSON() ([]byte, error) {
+	contextBytes, err := c.context.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	merged := map[string]interface{}{}
+
+	err = json.Unmarshal(contextBytes, &merged)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshalling template evaluation context")
+	}
+
+	for key, value := range c.extraValues {
+		if _, found := merged[key]; !found {
+			merged[key] = value
+		}
+	}
+
+	return json.Marshal(merged)
+}
@@ -0,0 +1,70 @@
+package erbrenderer_test
+
+import (
+	"encoding/json"
+
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+
+	. "github.com/cloudfoundry/bosh-cli/templatescompiler/erbrenderer"
+)
+
+type staticContext string
+
+func (c staticContext) MarshalJSON() ([]byte, error) {
+	return []byte(c), nil
+}
+
+var _ = Describe("NewContextWithExtraValues", func() {
+	It("merges extra values into the serialized context", func() {
+		context := NewContextWithExtraValues(staticContext(`{"index":0,"job":{"name":"fake-job"}}`), biproperty.Map{
+			"deployment_name": "fake-deployment",
+			"metadata":        biproperty.Map{"rack": "r1"},
+		})
+
+		contextBytes, err := json.Marshal(context)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(contextBytes).To(MatchJSON(`{
+			"index": 0,
+			"job": {"name": "fake-job"},
+			"deployment_name": "fake-deployment",
+			"metadata": {"rack": "r1"}
+		}`))
+	})
+
+	It("keeps values from the wrapped context when keys collide", func() {
+		context := NewContextWithExtraValues(staticContext(`{"index":0}`), biproperty.Map{"index": 5})
+
+		contextBytes, err := json.Marshal(context)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(contextBytes).To(MatchJSON(`{"index": 0}`))
+	})
+
+	It("produces JSON that round-trips into property maps", func() {
+		context := NewContextWithExtraValues(staticContext(`{}`), biproperty.Map{
+			"metadata": biproperty.Map{"zones": biproperty.List{"z1", "z2"}},
+		})
+
+		contextBytes, err := json.Marshal(context)
+		Expect(err).ToNot(HaveOccurred())
+
+		var raw map[interface{}]interface{}
+		Expect(yaml.Unmarshal(contextBytes, &raw)).To(Succeed())
+
+		properties, err := biproperty.BuildMap(raw)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(properties).To(Equal(biproperty.Map{
+			"metadata": biproperty.Map{"zones": biproperty.List{"z1", "z2"}},
+		}))
+	})
+
+	It("returns an error when the wrapped context is not a JSON object", func() {
+		context := NewContextWithExtraValues(staticContext(`[]`), biproperty.Map{})
+
+		_, err := context.MarshalJSON()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Unmarshalling template evaluation context"))
+	})
+})
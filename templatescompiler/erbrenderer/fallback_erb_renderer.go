@@ -0,0 +1,41 @@
+package erbrenderer
+
+import (
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type fallbackERBRenderer struct {
+	primary  ERBRenderer
+	fallback ERBRenderer
+	logger   boshlog.Logger
+	logTag   string
+}
+
+// NewFallbackERBRenderer renders with primary and only uses fallback when primary
+// reports an UnsupportedERBConstructError, e.g. trying the Go renderer before ruby.
+func NewFallbackERBRenderer(primary, fallback ERBRenderer, logger boshlog.Logger) ERBRenderer {
+	return fallbackERBRenderer{
+		primary:  primary,
+		fallback: fallback,
+		logger:   logger,
+		logTag:   "fallbackERBRenderer",
+	}
+}
+
+// NewGoFirstERBRenderer renders templates with the Go renderer, so that ruby
+// is only needed for templates using constructs the Go renderer does not
+// understand.
+func NewGoFirstERBRenderer(fs boshsys.FileSystem, runner boshsys.CmdRunner, logger boshlog.Logger) ERBRenderer {
+	return NewFallbackERBRenderer(NewGoERBRenderer(fs, logger), NewERBRenderer(fs, runner, logger), logger)
+}
+
+func (r fallbackERBRenderer) Render(srcPath, dstPath string, context TemplateEvaluationContext) error {
+	err := r.primary.Render(srcPath, dstPath, context)
+	if _, unsupported := err.(UnsupportedERBConstructError); unsupported {
+		r.logger.Debug(r.logTag, "Falling back to render %s: %s", srcPath, err.Error())
+		return r.fallback.Render(srcPath, dstPath, context)
+	}
+
+	return err
+}
@@ -0,0 +1,91 @@
+package erbrenderer_test
+
+import (
+	"errors"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/templatescompiler/erbrenderer"
+	fakebierbrenderer "github.com/cloudfoundry/bosh-cli/templatescompiler/erbrenderer/fakes"
+)
+
+var _ = Describe("FallbackERBRenderer", func() {
+	var (
+		primary  *fakebierbrenderer.FakeERBRenderer
+		fallback *fakebierbrenderer.FakeERBRenderer
+		renderer ERBRenderer
+		context  *fakebierbrenderer.FakeTemplateEvaluationContext
+	)
+
+	BeforeEach(func() {
+		primary = fakebierbrenderer.NewFakeERBRender()
+		fallback = fakebierbrenderer.NewFakeERBRender()
+		context = &fakebierbrenderer.FakeTemplateEvaluationContext{}
+		renderer = NewFallbackERBRenderer(primary, fallback, boshlog.NewLogger(boshlog.LevelNone))
+
+		fallback.SetRenderBehavior("src", "dst", context, nil)
+	})
+
+	It("does not use the fallback when the primary renderer succeeds", func() {
+		primary.SetRenderBehavior("src", "dst", context, nil)
+
+		err := renderer.Render("src", "dst", context)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fallback.RenderInputs).To(BeEmpty())
+	})
+
+	It("uses the fallback when the primary renderer does not support the template", func() {
+		primary.SetRenderBehavior("src", "dst", context, UnsupportedERBConstructError{Construct: "<% end %>", Template: "src"})
+
+		err := renderer.Render("src", "dst", context)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fallback.RenderInputs).To(HaveLen(1))
+	})
+
+	It("returns other primary renderer errors without falling back", func() {
+		primary.SetRenderBehavior("src", "dst", context, errors.New("fake-render-error"))
+
+		err := renderer.Render("src", "dst", context)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("fake-render-error"))
+		Expect(fallback.RenderInputs).To(BeEmpty())
+	})
+})
+
+var _ = Describe("NewGoFirstERBRenderer", func() {
+	var (
+		fs        *fakesys.FakeFileSystem
+		cmdRunner *fakesys.FakeCmdRunner
+		renderer  ERBRenderer
+		context   TemplateEvaluationContext
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		cmdRunner = fakesys.NewFakeCmdRunner()
+		renderer = NewGoFirstERBRenderer(fs, cmdRunner, boshlog.NewLogger(boshlog.LevelNone))
+
+		context = staticContext(`{"job_properties": {"port": 8080}, "default_properties": {"port": null}}`)
+	})
+
+	It("renders templates the Go renderer understands without ruby", func() {
+		fs.WriteFileString("/src", `port=<%= p("port") %>`)
+
+		err := renderer.Render("/src", "/dst", context)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fs.ReadFileString("/dst")).To(Equal("port=8080"))
+		Expect(cmdRunner.RunComplexCommands).To(BeEmpty())
+	})
+
+	It("renders other templates with ruby", func() {
+		fs.WriteFileString("/src", `<% port = p("port") %>port=<%= port %>`)
+
+		err := renderer.Render("/src", "/dst", context)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cmdRunner.RunComplexCommands).To(HaveLen(1))
+		Expect(cmdRunner.RunComplexCommands[0].Name).To(Equal("ruby"))
+	})
+})
@@ -0,0 +1,344 @@
+package erbrenderer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// UnsupportedERBConstructError is returned by the Go renderer for any ERB tag
+// it cannot evaluate, so that callers can fall back to the ruby renderer.
+type UnsupportedERBConstructError struct {
+	Construct string
+	Template  string
+}
+
+func (e UnsupportedERBConstructError) Error() string {
+	return fmt.Sprintf("Unsupported ERB construct '%s' in template '%s'", e.Construct, e.Template)
+}
+
+var (
+	erbTagPattern      = regexp.MustCompile(`(?s)<%(.*?)-?%>`)
+	erbPropertyPattern = regexp.MustCompile(`^p\(\s*["']([^"']+)["']\s*(?:,\s*(.+?)\s*)?\)$`)
+	erbIfPPattern      = regexp.MustCompile(`^if_p\(((?:\s*["'][^"']+["']\s*,?)+)\)\s*do\s*(?:\|([\w\s,]*)\|)?$`)
+	erbQuotedPattern   = regexp.MustCompile(`["']([^"']+)["']`)
+	erbSpecPattern     = regexp.MustCompile(`^spec((?:\.\w+)+)$`)
+	erbVariablePattern = regexp.MustCompile(`^[a-z_]\w*$`)
+	erbElsePattern     = regexp.MustCompile(`^end\.else\s+do$`)
+)
+
+// goERBBlock is an open if_p or else block. Output is only produced while
+// every open block is active.
+type goERBBlock struct {
+	active     bool
+	elseActive bool
+	isIfP      bool
+	bindings   map[string]interface{}
+}
+
+type goERBScope struct {
+	spec       map[string]interface{}
+	properties map[string]interface{}
+	blocks     []goERBBlock
+}
+
+func (s *goERBScope) active() bool {
+	for _, block := range s.blocks {
+		if !block.active {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *goERBScope) binding(name string) (interface{}, bool) {
+	for i := len(s.blocks) - 1; i >= 0; i-- {
+		if value, found := s.blocks[i].bindings[name]; found {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+type goERBRenderer struct {
+	fs     boshsys.FileSystem
+	logger boshlog.Logger
+	logTag string
+}
+
+// NewGoERBRenderer returns a renderer that does not require ruby. It understands
+// <%= p(...) %> lookups with optional defaults, <%= spec.* %>, if_p blocks with
+// an optional end.else branch, and comments. Any other construct results in an
+// UnsupportedERBConstructError.
+func NewGoERBRenderer(fs boshsys.FileSystem, logger boshlog.Logger) ERBRenderer {
+	return goERBRenderer{
+		fs:     fs,
+		logger: logger,
+		logTag: "goERBRenderer",
+	}
+}
+
+func (r goERBRenderer) Render(srcPath, dstPath string, context TemplateEvaluationContext) error {
+	r.logger.Debug(r.logTag, "Rendering template %s", dstPath)
+
+	template, err := r.fs.ReadFileString(srcPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading template '%s'", srcPath)
+	}
+
+	scope, err := r.scope(context)
+	if err != nil {
+		return err
+	}
+
+	var rendered bytes.Buffer
+	last := 0
+
+	for _, loc := range erbTagPattern.FindAllStringSubmatchIndex(template, -1) {
+		if scope.active() {
+			rendered.WriteString(template[last:loc[0]])
+		}
+		last = loc[1]
+
+		tag := template[loc[0]:loc[1]]
+		code := template[loc[2]:loc[3]]
+
+		if strings.HasSuffix(tag, "-%>") && strings.HasPrefix(template[last:], "\n") {
+			last++
+		}
+
+		if strings.HasPrefix(code, "#") {
+			continue
+		}
+
+		if !strings.HasPrefix(code, "=") {
+			err = r.control(strings.TrimSpace(code), tag, srcPath, scope)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !scope.active() {
+			continue
+		}
+
+		value, err := r.evaluate(strings.TrimSpace(code[1:]), tag, srcPath, scope)
+		if err != nil {
+			return err
+		}
+
+		rendered.WriteString(value)
+	}
+
+	if len(scope.blocks) > 0 {
+		return bosherr.Errorf("Error filling in template '%s': if_p block is missing 'end'", srcPath)
+	}
+
+	rendered.WriteString(template[last:])
+
+	err = r.fs.WriteFileString(dstPath, rendered.String())
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing rendered template '%s'", dstPath)
+	}
+
+	return nil
+}
+
+// control opens and closes if_p and else blocks.
+func (r goERBRenderer) control(code, tag, srcPath string, scope *goERBScope) error {
+	switch {
+	case code == "end":
+		if len(scope.blocks) == 0 {
+			return bosherr.Errorf("Error filling in template '%s': unexpected '%s'", srcPath, tag)
+		}
+		scope.blocks = scope.blocks[:len(scope.blocks)-1]
+
+	case erbElsePattern.MatchString(code):
+		if len(scope.blocks) == 0 || !scope.blocks[len(scope.blocks)-1].isIfP {
+			return bosherr.Errorf("Error filling in template '%s': unexpected '%s'", srcPath, tag)
+		}
+		top := scope.blocks[len(scope.blocks)-1]
+		scope.blocks[len(scope.blocks)-1] = goERBBlock{active: top.elseActive}
+
+	case erbIfPPattern.MatchString(code):
+		matches := erbIfPPattern.FindStringSubmatch(code)
+
+		names := []string{}
+		for _, quoted := range erbQuotedPattern.FindAllStringSubmatch(matches[1], -1) {
+			names = append(names, quoted[1])
+		}
+
+		variables := []string{}
+		for _, variable := range strings.Split(matches[2], ",") {
+			if variable = strings.TrimSpace(variable); variable != "" {
+				variables = append(variables, variable)
+			}
+		}
+
+		block := goERBBlock{isIfP: true, bindings: map[string]interface{}{}}
+		if scope.active() {
+			block.active = true
+			for i, name := range names {
+				value, found := r.lookup(scope.properties, name)
+				if !found {
+					block.active = false
+					break
+				}
+				if i < len(variables) {
+					block.bindings[variables[i]] = value
+				}
+			}
+			block.elseActive = !block.active
+		}
+
+		scope.blocks = append(scope.blocks, block)
+
+	default:
+		return UnsupportedERBConstructError{Construct: tag, Template: srcPath}
+	}
+
+	return nil
+}
+
+func (r goERBRenderer) evaluate(expression, tag, srcPath string, scope *goERBScope) (string, error) {
+	var value interface{}
+
+	if matches := erbPropertyPattern.FindStringSubmatch(expression); matches != nil {
+		var found bool
+		value, found = r.lookup(scope.properties, matches[1])
+		if !found {
+			if matches[2] == "" {
+				return "", bosherr.Errorf("Error filling in template '%s': Can't find property '%s'", srcPath, matches[1])
+			}
+
+			err := r.decode([]byte(matches[2]), &value)
+			if err != nil {
+				return "", UnsupportedERBConstructError{Construct: tag, Template: srcPath}
+			}
+		}
+	} else if matches := erbSpecPattern.FindStringSubmatch(expression); matches != nil {
+		value, _ = r.lookup(scope.spec, matches[1][1:])
+	} else if bound, found := scope.binding(expression); found && erbVariablePattern.MatchString(expression) {
+		value = bound
+	} else {
+		return "", UnsupportedERBConstructError{Construct: tag, Template: srcPath}
+	}
+
+	switch typedValue := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return typedValue, nil
+	case bool, json.Number:
+		return fmt.Sprintf("%v", typedValue), nil
+	default:
+		return "", UnsupportedERBConstructError{Construct: tag, Template: srcPath}
+	}
+}
+
+// scope resolves the job's properties the same way the ruby
+// TemplateEvaluationContext does: job_properties when present, otherwise
+// global_properties merged with cluster_properties, limited to the
+// properties declared in default_properties.
+func (r goERBRenderer) scope(context TemplateEvaluationContext) (*goERBScope, error) {
+	contextBytes, err := context.MarshalJSON()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Marshalling context")
+	}
+
+	spec := map[string]interface{}{}
+
+	err = r.decode(contextBytes, &spec)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshalling context")
+	}
+
+	jobProperties, _ := spec["job_properties"].(map[string]interface{})
+	globalProperties, _ := spec["global_properties"].(map[string]interface{})
+	clusterProperties, _ := spec["cluster_properties"].(map[string]interface{})
+	defaultProperties, _ := spec["default_properties"].(map[string]interface{})
+
+	source := jobProperties
+	if source == nil {
+		source = r.merge(globalProperties, clusterProperties)
+	}
+
+	properties := map[string]interface{}{}
+	for name, defaultValue := range defaultProperties {
+		value, found := r.lookup(source, name)
+		if !found {
+			value = defaultValue
+		}
+		r.set(properties, name, value)
+	}
+
+	return &goERBScope{spec: spec, properties: properties}, nil
+}
+
+func (r goERBRenderer) decode(data []byte, value interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(value)
+}
+
+func (r goERBRenderer) lookup(properties map[string]interface{}, name string) (interface{}, bool) {
+	var current interface{} = properties
+
+	for _, key := range strings.Split(name, ".") {
+		currentMap, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		current, ok = currentMap[key]
+		if !ok || current == nil {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+func (r goERBRenderer) set(properties map[string]interface{}, name string, value interface{}) {
+	keys := strings.Split(name, ".")
+	current := properties
+
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			current[key] = next
+		}
+		current = next
+	}
+
+	current[keys[len(keys)-1]] = value
+}
+
+func (r goERBRenderer) merge(base, override map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+
+	for key, value := range base {
+		merged[key] = value
+	}
+
+	for key, value := range override {
+		baseMap, baseIsMap := merged[key].(map[string]interface{})
+		overrideMap, overrideIsMap := value.(map[string]interface{})
+
+		if baseIsMap && overrideIsMap {
+			merged[key] = r.merge(baseMap, overrideMap)
+		} else {
+			merged[key] = value
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,138 @@
+package erbrenderer_test
+
+import (
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/templatescompiler/erbrenderer"
+)
+
+var _ = Describe("GoERBRenderer", func() {
+	var (
+		fs       *fakesys.FakeFileSystem
+		renderer ERBRenderer
+		context  TemplateEvaluationContext
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		renderer = NewGoERBRenderer(fs, boshlog.NewLogger(boshlog.LevelNone))
+
+		context = staticContext(`{
+			"global_properties": {"db": {"host": "global-host", "port": 5432}},
+			"cluster_properties": {"db": {"host": "cluster-host"}},
+			"default_properties": {
+				"db.host": null,
+				"db.port": null,
+				"db.user": "admin",
+				"db.password": null,
+				"db.ssl": true
+			}
+		}`)
+	})
+
+	It("renders property lookups resolved like the ruby context", func() {
+		fs.WriteFileString("/src", `host=<%= p("db.host") %>
+port=<%= p('db.port') %>
+user=<%= p("db.user") %>
+ssl=<%= p("db.ssl") %>
+<%# a comment -%>
+password=<%= p("db.password", "secret") %>
+`)
+
+		err := renderer.Render("/src", "/dst", context)
+		Expect(err).ToNot(HaveOccurred())
+
+		contents, err := fs.ReadFileString("/dst")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(contents).To(Equal(`host=cluster-host
+port=5432
+user=admin
+ssl=true
+password=secret
+`))
+	})
+
+	It("prefers job_properties when they are present", func() {
+		context = staticContext(`{
+			"job_properties": {"db": {"host": "job-host"}},
+			"global_properties": {"db": {"host": "global-host"}},
+			"default_properties": {"db.host": null}
+		}`)
+		fs.WriteFileString("/src", `<%= p("db.host") %>`)
+
+		err := renderer.Render("/src", "/dst", context)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fs.ReadFileString("/dst")).To(Equal("job-host"))
+	})
+
+	It("returns an error naming missing properties without defaults", func() {
+		fs.WriteFileString("/src", `<%= p("db.password") %>`)
+
+		err := renderer.Render("/src", "/dst", context)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Can't find property 'db.password'"))
+	})
+
+	It("renders spec values", func() {
+		context = staticContext(`{"index": 2, "deployment": "fake-deployment", "networks": {"default": {"ip": "10.0.0.5"}}}`)
+		fs.WriteFileString("/src", `<%= spec.deployment %>/<%= spec.index %> <%= spec.networks.default.ip %><%= spec.missing %>`)
+
+		err := renderer.Render("/src", "/dst", context)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fs.ReadFileString("/dst")).To(Equal("fake-deployment/2 10.0.0.5"))
+	})
+
+	It("renders if_p blocks when all properties are set", func() {
+		fs.WriteFileString("/src", `<% if_p("db.host", "db.port") do |host, port| -%>
+url=<%= host %>:<%= port %>
+<% end.else do -%>
+url=none
+<% end -%>
+`)
+
+		err := renderer.Render("/src", "/dst", context)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fs.ReadFileString("/dst")).To(Equal("url=cluster-host:5432\n"))
+	})
+
+	It("renders the else branch of if_p blocks when a property is not set", func() {
+		fs.WriteFileString("/src", `<% if_p("db.password") do |password| -%>
+password=<%= password %><%= p("db.missing") %>
+<% end.else do -%>
+password=none
+<% end -%>
+`)
+
+		err := renderer.Render("/src", "/dst", context)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fs.ReadFileString("/dst")).To(Equal("password=none\n"))
+	})
+
+	It("returns an error for unterminated if_p blocks", func() {
+		fs.WriteFileString("/src", `<% if_p("db.host") do |host| %><%= host %>`)
+
+		err := renderer.Render("/src", "/dst", context)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("if_p block is missing 'end'"))
+	})
+
+	It("returns an unsupported construct error for code it cannot evaluate", func() {
+		fs.WriteFileString("/src", `<% p("servers").each do |server| %><%= server %><% end %>`)
+
+		err := renderer.Render("/src", "/dst", context)
+		Expect(err).To(HaveOccurred())
+		Expect(err).To(BeAssignableToTypeOf(UnsupportedERBConstructError{}))
+		Expect(err.Error()).To(Equal(`Unsupported ERB construct '<% p("servers").each do |server| %>' in template '/src'`))
+	})
+
+	It("returns an unsupported construct error for non-scalar values", func() {
+		fs.WriteFileString("/src", `<%= p("db") %>`)
+		context = staticContext(`{"job_properties": {"db": {"host": "h"}}, "default_properties": {"db": null}}`)
+
+		err := renderer.Render("/src", "/dst", context)
+		Expect(err).To(BeAssignableToTypeOf(UnsupportedERBConstructError{}))
+	})
+})
@@ -0,0 +1,87 @@
+package erbrenderer
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"gopkg.in/yaml.v2"
+)
+
+type OutputFormat string
+
+const (
+	// OutputFormatAuto picks the format from the destination extension (.json, .yml, .yaml)
+	// and skips validation for any other extension.
+	OutputFormatAuto OutputFormat = "auto"
+	OutputFormatJSON OutputFormat = "json"
+	OutputFormatYAML OutputFormat = "yaml"
+)
+
+type outputValidatingERBRenderer struct {
+	renderer ERBRenderer
+	fs       boshsys.FileSystem
+	format   OutputFormat
+}
+
+// NewOutputValidatingERBRenderer wraps a renderer so that every rendered file
+// is parsed as JSON or YAML, catching template-induced syntax errors at render time.
+func NewOutputValidatingERBRenderer(renderer ERBRenderer, fs boshsys.FileSystem, format OutputFormat) ERBRenderer {
+	return outputValidatingERBRenderer{
+		renderer: renderer,
+		fs:       fs,
+		format:   format,
+	}
+}
+
+func (r outputValidatingERBRenderer) Render(srcPath, dstPath string, context TemplateEvaluationContext) error {
+	err := r.renderer.Render(srcPath, dstPath, context)
+	if err != nil {
+		return err
+	}
+
+	format := r.formatFor(dstPath)
+	if format == "" {
+		return nil
+	}
+
+	contents, err := r.fs.ReadFile(dstPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading rendered template '%s'", dstPath)
+	}
+
+	switch format {
+	case OutputFormatJSON:
+		var parsed interface{}
+		err = json.Unmarshal(contents, &parsed)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Rendered template '%s' is not valid JSON", dstPath)
+		}
+
+	case OutputFormatYAML:
+		var parsed interface{}
+		err = yaml.Unmarshal(contents, &parsed)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Rendered template '%s' is not valid YAML", dstPath)
+		}
+	}
+
+	return nil
+}
+
+func (r outputValidatingERBRenderer) formatFor(dstPath string) OutputFormat {
+	if r.format != OutputFormatAuto {
+		return r.format
+	}
+
+	switch strings.ToLower(filepath.Ext(dstPath)) {
+	case ".json":
+		return OutputFormatJSON
+	case ".yml", ".yaml":
+		return OutputFormatYAML
+	default:
+		return ""
+	}
+}
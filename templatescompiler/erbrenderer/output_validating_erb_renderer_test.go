@@ -0,0 +1,89 @@
+package erbrenderer_test
+
+import (
+	"errors"
+
+	. "github.com/cloudfoundry/bosh-cli/templatescompiler/erbrenderer"
+	fakebierbrenderer "github.com/cloudfoundry/bosh-cli/templatescompiler/erbrenderer/fakes"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OutputValidatingERBRenderer", func() {
+	var (
+		fs          *fakesys.FakeFileSystem
+		erbRenderer *fakebierbrenderer.FakeERBRenderer
+		context     *fakebierbrenderer.FakeTemplateEvaluationContext
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		erbRenderer = fakebierbrenderer.NewFakeERBRender()
+		context = &fakebierbrenderer.FakeTemplateEvaluationContext{}
+	})
+
+	render := func(format OutputFormat, dstPath, renderedContents string) error {
+		err := erbRenderer.SetRenderBehavior("fake-src-path", dstPath, context, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		err = fs.WriteFileString(dstPath, renderedContents)
+		Expect(err).ToNot(HaveOccurred())
+
+		return NewOutputValidatingERBRenderer(erbRenderer, fs, format).Render("fake-src-path", dstPath, context)
+	}
+
+	Context("when validating JSON", func() {
+		It("accepts valid JSON output", func() {
+			err := render(OutputFormatJSON, "/fake-dst-path", `{"port": 8080}`)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns an error naming the destination for invalid JSON output", func() {
+			err := render(OutputFormatJSON, "/fake-dst-path", `{"port": }`)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Rendered template '/fake-dst-path' is not valid JSON"))
+		})
+	})
+
+	Context("when validating YAML", func() {
+		It("accepts valid YAML output", func() {
+			err := render(OutputFormatYAML, "/fake-dst-path", "port: 8080\n")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns an error naming the destination for invalid YAML output", func() {
+			err := render(OutputFormatYAML, "/fake-dst-path", "port: [8080\n")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Rendered template '/fake-dst-path' is not valid YAML"))
+		})
+	})
+
+	Context("when the format is selected by destination extension", func() {
+		It("validates .json destinations as JSON", func() {
+			err := render(OutputFormatAuto, "/config/fake.json", "not: json")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Rendered template '/config/fake.json' is not valid JSON"))
+		})
+
+		It("validates .yml destinations as YAML", func() {
+			err := render(OutputFormatAuto, "/config/fake.yml", "a: [")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Rendered template '/config/fake.yml' is not valid YAML"))
+		})
+
+		It("skips validation for other extensions", func() {
+			err := render(OutputFormatAuto, "/config/fake.conf", "{ not json")
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	It("returns the render error without validating", func() {
+		err := erbRenderer.SetRenderBehavior("fake-src-path", "/fake-dst-path", context, errors.New("fake-render-error"))
+		Expect(err).ToNot(HaveOccurred())
+
+		err = NewOutputValidatingERBRenderer(erbRenderer, fs, OutputFormatJSON).Render("fake-src-path", "/fake-dst-path", context)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("fake-render-error"))
+	})
+})
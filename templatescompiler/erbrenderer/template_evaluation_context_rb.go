@@ -21,12 +21,29 @@ class Hash
   end
 end
 
+module PropertyHelper
+  def lookup_property(collection, name)
+    keys = name.split(".")
+    ref = collection
+
+    keys.each do |key|
+      ref = ref[key]
+      return nil if ref.nil?
+    end
+
+    ref
+  end
+end
+
 class TemplateEvaluationContext
+  include PropertyHelper
+
   attr_reader :name, :index
   attr_reader :properties, :raw_properties
   attr_reader :spec
 
-  def initialize(spec)
+  def initialize(spec, strict = false)
+    @strict = strict
     @name = spec["job"]["name"] if spec["job"].is_a?(Hash)
     @index = spec["index"]
 
@@ -41,8 +58,9 @@ class TemplateEvaluationContext
       copy_property(properties, properties1, name, value)
     end
 
-    @properties = openstruct(properties)
+    @properties = openstruct(properties, @strict)
     @raw_properties = properties
+    @links = spec['links'] || {}
     @spec = openstruct(spec)
   end
 
@@ -53,6 +71,11 @@ class TemplateEvaluationContext
   def p(*args)
     names = Array(args[0])
 
+    if @strict
+      undeclared = names.reject { |name| property_declared?(@raw_properties, name) }
+      raise UnknownProperty.new(undeclared) if undeclared.size == names.size
+    end
+
     names.each do |name|
       result = lookup_property(@raw_properties, name)
       return result unless result.nil?
@@ -72,13 +95,38 @@ class TemplateEvaluationContext
     yield *values
     InactiveElseBlock.new
   end
-  
+
+  def link(name)
+    link_spec = @links[name]
+    raise UnknownLink.new(name) if link_spec.nil?
+
+    create_evaluation_link(link_spec)
+  end
+
   def if_link(name)
-    false
+    link_spec = @links[name]
+    return ActiveElseBlock.new(self) if link_spec.nil?
+
+    yield create_evaluation_link(link_spec)
+    InactiveElseBlock.new
   end
 
   private
 
+  def create_evaluation_link(link_spec)
+    instances = (link_spec['instances'] || []).map do |instance|
+      EvaluationLinkInstance.new(
+        instance['name'],
+        instance['index'],
+        instance['az'],
+        instance['address'],
+        instance['bootstrap']
+      )
+    end
+
+    EvaluationLink.new(instances, link_spec['properties'] || {})
+  end
+
   def copy_property(dst, src, name, default = nil)
     keys = name.split(".")
     src_ref = src
@@ -98,28 +146,41 @@ class TemplateEvaluationContext
     dst_ref[keys[-1]] = src_ref.nil? ? default : src_ref
   end
 
-  def openstruct(object)
+  def openstruct(object, strict = false, path = nil)
     case object
       when Hash
-        mapped = object.inject({}) { |h, (k,v)| h[k] = openstruct(v); h }
-        OpenStruct.new(mapped)
+        mapped = object.inject({}) { |h, (k,v)| h[k] = openstruct(v, strict, [path, k].compact.join(".")); h }
+        strict ? StrictOpenStruct.new(mapped, path) : OpenStruct.new(mapped)
       when Array
-        object.map { |item| openstruct(item) }
+        object.map { |item| openstruct(item, strict, path) }
       else
         object
     end
   end
 
-  def lookup_property(collection, name)
+  def property_declared?(collection, name)
     keys = name.split(".")
     ref = collection
 
     keys.each do |key|
+      return false unless ref.is_a?(Hash) && ref.has_key?(key)
       ref = ref[key]
-      return nil if ref.nil?
     end
 
-    ref
+    true
+  end
+
+  class StrictOpenStruct < OpenStruct
+    def initialize(hash, path)
+      super(hash)
+      @path = path
+    end
+
+    def method_missing(name, *args)
+      key = name.to_s
+      return super if key.end_with?("=") || respond_to?(name)
+      raise UnknownProperty.new([[@path, key].compact.join(".")])
+    end
   end
 
   class UnknownProperty < StandardError
@@ -131,6 +192,51 @@ class TemplateEvaluationContext
     end
   end
 
+  class UnknownLink < StandardError
+    attr_reader :name
+
+    def initialize(name)
+      @name = name
+      super("Can't find link '#{name}'")
+    end
+  end
+
+  EvaluationLinkInstance = Struct.new(:name, :index, :az, :address, :bootstrap)
+
+  class EvaluationLink
+    include PropertyHelper
+
+    attr_reader :instances, :properties
+
+    def initialize(instances, properties)
+      @instances = instances
+      @properties = properties
+    end
+
+    def p(*args)
+      names = Array(args[0])
+
+      names.each do |name|
+        result = lookup_property(@properties, name)
+        return result unless result.nil?
+      end
+
+      return args[1] if args.length == 2
+      raise UnknownProperty.new(names)
+    end
+
+    def if_p(*names)
+      values = names.map do |name|
+        value = lookup_property(@properties, name)
+        return ActiveElseBlock.new(self) if value.nil?
+        value
+      end
+
+      yield *values
+      InactiveElseBlock.new
+    end
+  end
+
   class ActiveElseBlock
     def initialize(template)
       @context = template
@@ -143,6 +249,10 @@ class TemplateEvaluationContext
     def else_if_p(*names, &block)
       @context.if_p(*names, &block)
     end
+
+    def else_if_link(name, &block)
+      @context.if_link(name, &block)
+    end
   end
 
   class InactiveElseBlock
@@ -151,6 +261,10 @@ class TemplateEvaluationContext
     def else_if_p(*names)
       InactiveElseBlock.new
     end
+
+    def else_if_link(name)
+      InactiveElseBlock.new
+    end
   end
 end
 
@@ -193,10 +307,10 @@ class ERBRenderer
 end
 
 if $0 == __FILE__
-  context_path, src_path, dst_path = *ARGV
+  context_path, src_path, dst_path, mode = *ARGV
 
   context_hash = JSON.load(File.read(context_path))
-  context = TemplateEvaluationContext.new(context_hash)
+  context = TemplateEvaluationContext.new(context_hash, mode == "strict")
 
   renderer = ERBRenderer.new(context)
   renderer.render(src_path, dst_path)
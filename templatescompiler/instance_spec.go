@@ -0,0 +1,123 @@
+package templatescompiler
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+)
+
+// InstanceSpec describes the instance that job templates are rendered for.
+// Templates see it as spec, e.g. spec.az or spec.networks.private.ip.
+type InstanceSpec struct {
+	Name    string // instance group name
+	ID      string // generated when empty
+	Index   int
+	AZ      string
+	Address string
+
+	Networks map[string]NetworkSpec
+
+	// DNSDomainName ends the DNS names of the instance, e.g.
+	// 0.my-instance-group.my-network.my-deployment.bosh. It is bosh when empty.
+	DNSDomainName string
+
+	// UseDNSAddresses makes address the DNS name of the instance on its
+	// default network instead of its IP.
+	UseDNSAddresses bool
+}
+
+var invalidDNSLabelChars = regexp.MustCompile(`[^a-z0-9-]`)
+
+type NetworkSpec struct {
+	IP      string
+	Netmask string
+	Gateway string
+	DNS     []string
+	Default []string
+}
+
+// DefaultNetwork returns the name of the network that provides the gateway of
+// the instance, or of its only network.
+func (s InstanceSpec) DefaultNetwork() (string, bool) {
+	for name, network := range s.Networks {
+		for _, def := range network.Default {
+			if def == "gateway" {
+				return name, true
+			}
+		}
+	}
+
+	if len(s.Networks) == 1 {
+		for name := range s.Networks {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// DNSRecordName returns the DNS name of the instance on the network, built
+// the same way as the director does: index, instance group, network and
+// deployment, followed by the DNS domain name.
+func (s InstanceSpec) DNSRecordName(networkName string, deploymentName string) string {
+	domainName := s.DNSDomainName
+	if len(domainName) == 0 {
+		domainName = "bosh"
+	}
+
+	return strings.Join([]string{
+		strconv.Itoa(s.Index),
+		canonicalDNSLabel(s.Name),
+		canonicalDNSLabel(networkName),
+		canonicalDNSLabel(deploymentName),
+		domainName,
+	}, ".")
+}
+
+// canonicalDNSLabel lowercases the name, replaces underscores with dashes
+// and drops the other characters DNS labels cannot contain.
+func canonicalDNSLabel(name string) string {
+	name = strings.Replace(strings.ToLower(name), "_", "-", -1)
+	return invalidDNSLabelChars.ReplaceAllString(name, "")
+}
+
+// NewNetworkSpecs builds the network specs from the network interfaces of a
+// deployment manifest, which have ip, netmask, gateway, dns and default keys.
+func NewNetworkSpecs(networkInterfaces map[string]biproperty.Map) map[string]NetworkSpec {
+	networks := map[string]NetworkSpec{}
+
+	for name, networkInterface := range networkInterfaces {
+		network := NetworkSpec{
+			DNS:     stringList(networkInterface["dns"]),
+			Default: stringList(networkInterface["default"]),
+		}
+
+		network.IP, _ = networkInterface["ip"].(string)
+		network.Netmask, _ = networkInterface["netmask"].(string)
+		network.Gateway, _ = networkInterface["gateway"].(string)
+
+		networks[name] = network
+	}
+
+	return networks
+}
+
+// stringList converts lists of strings or of string types, such as network
+// defaults, to []string.
+func stringList(value interface{}) []string {
+	list := reflect.ValueOf(value)
+	if list.Kind() != reflect.Slice {
+		return nil
+	}
+
+	strs := make([]string, list.Len())
+	for i := range strs {
+		strs[i] = fmt.Sprintf("%v", list.Index(i).Interface())
+	}
+
+	return strs
+}
@@ -0,0 +1,41 @@
+package templatescompiler_test
+
+import (
+	biproperty "github.com/cloudfoundry/bosh-utils/property"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-cli/templatescompiler"
+)
+
+type fakeNetworkDefault string
+
+var _ = Describe("NewNetworkSpecs", func() {
+	It("builds the network specs from the network interfaces", func() {
+		networkSpecs := NewNetworkSpecs(map[string]biproperty.Map{
+			"private": biproperty.Map{
+				"type":             "manual",
+				"ip":               "10.0.0.6",
+				"netmask":          "255.255.255.0",
+				"gateway":          "10.0.0.1",
+				"dns":              []string{"8.8.8.8"},
+				"default":          []fakeNetworkDefault{"dns", "gateway"},
+				"cloud_properties": biproperty.Map{},
+			},
+			"dynamic": biproperty.Map{
+				"type": "dynamic",
+			},
+		})
+
+		Expect(networkSpecs).To(Equal(map[string]NetworkSpec{
+			"private": {
+				IP:      "10.0.0.6",
+				Netmask: "255.255.255.0",
+				Gateway: "10.0.0.1",
+				DNS:     []string{"8.8.8.8"},
+				Default: []string{"dns", "gateway"},
+			},
+			"dynamic": {},
+		}))
+	})
+})
@@ -14,10 +14,11 @@ import (
 type jobEvaluationContext struct {
 	releaseJob           bireljob.Job
 	releaseJobProperties *biproperty.Map
+	links                biproperty.Map
 	jobProperties        biproperty.Map
 	globalProperties     biproperty.Map
 	deploymentName       string
-	address              string
+	instance             InstanceSpec
 	uuidGen              boshuuid.Generator
 	logger               boshlog.Logger
 	logTag               string
@@ -26,6 +27,7 @@ type jobEvaluationContext struct {
 // RootContext is exposed as an open struct in ERB templates.
 // It must stay same to provide backwards compatible API.
 type RootContext struct {
+	Name       string     `json:"name,omitempty"`
 	Index      int        `json:"index"`
 	ID         string     `json:"id"`
 	AZ         string     `json:"az"`
@@ -33,6 +35,7 @@ type RootContext struct {
 	JobContext jobContext `json:"job"`
 	Deployment string     `json:"deployment"`
 	Address    string     `json:"address,omitempty"`
+	IP         string     `json:"ip,omitempty"`
 
 	// Usually is accessed with <%= spec.networks.default.ip %>
 	NetworkContexts map[string]networkContext `json:"networks"`
@@ -42,6 +45,9 @@ type RootContext struct {
 	ClusterProperties biproperty.Map  `json:"cluster_properties"` // values from instance group (deployment job) properties
 	JobProperties     *biproperty.Map `json:"job_properties"`     // values from release job (aka template) properties
 	DefaultProperties biproperty.Map  `json:"default_properties"` // values from release's job's spec
+
+	// Usually is accessed with <%= link("name").instances[0].address %>
+	Links biproperty.Map `json:"links,omitempty"`
 }
 
 type jobContext struct {
@@ -49,28 +55,34 @@ type jobContext struct {
 }
 
 type networkContext struct {
-	IP      string `json:"ip"`
-	Netmask string `json:"netmask"`
-	Gateway string `json:"gateway"`
+	IP      string   `json:"ip"`
+	Netmask string   `json:"netmask"`
+	Gateway string   `json:"gateway"`
+	DNS     []string `json:"dns,omitempty"`
+	Default []string `json:"default,omitempty"`
+
+	DNSRecordName string `json:"dns_record_name,omitempty"`
 }
 
 func NewJobEvaluationContext(
 	releaseJob bireljob.Job,
 	releaseJobProperties *biproperty.Map,
+	links biproperty.Map,
 	jobProperties biproperty.Map,
 	globalProperties biproperty.Map,
 	deploymentName string,
-	address string,
+	instance InstanceSpec,
 	uuidGen boshuuid.Generator,
 	logger boshlog.Logger,
 ) bierbrenderer.TemplateEvaluationContext {
 	return jobEvaluationContext{
 		releaseJob:           releaseJob,
 		releaseJobProperties: releaseJobProperties,
+		links:                links,
 		jobProperties:        jobProperties,
 		globalProperties:     globalProperties,
 		deploymentName:       deploymentName,
-		address:              address,
+		instance:             instance,
 		uuidGen:              uuidGen,
 		logTag:               "jobEvaluationContext",
 		logger:               logger,
@@ -82,9 +94,11 @@ func (ec jobEvaluationContext) MarshalJSON() ([]byte, error) {
 	var err error
 
 	context := RootContext{
-		Index:             0,
-		AZ:                "unknown",
-		Bootstrap:         true,
+		Name:              ec.instance.Name,
+		ID:                ec.instance.ID,
+		Index:             ec.instance.Index,
+		AZ:                ec.instance.AZ,
+		Bootstrap:         ec.instance.Index == 0, // the director also picks the first instance for new deployments
 		JobContext:        jobContext{Name: ec.releaseJob.Name()},
 		Deployment:        ec.deploymentName,
 		NetworkContexts:   ec.buildNetworkContexts(),
@@ -92,15 +106,29 @@ func (ec jobEvaluationContext) MarshalJSON() ([]byte, error) {
 		ClusterProperties: ec.jobProperties,
 		JobProperties:     ec.releaseJobProperties,
 		DefaultProperties: defaultProperties,
+		Links:             ec.links,
 	}
 
-	if len(ec.address) > 0 {
-		context.Address = ec.address
+	if len(context.AZ) == 0 {
+		context.AZ = "unknown"
 	}
 
-	context.ID, err = ec.uuidGen.Generate()
-	if err != nil {
-		return []byte{}, bosherr.WrapErrorf(err, "Setting job eval context's ID to UUID: %#v", context)
+	if len(ec.instance.Address) > 0 {
+		context.Address = ec.instance.Address
+		context.IP = ec.instance.Address
+	}
+
+	if ec.instance.UseDNSAddresses {
+		if networkName, found := ec.instance.DefaultNetwork(); found {
+			context.Address = ec.instance.DNSRecordName(networkName, ec.deploymentName)
+		}
+	}
+
+	if len(context.ID) == 0 {
+		context.ID, err = ec.uuidGen.Generate()
+		if err != nil {
+			return []byte{}, bosherr.WrapErrorf(err, "Setting job eval context's ID to UUID: %#v", context)
+		}
 	}
 
 	ec.logger.Debug(ec.logTag, "Marshalling context %#v", context)
@@ -122,10 +150,27 @@ func (ec jobEvaluationContext) propertyDefaults(properties map[string]bireljob.P
 }
 
 func (ec jobEvaluationContext) buildNetworkContexts() map[string]networkContext {
-	// IP is being returned by agent
-	return map[string]networkContext{
-		"default": networkContext{
-			IP: "",
-		},
+	if len(ec.instance.Networks) == 0 {
+		// IP is being returned by agent
+		return map[string]networkContext{
+			"default": networkContext{
+				IP: "",
+			},
+		}
 	}
+
+	networkContexts := map[string]networkContext{}
+
+	for name, network := range ec.instance.Networks {
+		networkContexts[name] = networkContext{
+			IP:            network.IP,
+			Netmask:       network.Netmask,
+			Gateway:       network.Gateway,
+			DNS:           network.DNS,
+			Default:       network.Default,
+			DNSRecordName: ec.instance.DNSRecordName(name, ec.deploymentName),
+		}
+	}
+
+	return networkContexts
 }
@@ -25,6 +25,8 @@ var _ = Describe("JobEvaluationContext", func() {
 	var (
 		releaseJob              *boshreljob.Job
 		jobProperties           *biproperty.Map
+		links                   biproperty.Map
+		instance                InstanceSpec
 		instanceGroupProperties biproperty.Map
 		deploymentProperties    biproperty.Map
 		erbRenderer             erbrenderer.ERBRenderer
@@ -49,6 +51,8 @@ var _ = Describe("JobEvaluationContext", func() {
 
 		uuidGen = fakeuuid.NewFakeGenerator()
 		jobProperties = nil
+		links = nil
+		instance = InstanceSpec{Address: "1.2.3.4"}
 	})
 
 	JustBeforeEach(func() {
@@ -57,10 +61,11 @@ var _ = Describe("JobEvaluationContext", func() {
 		jobEvaluationContext = NewJobEvaluationContext(
 			*releaseJob,
 			jobProperties,
+			links,
 			instanceGroupProperties,
 			deploymentProperties,
 			"fake-deployment-name",
-			"1.2.3.4",
+			instance,
 			uuidGen,
 			logger,
 		)
@@ -103,6 +108,101 @@ var _ = Describe("JobEvaluationContext", func() {
 		generatedContext := act()
 		Expect(generatedContext.Bootstrap).To(Equal(true))
 	})
+
+	Context("when the instance is described", func() {
+		BeforeEach(func() {
+			instance = InstanceSpec{
+				Name:    "fake-instance-group",
+				ID:      "fake-instance-id",
+				Index:   1,
+				AZ:      "z1",
+				Address: "10.0.0.6",
+				Networks: map[string]NetworkSpec{
+					"private": {
+						IP:      "10.0.0.6",
+						Netmask: "255.255.255.0",
+						Gateway: "10.0.0.1",
+						DNS:     []string{"8.8.8.8"},
+						Default: []string{"dns", "gateway"},
+					},
+				},
+			}
+		})
+
+		It("it has the instance available in the spec", func() {
+			uuidGen.GeneratedUUID = "fake-uuid"
+
+			generatedContext := act()
+			Expect(generatedContext.Name).To(Equal("fake-instance-group"))
+			Expect(generatedContext.ID).To(Equal("fake-instance-id"))
+			Expect(generatedContext.Index).To(Equal(1))
+			Expect(generatedContext.AZ).To(Equal("z1"))
+			Expect(generatedContext.Bootstrap).To(BeFalse())
+			Expect(generatedContext.Address).To(Equal("10.0.0.6"))
+			Expect(generatedContext.IP).To(Equal("10.0.0.6"))
+			Expect(generatedContext.Deployment).To(Equal("fake-deployment-name"))
+		})
+
+		It("it has the networks of the instance available in the spec", func() {
+			generatedContext := act()
+			Expect(generatedContext.NetworkContexts).To(HaveLen(1))
+
+			network := generatedContext.NetworkContexts["private"]
+			Expect(network.IP).To(Equal("10.0.0.6"))
+			Expect(network.Netmask).To(Equal("255.255.255.0"))
+			Expect(network.Gateway).To(Equal("10.0.0.1"))
+			Expect(network.DNS).To(Equal([]string{"8.8.8.8"}))
+			Expect(network.Default).To(Equal([]string{"dns", "gateway"}))
+			Expect(network.DNSRecordName).To(Equal("1.fake-instance-group.private.fake-deployment-name.bosh"))
+		})
+
+		Context("when a DNS domain name is given", func() {
+			BeforeEach(func() {
+				instance.Name = "Fake_Instance_Group"
+				instance.DNSDomainName = "internal.example.com"
+			})
+
+			It("uses it in canonical DNS record names", func() {
+				generatedContext := act()
+				Expect(generatedContext.NetworkContexts["private"].DNSRecordName).To(Equal("1.fake-instance-group.private.fake-deployment-name.internal.example.com"))
+			})
+		})
+
+		Context("when DNS addresses are used", func() {
+			BeforeEach(func() {
+				instance.UseDNSAddresses = true
+				instance.Networks["public"] = NetworkSpec{IP: "1.2.3.4"}
+			})
+
+			It("has the DNS record name of the default network as the address", func() {
+				generatedContext := act()
+				Expect(generatedContext.Address).To(Equal("1.fake-instance-group.private.fake-deployment-name.bosh"))
+				Expect(generatedContext.IP).To(Equal("10.0.0.6"))
+			})
+		})
+	})
+
+	It("it has no links when the job does not consume any", func() {
+		generatedJSON, err := jobEvaluationContext.MarshalJSON()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(generatedJSON)).ToNot(ContainSubstring(`"links"`))
+	})
+
+	Context("when the job consumes links", func() {
+		BeforeEach(func() {
+			links = biproperty.Map{
+				"db": map[string]interface{}{
+					"instances":  []interface{}{map[string]interface{}{"address": "10.0.0.5"}},
+					"properties": map[string]interface{}{"port": "5432"},
+				},
+			}
+		})
+
+		It("it has the links available in the spec", func() {
+			generatedContext := act()
+			Expect(generatedContext.Links).To(Equal(links))
+		})
+	})
 	Context("when the UUID generator raise an error", func() {
 		It("it raises an error", func() {
 			uuidGen.GenerateError = errors.Error("boom")
@@ -112,7 +212,7 @@ var _ = Describe("JobEvaluationContext", func() {
 		})
 	})
 
-	getValueFor := func(key string) string {
+	renderTemplate := func(erbContents string) string {
 		logger := boshlog.NewLogger(boshlog.LevelNone)
 		fs := boshsys.NewOsFileSystem(logger)
 		commandRunner := boshsys.NewExecCmdRunner(logger)
@@ -122,7 +222,6 @@ var _ = Describe("JobEvaluationContext", func() {
 		Expect(err).ToNot(HaveOccurred())
 		defer os.Remove(srcFile.Name())
 
-		erbContents := fmt.Sprintf("<%%= p('%s') %%>", key)
 		_, err = srcFile.WriteString(erbContents)
 		Expect(err).ToNot(HaveOccurred())
 
@@ -135,10 +234,11 @@ var _ = Describe("JobEvaluationContext", func() {
 		jobEvaluationContext := NewJobEvaluationContext(
 			*releaseJob,
 			jobProperties,
+			links,
 			instanceGroupProperties,
 			deploymentProperties,
 			"fake-deployment-name",
-			"1.2.3.4",
+			instance,
 			uuidGen,
 			logger,
 		)
@@ -150,6 +250,38 @@ var _ = Describe("JobEvaluationContext", func() {
 		return (string)(contents)
 	}
 
+	getValueFor := func(key string) string {
+		return renderTemplate(fmt.Sprintf("<%%= p('%s') %%>", key))
+	}
+
+	Context("when a template uses a consumed link", func() {
+		BeforeEach(func() {
+			links = biproperty.Map{
+				"db": biproperty.Map{
+					"instances": biproperty.List{
+						biproperty.Map{"name": "db", "index": 0, "address": "10.0.0.5", "bootstrap": true},
+					},
+					"properties": biproperty.Map{"port": 5432},
+				},
+			}
+		})
+
+		It("exposes the instances and properties of the link", func() {
+			contents := renderTemplate("<%= link('db').instances[0].address %>:<%= link('db').p('port') %>")
+			Expect(contents).To(Equal("10.0.0.5:5432"))
+		})
+
+		It("yields the link with if_link", func() {
+			contents := renderTemplate("<% if_link('db') do |db| %><%= db.p('port') %><% end.else do %>none<% end %>")
+			Expect(contents).To(Equal("5432"))
+		})
+
+		It("uses the else block of if_link when the link is not consumed", func() {
+			contents := renderTemplate("<% if_link('cache') do |cache| %>cache<% end.else do %>none<% end %>")
+			Expect(contents).To(Equal("none"))
+		})
+	})
+
 	Context("when a deployment and instance group set a property", func() {
 		BeforeEach(func() {
 			deploymentProperties = biproperty.Map{
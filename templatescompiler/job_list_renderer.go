@@ -1,6 +1,8 @@
 package templatescompiler
 
 import (
+	"sync"
+
 	bireljob "github.com/cloudfoundry/bosh-cli/release/job"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -11,15 +13,18 @@ type JobListRenderer interface {
 	Render(
 		releaseJobs []bireljob.Job,
 		releaseJobProperties map[string]*biproperty.Map,
+		releaseJobLinks map[string]biproperty.Map,
 		jobProperties biproperty.Map,
 		globalProperties biproperty.Map,
 		deploymentName string,
-		address string,
+		instance InstanceSpec,
+		stemcellOS string,
 	) (RenderedJobList, error)
 }
 
 type jobListRenderer struct {
 	jobRenderer JobRenderer
+	workers     int
 	logger      boshlog.Logger
 	logTag      string
 }
@@ -28,8 +33,25 @@ func NewJobListRenderer(
 	jobRenderer JobRenderer,
 	logger boshlog.Logger,
 ) JobListRenderer {
+	return NewParallelJobListRenderer(jobRenderer, 1, logger)
+}
+
+// NewParallelJobListRenderer returns a renderer that renders up to workers
+// jobs concurrently. Rendered jobs keep the order of the release jobs, and
+// when rendering fails the errors of all failed jobs are returned in that
+// same order.
+func NewParallelJobListRenderer(
+	jobRenderer JobRenderer,
+	workers int,
+	logger boshlog.Logger,
+) JobListRenderer {
+	if workers < 1 {
+		workers = 1
+	}
+
 	return &jobListRenderer{
 		jobRenderer: jobRenderer,
+		workers:     workers,
 		logger:      logger,
 		logTag:      "jobListRenderer",
 	}
@@ -38,17 +60,24 @@ func NewJobListRenderer(
 func (r *jobListRenderer) Render(
 	releaseJobs []bireljob.Job,
 	releaseJobProperties map[string]*biproperty.Map,
+	releaseJobLinks map[string]biproperty.Map,
 	jobProperties biproperty.Map,
 	globalProperties biproperty.Map,
 	deploymentName string,
-	address string,
+	instance InstanceSpec,
+	stemcellOS string,
 ) (RenderedJobList, error) {
 	r.logger.Debug(r.logTag, "Rendering job list: deploymentName='%s' jobProperties=%#v globalProperties=%#v", deploymentName, jobProperties, globalProperties)
+
+	if r.workers > 1 {
+		return r.renderParallel(releaseJobs, releaseJobProperties, releaseJobLinks, jobProperties, globalProperties, deploymentName, instance, stemcellOS)
+	}
+
 	renderedJobList := NewRenderedJobList()
 
 	// render all the jobs' templates
 	for _, releaseJob := range releaseJobs {
-		renderedJob, err := r.jobRenderer.Render(releaseJob, releaseJobProperties[releaseJob.Name()], jobProperties, globalProperties, deploymentName, address)
+		renderedJob, err := r.jobRenderer.Render(releaseJob, releaseJobProperties[releaseJob.Name()], releaseJobLinks[releaseJob.Name()], jobProperties, globalProperties, deploymentName, instance, stemcellOS)
 		if err != nil {
 			defer renderedJobList.DeleteSilently()
 			return renderedJobList, bosherr.WrapErrorf(err, "Rendering templates for job '%s/%s'", releaseJob.Name(), releaseJob.Fingerprint())
@@ -58,3 +87,61 @@ func (r *jobListRenderer) Render(
 
 	return renderedJobList, nil
 }
+
+type renderJobResult struct {
+	renderedJob RenderedJob
+	err         error
+}
+
+func (r *jobListRenderer) renderParallel(
+	releaseJobs []bireljob.Job,
+	releaseJobProperties map[string]*biproperty.Map,
+	releaseJobLinks map[string]biproperty.Map,
+	jobProperties biproperty.Map,
+	globalProperties biproperty.Map,
+	deploymentName string,
+	instance InstanceSpec,
+	stemcellOS string,
+) (RenderedJobList, error) {
+	results := make([]renderJobResult, len(releaseJobs))
+
+	indexCh := make(chan int)
+	wg := &sync.WaitGroup{}
+
+	for w := 0; w < r.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexCh {
+				releaseJob := releaseJobs[i]
+				renderedJob, err := r.jobRenderer.Render(releaseJob, releaseJobProperties[releaseJob.Name()], releaseJobLinks[releaseJob.Name()], jobProperties, globalProperties, deploymentName, instance, stemcellOS)
+				results[i] = renderJobResult{renderedJob: renderedJob, err: err}
+			}
+		}()
+	}
+
+	for i := range releaseJobs {
+		indexCh <- i
+	}
+	close(indexCh)
+
+	wg.Wait()
+
+	renderedJobList := NewRenderedJobList()
+	errs := []error{}
+
+	for i, result := range results {
+		if result.err != nil {
+			errs = append(errs, bosherr.WrapErrorf(result.err, "Rendering templates for job '%s/%s'", releaseJobs[i].Name(), releaseJobs[i].Fingerprint()))
+			continue
+		}
+		renderedJobList.Add(result.renderedJob)
+	}
+
+	if len(errs) > 0 {
+		defer renderedJobList.DeleteSilently()
+		return renderedJobList, bosherr.NewMultiError(errs...)
+	}
+
+	return renderedJobList, nil
+}
@@ -32,10 +32,11 @@ var _ = Describe("JobListRenderer", func() {
 
 		releaseJobs          []boshreljob.Job
 		releaseJobProperties map[string]*biproperty.Map
+		releaseJobLinks      map[string]biproperty.Map
 		jobProperties        biproperty.Map
 		globalProperties     biproperty.Map
 		deploymentName       string
-		address              string
+		instance             InstanceSpec
 
 		renderedJobs []*mock_template.MockRenderedJob
 
@@ -61,6 +62,12 @@ var _ = Describe("JobListRenderer", func() {
 			"fake-release-job-name-1": &biproperty.Map{},
 		}
 
+		releaseJobLinks = map[string]biproperty.Map{
+			"fake-release-job-name-0": biproperty.Map{
+				"fake-link": biproperty.Map{"instance_group": "fake-instance-group"},
+			},
+		}
+
 		jobProperties = biproperty.Map{
 			"fake-key": "fake-job-value",
 		}
@@ -70,7 +77,7 @@ var _ = Describe("JobListRenderer", func() {
 		}
 
 		deploymentName = "fake-deployment-name"
-		address = "1.2.3.4"
+		instance = InstanceSpec{Address: "1.2.3.4"}
 
 		renderedJobs = []*mock_template.MockRenderedJob{
 			mock_template.NewMockRenderedJob(mockCtrl),
@@ -81,13 +88,13 @@ var _ = Describe("JobListRenderer", func() {
 	})
 
 	JustBeforeEach(func() {
-		mockJobRenderer.EXPECT().Render(releaseJobs[0], releaseJobProperties[releaseJobs[0].Name()], jobProperties, globalProperties, deploymentName, address).Return(renderedJobs[0], nil)
-		expectRender1 = mockJobRenderer.EXPECT().Render(releaseJobs[1], releaseJobProperties[releaseJobs[1].Name()], jobProperties, globalProperties, deploymentName, address).Return(renderedJobs[1], nil)
+		mockJobRenderer.EXPECT().Render(releaseJobs[0], releaseJobProperties[releaseJobs[0].Name()], releaseJobLinks[releaseJobs[0].Name()], jobProperties, globalProperties, deploymentName, instance, "").Return(renderedJobs[0], nil)
+		expectRender1 = mockJobRenderer.EXPECT().Render(releaseJobs[1], releaseJobProperties[releaseJobs[1].Name()], releaseJobLinks[releaseJobs[1].Name()], jobProperties, globalProperties, deploymentName, instance, "").Return(renderedJobs[1], nil)
 	})
 
 	Describe("Render", func() {
 		It("returns a new RenderedJobList with all the RenderedJobs", func() {
-			renderedJobList, err := jobListRenderer.Render(releaseJobs, releaseJobProperties, jobProperties, globalProperties, deploymentName, address)
+			renderedJobList, err := jobListRenderer.Render(releaseJobs, releaseJobProperties, releaseJobLinks, jobProperties, globalProperties, deploymentName, instance, "")
 			Expect(err).ToNot(HaveOccurred())
 			Expect(renderedJobList.All()).To(Equal([]RenderedJob{
 				renderedJobs[0],
@@ -103,11 +110,65 @@ var _ = Describe("JobListRenderer", func() {
 			It("returns an error and cleans up any sucessfully rendered jobs", func() {
 				renderedJobs[0].EXPECT().DeleteSilently()
 
-				_, err := jobListRenderer.Render(releaseJobs, releaseJobProperties, jobProperties, globalProperties, deploymentName, address)
+				_, err := jobListRenderer.Render(releaseJobs, releaseJobProperties, releaseJobLinks, jobProperties, globalProperties, deploymentName, instance, "")
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-render-error"))
 			})
 		})
+
+		Context("when rendering in parallel", func() {
+			BeforeEach(func() {
+				jobListRenderer = NewParallelJobListRenderer(mockJobRenderer, 2, logger)
+			})
+
+			It("returns the RenderedJobs in release job order", func() {
+				renderedJobList, err := jobListRenderer.Render(releaseJobs, releaseJobProperties, releaseJobLinks, jobProperties, globalProperties, deploymentName, instance, "")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(renderedJobList.All()).To(Equal([]RenderedJob{
+					renderedJobs[0],
+					renderedJobs[1],
+				}))
+			})
+
+			Context("when rendering a job fails", func() {
+				JustBeforeEach(func() {
+					expectRender1.Return(nil, bosherr.Error("fake-render-error"))
+				})
+
+				It("returns an error and cleans up any sucessfully rendered jobs", func() {
+					renderedJobs[0].EXPECT().DeleteSilently()
+
+					_, err := jobListRenderer.Render(releaseJobs, releaseJobProperties, releaseJobLinks, jobProperties, globalProperties, deploymentName, instance, "")
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Rendering templates for job 'fake-release-job-name-1/"))
+					Expect(err.Error()).To(ContainSubstring("fake-render-error"))
+				})
+			})
+
+			Context("when rendering several jobs fails", func() {
+				BeforeEach(func() {
+					releaseJobs = append(releaseJobs, *boshreljob.NewJob(NewResource("fake-release-job-name-2", "", nil)))
+				})
+
+				JustBeforeEach(func() {
+					expectRender1.Return(nil, bosherr.Error("fake-render-error-1"))
+					mockJobRenderer.EXPECT().Render(releaseJobs[2], releaseJobProperties[releaseJobs[2].Name()], releaseJobLinks[releaseJobs[2].Name()], jobProperties, globalProperties, deploymentName, instance, "").Return(nil, bosherr.Error("fake-render-error-2"))
+				})
+
+				It("returns the errors of every failed job in release job order", func() {
+					renderedJobs[0].EXPECT().DeleteSilently()
+
+					_, err := jobListRenderer.Render(releaseJobs, releaseJobProperties, releaseJobLinks, jobProperties, globalProperties, deploymentName, instance, "")
+					Expect(err).To(HaveOccurred())
+
+					multiErr, ok := err.(bosherr.MultiError)
+					Expect(ok).To(BeTrue())
+					Expect(multiErr.Errors).To(HaveLen(2))
+					Expect(multiErr.Errors[0].Error()).To(ContainSubstring("fake-render-error-1"))
+					Expect(multiErr.Errors[1].Error()).To(ContainSubstring("fake-render-error-2"))
+				})
+			})
+		})
 	})
 
 })
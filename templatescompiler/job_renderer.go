@@ -3,6 +3,7 @@ package templatescompiler
 import (
 	"os"
 	"path/filepath"
+	"strings"
 
 	bireljob "github.com/cloudfoundry/bosh-cli/release/job"
 	bierbrenderer "github.com/cloudfoundry/bosh-cli/templatescompiler/erbrenderer"
@@ -14,7 +15,13 @@ import (
 )
 
 type JobRenderer interface {
-	Render(releaseJob bireljob.Job, releaseJobProperties *biproperty.Map, jobProperties biproperty.Map, globalProperties biproperty.Map, deploymentName string, address string) (RenderedJob, error)
+	Render(releaseJob bireljob.Job, releaseJobProperties *biproperty.Map, links biproperty.Map, jobProperties biproperty.Map, globalProperties biproperty.Map, deploymentName string, instance InstanceSpec, stemcellOS string) (RenderedJob, error)
+}
+
+// IsWindowsOS reports whether a stemcell operating system, such as
+// windows2012R2 or windows2016, is Windows.
+func IsWindowsOS(stemcellOS string) bool {
+	return strings.HasPrefix(strings.ToLower(stemcellOS), "windows")
 }
 
 type jobRenderer struct {
@@ -40,8 +47,12 @@ func NewJobRenderer(
 	}
 }
 
-func (r *jobRenderer) Render(releaseJob bireljob.Job, releaseJobProperties *biproperty.Map, jobProperties biproperty.Map, globalProperties biproperty.Map, deploymentName string, address string) (RenderedJob, error) {
-	context := NewJobEvaluationContext(releaseJob, releaseJobProperties, jobProperties, globalProperties, deploymentName, address, r.uuidGen, r.logger)
+// Render renders the job templates and the monit file. Jobs for Windows
+// stemcells may use backslashes in template destinations, such as
+// bin\pre-start.ps1, and may leave out the monit file when they do not run
+// any processes.
+func (r *jobRenderer) Render(releaseJob bireljob.Job, releaseJobProperties *biproperty.Map, links biproperty.Map, jobProperties biproperty.Map, globalProperties biproperty.Map, deploymentName string, instance InstanceSpec, stemcellOS string) (RenderedJob, error) {
+	context := NewJobEvaluationContext(releaseJob, releaseJobProperties, links, jobProperties, globalProperties, deploymentName, instance, r.uuidGen, r.logger)
 
 	sourcePath := releaseJob.ExtractedPath()
 
@@ -52,7 +63,13 @@ func (r *jobRenderer) Render(releaseJob bireljob.Job, releaseJobProperties *bipr
 
 	renderedJob := NewRenderedJob(releaseJob, destinationPath, r.fs, r.logger)
 
+	windows := IsWindowsOS(stemcellOS)
+
 	for src, dst := range releaseJob.Templates {
+		if windows {
+			dst = strings.Replace(dst, `\`, "/", -1)
+		}
+
 		err := r.renderFile(
 			filepath.Join(sourcePath, "templates", src),
 			filepath.Join(destinationPath, dst),
@@ -64,8 +81,15 @@ func (r *jobRenderer) Render(releaseJob bireljob.Job, releaseJobProperties *bipr
 		}
 	}
 
+	monitPath := filepath.Join(sourcePath, "monit")
+
+	if windows && !r.fs.FileExists(monitPath) {
+		r.logger.Debug(r.logTag, "Skipping missing monit file of Windows job '%s'", releaseJob.Name())
+		return renderedJob, nil
+	}
+
 	err = r.renderFile(
-		filepath.Join(sourcePath, "monit"),
+		monitPath,
 		filepath.Join(destinationPath, "monit"),
 		context,
 	)
@@ -29,6 +29,7 @@ var _ = Describe("JobRenderer", func() {
 		globalProperties     biproperty.Map
 		srcPath              string
 		dstPath              string
+		logger               boshlog.Logger
 	)
 
 	BeforeEach(func() {
@@ -54,9 +55,9 @@ var _ = Describe("JobRenderer", func() {
 			"director.yml.erb": "config/director.yml",
 		}
 
-		logger := boshlog.NewLogger(boshlog.LevelNone)
+		logger = boshlog.NewLogger(boshlog.LevelNone)
 
-		context = NewJobEvaluationContext(*job, &releaseJobProperties, jobProperties, globalProperties, "fake-deployment-name", "1.2.3.4", nil, logger)
+		context = NewJobEvaluationContext(*job, &releaseJobProperties, nil, jobProperties, globalProperties, "fake-deployment-name", InstanceSpec{Address: "1.2.3.4"}, nil, logger)
 
 		fakeERBRenderer = fakebirender.NewFakeERBRender()
 
@@ -87,7 +88,7 @@ var _ = Describe("JobRenderer", func() {
 
 	Describe("Render", func() {
 		It("renders job templates", func() {
-			renderedjob, err := jobRenderer.Render(*job, &releaseJobProperties, jobProperties, globalProperties, "fake-deployment-name", "1.2.3.4")
+			renderedjob, err := jobRenderer.Render(*job, &releaseJobProperties, nil, jobProperties, globalProperties, "fake-deployment-name", InstanceSpec{Address: "1.2.3.4"}, "ubuntu-trusty")
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(fakeERBRenderer.RenderInputs).To(Equal([]fakebirender.RenderInput{
@@ -115,10 +116,67 @@ var _ = Describe("JobRenderer", func() {
 			})
 
 			It("returns an error", func() {
-				_, err := jobRenderer.Render(*job, &releaseJobProperties, jobProperties, globalProperties, "fake-deployment-name", "1.2.3.4")
+				_, err := jobRenderer.Render(*job, &releaseJobProperties, nil, jobProperties, globalProperties, "fake-deployment-name", InstanceSpec{Address: "1.2.3.4"}, "ubuntu-trusty")
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-template-render-error"))
 			})
 		})
+
+		Context("when the stemcell is Windows", func() {
+			BeforeEach(func() {
+				job.Templates = map[string]string{
+					"pre-start.ps1.erb": `bin\pre-start.ps1`,
+				}
+
+				context = NewJobEvaluationContext(*job, &releaseJobProperties, nil, jobProperties, globalProperties, "fake-deployment-name", InstanceSpec{Address: "1.2.3.4"}, nil, logger)
+
+				fakeERBRenderer.SetRenderBehavior(
+					filepath.Join(srcPath, "templates/pre-start.ps1.erb"),
+					filepath.Join(dstPath, "bin/pre-start.ps1"),
+					context,
+					nil,
+				)
+			})
+
+			It("renders templates with backslashes in their destination into directories", func() {
+				fs.WriteFileString(filepath.Join(srcPath, "monit"), "")
+
+				renderedjob, err := jobRenderer.Render(*job, &releaseJobProperties, nil, jobProperties, globalProperties, "fake-deployment-name", InstanceSpec{Address: "1.2.3.4"}, "windows2012R2")
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeERBRenderer.RenderInputs).To(Equal([]fakebirender.RenderInput{
+					{
+						SrcPath: filepath.Join(srcPath, "templates/pre-start.ps1.erb"),
+						DstPath: filepath.Join(renderedjob.Path(), "bin/pre-start.ps1"),
+						Context: context,
+					},
+					{
+						SrcPath: filepath.Join(srcPath, "monit"),
+						DstPath: filepath.Join(renderedjob.Path(), "monit"),
+						Context: context,
+					},
+				}))
+			})
+
+			It("skips the monit file when the job does not have one", func() {
+				_, err := jobRenderer.Render(*job, &releaseJobProperties, nil, jobProperties, globalProperties, "fake-deployment-name", InstanceSpec{Address: "1.2.3.4"}, "windows2016")
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeERBRenderer.RenderInputs).To(HaveLen(1))
+				Expect(fakeERBRenderer.RenderInputs[0].SrcPath).To(Equal(filepath.Join(srcPath, "templates/pre-start.ps1.erb")))
+			})
+		})
+	})
+
+	Describe("IsWindowsOS", func() {
+		It("returns true for Windows stemcell operating systems", func() {
+			Expect(IsWindowsOS("windows2012R2")).To(BeTrue())
+			Expect(IsWindowsOS("Windows2016")).To(BeTrue())
+		})
+
+		It("returns false for other stemcell operating systems", func() {
+			Expect(IsWindowsOS("ubuntu-trusty")).To(BeFalse())
+			Expect(IsWindowsOS("")).To(BeFalse())
+		})
 	})
 })
@@ -0,0 +1,22 @@
+package ui
+
+// BufferableStage is a Stage whose output can be held back, e.g. while its
+// steps are performed concurrently with the steps of other stages.
+type BufferableStage interface {
+	Stage
+
+	// NewBufferedStage returns a Stage performing its steps like this one,
+	// but holding back their output until flush is called.
+	NewBufferedStage() (buffered Stage, flush func())
+}
+
+// NewBufferedStage returns a Stage holding back the output of its steps
+// until flush is called, if the given stage supports it. Otherwise the
+// stage itself is returned and flush does nothing.
+func NewBufferedStage(stage Stage) (Stage, func()) {
+	if bufferable, ok := stage.(BufferableStage); ok {
+		return bufferable.NewBufferedStage()
+	}
+
+	return stage, func() {}
+}
@@ -0,0 +1,115 @@
+package ui
+
+import (
+	"fmt"
+	"sync"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	. "github.com/cloudfoundry/bosh-cli/ui/table"
+)
+
+// bufferedUI holds back the output written to it until it is flushed to
+// another UI, so that output of concurrent steps does not interleave.
+type bufferedUI struct {
+	calls []func(UI)
+	first string
+	lock  sync.Mutex
+}
+
+func newBufferedUI() *bufferedUI {
+	return &bufferedUI{}
+}
+
+func (ui *bufferedUI) ErrorLinef(pattern string, args ...interface{}) {
+	message := fmt.Sprintf(pattern, args...)
+	ui.record(func(parent UI) { parent.ErrorLinef("%s", message) })
+}
+
+func (ui *bufferedUI) PrintLinef(pattern string, args ...interface{}) {
+	message := fmt.Sprintf(pattern, args...)
+	ui.record(func(parent UI) { parent.PrintLinef("%s", message) })
+}
+
+func (ui *bufferedUI) BeginLinef(pattern string, args ...interface{}) {
+	message := fmt.Sprintf(pattern, args...)
+
+	ui.lock.Lock()
+	if len(ui.calls) == 0 {
+		ui.first = message
+	}
+	ui.lock.Unlock()
+
+	ui.record(func(parent UI) { parent.BeginLinef("%s", message) })
+}
+
+func (ui *bufferedUI) EndLinef(pattern string, args ...interface{}) {
+	message := fmt.Sprintf(pattern, args...)
+	ui.record(func(parent UI) { parent.EndLinef("%s", message) })
+}
+
+func (ui *bufferedUI) PrintBlock(block string) {
+	ui.record(func(parent UI) { parent.PrintBlock(block) })
+}
+
+func (ui *bufferedUI) PrintErrorBlock(block string) {
+	ui.record(func(parent UI) { parent.PrintErrorBlock(block) })
+}
+
+func (ui *bufferedUI) PrintTable(table Table) {
+	ui.record(func(parent UI) { parent.PrintTable(table) })
+}
+
+// Prompts fail since their label would only be shown once the answer is
+// no longer needed
+
+func (ui *bufferedUI) AskForText(label string) (string, error) {
+	return "", bosherr.Errorf("Cannot ask for input '%s' while output is buffered", label)
+}
+
+func (ui *bufferedUI) AskForChoice(label string, options []string) (int, error) {
+	return 0, bosherr.Errorf("Cannot ask for a choice '%s' while output is buffered", label)
+}
+
+func (ui *bufferedUI) AskForPassword(label string) (string, error) {
+	return "", bosherr.Errorf("Cannot ask for password '%s' while output is buffered", label)
+}
+
+func (ui *bufferedUI) AskForConfirmation() error {
+	return bosherr.Error("Cannot ask for confirmation while output is buffered")
+}
+
+func (ui *bufferedUI) IsInteractive() bool {
+	return false
+}
+
+func (ui *bufferedUI) Flush() {}
+
+// firstLine returns the line begun first, if the output held back starts with one
+func (ui *bufferedUI) firstLine() (string, bool) {
+	ui.lock.Lock()
+	defer ui.lock.Unlock()
+
+	return ui.first, len(ui.calls) > 0 && ui.first != ""
+}
+
+// flushTo writes the output held back so far to the parent UI
+func (ui *bufferedUI) flushTo(parent UI) {
+	ui.lock.Lock()
+	defer ui.lock.Unlock()
+
+	for _, call := range ui.calls {
+		call(parent)
+	}
+	ui.calls = nil
+	ui.first = ""
+
+	parent.Flush()
+}
+
+func (ui *bufferedUI) record(call func(UI)) {
+	ui.lock.Lock()
+	defer ui.lock.Unlock()
+
+	ui.calls = append(ui.calls, call)
+}
@@ -14,6 +14,21 @@ type PerformCall struct {
 	Error     error
 	SkipError error
 	Stage     *FakeStage
+	Progress  *FakeProgress
+}
+
+type FakeProgress struct {
+	Total int64
+	Done  int64
+}
+
+func (p *FakeProgress) SetTotal(total int64) {
+	p.Total = total
+	p.Done = 0
+}
+
+func (p *FakeProgress) Add(n int64) {
+	p.Done += n
 }
 
 func NewFakeStage() *FakeStage {
@@ -43,6 +58,18 @@ func (s *FakeStage) Perform(name string, closure func() error) error {
 	return err
 }
 
+func (s *FakeStage) PerformWithProgress(name string, closure func(biui.Progress) error) error {
+	progress := &FakeProgress{}
+
+	err := s.Perform(name, func() error {
+		return closure(progress)
+	})
+
+	s.PerformCalls[len(s.PerformCalls)-1].Progress = progress
+
+	return err
+}
+
 func (s *FakeStage) PerformComplex(name string, closure func(biui.Stage) error) error {
 	subStage := NewFakeStage()
 
@@ -71,3 +98,14 @@ func (s *FakeStage) PerformComplex(name string, closure func(biui.Stage) error)
 
 	return err
 }
+
+// NewBufferedStage returns a new FakeStage whose calls are appended to the
+// calls of this stage when flush is called.
+func (s *FakeStage) NewBufferedStage() (biui.Stage, func()) {
+	buffered := NewFakeStage()
+
+	return buffered, func() {
+		s.PerformCalls = append(s.PerformCalls, buffered.PerformCalls...)
+		s.SubStages = append(s.SubStages, buffered.SubStages...)
+	}
+}
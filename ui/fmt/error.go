@@ -16,7 +16,19 @@ func MultilineError(err error) string {
 	return prefixingMultilineError(err, "", "")
 }
 
+// categorizedError only tells the failure category of the error it wraps,
+// e.g. cmd.ValidationError, so the wrapped error is shown instead.
+type categorizedError interface {
+	error
+	ExitCode() int
+	Cause() error
+}
+
 func prefixingMultilineError(err error, prefix string, bullet string) string {
+	if categorizedErr, ok := err.(categorizedError); ok {
+		return prefixingMultilineError(categorizedErr.Cause(), prefix, bullet)
+	}
+
 	currPrefix := prefix + bullet
 	prefix = prefix + strings.Repeat(" ", len(bullet))
 
@@ -9,6 +9,12 @@ import (
 	. "github.com/cloudfoundry/bosh-cli/ui/fmt"
 )
 
+type fakeCategorizedError struct{ err error }
+
+func (e fakeCategorizedError) Error() string { return e.err.Error() }
+func (e fakeCategorizedError) Cause() error  { return e.err }
+func (e fakeCategorizedError) ExitCode() int { return 2 }
+
 var _ = Describe("MultilineError", func() {
 	var (
 		err error
@@ -36,6 +42,13 @@ var _ = Describe("MultilineError", func() {
 		})
 	})
 
+	Context("when given a categorized error", func() {
+		It("returns the message of the error it wraps", func() {
+			err = bosherr.WrapError(fakeCategorizedError{err: bosherr.WrapError(bosherr.Error("inner omg"), "omg")}, "outer omg")
+			Expect(MultilineError(err)).To(Equal("outer omg:\n  omg:\n    inner omg"))
+		})
+	})
+
 	Context("when given an explainable error", func() {
 		It("returns a multi-line message string with sibling errors at the same indentation", func() {
 			err = bosherr.NewMultiError(bosherr.Error("a"), bosherr.Error("b"))
@@ -2,6 +2,7 @@ package ui
 
 import (
 	"fmt"
+	"strings"
 
 	. "github.com/cloudfoundry/bosh-cli/ui/table"
 )
@@ -23,7 +24,15 @@ func (ui *indentingUI) PrintLinef(pattern string, args ...interface{}) {
 }
 
 func (ui *indentingUI) BeginLinef(pattern string, args ...interface{}) {
-	ui.parent.BeginLinef("  %s", fmt.Sprintf(pattern, args...))
+	message := fmt.Sprintf(pattern, args...)
+
+	// keep the indentation of lines redrawn from their start, e.g. progress bars
+	if strings.HasPrefix(message, "\r") {
+		ui.parent.BeginLinef("\r  %s", strings.TrimPrefix(message, "\r"))
+		return
+	}
+
+	ui.parent.BeginLinef("  %s", message)
 }
 
 func (ui *indentingUI) EndLinef(pattern string, args ...interface{}) {
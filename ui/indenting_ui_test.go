@@ -55,6 +55,11 @@ var _ = Describe("IndentingUI", func() {
 			Expect(uiOut.String()).To(ContainSubstring("  fake-start"))
 			Expect(uiErr.String()).To(BeEmpty())
 		})
+
+		It("indents after a leading carriage return", func() {
+			ui.BeginLinef("\rfake-redrawn-line")
+			Expect(uiOut.String()).To(Equal("\r  fake-redrawn-line"))
+		})
 	})
 
 	Describe("EndLinef", func() {
@@ -0,0 +1,84 @@
+package ui
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrInterrupted is returned by the first step of an interruptible stage that
+// is started after the stage is interrupted.
+var ErrInterrupted = errors.New("Interrupted")
+
+type interruptibleStage struct {
+	stage       Stage
+	interrupted <-chan struct{}
+	stopped     *int32
+}
+
+// NewInterruptibleStage returns a Stage that stops once interrupted is
+// closed: the step in progress is finished, and the next step started fails
+// with ErrInterrupted without running. Steps started after that, e.g. to
+// clean up while the error is returned, run as usual.
+func NewInterruptibleStage(stage Stage, interrupted <-chan struct{}) Stage {
+	return &interruptibleStage{
+		stage:       stage,
+		interrupted: interrupted,
+		stopped:     new(int32),
+	}
+}
+
+func (s *interruptibleStage) Perform(name string, closure func() error) error {
+	if s.stop() {
+		return ErrInterrupted
+	}
+
+	return s.stage.Perform(name, closure)
+}
+
+func (s *interruptibleStage) PerformWithProgress(name string, closure func(Progress) error) error {
+	if s.stop() {
+		return ErrInterrupted
+	}
+
+	return s.stage.PerformWithProgress(name, closure)
+}
+
+func (s *interruptibleStage) PerformComplex(name string, closure func(Stage) error) error {
+	if s.stop() {
+		return ErrInterrupted
+	}
+
+	return s.stage.PerformComplex(name, func(subStage Stage) error {
+		return closure(&interruptibleStage{stage: subStage, interrupted: s.interrupted, stopped: s.stopped})
+	})
+}
+
+func (s *interruptibleStage) NewBufferedStage() (Stage, func()) {
+	buffered, flush := NewBufferedStage(s.stage)
+
+	return &interruptibleStage{stage: buffered, interrupted: s.interrupted, stopped: s.stopped}, flush
+}
+
+// NewUninterruptibleStage returns the stage wrapped by the interruptible
+// stages around the given stage, whose steps run even after the
+// interruption, e.g. to roll back what an interrupted deploy created.
+func NewUninterruptibleStage(stage Stage) Stage {
+	for {
+		interruptible, ok := stage.(*interruptibleStage)
+		if !ok {
+			return stage
+		}
+
+		stage = interruptible.stage
+	}
+}
+
+// stop returns true for the first step started after the interruption.
+func (s *interruptibleStage) stop() bool {
+	select {
+	case <-s.interrupted:
+		return atomic.CompareAndSwapInt32(s.stopped, 0, 1)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,114 @@
+package ui_test
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/cloudfoundry/bosh-cli/ui"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	"github.com/pivotal-golang/clock/fakeclock"
+)
+
+var _ = Describe("InterruptibleStage", func() {
+	var (
+		stage       Stage
+		interrupted chan struct{}
+		uiOut       *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		uiOut = bytes.NewBufferString("")
+		ui := NewWriterUI(uiOut, bytes.NewBufferString(""), logger)
+
+		interrupted = make(chan struct{})
+		stage = NewInterruptibleStage(NewStage(ui, fakeclock.NewFakeClock(time.Now()), logger), interrupted)
+	})
+
+	It("performs steps until it is interrupted", func() {
+		err := stage.Perform("First step", func() error { return nil })
+		Expect(err).ToNot(HaveOccurred())
+		Expect(uiOut.String()).To(ContainSubstring("First step... Finished"))
+	})
+
+	It("finishes the step in progress when it is interrupted", func() {
+		err := stage.Perform("First step", func() error {
+			close(interrupted)
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(uiOut.String()).To(ContainSubstring("First step... Finished"))
+	})
+
+	Context("when it is interrupted", func() {
+		BeforeEach(func() {
+			close(interrupted)
+		})
+
+		It("does not run the next step", func() {
+			run := false
+			err := stage.Perform("Next step", func() error {
+				run = true
+				return nil
+			})
+			Expect(err).To(Equal(ErrInterrupted))
+			Expect(run).To(BeFalse())
+			Expect(uiOut.String()).ToNot(ContainSubstring("Next step"))
+		})
+
+		It("runs the steps after the one it stopped, e.g. to clean up", func() {
+			err := stage.Perform("Next step", func() error { return nil })
+			Expect(err).To(Equal(ErrInterrupted))
+
+			err = stage.Perform("Cleaning up", func() error { return nil })
+			Expect(err).ToNot(HaveOccurred())
+			Expect(uiOut.String()).To(ContainSubstring("Cleaning up... Finished"))
+		})
+
+		It("stops once across complex stages", func() {
+			err := stage.PerformComplex("deploying", func(subStage Stage) error { return nil })
+			Expect(err).To(Equal(ErrInterrupted))
+
+			err = stage.PerformComplex("cleaning up", func(subStage Stage) error {
+				return subStage.Perform("Deleting files", func() error { return nil })
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	It("stops the steps of complex stages", func() {
+		err := stage.PerformComplex("deploying", func(subStage Stage) error {
+			close(interrupted)
+			return subStage.Perform("Creating VM", func() error { return nil })
+		})
+		Expect(err).To(Equal(ErrInterrupted))
+		Expect(uiOut.String()).ToNot(ContainSubstring("Creating VM"))
+	})
+
+	Describe("NewUninterruptibleStage", func() {
+		It("performs the steps of an interrupted stage, however deeply it is wrapped", func() {
+			timedOut := make(chan struct{})
+			stage = NewInterruptibleStage(stage, timedOut)
+
+			close(interrupted)
+			close(timedOut)
+
+			err := NewUninterruptibleStage(stage).Perform("Rolling back", func() error { return nil })
+			Expect(err).ToNot(HaveOccurred())
+			Expect(uiOut.String()).To(ContainSubstring("Rolling back... Finished"))
+		})
+
+		It("performs the steps of the complex stages of an interrupted stage", func() {
+			err := stage.PerformComplex("deploying", func(subStage Stage) error {
+				close(interrupted)
+
+				return NewUninterruptibleStage(subStage).Perform("Rolling back", func() error { return nil })
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(uiOut.String()).To(ContainSubstring("Rolling back... Finished"))
+		})
+	})
+})
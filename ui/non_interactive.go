@@ -1,6 +1,8 @@
 package ui
 
 import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
 	. "github.com/cloudfoundry/bosh-cli/ui/table"
 )
 
@@ -40,16 +42,19 @@ func (ui *nonInteractiveUI) PrintTable(table Table) {
 	ui.parent.PrintTable(table)
 }
 
+// Prompts other than confirmations fail so that unattended runs stop
+// instead of waiting for input that never comes
+
 func (ui *nonInteractiveUI) AskForText(label string) (string, error) {
-	panic("Cannot ask for input in non-interactive UI")
+	return "", bosherr.Errorf("Cannot ask for input '%s' in non-interactive UI", label)
 }
 
 func (ui *nonInteractiveUI) AskForChoice(label string, options []string) (int, error) {
-	panic("Cannot ask for a choice in non-interactive UI")
+	return 0, bosherr.Errorf("Cannot ask for a choice '%s' in non-interactive UI", label)
 }
 
 func (ui *nonInteractiveUI) AskForPassword(label string) (string, error) {
-	panic("Cannot ask for password in non-interactive UI")
+	return "", bosherr.Errorf("Cannot ask for password '%s' in non-interactive UI", label)
 }
 
 func (ui *nonInteractiveUI) AskForConfirmation() error {
@@ -76,20 +76,23 @@ var _ = Describe("NonInteractiveUI", func() {
 	})
 
 	Describe("AskForText", func() {
-		It("panics", func() {
-			Expect(func() { ui.AskForText("") }).To(Panic())
+		It("returns an error", func() {
+			_, err := ui.AskForText("fake-label")
+			Expect(err).To(MatchError("Cannot ask for input 'fake-label' in non-interactive UI"))
 		})
 	})
 
 	Describe("AskForPassword", func() {
-		It("panics", func() {
-			Expect(func() { ui.AskForPassword("") }).To(Panic())
+		It("returns an error", func() {
+			_, err := ui.AskForPassword("fake-label")
+			Expect(err).To(MatchError("Cannot ask for password 'fake-label' in non-interactive UI"))
 		})
 	})
 
 	Describe("AskForChoice", func() {
-		It("panics", func() {
-			Expect(func() { ui.AskForChoice("", nil) }).To(Panic())
+		It("returns an error", func() {
+			_, err := ui.AskForChoice("fake-label", nil)
+			Expect(err).To(MatchError("Cannot ask for a choice 'fake-label' in non-interactive UI"))
 		})
 	})
 
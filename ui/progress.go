@@ -0,0 +1,137 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	biuifmt "github.com/cloudfoundry/bosh-cli/ui/fmt"
+	"github.com/pivotal-golang/clock"
+)
+
+const (
+	progressBarWidth       = 20
+	progressRenderInterval = 500 * time.Millisecond
+)
+
+// Progress is reported by steps that know how much work they have to do,
+// e.g. bytes to download or packages to install.
+type Progress interface {
+	// SetTotal sets the amount of work of the step and starts counting the
+	// work done from zero, e.g. when a download is retried.
+	SetTotal(total int64)
+
+	// Add marks n more units of work as done.
+	Add(n int64)
+}
+
+type progressBar struct {
+	ui          UI
+	timeService clock.Clock
+	name        string
+
+	total       int64
+	done        int64
+	startTime   time.Time
+	renderTime  time.Time
+	renderedLen int
+
+	lock sync.Mutex
+}
+
+func newProgressBar(ui UI, timeService clock.Clock, name string) *progressBar {
+	return &progressBar{
+		ui:          ui,
+		timeService: timeService,
+		name:        name,
+	}
+}
+
+func (p *progressBar) SetTotal(total int64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.total = total
+	p.done = 0
+	p.startTime = p.timeService.Now()
+	p.render(p.startTime)
+}
+
+func (p *progressBar) Add(n int64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.total <= 0 {
+		return
+	}
+
+	p.done += n
+	if p.done > p.total {
+		p.done = p.total
+	}
+
+	// avoid flooding the terminal with fast progress
+	now := p.timeService.Now()
+	if now.Sub(p.renderTime) >= progressRenderInterval || p.done == p.total {
+		p.render(now)
+	}
+}
+
+// render redraws the line of the step with a bar, the percentage done and
+// the time the rest of the work is expected to take
+func (p *progressBar) render(now time.Time) {
+	if p.total <= 0 {
+		return
+	}
+
+	filled := int(p.done * progressBarWidth / p.total)
+	line := fmt.Sprintf("[%s%s] %3d%%",
+		strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled), p.done*100/p.total)
+
+	if p.done > 0 && p.done < p.total {
+		elapsed := now.Sub(p.startTime)
+		eta := time.Duration(float64(elapsed) * float64(p.total-p.done) / float64(p.done))
+		line += " ETA " + biuifmt.Duration(eta)
+	}
+
+	p.ui.BeginLinef("\r%s... %s%s", p.name, line, p.padding(len(line)))
+	p.renderTime = now
+	p.renderedLen = len(line)
+}
+
+// clear removes the bar so that the step's line can be ended as usual
+func (p *progressBar) clear() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.renderedLen > 0 {
+		p.ui.BeginLinef("\r%s... %s", p.name, p.padding(0))
+		p.ui.BeginLinef("\r%s...", p.name)
+		p.renderedLen = 0
+	}
+}
+
+// padding overwrites what is left of a longer, earlier rendering
+func (p *progressBar) padding(length int) string {
+	if length >= p.renderedLen {
+		return ""
+	}
+	return strings.Repeat(" ", p.renderedLen-length)
+}
+
+type progressWriter struct {
+	progress Progress
+}
+
+// NewProgressWriter returns a Writer adding the bytes written to it to the
+// progress, for use with io.Copy or io.MultiWriter.
+func NewProgressWriter(progress Progress) io.Writer {
+	return progressWriter{progress: progress}
+}
+
+func (w progressWriter) Write(p []byte) (int, error) {
+	w.progress.Add(int64(len(p)))
+	return len(p), nil
+}
@@ -10,6 +10,11 @@ import (
 
 type Stage interface {
 	Perform(name string, closure func() error) error
+
+	// PerformWithProgress is Perform for steps that report their progress,
+	// shown as a bar with the expected remaining time.
+	PerformWithProgress(name string, closure func(Progress) error) error
+
 	PerformComplex(name string, closure func(Stage) error) error
 }
 
@@ -58,6 +63,15 @@ func (s *stage) Perform(name string, closure func() error) error {
 	return nil
 }
 
+func (s *stage) PerformWithProgress(name string, closure func(Progress) error) error {
+	return s.Perform(name, func() error {
+		progress := newProgressBar(s.ui, s.timeService, name)
+		defer progress.clear()
+
+		return closure(progress)
+	})
+}
+
 func (s *stage) PerformComplex(name string, closure func(Stage) error) error {
 	// exit simple mode (always line break when entering a new complex stage)
 	s.ui.BeginLinef("\n")
@@ -83,3 +97,28 @@ func (s *stage) elapsedSince(startTime time.Time) string {
 func (s *stage) newSubStage() Stage {
 	return NewStage(NewIndentingUI(s.ui), s.timeService, s.logger)
 }
+
+func (s *stage) NewBufferedStage() (Stage, func()) {
+	ui := newBufferedUI()
+
+	buffered := &stage{
+		ui:          ui,
+		timeService: s.timeService,
+
+		logTag: s.logTag,
+		logger: s.logger,
+
+		simpleMode: true,
+	}
+
+	return buffered, func() {
+		// steps of other stages may have left simple mode since the buffered
+		// stage was created, so its first simple step enters it here
+		if line, found := ui.firstLine(); found && line != "\n" && !s.simpleMode {
+			s.ui.BeginLinef("\n")
+		}
+
+		ui.flushTo(s.ui)
+		s.simpleMode = buffered.simpleMode
+	}
+}
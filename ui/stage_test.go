@@ -93,6 +93,53 @@ var _ = Describe("Stage", func() {
 		})
 	})
 
+	Describe("PerformWithProgress", func() {
+		It("renders a progress bar with the expected remaining time and clears it when finished", func() {
+			err := stage.PerformWithProgress("Progress stage", func(progress Progress) error {
+				progress.SetTotal(4)
+
+				fakeTimeService.Increment(time.Second)
+				progress.Add(1)
+
+				// not redrawn this soon after the last rendering
+				fakeTimeService.Increment(100 * time.Millisecond)
+				progress.Add(1)
+
+				progress.Add(2)
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			expectedOutput := "Progress stage..." +
+				"\rProgress stage... [                    ]   0%" +
+				"\rProgress stage... [=====               ]  25% ETA 00:00:03" +
+				"\rProgress stage... [====================] 100%" + strings.Repeat(" ", 13) +
+				"\rProgress stage... " + strings.Repeat(" ", 27) +
+				"\rProgress stage..." +
+				" Finished (00:00:01)\n"
+			Expect(uiOut.String()).To(Equal(expectedOutput))
+		})
+
+		It("does not render a bar until the total is known", func() {
+			err := stage.PerformWithProgress("Progress stage", func(progress Progress) error {
+				progress.Add(1)
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(uiOut.String()).To(Equal("Progress stage... Finished (00:00:00)\n"))
+		})
+
+		It("fails on error", func() {
+			err := stage.PerformWithProgress("Progress stage", func(progress Progress) error {
+				progress.SetTotal(2)
+				return bosherr.Error("fake-stage-1-error")
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(uiOut.String()).To(HaveSuffix("\rProgress stage... Failed (00:00:00)\n"))
+		})
+	})
+
 	Describe("PerformComplex", func() {
 		It("prints a multi-line stage (depth: 1)", func() {
 			actionsPerformed := []string{}
@@ -0,0 +1,75 @@
+package ui
+
+import (
+	"sync"
+)
+
+type synchronizedStage struct {
+	stage Stage
+	lock  *sync.Mutex
+}
+
+// NewSynchronizedStage returns a Stage that can be performed from several
+// goroutines at once. When the wrapped stage is a BufferableStage, the
+// closures run concurrently and the output of every step is held back and
+// written at once when the step is done, so that lines of concurrent steps
+// do not interleave. Otherwise the steps are performed one at a time.
+func NewSynchronizedStage(stage Stage) Stage {
+	return &synchronizedStage{
+		stage: stage,
+		lock:  &sync.Mutex{},
+	}
+}
+
+func (s *synchronizedStage) Perform(name string, closure func() error) error {
+	stage, done := s.step()
+	defer done()
+
+	return stage.Perform(name, closure)
+}
+
+func (s *synchronizedStage) PerformWithProgress(name string, closure func(Progress) error) error {
+	stage, done := s.step()
+	defer done()
+
+	return stage.PerformWithProgress(name, closure)
+}
+
+func (s *synchronizedStage) PerformComplex(name string, closure func(Stage) error) error {
+	stage, done := s.step()
+	defer done()
+
+	return stage.PerformComplex(name, closure)
+}
+
+// NewBufferedStage returns a Stage whose steps, e.g. all the steps of
+// updating one instance, are written at once when flush is called.
+func (s *synchronizedStage) NewBufferedStage() (Stage, func()) {
+	if _, ok := s.stage.(BufferableStage); !ok {
+		return s, func() {}
+	}
+
+	return s.step()
+}
+
+// step returns the stage to perform a step with and the func to call once
+// the step is done.
+func (s *synchronizedStage) step() (Stage, func()) {
+	s.lock.Lock()
+
+	bufferable, ok := s.stage.(BufferableStage)
+	if !ok {
+		// the output cannot be held back, so the whole step holds the lock
+		return s.stage, s.lock.Unlock
+	}
+
+	buffered, flush := bufferable.NewBufferedStage()
+	s.lock.Unlock()
+
+	return buffered, func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		flush()
+	}
+}
@@ -0,0 +1,199 @@
+package ui_test
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/cloudfoundry/bosh-cli/ui"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	"github.com/pivotal-golang/clock/fakeclock"
+)
+
+var _ = Describe("SynchronizedStage", func() {
+	var (
+		stage Stage
+		uiOut *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		uiOut = bytes.NewBufferString("")
+		ui := NewWriterUI(uiOut, bytes.NewBufferString(""), logger)
+
+		stage = NewSynchronizedStage(NewStage(ui, fakeclock.NewFakeClock(time.Now()), logger))
+	})
+
+	Describe("Perform", func() {
+		It("runs the closures of concurrent steps at the same time", func() {
+			firstStarted := make(chan struct{})
+			secondStarted := make(chan struct{})
+
+			wg := &sync.WaitGroup{}
+			wg.Add(2)
+
+			go func() {
+				defer wg.Done()
+				stage.Perform("First step", func() error {
+					close(firstStarted)
+					<-secondStarted
+					return nil
+				})
+			}()
+
+			go func() {
+				defer wg.Done()
+				<-firstStarted
+				stage.Perform("Second step", func() error {
+					close(secondStarted)
+					return nil
+				})
+			}()
+
+			wg.Wait()
+
+			Expect(uiOut.String()).To(ContainSubstring("Second step... Finished"))
+			Expect(uiOut.String()).To(ContainSubstring("First step... Finished"))
+		})
+
+		It("writes the output of a step at once when it is done", func() {
+			firstStarted := make(chan struct{})
+			secondDone := make(chan struct{})
+
+			wg := &sync.WaitGroup{}
+			wg.Add(2)
+
+			go func() {
+				defer wg.Done()
+				stage.Perform("First step", func() error {
+					close(firstStarted)
+					<-secondDone
+					return nil
+				})
+			}()
+
+			go func() {
+				defer wg.Done()
+				<-firstStarted
+				stage.Perform("Second step", func() error { return nil })
+				close(secondDone)
+			}()
+
+			wg.Wait()
+
+			Expect(uiOut.String()).To(MatchRegexp("^Second step... Finished \\(.*\\)\nFirst step... Finished \\(.*\\)\n$"))
+		})
+
+		It("returns the error of the closure", func() {
+			err := stage.Perform("Failing step", func() error {
+				return errors.New("fake-step-error")
+			})
+			Expect(err).To(MatchError("fake-step-error"))
+			Expect(uiOut.String()).To(ContainSubstring("Failing step... Failed"))
+		})
+	})
+
+	Describe("NewBufferedStage", func() {
+		It("writes the steps performed with the buffered stage at once when flushed", func() {
+			firstStarted := make(chan struct{})
+			secondDone := make(chan struct{})
+
+			wg := &sync.WaitGroup{}
+			wg.Add(2)
+
+			go func() {
+				defer wg.Done()
+
+				instanceStage, flush := NewBufferedStage(stage)
+				defer flush()
+
+				instanceStage.Perform("First instance step 1", func() error {
+					close(firstStarted)
+					<-secondDone
+					return nil
+				})
+				instanceStage.Perform("First instance step 2", func() error { return nil })
+			}()
+
+			go func() {
+				defer wg.Done()
+				<-firstStarted
+
+				instanceStage, flush := NewBufferedStage(stage)
+
+				instanceStage.Perform("Second instance step 1", func() error { return nil })
+				instanceStage.PerformComplex("Second instance step 2", func(subStage Stage) error {
+					return subStage.Perform("Sub-step", func() error { return nil })
+				})
+
+				flush()
+				close(secondDone)
+			}()
+
+			wg.Wait()
+
+			output := uiOut.String()
+			Expect(output).To(MatchRegexp("(?s)^Second instance step 1... Finished.*Started Second instance step 2\n  Sub-step... Finished.*Finished Second instance step 2 .*\n\nFirst instance step 1... Finished.*\nFirst instance step 2... Finished \\(.*\\)\n$"))
+		})
+
+		It("returns the stage itself when the wrapped stage cannot buffer its output", func() {
+			fakeStage := &unbufferedStage{}
+			stage = NewSynchronizedStage(fakeStage)
+
+			buffered, flush := NewBufferedStage(stage)
+			Expect(buffered).To(Equal(stage))
+			flush()
+
+			err := buffered.Perform("Step", func() error { return nil })
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeStage.names).To(Equal([]string{"Step"}))
+		})
+	})
+
+	Describe("PerformWithProgress", func() {
+		It("reports the progress of the step", func() {
+			err := stage.PerformWithProgress("Progress step", func(progress Progress) error {
+				progress.SetTotal(2)
+				progress.Add(2)
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(uiOut.String()).To(ContainSubstring("Progress step... [====================] 100%"))
+		})
+	})
+
+	Describe("PerformComplex", func() {
+		It("synchronizes the sub-stage", func() {
+			err := stage.PerformComplex("complex stage", func(subStage Stage) error {
+				return subStage.Perform("Simple step", func() error { return nil })
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(uiOut.String()).To(ContainSubstring("Started complex stage"))
+			Expect(uiOut.String()).To(ContainSubstring("  Simple step... Finished"))
+			Expect(uiOut.String()).To(ContainSubstring("Finished complex stage"))
+		})
+	})
+})
+
+type unbufferedStage struct {
+	names []string
+}
+
+func (s *unbufferedStage) Perform(name string, closure func() error) error {
+	s.names = append(s.names, name)
+	return closure()
+}
+
+func (s *unbufferedStage) PerformWithProgress(name string, closure func(Progress) error) error {
+	return s.Perform(name, func() error { return closure(nil) })
+}
+
+func (s *unbufferedStage) PerformComplex(name string, closure func(Stage) error) error {
+	return s.Perform(name, func() error { return closure(s) })
+}
@@ -27,6 +27,12 @@ type Table struct {
 	BackgroundStr    string
 	BorderStr        string
 	Transpose        bool
+
+	// Wraps values of the widest columns so that rows fit; 0 means no limit
+	MaxWidth int
+
+	// Sets MaxWidth to the width of the terminal the table is printed to
+	WrapToTerminal bool
 }
 
 type Header struct {
@@ -85,6 +85,7 @@ func (t Table) Print(w io.Writer) error {
 	}
 
 	writer := NewWriter(w, "-", t.BackgroundStr, t.BorderStr)
+	writer.SetMaxWidth(t.MaxWidth)
 	rowCount := len(t.Rows)
 	for _, section := range t.Sections {
 		rowCount += len(section.Rows)
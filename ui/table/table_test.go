@@ -339,6 +339,27 @@ r2c1|r2c2..|
 `))
 		})
 
+		It("prints values of the widest columns wrapped to fit MaxWidth", func() {
+			table := Table{
+				Content: "things",
+
+				Rows: [][]Value{
+					{ValueString{"r1c1"}, ValueString{"r1c2-long-value"}},
+					{ValueString{"r2c1"}, ValueString{"r2c2"}},
+				},
+
+				BackgroundStr: ".",
+				BorderStr:     "|",
+				MaxWidth:      16,
+			}
+			table.Print(buf)
+			Expect("\n" + buf.String()).To(Equal(`
+r1c1|r1c2-long-|
+....|value.....|
+r2c1|r2c2......|
+`))
+		})
+
 		It("removes duplicate values in the first column", func() {
 			table := Table{
 				Content: "things",
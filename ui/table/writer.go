@@ -5,6 +5,7 @@ import (
 	"io"
 	"reflect"
 	"strings"
+	"unicode/utf8"
 )
 
 type Writer struct {
@@ -13,8 +14,9 @@ type Writer struct {
 	bgStr     string
 	borderStr string
 
-	rows   []writerRow
-	widths map[int]int
+	rows     []writerRow
+	widths   map[int]int
+	maxWidth int
 }
 
 type writerCell struct {
@@ -42,6 +44,13 @@ func NewWriter(w io.Writer, emptyStr, bgStr, borderStr string) *Writer {
 	}
 }
 
+// SetMaxWidth makes Flush narrow the widest columns, wrapping their values,
+// until rows fit into maxWidth characters. Zero means no limit. Widths are
+// counted in characters rather than bytes.
+func (w *Writer) SetMaxWidth(maxWidth int) {
+	w.maxWidth = maxWidth
+}
+
 func (w *Writer) Write(headers []Header, vals []Value) {
 	rowsToAdd := 1
 	colsWithRows := [][]writerCell{}
@@ -77,8 +86,8 @@ func (w *Writer) Write(headers []Header, vals []Value) {
 		rowsInColLen := len(rowsInCol)
 
 		for _, cell := range rowsInCol {
-			if len(cell.String) > w.widths[visibleHeaderIndex] {
-				w.widths[visibleHeaderIndex] = len(cell.String)
+			if width := utf8.RuneCountInString(cell.String); width > w.widths[visibleHeaderIndex] {
+				w.widths[visibleHeaderIndex] = width
 			}
 		}
 
@@ -112,7 +121,9 @@ func (w *Writer) Write(headers []Header, vals []Value) {
 }
 
 func (w *Writer) Flush() error {
-	for _, row := range w.rows {
+	w.fitWidths()
+
+	for _, row := range w.wrappedRows() {
 		if row.IsSpacer {
 			_, err := fmt.Fprintln(w.w)
 			if err != nil {
@@ -134,9 +145,9 @@ func (w *Writer) Flush() error {
 				}
 			}
 
-			paddingSize := w.widths[colIdx] - len(col.String)
+			paddingSize := w.widths[colIdx] - utf8.RuneCountInString(col.String)
 
-			_, err := fmt.Fprintf(w.w, strings.Repeat(w.bgStr, paddingSize)+w.borderStr)
+			_, err := fmt.Fprint(w.w, strings.Repeat(w.bgStr, paddingSize)+w.borderStr)
 			if err != nil {
 				return err
 			}
@@ -150,3 +161,83 @@ func (w *Writer) Flush() error {
 
 	return nil
 }
+
+// minWrappedWidth is the width below which columns are not narrowed further
+// even if rows do not fit into the max width.
+const minWrappedWidth = 10
+
+func (w *Writer) fitWidths() {
+	if w.maxWidth <= 0 {
+		return
+	}
+
+	total := 0
+	for _, width := range w.widths {
+		total += width + utf8.RuneCountInString(w.borderStr)
+	}
+
+	for total > w.maxWidth {
+		widestIdx := -1
+		for idx, width := range w.widths {
+			if width > minWrappedWidth && (widestIdx == -1 || width > w.widths[widestIdx] ||
+				(width == w.widths[widestIdx] && idx < widestIdx)) {
+				widestIdx = idx
+			}
+		}
+
+		if widestIdx == -1 {
+			return
+		}
+
+		w.widths[widestIdx]--
+		total--
+	}
+}
+
+func (w *Writer) wrappedRows() []writerRow {
+	var rows []writerRow
+
+	for _, row := range w.rows {
+		if row.IsSpacer {
+			rows = append(rows, row)
+			continue
+		}
+
+		var colsWithLines [][]writerCell
+		linesToAdd := 1
+
+		for colIdx, col := range row.Values {
+			var lines []writerCell
+
+			// wrapped by characters so that multi-byte characters are not split
+			str := []rune(col.String)
+			for len(str) > w.widths[colIdx] {
+				lines = append(lines, writerCell{Value: col.Value, String: string(str[:w.widths[colIdx]])})
+				str = str[w.widths[colIdx]:]
+			}
+			lines = append(lines, writerCell{Value: col.Value, String: string(str), IsEmpty: col.IsEmpty})
+
+			if len(lines) > linesToAdd {
+				linesToAdd = len(lines)
+			}
+
+			colsWithLines = append(colsWithLines, lines)
+		}
+
+		for i := 0; i < linesToAdd; i++ {
+			var wrappedRow writerRow
+
+			for _, lines := range colsWithLines {
+				if i < len(lines) {
+					wrappedRow.Values = append(wrappedRow.Values, lines[i])
+				} else {
+					wrappedRow.Values = append(wrappedRow.Values, writerCell{})
+				}
+			}
+
+			rows = append(rows, wrappedRow)
+		}
+	}
+
+	return rows
+}
@@ -129,5 +129,48 @@ c0r1||>c1r1<...||
 ....||>another<||
 `))
 		})
+
+		Context("when max width is set", func() {
+			It("wraps values of the widest column so that rows fit", func() {
+				writer.SetMaxWidth(24)
+				writer.Write(visibleHeaders, []Value{ValueString{"c0r0"}, ValueString{"c1r0-long-value-to-wrap"}})
+				writer.Write(visibleHeaders, []Value{ValueString{"c0r1"}, ValueString{"c1r1"}})
+				writer.Flush()
+				Expect("\n" + buf.String()).To(Equal(`
+c0r0||c1r0-long-value-||
+....||to-wrap.........||
+c0r1||c1r1............||
+`))
+			})
+
+			It("does not narrow columns that are not wider than 10 characters", func() {
+				writer.SetMaxWidth(10)
+				writer.Write(visibleHeaders, []Value{ValueString{"c0r0-value"}, ValueString{"c1r0-long-value"}})
+				writer.Flush()
+				Expect("\n" + buf.String()).To(Equal(`
+c0r0-value||c1r0-long-||
+..........||value.....||
+`))
+			})
+
+			It("does not wrap values when rows fit", func() {
+				writer.SetMaxWidth(12)
+				writer.Write(visibleHeaders, []Value{ValueString{"c0r0"}, ValueString{"c1r0"}})
+				writer.Flush()
+				Expect(buf.String()).To(Equal("c0r0||c1r0||\n"))
+			})
+
+			It("counts and wraps multi-byte characters as single characters", func() {
+				writer.SetMaxWidth(20)
+				writer.Write(visibleHeaders, []Value{ValueString{"c0r0"}, ValueString{"ünïcödé-välüé-tö-wräp"}})
+				writer.Write(visibleHeaders, []Value{ValueString{"c0r1"}, ValueString{"ä"}})
+				writer.Flush()
+				Expect("\n" + buf.String()).To(Equal(`
+c0r0||ünïcödé-välü||
+....||é-tö-wräp...||
+c0r1||ä...........||
+`))
+			})
+		})
 	})
 })
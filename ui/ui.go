@@ -10,10 +10,15 @@ import (
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	"github.com/mattn/go-isatty"
 	"github.com/vito/go-interact/interact"
+	"golang.org/x/crypto/ssh/terminal"
 
 	. "github.com/cloudfoundry/bosh-cli/ui/table"
 )
 
+// ErrConfirmationDeclined is returned by AskForConfirmation when the user
+// does not confirm to continue.
+var ErrConfirmationDeclined = errors.New("Stopped")
+
 type WriterUI struct {
 	outWriter io.Writer
 	errWriter io.Writer
@@ -41,6 +46,23 @@ func (ui *WriterUI) IsTTY() bool {
 	return ok && isatty.IsTerminal(file.Fd())
 }
 
+// terminalWidth returns the width of the terminal the output is written to,
+// or 0 if the output is not a terminal so that tables are never wrapped when
+// piped to other programs.
+func (ui *WriterUI) terminalWidth() int {
+	file, ok := ui.outWriter.(*os.File)
+	if !ok || !isatty.IsTerminal(file.Fd()) {
+		return 0
+	}
+
+	width, _, err := terminal.GetSize(int(file.Fd()))
+	if err != nil {
+		return 0
+	}
+
+	return width
+}
+
 // ErrorLinef starts and ends a text error line
 func (ui *WriterUI) ErrorLinef(pattern string, args ...interface{}) {
 	message := fmt.Sprintf(pattern, args...)
@@ -92,6 +114,10 @@ func (ui *WriterUI) PrintErrorBlock(block string) {
 }
 
 func (ui *WriterUI) PrintTable(table Table) {
+	if table.WrapToTerminal && table.MaxWidth == 0 {
+		table.MaxWidth = ui.terminalWidth()
+	}
+
 	err := table.Print(ui.outWriter)
 	if err != nil {
 		ui.logger.Error(ui.logTag, "UI.PrintTable failed: %s", err)
@@ -146,7 +172,7 @@ func (ui *WriterUI) AskForConfirmation() error {
 	}
 
 	if falseByDefault == false {
-		return errors.New("Stopped")
+		return ErrConfirmationDeclined
 	}
 
 	return nil